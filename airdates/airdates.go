@@ -0,0 +1,222 @@
+// Program airdates checks the air dates of the episodes in the site
+// repository against the broadcast times of their YouTube videos, and
+// proposes corrections where they disagree.
+//
+// You must provide a YOUTUBE_API_KEY credential with a YouTube Data API key
+// (run "doctor" to see where credentials are sought).
+//
+// The air time of a video is the actual start of its live broadcast, or its
+// scheduled start, or failing those the time it was published, converted to
+// the time zone of the show schedule. An episode whose date differs from the
+// air date of its video by more than -tolerance days is reported, and a
+// correction proposed. Like summarize, corrections are written to a proposals
+// file for review:
+//
+//	airdates -o proposals.json
+//
+// Delete the proposals that should not be applied, set "reviewed" to true on
+// the rest, and apply them:
+//
+//	airdates -apply proposals.json
+//
+// Applying a correction updates the date in the episode file and renames the
+// file to match the new date.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath   = flag.String("o", "", "Write proposals to this file (default stdout)")
+	applyPath = flag.String("apply", "", "Apply the reviewed proposals in this file")
+	tolerance = flag.Int("tolerance", 1, "Report dates that differ by more than this many days")
+)
+
+// A proposal is a corrected air date for an episode, pending human review.
+type proposal struct {
+	Episode  ilof.Label `json:"episode"`
+	Path     string     `json:"path"`
+	Date     ilof.Date  `json:"date"`     // current date
+	AirDate  ilof.Date  `json:"airDate"`  // proposed date
+	NewPath  string     `json:"newPath"`  // path after renaming
+	Evidence string     `json:"evidence"` // the video time used
+	Reviewed bool       `json:"reviewed"` // set to true by a human to apply
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	if *applyPath != "" {
+		if err := applyProposals(*applyPath); err != nil {
+			log.Fatalf("Applying proposals: %v", err)
+		}
+		return
+	}
+
+	apiKey, err := ilof.YouTubeAPIKey.Value()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sched, err := ilof.LoadSchedule(repo.ScheduleFile)
+	if err != nil {
+		log.Fatalf("Loading schedule: %v", err)
+	}
+
+	type episode struct {
+		path string
+		ep   *ilof.Episode
+	}
+	byVideo := make(map[string]episode)
+	var ids []string
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		if id, ok := ilof.YouTubeVideoID(ep.YouTubeURL); ok {
+			byVideo[id] = episode{path, ep}
+			ids = append(ids, id)
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	log.Printf("Found %d episodes with videos", len(ids))
+
+	times, err := ilof.YouTubeVideoTimes(context.Background(), ids, apiKey)
+	if err != nil {
+		log.Fatalf("Fetching video times: %v", err)
+	}
+	props := []*proposal{}
+	for _, id := range ids {
+		e := byVideo[id]
+		vt, ok := times[id]
+		if !ok {
+			log.Printf("- Episode %s: video %s not found", e.ep.Episode, id)
+			continue
+		}
+		at := vt.AirTime().In(sched.Location())
+		air := ilof.Date(time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC))
+		if days := dayDiff(e.ep.Date, air); days <= *tolerance {
+			continue
+		}
+		props = append(props, &proposal{
+			Episode:  e.ep.Episode,
+			Path:     e.path,
+			Date:     e.ep.Date,
+			AirDate:  air,
+			NewPath:  renamed(e.path, air),
+			Evidence: fmt.Sprintf("video %s %s at %s", id, timeSource(vt), at.Format(time.RFC3339)),
+		})
+	}
+	sort.Slice(props, func(i, j int) bool { return props[i].Path < props[j].Path })
+
+	data, err := json.MarshalIndent(props, "", "  ")
+	if err != nil {
+		log.Fatalf("Encoding proposals: %v", err)
+	}
+	data = append(data, '\n')
+	if *outPath == "" {
+		os.Stdout.Write(data)
+	} else if err := atomicfile.WriteData(*outPath, data, 0644); err != nil {
+		log.Fatalf("Writing proposals: %v", err)
+	}
+	log.Printf("Proposed %d date corrections; review them and set \"reviewed\": true to apply", len(props))
+}
+
+// dayDiff returns the absolute difference between a and b in whole days.
+func dayDiff(a, b ilof.Date) int {
+	d := time.Time(a).Sub(time.Time(b))
+	if d < 0 {
+		d = -d
+	}
+	return int(d.Round(time.Hour).Hours()) / 24
+}
+
+// timeSource describes which time of v was used as its air time.
+func timeSource(v *ilof.VideoTimes) string {
+	if !v.ActualStart.IsZero() {
+		return "broadcast"
+	} else if !v.Scheduled.IsZero() {
+		return "scheduled"
+	}
+	return "published"
+}
+
+// renamed returns path with its leading date replaced by d. Episode file
+// names begin with the air date, as "2006-01-02-0100.md".
+func renamed(path string, d ilof.Date) string {
+	dir, base := filepath.Split(path)
+	if ilof.IsEpisodeFileName(base) {
+		base = d.String() + base[len("2006-01-02"):]
+	}
+	return filepath.Join(dir, base)
+}
+
+// applyProposals applies the reviewed date corrections in the file at path.
+// Episodes whose dates have changed since the proposal was generated are not
+// changed.
+func applyProposals(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var props []*proposal
+	if err := json.Unmarshal(data, &props); err != nil {
+		return fmt.Errorf("decoding proposals: %w", err)
+	}
+	var numApplied, numSkipped int
+	for _, p := range props {
+		if !p.Reviewed {
+			numSkipped++
+			continue
+		}
+		ep, err := ilof.LoadEpisode(p.Path)
+		if err != nil {
+			return err
+		} else if ep.Episode != p.Episode {
+			return fmt.Errorf("%s: proposal is for episode %s, file has episode %s", p.Path, p.Episode, ep.Episode)
+		} else if ep.Date.String() != p.Date.String() {
+			log.Printf("- Episode %s date has changed to %s; skipped", ep.Episode, ep.Date)
+			continue
+		}
+		newPath := p.NewPath
+		if newPath == "" {
+			newPath = p.Path
+		}
+		if newPath != p.Path {
+			if _, err := os.Stat(newPath); err == nil {
+				return fmt.Errorf("%s: cannot rename, %s already exists", p.Path, newPath)
+			}
+		}
+
+		// Write the new file before removing the old one, so that a failure
+		// does not lose the episode.
+		ep.Date = p.AirDate
+		if err := ilof.WriteEpisode(newPath, ep); err != nil {
+			return err
+		}
+		if newPath != p.Path {
+			if err := os.Remove(p.Path); err != nil {
+				return err
+			}
+			fmt.Printf("%s: date %s → %s, renamed to %s\n", p.Path, p.Date, p.AirDate, filepath.Base(newPath))
+		} else {
+			fmt.Printf("%s: date %s → %s\n", p.Path, p.Date, p.AirDate)
+		}
+		numApplied++
+	}
+	log.Printf("Applied %d corrections (%d not reviewed)", numApplied, numSkipped)
+	return nil
+}
@@ -0,0 +1,157 @@
+// Program backfill fills in missing metadata in the episode files of the site
+// repository, according to a selection of rules.
+//
+// Each rule examines a single episode and may update some of its fields.
+// Changes are reported per file; with -dry-run no files are modified.
+//
+// The episodes examined can be restricted by air date (-since, -until), by
+// label (-from, -to), by -season, and to episodes lacking a value for one of
+// the -missing fields. Episode files outside the date and label ranges are
+// skipped by name, without being read.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	ruleNames = flag.String("rules", "", "Comma-separated rules to apply (see -help)")
+	doDryRun  = flag.Bool("dry-run", false, "Report changes without modifying any files")
+
+	sinceFlag   = flag.String("since", "", "Only episodes aired on or after this date (YYYY-MM-DD)")
+	untilFlag   = flag.String("until", "", "Only episodes aired on or before this date (YYYY-MM-DD)")
+	fromFlag    = flag.String("from", "", "Only episodes with this label or later")
+	toFlag      = flag.String("to", "", "Only episodes with this label or earlier")
+	seasonFlag  = flag.Int("season", 0, "Only episodes in this season")
+	missingFlag = flag.String("missing", "", "Only episodes lacking one of these comma-separated fields")
+)
+
+// A rule describes a single kind of backfill.
+type rule struct {
+	help string
+
+	// init, if non-nil, is called once before the rule is applied.
+	init func(context.Context) error
+
+	// apply updates ep as required and returns a description of each change
+	// made. It returns no changes if ep needs no update.
+	apply func(ep *ilof.Episode) []string
+}
+
+// rules is the registry of available rules, by name.
+var rules = map[string]*rule{}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %[1]s -rules <rule>,... [-dry-run] [filters]
+
+Apply backfill rules to the episode files of the site repository.
+The following rules are defined:
+
+`, filepath.Base(os.Args[0]))
+		var names []string
+		for name := range rules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, rules[name].help)
+		}
+		fmt.Fprintln(os.Stderr, "\nOptions:")
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if *ruleNames == "" {
+		log.Fatal("You must specify at least one rule with -rules")
+	}
+	var active []*rule
+	for _, name := range strings.Split(*ruleNames, ",") {
+		r, ok := rules[name]
+		if !ok {
+			log.Fatalf("Unknown rule %q", name)
+		}
+		active = append(active, r)
+	}
+	filter, err := parseFilter()
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	ctx := context.Background()
+	for _, r := range active {
+		if r.init == nil {
+			continue
+		} else if err := r.init(ctx); err != nil {
+			log.Fatalf("Initializing rules: %v", err)
+		}
+	}
+
+	var numFiles, numChanges int
+	if err := ilof.ForEachEpisodeWith(repo.EpisodeDir, filter, func(path string, ep *ilof.Episode) error {
+		var changes []string
+		for _, r := range active {
+			changes = append(changes, r.apply(ep)...)
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		numFiles++
+		numChanges += len(changes)
+		fmt.Printf("%s:\n", path)
+		for _, c := range changes {
+			fmt.Printf("  - %s\n", c)
+		}
+		if *doDryRun {
+			return nil
+		}
+		return ilof.WriteEpisode(path, ep)
+	}); err != nil {
+		log.Fatalf("Updating episodes: %v", err)
+	}
+	if *doDryRun {
+		log.Printf("Found %d changes to %d files (dry run, no files modified)", numChanges, numFiles)
+	} else {
+		log.Printf("Made %d changes to %d files", numChanges, numFiles)
+	}
+}
+
+// parseFilter returns the episode filter described by the flags.
+func parseFilter() (*ilof.EpisodeFilter, error) {
+	f := &ilof.EpisodeFilter{
+		From:   ilof.ParseLabel(*fromFlag),
+		To:     ilof.ParseLabel(*toFlag),
+		Season: *seasonFlag,
+	}
+	for _, d := range []struct {
+		name, value string
+		date        *ilof.Date
+	}{{"since", *sinceFlag, &f.Since}, {"until", *untilFlag, &f.Until}} {
+		if d.value == "" {
+			continue
+		} else if err := d.date.UnmarshalText([]byte(d.value)); err != nil {
+			return nil, fmt.Errorf("invalid -%s date: %w", d.name, err)
+		}
+	}
+	for _, name := range strings.Split(*missingFlag, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			f.Missing = append(f.Missing, name)
+		}
+	}
+	return f, f.Check()
+}
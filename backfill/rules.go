@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var defaultTags = flag.String("default-tags", "", "Comma-separated tags for the tags rule")
+
+func init() {
+	rules["season"] = &rule{
+		help: "Assign seasons from " + repo.SeasonFile,
+		init: initSeasons,
+		apply: func(ep *ilof.Episode) []string {
+			num := seasons.SeasonOf(ep.Date)
+			if num == 0 || ep.Season == num {
+				return nil
+			}
+			old := ep.Season
+			ep.Season = num
+			return []string{fmt.Sprintf("season: %d → %d", old, num)}
+		},
+	}
+	rules["duration"] = &rule{
+		help:  "Fill missing durations from the Acast feed",
+		init:  initDurations,
+		apply: applyDuration,
+	}
+	rules["video-duration"] = &rule{
+		help:  "Fill missing durations from YouTube video metadata",
+		init:  initVideoDurations,
+		apply: applyVideoDuration,
+	}
+	rules["tags"] = &rule{
+		help: "Add -default-tags to episodes with no tags",
+		apply: func(ep *ilof.Episode) []string {
+			if len(ep.AllTags()) != 0 || *defaultTags == "" {
+				return nil
+			}
+			var out []string
+			for _, tag := range strings.Split(*defaultTags, ",") {
+				if tag = strings.TrimSpace(tag); tag == "" {
+					continue
+				} else if ep.HasTag(tag) {
+					out = append(out, "duplicate tag "+tag+" (ignored)")
+					continue
+				}
+				ep.AddTag(tag)
+				out = append(out, "add tag "+tag)
+			}
+			return out
+		},
+	}
+	rules["chapters"] = &rule{
+		help: "Fill missing chapters from timestamps in the episode text",
+		apply: func(ep *ilof.Episode) []string {
+			if len(ep.Chapters) != 0 {
+				return nil
+			}
+			ep.Chapters = ilof.ParseChapters(ep.Detail)
+			if len(ep.Chapters) == 0 {
+				return nil
+			}
+			return []string{fmt.Sprintf("chapters: %d from episode text", len(ep.Chapters))}
+		},
+	}
+	rules["urls"] = &rule{
+		help:  "Normalize stream and audio URLs",
+		apply: applyURLs,
+	}
+}
+
+var seasons ilof.Seasons
+
+func initSeasons(context.Context) error {
+	ss, err := ilof.LoadSeasons(repo.SeasonFile)
+	if err != nil {
+		return fmt.Errorf("loading seasons: %w", err)
+	}
+	seasons = ss
+	return nil
+}
+
+// durations maps Acast page links to the durations reported in the feed.
+var durations = make(map[string]time.Duration)
+
+func initDurations(ctx context.Context) error {
+	audio, err := ilof.LoadAcastFeed(ctx, ilof.AcastFeedURL)
+	if err != nil {
+		return fmt.Errorf("loading acast feed: %w", err)
+	}
+	for _, ep := range audio {
+		if ep.Duration > 0 {
+			durations[ep.PageLink] = ep.Duration
+		}
+	}
+	log.Printf("Loaded %d audio durations", len(durations))
+	return nil
+}
+
+func applyDuration(ep *ilof.Episode) []string {
+	if ep.Duration != "" || ep.AcastURL == "" {
+		return nil
+	}
+	d, ok := durations[ilof.NormalizeURL(ep.AcastURL)]
+	if !ok {
+		return nil
+	}
+	ep.Duration = d.Round(time.Second).String()
+	return []string{"duration: " + ep.Duration}
+}
+
+// videoDurations maps YouTube video IDs to the durations of the videos.
+var videoDurations = make(map[string]time.Duration)
+
+func initVideoDurations(ctx context.Context) error {
+	apiKey, err := ilof.YouTubeAPIKey.Value()
+	if err != nil {
+		return err
+	}
+	var ids []string
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		if id, ok := ilof.YouTubeVideoID(ep.YouTubeURL); ok && ep.Duration == "" {
+			ids = append(ids, id)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	infos, err := ilof.YouTubeVideosInfo(ctx, ids, apiKey)
+	if err != nil {
+		return fmt.Errorf("looking up videos: %w", err)
+	}
+	for id, info := range infos {
+		if info.Duration > 0 {
+			videoDurations[id] = info.Duration
+		}
+	}
+	log.Printf("Loaded %d video durations", len(videoDurations))
+	return nil
+}
+
+func applyVideoDuration(ep *ilof.Episode) []string {
+	if ep.Duration != "" {
+		return nil
+	}
+	id, _ := ilof.YouTubeVideoID(ep.YouTubeURL)
+	d, ok := videoDurations[id]
+	if !ok {
+		return nil
+	}
+	ep.Duration = d.Round(time.Second).String()
+	return []string{"duration: " + ep.Duration + " (from video)"}
+}
+
+func applyURLs(ep *ilof.Episode) []string {
+	var out []string
+	fix := func(name string, s *string) {
+		if *s == "" {
+			return
+		}
+		if t := ilof.NormalizeURL(*s); t != *s {
+			out = append(out, fmt.Sprintf("%s: %s → %s", name, *s, t))
+			*s = t
+		}
+	}
+	fix("youtube", &ep.YouTubeURL)
+	fix("crowdcast", &ep.CrowdcastURL)
+	fix("acast", &ep.AcastURL)
+	return out
+}
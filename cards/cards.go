@@ -0,0 +1,113 @@
+// Program cards generates the social card metadata for the episodes in the
+// site repository, so that shared links to episode pages show a title,
+// description, and image.
+//
+// The output is a JSON object mapping each episode label to its card. The
+// site templates can render the card for an episode page as meta tags, e.g.:
+//
+//	{% assign card = site.data.cards[page.episode] %}
+//	<meta property="og:title" content="{{ card.title | escape }}">
+//	<meta property="og:description" content="{{ card.description | escape }}">
+//	<meta property="og:image" content="{{ card.image | absolute_url }}">
+//	<meta name="twitter:card" content="{{ card.card }}">
+//
+// The image for an episode is the thumbnail of its video, unless the image
+// directory has a file named for the episode label (for example, 100.png),
+// in which case that is used instead. By default the image directory is
+// "cards" in the asset directory of the repository layout; set -images to
+// use another, or -images="" to use no custom images.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath  = flag.String("o", "_data/cards.json", "Write the cards to this file")
+	imageDir = flag.String("images", "", "Directory of custom card images, relative to the repository root (default: cards in the asset directory)")
+	doDryRun = flag.Bool("dry-run", false, "Print the cards to stdout instead of writing them")
+)
+
+// imageExts are the extensions of custom card images, in order of preference.
+var imageExts = []string{".png", ".jpg", ".jpeg", ".webp"}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	if !isFlagSet("images") {
+		*imageDir = filepath.Join(repo.AssetDir, "cards")
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	gidx := ilof.GuestIndex(guests)
+
+	cards := make(map[ilof.Label]*ilof.SocialCard)
+	var numCustom int
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		for _, g := range gidx[ep.Episode] {
+			ep.Guests = append(ep.Guests, g.Name)
+		}
+		card := ilof.EpisodeCard(ep)
+		if img := customImage(ep.Episode); img != "" {
+			card.SetImage(img, card.Title)
+			numCustom++
+		}
+		cards[ep.Episode] = card
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	log.Printf("Generated %d cards (%d with custom images)", len(cards), numCustom)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(cards); err != nil {
+		log.Fatalf("Encoding cards: %v", err)
+	}
+	if *doDryRun {
+		os.Stdout.Write(buf.Bytes())
+	} else if err := atomicfile.WriteData(*outPath, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("Writing cards: %v", err)
+	} else if err := ilof.RecordGenerated(repo.ManifestFile, "cards", *outPath); err != nil {
+		log.Fatalf("Updating manifest: %v", err)
+	}
+}
+
+// isFlagSet reports whether the flag with the given name was set on the
+// command line.
+func isFlagSet(name string) bool {
+	var set bool
+	flag.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// customImage returns the site path of the custom card image for the episode
+// with the given label, or "" if there is none.
+func customImage(label ilof.Label) string {
+	if *imageDir == "" {
+		return ""
+	}
+	for _, ext := range imageExts {
+		p := filepath.Join(*imageDir, string(label)+ext)
+		if _, err := os.Stat(p); err == nil {
+			return "/" + path.Clean(filepath.ToSlash(p))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+// Program cccheck verifies that the Crowdcast URL of each episode in the site
+// repository still serves the replay of its event.
+//
+// A generic link check (see linkcheck) is not enough for Crowdcast: for an
+// event that has been removed, Crowdcast answers 200 OK with an "event not
+// found" page, or redirects to its home page. cccheck fetches each page,
+// following redirects, and reports the URLs that fail ("dead"), that lead to
+// the not-found page or away from the event ("not-found"), or whose event
+// has no replay ("no-replay"). Links to archived snapshots are not checked.
+//
+// The report is written to stdout as JSON. Exit status 0 means every replay
+// was found; exit status 3 means some were not, and exit status 1 means the
+// check could not be completed. To repair the links that can be repaired,
+// run ccmigrate.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	numWorkers = flag.Int("workers", 4, "Number of concurrent checks")
+	hostDelay  = flag.Duration("host-delay", 1*time.Second, "Minimum time between requests to the same host")
+	timeout    = flag.Duration("timeout", 30*time.Second, "Timeout for each check")
+	labelFlag  = flag.String("episode", "", "Check only this episode")
+)
+
+// A result reports an episode whose Crowdcast URL does not serve a replay.
+type result struct {
+	Path    string             `json:"path"`
+	Episode ilof.Label         `json:"episode"`
+	Status  *ilof.ReplayStatus `json:"status"`
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	var checks []*result
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		if ep.CrowdcastURL == "" || ilof.IsWaybackURL(ep.CrowdcastURL) {
+			return nil
+		} else if *labelFlag != "" && ep.Episode != ilof.ParseLabel(*labelFlag) {
+			return nil
+		}
+		checks = append(checks, &result{Path: path, Episode: ep.Episode, Status: &ilof.ReplayStatus{
+			LinkStatus: ilof.LinkStatus{URL: ep.CrowdcastURL},
+		}})
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	log.Printf("Checking %d Crowdcast links", len(checks))
+
+	ctx := context.Background()
+	if *hostDelay > 0 {
+		ilof.RateLimiter = ilof.NewHostLimiter(1/hostDelay.Seconds(), 1)
+	}
+	var (
+		mu      sync.Mutex
+		results = []*result{}
+	)
+	bar := ilof.NewProgressBar(os.Stderr, "Checking replays")
+	log.SetOutput(bar)
+	err := ilof.RunBatch(ctx, len(checks), &ilof.BatchOptions{
+		Workers:  *numWorkers,
+		Progress: bar.Update,
+	}, func(ctx context.Context, i int) string {
+		c := checks[i]
+		cctx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		if c.Status = ilof.CheckCrowdcastReplay(cctx, c.Status.URL); !c.Status.OK() {
+			mu.Lock()
+			results = append(results, c)
+			mu.Unlock()
+		}
+		return string(c.Episode)
+	})
+	bar.Finish()
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		log.Fatalf("Checking replays: %v", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(struct {
+		R []*result `json:"results"`
+	}{R: results}); err != nil {
+		log.Fatalf("Encoding JSON: %v", err)
+	}
+	if len(results) != 0 {
+		log.Printf("%d of %d Crowdcast links do not serve a replay", len(results), len(checks))
+		os.Exit(3)
+	}
+	log.Printf("All %d Crowdcast links serve a replay", len(checks))
+}
@@ -0,0 +1,164 @@
+// Program ccimport reconciles the episode files of the site repository with
+// an event export from Crowdcast.
+//
+// Each event in the export is matched to an episode, by the episode number
+// in the event title if there is one, or otherwise by air date. For each
+// matched episode, a missing Crowdcast URL is filled in from the export. With
+// -overwrite, a Crowdcast URL that differs from the export is replaced. With
+// -fix-dates, the air date of an episode matched by number is corrected to
+// the date of the event, and the episode file is renamed to match.
+//
+// Usage:
+//
+//	ccimport [options] export.csv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	doOverwrite = flag.Bool("overwrite", false, "Replace Crowdcast URLs that differ from the export")
+	doFixDates  = flag.Bool("fix-dates", false, "Correct air dates of episodes matched by number")
+	doDryRun    = flag.Bool("dry-run", false, "Report changes without modifying any files")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s [options] <export.csv>
+
+Reconcile episode files with a Crowdcast event export (CSV). The export
+must have a header row; the title and start time columns are required,
+and the registrant count and replay or event URL columns are used if
+present.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+type episodeFile struct {
+	path string
+	ep   *ilof.Episode
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatal("You must provide the path of a Crowdcast export")
+	}
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	sched, err := ilof.LoadSchedule(repo.ScheduleFile)
+	if err != nil {
+		log.Fatalf("Loading schedule: %v", err)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Opening export: %v", err)
+	}
+	events, err := ilof.ParseCrowdcastExport(f, sched.Location())
+	f.Close()
+	if err != nil {
+		log.Fatalf("Reading export: %v", err)
+	}
+	log.Printf("Read %d events from %q", len(events), flag.Arg(0))
+
+	byLabel := make(map[ilof.Label]*episodeFile)
+	byDate := make(map[string][]*episodeFile)
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		ef := &episodeFile{path: path, ep: ep}
+		byLabel[ep.Episode] = ef
+		byDate[ep.Date.String()] = append(byDate[ep.Date.String()], ef)
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+
+	var numMatched, numChanged int
+	for _, ev := range events {
+		start := ev.Start.In(sched.Location())
+		day := start.Format("2006-01-02")
+		ef, byNumber := byLabel[ev.Label()]
+		if !byNumber {
+			if cands := byDate[day]; len(cands) == 1 {
+				ef = cands[0]
+			} else {
+				log.Printf("- No unique episode for event %q on %s (%d candidates)", ev.Title, day, len(cands))
+				continue
+			}
+		}
+		numMatched++
+
+		var changes []string
+		ep := ef.ep
+		if ev.URL != "" && ep.CrowdcastURL != ev.URL {
+			if ep.CrowdcastURL == "" {
+				changes = append(changes, fmt.Sprintf("set crowdcast %s", ev.URL))
+				ep.CrowdcastURL = ev.URL
+			} else if *doOverwrite {
+				changes = append(changes, fmt.Sprintf("crowdcast %s → %s", ep.CrowdcastURL, ev.URL))
+				ep.CrowdcastURL = ev.URL
+			} else {
+				log.Printf("- Episode %s: Crowdcast URL %q differs from export %q", ep.Episode, ep.CrowdcastURL, ev.URL)
+			}
+		}
+		newPath := ef.path
+		if byNumber && ep.Date.String() != day {
+			if *doFixDates {
+				changes = append(changes, fmt.Sprintf("date %s → %s", ep.Date, day))
+				ep.Date = ilof.Date(time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC))
+				newPath = renamedPath(ef.path, day)
+			} else {
+				log.Printf("- Episode %s: air date %s differs from event date %s", ep.Episode, ep.Date, day)
+			}
+		}
+		if ev.Registrants > 0 {
+			log.Printf("- Episode %s: %d registrants", ep.Episode, ev.Registrants)
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		numChanged++
+		for _, c := range changes {
+			fmt.Printf("%s: %s\n", ef.path, c)
+		}
+		if newPath != ef.path {
+			fmt.Printf("%s: rename to %s\n", ef.path, newPath)
+		}
+		if *doDryRun {
+			continue
+		}
+		if err := ilof.WriteEpisode(ef.path, ep); err != nil {
+			log.Fatalf("Writing episode %s: %v", ep.Episode, err)
+		}
+		if newPath != ef.path {
+			if err := os.Rename(ef.path, newPath); err != nil {
+				log.Fatalf("Renaming episode file: %v", err)
+			}
+			ef.path = newPath
+		}
+	}
+	log.Printf("Matched %d of %d events; updated %d episodes", numMatched, len(events), numChanged)
+	if *doDryRun {
+		log.Print("@ No files were modified, this is a dry run")
+	}
+}
+
+// renamedPath returns path with the date prefix of its base name replaced by
+// date (YYYY-MM-DD).
+func renamedPath(path, date string) string {
+	dir, base := filepath.Split(path)
+	return filepath.Join(dir, date+base[len(date):])
+}
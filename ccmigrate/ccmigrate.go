@@ -0,0 +1,166 @@
+// Program ccmigrate finds the Crowdcast links of the episodes in the site
+// repository that no longer work, and rewrites them where possible.
+//
+// Crowdcast has changed the structure of its URLs several times, and some
+// events have been removed. A URL is dead if it fails, or if it serves the
+// "event not found" page or a page with no replay (see cccheck). For each
+// episode whose Crowdcast URL is dead, ccmigrate tries in order:
+//
+//  1. The replay URLs Crowdcast currently uses for the same event.
+//  2. The archived snapshot of the original URL in the Wayback Machine
+//     closest to the air date of the episode.
+//
+// If the URL is live but redirects to another Crowdcast URL, it is replaced
+// by the destination. Episodes for which no replacement is found are listed
+// in the report as needing manual attention.
+//
+// The report is written to stdout as JSON. With -dry-run, no episode files
+// are modified.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	doDryRun   = flag.Bool("dry-run", false, "Report changes without modifying any files")
+	doArchive  = flag.Bool("archive", true, "Use Wayback Machine snapshots for events with no replay")
+	timeout    = flag.Duration("timeout", 30*time.Second, "Timeout for each check")
+	labelFlag  = flag.String("episode", "", "Check only this episode")
+	reportLive = flag.Bool("all", false, "Include episodes whose links work in the report")
+)
+
+// A result reports the outcome of checking the Crowdcast link of an episode.
+type result struct {
+	Path    string     `json:"path"`
+	Episode ilof.Label `json:"episode"`
+	URL     string     `json:"url"`
+	Status  string     `json:"status"`           // "ok", "redirect", "replay", "archive", or "manual"
+	New     string     `json:"new,omitempty"`    // the replacement URL, if any
+	Detail  string     `json:"detail,omitempty"` // error text, for "manual"
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	ctx := context.Background()
+	results := []*result{}
+	var numChecked, numFixed, numManual int
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		if ep.CrowdcastURL == "" || ilof.IsWaybackURL(ep.CrowdcastURL) {
+			return nil
+		} else if *labelFlag != "" && string(ep.Episode) != *labelFlag {
+			return nil
+		}
+		numChecked++
+		r := checkEpisode(ctx, ep)
+		r.Path = path
+		switch r.Status {
+		case "ok":
+			if !*reportLive {
+				return nil
+			}
+		case "manual":
+			numManual++
+		default:
+			numFixed++
+			log.Printf("- Episode %s: %s %s → %s", ep.Episode, r.Status, r.URL, r.New)
+			if !*doDryRun {
+				ep.CrowdcastURL = r.New
+				if err := ilof.WriteEpisode(path, ep); err != nil {
+					return err
+				}
+			}
+		}
+		results = append(results, r)
+		return nil
+	}); err != nil {
+		log.Fatalf("Checking episodes: %v", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(struct {
+		R []*result `json:"results"`
+	}{R: results}); err != nil {
+		log.Fatalf("Encoding JSON: %v", err)
+	}
+	log.Printf("Checked %d links: %d rewritten, %d need manual attention", numChecked, numFixed, numManual)
+	if *doDryRun && numFixed != 0 {
+		log.Print("@ No files were modified, this is a dry run")
+	}
+}
+
+// checkEpisode checks the Crowdcast URL of ep, and looks for a replacement
+// if it is dead. The caller must fill in the path of the result.
+func checkEpisode(ctx context.Context, ep *ilof.Episode) *result {
+	r := &result{Episode: ep.Episode, URL: ep.CrowdcastURL}
+	st := check(ctx, ep.CrowdcastURL)
+	if st.OK() {
+		r.Status = "ok"
+		if _, ok := ilof.CrowdcastSlug(st.Final); ok && st.Redirected() {
+			if final := ilof.NormalizeURL(st.Final); final != ilof.NormalizeURL(ep.CrowdcastURL) {
+				r.Status, r.New = "redirect", final
+			}
+		}
+		return r
+	}
+	r.Detail = describe(st)
+
+	if slug, ok := ilof.CrowdcastSlug(ep.CrowdcastURL); ok {
+		for _, u := range ilof.CrowdcastReplayURLs(slug) {
+			if ilof.NormalizeURL(u) == ilof.NormalizeURL(ep.CrowdcastURL) {
+				continue // already tried
+			}
+			if check(ctx, u).OK() {
+				r.Status, r.New, r.Detail = "replay", u, ""
+				return r
+			}
+		}
+	}
+	if *doArchive {
+		cctx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		snap, ok, err := ilof.WaybackSnapshot(cctx, ep.CrowdcastURL, time.Time(ep.Date).Format("20060102"))
+		if err != nil {
+			log.Printf("* Episode %s: checking archive: %v", ep.Episode, err)
+		} else if ok {
+			r.Status, r.New, r.Detail = "archive", snap, ""
+			return r
+		}
+	}
+	r.Status = "manual"
+	return r
+}
+
+func check(ctx context.Context, url string) *ilof.ReplayStatus {
+	cctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	return ilof.CheckCrowdcastReplay(cctx, url)
+}
+
+// describe returns a description of the problem reported by st.
+func describe(st *ilof.ReplayStatus) string {
+	switch {
+	case st.Error != "":
+		return st.Error
+	case st.Status != http.StatusOK:
+		return fmt.Sprintf("HTTP status %d", st.Status)
+	case st.Problem == "no-replay":
+		return "the event has no replay"
+	}
+	return "the event was not found"
+}
@@ -0,0 +1,80 @@
+// Program corpus assembles the stored transcripts of the episodes in the site
+// repository, with the metadata of each episode, into a single transcript
+// corpus file in JSON Lines format, for search, statistics, and research.
+//
+// The first line of the corpus is a header giving the format version, the
+// numbers of episodes and segments, and a digest of the rest of the file.
+// Then for each episode with a transcript, in order of episode number, comes
+// a record of the episode (date, guests, topics, and so on) followed by one
+// record for each caption of its transcript, giving its time offsets, speaker
+// turn, and normalized text. Records are distinguished by their "kind" field.
+//
+// The corpus depends only on the contents of the repository, so building it
+// twice from the same commit yields identical files, with the same digest.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath    = flag.String("o", "", "Write the corpus to this file (default stdout)")
+	seasonFlag = flag.Int("season", 0, "Only include episodes in this season")
+)
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	gidx := ilof.GuestIndex(guests)
+
+	var items []*ilof.DatasetItem
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		if ep.TranscriptFile() == "" {
+			return nil
+		} else if *seasonFlag > 0 && ep.Season != *seasonFlag {
+			return nil
+		}
+		t, err := ilof.LoadEpisodeTranscript(ep)
+		if err != nil {
+			log.Printf("* Episode %s: %v", ep.Episode, err)
+			return nil
+		}
+		for _, g := range gidx[ep.Episode] {
+			ep.Guests = append(ep.Guests, g.Name)
+		}
+		items = append(items, &ilof.DatasetItem{Episode: ep, Transcript: t})
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+
+	var buf bytes.Buffer
+	bar := ilof.NewProgressBar(os.Stderr, "Building corpus")
+	hdr, err := ilof.WriteDatasetWith(&buf, items, bar.Update)
+	bar.Finish()
+	if err != nil {
+		log.Fatalf("Building corpus: %v", err)
+	}
+	if *outPath == "" {
+		os.Stdout.Write(buf.Bytes())
+	} else if err := atomicfile.WriteData(*outPath, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("Writing corpus: %v", err)
+	} else if err := ilof.RecordGenerated(repo.ManifestFile, "corpus", *outPath); err != nil {
+		log.Fatalf("Updating manifest: %v", err)
+	}
+	log.Printf("Wrote corpus version %d: %d episodes, %d segments, digest %s",
+		hdr.Version, hdr.Episodes, hdr.Segments, hdr.Digest[:12])
+}
@@ -0,0 +1,199 @@
+// Program digest generates a digest of recent episodes from the site
+// repository, for distribution to the mailing list.
+//
+// The digest lists each episode aired in the reporting period with its
+// guests, summary, and links to the video, audio, and transcript. It can be
+// written as markdown, HTML, or a complete multipart email message with both.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	format    = flag.String("format", "markdown", "Output format (markdown, html, email)")
+	days      = flag.Int("days", 7, "Include episodes aired within this many days")
+	endDate   = flag.String("end", "", "Last date of the digest period (YYYY-MM-DD; default today)")
+	mailFrom  = flag.String("from", "", "With -format=email, the From address")
+	mailTo    = flag.String("to", "", "With -format=email, the To address")
+	subjectFl = flag.String("subject", "", "Subject line (default describes the period)")
+)
+
+// An item is a single episode entry in the digest.
+type item struct {
+	*ilof.Episode
+	GuestNames    []string
+	PageURL       string
+	TranscriptURL string
+}
+
+type digest struct {
+	Subject    string
+	Start, End string
+	Items      []*item
+}
+
+func main() {
+	flag.Parse()
+	end := time.Now()
+	if *endDate != "" {
+		t, err := time.Parse("2006-01-02", *endDate)
+		if err != nil {
+			log.Fatalf("Invalid -end date: %v", err)
+		}
+		end = t
+	}
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, 0, 1-*days)
+
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	gidx := ilof.GuestIndex(guests)
+
+	d := &digest{
+		Start: start.Format("2006-01-02"),
+		End:   end.Format("2006-01-02"),
+	}
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		at := time.Time(ep.Date)
+		if at.Before(start) || at.After(end) {
+			return nil
+		}
+		it := &item{Episode: ep, PageURL: ep.PageURL()}
+		for _, g := range gidx[ep.Episode] {
+			it.GuestNames = append(it.GuestNames, g.Name)
+		}
+		it.TranscriptURL = ilof.TranscriptPageURL(ep)
+		d.Items = append(d.Items, it)
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	sort.Slice(d.Items, func(i, j int) bool {
+		return time.Time(d.Items[i].Date).Before(time.Time(d.Items[j].Date))
+	})
+	log.Printf("Found %d episodes from %s to %s", len(d.Items), d.Start, d.End)
+
+	d.Subject = *subjectFl
+	if d.Subject == "" {
+		d.Subject = fmt.Sprintf("In Lieu of Fun: episodes from %s to %s", d.Start, d.End)
+	}
+
+	switch *format {
+	case "markdown":
+		err = mdDigest.Execute(os.Stdout, d)
+	case "html":
+		err = htmlDigest.Execute(os.Stdout, d)
+	case "email":
+		err = writeEmail(os.Stdout, d)
+	default:
+		log.Fatalf("Unknown output format %q", *format)
+	}
+	if err != nil {
+		log.Fatalf("Writing digest: %v", err)
+	}
+}
+
+// writeEmail writes d to w as a MIME multipart/alternative message with plain
+// text (markdown) and HTML parts.
+func writeEmail(w io.Writer, d *digest) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		ctype string
+		exec  func(io.Writer, interface{}) error
+	}{
+		{"text/plain; charset=utf-8", mdDigest.Execute},
+		{"text/html; charset=utf-8", htmlDigest.Execute},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.ctype}})
+		if err != nil {
+			return err
+		}
+		if err := part.exec(pw, d); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	if *mailFrom != "" {
+		fmt.Fprintf(w, "From: %s\r\n", *mailFrom)
+	}
+	if *mailTo != "" {
+		fmt.Fprintf(w, "To: %s\r\n", *mailTo)
+	}
+	fmt.Fprintf(w, "Subject: %s\r\n", d.Subject)
+	fmt.Fprintf(w, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(w, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(w, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	_, err := body.WriteTo(w)
+	return err
+}
+
+var funcs = map[string]interface{}{
+	"join": strings.Join,
+}
+
+var mdDigest = template.Must(template.New("md").Funcs(funcs).Parse(`# {{.Subject}}
+{{if not .Items}}
+No episodes aired from {{.Start}} to {{.End}}.
+{{end}}{{range .Items}}
+## Episode {{.Episode.Episode}} ({{.Date}})
+{{if .GuestNames}}
+Guests: {{join .GuestNames ", "}}
+{{end}}{{if .Summary}}
+{{.Summary}}
+{{end}}
+- Episode page: {{.PageURL}}
+{{- if .YouTubeURL}}
+- Video: {{.YouTubeURL}}{{end}}
+{{- if .AcastURL}}
+- Audio: {{.AcastURL}}{{end}}
+{{- if .TranscriptURL}}
+- Transcript: {{.TranscriptURL}}{{end}}
+{{end}}`))
+
+var htmlDigest = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Subject}}</title></head>
+<body>
+<h1>{{.Subject}}</h1>
+{{if not .Items}}<p>No episodes aired from {{.Start}} to {{.End}}.</p>
+{{end}}{{range .Items}}
+<h2><a href="{{.PageURL}}">Episode {{.Episode.Episode}}</a> ({{.Date}})</h2>
+{{if .GuestNames}}<p>Guests: {{join .GuestNames ", "}}</p>{{end}}
+{{if .Summary}}<p>{{.Summary}}</p>{{end}}
+<ul>
+{{- if .YouTubeURL}}
+<li><a href="{{.YouTubeURL}}">Video</a></li>{{end}}
+{{- if .AcastURL}}
+<li><a href="{{.AcastURL}}">Audio</a></li>{{end}}
+{{- if .TranscriptURL}}
+<li><a href="{{.TranscriptURL}}">Transcript</a></li>{{end}}
+</ul>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,77 @@
+// Program doctor reports which of the credentials used by the tools are
+// configured, and where each was found.
+//
+// Credentials are sought in the environment, then in the credentials file,
+// then in the system keychain. The credentials file holds NAME=value lines,
+// in the style of a .env file; it is named by $ILOF_CREDENTIALS, or by default
+// is ilof/credentials.env in the user configuration directory (on Linux,
+// ~/.config/ilof/credentials.env). In the system keychain, credentials are
+// stored under the service "ilof" with the credential name as the account.
+//
+// Values are not printed, only a masked prefix. Exit status 0 means every
+// credential could be checked; exit status 1 means the credentials file or
+// the keychain could not be read.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/inlieuoffun/tools/ilof"
+)
+
+func main() {
+	flag.Parse()
+	creds := ilof.DefaultCredentials
+
+	if creds.File == "" {
+		fmt.Println("Credentials file: none (no user configuration directory)")
+	} else if fi, err := os.Stat(creds.File); os.IsNotExist(err) {
+		fmt.Printf("Credentials file: %s (not found)\n", creds.File)
+	} else if err != nil {
+		fmt.Printf("Credentials file: %s (%v)\n", creds.File, err)
+	} else {
+		fmt.Printf("Credentials file: %s\n", creds.File)
+		if fi.Mode().Perm()&0077 != 0 {
+			fmt.Printf("  Warning: the file is readable by other users (mode %v); chmod 600 it\n", fi.Mode().Perm())
+		}
+	}
+	fmt.Println()
+
+	tw := tabwriter.NewWriter(os.Stdout, 4, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATUS\tSOURCE\tUSE")
+	var numSet, numFailed int
+	for _, c := range ilof.Credentials {
+		v, src, err := creds.Lookup(c)
+		var missing *ilof.MissingCredentialError
+		switch {
+		case errors.As(err, &missing):
+			fmt.Fprintf(tw, "%s\tmissing\t-\t%s\n", c.Name, c.About)
+		case err != nil:
+			fmt.Fprintf(tw, "%s\terror\t-\t%s\n", c.Name, c.About)
+			log.Printf("* %v", err)
+			numFailed++
+		default:
+			fmt.Fprintf(tw, "%s\tset (%s)\t%s\t%s\n", c.Name, mask(v), src, c.About)
+			numSet++
+		}
+	}
+	tw.Flush()
+	fmt.Printf("\n%d of %d credentials are set\n", numSet, len(ilof.Credentials))
+	if numFailed != 0 {
+		os.Exit(1)
+	}
+}
+
+// mask returns a masked form of the secret s, showing only a short prefix.
+func mask(s string) string {
+	const show = 4
+	if len(s) <= 2*show {
+		return "****"
+	}
+	return s[:show] + "****"
+}
@@ -0,0 +1,113 @@
+// Program entities finds the people, books, and court cases mentioned in the
+// descriptions and transcripts of episodes, and writes them as JSON grouped by
+// episode:
+//
+//	entities -o entities.json
+//
+// Entities are found by heuristics, using the names in the guest list as a
+// dictionary of known people, and should be reviewed before use.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath       = flag.String("o", "", "Write entities to this file (default stdout)")
+	labelFlag     = flag.String("episode", "", "Find entities only for this episode")
+	minCount      = flag.Int("min-count", 1, "Report only entities mentioned at least this many times")
+	doTranscripts = flag.Bool("transcripts", true, "Include stored transcripts in the episode text")
+)
+
+// An episodeEntities records the entities mentioned in an episode.
+type episodeEntities struct {
+	Episode  ilof.Label     `json:"episode"`
+	Entities []*ilof.Entity `json:"entities"`
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	ex := &ilof.HeuristicExtractor{Dictionary: make(map[string]ilof.EntityKind)}
+	for _, g := range guests {
+		ex.Dictionary[g.Name] = ilof.PersonEntity
+	}
+
+	ctx := context.Background()
+	out := []*episodeEntities{}
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		if *labelFlag != "" && string(ep.Episode) != *labelFlag {
+			return nil
+		}
+		es, err := extract(ctx, ex, ep)
+		if err != nil {
+			return err
+		}
+		var keep []*ilof.Entity
+		for _, e := range es {
+			if e.Count >= *minCount {
+				keep = append(keep, e)
+			}
+		}
+		if len(keep) != 0 {
+			out = append(out, &episodeEntities{Episode: ep.Episode, Entities: keep})
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	log.Printf("Found entities for %d episodes", len(out))
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		log.Fatalf("Encoding entities: %v", err)
+	}
+	data = append(data, '\n')
+	if *outPath == "" {
+		os.Stdout.Write(data)
+	} else if err := atomicfile.WriteData(*outPath, data, 0644); err != nil {
+		log.Fatalf("Writing entities: %v", err)
+	}
+}
+
+// extract returns the entities mentioned in the description and transcript
+// of ep. The description and transcript are processed separately, so that
+// the markdown of the description is not confused with the transcript.
+func extract(ctx context.Context, ex ilof.EntityExtractor, ep *ilof.Episode) ([]*ilof.Entity, error) {
+	desc := strings.Join([]string{ep.Topics, ep.Summary, ep.Detail}, "\n")
+	es, err := ex.Extract(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	if !*doTranscripts {
+		return es, nil
+	}
+	t, err := ilof.LoadEpisodeTranscript(ep)
+	if err != nil {
+		log.Printf("* Episode %s: %v", ep.Episode, err)
+		return es, nil
+	} else if t == nil {
+		return es, nil
+	}
+	ts, err := ex.Extract(ctx, t.Text())
+	if err != nil {
+		return nil, err
+	}
+	return ilof.MergeEntities(es, ts), nil
+}
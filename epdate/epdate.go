@@ -4,8 +4,41 @@
 // visible on the main web site, and creates new episode files for them with
 // stream URLs populated.
 //
-// You must provide a TWITTER_TOKEN environment variable with a Twitter API v2
-// bearer token.
+// If an episode has no summary, epdate fills one in from the first paragraph
+// of the video description, and marks the episode with "auto-summary: true"
+// so that editors know to review it. Remove the mark once the summary has
+// been checked.
+//
+// With -edit, the new and modified files are opened in the editor named by
+// the EDITOR environment variable, which may include arguments, such as
+// "code --wait". If there is no terminal for the editor, the files are listed
+// instead.
+//
+// New guests are added to the guest list with their Twitter bio as notes,
+// sanitized to remove line breaks, emoji, URLs, and disclaimers, and
+// truncated to -notes-length characters. With -keep-bio, the original bio is
+// kept in the "bio" field of the guest record.
+//
+// The announcement post for each new episode is archived as JSON, with its
+// author and the users it mentions, in the announcements directory of the
+// repository (by default _data/announcements/<episode>.json), so that the
+// origin of the episode data is kept if the post or the account goes away.
+//
+// You must provide a TWITTER_TOKEN with a Twitter API v2 bearer token, and a
+// YOUTUBE_API_KEY, in the environment or the credentials file, or in the
+// system keychain (run "doctor" to see where they are sought). To spread the
+// load of polling over several Twitter API tokens, set TWITTER_TOKEN to a
+// comma-separated list of tokens; when one reaches its rate limit, the next
+// is used until the limit resets.
+//
+// When it creates episodes, epdate posts a notice of them to the webhook named
+// by -webhook or the ILOF_WEBHOOK credential, if either is set, so that a
+// long-running -poll can report its work; scancast -poll uses the same
+// mechanism for new audio episodes.
+//
+// Like the other tools, epdate works in the site repository containing the
+// working directory, or in the repository named by -repo (or $ILOF_REPO), so
+// that it can run from outside the tree.
 //
 // Exit status 0 means an update was generated.
 // Exit status 3 means no update was available.
@@ -22,9 +55,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/inlieuoffun/tools/ilof"
 	"github.com/inlieuoffun/tools/repo"
@@ -32,12 +67,19 @@ import (
 
 var (
 	doDryRun     = flag.Bool("dry-run", false, "Do not create or modify any files")
-	doForce      = flag.Bool("force", false, "Create updates even if the files exist")
+	doForce      = flag.Bool("force", false, "Create updates even if the files exist or have local changes")
 	doEdit       = flag.Bool("edit", false, "Edit new or modified files after update")
+	doCommit     = flag.Bool("commit", false, "Commit new or modified files after update")
+	doPush       = flag.Bool("push", false, "With -commit, push the commit to origin")
+	doBranches   = flag.Bool("branch-per-episode", false, "Commit each new episode on its own branch (implies -commit)")
+	doPrune      = flag.Bool("prune-branches", false, "Delete episode branches merged into the current branch, and exit")
 	doPoll       = flag.Bool("poll", false, "Poll for updates")
 	doPollOne    = flag.Bool("poll-one", false, "Poll for a single update")
 	skipVidCheck = flag.Bool("skip-video-check", false, "SKip check for video ID")
 	override     = flag.String("override", "", "Override latest episode with num:date")
+	notesLength  = flag.Int("notes-length", ilof.DefaultMaxNotes, "Truncate the notes of new guests to this length (0 for no limit)")
+	keepBio      = flag.Bool("keep-bio", false, "Preserve the unsanitized Twitter bio of new guests in the guest list")
+	webhookURL   = flag.String("webhook", "", "Post a notice of new episodes to this webhook URL (default $ILOF_WEBHOOK)")
 	checkRepo    = flag.String("check-repo", "inlieuoffun.github.io",
 		"Check that working directory matches this repo name")
 
@@ -60,28 +102,53 @@ func init() {
 }
 
 const (
-	episodeDir  = "_episodes"
-	guestFile   = "_data/guests.yaml"
 	minPollTime = 1 * time.Minute
 	maxPollTime = 90 * time.Minute
+
+	// The prefix of the branch names used by -branch-per-episode.
+	branchPrefix = "epdate/ep-"
 )
 
 func main() {
 	flag.Parse()
-	token := os.Getenv("TWITTER_TOKEN")
-	if token == "" {
-		log.Fatal(`No TWITTER_TOKEN is set in the environment.
-  If you need a token, visit https://developer.twitter.com/en/portal/dashboard`)
+	if *doPrune {
+		if err := repo.ChdirRoot(); err != nil {
+			log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+		}
+		if err := pruneBranches(); err != nil {
+			log.Fatalf("Pruning branches: %v", err)
+		}
+		return
+	}
+	if *doBranches {
+		if *doEdit {
+			log.Fatal("The -edit flag cannot be used with -branch-per-episode")
+		}
+		*doCommit = true
+	}
+	token, err := ilof.TwitterToken.Value()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tokens, err := ilof.NewTokenPool(ilof.SplitTokens(token)...)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", ilof.TwitterToken.Name, err)
+	} else if n := tokens.Len(); n > 1 {
+		log.Printf("Using %d Twitter tokens", n)
 	}
-	apiKey := os.Getenv("YOUTUBE_API_KEY")
-	if apiKey == "" {
-		log.Fatal(`No YOUTUBE_API_KEY is set in the environment.
-  If you need a key, visit https://console.developers.google.com/apis/credentials`)
+	apiKey, err := ilof.YouTubeAPIKey.Value()
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	if err := repo.ChdirRoot(); err != nil {
-		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone)", err)
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	rules, err := ilof.LoadTagRules(repo.TagRuleFile)
+	if err != nil {
+		log.Fatalf("Loading tag rules: %v", err)
 	}
+	tagRules = rules
 	if *checkRepo != "" {
 		remote, err := repo.RemoteRepo("origin")
 		if err != nil {
@@ -93,7 +160,7 @@ func main() {
 
 	ctx := context.Background()
 	for {
-		latestDate, didUpdate := checkForUpdate(ctx, token, apiKey)
+		latestDate, didUpdate := checkForUpdate(ctx, tokens, apiKey)
 		if didUpdate {
 			if *doPollOne || !*doPoll {
 				return
@@ -123,7 +190,7 @@ func main() {
 	}
 }
 
-func checkForUpdate(ctx context.Context, token, apiKey string) (ilof.Date, bool) {
+func checkForUpdate(ctx context.Context, tokens *ilof.TokenPool, apiKey string) (ilof.Date, bool) {
 	latest, err := ilof.LatestEpisode(ctx)
 	if err != nil {
 		log.Fatalf("Looking up latest episode: %v", err)
@@ -140,7 +207,7 @@ func checkForUpdate(ctx context.Context, token, apiKey string) (ilof.Date, bool)
 		}
 	}
 
-	updates, err := ilof.TwitterUpdates(ctx, token, latest.Date)
+	updates, err := ilof.TwitterUpdatesWith(ctx, tokens, latest.Date)
 	if err != nil {
 		log.Printf("Finding updates on twitter: %v", err)
 		if err == ilof.ErrNoUpdates {
@@ -150,14 +217,62 @@ func checkForUpdate(ctx context.Context, token, apiKey string) (ilof.Date, bool)
 	}
 	log.Printf("Found %d updates on twitter since %s", len(updates), latest.Date)
 
-	var editPaths []string
+	// Number the new episodes after those published on the site, or with
+	// -override, after the given episode.
+	index := []*ilof.Episode{latest}
+	if *override == "" {
+		eps, err := ilof.AllEpisodes(ctx)
+		if err != nil {
+			log.Fatalf("Listing episodes: %v", err)
+		}
+		index = append(index, eps...)
+	}
+
+	var editPaths, archived, added []string
 	var guestsDirty bool
 
-	numValid := 0
+	// Stage the changes in a transaction, so that the episode files and the
+	// guest list are updated together or not at all. In a dry run, record the
+	// changes without writing them, so they can be reported exactly.
+	tx := new(repo.Transaction)
+	defer tx.Rollback()
+	var wt repo.Worktree = tx
+	dryRun := &repo.DryRun{}
+	if *doDryRun {
+		wt = dryRun
+	}
+	// Exiting skips deferred calls, so report errors with fail, which discards
+	// any staged writes before it exits.
+	fail := func(msg string, args ...any) {
+		tx.Rollback()
+		log.Fatalf(msg, args...)
+	}
+
+	// With -branch-per-episode, each episode is staged in its own transaction
+	// and committed to its own branch, starting from the current branch.
+	var baseBranch string
+	if *doBranches && !*doDryRun {
+		b, err := repo.CurrentBranch()
+		if err != nil {
+			fail("Finding current branch: %v", err)
+		}
+		baseBranch = b
+	}
+
+	// Do not clobber manual edits to the guest list that have not been
+	// committed. Episode files that exist are not rewritten without -force.
+	if !*doDryRun && !*doForce {
+		if ok, err := repo.IsClean(repo.GuestFile); err != nil {
+			fail("Checking for local changes: %v", err)
+		} else if !ok {
+			fail("Guest file %q has uncommitted changes (use -force to update anyway)", repo.GuestFile)
+		}
+	}
+
 	for i, up := range updates {
-		epNum := int(latest.Episode.Number()) + numValid + 1
-		epFile := fmt.Sprintf("%s-%04d.md", up.AirDate.Format("2006-01-02"), epNum)
-		epPath := filepath.Join(episodeDir, epFile)
+		epNum := int(ilof.NextEpisodeLabel(index).Number())
+		epFile := ilof.EpisodeFileName(ilof.Date(up.AirDate), ilof.Label(strconv.Itoa(epNum)))
+		epPath := filepath.Join(repo.EpisodeDir, epFile)
 		exists := fileExists(epPath)
 
 		log.Printf("Update %d: episode %d, id %s, posted %s, air %s, exists=%v",
@@ -166,6 +281,17 @@ func checkForUpdate(ctx context.Context, token, apiKey string) (ilof.Date, bool)
 		if exists && !*doForce {
 			continue
 		}
+		branch := fmt.Sprintf("%s%04d", branchPrefix, epNum)
+		if baseBranch != "" {
+			if ok, err := repo.BranchExists(branch); err != nil {
+				fail("Checking for branch %q: %v", branch, err)
+			} else if ok && !*doForce {
+				log.Printf("* Branch %q already exists; skipping", branch)
+				continue
+			}
+			tx = new(repo.Transaction)
+			wt = tx
+		}
 		var desc string
 		if info, err := fetchEpisodeInfo(ctx, up, apiKey); err == errNoVideoID {
 			if !*skipVidCheck {
@@ -179,59 +305,217 @@ func checkForUpdate(ctx context.Context, token, apiKey string) (ilof.Date, bool)
 			log.Printf("- Fetched video description from YouTube (%d bytes)", len(desc))
 		}
 
-		if *doDryRun {
-			log.Printf("@ Not writing episode file %q, this is a dry run", epPath)
-		} else if err := createEpisodeFile(epPath, epNum, desc, up); err != nil {
-			log.Fatalf("* Creating episode file for %d: %v", epNum, err)
+		if res, err := ilof.CreateOrUpdateEpisodeFromUpdate(repo.EpisodeDir, epNum, up, &ilof.EpisodeFileOptions{
+			Worktree:    wt,
+			Description: desc,
+			TagRules:    tagRules,
+		}); err != nil {
+			fail("* Creating episode file for %d: %v", epNum, err)
 		} else {
-			log.Printf("- Wrote episode %d file: %s", epNum, epPath)
+			if res.Summarized {
+				log.Printf("- Filled in summary from the video description (review before publishing)")
+			}
+			log.Printf("- Staged episode %d file: %s", epNum, res.Path)
+		}
+		if path, err := archiveAnnouncement(wt, epNum, up); err != nil {
+			fail("* Archiving announcement for %d: %v", epNum, err)
+		} else if path != "" {
+			log.Printf("- Staged announcement archive: %s", path)
+			archived = append(archived, path)
 		}
 
 		for _, guest := range up.Guests {
 			log.Printf("- Guest: %s", guest)
 		}
-		if *doDryRun {
-			log.Printf("@ Skipped guest list update, this is a dry run")
-		} else if err := ilof.AddOrUpdateGuests(float64(epNum), guestFile, up.Guests); err != nil {
-			log.Fatalf("* Updating guest list: %v", err)
+		if err := ilof.AddOrUpdateGuestsIn(wt, ilof.Label(strconv.Itoa(epNum)), repo.GuestFile, up.Guests, &ilof.GuestOptions{
+			MaxNotes: *notesLength,
+			KeepBio:  *keepBio,
+		}); err != nil {
+			fail("* Updating guest list: %v", err)
 		}
 		editPaths = append(editPaths, epPath)
+		added = append(added, strconv.Itoa(epNum))
 		guestsDirty = guestsDirty || len(up.Guests) != 0
-		numValid++
+		index = append(index, &ilof.Episode{Episode: ilof.Label(strconv.Itoa(epNum)), Date: ilof.Date(up.AirDate)})
+
+		if baseBranch != "" {
+			if err := publishBranch(tx, baseBranch, branch, epNum); err != nil {
+				fail("* Publishing episode %d on branch %q: %v", epNum, branch, err)
+			}
+		}
+	}
+	if *doDryRun {
+		reportDryRun(dryRun)
+		return latest.Date, true
+	} else if baseBranch != "" {
+		notifyAdded(ctx, added, "on branches "+branchPrefix+"*")
+		return latest.Date, true
+	}
+	if err := tx.Commit(); err != nil {
+		fail("Writing updates: %v", err)
+	}
+	if n := len(tx.Paths()); n != 0 {
+		log.Printf("Wrote %d files", n)
 	}
 	if guestsDirty {
-		editPaths = append(editPaths, guestFile)
+		// If the guest list is sharded, this includes all the shards.
+		paths, err := ilof.GuestFiles(repo.GuestFile)
+		if err != nil {
+			fail("Listing guest files: %v", err)
+		}
+		editPaths = append(editPaths, paths...)
 	}
-
 	if *doEdit && len(editPaths) != 0 {
-		if err := editFiles(editPaths); err != nil {
-			log.Fatalf("Edit failed: %v", err)
+		if err := editFiles(editPaths); errors.Is(err, errNoTerminal) {
+			log.Printf("* Not editing files: %v", err)
+			for _, path := range editPaths {
+				log.Printf("  %s", path)
+			}
+		} else if err != nil {
+			fail("Edit failed: %v", err)
 		}
 	}
+	if *doCommit && len(editPaths) != 0 {
+		if err := publish(append(editPaths, archived...), added); err != nil {
+			fail("Publishing update: %v", err)
+		}
+	}
+	notifyAdded(ctx, added, "")
 	return latest.Date, true
 }
 
-func createEpisodeFile(path string, num int, desc string, up *ilof.TwitterUpdate) error {
-	ep, err := ilof.LoadEpisode(path)
+// notifyAdded posts a notice of the episodes with the given labels to the
+// webhook, if one is configured, noting where they are if where != "". A
+// failure to notify is logged, but does not stop the update.
+func notifyAdded(ctx context.Context, added []string, where string) {
+	hook := *webhookURL
+	if hook == "" {
+		hook = ilof.WebhookURL.Get()
+	}
+	if hook == "" || len(added) == 0 {
+		return
+	}
+	text := "New episodes: " + strings.Join(added, ", ")
+	if where != "" {
+		text += " (" + where + ")"
+	}
+	if err := ilof.Notify(ctx, hook, text, added); err != nil {
+		log.Printf("* Posting to webhook: %v", err)
+	}
+}
+
+// reportDryRun prints the changes recorded by d, this being a dry run.
+func reportDryRun(d *repo.DryRun) {
+	for _, w := range d.Writes() {
+		if !w.Changed() {
+			continue
+		}
+		verb := "update"
+		if w.Created {
+			verb = "create"
+		}
+		log.Printf("@ Would %s %s, this is a dry run:", verb, w.Path)
+		os.Stdout.Write(w.New)
+		fmt.Println()
+	}
+}
+
+// tagRules are the rules used to tag new episodes from their descriptions.
+var tagRules = ilof.TagRules
+
+// archiveAnnouncement stages a record of the announcement of episode num
+// under the announcement directory, and returns its path. If up does not
+// include the announcement post, it does nothing and returns "".
+func archiveAnnouncement(wt repo.Worktree, num int, up *ilof.TwitterUpdate) (string, error) {
+	a := up.Announcement(ilof.Label(strconv.Itoa(num)))
+	if a == nil {
+		return "", nil
+	}
+	if !*doDryRun {
+		if err := os.MkdirAll(repo.AnnouncementDir, 0755); err != nil {
+			return "", err
+		}
+	}
+	return ilof.WriteAnnouncementIn(wt, repo.AnnouncementDir, a)
+}
+
+// publish commits the files at paths, for the added episode numbers, and
+// with -push pushes the commit to origin.
+func publish(paths, added []string) error {
+	msg := "Add episode " + added[0]
+	if len(added) > 1 {
+		msg = "Add episodes " + strings.Join(added, ", ")
+	}
+	if err := repo.Commit(paths, msg); err != nil {
+		return err
+	}
+	log.Printf("- Committed %d files: %s", len(paths), msg)
+	if !*doPush {
+		return nil
+	}
+	branch, err := repo.CurrentBranch()
 	if err != nil {
-		if !os.IsNotExist(err) {
+		return err
+	} else if err := repo.Push("origin", branch); err != nil {
+		return err
+	}
+	log.Printf("- Pushed branch %q to origin", branch)
+	return nil
+}
+
+// publishBranch creates the given branch from base, applies the changes
+// staged in tx for episode epNum and commits them to the branch, then returns
+// to base. With -push, the branch is also pushed to origin.
+func publishBranch(tx *repo.Transaction, base, branch string, epNum int) error {
+	if ok, err := repo.BranchExists(branch); err != nil {
+		return err
+	} else if ok {
+		// With -force, replace the existing branch.
+		if err := repo.DeleteBranch(branch); err != nil {
 			return err
 		}
-		ep = &ilof.Episode{
-			Episode: ilof.Label(strconv.Itoa(num)),
-			Date:    ilof.Date(up.AirDate),
-			Detail:  desc,
+	}
+	if err := repo.CreateBranch(branch); err != nil {
+		return err
+	}
+	paths := tx.Paths()
+	err := tx.Commit()
+	if err == nil {
+		err = repo.Commit(paths, fmt.Sprintf("Add episode %d", epNum))
+	}
+	if err != nil {
+		// Leave the branch checked out so that the problem can be inspected.
+		return err
+	}
+	log.Printf("- Committed %d files to branch %q", len(paths), branch)
+
+	if err := repo.Checkout(base); err != nil {
+		return fmt.Errorf("returning to %q: %w", base, err)
+	}
+	if *doPush {
+		if err := repo.Push("origin", branch); err != nil {
+			return err
 		}
+		log.Printf("- Pushed branch %q to origin", branch)
 	}
-	if ilof.Similarity(desc, "cheese night") != 0 {
-		ep.AddTag("cheese-night")
+	return nil
+}
+
+// pruneBranches deletes the episode branches created by -branch-per-episode
+// that have been merged into the current branch.
+func pruneBranches() error {
+	names, err := repo.MergedBranches(branchPrefix)
+	if err != nil {
+		return err
 	}
-	if ilof.Similarity(desc, "where's lie") != 0 {
-		ep.AddTag("truth-from-fiction")
+	for _, name := range names {
+		if err := repo.DeleteBranch(name); err != nil {
+			return fmt.Errorf("deleting %q: %w", name, err)
+		}
+		log.Printf("- Deleted merged branch %q", name)
 	}
-	ep.CrowdcastURL = up.Crowdcast
-	ep.YouTubeURL = up.YouTube
-	return ilof.WriteEpisode(path, ep)
+	log.Printf("Pruned %d branches", len(names))
+	return nil
 }
 
 func fetchEpisodeInfo(ctx context.Context, up *ilof.TwitterUpdate, apiKey string) (*ilof.VideoInfo, error) {
@@ -247,23 +531,43 @@ func fileExists(path string) bool {
 	return err == nil
 }
 
+// errNoTerminal is reported by editFiles when there is no terminal on which
+// to run the editor.
+var errNoTerminal = errors.New("no terminal is available")
+
+// editFiles runs the editor named by the EDITOR environment variable on the
+// given paths. EDITOR may include arguments, as "code --wait"; words may be
+// quoted with single or double quotes. The editor uses the standard input and
+// output of the process if they are a terminal, or else the controlling
+// terminal if there is one; otherwise editFiles reports errNoTerminal.
 func editFiles(paths []string) error {
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
+	args, err := splitCommand(os.Getenv("EDITOR"))
+	if err != nil {
+		return fmt.Errorf("invalid EDITOR: %w", err)
+	} else if len(args) == 0 {
 		return errors.New("no EDITOR is defined")
 	}
 
-	// Ensure the editor can interact with the controlling terminal.
-	f, err := os.Open("/dev/tty")
-	if err != nil {
-		return err
+	// Ensure the editor can interact with a terminal. If stdio has been
+	// redirected, fall back to the controlling terminal (not on Windows,
+	// which has no /dev/tty).
+	stdin, stdout := os.Stdin, os.Stdout
+	if !ilof.IsTerminal(stdin) || !ilof.IsTerminal(stdout) {
+		if runtime.GOOS == "windows" {
+			return errNoTerminal
+		}
+		f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			return errNoTerminal
+		}
+		defer f.Close()
+		stdin, stdout = f, f
 	}
-	defer f.Close()
 
-	cmd := exec.Command(editor, paths...)
+	cmd := exec.Command(args[0], append(args[1:], paths...)...)
 	buf := bytes.NewBuffer(nil)
-	cmd.Stdin = f
-	cmd.Stdout = f
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
 	cmd.Stderr = buf
 	err = cmd.Run()
 	if err != nil {
@@ -276,6 +580,43 @@ func editFiles(paths []string) error {
 	return nil
 }
 
+// splitCommand splits s into words separated by whitespace. A word may be
+// quoted with single or double quotes to include whitespace. Backslashes are
+// not special, so that Windows paths need no escaping.
+func splitCommand(s string) ([]string, error) {
+	var out []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				out = append(out, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	} else if inWord {
+		out = append(out, word.String())
+	}
+	return out, nil
+}
+
 func todayStart(now time.Time) time.Time {
 	if isShowDay := now.Weekday()%2 == 1; !isShowDay || now.UTC().Hour() > showStartHour+1 {
 		return nextStartAfter(now)
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		input, want string // want is the words as printed by %q, or an error
+	}{
+		{"", "[]"},
+		{"   ", "[]"},
+		{"vi", `["vi"]`},
+		{"  code   --wait  ", `["code" "--wait"]`},
+		{`"/Applications/Sublime Text.app/subl" -w`, `["/Applications/Sublime Text.app/subl" "-w"]`},
+		{`emacs -nw '+set tw=72'`, `["emacs" "-nw" "+set tw=72"]`},
+		{`a"b c"d`, `["ab cd"]`},
+		{`"" x`, `["" "x"]`},
+		{`it's "quoted"`, "error: unterminated ' quote"},
+		{`C:\Tools\edit.exe /w`, `["C:\\Tools\\edit.exe" "/w"]`},
+		{`vi "unterminated`, `error: unterminated " quote`},
+	}
+	for _, tc := range tests {
+		words, err := splitCommand(tc.input)
+		got := fmt.Sprintf("%q", words)
+		if err != nil {
+			got = "error: " + err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("splitCommand(%q): got %s, want %s", tc.input, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Program epfmt rewrites the episode files of the site repository in
+// canonical form.
+//
+// In canonical form, the front matter fields appear in a fixed order, values
+// are quoted only where necessary, tags are written as a flow list, and the
+// body has no trailing whitespace. Comments and unrecognized fields in the
+// front matter are preserved. This is the same form written by the other
+// tools that update episode files, so keeping the files canonical keeps the
+// diffs from those tools small.
+//
+// With -check, files are not modified; instead the files not in canonical
+// form are listed, and the program exits with status 1 if there are any.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var doCheck = flag.Bool("check", false, "List files not in canonical form without modifying them")
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	var numFiles, numChanged int
+	if err := ilof.ForEachEpisodeFile(repo.EpisodeDir, func(path string) error {
+		numFiles++
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		canon, err := ilof.FormatEpisodeFile(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		} else if bytes.Equal(canon, data) {
+			return nil
+		}
+		numChanged++
+		fmt.Println(path)
+		if *doCheck {
+			return nil
+		}
+		return atomicfile.WriteData(path, canon, 0644)
+	}); err != nil {
+		log.Fatalf("Formatting episodes: %v", err)
+	}
+	if *doCheck {
+		log.Printf("%d of %d files are not in canonical form", numChanged, numFiles)
+		if numChanged != 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	log.Printf("Rewrote %d of %d files", numChanged, numFiles)
+}
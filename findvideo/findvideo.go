@@ -0,0 +1,280 @@
+// Program findvideo searches the YouTube channel of the show for the videos
+// of episodes that have no YouTube URL, and proposes candidates for them.
+//
+// You must provide a YOUTUBE_API_KEY credential with a YouTube Data API key
+// (run "doctor" to see where credentials are sought). By default the channel
+// is the one that published the video of the most recent episode that has
+// one; use -channel to specify another.
+//
+// Each video on the channel not already linked from an episode is scored
+// against each episode lacking a video, by its publication date, by whether
+// its title gives the episode number, and by the similarity of its title and
+// description to the guests, topics, and summary of the episode. Like
+// summarize, the proposals are written to a file for review:
+//
+//	findvideo -o proposals.json
+//
+// Each proposal gives the best candidate and its score in [0, 1], and lists
+// the runners-up. No video is proposed for two episodes: a video goes to the
+// episode it matches best, and the other episodes get their next best
+// candidates. Edit the proposals as needed, set "reviewed" to true on those to
+// keep, and apply them:
+//
+//	findvideo -apply proposals.json
+//
+// Applying reviewed proposals that share a video is an error.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath   = flag.String("o", "", "Write proposals to this file (default stdout)")
+	applyPath = flag.String("apply", "", "Apply the reviewed proposals in this file")
+	channelID = flag.String("channel", "", "YouTube channel ID to search (default: from the latest episode)")
+	minScore  = flag.Float64("min-score", 0.2, "Minimum score of a candidate to propose")
+	numAlts   = flag.Int("alts", 2, "Number of runner-up candidates to list per proposal")
+)
+
+// A candidate is a video proposed for an episode.
+type candidate struct {
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	Published time.Time `json:"published"`
+	Score     float64   `json:"score"`
+}
+
+// A proposal is a video URL proposed for an episode, pending human review.
+type proposal struct {
+	Episode   ilof.Label   `json:"episode"`
+	Path      string       `json:"path"`
+	Date      ilof.Date    `json:"date"`
+	candidate              // the best candidate, applied if reviewed
+	Others    []*candidate `json:"others,omitempty"`
+	Reviewed  bool         `json:"reviewed"` // set to true by a human to apply
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	if *applyPath != "" {
+		if err := applyProposals(*applyPath); err != nil {
+			log.Fatalf("Applying proposals: %v", err)
+		}
+		return
+	}
+
+	apiKey, err := ilof.YouTubeAPIKey.Value()
+	if err != nil {
+		log.Fatal(err)
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	gidx := ilof.GuestIndex(guests)
+
+	var missing []*ilof.Episode
+	var latest *ilof.Episode
+	paths := make(map[ilof.Label]string)
+	linked := make(map[string]bool) // video IDs already linked
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		paths[ep.Episode] = path
+		if id, ok := ilof.YouTubeVideoID(ep.YouTubeURL); ok {
+			linked[id] = true
+			if latest == nil || time.Time(ep.Date).After(time.Time(latest.Date)) {
+				latest = ep
+			}
+			return nil
+		} else if ep.YouTubeURL != "" {
+			return nil // a URL we do not understand; leave it to lint
+		}
+		for _, g := range gidx[ep.Episode] {
+			ep.Guests = append(ep.Guests, g.Name)
+		}
+		missing = append(missing, ep)
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	log.Printf("Found %d episodes without a video", len(missing))
+	if len(missing) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	if *channelID == "" {
+		if latest == nil {
+			log.Fatal("No episode has a video; specify a -channel")
+		}
+		id, _ := ilof.YouTubeVideoID(latest.YouTubeURL)
+		info, err := ilof.YouTubeVideoInfo(ctx, id, apiKey)
+		if err != nil {
+			log.Fatalf("Finding channel from episode %s: %v", latest.Episode, err)
+		}
+		*channelID = info.ChannelID
+		log.Printf("Using channel %s (%s) from episode %s", info.ChannelID, info.ChannelTitle, latest.Episode)
+	}
+	all, err := ilof.YouTubeChannelVideos(ctx, *channelID, apiKey)
+	if err != nil {
+		log.Fatalf("Listing channel videos: %v", err)
+	}
+	var videos []*ilof.VideoInfo
+	for _, v := range all {
+		if !linked[v.ID] {
+			videos = append(videos, v)
+		}
+	}
+	log.Printf("Found %d channel videos not linked from an episode", len(videos))
+
+	matches := make([][]*ilof.VideoMatch, len(missing))
+	for i, ep := range missing {
+		matches[i] = ilof.MatchVideo(ep, videos)
+	}
+	best := assignVideos(matches, *minScore)
+
+	props := []*proposal{}
+	for i, ep := range missing {
+		b := best[i]
+		if b < 0 {
+			continue
+		}
+		ms := matches[i]
+		p := &proposal{
+			Episode:   ep.Episode,
+			Path:      paths[ep.Episode],
+			Date:      ep.Date,
+			candidate: *newCandidate(ms[b]),
+		}
+		for j, m := range ms {
+			if len(p.Others) == *numAlts {
+				break
+			} else if j != b {
+				p.Others = append(p.Others, newCandidate(m))
+			}
+		}
+		props = append(props, p)
+	}
+
+	data, err := json.MarshalIndent(props, "", "  ")
+	if err != nil {
+		log.Fatalf("Encoding proposals: %v", err)
+	}
+	data = append(data, '\n')
+	if *outPath == "" {
+		os.Stdout.Write(data)
+	} else if err := atomicfile.WriteData(*outPath, data, 0644); err != nil {
+		log.Fatalf("Writing proposals: %v", err)
+	}
+	log.Printf("Proposed videos for %d episodes; review them and set \"reviewed\": true to apply", len(props))
+}
+
+// assignVideos chooses a video for each episode, given the candidate matches
+// for each episode in decreasing order of score, so that no video is
+// proposed for more than one episode. The highest-scoring matches are taken
+// first, and an episode whose best video is taken falls back to its next
+// best. It returns the index in matches[i] of the video chosen for episode
+// i, or -1 if no video with at least minScore remains for it.
+func assignVideos(matches [][]*ilof.VideoMatch, minScore float64) []int {
+	type pair struct{ ep, m int }
+	var pairs []pair
+	for i, ms := range matches {
+		for j, m := range ms {
+			if m.Score >= minScore {
+				pairs = append(pairs, pair{i, j})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool {
+		return matches[pairs[a].ep][pairs[a].m].Score > matches[pairs[b].ep][pairs[b].m].Score
+	})
+
+	out := make([]int, len(matches))
+	for i := range out {
+		out[i] = -1
+	}
+	taken := make(map[string]bool)
+	for _, p := range pairs {
+		id := matches[p.ep][p.m].Video.ID
+		if out[p.ep] >= 0 || taken[id] {
+			continue
+		}
+		out[p.ep] = p.m
+		taken[id] = true
+	}
+	return out
+}
+
+func newCandidate(m *ilof.VideoMatch) *candidate {
+	return &candidate{
+		URL:       "https://www.youtube.com/watch?v=" + m.Video.ID,
+		Title:     m.Video.Title,
+		Published: m.Video.PublishedAt,
+		Score:     math.Round(m.Score*1000) / 1000,
+	}
+}
+
+// applyProposals sets the YouTube URLs of the episodes in the reviewed
+// proposals in the file at path. Episodes that have acquired a YouTube URL
+// since the proposal was generated are not changed.
+func applyProposals(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var props []*proposal
+	if err := json.Unmarshal(data, &props); err != nil {
+		return fmt.Errorf("decoding proposals: %w", err)
+	}
+	seen := make(map[string]ilof.Label)
+	for _, p := range props {
+		if !p.Reviewed {
+			continue
+		} else if old, ok := seen[p.URL]; ok {
+			return fmt.Errorf("video %s is proposed for both episode %s and episode %s", p.URL, old, p.Episode)
+		}
+		seen[p.URL] = p.Episode
+	}
+
+	var numApplied, numSkipped int
+	for _, p := range props {
+		if !p.Reviewed {
+			numSkipped++
+			continue
+		}
+		ep, err := ilof.LoadEpisode(p.Path)
+		if err != nil {
+			return err
+		} else if ep.Episode != p.Episode {
+			return fmt.Errorf("%s: proposal is for episode %s, file has episode %s", p.Path, p.Episode, ep.Episode)
+		} else if ep.YouTubeURL != "" {
+			log.Printf("- Episode %s already has a YouTube URL; skipped", ep.Episode)
+			continue
+		} else if _, ok := ilof.YouTubeVideoID(p.URL); !ok {
+			return fmt.Errorf("%s: invalid YouTube URL %q", p.Path, p.URL)
+		}
+		ep.YouTubeURL = p.URL
+		if err := ilof.WriteEpisode(p.Path, ep); err != nil {
+			return err
+		}
+		fmt.Printf("%s: set youtube %s\n", p.Path, p.URL)
+		numApplied++
+	}
+	log.Printf("Applied %d videos (%d not reviewed)", numApplied, numSkipped)
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+)
+
+var (
+	doAll      = flag.Bool("all", false, "Fetch captions for all episodes lacking a transcript")
+	seasonFlag = flag.Int("season", 0, "With -all, only fetch episodes in this season")
+	sinceFlag  = flag.String("since", "", "With -all, only fetch episodes aired on or after this date (YYYY-MM-DD)")
+	untilFlag  = flag.String("until", "", "With -all, only fetch episodes aired on or before this date (YYYY-MM-DD)")
+	cacheDir   = flag.String("cache-dir", defaultCacheDir(), "With -all, cache YouTube responses in this directory (empty to disable)")
+	numWorkers = flag.Int("workers", 4, "With -all, number of concurrent caption fetches")
+	maxQPS     = flag.Float64("qps", 1, "Maximum YouTube requests per second (0 for no limit)")
+)
+
+// runBatch fetches transcripts for all the selected episodes lacking one, and
+// writes the results to stdout or the transcript store.
+func runBatch(ctx context.Context) error {
+	eps, err := selectEpisodes(ctx)
+	if err != nil {
+		return err
+	}
+	if *cacheDir != "" {
+		log.Printf("Caching YouTube responses in %q", *cacheDir)
+		ilof.CaptionCache = ilof.DirCache(*cacheDir)
+	}
+	log.Printf("Found %d episodes needing transcripts", len(eps))
+
+	var (
+		mu  sync.Mutex
+		out []*episodeTranscript
+	)
+	bar := ilof.NewProgressBar(os.Stderr, "Fetching transcripts")
+	log.SetOutput(bar)
+	err = ilof.RunBatch(ctx, len(eps), &ilof.BatchOptions{
+		Workers:  *numWorkers,
+		Progress: bar.Update,
+	}, func(ctx context.Context, i int) string {
+		ep := eps[i]
+		id, _ := ilof.YouTubeVideoID(ep.YouTubeURL)
+		if *outDir != "" && isStored(ep.Episode, id) {
+			return fmt.Sprintf("episode %s: already stored", ep.Episode)
+		}
+		et, msg := fetchEpisode(ctx, ep.Episode, id)
+		log.Printf("Episode %s: %s", ep.Episode, msg)
+		if et != nil {
+			mu.Lock()
+			out = append(out, et)
+			mu.Unlock()
+		}
+		return fmt.Sprintf("episode %s: %s", ep.Episode, msg)
+	})
+	bar.Finish()
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Fetched %d of %d transcripts", len(out), len(eps))
+	if *outDir != "" {
+		return nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	return enc.Encode(struct {
+		T []*episodeTranscript `json:"transcripts"`
+	}{T: out})
+}
+
+// fetchEpisode fetches and (with -out-dir) stores the transcript for the given
+// episode and video ID. It returns the envelope to report, or nil if the fetch
+// failed, along with a progress message.
+func fetchEpisode(ctx context.Context, label ilof.Label, id string) (*episodeTranscript, string) {
+	cap, err := fetchTranscript(ctx, id)
+	if errors.Is(err, errNoCaptions) {
+		return nil, "no captions"
+	} else if err != nil {
+		return nil, err.Error()
+	}
+	et := newEnvelope(ctx, label, cap)
+	if *outDir == "" {
+		if *doStamp {
+			if err := updateEpisode(label, "", cap); err != nil {
+				return nil, fmt.Sprintf("updating episode: %v", err)
+			}
+		}
+		return et, fmt.Sprintf("%d captions", len(cap.Captions))
+	} else if err := storeTranscript(et); err != nil {
+		return nil, fmt.Sprintf("storing transcript: %v", err)
+	}
+	return &episodeTranscript{Episode: label}, fmt.Sprintf("stored %d captions", len(cap.Captions))
+}
+
+// selectEpisodes returns the episodes from the catalog that have a YouTube
+// video but no transcript, and that satisfy the filter flags.
+func selectEpisodes(ctx context.Context) ([]*ilof.Episode, error) {
+	since, err := parseDateFlag("since", *sinceFlag)
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseDateFlag("until", *untilFlag)
+	if err != nil {
+		return nil, err
+	}
+	eps, err := ilof.AllEpisodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading episodes: %w", err)
+	}
+
+	var out []*ilof.Episode
+	for _, ep := range eps {
+		if ep.Transcript != "" {
+			continue // already have one
+		} else if _, ok := ilof.YouTubeVideoID(ep.YouTubeURL); !ok {
+			continue // no video to fetch
+		} else if *seasonFlag > 0 && ep.Season != *seasonFlag {
+			continue
+		}
+		at := time.Time(ep.Date)
+		if !since.IsZero() && at.Before(since) {
+			continue
+		} else if !until.IsZero() && at.After(until) {
+			continue
+		}
+		out = append(out, ep)
+	}
+	return out, nil
+}
+
+// limitRate limits the rate of YouTube requests, if the -qps flag sets a
+// limit.
+func limitRate() {
+	if *maxQPS > 0 {
+		ilof.RateLimiter = ilof.NewHostLimiter(*maxQPS, 1)
+	}
+}
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ilof", "fytt")
+}
+
+func parseDateFlag(name, s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	ts, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -%s date: %w", name, err)
+	}
+	return ts, nil
+}
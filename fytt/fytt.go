@@ -4,32 +4,55 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
 )
 
 var (
-	videoID = flag.String("id", "", "Video ID to fetch")
-	episode = flag.String("episode", "", "Episode number")
+	videoIDs []string // from -id
+	episodes []string // from -episode
+
+	format = flag.String("format", "json", "Output format (json, srt, vtt, podcast, text); with -out-dir, a comma-separated list")
+	lang   = flag.String("lang", "", "Caption language to select (default prefers English)")
+	kind   = flag.String("track-kind", "", `Caption track kind to select ("asr" or "standard")`)
+	doList = flag.Bool("list-tracks", false, "List the available caption tracks and exit")
+	width  = flag.Int("width", 72, "Line width for -format=text (0 for one caption per line)")
+
+	clientVersion = flag.String("client-version", ilof.InnerTubeClientVersion, "YouTube web player version to report when listing caption tracks")
 )
 
 func init() {
+	flag.Func("id", "Video ID to fetch (comma-separated, or repeat the flag)", appendList(&videoIDs))
+	flag.Func("episode", "Episode number (comma-separated, or repeat the flag)", appendList(&episodes))
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: %[1]s -id <video-id>
-       %[1]s -episode <episode-id>
+		fmt.Fprintf(os.Stderr, `Usage: %[1]s -id <video-id>[,<video-id>...]
+       %[1]s -episode <episode-id>[,<episode-id>...]
+       %[1]s -all [-season n] [-since date] [-until date]
 
 Fetch text captions for a YouTube video. Either the -id of the video
 must be specified directly, or the -episode whose video URL is to be
 fetched.
 
-Output is written to stdout as JSON:
+Output is written to stdout in the selected -format. The default is JSON:
 
   {
+    "episode": "<episode-id>",
+    "video": {
+      "title": "<video-title>",
+      "publishedAt": "<timestamp>",
+      "channelId": "<channel-id>",
+      "channelTitle": "<channel-title>"
+    },
     "transcript": {
       "videoID": "<video-id>",
       "captionsURL": "<captions-url>",
@@ -41,6 +64,50 @@ Output is written to stdout as JSON:
     }
   }
 
+The "episode" field is set when an -episode is given. The "video" field is
+set when a YOUTUBE_API_KEY credential is available.
+
+Several videos or episodes may be fetched at once by giving -id or -episode
+a comma-separated list, or by repeating the flag. Their transcripts are
+written as a JSON object in the same format as for -all, below, or with
+-out-dir, each is stored separately. A video that fails is logged and
+skipped; if every video fails, fytt exits with a non-zero status.
+
+With -all, fetch captions for every episode that has a YouTube URL but no
+stored transcript, optionally restricted to a -season or range of air dates.
+Output is a JSON object with an array of episode transcripts:
+
+  {"transcripts": [{"episode": "<label>", "transcript": {...}}, ...]}
+
+Batch fetches cache YouTube caption data in -cache-dir, and with -out-dir
+skip episodes whose transcripts are already stored, so that an interrupted
+run can be resumed by running it again. Use -workers to control the number
+of concurrent fetches. Progress is displayed on stderr, as a bar if stderr
+is a terminal.
+
+In every mode, requests to each YouTube host are limited to -qps requests
+per second (default 1; 0 for no limit). Fetching too quickly will trigger
+YouTube's rate limits.
+
+With -out-dir, each transcript is instead stored in the site repository as
+<out-dir>/<episode>-<video-id>.<ext> in each of the selected formats, and
+the "transcript" field of the episode file is updated to refer to it. Use
+-store to store them in the transcript directory of the repository layout.
+
+If the video has multiple caption tracks, use -lang and -track-kind to
+select among them. Use -list-tracks to list the tracks available. If the
+watch page does not list caption tracks, they are requested from YouTube
+as by its web player; if YouTube rejects the request, set -client-version
+to the version of a recent web player.
+
+With -stamp, the caption URL and the date of the fetch are also recorded in
+the "caption-url" and "caption-date" fields of the episode file.
+
+With -format=srt or -format=vtt, the captions are written as a SubRip or
+WebVTT subtitle file. With -format=podcast, they are written in the JSON
+transcript format of the Podcasting 2.0 namespace. With -format=text, the
+caption text is written as plain text wrapped to -width columns.
+
 Options:
 `, filepath.Base(os.Args[0]))
 		flag.PrintDefaults()
@@ -49,45 +116,257 @@ Options:
 
 func main() {
 	flag.Parse()
-	if *videoID == "" && *episode == "" {
-		log.Fatal("You must set a non-empty video -id or an -episode")
+	if *doStore {
+		if *outDir != "" {
+			log.Fatal("You cannot set both -store and -out-dir")
+		} else if err := repo.ChdirRoot(); err != nil {
+			log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+		}
+		*outDir = repo.TranscriptDir
+	}
+	if len(videoIDs) == 0 && len(episodes) == 0 && !*doAll {
+		log.Fatal("You must set a non-empty video -id or an -episode, or -all")
+	}
+	for _, f := range strings.Split(*format, ",") {
+		switch f {
+		case "json", "srt", "vtt", "podcast", "text":
+		default:
+			log.Fatalf("Unknown output format %q", f)
+		}
+	}
+	if *outDir == "" && strings.Contains(*format, ",") {
+		log.Fatal("Multiple output formats require -out-dir")
 	}
 
 	ctx := context.Background()
-	if *episode != "" {
-		ep, err := ilof.FetchEpisode(ctx, *episode)
+	limitRate()
+	ilof.InnerTubeClientVersion = *clientVersion
+	if *outDir != "" || *doStamp {
+		if len(episodes) == 0 && !*doAll {
+			log.Fatal("You must set an -episode or -all with -out-dir or -stamp")
+		} else if len(videoIDs) != 0 && !*doAll {
+			log.Fatal("You cannot set a video -id with -out-dir or -stamp")
+		}
+		if err := openStore(); err != nil {
+			log.Fatalf("Opening transcript store: %v", err)
+		}
+	}
+	if *doAll {
+		if *outDir == "" && *format != "json" {
+			log.Fatalf("Batch mode does not support -format=%s", *format)
+		}
+		if err := runBatch(ctx); err != nil {
+			log.Fatalf("Batch fetch failed: %v", err)
+		}
+		return
+	}
+	targets, err := resolveTargets(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *doList {
+		for _, tg := range targets {
+			tracks, err := ilof.YouTubeCaptionTracks(ctx, tg.id)
+			if err != nil {
+				log.Fatalf("Listing caption tracks for %q: %v", tg.id, err)
+			}
+			for _, t := range tracks {
+				kind := "standard"
+				if t.IsAutomatic() {
+					kind = "asr"
+				}
+				if len(targets) > 1 {
+					fmt.Printf("%-12s ", tg.id)
+				}
+				fmt.Printf("%-8s %-8s %s\n", t.Lang, kind, t.Name.Text)
+			}
+		}
+		return
+	}
+	if len(targets) > 1 {
+		if *outDir == "" && *format != "json" {
+			log.Fatalf("Multiple videos do not support -format=%s without -out-dir", *format)
+		}
+		if err := runList(ctx, targets); err != nil {
+			log.Fatalf("Fetching transcripts: %v", err)
+		}
+		return
+	}
+
+	tg := targets[0]
+	cap, err := fetchTranscript(ctx, tg.id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Found %d captions for ID %q", len(cap.Captions), cap.VideoID)
+
+	et := newEnvelope(ctx, tg.label, cap)
+	if *outDir != "" {
+		if err := storeTranscript(et); err != nil {
+			log.Fatalf("Storing transcript: %v", err)
+		}
+		return
+	} else if *doStamp {
+		if err := updateEpisode(et.Episode, "", cap); err != nil {
+			log.Fatalf("Updating episode: %v", err)
+		}
+	}
+	if err := writeTranscript(os.Stdout, *format, et); err != nil {
+		log.Fatalf("Writing output: %v", err)
+	}
+}
+
+// appendList returns a flag handler that appends the comma-separated values
+// of each use of the flag to *vs.
+func appendList(vs *[]string) func(string) error {
+	return func(s string) error {
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				*vs = append(*vs, v)
+			}
+		}
+		return nil
+	}
+}
+
+// A target is a video whose transcript is to be fetched, and the label of its
+// episode if it was selected by -episode.
+type target struct {
+	label ilof.Label
+	id    string
+}
+
+// resolveTargets returns the videos selected by the -id and -episode flags,
+// looking up the video of each episode on the site.
+func resolveTargets(ctx context.Context) ([]target, error) {
+	var out []target
+	for _, id := range videoIDs {
+		out = append(out, target{id: id})
+	}
+	for _, num := range episodes {
+		ep, err := ilof.FetchEpisode(ctx, num)
 		if err != nil {
-			log.Fatalf("Fetching episode %q: %v", *episode, err)
+			return nil, fmt.Errorf("fetching episode %q: %w", num, err)
 		}
 		id, ok := ilof.YouTubeVideoID(ep.YouTubeURL)
 		if !ok {
-			log.Fatalf("Unable to find video ID for episode %q", ep.Episode)
+			return nil, fmt.Errorf("unable to find video ID for episode %q", ep.Episode)
 		}
 		log.Printf("Found video ID %q for episode %q", id, ep.Episode)
-		*videoID = id
+		out = append(out, target{label: ep.Episode, id: id})
+	}
+	return out, nil
+}
+
+// runList fetches transcripts for each of the targets in order, and writes
+// them to stdout as for -all, or with -out-dir stores them. A target whose
+// transcript cannot be fetched is logged and skipped, but it is an error if
+// none can be fetched.
+func runList(ctx context.Context, targets []target) error {
+	var out []*episodeTranscript
+	for _, tg := range targets {
+		name := tg.id
+		if tg.label != "" {
+			name = "episode " + string(tg.label)
+		}
+		et, msg := fetchEpisode(ctx, tg.label, tg.id)
+		log.Printf("%s: %s", name, msg)
+		if et != nil {
+			out = append(out, et)
+		}
+	}
+	log.Printf("Fetched %d of %d transcripts", len(out), len(targets))
+	if *outDir == "" {
+		if err := json.NewEncoder(os.Stdout).Encode(struct {
+			T []*episodeTranscript `json:"transcripts"`
+		}{T: out}); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
+	if len(out) == 0 {
+		return fmt.Errorf("none of the %d transcripts could be fetched", len(targets))
 	}
+	return nil
+}
 
-	url, err := ilof.YouTubeCaptionURL(ctx, *videoID)
+// errNoCaptions is reported by fetchTranscript for a video without captions.
+var errNoCaptions = errors.New("no captions found")
+
+// fetchTranscript fetches the captions for the specified video ID.
+func fetchTranscript(ctx context.Context, id string) (*ilof.Transcript, error) {
+	url, err := ilof.YouTubeSelectCaption(ctx, id, &ilof.CaptionOptions{
+		Lang: *lang,
+		Kind: *kind,
+	})
 	if err != nil {
-		log.Fatalf("Getting caption URL: %v", err)
+		return nil, fmt.Errorf("getting caption URL: %w", err)
 	} else if url == "" {
-		log.Fatalf("No caption URL found for video ID %q", *videoID)
+		return nil, fmt.Errorf("video ID %q: %w", id, errNoCaptions)
 	}
-	log.Printf("Caption URL: %q", url)
-
 	cap, err := ilof.YouTubeCaptionData(ctx, url)
 	if err != nil {
-		log.Fatalf("Getting caption data: %v", err)
+		return nil, fmt.Errorf("getting caption data: %w", err)
 	}
-	cap.VideoID = *videoID
-	log.Printf("Found %d captions for ID %q", len(cap.Captions), cap.VideoID)
+	cap.VideoID = id
+	return cap, nil
+}
+
+// An episodeTranscript is the envelope for a transcript in JSON output.
+type episodeTranscript struct {
+	Episode    ilof.Label       `json:"episode,omitempty"`
+	Video      *videoMeta       `json:"video,omitempty"`
+	Transcript *ilof.Transcript `json:"transcript,omitempty"`
+}
 
-	// TODO(creachadair): Other output formats.
-	bits, err := json.Marshal(struct {
-		Transcript *ilof.Transcript `json:"transcript"`
-	}{cap})
+// videoMeta records metadata about the video from which a transcript was
+// taken.
+type videoMeta struct {
+	Title        string    `json:"title"`
+	PublishedAt  time.Time `json:"publishedAt"`
+	ChannelID    string    `json:"channelId"`
+	ChannelTitle string    `json:"channelTitle"`
+}
+
+// newEnvelope returns an envelope for transcript t of the given episode.
+// If a YouTube API key is available, the envelope includes video metadata.
+func newEnvelope(ctx context.Context, label ilof.Label, t *ilof.Transcript) *episodeTranscript {
+	et := &episodeTranscript{Episode: label, Transcript: t}
+	apiKey := ilof.YouTubeAPIKey.Get()
+	if apiKey == "" {
+		return et
+	}
+	info, err := ilof.YouTubeVideoInfo(ctx, t.VideoID, apiKey)
+	if err != nil {
+		log.Printf("* Unable to fetch video detail for %q: %v", t.VideoID, err)
+		return et
+	}
+	et.Video = &videoMeta{
+		Title:        info.Title,
+		PublishedAt:  info.PublishedAt,
+		ChannelID:    info.ChannelID,
+		ChannelTitle: info.ChannelTitle,
+	}
+	return et
+}
+
+// writeTranscript writes et to w in the specified format.
+func writeTranscript(w io.Writer, format string, et *episodeTranscript) error {
+	t := et.Transcript
+	switch format {
+	case "srt":
+		return t.WriteSRT(w)
+	case "vtt":
+		return t.WriteVTT(w)
+	case "podcast":
+		return t.WritePodcastJSON(w)
+	case "text":
+		return t.WriteText(w, *width)
+	}
+	bits, err := json.Marshal(et)
 	if err != nil {
-		log.Fatalf("Encoding output: %v", err)
+		return fmt.Errorf("encoding output: %w", err)
 	}
-	fmt.Println(string(bits))
+	_, err = fmt.Fprintln(w, string(bits))
+	return err
 }
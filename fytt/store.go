@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outDir  = flag.String("out-dir", "", "Store transcripts in this directory of the site repository")
+	doStore = flag.Bool("store", false, "Store transcripts in the transcript directory of the site repository")
+	doStamp = flag.Bool("stamp", false, "Record the caption URL and fetch date in the episode file")
+)
+
+// formatExt maps output format names to file extensions.
+var formatExt = map[string]string{
+	"json": ".json",
+	"srt":  ".srt",
+	"vtt":  ".vtt",
+	"text": ".txt",
+
+	"podcast": ".podcast.json", // see ilof.PodcastTranscriptPaths
+}
+
+var (
+	// epPaths maps episode labels to the paths of their episode files, in the
+	// repository whose root is the working directory.
+	epPaths map[ilof.Label]string
+
+	// epMu serializes updates to episode files.
+	epMu sync.Mutex
+)
+
+// openStore changes to the repository root, creates the output directory if
+// necessary, and locates the episode files.
+func openStore() error {
+	if err := repo.ChdirRoot(); err != nil {
+		return fmt.Errorf("changing directory to repo root: %w", err)
+	}
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0755); err != nil {
+			return err
+		}
+	}
+	paths, err := ilof.EpisodePaths(repo.EpisodeDir)
+	if err != nil {
+		return err
+	}
+	epPaths = paths
+	return nil
+}
+
+// transcriptBase returns the base name, without extension, of the transcript
+// files for the given episode and video ID. The label is used as it is, so
+// that special episodes such as "S1" are named like the others.
+func transcriptBase(label ilof.Label, videoID string) string {
+	return string(label) + "-" + videoID
+}
+
+// isStored reports whether a transcript for the given episode and video ID is
+// already present in the output directory in all the selected formats.
+func isStored(label ilof.Label, videoID string) bool {
+	base := filepath.Join(*outDir, transcriptBase(label, videoID))
+	for _, f := range strings.Split(*format, ",") {
+		if !repo.FileExists(base + formatExt[f]) {
+			return false
+		}
+	}
+	return true
+}
+
+// storeTranscript writes et to the output directory in each of the selected
+// formats, and records the location of the JSON transcript (or the first
+// other format written, if JSON is not selected) in the episode file. The
+// podcast format is never recorded, as it cannot be loaded as a transcript.
+func storeTranscript(et *episodeTranscript) error {
+	label := et.Episode
+	base := filepath.Join(*outDir, transcriptBase(label, et.Transcript.VideoID))
+	var stored string
+	for _, f := range strings.Split(*format, ",") {
+		path := base + formatExt[f]
+		var buf bytes.Buffer
+		if err := writeTranscript(&buf, f, et); err != nil {
+			return err
+		}
+		if err := atomicfile.WriteData(path, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		if f != "podcast" && (stored == "" || f == "json") {
+			stored = path
+		}
+	}
+
+	return updateEpisode(label, filepath.ToSlash(stored), et.Transcript)
+}
+
+// updateEpisode updates the episode file for label to refer to the stored
+// transcript, if stored != "". With -stamp, it also records the caption URL
+// of t and the current date.
+func updateEpisode(label ilof.Label, stored string, t *ilof.Transcript) error {
+	epPath, ok := epPaths[label]
+	if !ok {
+		return fmt.Errorf("no episode file found for episode %s", label)
+	}
+	epMu.Lock()
+	defer epMu.Unlock()
+	ep, err := ilof.LoadEpisode(epPath)
+	if err != nil {
+		return err
+	}
+	dirty := false
+	if stored != "" && ep.Transcript != stored {
+		ep.Transcript = stored
+		dirty = true
+	}
+	if *doStamp {
+		today := ilof.Date(time.Now().UTC().Truncate(24 * time.Hour))
+		ep.CaptionURL = t.CaptionsURL
+		ep.CaptionDate = &today
+		dirty = true
+	}
+	if !dirty {
+		return nil // nothing to update
+	}
+	return ilof.WriteEpisode(epPath, ep)
+}
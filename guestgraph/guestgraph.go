@@ -0,0 +1,91 @@
+// Program guestgraph exports the co-appearance graph of the people on the
+// show, for visualizing its social network.
+//
+// The nodes of the graph are the guests in the guest list and the hosts
+// recorded in the episode files, and an edge joins two people who appeared
+// on the same episode, weighted by the number of episodes they shared. The
+// graph is written as Graphviz DOT, GraphML, or JSON (with "nodes" and
+// "links", as used by d3-force), chosen by -format or else by the extension
+// of the -o file. For example:
+//
+//	guestgraph -o guests.dot && dot -Kneato -Tsvg guests.dot > guests.svg
+//
+// Hosts appear on most episodes and so tend to dominate the graph; use
+// -hosts=false to leave them out.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath   = flag.String("o", "", "Write the graph to this file (default stdout)")
+	format    = flag.String("format", "", `Output format: "dot", "graphml", or "json" (default: from -o, else json)`)
+	withHosts = flag.Bool("hosts", true, "Include the hosts in the graph")
+	minWeight = flag.Int("min-weight", 1, "Omit edges with fewer shared episodes than this")
+)
+
+func main() {
+	flag.Parse()
+	f := strings.ToLower(*format)
+	if f == "" {
+		switch filepath.Ext(*outPath) {
+		case ".dot", ".gv":
+			f = "dot"
+		case ".graphml", ".xml":
+			f = "graphml"
+		default:
+			f = "json"
+		}
+	}
+	if f != "dot" && f != "graphml" && f != "json" {
+		log.Fatalf("Unknown format %q", *format)
+	}
+
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	var eps []*ilof.Episode
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		eps = append(eps, ep)
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	g := ilof.BuildGuestGraph(eps, guests, &ilof.GraphOptions{
+		OmitHosts: !*withHosts,
+		MinWeight: *minWeight,
+	})
+	log.Printf("Graph has %d people and %d connections", len(g.Nodes), len(g.Edges))
+
+	var buf bytes.Buffer
+	switch f {
+	case "dot":
+		err = g.WriteDOT(&buf)
+	case "graphml":
+		err = g.WriteGraphML(&buf)
+	default:
+		err = g.WriteJSON(&buf)
+	}
+	if err != nil {
+		log.Fatalf("Encoding graph: %v", err)
+	}
+	if *outPath == "" {
+		os.Stdout.Write(buf.Bytes())
+	} else if err := atomicfile.WriteData(*outPath, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("Writing graph: %v", err)
+	}
+}
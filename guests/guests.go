@@ -0,0 +1,502 @@
+// Program guests maintains the guest list of the site repository.
+//
+// Usage:
+//
+//	guests list [-json]
+//	guests show <name-or-handle>
+//	guests episodes [-json] <name-or-handle>
+//	guests add -name <name> [-twitter <handle>] [-url <url>] [-notes <text>] [-episodes n,...]
+//	guests merge <keep> <drop>
+//	guests fix-handles [-dry-run]
+//	guests twitter-ids [-refresh] [-all] [-dry-run]
+//	guests backfill [-dry-run]
+//	guests shard [-dir <dir>] [-dry-run]
+//	guests unshard [-file <file>] [-dry-run]
+//
+// Guests may be identified by name (ignoring case) or Twitter handle.
+//
+// The episodes command looks up the episodes of a guest on the site, rather
+// than in the repository, so it does not need a repository clone.
+//
+// Twitter handles can be renamed by their owners, which breaks links to them.
+// The twitter-ids command looks up the numeric user ID of each guest's handle
+// and records it in the guest list; with -refresh, it instead looks up the
+// current handle of each recorded ID, and updates the handles that have
+// changed. Both require a TWITTER_TOKEN credential.
+//
+// The guest list may be a single file, or sharded into several files by the
+// first letter of each guest's name, to keep the files small and reduce
+// merge conflicts. The shard command converts a single file into shards, and
+// the unshard command converts them back. Each updates the layout file of
+// the repository (.ilof.yaml) to point to the new location; the site
+// templates must be updated to match, since a directory appears in the site
+// data as a map of shards rather than a single list.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+// A command is a subcommand of the program.
+type command struct {
+	usage string
+	help  string
+	run   func(gl *ilof.GuestList, args []string) error
+
+	// If true, the command does not use the repository, and is passed a nil
+	// guest list.
+	noRepo bool
+}
+
+// commands is the registry of subcommands, by name.
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"list": {
+			usage: "[-json]",
+			help:  "List all guests",
+			run:   runList,
+		},
+		"show": {
+			usage: "<name-or-handle>",
+			help:  "Show the record and episodes of a guest",
+			run:   runShow,
+		},
+		"episodes": {
+			usage:  "[-json] <name-or-handle>",
+			help:   "List the episodes of a guest, as published on the site",
+			run:    runEpisodes,
+			noRepo: true,
+		},
+		"add": {
+			usage: "-name <name> [-twitter h] [-url u] [-notes s] [-episodes n,...]",
+			help:  "Add a new guest",
+			run:   runAdd,
+		},
+		"merge": {
+			usage: "<keep> <drop>",
+			help:  "Merge the record of guest <drop> into <keep>",
+			run:   runMerge,
+		},
+		"fix-handles": {
+			usage: "[-dry-run]",
+			help:  "Normalize Twitter handles, and fill them in from profile URLs",
+			run:   runFixHandles,
+		},
+		"twitter-ids": {
+			usage: "[-refresh] [-all] [-dry-run]",
+			help:  "Record Twitter user IDs, or update handles from them",
+			run:   runTwitterIDs,
+		},
+		"backfill": {
+			usage: "[-dry-run]",
+			help:  "Add appearances for guests named in episode summaries",
+			run:   runBackfill,
+		},
+		"shard": {
+			usage: "[-dir <dir>] [-dry-run]",
+			help:  "Split the guest file into shards by name",
+			run:   runShard,
+		},
+		"unshard": {
+			usage: "[-file <file>] [-dry-run]",
+			help:  "Combine the guest shards into a single file",
+			run:   runUnshard,
+		},
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options] [args]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := commands[name]
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n  %-12s   %s %s\n", name, c.help, "", name, c.usage)
+	}
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	name := flag.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		log.Fatalf("Unknown command %q (use -help for a list)", name)
+	}
+	var gl *ilof.GuestList
+	if !cmd.noRepo {
+		if err := repo.ChdirRoot(); err != nil {
+			log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+		}
+		var err error
+		gl, err = ilof.OpenGuestList(repo.GuestFile)
+		if err != nil {
+			log.Fatalf("Loading guests: %v", err)
+		}
+	}
+	if err := cmd.run(gl, flag.Args()[1:]); err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
+}
+
+func newFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s %s\n\nOptions:\n",
+			filepath.Base(os.Args[0]), name, commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func runList(gl *ilof.GuestList, args []string) error {
+	fs := newFlags("list")
+	doJSON := fs.Bool("json", false, "Write the guest list as JSON")
+	fs.Parse(args)
+	if *doJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(gl.Guests)
+	}
+	for _, g := range gl.Guests {
+		fmt.Println(g)
+	}
+	return nil
+}
+
+func runShow(gl *ilof.GuestList, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: show %s", commands["show"].usage)
+	}
+	g := gl.Find(args[0])
+	if g == nil {
+		return fmt.Errorf("no guest matching %q", args[0])
+	}
+	fmt.Printf("Name:     %s\n", g.Name)
+	if g.Twitter != "" {
+		fmt.Printf("Twitter:  @%s\n", ilof.NormalizeHandle(g.Twitter))
+	}
+	if g.TwitterID != "" {
+		fmt.Printf("User ID:  %s\n", g.TwitterID)
+	}
+	if g.URL != "" {
+		fmt.Printf("URL:      %s\n", g.URL)
+	}
+	if g.Notes != "" {
+		fmt.Printf("Notes:    %s\n", g.Notes)
+	}
+
+	eps := make(map[ilof.Label]*ilof.Episode)
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		if g.OnEpisode(ep.Episode) {
+			eps[ep.Episode] = ep
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("Episodes: %d\n", len(g.Episodes))
+	for _, v := range g.Episodes {
+		if ep, ok := eps[v]; ok {
+			fmt.Printf("  %-6s %s  %s\n", ep.Episode, ep.Date, ep.PageURL())
+		} else {
+			fmt.Printf("  %-6v (no episode file)\n", v)
+		}
+	}
+	return nil
+}
+
+func runEpisodes(_ *ilof.GuestList, args []string) error {
+	fs := newFlags("episodes")
+	doJSON := fs.Bool("json", false, "Write the episodes as JSON")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: episodes %s", commands["episodes"].usage)
+	}
+	eps, err := ilof.EpisodesByGuest(context.Background(), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *doJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			E []*ilof.Episode `json:"episodes"`
+		}{E: eps})
+	}
+	for _, ep := range eps {
+		fmt.Printf("%-6s %s  %s\n", ep.Episode, ep.Date, ep.PageURL())
+	}
+	return nil
+}
+
+// similarNameScore is the minimum name similarity for which add warns that a
+// new guest may duplicate an existing one.
+const similarNameScore = 0.8
+
+func runAdd(gl *ilof.GuestList, args []string) error {
+	fs := newFlags("add")
+	name := fs.String("name", "", "Guest name (required)")
+	twitter := fs.String("twitter", "", "Twitter handle")
+	url := fs.String("url", "", "Guest URL")
+	notes := fs.String("notes", "", "Notes about the guest")
+	epList := fs.String("episodes", "", "Comma-separated episode labels")
+	fs.Parse(args)
+
+	g := &ilof.Guest{
+		Name:    strings.TrimSpace(*name),
+		Twitter: ilof.NormalizeHandle(*twitter),
+		URL:     ilof.NormalizeURL(*url),
+		Notes:   *notes,
+	}
+	for _, s := range strings.Split(*epList, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		g.Episodes = append(g.Episodes, ilof.ParseLabel(s))
+	}
+	for _, old := range gl.Similar(g.Name, similarNameScore) {
+		log.Printf("* Note: %q is similar to existing guest %s", g.Name, old)
+	}
+	if err := gl.Add(g); err != nil {
+		return err
+	}
+	log.Printf("Added guest: %s", g)
+	return gl.Save()
+}
+
+func runMerge(gl *ilof.GuestList, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: merge %s", commands["merge"].usage)
+	}
+	keep, drop := gl.Find(args[0]), gl.Find(args[1])
+	if keep == nil {
+		return fmt.Errorf("no guest matching %q", args[0])
+	} else if drop == nil {
+		return fmt.Errorf("no guest matching %q", args[1])
+	}
+	if err := gl.Merge(keep, drop); err != nil {
+		return err
+	}
+	log.Printf("Merged %q into %s", drop.Name, keep)
+	return gl.Save()
+}
+
+func runFixHandles(gl *ilof.GuestList, args []string) error {
+	fs := newFlags("fix-handles")
+	doDryRun := fs.Bool("dry-run", false, "Report changes without modifying the guest file")
+	fs.Parse(args)
+
+	var changed int
+	for _, g := range gl.Guests {
+		fix := ilof.NormalizeHandle(g.Twitter)
+		if fix == "" && g.URL != "" {
+			if h := ilof.NormalizeHandle(g.URL); h != g.URL {
+				fix = h // the URL is a Twitter profile
+			}
+		}
+		if fix != g.Twitter {
+			fmt.Printf("%s: twitter %q → %q\n", g.Name, g.Twitter, fix)
+			g.Twitter = fix
+			changed++
+		}
+	}
+	log.Printf("Fixed %d handles", changed)
+	if changed == 0 || *doDryRun {
+		return nil
+	}
+	return gl.Save()
+}
+
+func runTwitterIDs(gl *ilof.GuestList, args []string) error {
+	fs := newFlags("twitter-ids")
+	doRefresh := fs.Bool("refresh", false, "Update handles from recorded user IDs")
+	doAll := fs.Bool("all", false, "Look up all handles, not only those without IDs")
+	doDryRun := fs.Bool("dry-run", false, "Report changes without modifying the guest file")
+	fs.Parse(args)
+
+	token, err := ilof.TwitterToken.Value()
+	if err != nil {
+		return err
+	}
+	tokens, err := ilof.NewTokenPool(ilof.SplitTokens(token)...)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	var changed int
+	if *doRefresh {
+		var ids []string
+		for _, g := range gl.Guests {
+			if g.TwitterID != "" {
+				ids = append(ids, g.TwitterID)
+			}
+		}
+		us, err := ilof.LookupTwitterIDs(ctx, tokens, ids)
+		if err != nil {
+			return err
+		}
+		diffs, missing := ilof.RefreshTwitterHandles(gl.Guests, us)
+		for _, d := range diffs {
+			fmt.Printf("%s: twitter %q → %q\n", d.Guest.Name, d.Old, d.New)
+		}
+		for _, g := range missing {
+			log.Printf("* %s: account %s (@%s) was not found", g.Name, g.TwitterID, g.Twitter)
+		}
+		changed = len(diffs)
+		log.Printf("Checked %d user IDs: %d handles changed, %d not found", len(ids), changed, len(missing))
+	} else {
+		var handles []string
+		var want []*ilof.Guest
+		for _, g := range gl.Guests {
+			if g.Twitter != "" && (g.TwitterID == "" || *doAll) {
+				handles = append(handles, g.Twitter)
+				want = append(want, g)
+			}
+		}
+		us, err := ilof.LookupTwitterHandles(ctx, tokens, handles)
+		if err != nil {
+			return err
+		}
+		set := ilof.SetTwitterIDs(want, us)
+		for _, g := range set {
+			fmt.Printf("%s: @%s has user ID %s\n", g.Name, ilof.NormalizeHandle(g.Twitter), g.TwitterID)
+		}
+		changed = len(set)
+		log.Printf("Looked up %d handles: %d IDs recorded, %d not found", len(handles), changed, len(handles)-len(us))
+	}
+	if changed == 0 || *doDryRun {
+		return nil
+	}
+	return gl.Save()
+}
+
+func runBackfill(gl *ilof.GuestList, args []string) error {
+	fs := newFlags("backfill")
+	doDryRun := fs.Bool("dry-run", false, "Report changes without modifying the guest file")
+	fs.Parse(args)
+
+	// Look for the full name of each guest in the text of each episode on
+	// which the guest is not already recorded. Only names of at least two
+	// words are considered, to avoid spurious matches.
+	var changed int
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		text := " " + strings.Join(ilof.Words(ep.Summary+" "+ep.Topics+" "+ep.Detail), " ") + " "
+		for _, g := range gl.Guests {
+			name := ilof.Words(g.Name)
+			if len(name) < 2 || g.OnEpisode(ep.Episode) {
+				continue
+			}
+			if strings.Contains(text, " "+strings.Join(name, " ")+" ") {
+				fmt.Printf("%s: add episode %s\n", g.Name, ep.Episode)
+				g.Episodes = append(g.Episodes, ep.Episode)
+				ilof.SortLabels(g.Episodes)
+				changed++
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	log.Printf("Added %d appearances", changed)
+	if changed == 0 || *doDryRun {
+		return nil
+	}
+	return gl.Save()
+}
+
+func runShard(gl *ilof.GuestList, args []string) error {
+	fs := newFlags("shard")
+	dir := fs.String("dir", strings.TrimSuffix(gl.Path, filepath.Ext(gl.Path)), "Directory for the guest shards")
+	doDryRun := fs.Bool("dry-run", false, "Report the shards without writing them")
+	fs.Parse(args)
+	if gl.Sharded() {
+		return fmt.Errorf("guest list %q is already sharded", gl.Path)
+	} else if _, err := os.Stat(*dir); err == nil {
+		return fmt.Errorf("%q already exists", *dir)
+	}
+
+	counts := make(map[string]int)
+	for _, g := range gl.Guests {
+		counts[ilof.GuestShard(g.Name)]++
+	}
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s: %d guests\n", filepath.Join(*dir, name), counts[name])
+	}
+	if *doDryRun {
+		return nil
+	}
+
+	old := gl.Path
+	if err := gl.SaveAs(*dir, true); err != nil {
+		return err
+	} else if err := os.Remove(old); err != nil {
+		return err
+	} else if err := repo.SetLayoutPath(".", "guests", *dir); err != nil {
+		return err
+	}
+	log.Printf("Split %s into %d shards in %s, and updated %s", old, len(names), *dir, repo.LayoutFile)
+	return nil
+}
+
+func runUnshard(gl *ilof.GuestList, args []string) error {
+	fs := newFlags("unshard")
+	file := fs.String("file", filepath.Clean(gl.Path)+".yaml", "Path of the combined guest file")
+	doDryRun := fs.Bool("dry-run", false, "Report the result without writing it")
+	fs.Parse(args)
+	if !gl.Sharded() {
+		return fmt.Errorf("guest list %q is not sharded", gl.Path)
+	} else if _, err := os.Stat(*file); err == nil {
+		return fmt.Errorf("%q already exists", *file)
+	}
+	shards, err := ilof.GuestFiles(gl.Path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d guests from %d shards\n", *file, len(gl.Guests), len(shards))
+	if *doDryRun {
+		return nil
+	}
+
+	old := gl.Path
+	if err := gl.SaveAs(*file, false); err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if err := os.Remove(shard); err != nil {
+			return err
+		}
+	}
+	os.Remove(old) // OK if other files remain
+	if err := repo.SetLayoutPath(".", "guests", *file); err != nil {
+		return err
+	}
+	log.Printf("Combined %d shards from %s into %s, and updated %s", len(shards), old, *file, repo.LayoutFile)
+	return nil
+}
@@ -0,0 +1,171 @@
+// Program ical generates an iCalendar (.ics) feed of upcoming episodes of the
+// show, and optionally past episodes, from the site repository.
+//
+// Upcoming shows are projected from the broadcast schedule in the repository
+// (or the default schedule, if none is defined). If a YOUTUBE_API_KEY is set
+// and a -channel is given, scheduled live broadcasts on the channel are also
+// included, and take the place of projected shows on the same day.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	numUpcoming = flag.Int("upcoming", 6, "Number of scheduled shows to include")
+	doPast      = flag.Bool("past", false, "Include past episodes")
+	channelID   = flag.String("channel", "", "YouTube channel ID to check for scheduled broadcasts")
+	outPath     = flag.String("o", "", "Write output to this file (default stdout)")
+)
+
+// An event is a single calendar entry.
+type event struct {
+	UID      string
+	Start    time.Time
+	Duration time.Duration
+	Summary  string
+	Desc     string
+	URL      string
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	sched, err := ilof.LoadSchedule(repo.ScheduleFile)
+	if err != nil {
+		log.Fatalf("Loading schedule: %v", err)
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	var events []*event
+
+	// Scheduled broadcasts, if we can find them, take priority over projected
+	// dates from the schedule.
+	booked := make(map[string]bool)
+	if apiKey := ilof.YouTubeAPIKey.Get(); apiKey != "" && *channelID != "" {
+		bs, err := ilof.YouTubeUpcoming(ctx, *channelID, apiKey)
+		if err != nil {
+			log.Fatalf("Finding upcoming broadcasts: %v", err)
+		}
+		log.Printf("Found %d upcoming broadcasts", len(bs))
+		for _, b := range bs {
+			events = append(events, &event{
+				UID:      "yt-" + b.ID + "@inlieuof.fun",
+				Start:    b.Start,
+				Duration: sched.Length(),
+				Summary:  b.Title,
+				URL:      b.URL(),
+			})
+			booked[b.Start.In(sched.Location()).Format("2006-01-02")] = true
+		}
+	}
+	for _, start := range sched.Upcoming(now, *numUpcoming) {
+		day := start.Format("2006-01-02")
+		if booked[day] {
+			continue
+		}
+		events = append(events, &event{
+			UID:      "sched-" + day + "@inlieuof.fun",
+			Start:    start,
+			Duration: sched.Length(),
+			Summary:  "In Lieu of Fun",
+			URL:      ilof.BaseURL,
+		})
+	}
+
+	if *doPast {
+		if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+			start := sched.StartOn(ep.Date)
+			if start.After(now) {
+				return nil
+			}
+			ev := &event{
+				UID:      "ep-" + string(ep.Episode) + "@inlieuof.fun",
+				Start:    start,
+				Duration: sched.Length(),
+				Summary:  fmt.Sprintf("In Lieu of Fun, Episode %s", ep.Episode),
+				Desc:     ep.Summary,
+				URL:      ep.PageURL(),
+			}
+			if d, err := time.ParseDuration(ep.Duration); err == nil && d > 0 {
+				ev.Duration = d
+			}
+			events = append(events, ev)
+			return nil
+		}); err != nil {
+			log.Fatalf("Loading episodes: %v", err)
+		}
+	}
+	log.Printf("Writing %d calendar events", len(events))
+
+	out := io.Writer(os.Stdout)
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatalf("Creating output: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := writeCalendar(out, events, now); err != nil {
+		log.Fatalf("Writing calendar: %v", err)
+	}
+}
+
+// writeCalendar writes events to w as an iCalendar (RFC 5545) document.
+func writeCalendar(w io.Writer, events []*event, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(s string) {
+		// Fold lines longer than 75 octets, per RFC 5545 section 3.1.
+		for len(s) > 75 {
+			cut := 75
+			for cut > 0 && s[cut]&0xC0 == 0x80 {
+				cut-- // do not split a UTF-8 sequence
+			}
+			bw.WriteString(s[:cut] + "\r\n")
+			s = " " + s[cut:]
+		}
+		bw.WriteString(s + "\r\n")
+	}
+	stamp := func(t time.Time) string { return t.UTC().Format("20060102T150405Z") }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//inlieuof.fun//ILoF Tools//EN")
+	line("X-WR-CALNAME:In Lieu of Fun")
+	for _, ev := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + ev.UID)
+		line("DTSTAMP:" + stamp(now))
+		line("DTSTART:" + stamp(ev.Start))
+		line("DTEND:" + stamp(ev.Start.Add(ev.Duration)))
+		line("SUMMARY:" + escapeText(ev.Summary))
+		if ev.Desc != "" {
+			line("DESCRIPTION:" + escapeText(ev.Desc))
+		}
+		if ev.URL != "" {
+			line("URL:" + ev.URL)
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escapeText(s string) string { return textEscaper.Replace(s) }
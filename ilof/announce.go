@@ -0,0 +1,78 @@
+package ilof
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/creachadair/twitter/types"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+// An Announcement is the archived record of the post that announced an
+// episode. The post is kept as the platform reported it, so that the origin
+// of the episode data can be checked even if the post, the account, or the
+// platform API goes away.
+type Announcement struct {
+	Episode  Label     `json:"episode"`
+	Platform string    `json:"platform"` // e.g., "twitter"
+	URL      string    `json:"url"`      // the address of the post
+	Archived time.Time `json:"archived"` // when the post was archived
+
+	Post      *types.Tweet  `json:"post"`
+	Author    *types.User   `json:"author,omitempty"`
+	Mentioned []*types.User `json:"mentioned,omitempty"`
+}
+
+// Announcement returns an archive record of the announcement of up for the
+// given episode, or nil if up does not include the post.
+func (up *TwitterUpdate) Announcement(ep Label) *Announcement {
+	if up.Post == nil {
+		return nil
+	}
+	user := "i/web"
+	if up.Author != nil && up.Author.Username != "" {
+		user = up.Author.Username
+	}
+	return &Announcement{
+		Episode:   ep,
+		Platform:  "twitter",
+		URL:       fmt.Sprintf("https://twitter.com/%s/status/%s", user, up.Post.ID),
+		Archived:  time.Now().UTC().Truncate(time.Second),
+		Post:      up.Post,
+		Author:    up.Author,
+		Mentioned: up.Mentioned,
+	}
+}
+
+// AnnouncementPath returns the path of the archived announcement of the given
+// episode in dir.
+func AnnouncementPath(dir string, ep Label) string {
+	return filepath.Join(dir, string(ep)+".json")
+}
+
+// WriteAnnouncementIn writes a to its path in dir through wt, replacing any
+// previous record for the same episode, and returns the path.
+func WriteAnnouncementIn(wt repo.Worktree, dir string, a *Announcement) (string, error) {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := AnnouncementPath(dir, a.Episode)
+	return path, wt.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// LoadAnnouncement loads an archived announcement from the file at path.
+func LoadAnnouncement(path string) (*Announcement, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	a := new(Announcement)
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return a, nil
+}
@@ -0,0 +1,122 @@
+package ilof
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// A SeasonArchive gathers the episodes of one season, for an archive page.
+type SeasonArchive struct {
+	Season   int
+	Title    string     // the title of the season, if it has one
+	Episodes []*Episode // in order of label
+	Guests   map[Label][]string
+	Stats    *Stats
+}
+
+// SeasonArchives groups eps by season, and returns an archive for each
+// season in order. An episode with no season is assigned to the season of
+// its air date according to seasons; episodes that still have no season are
+// omitted. At most topN guests and tags are reported in the statistics of
+// each season; if topN <= 0 all are reported.
+func SeasonArchives(eps []*Episode, guests []*Guest, seasons Seasons, topN int) []*SeasonArchive {
+	bySeason := make(map[int][]*Episode)
+	for _, ep := range eps {
+		num := ep.Season
+		if num == 0 {
+			num = seasons.SeasonOf(ep.Date)
+		}
+		if num > 0 {
+			bySeason[num] = append(bySeason[num], ep)
+		}
+	}
+	titles := make(map[int]string)
+	for _, s := range seasons {
+		titles[s.Season] = s.Title
+	}
+	gidx := GuestIndex(guests)
+
+	var out []*SeasonArchive
+	for num, seps := range bySeason {
+		sort.SliceStable(seps, func(i, j int) bool {
+			return labelLess(seps[i].Episode, seps[j].Episode)
+		})
+		a := &SeasonArchive{
+			Season:   num,
+			Title:    titles[num],
+			Episodes: seps,
+			Guests:   make(map[Label][]string),
+			Stats:    ComputeStats(seps, guests, topN),
+		}
+		if topN > 0 && len(a.Stats.Tags) > topN {
+			a.Stats.Tags = a.Stats.Tags[:topN]
+		}
+		for _, ep := range seps {
+			for _, g := range gidx[ep.Episode] {
+				a.Guests[ep.Episode] = append(a.Guests[ep.Episode], g.Name)
+			}
+		}
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Season < out[j].Season })
+	return out
+}
+
+// PageTitle returns the title of the archive page for a, e.g., "Season 2" or
+// "Season 2: Electric Boogaloo".
+func (a *SeasonArchive) PageTitle() string {
+	if a.Title != "" {
+		return fmt.Sprintf("Season %d: %s", a.Season, a.Title)
+	}
+	return fmt.Sprintf("Season %d", a.Season)
+}
+
+// ArchiveMarker is a front matter field that marks a page written by
+// SeasonArchive.Markdown, so that generated pages can be told apart from
+// pages written by hand.
+const ArchiveMarker = "generated: season-archive"
+
+// Markdown renders a as a markdown page with Jekyll front matter. The page
+// has the permalink /season/N/ and the given layout.
+func (a *SeasonArchive) Markdown(layout string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := archiveTemplate.Execute(&buf, struct {
+		*SeasonArchive
+		Layout, Marker string
+	}{a, layout, ArchiveMarker})
+	return buf.Bytes(), err
+}
+
+// markdownCell escapes s for use in a cell of a markdown table.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(s), " "), "|", `\|`)
+}
+
+var archiveTemplate = template.Must(template.New("archive").Funcs(template.FuncMap{
+	"cell":  markdownCell,
+	"join":  func(ss []string) string { return strings.Join(ss, ", ") },
+	"hours": func(h float64) string { return fmt.Sprintf("%.1f", h) },
+	"quote": func(s string) string { return fmt.Sprintf("%q", s) },
+}).Parse(`---
+layout: {{.Layout}}
+title: {{quote .PageTitle}}
+permalink: /season/{{.Season}}/
+{{.Marker}}
+---
+{{with .Stats -}}
+From {{.First}} to {{.Last}} there were **{{.Episodes}} episodes**
+({{.Specials}} specials), with {{.GuestVisits}} appearances by {{.NumGuests}} guests.
+{{- if .NumTimed}} The {{.NumTimed}} episodes with a recorded duration
+total **{{hours .Hours}} hours**.{{end}}
+{{if .Tags}}
+Notable tags: {{range $i, $t := .Tags}}{{if $i}}, {{end}}{{$t.Name}} ({{$t.Count}}){{end}}.
+{{end}}{{if .TopGuests}}
+Most frequent guests: {{range $i, $g := .TopGuests}}{{if $i}}, {{end}}{{$g.Name}} ({{$g.Count}}){{end}}.
+{{end}}{{end}}
+| Episode | Date | Guests | Topics | Tags |
+|---|---|---|---|---|
+{{range .Episodes}}| [{{.Episode}}](/episode/{{.Episode}}) | {{.Date}} | {{cell (join (index $.Guests .Episode))}} | {{cell .Topics}} | {{join .AllTags}} |
+{{end}}`))
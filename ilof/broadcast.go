@@ -0,0 +1,226 @@
+package ilof
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Broadcast describes a scheduled live broadcast on YouTube.
+type Broadcast struct {
+	ID    string    `json:"id"`
+	Title string    `json:"title"`
+	Start time.Time `json:"scheduledStartTime"`
+}
+
+// URL returns the watch URL for the broadcast.
+func (b *Broadcast) URL() string { return youTubeWatchURL(b.ID) }
+
+// YouTubeUpcoming returns the upcoming live broadcasts scheduled on the
+// specified YouTube channel, in order of scheduled start time.
+func YouTubeUpcoming(ctx context.Context, channelID, apiKey string) ([]*Broadcast, error) {
+	q := make(url.Values)
+	q.Set("channelId", channelID)
+	q.Set("eventType", "upcoming")
+	q.Set("type", "video")
+	q.Set("part", "id")
+	q.Set("maxResults", "25")
+	q.Set("key", apiKey)
+	var search struct {
+		Items []struct {
+			ID struct {
+				VideoID string `json:"videoId"`
+			} `json:"id"`
+		} `json:"items"`
+	}
+	if err := youTubeAPI(ctx, "search", q, &search); err != nil {
+		return nil, err
+	}
+	if len(search.Items) == 0 {
+		return nil, nil
+	}
+
+	var ids []string
+	for _, item := range search.Items {
+		ids = append(ids, item.ID.VideoID)
+	}
+	q = make(url.Values)
+	q.Set("id", strings.Join(ids, ","))
+	q.Set("part", "snippet,liveStreamingDetails")
+	q.Set("key", apiKey)
+	var videos struct {
+		Items []struct {
+			ID      string `json:"id"`
+			Snippet struct {
+				Title string `json:"title"`
+			} `json:"snippet"`
+			Live struct {
+				Start time.Time `json:"scheduledStartTime"`
+			} `json:"liveStreamingDetails"`
+		} `json:"items"`
+	}
+	if err := youTubeAPI(ctx, "videos", q, &videos); err != nil {
+		return nil, err
+	}
+	var out []*Broadcast
+	for _, item := range videos.Items {
+		out = append(out, &Broadcast{ID: item.ID, Title: item.Snippet.Title, Start: item.Live.Start})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Start.Before(out[j].Start)
+	})
+	return out, nil
+}
+
+// youTubeAPI calls the specified method of the YouTube Data API with the given
+// query parameters, and decodes the JSON response into v.
+func youTubeAPI(ctx context.Context, method string, q url.Values, v interface{}) error {
+	u := "https://www.googleapis.com/youtube/v3/" + method + "?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Add("Accept", "application/json")
+	bits, err := loadRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bits, v)
+}
+
+// VideoTimes records when a YouTube video was broadcast and published.
+type VideoTimes struct {
+	ID          string    `json:"id"`
+	Published   time.Time `json:"publishedAt"`
+	Scheduled   time.Time `json:"scheduledStartTime"` // zero if not a live broadcast
+	ActualStart time.Time `json:"actualStartTime"`    // zero if not a live broadcast
+}
+
+// AirTime returns the best available estimate of when the video aired: the
+// actual start of its live broadcast if known, otherwise its scheduled start,
+// otherwise the time it was published.
+func (v *VideoTimes) AirTime() time.Time {
+	if !v.ActualStart.IsZero() {
+		return v.ActualStart
+	} else if !v.Scheduled.IsZero() {
+		return v.Scheduled
+	}
+	return v.Published
+}
+
+// maxVideoIDs is the maximum number of video IDs per request to the videos
+// method of the YouTube Data API.
+const maxVideoIDs = 50
+
+// YouTubeVideoTimes returns the broadcast and publish times of the specified
+// YouTube video IDs, keyed by ID. Videos that are not found are omitted.
+func YouTubeVideoTimes(ctx context.Context, ids []string, apiKey string) (map[string]*VideoTimes, error) {
+	out := make(map[string]*VideoTimes)
+	for len(ids) != 0 {
+		batch := ids[:min(len(ids), maxVideoIDs)]
+		ids = ids[len(batch):]
+
+		q := make(url.Values)
+		q.Set("id", strings.Join(batch, ","))
+		q.Set("part", "snippet,liveStreamingDetails")
+		q.Set("key", apiKey)
+		var videos struct {
+			Items []struct {
+				ID      string `json:"id"`
+				Snippet struct {
+					Published time.Time `json:"publishedAt"`
+				} `json:"snippet"`
+				Live struct {
+					Scheduled   time.Time `json:"scheduledStartTime"`
+					ActualStart time.Time `json:"actualStartTime"`
+				} `json:"liveStreamingDetails"`
+			} `json:"items"`
+		}
+		if err := youTubeAPI(ctx, "videos", q, &videos); err != nil {
+			return nil, err
+		}
+		for _, item := range videos.Items {
+			out[item.ID] = &VideoTimes{
+				ID:          item.ID,
+				Published:   item.Snippet.Published,
+				Scheduled:   item.Live.Scheduled,
+				ActualStart: item.Live.ActualStart,
+			}
+		}
+	}
+	return out, nil
+}
+
+// YouTubeChannelVideos returns all the videos uploaded to the specified
+// YouTube channel, including past live broadcasts, most recent first. The
+// descriptions of the videos may be abbreviated.
+func YouTubeChannelVideos(ctx context.Context, channelID, apiKey string) ([]*VideoInfo, error) {
+	q := make(url.Values)
+	q.Set("id", channelID)
+	q.Set("part", "contentDetails")
+	q.Set("key", apiKey)
+	var channels struct {
+		Items []struct {
+			Details struct {
+				Playlists struct {
+					Uploads string `json:"uploads"`
+				} `json:"relatedPlaylists"`
+			} `json:"contentDetails"`
+		} `json:"items"`
+	}
+	if err := youTubeAPI(ctx, "channels", q, &channels); err != nil {
+		return nil, err
+	} else if len(channels.Items) == 0 || channels.Items[0].Details.Playlists.Uploads == "" {
+		return nil, fmt.Errorf("channel %q not found", channelID)
+	}
+
+	var out []*VideoInfo
+	var page string
+	for {
+		q := make(url.Values)
+		q.Set("playlistId", channels.Items[0].Details.Playlists.Uploads)
+		q.Set("part", "snippet,contentDetails")
+		q.Set("maxResults", "50")
+		q.Set("key", apiKey)
+		if page != "" {
+			q.Set("pageToken", page)
+		}
+		var items struct {
+			Items []struct {
+				Snippet *VideoInfo `json:"snippet"`
+				Details struct {
+					VideoID   string    `json:"videoId"`
+					Published time.Time `json:"videoPublishedAt"`
+				} `json:"contentDetails"`
+			} `json:"items"`
+			Next string `json:"nextPageToken"`
+		}
+		if err := youTubeAPI(ctx, "playlistItems", q, &items); err != nil {
+			return nil, err
+		}
+		for _, item := range items.Items {
+			v := item.Snippet
+			if v == nil || item.Details.VideoID == "" {
+				continue
+			}
+			v.ID = item.Details.VideoID
+			if !item.Details.Published.IsZero() {
+				v.PublishedAt = item.Details.Published
+			}
+			out = append(out, v)
+		}
+		if items.Next == "" {
+			break
+		}
+		page = items.Next
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].PublishedAt.After(out[j].PublishedAt)
+	})
+	return out, nil
+}
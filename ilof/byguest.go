@@ -0,0 +1,161 @@
+package ilof
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrGuestNotFound is reported by EpisodesByGuest when no guest matches.
+var ErrGuestNotFound = errors.New("guest not found")
+
+// AllGuests queries the site for the guest list. If the site does not serve
+// one, the guests are instead derived from the guest names of the episodes,
+// as for GuestsFromEpisodes; such guests have only names and episodes.
+func AllGuests(ctx context.Context) ([]*Guest, error) {
+	body, err := fetchSite(ctx, BaseURL+"/guests.json")
+	var serr *StatusError
+	if errors.As(err, &serr) && serr.Code == http.StatusNotFound {
+		eps, err := AllEpisodes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return GuestsFromEpisodes(eps), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return DecodeGuests(bytes.NewReader(body))
+}
+
+// GuestsFromEpisodes returns a guest for each distinct guest name (ignoring
+// case) of eps, as published by the site, with the episodes on which that
+// name appears. The guests are in order of first appearance in eps.
+func GuestsFromEpisodes(eps []*Episode) []*Guest {
+	var out []*Guest
+	byName := make(map[string]*Guest)
+	for _, ep := range eps {
+		for _, name := range ep.Guests {
+			key := strings.ToLower(name)
+			g := byName[key]
+			if g == nil {
+				g = &Guest{Name: name}
+				byName[key] = g
+				out = append(out, g)
+			}
+			if !g.OnEpisode(ep.Episode) {
+				g.Episodes = append(g.Episodes, ep.Episode)
+			}
+		}
+	}
+	return out
+}
+
+// DecodeGuests decodes a guest list in the format of the site's guests.json
+// endpoint from r.
+func DecodeGuests(r io.Reader) ([]*Guest, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var gs struct {
+		Guests []*Guest `json:"guests"`
+	}
+	if err := json.Unmarshal(body, &gs); err != nil {
+		return nil, err
+	}
+	return gs.Guests, nil
+}
+
+// EpisodesByGuest queries the site for the episodes featuring the guest whose
+// name (ignoring case) or Twitter handle matches handle, in order of air date.
+// It reports ErrGuestNotFound if there is no such guest. See GuestEpisodes.
+func EpisodesByGuest(ctx context.Context, handle string) ([]*Episode, error) {
+	guests, err := AllGuests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	eps, err := AllEpisodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g, out := GuestEpisodes(guests, eps, handle)
+	if g == nil {
+		return nil, ErrGuestNotFound
+	}
+	return out, nil
+}
+
+// LookupGuest returns the guest of gs whose name (ignoring case) or Twitter
+// handle matches key, or nil if there is no such guest. A handle may be given
+// with or without a leading "@". Names take precedence over handles.
+func LookupGuest(gs []*Guest, key string) *Guest {
+	for _, g := range gs {
+		if strings.EqualFold(g.Name, key) {
+			return g
+		}
+	}
+	handle := NormalizeHandle(key)
+	for _, g := range gs {
+		if g.Twitter != "" && strings.EqualFold(NormalizeHandle(g.Twitter), handle) {
+			return g
+		}
+	}
+	return nil
+}
+
+// GuestEpisodes returns the guest of guests matching key, as for LookupGuest,
+// and the episodes of eps featuring that guest, in order of air date.
+//
+// An episode features the guest if it is listed among the guest's episodes,
+// or if the guest's name is among the guest names of the episode, as
+// published by the site. If no guest in guests matches key but key is one of
+// those names, the guest returned has only a name.
+func GuestEpisodes(guests []*Guest, eps []*Episode, key string) (*Guest, []*Episode) {
+	g := LookupGuest(guests, key)
+	if g == nil {
+		for _, ep := range eps {
+			for _, name := range ep.Guests {
+				if strings.EqualFold(name, key) {
+					g = &Guest{Name: name}
+					break
+				}
+			}
+			if g != nil {
+				break
+			}
+		}
+		if g == nil {
+			return nil, nil
+		}
+	}
+
+	var out []*Episode
+	for _, ep := range eps {
+		if g.OnEpisode(ep.Episode) || hasGuestName(ep, g.Name) {
+			out = append(out, ep)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		di, dj := time.Time(out[i].Date), time.Time(out[j].Date)
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return labelLess(out[i].Episode, out[j].Episode)
+	})
+	return g, out
+}
+
+func hasGuestName(ep *Episode, name string) bool {
+	for _, s := range ep.Guests {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+	return false
+}
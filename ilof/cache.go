@@ -0,0 +1,172 @@
+package ilof
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/creachadair/atomicfile"
+)
+
+// A ResponseCache stores the bodies of HTTP responses keyed by URL.
+type ResponseCache interface {
+	// Get returns the cached data for url, if any.
+	Get(url string) ([]byte, bool)
+
+	// Put stores data as the response for url.
+	Put(url string, data []byte)
+}
+
+// CaptionCache, if non-nil, is used to cache the caption data fetched by
+// YouTubeCaptionData. Only responses that parse successfully are cached, so
+// that error pages are not retained. Watch pages and player responses are not
+// cached, since the caption URLs they list are signed and expire within hours.
+// Captions are cached under their URL without the signing parameters, so a
+// caption track fetched by one run is found by the next, although the URL
+// that run finds for it is signed anew.
+var CaptionCache ResponseCache
+
+// captionSigningParams are the query parameters of a caption URL that change
+// each time YouTube signs it, and do not affect which captions it serves.
+var captionSigningParams = []string{"expire", "signature", "sig", "sparams", "ei", "key", "ip", "ipbits"}
+
+// captionCacheKey returns the key under which the captions at the given URL
+// are cached: the URL without its signing parameters, with the remaining
+// parameters in a fixed order.
+func captionCacheKey(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	q := u.Query()
+	for _, p := range captionSigningParams {
+		q.Del(p)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// A DirCache is a ResponseCache that stores responses as files in a directory.
+// Errors reading or writing the cache are treated as cache misses.
+type DirCache string
+
+func (c DirCache) path(url string) string {
+	h := sha256.Sum256([]byte(url))
+	return filepath.Join(string(c), hex.EncodeToString(h[:]))
+}
+
+// Get implements a method of the ResponseCache interface.
+func (c DirCache) Get(url string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(url))
+	return data, err == nil
+}
+
+// Put implements a method of the ResponseCache interface.
+func (c DirCache) Put(url string, data []byte) {
+	if os.MkdirAll(string(c), 0755) == nil {
+		atomicfile.WriteData(c.path(url), data, 0644)
+	}
+}
+
+func cacheGet(captionURL string) ([]byte, bool) {
+	if CaptionCache == nil {
+		return nil, false
+	}
+	return CaptionCache.Get(captionCacheKey(captionURL))
+}
+
+func cachePut(captionURL string, data []byte) {
+	if CaptionCache != nil {
+		CaptionCache.Put(captionCacheKey(captionURL), data)
+	}
+}
+
+// SiteCache, if non-nil, memoizes the responses fetched from the site by
+// LatestEpisode, FetchEpisode, AllEpisodes, and AllGuests, so that the tools
+// running in one process share one fetch of each URL. It is nil by default,
+// so that every call sees the current site; a program that fetches the same
+// data repeatedly may set it, and if it runs for a long time, should call its
+// Reset method when it wants fresh data.
+var SiteCache *MemoCache
+
+// A MemoCache memoizes fetched data in memory, keyed by URL. Concurrent calls
+// for the same URL share a single fetch, and later calls reuse its result.
+// Failed fetches are not retained. A zero MemoCache is ready for use, and is
+// safe for concurrent use by multiple goroutines.
+type MemoCache struct {
+	mu      sync.Mutex
+	entries map[string]*memoEntry
+}
+
+type memoEntry struct {
+	ready chan struct{} // closed when the fetch is complete
+	data  []byte
+	err   error
+}
+
+// Fetch returns the data for url. If url is not cached and no fetch of it is
+// in progress, Fetch calls fetch to obtain it; otherwise it waits for the
+// result of the fetch already made. If the caller that started the fetch
+// gives up because its context ended, waiting callers whose own contexts are
+// still live try again. The data returned are shared, and must not be
+// modified by the caller.
+func (c *MemoCache) Fetch(ctx context.Context, url string, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	for {
+		c.mu.Lock()
+		if c.entries == nil {
+			c.entries = make(map[string]*memoEntry)
+		}
+		e, ok := c.entries[url]
+		if !ok {
+			e = &memoEntry{ready: make(chan struct{})}
+			c.entries[url] = e
+			c.mu.Unlock()
+
+			e.data, e.err = fetch(ctx)
+			if e.err != nil {
+				c.mu.Lock()
+				if c.entries[url] == e {
+					delete(c.entries, url)
+				}
+				c.mu.Unlock()
+			}
+			close(e.ready)
+			return e.data, e.err
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-e.ready:
+		}
+		if e.err != nil && ctx.Err() == nil &&
+			(errors.Is(e.err, context.Canceled) || errors.Is(e.err, context.DeadlineExceeded)) {
+			continue // the fetching caller gave up; try again
+		}
+		return e.data, e.err
+	}
+}
+
+// Reset discards all the data cached by c. Fetches in progress are not
+// affected, but their results are not retained.
+func (c *MemoCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// fetchSite fetches url, using SiteCache if it is set.
+func fetchSite(ctx context.Context, url string) ([]byte, error) {
+	if SiteCache == nil {
+		return fetchURL(ctx, url)
+	}
+	return SiteCache.Fetch(ctx, url, func(ctx context.Context) ([]byte, error) {
+		return fetchURL(ctx, url)
+	})
+}
@@ -8,14 +8,19 @@ import (
 	"errors"
 	"fmt"
 	"html"
+	"io"
 	"net/http"
+	"strings"
 )
 
 // youTubeWatchBase is the base URL for the "watch" page for a video ID.
 const youTubeWatchBase = `https://www.youtube.com/watch?v=%s`
 
+// loadWatchPage fetches the watch page for a video ID. Watch pages are not
+// cached, since the caption URLs they list are signed and soon expire.
 func loadWatchPage(ctx context.Context, id string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(youTubeWatchBase, id), nil)
+	url := fmt.Sprintf(youTubeWatchBase, id)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -24,59 +29,247 @@ func loadWatchPage(ctx context.Context, id string) ([]byte, error) {
 
 // YouTubeCaptionURL returns the URL of the captions for the specified video
 // ID.  It returns "" without error if the video exists but lacks captions.
+// If multiple caption tracks are available, English is preferred.
 func YouTubeCaptionURL(ctx context.Context, id string) (string, error) {
-	bits, err := loadWatchPage(ctx, id)
+	return YouTubeSelectCaption(ctx, id, nil)
+}
+
+// YouTubeSelectCaption returns the URL of the captions for the specified video
+// ID chosen according to opts. A nil opts is equivalent to the zero value.
+// It returns "" without error if the video has no matching captions.
+func YouTubeSelectCaption(ctx context.Context, id string, opts *CaptionOptions) (string, error) {
+	tracks, err := YouTubeCaptionTracks(ctx, id)
 	if err != nil {
 		return "", err
 	}
+	if t := opts.Select(tracks); t != nil {
+		return t.URL, nil
+	}
+	return "", nil
+}
+
+// YouTubeCaptionTracks returns the caption tracks available for the specified
+// video ID. It returns an empty slice without error if the video exists but
+// lacks captions.
+//
+// The caption tracks are read from the player response embedded in the watch
+// page of the video. The layout of that page changes from time to time, so if
+// it does not yield any caption tracks, the same player response is requested
+// from the InnerTube player endpoint instead.
+func YouTubeCaptionTracks(ctx context.Context, id string) ([]*CaptionTrack, error) {
+	tracks, err := watchPageCaptionTracks(ctx, id)
+	if err == nil && len(tracks) != 0 {
+		return tracks, nil
+	}
+	ptracks, perr := YouTubePlayerCaptionTracks(ctx, id)
+	if perr != nil {
+		return tracks, err // report the result from the watch page
+	}
+	return ptracks, nil
+}
+
+// watchPageCaptionTracks returns the caption tracks listed in the watch page
+// of the specified video ID.
+func watchPageCaptionTracks(ctx context.Context, id string) ([]*CaptionTrack, error) {
+	bits, err := loadWatchPage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 	const needle = `"captions":`
 	i := bytes.Index(bits, []byte(needle))
 	if i < 0 {
 		if bytes.Contains(bits, []byte(`class="g-recaptcha"`)) {
-			return "", errors.New("rate limit exceeded")
+			return nil, errors.New("rate limit exceeded")
 		} else if !bytes.Contains(bits, []byte(`playabilityStatus`)) {
-			return "", fmt.Errorf("video ID %q not found", id)
+			return nil, fmt.Errorf("video ID %q not found", id)
 		}
-		return "", nil
-	}
-
-	var data struct {
-		R *struct {
-			C []*captionInfo `json:"captionTracks"`
-		} `json:"playerCaptionsTracklistRenderer"`
+		return nil, nil
 	}
 
 	// Decode the JSON blob. Use a json.Decoder so that the garbage in the file
 	// after the blob we're interested in can be ignored.
+	var data playerCaptions
 	dec := json.NewDecoder(bytes.NewReader(bits[i+len(needle):]))
 	if err := dec.Decode(&data); err != nil {
-		return "", err
+		return nil, err
 	}
+	return data.tracks(), nil
+}
+
+// playerCaptions is the "captions" field of a YouTube player response.
+type playerCaptions struct {
+	R *struct {
+		C []*CaptionTrack `json:"captionTracks"`
+	} `json:"playerCaptionsTracklistRenderer"`
+}
 
-	if data.R == nil && len(data.R.C) == 0 {
-		return "", nil
+func (p *playerCaptions) tracks() []*CaptionTrack {
+	if p == nil || p.R == nil {
+		return nil
 	}
+	return p.R.C
+}
 
-	// Look for an English transcription, if available.
-	for _, info := range data.R.C {
-		if info.Lang == "en" {
-			return info.URL, nil
-		}
+// youTubePlayerURL is the URL of the InnerTube player endpoint, which serves
+// the player response that the watch page embeds.
+const youTubePlayerURL = `https://www.youtube.com/youtubei/v1/player?prettyPrint=false`
+
+// innerTubeClientName is the InnerTube client name sent with player requests.
+const innerTubeClientName = "WEB"
+
+// InnerTubeClientVersion is the InnerTube client version sent with player
+// requests. The endpoint rejects requests from clients it does not recognize,
+// so this must match a recent release of the web player; programs may update
+// it when YouTube stops accepting the default.
+var InnerTubeClientVersion = "2.20240726.00.00"
+
+// YouTubePlayerCaptionTracks returns the caption tracks available for the
+// specified video ID, as reported by the InnerTube player endpoint. It returns
+// an empty slice without error if the video exists but lacks captions.
+// Ordinarily YouTubeCaptionTracks should be used instead; it calls this
+// function if the watch page does not list caption tracks.
+//
+// Player responses are not cached, since the caption URLs they list are
+// signed and soon expire.
+func YouTubePlayerCaptionTracks(ctx context.Context, id string) ([]*CaptionTrack, error) {
+	type client struct {
+		Name    string `json:"clientName"`
+		Version string `json:"clientVersion"`
+		Lang    string `json:"hl"`
+	}
+	var reqBody struct {
+		Context struct {
+			Client client `json:"client"`
+		} `json:"context"`
+		VideoID string `json:"videoId"`
+	}
+	reqBody.Context.Client = client{Name: innerTubeClientName, Version: InnerTubeClientVersion, Lang: "en"}
+	reqBody.VideoID = id
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", youTubePlayerURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	bits, err := loadRequest(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("player request: %w", err)
+	}
+	tracks, err := DecodePlayerCaptions(bytes.NewReader(bits))
+	if err != nil {
+		return nil, fmt.Errorf("video ID %q: %w", id, err)
 	}
+	return tracks, nil
+}
 
-	// If we don't find English specifically, just take the first one.
-	return data.R.C[0].URL, nil
+// DecodePlayerCaptions decodes a YouTube player response from r, as served by
+// the InnerTube player endpoint, and returns the caption tracks it lists. It
+// reports an error if the response says the video is missing or cannot be
+// played.
+func DecodePlayerCaptions(r io.Reader) ([]*CaptionTrack, error) {
+	var rsp struct {
+		Status struct {
+			Status string `json:"status"`
+			Reason string `json:"reason"`
+		} `json:"playabilityStatus"`
+		Captions *playerCaptions `json:"captions"`
+	}
+	if err := json.NewDecoder(r).Decode(&rsp); err != nil {
+		return nil, fmt.Errorf("decoding player response: %w", err)
+	}
+	switch rsp.Status.Status {
+	case "OK":
+		return rsp.Captions.tracks(), nil
+	case "ERROR":
+		return nil, errors.New("video not found")
+	case "":
+		return nil, errors.New("invalid player response: missing playability status")
+	default:
+		return nil, fmt.Errorf("video is not playable: %s (%s)", rsp.Status.Status, rsp.Status.Reason)
+	}
 }
 
-type captionInfo struct {
+// A CaptionTrack describes a caption track available for a video.
+type CaptionTrack struct {
 	URL  string `json:"baseUrl"`
 	Lang string `json:"languageCode"`
-	Kind string `json:"kind"`
+	Kind string `json:"kind,omitempty"` // "asr" for automatic captions
+	Name struct {
+		Text string `json:"simpleText"`
+	} `json:"name"`
 
 	// other fields ignored
 }
 
+// IsAutomatic reports whether t is an automatically-generated caption track.
+func (t *CaptionTrack) IsAutomatic() bool { return t.Kind == "asr" }
+
+// CaptionOptions control the selection of a caption track.
+type CaptionOptions struct {
+	// If set, select only a track in this language. A language without a
+	// region subtag (e.g., "en") also matches regional variants ("en-GB").
+	// If empty, English is preferred but any language is accepted.
+	Lang string
+
+	// If set, select only a track of this kind: "asr" selects automatic
+	// captions, "standard" selects captions that are not automatic.
+	Kind string
+}
+
+// Select returns the first track in tracks matching o, or nil if none match.
+// A nil *CaptionOptions is equivalent to the zero value.
+func (o *CaptionOptions) Select(tracks []*CaptionTrack) *CaptionTrack {
+	var lang, kind string
+	if o != nil {
+		lang, kind = o.Lang, o.Kind
+	}
+	var cands []*CaptionTrack
+	for _, t := range tracks {
+		switch kind {
+		case "standard":
+			if t.IsAutomatic() {
+				continue
+			}
+		case "":
+		default:
+			if t.Kind != kind {
+				continue
+			}
+		}
+		cands = append(cands, t)
+	}
+	if len(cands) == 0 {
+		return nil
+	}
+
+	want, anyLang := lang, lang == ""
+	if anyLang {
+		want = "en"
+	}
+	// Prefer an exact language match, then a regional variant.
+	for _, t := range cands {
+		if t.Lang == want {
+			return t
+		}
+	}
+	for _, t := range cands {
+		if strings.HasPrefix(t.Lang, want+"-") {
+			return t
+		}
+	}
+	if anyLang {
+		return cands[0]
+	}
+	return nil
+}
+
 func loadCaptionXML(ctx context.Context, url string) ([]byte, error) {
+	if data, ok := cacheGet(url); ok {
+		return data, nil
+	}
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -98,6 +291,7 @@ func YouTubeCaptionData(ctx context.Context, url string) (*Transcript, error) {
 	if err := dec.Decode(cap); err != nil {
 		return nil, fmt.Errorf("decoding XML: %w", err)
 	}
+	cachePut(url, bits)
 	for _, c := range cap.Captions {
 		c.Text = html.UnescapeString(c.Text)
 	}
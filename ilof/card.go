@@ -0,0 +1,109 @@
+package ilof
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// A SocialCard is the metadata for the preview shown when a link to an
+// episode page is shared, as rendered into OpenGraph and Twitter card meta
+// tags by the site templates.
+type SocialCard struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`
+	Image       string `json:"image,omitempty"` // absolute URL or site path
+	ImageAlt    string `json:"imageAlt,omitempty"`
+	Type        string `json:"type"` // OpenGraph type
+	Card        string `json:"card"` // Twitter card type
+}
+
+// maxCardDescription is the maximum length of a card description, in runes.
+// Longer descriptions are truncated by most sites in any case.
+const maxCardDescription = 200
+
+// EpisodeCard returns a social card for ep. The description is taken from
+// the summary of the episode, or failing that its topics or the first
+// paragraph of its detail, as plain text. The image is the thumbnail of the
+// episode video, if it has one; callers may substitute another image.
+func EpisodeCard(ep *Episode) *SocialCard {
+	title := fmt.Sprintf("In Lieu of Fun, Episode %s", ep.Episode)
+	if len(ep.Guests) != 0 {
+		title += ": " + joinNames(ep.Guests)
+	}
+	c := &SocialCard{
+		Title: title,
+		URL:   ep.PageURL(),
+		Type:  "article",
+		Card:  "summary",
+	}
+	for _, text := range []string{ep.Summary, ep.Topics, firstParagraph(ep.Detail)} {
+		if d := plainText(text); d != "" {
+			c.Description = truncateWords(d, maxCardDescription)
+			break
+		}
+	}
+	if id, ok := YouTubeVideoID(ep.YouTubeURL); ok {
+		c.SetImage("https://i.ytimg.com/vi/"+id+"/hqdefault.jpg", "Video thumbnail for episode "+string(ep.Episode))
+		c.Type = "video.episode"
+	}
+	return c
+}
+
+// SetImage sets the image of c and its alt text, and selects the large-image
+// card type.
+func (c *SocialCard) SetImage(image, alt string) {
+	c.Image = image
+	c.ImageAlt = alt
+	c.Card = "summary_large_image"
+}
+
+// joinNames joins names into an English list, as "A, B, and C".
+func joinNames(names []string) string {
+	switch len(names) {
+	case 1:
+		return names[0]
+	case 2:
+		return names[0] + " and " + names[1]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", and " + names[len(names)-1]
+}
+
+// firstParagraph returns the first non-blank paragraph of s.
+func firstParagraph(s string) string {
+	for _, p := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			return p
+		}
+	}
+	return ""
+}
+
+var (
+	mdLinkRE     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	mdEmphasisRE = regexp.MustCompile("[*_`]+")
+)
+
+// plainText removes common markdown formatting from s, replacing links by
+// their text, and collapses whitespace.
+func plainText(s string) string {
+	s = mdLinkRE.ReplaceAllString(s, "$1")
+	s = mdEmphasisRE.ReplaceAllString(s, "")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// truncateWords returns s if it has at most n runes, or otherwise a prefix
+// of s ending at a word boundary followed by an ellipsis, of at most n runes.
+func truncateWords(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)[:n-1]
+	cut := string(runes)
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.-") + "…"
+}
@@ -0,0 +1,199 @@
+package ilof
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// A Chapter marks the start of a section of an episode.
+type Chapter struct {
+	Start Offset `json:"start" yaml:"start"`
+	Title string `json:"title" yaml:"title"`
+}
+
+// An Offset is a time offset from the start of an episode, encoded as a
+// timestamp "M:SS" or "H:MM:SS" as in video descriptions.
+type Offset time.Duration
+
+// ParseOffset parses a timestamp of the form [[H:]M:]S.
+func ParseOffset(s string) (Offset, error) {
+	d, err := parseAudioDuration(strings.TrimSpace(s))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return Offset(d), nil
+}
+
+// Seconds returns o in seconds.
+func (o Offset) Seconds() float64 { return time.Duration(o).Seconds() }
+
+// String encodes o as a timestamp "M:SS", or "H:MM:SS" if it is an hour or
+// more. Fractions of a second are discarded.
+func (o Offset) String() string {
+	d := time.Duration(o).Truncate(time.Second)
+	h, m, s := d/time.Hour, (d%time.Hour)/time.Minute, (d%time.Minute)/time.Second
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// UnmarshalText decodes an offset from a timestamp.
+func (o *Offset) UnmarshalText(data []byte) error {
+	v, err := ParseOffset(string(data))
+	if err != nil {
+		return err
+	}
+	*o = v
+	return nil
+}
+
+// MarshalText encodes an offset as a timestamp (used for JSON).
+func (o Offset) MarshalText() ([]byte, error) { return []byte(o.String()), nil }
+
+// UnmarshalYAML decodes an offset from a YAML timestamp string, or from a
+// number of seconds.
+func (o *Offset) UnmarshalYAML(node *yaml.Node) error { return o.UnmarshalText([]byte(node.Value)) }
+
+// MarshalYAML encodes an offset as a YAML string.
+func (o Offset) MarshalYAML() (interface{}, error) {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: o.String()}, nil
+}
+
+// chapterLineRE matches a chapter line in a video description, a timestamp
+// followed by a title, as in "05:12 Courts" or "(1:02:03) - Q&A".
+var chapterLineRE = regexp.MustCompile(`^\(?(\d{1,2}:\d{2}(?::\d{2})?)\)?\s*(?:[-–—:|]\s*)?(\S.*)$`)
+
+// ParseChapters returns the chapters listed in a video description, one per
+// line beginning with a timestamp. Like YouTube, it accepts a list only if it
+// has at least two chapters in strictly increasing order of start time;
+// otherwise it returns nil, since the timestamps are probably something else.
+func ParseChapters(desc string) []*Chapter {
+	var out []*Chapter
+	for _, line := range strings.Split(strings.ReplaceAll(desc, "\r\n", "\n"), "\n") {
+		m := chapterLineRE.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		start, err := ParseOffset(m[1])
+		if err != nil {
+			continue
+		}
+		if n := len(out); n != 0 && start <= out[n-1].Start {
+			return nil
+		}
+		out = append(out, &Chapter{Start: start, Title: plainText(m[2])})
+	}
+	if len(out) < 2 {
+		return nil
+	}
+	return out
+}
+
+// SegmentChapters returns chapters for the segments of ep whose start is
+// known, in order of start time, for an episode that has no chapters of its
+// own. The chapters begin with an introduction at the start of the episode,
+// since players show a chapter list as covering the whole episode. Each
+// segment chapter is titled by the title of the segment in the tag registry
+// reg, if it has one, otherwise by the segment name. SegmentChapters returns
+// nil if no segment has a known start.
+func SegmentChapters(ep *Episode, reg []*TagInfo) []*Chapter {
+	titles := make(map[string]string)
+	for _, t := range reg {
+		if t.Title != "" {
+			titles[t.Tag] = t.Title
+		}
+	}
+	var out []*Chapter
+	for _, s := range ep.Segments {
+		if s.Start <= 0 {
+			continue
+		}
+		title := titles[s.Name]
+		if title == "" {
+			title = s.Name
+		}
+		out = append(out, &Chapter{Start: s.Start, Title: title})
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return append([]*Chapter{{Title: "Introduction"}}, out...)
+}
+
+// PodcastChapterJSON is the MIME type of the chapters format that a podcast
+// feed can advertise with the podcast:chapters element of the Podcasting 2.0
+// namespace.
+const PodcastChapterJSON = "application/json+chapters"
+
+// WritePodcastChapters writes chs to w in the JSON chapters format of the
+// Podcasting 2.0 namespace.
+func WritePodcastChapters(w io.Writer, chs []*Chapter) error {
+	type chapter struct {
+		Start float64 `json:"startTime"`
+		Title string  `json:"title"`
+	}
+	doc := struct {
+		Version  string     `json:"version"`
+		Chapters []*chapter `json:"chapters"`
+	}{Version: "1.2.0", Chapters: []*chapter{}}
+	for _, c := range chs {
+		doc.Chapters = append(doc.Chapters, &chapter{Start: c.Start.Seconds(), Title: c.Title})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
+
+// WriteChaptersVTT writes chs to w as a WebVTT chapters track, with one cue
+// per chapter lasting until the next begins. The last cue ends at end, or a
+// minute after it begins if end is not later than that.
+func WriteChaptersVTT(w io.Writer, chs []*Chapter, end time.Duration) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n\n")
+	for i, c := range chs {
+		stop := end
+		if i+1 < len(chs) {
+			stop = time.Duration(chs[i+1].Start)
+		} else if stop <= time.Duration(c.Start) {
+			stop = time.Duration(c.Start) + time.Minute
+		}
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1,
+			formatCueTime(c.Start.Seconds(), "."), formatCueTime(stop.Seconds(), "."), cueText(c.Title))
+	}
+	return bw.Flush()
+}
+
+// PodcastChapterPaths returns the paths of the chapter files exported for an
+// episode whose transcript is stored at path, in the Podcasting 2.0 JSON
+// chapters format and as a WebVTT chapters track. For
+// "transcripts/0100-abc.json" they are "transcripts/0100-abc.chapters.json"
+// and "transcripts/0100-abc.chapters.vtt".
+func PodcastChapterPaths(path string) (jsonPath, vttPath string) {
+	base := strings.TrimSuffix(path, ".json")
+	return base + ".chapters.json", base + ".chapters.vtt"
+}
+
+// EpisodeEnd returns the length of ep, from its recorded duration if that is
+// set, or else from the end of the last caption of t if t != nil. It returns
+// 0 if neither is known.
+func EpisodeEnd(ep *Episode, t *Transcript) time.Duration {
+	if d, err := time.ParseDuration(ep.Duration); err == nil && d > 0 {
+		return d
+	}
+	if t != nil && len(t.Captions) != 0 {
+		c := t.Captions[len(t.Captions)-1]
+		return time.Duration((c.Start + c.Duration) * float64(time.Second))
+	}
+	return 0
+}
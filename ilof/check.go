@@ -0,0 +1,56 @@
+package ilof
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// A LinkStatus reports the result of checking the liveness of a URL.
+type LinkStatus struct {
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"` // HTTP status code, if a response was received
+	Final  string `json:"final,omitempty"`  // the final URL, if redirected
+	Error  string `json:"error,omitempty"`  // error text, if the request failed
+}
+
+// OK reports whether s represents a successful response.
+func (s *LinkStatus) OK() bool { return s.Error == "" && s.Status == http.StatusOK }
+
+// Redirected reports whether s was redirected to a different URL.
+func (s *LinkStatus) Redirected() bool { return s.Final != "" && s.Final != s.URL }
+
+// CheckLink checks whether url is reachable. It first tries a HEAD request,
+// and falls back to GET if the server does not support HEAD.
+func CheckLink(ctx context.Context, url string) *LinkStatus {
+	st := checkLink(ctx, "HEAD", url)
+	switch st.Status {
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented, http.StatusForbidden:
+		return checkLink(ctx, "GET", url)
+	}
+	if st.Error != "" && st.Status == 0 {
+		return checkLink(ctx, "GET", url)
+	}
+	return st
+}
+
+func checkLink(ctx context.Context, method, url string) *LinkStatus {
+	st := &LinkStatus{URL: url}
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	rsp, err := DefaultFetchOptions.send(ctx, req)
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	io.Copy(io.Discard, io.LimitReader(rsp.Body, 1<<16))
+	rsp.Body.Close()
+	st.Status = rsp.StatusCode
+	if final := rsp.Request.URL.String(); final != url {
+		st.Final = final
+	}
+	return st
+}
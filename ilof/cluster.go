@@ -0,0 +1,168 @@
+package ilof
+
+import (
+	"math"
+	"sort"
+)
+
+// A Cluster is a group of episodes with similar text, found by
+// Corpus.Cluster.
+type Cluster struct {
+	Episodes []Label  `json:"episodes"` // in order of label
+	Terms    []string `json:"terms"`    // the most characteristic terms, most important first
+}
+
+// maxClusterIters bounds the number of refinement steps in Corpus.Cluster.
+const maxClusterIters = 50
+
+// numClusterTerms is the number of terms reported for each cluster.
+const numClusterTerms = 5
+
+// Cluster partitions the documents of the corpus into at most k clusters of
+// similar documents, using spherical k-means over their TF-IDF vectors. The
+// clusters are returned in decreasing order of size. The result is
+// deterministic for a given corpus.
+func (c *Corpus) Cluster(k int) []*Cluster {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	labels := make([]Label, 0, len(c.counts))
+	for label := range c.counts {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool { return labelLess(labels[i], labels[j]) })
+	vecs := make([]termVector, len(labels))
+	for i, label := range labels {
+		vecs[i] = c.docVector(label)
+	}
+	if k <= 0 || len(vecs) == 0 {
+		return nil
+	}
+	k = min(k, len(vecs))
+
+	// Choose initial centers by farthest-point selection: start with the first
+	// document, then repeatedly add the document least similar to any center.
+	centers := []termVector{vecs[0]}
+	best := make([]float64, len(vecs))
+	for i, v := range vecs {
+		best[i] = cosine(v, centers[0])
+	}
+	for len(centers) < k {
+		far := 0
+		for i := range vecs {
+			if best[i] < best[far] {
+				far = i
+			}
+		}
+		centers = append(centers, vecs[far])
+		for i, v := range vecs {
+			best[i] = math.Max(best[i], cosine(v, vecs[far]))
+		}
+	}
+
+	assign := make([]int, len(vecs))
+	for iter := 0; iter < maxClusterIters; iter++ {
+		changed := iter == 0
+		for i, v := range vecs {
+			bi, bs := 0, -1.0
+			for j, ctr := range centers {
+				if s := cosine(v, ctr); s > bs {
+					bi, bs = j, s
+				}
+			}
+			if assign[i] != bi {
+				assign[i] = bi
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		for j := range centers {
+			var members []termVector
+			for i, a := range assign {
+				if a == j {
+					members = append(members, vecs[i])
+				}
+			}
+			if len(members) != 0 {
+				centers[j] = centroid(members)
+			}
+		}
+	}
+
+	var out []*Cluster
+	for j, ctr := range centers {
+		cl := &Cluster{Terms: topTerms(ctr, numClusterTerms)}
+		for i, a := range assign {
+			if a == j {
+				cl.Episodes = append(cl.Episodes, labels[i])
+			}
+		}
+		if len(cl.Episodes) != 0 {
+			out = append(out, cl)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return len(out[i].Episodes) > len(out[j].Episodes) })
+	return out
+}
+
+// SortLabels sorts labels in episode order: numerically, with non-numeric
+// labels following in lexical order.
+func SortLabels(labels []Label) {
+	sort.Slice(labels, func(i, j int) bool { return labelLess(labels[i], labels[j]) })
+}
+
+// labelLess orders episode labels numerically, with non-numeric labels
+// following in lexical order.
+func labelLess(a, b Label) bool {
+	na, nb := a.Number(), b.Number()
+	if (na < 0) != (nb < 0) {
+		return nb < 0
+	} else if na != nb {
+		return na < nb
+	}
+	return a < b
+}
+
+// centroid returns the unit-length mean of vs.
+func centroid(vs []termVector) termVector {
+	sum := make(termVector)
+	for _, v := range vs {
+		for term, w := range v {
+			sum[term] += w
+		}
+	}
+	var norm float64
+	for _, w := range sum {
+		norm += w * w
+	}
+	if norm != 0 {
+		norm = math.Sqrt(norm)
+		for term := range sum {
+			sum[term] /= norm
+		}
+	}
+	return sum
+}
+
+// topTerms returns up to n of the terms of v with the highest weights that
+// are acceptable as keywords, in decreasing order of weight.
+func topTerms(v termVector, n int) []string {
+	var terms []string
+	for term := range v {
+		if goodKeyword(term) {
+			terms = append(terms, term)
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if v[terms[i]] != v[terms[j]] {
+			return v[terms[i]] > v[terms[j]]
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
@@ -0,0 +1,198 @@
+package ilof
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Corpus is a collection of episode texts, used to compare texts by TF-IDF
+// weighted cosine similarity. Words that occur in many episodes of the corpus
+// carry less weight than words that occur in few, so that rare topical words
+// dominate the comparison.
+//
+// A Corpus is safe for concurrent use by multiple goroutines.
+type Corpus struct {
+	opts *WordOptions
+
+	mu     sync.Mutex
+	counts map[Label]map[string]int // term counts per document
+	df     map[string]int           // number of documents containing each term
+	vecs   map[Label]termVector     // cached document vectors, cleared by Add
+}
+
+// A termVector maps terms to weights, normalized to unit length.
+type termVector map[string]float64
+
+// NewCorpus returns an empty corpus whose texts are split into words as
+// directed by opts.
+func NewCorpus(opts *WordOptions) *Corpus {
+	return &Corpus{
+		opts:   opts,
+		counts: make(map[Label]map[string]int),
+		df:     make(map[string]int),
+	}
+}
+
+// DefaultCorpusOptions are the word options used by EpisodeCorpus.
+var DefaultCorpusOptions = &WordOptions{
+	Unicode:   true,
+	Stem:      true,
+	Stopwords: EnglishStopwords,
+}
+
+// EpisodeCorpus returns a corpus of the topics, summaries, and details of the
+// given episodes, using DefaultCorpusOptions. Further text, such as
+// transcripts, may be added to the corpus with Add.
+func EpisodeCorpus(eps []*Episode) *Corpus {
+	c := NewCorpus(DefaultCorpusOptions)
+	for _, ep := range eps {
+		c.Add(ep.Episode, strings.Join([]string{ep.Topics, ep.Summary, ep.Detail}, " "))
+	}
+	return c
+}
+
+// Add adds text to the document for the given episode label. If the corpus
+// already has a document for label, text is appended to it.
+func (c *Corpus) Add(label Label, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.counts[label]
+	if !ok {
+		doc = make(map[string]int)
+		c.counts[label] = doc
+	}
+	for _, w := range WordsWith(text, c.opts) {
+		if doc[w] == 0 {
+			c.df[w]++
+		}
+		doc[w]++
+	}
+	c.vecs = nil
+}
+
+// Len reports the number of documents in the corpus.
+func (c *Corpus) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.counts)
+}
+
+// idf returns the smoothed inverse document frequency of term. The caller
+// must hold c.mu.
+func (c *Corpus) idf(term string) float64 {
+	n := float64(len(c.counts))
+	return math.Log((1+n)/(1+float64(c.df[term]))) + 1
+}
+
+// vector returns the unit TF-IDF vector for the given term counts. The caller
+// must hold c.mu.
+func (c *Corpus) vector(counts map[string]int) termVector {
+	v := make(termVector, len(counts))
+	var norm float64
+	for term, n := range counts {
+		w := (1 + math.Log(float64(n))) * c.idf(term)
+		v[term] = w
+		norm += w * w
+	}
+	if norm != 0 {
+		norm = math.Sqrt(norm)
+		for term := range v {
+			v[term] /= norm
+		}
+	}
+	return v
+}
+
+// docVector returns the vector for the document with the given label, or nil
+// if there is no such document. The caller must hold c.mu.
+func (c *Corpus) docVector(label Label) termVector {
+	if v, ok := c.vecs[label]; ok {
+		return v
+	}
+	counts, ok := c.counts[label]
+	if !ok {
+		return nil
+	}
+	if c.vecs == nil {
+		c.vecs = make(map[Label]termVector)
+	}
+	v := c.vector(counts)
+	c.vecs[label] = v
+	return v
+}
+
+// textVector returns the vector for an arbitrary text, weighted by the
+// document frequencies of the corpus. The caller must hold c.mu.
+func (c *Corpus) textVector(text string) termVector {
+	counts := make(map[string]int)
+	for _, w := range WordsWith(text, c.opts) {
+		counts[w]++
+	}
+	return c.vector(counts)
+}
+
+func cosine(a, b termVector) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	var sum float64
+	for term, w := range a {
+		sum += w * b[term]
+	}
+	return sum
+}
+
+// Similarity returns the TF-IDF weighted cosine similarity of texts a and b,
+// in the range [0, 1], using the document frequencies of the corpus.
+func (c *Corpus) Similarity(a, b string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return cosine(c.textVector(a), c.textVector(b))
+}
+
+// EpisodeSimilarity returns the TF-IDF weighted cosine similarity of the
+// documents for episodes a and b, or 0 if either is not in the corpus.
+func (c *Corpus) EpisodeSimilarity(a, b Label) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return cosine(c.docVector(a), c.docVector(b))
+}
+
+// A RelatedEpisode is an episode scored for similarity to another.
+type RelatedEpisode struct {
+	Episode Label   `json:"episode"`
+	Score   float64 `json:"score"`
+}
+
+// Related returns up to n episodes of the corpus most similar to the episode
+// with the given label, in decreasing order of score. Episodes with a score
+// of 0 are omitted. If n <= 0, all episodes with nonzero scores are returned.
+func (c *Corpus) Related(label Label, n int) []*RelatedEpisode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v := c.docVector(label)
+	if v == nil {
+		return nil
+	}
+	var out []*RelatedEpisode
+	for other := range c.counts {
+		if other == label {
+			continue
+		}
+		if s := cosine(v, c.docVector(other)); s > 0 {
+			out = append(out, &RelatedEpisode{Episode: other, Score: s})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Episode < out[j].Episode
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
@@ -0,0 +1,296 @@
+package ilof
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Credential is a secret or setting that a tool may need, such as an API
+// key. Each credential is looked up by its Name, in the environment, in the
+// credentials file, and in the system keychain, in that order.
+type Credential struct {
+	Name  string // the name, e.g., "YOUTUBE_API_KEY"
+	About string // what it is, and which tools use it
+	Help  string // how to obtain it, if not obvious
+}
+
+// The credentials used by the tools.
+var (
+	TwitterToken = &Credential{
+		Name:  "TWITTER_TOKEN",
+		About: "Twitter API v2 bearer tokens, separated by commas (epdate, guests)",
+		Help:  "If you need a token, visit https://developer.twitter.com/en/portal/dashboard",
+	}
+	YouTubeAPIKey = &Credential{
+		Name:  "YOUTUBE_API_KEY",
+		About: "YouTube Data API key (epdate, airdates, backfill, findvideo, fytt, ical, linkcheck, playlists -dry-run)",
+		Help:  "If you need a key, visit https://console.developers.google.com/apis/credentials",
+	}
+	YouTubeClientID = &Credential{
+		Name:  "YOUTUBE_CLIENT_ID",
+		About: "Google OAuth client ID for managing YouTube playlists (playlists)",
+		Help:  "If you need a client, create a desktop OAuth client at https://console.developers.google.com/apis/credentials",
+	}
+	YouTubeClientSecret = &Credential{
+		Name:  "YOUTUBE_CLIENT_SECRET",
+		About: "Google OAuth client secret for managing YouTube playlists (playlists)",
+		Help:  "If you need a client, create a desktop OAuth client at https://console.developers.google.com/apis/credentials",
+	}
+	YouTubeRefreshToken = &Credential{
+		Name:  "YOUTUBE_REFRESH_TOKEN",
+		About: "OAuth refresh token for the YouTube channel account (playlists)",
+		Help:  "To obtain a token, run \"playlists -authorize\"",
+	}
+	SpotifyClientID = &Credential{
+		Name:  "SPOTIFY_CLIENT_ID",
+		About: "Spotify Web API client ID (scancast)",
+		Help:  "If you need a client, visit https://developer.spotify.com/dashboard",
+	}
+	SpotifyClientSecret = &Credential{
+		Name:  "SPOTIFY_CLIENT_SECRET",
+		About: "Spotify Web API client secret (scancast)",
+		Help:  "If you need a client, visit https://developer.spotify.com/dashboard",
+	}
+	LLMAPIURL = &Credential{
+		Name:  "LLM_API_URL",
+		About: "base URL of an OpenAI-compatible language model API (summarize)",
+	}
+	LLMAPIKey = &Credential{
+		Name:  "LLM_API_KEY",
+		About: "key for the language model API, if it requires one (summarize)",
+	}
+	EmbedAPIURL = &Credential{
+		Name:  "EMBED_API_URL",
+		About: "base URL of an OpenAI-compatible embedding API (semsearch)",
+	}
+	EmbedAPIKey = &Credential{
+		Name:  "EMBED_API_KEY",
+		About: "key for the embedding API, if it requires one (semsearch)",
+	}
+	WebhookURL = &Credential{
+		Name:  "ILOF_WEBHOOK",
+		About: "webhook URL for notifications (epdate, milestones, scancast)",
+	}
+)
+
+// Credentials lists all the known credentials.
+var Credentials = []*Credential{
+	TwitterToken, YouTubeAPIKey, YouTubeClientID, YouTubeClientSecret,
+	YouTubeRefreshToken, SpotifyClientID, SpotifyClientSecret,
+	LLMAPIURL, LLMAPIKey, EmbedAPIURL, EmbedAPIKey, WebhookURL,
+}
+
+// Value returns the value of c from the default loader, or an error that
+// says where it was sought and how to obtain it.
+func (c *Credential) Value() (string, error) {
+	v, _, err := DefaultCredentials.Lookup(c)
+	return v, err
+}
+
+// Get returns the value of c from the default loader, or "" if it is not
+// set or cannot be loaded. Use Get for optional credentials.
+func (c *Credential) Get() string {
+	v, _ := c.Value()
+	return v
+}
+
+// A MissingCredentialError reports that a credential was not found.
+type MissingCredentialError struct {
+	Credential *Credential
+	Checked    []string // where the credential was sought
+}
+
+func (e *MissingCredentialError) Error() string {
+	msg := fmt.Sprintf("no %s is set (checked %s)", e.Credential.Name, joinList(e.Checked))
+	if e.Credential.Help != "" {
+		msg += "\n  " + e.Credential.Help
+	}
+	return msg
+}
+
+// joinList joins ss as an English list, e.g., "a, b, and c".
+func joinList(ss []string) string {
+	switch len(ss) {
+	case 0:
+		return "nothing"
+	case 1:
+		return ss[0]
+	case 2:
+		return ss[0] + " and " + ss[1]
+	}
+	return strings.Join(ss[:len(ss)-1], ", ") + ", and " + ss[len(ss)-1]
+}
+
+// A CredentialLoader looks up credentials from the environment, a file, and
+// the system keychain.
+type CredentialLoader struct {
+	// Look up a variable in the environment. If nil, os.LookupEnv is used.
+	LookupEnv func(name string) (string, bool)
+
+	// The path of a file of NAME=value lines, in the style of a .env file.
+	// If empty, or the file does not exist, no file is used.
+	File string
+
+	// Look up a credential in the system keychain, reporting "" if it is not
+	// present. If nil, the keychain is not used.
+	Keychain func(name string) (string, error)
+
+	once sync.Once
+	file map[string]string
+	err  error
+}
+
+// CredentialFileEnv is the environment variable that overrides the location
+// of the credentials file used by DefaultCredentials.
+const CredentialFileEnv = "ILOF_CREDENTIALS"
+
+// DefaultCredentials is the loader used by the Value and Get methods of a
+// Credential. Its file is named by $ILOF_CREDENTIALS, or by default is
+// ilof/credentials.env in the user configuration directory.
+var DefaultCredentials = &CredentialLoader{
+	File:     defaultCredentialFile(),
+	Keychain: KeychainLookup,
+}
+
+func defaultCredentialFile() string {
+	if path := os.Getenv(CredentialFileEnv); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ilof", "credentials.env")
+}
+
+// Lookup returns the value of c and a description of its source. If c is not
+// found, it reports a *MissingCredentialError. An error reading the file or
+// the keychain is also reported, as it may be the reason c was not found.
+func (l *CredentialLoader) Lookup(c *Credential) (value, source string, _ error) {
+	lookupEnv := l.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	checked := []string{"the environment"}
+	if v, ok := lookupEnv(c.Name); ok && v != "" {
+		return v, "environment", nil
+	}
+
+	if l.File != "" {
+		vals, err := l.loadFile()
+		if err != nil {
+			return "", "", fmt.Errorf("loading %s: %w", c.Name, err)
+		}
+		if vals != nil {
+			checked = append(checked, l.File)
+		}
+		if v := vals[c.Name]; v != "" {
+			return v, l.File, nil
+		}
+	}
+
+	if l.Keychain != nil {
+		checked = append(checked, "the system keychain")
+		v, err := l.Keychain(c.Name)
+		if err != nil {
+			return "", "", fmt.Errorf("loading %s from the keychain: %w", c.Name, err)
+		} else if v != "" {
+			return v, "keychain", nil
+		}
+	}
+	return "", "", &MissingCredentialError{Credential: c, Checked: checked}
+}
+
+// loadFile loads and caches the contents of the credentials file. It returns
+// nil without error if the file does not exist.
+func (l *CredentialLoader) loadFile() (map[string]string, error) {
+	l.once.Do(func() {
+		data, err := os.ReadFile(l.File)
+		if os.IsNotExist(err) {
+			return
+		} else if err != nil {
+			l.err = err
+			return
+		}
+		l.file, l.err = ParseCredentialFile(data)
+		if l.err != nil {
+			l.err = fmt.Errorf("%s: %w", l.File, l.err)
+		}
+	})
+	return l.file, l.err
+}
+
+// ParseCredentialFile parses the contents of a credentials file. Each line is
+// blank, a comment beginning with "#", or NAME=value, optionally preceded by
+// "export". A value may be quoted with single or double quotes; a double
+// quoted value is unquoted as a Go string.
+func ParseCredentialFile(data []byte) (map[string]string, error) {
+	out := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for ln := 1; sc.Scan(); ln++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %d: invalid line, want NAME=value", ln)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			v, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid quoted value: %w", ln, err)
+			}
+			value = v
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		}
+		out[name] = value
+	}
+	return out, sc.Err()
+}
+
+// KeychainService is the service name under which credentials are stored in
+// the system keychain, with the credential name as the account.
+const KeychainService = "ilof"
+
+// KeychainLookup looks up the named credential in the system keychain: the
+// login keychain on macOS, or the Secret Service (via secret-tool) on other
+// systems. It reports "" without error if the credential is not present or
+// no keychain tool is installed. To store a credential, use e.g.
+//
+//	security add-generic-password -s ilof -a YOUTUBE_API_KEY -w       # macOS
+//	secret-tool store --label=YOUTUBE_API_KEY service ilof account YOUTUBE_API_KEY
+func KeychainLookup(name string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", KeychainService, "-a", name, "-w")
+	case "windows":
+		return "", nil
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", KeychainService, "account", name)
+	}
+	if cmd.Err != nil {
+		return "", nil // the tool is not installed
+	}
+	out, err := cmd.Output()
+	if _, ok := err.(*exec.ExitError); ok {
+		return "", nil // not found
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,311 @@
+package ilof
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// A CrowdcastEvent records the metadata for an event exported from Crowdcast.
+type CrowdcastEvent struct {
+	Title       string
+	Start       time.Time
+	Registrants int    // 0 if unknown
+	URL         string // the event or replay URL
+}
+
+// Label returns the episode label named in the title of the event, such as
+// "Episode 123" or "#123", or "" if the title does not name an episode.
+func (e *CrowdcastEvent) Label() Label {
+	if m := titleEpisode.FindStringSubmatch(e.Title); m != nil {
+		return ParseLabel(m[1])
+	}
+	return ""
+}
+
+var titleEpisode = regexp.MustCompile(`(?i)(?:episode\s*|ep\.?\s*|#)(\d+(?:\.\d+)?)\b`)
+
+// crowdcastColumns gives the header names recognized for each field of a
+// Crowdcast export, in lower case.
+var crowdcastColumns = map[string][]string{
+	"title":       {"title", "event title", "event name", "name"},
+	"start":       {"start time", "start date", "start", "date", "scheduled for"},
+	"registrants": {"registrants", "registrations", "total registrants"},
+	"url":         {"replay url", "event url", "url", "link"},
+}
+
+// crowdcastTimeFormats are the timestamp layouts accepted in the start time
+// column of a Crowdcast export.
+var crowdcastTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"01/02/2006 15:04",
+	"01/02/2006 3:04 PM",
+	"Jan 2, 2006 3:04 PM",
+	"2006-01-02",
+	"01/02/2006",
+}
+
+// ParseCrowdcastExport parses a CSV event export from Crowdcast. Columns are
+// identified by their header names; the title and start columns are
+// required. Times without a zone are interpreted in loc.
+func ParseCrowdcastExport(r io.Reader, loc *time.Location) ([]*CrowdcastEvent, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty export")
+	} else if err != nil {
+		return nil, err
+	}
+
+	col := make(map[string]int)
+	for field, names := range crowdcastColumns {
+		col[field] = -1
+	nextName:
+		for _, name := range names {
+			for i, h := range header {
+				if strings.EqualFold(strings.TrimSpace(h), name) {
+					col[field] = i
+					break nextName
+				}
+			}
+		}
+	}
+	if col["title"] < 0 || col["start"] < 0 {
+		return nil, fmt.Errorf("missing title or start column in header %q", header)
+	}
+	get := func(rec []string, field string) string {
+		if i := col[field]; i >= 0 && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	var out []*CrowdcastEvent
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		ev := &CrowdcastEvent{Title: get(rec, "title"), URL: NormalizeURL(get(rec, "url"))}
+		if ev.Start, err = parseCrowdcastTime(get(rec, "start"), loc); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ev.URL != "" && !strings.HasPrefix(ev.URL, "https://") && !strings.HasPrefix(ev.URL, "http://") {
+			return nil, fmt.Errorf("line %d: invalid URL %q", line, ev.URL)
+		}
+		if s := get(rec, "registrants"); s != "" {
+			n, err := strconv.Atoi(strings.ReplaceAll(s, ",", ""))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid registrant count %q", line, s)
+			}
+			ev.Registrants = n
+		}
+		out = append(out, ev)
+	}
+	return out, nil
+}
+
+func parseCrowdcastTime(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range crowdcastTimeFormats {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid start time %q", s)
+}
+
+// CrowdcastSlug returns the event name from a Crowdcast event or replay URL,
+// for example "ilof-100" from "https://www.crowdcast.io/e/ilof-100/register".
+// It reports false if s is not a Crowdcast event URL.
+func CrowdcastSlug(s string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host != "crowdcast.io" && !strings.HasSuffix(host, ".crowdcast.io") {
+		return "", false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || (parts[0] != "e" && parts[0] != "c") || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// CrowdcastReplayURLs returns the URLs at which Crowdcast may serve the
+// replay of the event with the given name, in order of preference. Crowdcast
+// has moved replays between these forms over time.
+func CrowdcastReplayURLs(slug string) []string {
+	return []string{
+		"https://www.crowdcast.io/c/" + slug,
+		"https://www.crowdcast.io/e/" + slug,
+	}
+}
+
+// A ReplayStatus reports whether a Crowdcast URL serves the replay of an
+// event. Crowdcast answers 200 OK with an error page for events that have
+// been removed, so a link can be live without a replay behind it.
+type ReplayStatus struct {
+	LinkStatus
+	Problem string `json:"problem,omitempty"` // "dead", "not-found", "no-replay", or "" if OK
+}
+
+// OK reports whether s represents a page that serves a replay.
+func (s *ReplayStatus) OK() bool { return s.Problem == "" }
+
+// Patterns in the title or status messages of a Crowdcast page that indicate
+// it does not serve a replay, matched in lower case.
+var (
+	crowdcastNotFound = []string{
+		"event not found",
+		"event could not be found",
+		"event does not exist",
+		"event has been deleted",
+		"page not found",
+	}
+	crowdcastNoReplay = []string{
+		"replay is not available",
+		"replay is unavailable",
+		"no replay available",
+		"replay has been disabled",
+	}
+)
+
+// CheckCrowdcastReplay fetches link, following redirects, and reports whether
+// it serves the replay of a Crowdcast event. A page that redirects away from
+// the event, or whose title or status message says the event was not found
+// or has no replay, is reported as a problem even if its status is 200 OK.
+// The rest of the page is not examined, since the scripts of every page
+// contain the text of these messages.
+func CheckCrowdcastReplay(ctx context.Context, link string) *ReplayStatus {
+	st := &ReplayStatus{LinkStatus: LinkStatus{URL: link}, Problem: "dead"}
+	req, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	rsp, err := DefaultFetchOptions.send(ctx, req)
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	defer rsp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(rsp.Body, 1<<20))
+	st.Status = rsp.StatusCode
+	if final := rsp.Request.URL.String(); final != link {
+		st.Final = final
+	}
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	} else if st.Status != http.StatusOK {
+		return st
+	}
+
+	text := strings.ToLower(crowdcastStatusText(body))
+	switch {
+	case st.Redirected() && isCrowdcastEvent(link) && !isCrowdcastEvent(st.Final):
+		st.Problem = "not-found" // e.g., to the Crowdcast home page
+	case containsAny(text, crowdcastNotFound):
+		st.Problem = "not-found"
+	case containsAny(text, crowdcastNoReplay):
+		st.Problem = "no-replay"
+	default:
+		st.Problem = ""
+	}
+	return st
+}
+
+// crowdcastStatusText returns the title of the Crowdcast page in data, and the
+// text of the elements that show the status of the event: those with the ARIA
+// role "alert", and those whose class names mention a status or error.
+func crowdcastStatusText(data []byte) string {
+	var text []string
+	var inTitle bool
+	var depth int // > 0 inside a status element
+	tok := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch tok.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(strings.Join(text, " ")), " ")
+		case html.StartTagToken:
+			t := tok.Token()
+			if isVoidElement(t.DataAtom) {
+				continue
+			} else if depth > 0 {
+				depth++
+			} else if t.DataAtom == atom.Title {
+				inTitle = true
+			} else if isStatusElement(t) {
+				depth = 1
+			}
+		case html.EndTagToken:
+			if depth > 0 {
+				depth--
+			} else if tok.Token().DataAtom == atom.Title {
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle || depth > 0 {
+				text = append(text, string(tok.Text()))
+			}
+		}
+	}
+}
+
+// isStatusElement reports whether t begins an element that shows the status
+// of a Crowdcast event.
+func isStatusElement(t html.Token) bool {
+	if role, _ := getAttr(t, "role"); role == "alert" {
+		return true
+	}
+	class, _ := getAttr(t, "class")
+	class = strings.ToLower(class)
+	return strings.Contains(class, "status") || strings.Contains(class, "error") ||
+		strings.Contains(class, "not-found")
+}
+
+// isVoidElement reports whether a is an HTML element that has no end tag.
+func isVoidElement(a atom.Atom) bool {
+	switch a {
+	case atom.Area, atom.Base, atom.Br, atom.Col, atom.Embed, atom.Hr, atom.Img,
+		atom.Input, atom.Link, atom.Meta, atom.Source, atom.Track, atom.Wbr:
+		return true
+	}
+	return false
+}
+
+// isCrowdcastEvent reports whether s is the URL of a Crowdcast event.
+func isCrowdcastEvent(s string) bool {
+	_, ok := CrowdcastSlug(s)
+	return ok
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package ilof
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DatasetVersion is the version of the transcript corpus format written by
+// WriteDataset. It changes whenever the records or the normalization of the
+// text change, so that consumers can tell which they have.
+const DatasetVersion = 1
+
+// A transcript corpus is a JSON Lines file holding the stored transcripts of
+// the episodes with their metadata. Each line is a record with a "kind"
+// field. The first line is a DatasetHeader; then for each episode, in order
+// of episode number, a DatasetEpisode followed by its DatasetSegment records
+// in order of time.
+
+// A DatasetHeader is the first record of a transcript corpus.
+type DatasetHeader struct {
+	Kind     string `json:"kind"` // "header"
+	Version  int    `json:"version"`
+	Episodes int    `json:"episodes"`
+	Segments int    `json:"segments"`
+	Digest   string `json:"digest"` // SHA-256 of the records after the header
+}
+
+// A DatasetEpisode is the record of an episode in a transcript corpus.
+type DatasetEpisode struct {
+	Kind     string   `json:"kind"` // "episode"
+	Episode  Label    `json:"episode"`
+	Date     string   `json:"date"`
+	Season   int      `json:"season,omitempty"`
+	Guests   []string `json:"guests,omitempty"`
+	Topics   string   `json:"topics,omitempty"`
+	Summary  string   `json:"summary,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	VideoID  string   `json:"videoID,omitempty"`
+	Segments int      `json:"segments"`
+
+	Text []*DatasetSegment `json:"-"` // populated by ReadDataset
+}
+
+// A DatasetSegment is the record of a caption in a transcript corpus.
+type DatasetSegment struct {
+	Kind    string  `json:"kind"` // "segment"
+	Episode Label   `json:"episode"`
+	Seq     int     `json:"seq"`   // 0-based index within the episode
+	Start   float64 `json:"start"` // offset in seconds
+	End     float64 `json:"end"`   // offset in seconds
+	Turn    int     `json:"turn"`  // incremented at each change of speaker
+	Speaker string  `json:"speaker,omitempty"`
+	Text    string  `json:"text"`
+}
+
+// A DatasetItem is an episode and its transcript, for WriteDataset.
+type DatasetItem struct {
+	Episode    *Episode
+	Transcript *Transcript
+}
+
+var (
+	// speakerRE matches a speaker name at the start of a caption, as in
+	// "BEN WITTES: So tonight..." or "Kate Klonick: Hi".
+	speakerRE = regexp.MustCompile(`^((?:[A-Z][A-Za-z.'-]*)(?: [A-Z][A-Za-z.'-]*){0,3}):\s+`)
+
+	// datasetNoiseRE matches annotations such as "[Music]" and "(applause)".
+	datasetNoiseRE = regexp.MustCompile(`\[[^\]]*\]|\([A-Za-z ]*\)`)
+)
+
+// datasetSegments returns the segments of t, with normalized text. Empty
+// captions are dropped. A caption beginning with ">>" or a speaker name
+// starts a new turn; the speaker is recorded when named.
+func datasetSegments(label Label, t *Transcript) []*DatasetSegment {
+	var out []*DatasetSegment
+	var turn int
+	var speaker string
+	for _, c := range t.Captions {
+		text := strings.Join(strings.Fields(c.Text), " ")
+		var newTurn bool
+		if strings.HasPrefix(text, ">>") {
+			text = strings.TrimSpace(strings.TrimLeft(text, ">"))
+			newTurn = true
+		}
+		if m := speakerRE.FindStringSubmatch(text); m != nil {
+			newTurn = newTurn || m[1] != speaker
+			speaker = m[1]
+			text = text[len(m[0]):]
+		} else if newTurn {
+			speaker = ""
+		}
+		if newTurn {
+			turn++
+		}
+		text = strings.ReplaceAll(text, ">>", "")
+		text = strings.Join(strings.Fields(datasetNoiseRE.ReplaceAllString(text, " ")), " ")
+		if text == "" {
+			continue
+		}
+		out = append(out, &DatasetSegment{
+			Kind:    "segment",
+			Episode: label,
+			Seq:     len(out),
+			Start:   roundMillis(c.Start),
+			End:     roundMillis(c.Start + c.Duration),
+			Turn:    turn,
+			Speaker: speaker,
+			Text:    text,
+		})
+	}
+	return out
+}
+
+func roundMillis(v float64) float64 { return math.Round(v*1000) / 1000 }
+
+// WriteDataset writes a transcript corpus of items to w, and returns its
+// header. The output depends only on the contents of items, not their order
+// or the time, so the same inputs always yield the same corpus. The guests of
+// each episode are taken from its Guests field, which the caller should
+// populate from the guest list.
+func WriteDataset(w io.Writer, items []*DatasetItem) (*DatasetHeader, error) {
+	return WriteDatasetWith(w, items, nil)
+}
+
+// WriteDatasetWith acts as WriteDataset, and if progress is non-nil, reports
+// its progress as each episode is written.
+func WriteDatasetWith(w io.Writer, items []*DatasetItem, progress ProgressFunc) (*DatasetHeader, error) {
+	items = append([]*DatasetItem(nil), items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return labelLess(items[i].Episode.Episode, items[j].Episode.Episode)
+	})
+
+	var body strings.Builder
+	enc := json.NewEncoder(&body)
+	enc.SetEscapeHTML(false)
+	hdr := &DatasetHeader{Kind: "header", Version: DatasetVersion}
+	for _, item := range items {
+		ep := item.Episode
+		segs := datasetSegments(ep.Episode, item.Transcript)
+		rec := &DatasetEpisode{
+			Kind:     "episode",
+			Episode:  ep.Episode,
+			Date:     ep.Date.String(),
+			Season:   ep.Season,
+			Guests:   ep.Guests,
+			Topics:   ep.Topics,
+			Summary:  ep.Summary,
+			Tags:     ep.AllTags(),
+			Segments: len(segs),
+		}
+		if id, ok := YouTubeVideoID(ep.YouTubeURL); ok {
+			rec.VideoID = id
+		} else {
+			rec.VideoID = item.Transcript.VideoID
+		}
+		if err := enc.Encode(rec); err != nil {
+			return nil, err
+		}
+		for _, seg := range segs {
+			if err := enc.Encode(seg); err != nil {
+				return nil, err
+			}
+		}
+		hdr.Episodes++
+		hdr.Segments += len(segs)
+		if progress != nil {
+			progress(Progress{Done: hdr.Episodes, Total: len(items), Item: "episode " + string(ep.Episode)})
+		}
+	}
+	sum := sha256.Sum256([]byte(body.String()))
+	hdr.Digest = hex.EncodeToString(sum[:])
+
+	bw := bufio.NewWriter(w)
+	henc := json.NewEncoder(bw)
+	if err := henc.Encode(hdr); err != nil {
+		return nil, err
+	}
+	bw.WriteString(body.String())
+	return hdr, bw.Flush()
+}
+
+// ReadDataset reads a transcript corpus from r, and returns its header and
+// its episodes in order, with their segments. It checks the digest and
+// counts given in the header.
+func ReadDataset(r io.Reader) (*DatasetHeader, []*DatasetEpisode, error) {
+	br := bufio.NewReader(r)
+	first, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+	var hdr DatasetHeader
+	if err := json.Unmarshal(first, &hdr); err != nil {
+		return nil, nil, fmt.Errorf("decoding header: %w", err)
+	} else if hdr.Kind != "header" {
+		return nil, nil, errors.New("missing corpus header")
+	} else if hdr.Version != DatasetVersion {
+		return nil, nil, fmt.Errorf("unsupported corpus version %d", hdr.Version)
+	}
+
+	h := sha256.New()
+	var eps []*DatasetEpisode
+	var numSegs int
+	for line := 2; ; line++ {
+		data, err := br.ReadBytes('\n')
+		if err == io.EOF && len(data) == 0 {
+			break
+		} else if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		h.Write(data)
+		var kind struct {
+			Kind string `json:"kind"`
+		}
+		if err := json.Unmarshal(data, &kind); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch kind.Kind {
+		case "episode":
+			ep := new(DatasetEpisode)
+			if err := json.Unmarshal(data, ep); err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			eps = append(eps, ep)
+		case "segment":
+			seg := new(DatasetSegment)
+			if err := json.Unmarshal(data, seg); err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", line, err)
+			} else if len(eps) == 0 || eps[len(eps)-1].Episode != seg.Episode {
+				return nil, nil, fmt.Errorf("line %d: segment of episode %s out of place", line, seg.Episode)
+			}
+			ep := eps[len(eps)-1]
+			ep.Text = append(ep.Text, seg)
+			numSegs++
+		default:
+			return nil, nil, fmt.Errorf("line %d: unknown record kind %q", line, kind.Kind)
+		}
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != hdr.Digest {
+		return nil, nil, errors.New("corpus digest does not match")
+	} else if len(eps) != hdr.Episodes || numSegs != hdr.Segments {
+		return nil, nil, fmt.Errorf("corpus has %d episodes and %d segments, header says %d and %d",
+			len(eps), numSegs, hdr.Episodes, hdr.Segments)
+	}
+	return &hdr, eps, nil
+}
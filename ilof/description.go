@@ -0,0 +1,67 @@
+package ilof
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxAutoSummary is the maximum length of a summary taken from a video
+// description by DescriptionSummary, in runes.
+const maxAutoSummary = 400
+
+var (
+	// linksHeadingRE matches a heading that introduces the block of links in
+	// a video description, such as "Links", "Show notes:" or "Things we
+	// mentioned:". An ordinary sentence that ends in a colon does not match.
+	linksHeadingRE = regexp.MustCompile(`(?i)^(?:(?:links|show notes|resources|references|further reading)\b[\w ]{0,30}|[\w ]{0,30}\b(?:links|mentioned|resources|references))\s*:?$`)
+
+	// timestampRE matches a line beginning with a chapter timestamp.
+	timestampRE = regexp.MustCompile(`^\(?\d{1,2}:\d{2}(?::\d{2})?\b`)
+)
+
+// DescriptionSummary returns a provisional summary for an episode taken from
+// the YouTube description of its video, or "" if none can be found. The
+// summary is the first paragraph of the description before the block of
+// links and chapter timestamps that usually follows it; a line with a bare
+// URL, a chapter timestamp, or a heading like "Links:" begins that block. If
+// that paragraph is long, it is cut to as many whole sentences as fit.
+func DescriptionSummary(desc string) string {
+	var para []string
+	for _, line := range strings.Split(strings.ReplaceAll(desc, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if len(para) != 0 {
+				break
+			}
+			continue
+		} else if bareURL.MatchString(mdLinkRE.ReplaceAllString(line, "$1")) || linksHeadingRE.MatchString(line) || timestampRE.MatchString(line) {
+			break
+		}
+		para = append(para, line)
+	}
+	text := plainText(strings.Join(para, " "))
+	if utf8.RuneCountInString(text) <= maxAutoSummary {
+		return text
+	}
+
+	// Keep whole sentences while they fit; if even the first does not fit,
+	// cut it at a word boundary.
+	var words []string
+	var keep string
+	var n int
+	for _, tok := range strings.Fields(text) {
+		n += utf8.RuneCountInString(tok) + 1
+		if n > maxAutoSummary {
+			break
+		}
+		words = append(words, tok)
+		if endsSentence(tok) {
+			keep = strings.Join(words, " ")
+		}
+	}
+	if keep != "" {
+		return keep
+	}
+	return truncateWords(text, maxAutoSummary)
+}
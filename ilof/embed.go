@@ -0,0 +1,392 @@
+package ilof
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/creachadair/atomicfile"
+)
+
+// An Embedder computes embedding vectors for texts, such that texts with
+// similar meanings have nearby vectors.
+type Embedder interface {
+	// Name returns a string identifying the embedding model. Vectors from
+	// embedders with different names cannot be compared.
+	Name() string
+
+	// Embed returns one vector for each of texts, in the same order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// An EmbeddingClient is an Embedder that calls the embeddings method of an
+// OpenAI-compatible API. Local model servers such as Ollama and llama.cpp
+// provide the same method, so this also serves for local models.
+type EmbeddingClient struct {
+	BaseURL string // e.g., "https://api.openai.com/v1"
+	APIKey  string // sent as a bearer token, if set
+	Model   string // the model name to request
+}
+
+// Name implements a method of the Embedder interface.
+func (c *EmbeddingClient) Name() string { return "api:" + c.Model }
+
+// Embed implements a method of the Embedder interface.
+func (c *EmbeddingClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}{Model: c.Model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	u := strings.TrimSuffix(c.BaseURL, "/") + "/embeddings"
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	opts := *DefaultFetchOptions
+	if opts.Timeout != 0 {
+		opts.Timeout = max(opts.Timeout, llmTimeout)
+	}
+	bits, err := loadRequestWith(ctx, req, &opts)
+	if err != nil {
+		return nil, err
+	}
+	var rsp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(bits, &rsp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	} else if len(rsp.Data) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(rsp.Data), len(texts))
+	}
+	out := make([][]float32, len(texts))
+	for _, d := range rsp.Data {
+		if d.Index < 0 || d.Index >= len(out) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		out[d.Index] = d.Embedding
+	}
+	return out, nil
+}
+
+// A HashEmbedder is an Embedder that needs no model. It hashes the stemmed
+// words and adjacent word pairs of a text into a vector of the given
+// dimension. Unlike a trained model, it does not relate synonyms, but it
+// does match different forms of the same word; it is meant for offline use
+// and testing.
+type HashEmbedder struct {
+	Dim int // if 0, 512 is used
+}
+
+// Name implements a method of the Embedder interface.
+func (h HashEmbedder) Name() string { return fmt.Sprintf("hash:%d", h.dim()) }
+
+func (h HashEmbedder) dim() int {
+	if h.Dim <= 0 {
+		return 512
+	}
+	return h.Dim
+}
+
+// Embed implements a method of the Embedder interface.
+func (h HashEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, h.dim())
+		words := WordsWith(text, DefaultCorpusOptions)
+		add := func(term string, w float32) {
+			f := fnv.New64a()
+			f.Write([]byte(term))
+			x := f.Sum64()
+			if x&1 == 0 {
+				w = -w
+			}
+			v[(x>>1)%uint64(len(v))] += w
+		}
+		for j, w := range words {
+			add(w, 1)
+			if j > 0 {
+				add(words[j-1]+" "+w, 0.5)
+			}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// A Vector is an embedding vector. It is encoded in JSON as a base64 string
+// of little-endian 32-bit floats, which is much more compact than an array.
+type Vector []float32
+
+// MarshalJSON implements the json.Marshaler interface.
+func (v Vector) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(buf))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (v *Vector) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	} else if len(buf)%4 != 0 {
+		return errors.New("invalid vector length")
+	}
+	*v = make(Vector, len(buf)/4)
+	for i := range *v {
+		(*v)[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return nil
+}
+
+// normalize scales v to unit length, in place.
+func (v Vector) normalize() {
+	var norm float64
+	for _, f := range v {
+		norm += float64(f) * float64(f)
+	}
+	if norm == 0 {
+		return
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] = float32(float64(v[i]) / norm)
+	}
+}
+
+// dot returns the dot product of v and w, which must have the same length.
+func (v Vector) dot(w Vector) float64 {
+	var s float64
+	for i, f := range v {
+		s += float64(f) * float64(w[i])
+	}
+	return s
+}
+
+// A Chunk is a passage of a transcript.
+type Chunk struct {
+	Episode Label   `json:"episode"`
+	Start   float64 `json:"start"` // offset of the first caption, in seconds
+	Text    string  `json:"text"`
+}
+
+// TranscriptChunks splits t into passages of about size words each, starting
+// at caption boundaries, with consecutive passages overlapping by about
+// overlap words so that a topic spanning a boundary is not lost.
+func TranscriptChunks(label Label, t *Transcript, size, overlap int) []*Chunk {
+	if size <= 0 {
+		return nil
+	}
+	overlap = max(0, min(overlap, size/2))
+	type word struct {
+		text  string
+		start float64
+		first bool // the first word of a caption
+	}
+	var words []word
+	for _, c := range t.Captions {
+		for i, w := range strings.Fields(c.Text) {
+			words = append(words, word{w, c.Start, i == 0})
+		}
+	}
+
+	var out []*Chunk
+	for lo := 0; lo < len(words); {
+		hi := min(lo+size, len(words))
+		var parts []string
+		for _, w := range words[lo:hi] {
+			parts = append(parts, w.text)
+		}
+		out = append(out, &Chunk{Episode: label, Start: words[lo].start, Text: strings.Join(parts, " ")})
+		if hi == len(words) {
+			break
+		}
+
+		// Start the next chunk at the beginning of a caption near the end of
+		// the overlap, if possible, so that offsets point to its first words.
+		next := hi - overlap
+		for j := next; j > lo+size/2; j-- {
+			if words[j].first {
+				next = j
+				break
+			}
+		}
+		lo = next
+	}
+	return out
+}
+
+// An indexedChunk is a chunk with its embedding.
+type indexedChunk struct {
+	*Chunk
+	Vector Vector `json:"vector"`
+}
+
+// An EmbeddingIndex is a collection of transcript chunks and their embedding
+// vectors, supporting nearest-neighbor search.
+type EmbeddingIndex struct {
+	Model    string           `json:"model"`    // the name of the embedder
+	Episodes map[Label]string `json:"episodes"` // content digest of the text indexed per episode
+	Chunks   []*indexedChunk  `json:"chunks"`
+}
+
+// NewEmbeddingIndex returns an empty index for vectors from e.
+func NewEmbeddingIndex(e Embedder) *EmbeddingIndex {
+	return &EmbeddingIndex{Model: e.Name(), Episodes: make(map[Label]string)}
+}
+
+// LoadEmbeddingIndex loads an index from the file at path.
+func LoadEmbeddingIndex(path string) (*EmbeddingIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	idx := new(EmbeddingIndex)
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("decoding index: %w", err)
+	}
+	if idx.Episodes == nil {
+		idx.Episodes = make(map[Label]string)
+	}
+	return idx, nil
+}
+
+// Save writes the index to the file at path.
+func (x *EmbeddingIndex) Save(path string) error {
+	data, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteData(path, data, 0644)
+}
+
+// embedBatch is the number of chunks sent to an embedder at once.
+const embedBatch = 64
+
+// Current reports whether the index has the chunks of the episode with the
+// given label, computed from the same text.
+func (x *EmbeddingIndex) Current(label Label, chunks []*Chunk) bool {
+	d, ok := x.Episodes[label]
+	return ok && d == chunkDigest(chunks)
+}
+
+// Add replaces the chunks of the episode with the given label in the index
+// by chunks, embedded by e. The name of e must match the index.
+func (x *EmbeddingIndex) Add(ctx context.Context, e Embedder, label Label, chunks []*Chunk) error {
+	if e.Name() != x.Model {
+		return fmt.Errorf("embedder %q does not match index model %q", e.Name(), x.Model)
+	}
+	var added []*indexedChunk
+	for i := 0; i < len(chunks); i += embedBatch {
+		batch := chunks[i:min(i+embedBatch, len(chunks))]
+		texts := make([]string, len(batch))
+		for j, c := range batch {
+			texts[j] = c.Text
+		}
+		vecs, err := e.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("embedding episode %s: %w", label, err)
+		}
+		for j, c := range batch {
+			v := Vector(vecs[j])
+			v.normalize()
+			added = append(added, &indexedChunk{Chunk: c, Vector: v})
+		}
+	}
+	x.Remove(label)
+	x.Chunks = append(x.Chunks, added...)
+	x.Episodes[label] = chunkDigest(chunks)
+	return nil
+}
+
+// Remove removes the chunks of the episode with the given label.
+func (x *EmbeddingIndex) Remove(label Label) {
+	keep := x.Chunks[:0]
+	for _, c := range x.Chunks {
+		if c.Episode != label {
+			keep = append(keep, c)
+		}
+	}
+	x.Chunks = keep
+	delete(x.Episodes, label)
+}
+
+// A SemanticMatch is a chunk found by a search of an EmbeddingIndex.
+type SemanticMatch struct {
+	*Chunk
+	Score float64 `json:"score"` // cosine similarity to the query
+}
+
+// Search returns up to k chunks of the index nearest to query, in decreasing
+// order of similarity. At most perEpisode chunks are returned for any one
+// episode, if perEpisode > 0. The name of e must match the index.
+func (x *EmbeddingIndex) Search(ctx context.Context, e Embedder, query string, k, perEpisode int) ([]*SemanticMatch, error) {
+	if e.Name() != x.Model {
+		return nil, fmt.Errorf("embedder %q does not match index model %q", e.Name(), x.Model)
+	}
+	vecs, err := e.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	q := Vector(vecs[0])
+	q.normalize()
+
+	var all []*SemanticMatch
+	for _, c := range x.Chunks {
+		if len(c.Vector) != len(q) {
+			continue
+		}
+		all = append(all, &SemanticMatch{Chunk: c.Chunk, Score: c.Vector.dot(q)})
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Score > all[j].Score })
+
+	var out []*SemanticMatch
+	count := make(map[Label]int)
+	for _, m := range all {
+		if k > 0 && len(out) == k {
+			break
+		} else if perEpisode > 0 && count[m.Episode] >= perEpisode {
+			continue
+		}
+		count[m.Episode]++
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// chunkDigest returns a digest of the text and offsets of chunks.
+func chunkDigest(chunks []*Chunk) string {
+	h := sha256.New()
+	for _, c := range chunks {
+		fmt.Fprintf(h, "%g\x00%s\x00", c.Start, c.Text)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
@@ -0,0 +1,218 @@
+package ilof
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// An EntityKind identifies the kind of a named entity.
+type EntityKind string
+
+// The kinds of entity found by an EntityExtractor.
+const (
+	PersonEntity EntityKind = "person"
+	BookEntity   EntityKind = "book"
+	CaseEntity   EntityKind = "case"
+)
+
+// An Entity is a named entity mentioned in a text.
+type Entity struct {
+	Kind  EntityKind `json:"kind"`
+	Name  string     `json:"name"`
+	Count int        `json:"count"` // number of mentions
+}
+
+// An EntityExtractor finds the named entities mentioned in a text.
+// Implementations may use a local heuristic or a remote service.
+type EntityExtractor interface {
+	// Extract returns the entities mentioned in text, each reported once with
+	// the number of its mentions.
+	Extract(ctx context.Context, text string) ([]*Entity, error)
+}
+
+// A HeuristicExtractor is an EntityExtractor that finds entities using a
+// dictionary of known names and patterns of capitalization:
+//
+//   - Names in the dictionary are matched regardless of case.
+//   - Court cases are matched as "A v. B" or "In re A", for capitalized A and B.
+//   - Books are matched as capitalized titles in markdown emphasis, or quoted
+//     or capitalized titles following "book", "novel", or "memoir".
+//   - People are matched as runs of two or three capitalized words, optionally
+//     preceded by a title such as "Judge" or "Senator".
+//
+// The heuristics favour recall over precision, so results should be reviewed.
+type HeuristicExtractor struct {
+	// Known names and their kinds, such as the names of guests.
+	Dictionary map[string]EntityKind
+}
+
+var (
+	caseRE = regexp.MustCompile(`\b((?:[A-Z][\w'&.-]*\s+){0,3}?[A-Z][\w'&-]*)\s+vs?\.\s+` +
+		`([A-Z][\w'&-]*(?:\s+(?:of\s+|the\s+)*[A-Z][\w'&-]*){0,3})|\bIn re ([A-Z][\w'&-]*(?:\s+[A-Z][\w'&-]*){0,2})`)
+	emphasisBookRE = regexp.MustCompile(`(?:^|[^*\w])[*_]([A-Z][^*_\n]{2,80}?)[*_](?:[^*\w]|$)`)
+	quotedBookRE   = regexp.MustCompile(`(?i:book|novel|memoir)(?:\s+(?i:called|titled|named))?,?\s+["“]([^"”\n]{2,80})["”]`)
+	calledBookRE   = regexp.MustCompile(`(?i:book|novel|memoir)\s+(?i:called|titled|named)\s+((?:[A-Z][\w'’:-]*)(?:\s+(?:of|the|and|a|in|[A-Z][\w'’:-]*))*)`)
+	personRE       = regexp.MustCompile(`\b(?:(?:Judge|Justice|Senator|Sen\.|Representative|Rep\.|Professor|Prof\.|Dr\.|President|Governor|Gov\.|General|Gen\.|Secretary)\s+)?` +
+		`([A-Z][a-z'’-]+(?:\s+[A-Z]\.)?(?:\s+[A-Z][a-z'’-]+)+)\b`)
+)
+
+// notNameWords are capitalized words that do not occur in the names of
+// people, in lower case.
+var notNameWords = map[string]bool{
+	"january": true, "february": true, "march": true, "april": true, "may": true,
+	"june": true, "july": true, "august": true, "september": true, "october": true,
+	"november": true, "december": true, "monday": true, "tuesday": true,
+	"wednesday": true, "thursday": true, "friday": true, "saturday": true,
+	"sunday": true, "court": true, "supreme": true, "united": true,
+	"states": true, "house": true, "senate": true, "congress": true,
+	"university": true, "act": true, "department": true, "new": true,
+	"north": true, "south": true, "east": true, "west": true, "street": true,
+	"times": true, "post": true, "news": true, "lawfare": true, "fun": true,
+	"lieu": true, "show": true, "episode": true, "season": true, "god": true,
+	"okay": true, "yeah": true, "thank": true, "thanks": true, "hello": true,
+}
+
+// Extract implements the EntityExtractor interface. It does not fail.
+func (h *HeuristicExtractor) Extract(_ context.Context, text string) ([]*Entity, error) {
+	type key struct {
+		kind EntityKind
+		name string
+	}
+	counts := make(map[key]int)
+	known := make(map[string]bool) // lower-case dictionary names
+
+	words := Words(text)
+	for name, kind := range h.Dictionary {
+		known[strings.ToLower(name)] = true
+		phrase := Words(name)
+		if len(phrase) == 0 {
+			continue
+		}
+		for i := range words {
+			if hasPhraseAt(words, i, phrase) {
+				counts[key{kind, name}]++
+			}
+		}
+	}
+
+	// Remove the spans of cases and books once found, so that their words are
+	// not also reported as the names of people.
+	rest := []byte(text)
+	blank := func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			rest[i] = ' '
+		}
+	}
+	for _, m := range caseRE.FindAllStringSubmatchIndex(text, -1) {
+		var name string
+		if m[2] >= 0 {
+			first := trimLeadingStopwords(text[m[2]:m[3]])
+			if first == "" {
+				continue
+			}
+			name = first + " v. " + text[m[4]:m[5]]
+		} else {
+			name = "In re " + text[m[6]:m[7]]
+		}
+		if !known[strings.ToLower(name)] {
+			counts[key{CaseEntity, name}]++
+		}
+		blank(m[0], m[1])
+	}
+	for _, re := range []*regexp.Regexp{emphasisBookRE, quotedBookRE, calledBookRE} {
+		for _, m := range re.FindAllSubmatchIndex(rest, -1) {
+			name := strings.TrimSpace(string(rest[m[2]:m[3]]))
+			if name != "" && !known[strings.ToLower(name)] {
+				counts[key{BookEntity, name}]++
+			}
+			blank(m[0], m[1])
+		}
+	}
+	for _, m := range personRE.FindAllSubmatchIndex(rest, -1) {
+		for _, name := range personNames(string(rest[m[2]:m[3]])) {
+			if !known[strings.ToLower(name)] {
+				counts[key{PersonEntity, name}]++
+			}
+		}
+	}
+
+	out := make([]*Entity, 0, len(counts))
+	for k, n := range counts {
+		out = append(out, &Entity{Kind: k.kind, Name: k.name, Count: n})
+	}
+	SortEntities(out)
+	return out, nil
+}
+
+// trimLeadingStopwords removes from the front of s any words that are
+// stopwords, such as a capitalized word at the start of a sentence.
+func trimLeadingStopwords(s string) string {
+	fs := strings.Fields(s)
+	for len(fs) != 0 && EnglishStopwords.Contains(strings.ToLower(strings.ReplaceAll(fs[0], "'", ""))) {
+		fs = fs[1:]
+	}
+	return strings.Join(fs, " ")
+}
+
+// personNames splits a run of capitalized words into the plausible names of
+// people it contains: runs of two or three words that are not stopwords or
+// known not to occur in names.
+func personNames(s string) []string {
+	var out, cur []string
+	flush := func() {
+		if len(cur) >= 2 && len(cur) <= 3 {
+			out = append(out, strings.Join(cur, " "))
+		}
+		cur = nil
+	}
+	for _, w := range strings.Fields(s) {
+		lw := strings.ToLower(strings.Trim(w, "."))
+		if notNameWords[lw] || EnglishStopwords.Contains(strings.ReplaceAll(lw, "'", "")) {
+			flush()
+		} else {
+			cur = append(cur, w)
+		}
+	}
+	flush()
+	return out
+}
+
+// SortEntities sorts es by kind, then in decreasing order of count, with ties
+// broken by name.
+func SortEntities(es []*Entity) {
+	sort.Slice(es, func(i, j int) bool {
+		if es[i].Kind != es[j].Kind {
+			return es[i].Kind < es[j].Kind
+		} else if es[i].Count != es[j].Count {
+			return es[i].Count > es[j].Count
+		}
+		return es[i].Name < es[j].Name
+	})
+}
+
+// MergeEntities combines lists of entities, adding the counts of entities
+// with the same kind and name. The result is sorted by SortEntities.
+func MergeEntities(lists ...[]*Entity) []*Entity {
+	type key struct {
+		kind EntityKind
+		name string
+	}
+	byKey := make(map[key]*Entity)
+	var out []*Entity
+	for _, es := range lists {
+		for _, e := range es {
+			k := key{e.Kind, e.Name}
+			if old, ok := byKey[k]; ok {
+				old.Count += e.Count
+				continue
+			}
+			cp := *e
+			byKey[k] = &cp
+			out = append(out, &cp)
+		}
+	}
+	SortEntities(out)
+	return out
+}
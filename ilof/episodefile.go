@@ -0,0 +1,102 @@
+package ilof
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/inlieuoffun/tools/repo"
+)
+
+// EpisodeFileName returns the base name of the episode file for the episode
+// with the given label and air date. Numbered episodes are padded to four
+// digits, as "2021-01-05-0100.md".
+func EpisodeFileName(date Date, label Label) string {
+	if v := label.Number(); v >= 0 && v == float64(int(v)) {
+		return fmt.Sprintf("%s-%04d.md", date, int(v))
+	}
+	return fmt.Sprintf("%s-%s.md", date, label)
+}
+
+// EpisodeFileOptions are options for CreateOrUpdateEpisodeFromUpdate.
+type EpisodeFileOptions struct {
+	// The worktree in which to read and write the episode file. If nil,
+	// repo.OS is used.
+	Worktree repo.Worktree
+
+	// The description of the episode video, if known. A new episode file
+	// takes its detail from the description, a file with no summary gets one
+	// from it, marked for review, and a file with no chapters gets those
+	// listed in it.
+	Description string
+
+	// The rules used to detect segments of the episode in its description.
+	// If nil, TagRules is used.
+	TagRules []*TagRule
+}
+
+// An EpisodeFileResult reports the episode file written by
+// CreateOrUpdateEpisodeFromUpdate.
+type EpisodeFileResult struct {
+	Path       string   // the path of the episode file
+	Episode    *Episode // the episode as written
+	Created    bool     // whether the file was created
+	Summarized bool     // whether the summary was filled in from the description
+}
+
+// CreateOrUpdateEpisodeFromUpdate writes the file in dir for episode num,
+// announced by up. If the file does not exist, it is created with the air
+// date of up; otherwise its existing contents are kept. In either case the
+// stream URLs are set from up, and segments detected by the tag rules in the
+// description are added. A nil opts is equivalent to a zero value.
+func CreateOrUpdateEpisodeFromUpdate(dir string, num int, up *TwitterUpdate, opts *EpisodeFileOptions) (*EpisodeFileResult, error) {
+	if opts == nil {
+		opts = new(EpisodeFileOptions)
+	}
+	wt := opts.Worktree
+	if wt == nil {
+		wt = repo.OS
+	}
+	rules := opts.TagRules
+	if rules == nil {
+		rules = TagRules
+	}
+
+	label := Label(strconv.Itoa(num))
+	res := &EpisodeFileResult{Path: filepath.Join(dir, EpisodeFileName(Date(up.AirDate), label))}
+	ep, err := LoadEpisodeIn(wt, res.Path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		ep = &Episode{
+			Episode: label,
+			Date:    Date(up.AirDate),
+			Detail:  opts.Description,
+		}
+		res.Created = true
+	}
+	for _, r := range rules {
+		if _, ok := r.Match(opts.Description, "", 0); ok && !ep.HasTag(r.Tag) {
+			ep.AddSegment(r.Tag)
+		}
+	}
+	if ep.Summary == "" {
+		if sum := DescriptionSummary(opts.Description); sum != "" {
+			ep.Summary = sum
+			ep.AutoSummary = true
+			res.Summarized = true
+		}
+	}
+	if len(ep.Chapters) == 0 {
+		ep.Chapters = ParseChapters(opts.Description)
+	}
+	ep.CrowdcastURL = up.Crowdcast
+	ep.YouTubeURL = up.YouTube
+	if err := WriteEpisodeIn(wt, res.Path, ep); err != nil {
+		return nil, err
+	}
+	res.Episode = ep
+	return res, nil
+}
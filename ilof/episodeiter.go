@@ -0,0 +1,101 @@
+package ilof
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/inlieuoffun/tools/repo"
+)
+
+// An EpisodeIter yields the episodes of a directory one at a time, in order
+// of file name. Each episode file is loaded only when the iterator reaches
+// it, so a caller that processes episodes as they arrive does not hold the
+// whole catalog in memory, and a caller that stops early does not read the
+// rest of the files. Use Episodes to construct an iterator:
+//
+//	it := ilof.Episodes(ctx, repo.EpisodeDir, nil)
+//	for it.Next() {
+//	   ep := it.Episode()
+//	   // ...
+//	}
+//	if err := it.Err(); err != nil {
+//	   log.Fatalf("Loading episodes: %v", err)
+//	}
+//
+// An EpisodeIter holds no resources between calls, so there is nothing to
+// release when the caller stops early.
+type EpisodeIter struct {
+	ctx    context.Context
+	dir    string
+	filter *EpisodeFilter
+	names  []string // file names not yet visited
+	listed bool     // whether names has been read from dir
+
+	path string
+	ep   *Episode
+	err  error
+}
+
+// Episodes returns an iterator over the episodes in the given directory that
+// are selected by filter. A nil filter selects every episode. The iterator
+// stops with the error of ctx if ctx ends before the last episode.
+func Episodes(ctx context.Context, dir string, filter *EpisodeFilter) *EpisodeIter {
+	it := &EpisodeIter{ctx: ctx, dir: dir, filter: filter}
+	if filter != nil {
+		it.err = filter.Check()
+	}
+	return it
+}
+
+// Next advances it to the next selected episode, and reports whether there
+// is one. When Next reports false, the iteration is over, and Err reports
+// whether it stopped because of an error.
+func (it *EpisodeIter) Next() bool {
+	it.path, it.ep = "", nil
+	if it.err != nil {
+		return false
+	}
+	if !it.listed {
+		it.listed = true
+		names, err := repo.EpisodeFileNames(it.dir)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.names = names
+	}
+	for len(it.names) != 0 {
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		path := filepath.Join(it.dir, it.names[0])
+		it.names = it.names[1:]
+		if it.filter != nil && !it.filter.matchFile(path) {
+			continue
+		}
+		ep, err := LoadEpisode(path)
+		if err != nil {
+			it.err = fmt.Errorf("loading episode file: %v", err)
+			return false
+		}
+		if it.filter != nil && !it.filter.match(ep) {
+			continue
+		}
+		it.path, it.ep = path, ep
+		return true
+	}
+	return false
+}
+
+// Episode returns the current episode. It returns nil before the first call
+// to Next and after Next reports false.
+func (it *EpisodeIter) Episode() *Episode { return it.ep }
+
+// Path returns the path of the file of the current episode.
+func (it *EpisodeIter) Path() string { return it.path }
+
+// Err returns the error that stopped the iteration, or nil if the iteration
+// has not stopped or reached the last episode.
+func (it *EpisodeIter) Err() error { return it.err }
@@ -0,0 +1,185 @@
+package ilof
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shortLinkHosts are the hosts of common link-shortening services.
+var shortLinkHosts = map[string]bool{
+	"t.co": true, "bit.ly": true, "buff.ly": true, "ow.ly": true,
+	"tinyurl.com": true, "goo.gl": true, "dlvr.it": true, "lnkd.in": true,
+	"trib.al": true, "fb.me": true, "wp.me": true, "tiny.cc": true,
+	"is.gd": true, "rebrand.ly": true, "shorturl.at": true,
+}
+
+// IsShortLink reports whether s is a URL on a known link-shortening service.
+func IsShortLink(s string) bool {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return false
+	}
+	return shortLinkHosts[strings.ToLower(strings.TrimPrefix(u.Hostname(), "www."))]
+}
+
+// ErrTooManyRedirects is reported by Expand when a URL redirects more times
+// than permitted.
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// An Expander resolves shortened links to their final destinations by
+// following redirects. Results are cached, so that each link is resolved at
+// most once. The zero value is ready for use. An Expander is safe for
+// concurrent use by multiple goroutines.
+type Expander struct {
+	// The maximum number of redirects to follow. If zero, 10 is used.
+	MaxRedirects int
+
+	// The time limit for resolving a single URL. If zero, 15 seconds is used.
+	Timeout time.Duration
+
+	// If non-nil, resolved URLs are stored in this cache as well as in memory,
+	// so that they persist across runs.
+	Cache ResponseCache
+
+	// The client used to send requests. If nil, the client of
+	// DefaultFetchOptions is used (but its redirect policy is not).
+	Client *http.Client
+
+	mu   sync.Mutex
+	seen map[string]string
+}
+
+// DefaultExpander is the Expander used by the update pipeline.
+var DefaultExpander = new(Expander)
+
+// expandCachePrefix distinguishes expanded URLs in a shared ResponseCache.
+const expandCachePrefix = "expand:"
+
+// Expand follows the redirects from s and returns the final URL, normalized
+// by NormalizeURL. If the redirect limit is exceeded, Expand returns the last
+// URL reached along with ErrTooManyRedirects.
+func (e *Expander) Expand(ctx context.Context, s string) (string, error) {
+	e.mu.Lock()
+	final, ok := e.seen[s]
+	e.mu.Unlock()
+	if ok {
+		return final, nil
+	}
+	if e.Cache != nil {
+		if data, ok := e.Cache.Get(expandCachePrefix + s); ok {
+			e.remember(s, string(data))
+			return string(data), nil
+		}
+	}
+
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	final, err := e.follow(ctx, s)
+	if err != nil {
+		return final, err
+	}
+	final = NormalizeURL(final)
+	e.remember(s, final)
+	if e.Cache != nil {
+		e.Cache.Put(expandCachePrefix+s, []byte(final))
+	}
+	return final, nil
+}
+
+// ExpandIfShort returns the expansion of s if it is a short link, or s itself
+// otherwise. If expansion fails, it also returns s.
+func (e *Expander) ExpandIfShort(ctx context.Context, s string) string {
+	if !IsShortLink(s) {
+		return s
+	}
+	if final, err := e.Expand(ctx, s); err == nil {
+		return final
+	}
+	return s
+}
+
+func (e *Expander) remember(s, final string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.seen == nil {
+		e.seen = make(map[string]string)
+	}
+	e.seen[s] = final
+}
+
+// follow follows the redirects from s one hop at a time, and returns the URL
+// of the first response that is not a redirect.
+func (e *Expander) follow(ctx context.Context, s string) (string, error) {
+	opts := *DefaultFetchOptions
+	opts.Timeout = 0 // Expand sets its own limit
+	if e.Client != nil {
+		opts.Client = e.Client
+	}
+	noFollow := http.DefaultClient
+	if opts.Client != nil {
+		noFollow = opts.Client
+	}
+	opts.Client = &http.Client{
+		Transport:     noFollow.Transport,
+		Jar:           noFollow.Jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	limit := e.MaxRedirects
+	if limit <= 0 {
+		limit = 10
+	}
+	cur := s
+	for hop := 0; ; hop++ {
+		next, err := nextHop(ctx, &opts, "HEAD", cur)
+		if err == errNoHead {
+			next, err = nextHop(ctx, &opts, "GET", cur)
+		}
+		if err != nil {
+			return cur, err
+		} else if next == "" {
+			return cur, nil
+		} else if hop == limit {
+			return cur, ErrTooManyRedirects
+		}
+		cur = next
+	}
+}
+
+var errNoHead = errors.New("HEAD not supported")
+
+// nextHop sends a single request for s and returns the redirect target, or ""
+// if the response is not a redirect.
+func nextHop(ctx context.Context, opts *FetchOptions, method, s string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s, nil)
+	if err != nil {
+		return "", err
+	}
+	rsp, err := opts.send(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	rsp.Body.Close()
+	switch {
+	case method == "HEAD" && (rsp.StatusCode == http.StatusMethodNotAllowed ||
+		rsp.StatusCode == http.StatusNotImplemented || rsp.StatusCode == http.StatusForbidden):
+		return "", errNoHead
+	case rsp.StatusCode < 300 || rsp.StatusCode >= 400 || rsp.StatusCode == http.StatusNotModified:
+		return "", nil
+	}
+	loc, err := rsp.Location()
+	if err != nil {
+		return "", fmt.Errorf("redirect from %q: %w", s, err)
+	}
+	return loc.String(), nil
+}
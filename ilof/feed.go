@@ -20,9 +20,11 @@ const AcastFeedURL = "https://feeds.acast.com/public/shows/in-lieu-of-fun"
 
 // LoadAcastFeed fetches and parses the Acast RSS feed from url.
 func LoadAcastFeed(ctx context.Context, url string) ([]*AudioEpisode, error) {
-	p := gofeed.NewParser()
-	// Yes, the parser API has the context backward.
-	feed, err := p.ParseURLWithContext(url, ctx)
+	data, err := fetchURL(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("fetching feed: %w", err)
+	}
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("parsing feed: %w", err)
 	}
@@ -42,6 +44,62 @@ func LoadAcastFeed(ctx context.Context, url string) ([]*AudioEpisode, error) {
 	return eps, nil
 }
 
+// An AudioChange reports an episode whose recorded audio file differs from
+// the one in the feed entry for its Acast page. This happens when Acast
+// re-publishes an episode: the landing page stays the same, but the audio
+// file, and often the duration, change.
+type AudioChange struct {
+	Episode *Episode      `json:"episode"` // the episode as recorded
+	Audio   *AudioEpisode `json:"audio"`   // its current entry in the feed
+}
+
+// RepublishedAudio returns the episodes of eps whose Acast page appears in
+// audio with an audio file other than the one recorded for the episode.
+// Episodes with no recorded audio file are not reported, nor are differences
+// in duration alone, since the recorded duration may have been taken from the
+// video rather than the audio.
+func RepublishedAudio(audio []*AudioEpisode, eps []*Episode) []*AudioChange {
+	byPage := make(map[string]*AudioEpisode)
+	for _, a := range audio {
+		byPage[a.PageLink] = a
+	}
+	var out []*AudioChange
+	for _, ep := range eps {
+		if ep.AcastURL == "" || ep.AudioFileURL == "" {
+			continue
+		}
+		a, ok := byPage[NormalizeURL(ep.AcastURL)]
+		if ok && a.FileLink != "" && a.FileLink != ep.AudioFileURL {
+			out = append(out, &AudioChange{Episode: ep, Audio: a})
+		}
+	}
+	return out
+}
+
+// Apply refreshes the audio file of ep, which should be the episode file for
+// c.Episode, from the feed entry of c, and returns a description of each
+// change made. If duration is true, it also refreshes the duration; this is
+// optional because the recorded duration may have been taken from the video.
+func (c *AudioChange) Apply(ep *Episode, duration bool) []string {
+	var out []string
+	if f := c.Audio.FileLink; f != "" && f != ep.AudioFileURL {
+		out = append(out, fmt.Sprintf("audio-file: %s → %s", ep.AudioFileURL, f))
+		ep.AudioFileURL = f
+	}
+	if !duration {
+		return out
+	}
+	if d := c.Audio.Duration.Round(time.Second); d > 0 && d.String() != ep.Duration {
+		if ep.Duration == "" {
+			out = append(out, fmt.Sprintf("duration: %s", d))
+		} else {
+			out = append(out, fmt.Sprintf("duration: %s → %s", ep.Duration, d))
+		}
+		ep.Duration = d.String()
+	}
+	return out
+}
+
 func getExtensionField(ext ext.Extensions, ns, name string) string {
 	es := ext[ns][name]
 	if es == nil {
@@ -74,7 +132,7 @@ func newAudioEpisode(show string, item *gofeed.Item) (*AudioEpisode, error) {
 		Title:       item.Title,
 		RawDesc:     item.Description,
 		Description: item.Description,
-		PageLink:    item.Link,
+		PageLink:    NormalizeURL(item.Link),
 	}
 	if ps, err := parseHTML(item.Description); err == nil {
 		ep.Description = ps.Text
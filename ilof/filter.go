@@ -0,0 +1,123 @@
+package ilof
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// An EpisodeFilter selects episodes for ForEachEpisodeWith and Episodes. A
+// zero filter selects every episode.
+//
+// Episode file names begin with the air date and end with the label, as in
+// "2021-01-05-0100.md", so the date and label ranges are checked against the
+// file name before the file is loaded; files outside the ranges are not read.
+// The remaining conditions, and the ranges again, are checked against the
+// front matter of the files that are loaded.
+type EpisodeFilter struct {
+	Since Date // if not zero, only episodes aired on or after this date
+	Until Date // if not zero, only episodes aired on or before this date
+
+	From Label // if not empty, only episodes whose labels are not before this
+	To   Label // if not empty, only episodes whose labels are not after this
+
+	Season int // if positive, only episodes in this season
+
+	// If not empty, only episodes that lack a value for at least one of these
+	// fields, named as in the front matter, e.g., "duration".
+	Missing []string
+
+	// If non-nil, only episodes for which Match reports true.
+	Match func(*Episode) bool
+}
+
+// Check reports an error if f names an unknown field in Missing.
+func (f *EpisodeFilter) Check() error {
+	for _, name := range f.Missing {
+		if _, ok := episodeFields[name]; !ok {
+			return fmt.Errorf("unknown episode field %q", name)
+		}
+	}
+	return nil
+}
+
+// episodeFields maps the front matter names of the fields of an Episode to
+// their indexes.
+var episodeFields = func() map[string]int {
+	m := make(map[string]int)
+	t := reflect.TypeOf(Episode{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = strings.ToLower(t.Field(i).Name)
+		}
+		m[name] = i
+	}
+	return m
+}()
+
+// matchFile reports whether the episode file at path may be selected by f,
+// according to its name.
+func (f *EpisodeFilter) matchFile(path string) bool {
+	date, label, ok := parseEpisodeFileName(filepath.Base(path))
+	if !ok {
+		return true // cannot tell
+	}
+	return f.inDates(date) && f.inLabels(label)
+}
+
+// match reports whether ep is selected by f.
+func (f *EpisodeFilter) match(ep *Episode) bool {
+	if !f.inDates(ep.Date) || !f.inLabels(ep.Episode) {
+		return false
+	} else if f.Season > 0 && ep.Season != f.Season {
+		return false
+	}
+	if len(f.Missing) != 0 {
+		v := reflect.ValueOf(ep).Elem()
+		var missing bool
+		for _, name := range f.Missing {
+			if i, ok := episodeFields[name]; ok && v.Field(i).IsZero() {
+				missing = true
+				break
+			}
+		}
+		if !missing {
+			return false
+		}
+	}
+	return f.Match == nil || f.Match(ep)
+}
+
+func (f *EpisodeFilter) inDates(d Date) bool {
+	t := time.Time(d)
+	if !f.Since.IsZero() && t.Before(time.Time(f.Since)) {
+		return false
+	}
+	return f.Until.IsZero() || !t.After(time.Time(f.Until))
+}
+
+func (f *EpisodeFilter) inLabels(label Label) bool {
+	if f.From != "" && labelLess(label, f.From) {
+		return false
+	}
+	return f.To == "" || !labelLess(f.To, label)
+}
+
+// ForEachEpisodeWith calls f for each episode file in the given directory
+// that is selected by filter. A nil filter selects every episode. If f reports
+// an error, the traversal stops and that error is reported to the caller.
+func ForEachEpisodeWith(dir string, filter *EpisodeFilter, f func(path string, ep *Episode) error) error {
+	it := Episodes(context.Background(), dir, filter)
+	for it.Next() {
+		if err := f(it.Path(), it.Episode()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
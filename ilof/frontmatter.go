@@ -0,0 +1,139 @@
+package ilof
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// episodeKeys is the set of front matter keys that correspond to fields of
+// an Episode.
+var episodeKeys = func() map[string]bool {
+	keys := make(map[string]bool)
+	typ := reflect.TypeOf(Episode{})
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = strings.ToLower(typ.Field(i).Name)
+		}
+		keys[name] = true
+	}
+	return keys
+}()
+
+// encodeFrontMatter renders the fields of ep as YAML front matter in canonical
+// form: fields in the order of the Episode type, dates and strings unquoted
+// where possible, tags as a flow list, and two-space indentation.
+//
+// If orig is not empty, it is the previous front matter for the episode.
+// Comments in orig are carried over to the corresponding fields of the
+// output, and fields of orig not recognized as Episode fields are preserved
+// after the recognized ones.
+func encodeFrontMatter(ep *Episode, orig string) ([]byte, error) {
+	var out yaml.Node
+	if err := out.Encode(ep); err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if strings.TrimSpace(orig) != "" {
+		if err := yaml.Unmarshal([]byte(orig), &doc); err != nil {
+			return nil, fmt.Errorf("decoding front matter: %v", err)
+		}
+	}
+	if len(doc.Content) == 1 && doc.Content[0].Kind == yaml.MappingNode {
+		old := doc.Content[0]
+		out.HeadComment = old.HeadComment
+		out.FootComment = old.FootComment
+
+		// A comment before the first field usually describes the whole file,
+		// so keep it at the top regardless of which field comes first.
+		var top string
+		if len(old.Content) != 0 {
+			top = old.Content[0].HeadComment
+			old.Content[0].HeadComment = ""
+		}
+		for i := 0; i+1 < len(old.Content); i += 2 {
+			key, val := old.Content[i], old.Content[i+1]
+			if !episodeKeys[key.Value] {
+				out.Content = append(out.Content, key, val) // preserve as-is
+				continue
+			}
+			for j := 0; j+1 < len(out.Content); j += 2 {
+				if out.Content[j].Value == key.Value {
+					copyComments(out.Content[j], key)
+					copyComments(out.Content[j+1], val)
+					break
+				}
+			}
+		}
+		if top != "" && len(out.Content) != 0 {
+			out.Content[0].HeadComment = strings.TrimSpace(top + "\n" + out.Content[0].HeadComment)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&out); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func copyComments(dst, src *yaml.Node) {
+	dst.HeadComment = src.HeadComment
+	dst.LineComment = src.LineComment
+	dst.FootComment = src.FootComment
+}
+
+// FormatEpisodeFile returns the contents of the episode file data rewritten in
+// canonical form. The front matter is formatted as by WriteEpisode, and
+// trailing whitespace is removed from the lines of the body.
+func FormatEpisodeFile(data []byte) ([]byte, error) {
+	front, body, err := splitFrontMatter(data)
+	if err != nil {
+		return nil, err
+	}
+	var ep Episode
+	if err := yaml.Unmarshal([]byte(front), &ep); err != nil {
+		return nil, fmt.Errorf("decoding front matter: %v", err)
+	}
+	ep.Detail = trimLines(body)
+	return formatEpisode(&ep, front)
+}
+
+// formatEpisode renders the complete contents of an episode file for ep. See
+// encodeFrontMatter for the meaning of orig.
+func formatEpisode(ep *Episode, orig string) ([]byte, error) {
+	fm, err := encodeFrontMatter(ep, orig)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	buf.Write(fm)
+	buf.WriteString("---\n")
+	if ep.Detail != "" {
+		buf.WriteString(ep.Detail)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// trimLines removes trailing whitespace from each line of s, and leading and
+// trailing blank lines from s as a whole.
+func trimLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
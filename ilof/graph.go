@@ -0,0 +1,249 @@
+package ilof
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A GuestGraph is the co-appearance graph of the people on the show. Its
+// nodes are guests and hosts, and an edge joins two people who appeared on
+// the same episode, weighted by the number of episodes they shared.
+type GuestGraph struct {
+	Nodes []*GraphNode `json:"nodes"` // hosts first, then guests, each by name
+	Edges []*GraphEdge `json:"links"` // in decreasing order of weight
+}
+
+// A GraphNode is a person in a GuestGraph.
+type GraphNode struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`     // "host" or "guest"
+	Episodes int    `json:"episodes"` // the number of episodes the person appeared on
+}
+
+// A GraphEdge joins two people in a GuestGraph who appeared together.
+type GraphEdge struct {
+	Source   string  `json:"source"` // node ID
+	Target   string  `json:"target"` // node ID
+	Weight   int     `json:"weight"` // the number of shared episodes
+	Episodes []Label `json:"episodes"`
+}
+
+// GraphOptions control the construction of a GuestGraph. A nil *GraphOptions
+// provides default values for all fields.
+type GraphOptions struct {
+	// If true, hosts are omitted from the graph. Hosts appear on most
+	// episodes, so they tend to dominate the graph.
+	OmitHosts bool
+
+	// Edges with fewer shared episodes than this are omitted. Nodes left with
+	// no edges are kept.
+	MinWeight int
+}
+
+func (o *GraphOptions) omitHosts() bool { return o != nil && o.OmitHosts }
+
+func (o *GraphOptions) minWeight() int {
+	if o == nil {
+		return 0
+	}
+	return o.MinWeight
+}
+
+// BuildGuestGraph builds the co-appearance graph of the guests in guests and
+// the hosts recorded in eps. A guest who is also a host is one node, of kind
+// "host". Only appearances on the episodes in eps are counted.
+func BuildGuestGraph(eps []*Episode, guests []*Guest, opts *GraphOptions) *GuestGraph {
+	gidx := GuestIndex(guests)
+	nodes := make(map[string]*GraphNode) // by lower-case name
+	node := func(name, kind string) *GraphNode {
+		key := strings.ToLower(strings.TrimSpace(name))
+		n := nodes[key]
+		if n == nil {
+			n = &GraphNode{Name: strings.TrimSpace(name), Kind: kind}
+			nodes[key] = n
+		} else if kind == "host" {
+			n.Kind = kind
+		}
+		return n
+	}
+
+	type pair [2]*GraphNode
+	shared := make(map[pair][]Label)
+	for _, ep := range eps {
+		present := make(map[*GraphNode]bool)
+		if !opts.omitHosts() {
+			for _, h := range ep.Hosts {
+				present[node(h, "host")] = true
+			}
+		}
+		for _, g := range gidx[ep.Episode] {
+			present[node(g.Name, "guest")] = true
+		}
+		var ps []*GraphNode
+		for n := range present {
+			n.Episodes++
+			ps = append(ps, n)
+		}
+		sort.Slice(ps, func(i, j int) bool { return ps[i].Name < ps[j].Name })
+		for i, a := range ps {
+			for _, b := range ps[i+1:] {
+				shared[pair{a, b}] = append(shared[pair{a, b}], ep.Episode)
+			}
+		}
+	}
+
+	g := new(GuestGraph)
+	for _, n := range nodes {
+		if n.Episodes != 0 {
+			g.Nodes = append(g.Nodes, n)
+		}
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		ni, nj := g.Nodes[i], g.Nodes[j]
+		if ni.Kind != nj.Kind {
+			return ni.Kind == "host"
+		}
+		return ni.Name < nj.Name
+	})
+	for i, n := range g.Nodes {
+		n.ID = "n" + strconv.Itoa(i+1)
+	}
+	for p, labels := range shared {
+		if len(labels) < opts.minWeight() {
+			continue
+		}
+		SortLabels(labels)
+		g.Edges = append(g.Edges, &GraphEdge{
+			Source:   p[0].ID,
+			Target:   p[1].ID,
+			Weight:   len(labels),
+			Episodes: labels,
+		})
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		ei, ej := g.Edges[i], g.Edges[j]
+		if ei.Weight != ej.Weight {
+			return ei.Weight > ej.Weight
+		} else if ei.Source != ej.Source {
+			return ei.Source < ej.Source
+		}
+		return ei.Target < ej.Target
+	})
+	return g
+}
+
+// WriteJSON writes g to w as a JSON object with "nodes" and "links", in the
+// form expected by force-directed layouts such as d3-force.
+func (g *GuestGraph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(g)
+}
+
+// WriteDOT writes g to w as an undirected Graphviz graph. Hosts are drawn as
+// boxes, and the pen width of each edge grows with its weight.
+func (g *GuestGraph) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("graph ilof {\n  node [shape=ellipse];\n")
+	for _, n := range g.Nodes {
+		shape := ""
+		if n.Kind == "host" {
+			shape = ", shape=box"
+		}
+		fmt.Fprintf(&sb, "  %s [label=%s, episodes=%d%s];\n", n.ID, dotQuote(n.Name), n.Episodes, shape)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, "  %s -- %s [weight=%d, penwidth=%.1f];\n",
+			e.Source, e.Target, e.Weight, 1+math.Log2(float64(e.Weight)))
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// dotQuote quotes s as a Graphviz string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// WriteGraphML writes g to w as a GraphML document, with the name, kind, and
+// episode count of each node and the weight of each edge as attributes.
+func (g *GuestGraph) WriteGraphML(w io.Writer) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "name", For: "node", Name: "name", Type: "string"},
+			{ID: "kind", For: "node", Name: "kind", Type: "string"},
+			{ID: "episodes", For: "node", Name: "episodes", Type: "int"},
+			{ID: "weight", For: "edge", Name: "weight", Type: "int"},
+		},
+		Graph: graphMLGraph{ID: "ilof", EdgeDefault: "undirected"},
+	}
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: n.ID, Data: []graphMLData{
+			{Key: "name", Value: n.Name},
+			{Key: "kind", Value: n.Kind},
+			{Key: "episodes", Value: strconv.Itoa(n.Episodes)},
+		}})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: e.Source, Target: e.Target, Data: []graphMLData{
+			{Key: "weight", Value: strconv.Itoa(e.Weight)},
+		}})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
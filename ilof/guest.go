@@ -3,23 +3,27 @@
 package ilof
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
 
-	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/repo"
 	yaml "gopkg.in/yaml.v3"
 )
 
 // A Guest gives the name and some links for a guest.
 type Guest struct {
-	Name     string    `yaml:"name"`
-	Twitter  string    `yaml:"twitter,omitempty"`
-	URL      string    `yaml:"url,omitempty"`
-	Notes    string    `yaml:"notes,omitempty"`
-	Episodes []float64 `yaml:"episodes,flow"`
+	Name      string  `json:"name" yaml:"name"`
+	Twitter   string  `json:"twitter,omitempty" yaml:"twitter,omitempty"`
+	TwitterID string  `json:"twitterID,omitempty" yaml:"twitter-id,omitempty"` // numeric user ID, stable across renames
+	URL       string  `json:"url,omitempty" yaml:"url,omitempty"`
+	Notes     string  `json:"notes,omitempty" yaml:"notes,omitempty"`
+	Bio       string  `json:"bio,omitempty" yaml:"bio,omitempty"` // original notes, if sanitized; see GuestOptions
+	Episodes  []Label `json:"episodes" yaml:"episodes,flow"`
 }
 
 func (g *Guest) String() string {
@@ -38,7 +42,7 @@ func (g *Guest) String() string {
 }
 
 // OnEpisode reports whether g is a guest on the specified episode.
-func (g *Guest) OnEpisode(ep float64) bool {
+func (g *Guest) OnEpisode(ep Label) bool {
 	for _, v := range g.Episodes {
 		if v == ep {
 			return true
@@ -47,45 +51,52 @@ func (g *Guest) OnEpisode(ep float64) bool {
 	return false
 }
 
+// GuestIndex returns a map from episode labels to the guests who appeared on
+// those episodes, in the order they occur in guests.
+func GuestIndex(guests []*Guest) map[Label][]*Guest {
+	idx := make(map[Label][]*Guest)
+	for _, g := range guests {
+		for _, ep := range g.Episodes {
+			idx[ep] = append(idx[ep], g)
+		}
+	}
+	return idx
+}
+
 var firstNonComment = regexp.MustCompile(`(?m)^[^#]`)
 
 // AddOrUpdateGuests updates the guest list at path for the listed guests on
 // the specified episode. New entries are added if they do not already exist,
 // matched by name. Otherwise, new episode entries are added to existing
-// guests. If successful, the file at path is updated in place.
-func AddOrUpdateGuests(episode float64, path string, guests []*Guest) error {
+// guests. The notes of new entries are sanitized as specified by opts. If
+// successful, the file at path is updated in place.
+func AddOrUpdateGuests(episode Label, path string, guests []*Guest, opts *GuestOptions) error {
+	return AddOrUpdateGuestsIn(repo.OS, episode, path, guests, opts)
+}
+
+// AddOrUpdateGuestsIn is as AddOrUpdateGuests, but reads and writes the guest
+// list through wt.
+func AddOrUpdateGuestsIn(wt repo.Worktree, episode Label, path string, guests []*Guest, opts *GuestOptions) error {
 	if len(guests) == 0 {
 		return nil
 	}
 
-	data, err := os.ReadFile(path)
+	src, entries, err := loadGuestFile(wt, path)
 	if err != nil {
 		return err
 	}
 
-	// Cut off and save the comment block at the top of the file, so we can put
-	// it back when the file is updated.
-	var comments, content []byte = nil, data
-	if m := firstNonComment.FindIndex(data); m != nil {
-		comments = data[:m[0]]
-		content = data[m[0]:]
-	}
-
-	var entries []*Guest
-	if err := yaml.Unmarshal(content, &entries); err != nil {
-		return err
-	}
-
 	dirty := false
 	for _, g := range guests {
 		old := findGuest(g, entries)
 		if old == nil {
-			g.Episodes = []float64{episode}
+			sanitizeGuest(g, opts)
+			g.Episodes = []Label{episode}
 			entries = append(entries, g)
 			dirty = true
 		} else if !old.OnEpisode(episode) {
 			old.Episodes = append(old.Episodes, episode)
-			sort.Float64s(old.Episodes)
+			SortLabels(old.Episodes)
 			dirty = true
 		}
 	}
@@ -94,26 +105,246 @@ func AddOrUpdateGuests(episode float64, path string, guests []*Guest) error {
 		return nil // no changes; don't rewrite the file
 	}
 
-	out, err := atomicfile.New(path, 0644)
+	return writeGuestFile(wt, path, src, entries)
+}
+
+// LoadGuests reads the guest list at path.
+func LoadGuests(path string) ([]*Guest, error) {
+	_, entries, err := loadGuestFile(repo.OS, path)
+	return entries, err
+}
+
+// A guest list may be stored in a single file, or sharded into several files
+// in a directory, to keep the files small and reduce merge conflicts. In a
+// sharded list, each guest is stored in the shard for the first letter of
+// their name, e.g., _data/guests/a-f.yaml. Names that do not begin with a
+// letter from a to z are stored in the shard named "other.yaml". The
+// functions that read and write a guest list accept either form: if the path
+// of the list is a directory, it is sharded.
+var guestShards = []struct {
+	name   string
+	lo, hi byte
+}{
+	{"a-f.yaml", 'a', 'f'},
+	{"g-l.yaml", 'g', 'l'},
+	{"m-r.yaml", 'm', 'r'},
+	{"s-z.yaml", 's', 'z'},
+}
+
+// otherGuestShard is the shard for names not matched by guestShards.
+const otherGuestShard = "other.yaml"
+
+// GuestShard returns the file name of the shard that holds the guest with the
+// given name in a sharded guest list.
+func GuestShard(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name != "" {
+		for _, s := range guestShards {
+			if name[0] >= s.lo && name[0] <= s.hi {
+				return s.name
+			}
+		}
+	}
+	return otherGuestShard
+}
+
+// IsShardedGuestList reports whether the guest list at path is sharded, that
+// is, whether path is a directory.
+func IsShardedGuestList(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// GuestFiles returns the paths of the existing files of the guest list at
+// path: path itself if the list is a single file, or else its shards in
+// order. It reports an error if a sharded list has files that are not shards.
+func GuestFiles(path string) ([]string, error) {
+	if !IsShardedGuestList(path) {
+		return []string{path}, nil
+	}
+	shards, err := guestShardPaths(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, p := range shards {
+		if _, err := os.Stat(p); err == nil {
+			out = append(out, p)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// guestShardPaths returns the paths of all the shards of the sharded guest
+// list in dir, whether or not they exist. It reports an error if dir has
+// YAML files that are not shards, which would otherwise be ignored.
+func guestShardPaths(dir string) ([]string, error) {
+	known := map[string]bool{otherGuestShard: true}
+	var out []string
+	for _, s := range guestShards {
+		known[s.name] = true
+		out = append(out, filepath.Join(dir, s.name))
+	}
+	out = append(out, filepath.Join(dir, otherGuestShard))
+
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, de := range des {
+		if ext := filepath.Ext(de.Name()); (ext == ".yaml" || ext == ".yml") && !known[de.Name()] {
+			return nil, fmt.Errorf("%s is not a guest shard (want one of %s)",
+				filepath.Join(dir, de.Name()), strings.Join(sortedKeys(known), ", "))
+		}
+	}
+	return out, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// A guestSource records the files a guest list was loaded from, so it can be
+// written back in the same form.
+type guestSource struct {
+	sharded  bool
+	comments map[string][]byte // the comment block at the top of each file
+	files    map[*Guest]string // the file each guest was loaded from
+}
+
+// loadGuestFile reads the guest list at path in wt, which may be a single
+// file or a directory of shards.
+func loadGuestFile(wt repo.Worktree, path string) (*guestSource, []*Guest, error) {
+	src := &guestSource{
+		sharded:  IsShardedGuestList(path),
+		comments: make(map[string][]byte),
+		files:    make(map[*Guest]string),
+	}
+	if !src.sharded {
+		comments, entries, err := loadGuestData(wt, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		src.comments[path] = comments
+		return src, entries, nil
+	}
+
+	shards, err := guestShardPaths(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var all []*Guest
+	for _, shard := range shards {
+		comments, entries, err := loadGuestData(wt, shard)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", shard, err)
+		}
+		src.comments[shard] = comments
+		for _, g := range entries {
+			src.files[g] = shard
+		}
+		all = append(all, entries...)
+	}
+	return src, all, nil
+}
+
+// loadGuestData reads a single guest list file at path in wt. It returns the
+// comment block at the top of the file separately, so it can be restored
+// when the file is rewritten.
+func loadGuestData(wt repo.Worktree, path string) (comments []byte, entries []*Guest, err error) {
+	data, err := wt.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Cut off and save the comment block at the top of the file, so we can put
+	// it back when the file is updated.
+	var content []byte = data
+	if m := firstNonComment.FindIndex(data); m != nil {
+		comments = data[:m[0]]
+		content = data[m[0]:]
+	}
+
+	if err := yaml.Unmarshal(content, &entries); err != nil {
+		return nil, nil, err
+	}
+	return comments, entries, nil
+}
+
+// writeGuestFile writes entries to the guest list at path in wt, in the form
+// recorded by src, replacing the existing contents. In a sharded list, each
+// guest is written to the shard for their name, and shards whose contents
+// are unchanged are not rewritten.
+func writeGuestFile(wt repo.Worktree, path string, src *guestSource, entries []*Guest) error {
+	if !src.sharded {
+		data, err := formatGuests(src.comments[path], entries)
+		if err != nil {
+			return err
+		}
+		return wt.WriteFile(path, data, 0644)
+	}
+
+	byShard := make(map[string][]*Guest)
+	for _, g := range entries {
+		shard := filepath.Join(path, GuestShard(g.Name))
+		byShard[shard] = append(byShard[shard], g)
+	}
+	shards, err := guestShardPaths(path)
 	if err != nil {
 		return err
 	}
-	defer out.Cancel()
+	for _, shard := range shards {
+		gs := byShard[shard]
+		old, err := wt.ReadFile(shard)
+		if os.IsNotExist(err) && len(gs) == 0 {
+			continue
+		} else if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		data, err := formatGuests(src.comments[shard], gs)
+		if err != nil {
+			return err
+		} else if bytes.Equal(data, old) {
+			continue
+		}
+		if err := wt.WriteFile(shard, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatGuests formats the comment block and entries as a guest list file.
+func formatGuests(comments []byte, entries []*Guest) ([]byte, error) {
+	var out bytes.Buffer
 	out.Write(comments)
+	if len(entries) == 0 {
+		out.WriteString("[]\n")
+		return out.Bytes(), nil
+	}
 
 	// Write out each record separately, so we can keep space between them for
 	// the benefit of human readers. There must be a better way to do this.
 	for i := range entries {
 		if i > 0 {
-			fmt.Fprintln(out)
+			fmt.Fprintln(&out)
 		}
 		bits, err := yaml.Marshal(entries[i : i+1])
 		if err != nil {
-			return err
+			return nil, err
 		}
 		out.Write(bits)
 	}
-	return out.Close()
+	return out.Bytes(), nil
 }
 
 func findGuest(needle *Guest, gs []*Guest) *Guest {
@@ -126,7 +357,8 @@ func findGuest(needle *Guest, gs []*Guest) *Guest {
 }
 
 func isSameGuest(g1, g2 *Guest) bool {
-	return g1.Name == g2.Name || g1.Twitter != "" && g1.Twitter == g2.Twitter
+	return g1.Name == g2.Name || g1.Twitter != "" && g1.Twitter == g2.Twitter ||
+		g1.TwitterID != "" && g1.TwitterID == g2.TwitterID
 }
 
 func guestListsEqual(g1, g2 []*Guest) bool {
@@ -140,3 +372,165 @@ func guestListsEqual(g1, g2 []*Guest) bool {
 	}
 	return true
 }
+
+// A GuestList is a guest list loaded for editing. Changes to the list are not
+// written back to its files until Save is called.
+type GuestList struct {
+	Path   string   // the path of the guest file, or directory of shards
+	Guests []*Guest // in file order
+
+	src *guestSource
+}
+
+// OpenGuestList loads the guest list at path for editing. The list may be a
+// single file or sharded.
+func OpenGuestList(path string) (*GuestList, error) {
+	src, entries, err := loadGuestFile(repo.OS, path)
+	if err != nil {
+		return nil, err
+	}
+	return &GuestList{Path: path, Guests: entries, src: src}, nil
+}
+
+// Sharded reports whether gl is stored as a directory of shards.
+func (gl *GuestList) Sharded() bool { return gl.src.sharded }
+
+// Save writes the contents of gl back to its files.
+func (gl *GuestList) Save() error { return writeGuestFile(repo.OS, gl.Path, gl.src, gl.Guests) }
+
+// SaveAs writes the contents of gl to path, as a single file if sharded is
+// false or as a directory of shards if it is true, and makes that the path
+// of gl. The comment block of the list is carried over; when a single file
+// is sharded, each shard gets the comments of the file. The files at the
+// previous path are not removed.
+func (gl *GuestList) SaveAs(path string, sharded bool) error {
+	var comments []byte
+	if !gl.src.sharded {
+		comments = gl.src.comments[gl.Path]
+	} else if shards, err := guestShardPaths(gl.Path); err == nil {
+		comments = gl.src.comments[shards[0]]
+	}
+	src := &guestSource{sharded: sharded, comments: make(map[string][]byte)}
+	if !sharded {
+		src.comments[path] = comments
+	} else {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+		shards, err := guestShardPaths(path)
+		if err != nil {
+			return err
+		}
+		for _, shard := range shards {
+			src.comments[shard] = comments
+		}
+	}
+	if err := writeGuestFile(repo.OS, path, src, gl.Guests); err != nil {
+		return err
+	}
+	gl.Path, gl.src = path, src
+	return nil
+}
+
+// Find returns the guest whose name (ignoring case) or Twitter handle matches
+// key, or nil if there is no such guest. A handle may be given with or
+// without a leading "@".
+func (gl *GuestList) Find(key string) *Guest { return LookupGuest(gl.Guests, key) }
+
+// nameSimilarity is the metric used to compare guest names.
+var nameSimilarity = &SimilarityOpts{Metric: EditDistance}
+
+// Similar returns the guests whose names are similar to name, with a score of
+// at least min but not identical to it (ignoring case), in decreasing order of
+// similarity. This is useful for catching misspelled duplicates.
+func (gl *GuestList) Similar(name string, min float64) []*Guest {
+	type scored struct {
+		g     *Guest
+		score float64
+	}
+	var ss []scored
+	for _, g := range gl.Guests {
+		if strings.EqualFold(g.Name, name) {
+			continue
+		}
+		if s := nameSimilarity.Similarity(g.Name, name); s >= min {
+			ss = append(ss, scored{g, s})
+		}
+	}
+	sort.SliceStable(ss, func(i, j int) bool { return ss[i].score > ss[j].score })
+	out := make([]*Guest, len(ss))
+	for i, s := range ss {
+		out[i] = s.g
+	}
+	return out
+}
+
+// Add adds g to the list. It reports an error if g has the same name or
+// Twitter handle as an existing guest.
+func (gl *GuestList) Add(g *Guest) error {
+	if g.Name == "" {
+		return fmt.Errorf("missing guest name")
+	} else if old := gl.Find(g.Name); old != nil {
+		return fmt.Errorf("guest %q already exists", old.Name)
+	} else if g.Twitter != "" {
+		if old := gl.Find(g.Twitter); old != nil {
+			return fmt.Errorf("guest %q already has handle %q", old.Name, g.Twitter)
+		}
+	}
+	SortLabels(g.Episodes)
+	gl.Guests = append(gl.Guests, g)
+	return nil
+}
+
+// Merge merges the guest record drop into keep and removes drop from the
+// list. The episodes of both are combined, and any fields empty in keep are
+// copied from drop.
+func (gl *GuestList) Merge(keep, drop *Guest) error {
+	if keep == drop {
+		return fmt.Errorf("cannot merge guest %q with itself", keep.Name)
+	}
+	pos := -1
+	for i, g := range gl.Guests {
+		if g == drop {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		return fmt.Errorf("guest %q is not in the list", drop.Name)
+	}
+	for _, ep := range drop.Episodes {
+		if !keep.OnEpisode(ep) {
+			keep.Episodes = append(keep.Episodes, ep)
+		}
+	}
+	SortLabels(keep.Episodes)
+	if keep.Twitter == "" {
+		keep.Twitter = drop.Twitter
+	}
+	if keep.TwitterID == "" && strings.EqualFold(keep.Twitter, drop.Twitter) {
+		keep.TwitterID = drop.TwitterID
+	}
+	if keep.URL == "" {
+		keep.URL = drop.URL
+	}
+	if keep.Notes == "" {
+		keep.Notes = drop.Notes
+	} else if drop.Notes != "" && drop.Notes != keep.Notes {
+		keep.Notes += "\n" + drop.Notes
+	}
+	gl.Guests = append(gl.Guests[:pos], gl.Guests[pos+1:]...)
+	return nil
+}
+
+var twitterURL = regexp.MustCompile(`^(?i)https?://(?:www\.|mobile\.)?(?:twitter|x)\.com/@?(\w+)/?$`)
+
+// NormalizeHandle returns the Twitter handle s without a leading "@" or
+// surrounding whitespace. If s is a URL for a Twitter profile, the handle is
+// extracted from the URL.
+func NormalizeHandle(s string) string {
+	s = strings.TrimSpace(s)
+	if m := twitterURL.FindStringSubmatch(s); m != nil {
+		return m[1]
+	}
+	return strings.TrimPrefix(s, "@")
+}
@@ -0,0 +1,215 @@
+package ilof
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// GuestOptions are options for AddOrUpdateGuests. A nil *GuestOptions is
+// equivalent to a zero value.
+//
+// The notes of a new guest record are usually imported from the guest's
+// Twitter bio, so by default they are sanitized for the guest list: line
+// breaks are joined, and emoji, URLs, and boilerplate such as "views my own"
+// are removed. The Keep fields disable these steps.
+type GuestOptions struct {
+	KeepNewlines    bool // do not join the lines of the notes
+	KeepEmoji       bool // do not remove emoji and other pictographs
+	KeepURLs        bool // do not remove URLs
+	KeepBoilerplate bool // do not remove disclaimers such as "RTs ≠ endorsements"
+
+	// If positive, notes longer than this many characters are truncated at a
+	// word boundary and marked with an ellipsis. The tools that add guests
+	// use DefaultMaxNotes unless told otherwise.
+	MaxNotes int
+
+	// If true, and sanitizing changes the notes of a guest, the original
+	// notes are preserved in the Bio field of the record.
+	KeepBio bool
+}
+
+// DefaultMaxNotes is the length to which the tools that add guests truncate
+// their notes by default.
+const DefaultMaxNotes = 160
+
+var (
+	noteURL = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+	noteBoilerplate = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(?:all\s+)?(?:views|opinions|tweets|thoughts)\s+(?:are\s+|here\s+are\s+)?(?:my\s+own|mine)(?:\s+alone)?\b\.?`),
+		regexp.MustCompile(`(?i)\b(?:RTs?|retweets?|likes?)(?:\s*(?:&|and|/)\s*(?:RTs?|retweets?|likes?))?\s*(?:≠|!=|=/=|are\s+not|aren't|do\s+not\s+equal|don't\s+equal)\s*endorse(?:ments?)?\b\.?`),
+	}
+
+	// noteSeparators matches runs of separators between the pieces of a bio,
+	// as left behind when the text between them is removed.
+	noteSeparators = regexp.MustCompile(`\s*([|•·])(?:\s*[|•·])+\s*`)
+)
+
+// SanitizeNotes returns notes sanitized as specified by opts, as for the
+// notes of new records in AddOrUpdateGuests.
+func SanitizeNotes(notes string, opts *GuestOptions) string {
+	if opts == nil {
+		opts = new(GuestOptions)
+	}
+	s := notes
+	if !opts.KeepURLs {
+		s = noteURL.ReplaceAllString(s, "")
+	}
+	if !opts.KeepEmoji {
+		s = strings.Map(func(r rune) rune {
+			if isPictograph(r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	if !opts.KeepBoilerplate {
+		for _, re := range noteBoilerplate {
+			s = re.ReplaceAllString(s, "")
+		}
+	}
+
+	// Tidy up the spacing of each line, and the separators left behind by
+	// removals, then join the lines.
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		line = noteSeparators.ReplaceAllString(line, " $1 ")
+		line = strings.Trim(line, " |•·,;")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if opts.KeepNewlines {
+		s = strings.Join(lines, "\n")
+	} else {
+		var buf strings.Builder
+		for i, line := range lines {
+			if i > 0 {
+				if strings.ContainsAny(lines[i-1][len(lines[i-1])-1:], ".;:,!?") {
+					buf.WriteString(" ")
+				} else {
+					buf.WriteString("; ")
+				}
+			}
+			buf.WriteString(line)
+		}
+		s = buf.String()
+	}
+
+	if opts.MaxNotes > 0 {
+		s = truncateWords(s, opts.MaxNotes)
+	}
+	return s
+}
+
+// isPictograph reports whether r is an emoji or other pictograph, or a
+// character used only to modify or join them.
+func isPictograph(r rune) bool {
+	switch {
+	case unicode.Is(extendedPictographic, r):
+		return true
+	case r == 0x200d, r >= 0xfe00 && r <= 0xfe0f: // zero-width joiner, variation selectors
+		return true
+	case r == 0x20e3: // combining enclosing keycap
+		return true
+	case r >= 0x1f1e6 && r <= 0x1f1ff: // regional indicators, paired as flags
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff: // skin tone modifiers
+		return true
+	case r >= 0xe0020 && r <= 0xe007f: // tag characters, used in flags
+		return true
+	}
+	return false
+}
+
+// extendedPictographic is the set of characters with the Unicode property
+// Extended_Pictographic, from emoji-data.txt of Unicode 15. Unlike the
+// symbol category So, it does not include symbols such as ° and ≠ that
+// appear in ordinary text.
+var extendedPictographic = &unicode.RangeTable{
+	LatinOffset: 1,
+	R16: []unicode.Range16{
+		{0x00a9, 0x00ae, 5},
+		{0x203c, 0x2049, 13},
+		{0x2122, 0x2139, 23},
+		{0x2194, 0x2199, 1},
+		{0x21a9, 0x21aa, 1},
+		{0x231a, 0x231b, 1},
+		{0x2328, 0x2388, 96},
+		{0x23cf, 0x23cf, 1},
+		{0x23e9, 0x23f3, 1},
+		{0x23f8, 0x23fa, 1},
+		{0x24c2, 0x24c2, 1},
+		{0x25aa, 0x25ab, 1},
+		{0x25b6, 0x25c0, 10},
+		{0x25fb, 0x25fe, 1},
+		{0x2600, 0x2605, 1},
+		{0x2607, 0x2612, 1},
+		{0x2614, 0x2685, 1},
+		{0x2690, 0x2705, 1},
+		{0x2708, 0x2712, 1},
+		{0x2714, 0x2716, 2},
+		{0x271d, 0x2721, 4},
+		{0x2728, 0x2728, 1},
+		{0x2733, 0x2734, 1},
+		{0x2744, 0x2747, 3},
+		{0x274c, 0x274e, 2},
+		{0x2753, 0x2755, 1},
+		{0x2757, 0x2757, 1},
+		{0x2763, 0x2767, 1},
+		{0x2795, 0x2797, 1},
+		{0x27a1, 0x27b0, 15},
+		{0x27bf, 0x27bf, 1},
+		{0x2934, 0x2935, 1},
+		{0x2b05, 0x2b07, 1},
+		{0x2b1b, 0x2b1c, 1},
+		{0x2b50, 0x2b55, 5},
+		{0x3030, 0x303d, 13},
+		{0x3297, 0x3299, 2},
+	},
+	R32: []unicode.Range32{
+		{0x1f000, 0x1f0ff, 1},
+		{0x1f10d, 0x1f10f, 1},
+		{0x1f12f, 0x1f12f, 1},
+		{0x1f16c, 0x1f171, 1},
+		{0x1f17e, 0x1f17f, 1},
+		{0x1f18e, 0x1f18e, 1},
+		{0x1f191, 0x1f19a, 1},
+		{0x1f1ad, 0x1f1e5, 1},
+		{0x1f201, 0x1f20f, 1},
+		{0x1f21a, 0x1f21a, 1},
+		{0x1f22f, 0x1f22f, 1},
+		{0x1f232, 0x1f23a, 1},
+		{0x1f23c, 0x1f23f, 1},
+		{0x1f249, 0x1f3fa, 1},
+		{0x1f400, 0x1f53d, 1},
+		{0x1f546, 0x1f64f, 1},
+		{0x1f680, 0x1f6ff, 1},
+		{0x1f774, 0x1f77f, 1},
+		{0x1f7d5, 0x1f7ff, 1},
+		{0x1f80c, 0x1f80f, 1},
+		{0x1f848, 0x1f84f, 1},
+		{0x1f85a, 0x1f85f, 1},
+		{0x1f888, 0x1f88f, 1},
+		{0x1f8ae, 0x1f8ff, 1},
+		{0x1f90c, 0x1f93a, 1},
+		{0x1f93c, 0x1f945, 1},
+		{0x1f947, 0x1faff, 1},
+		{0x1fc00, 0x1fffd, 1},
+	},
+}
+
+// sanitizeGuest sanitizes the notes of a new guest record g, as specified by
+// opts.
+func sanitizeGuest(g *Guest, opts *GuestOptions) {
+	if g.Notes == "" {
+		return
+	}
+	clean := SanitizeNotes(g.Notes, opts)
+	if clean != g.Notes && opts != nil && opts.KeepBio && g.Bio == "" {
+		g.Bio = g.Notes
+	}
+	g.Notes = clean
+}
@@ -0,0 +1,136 @@
+package ilof
+
+import "sort"
+
+// HostStats summarizes the attendance of the hosts over a set of episodes.
+// Only episodes that record their hosts are counted; the hosts are the names
+// that appear in the Hosts field of any of them.
+type HostStats struct {
+	Recorded int               `json:"recorded"` // episodes that record their hosts
+	Hosts    []*HostAttendance `json:"hosts"`    // in decreasing order of attendance
+	Pairs    []*HostPair       `json:"pairs"`    // in decreasing order of count
+}
+
+// HostAttendance reports the attendance of one host.
+type HostAttendance struct {
+	Name     string  `json:"name"`
+	Episodes int     `json:"episodes"` // episodes attended
+	Rate     float64 `json:"rate"`     // fraction of recorded episodes attended
+
+	// The longest run of consecutive recorded episodes the host missed after
+	// first appearing, or nil if the host missed none.
+	LongestAbsence *Absence `json:"longestAbsence,omitempty"`
+}
+
+// An Absence is a run of consecutive recorded episodes missed by a host.
+type Absence struct {
+	Episodes int   `json:"episodes"` // the number of episodes missed
+	First    Label `json:"first"`    // the first episode missed
+	Last     Label `json:"last"`     // the last episode missed
+	Start    Date  `json:"start"`    // the air date of First
+	End      Date  `json:"end"`      // the air date of Last
+	Ongoing  bool  `json:"ongoing,omitempty"`
+}
+
+// A HostPair reports the number of episodes two hosts hosted together.
+type HostPair struct {
+	Hosts    [2]string `json:"hosts"` // in lexicographic order
+	Episodes int       `json:"episodes"`
+}
+
+// ComputeHostStats computes host attendance statistics over eps. It returns
+// nil if none of eps records its hosts.
+func ComputeHostStats(eps []*Episode) *HostStats {
+	var recorded []*Episode
+	for _, ep := range eps {
+		if len(ep.Hosts) != 0 {
+			recorded = append(recorded, ep)
+		}
+	}
+	if len(recorded) == 0 {
+		return nil
+	}
+	sort.SliceStable(recorded, func(i, j int) bool {
+		return labelLess(recorded[i].Episode, recorded[j].Episode)
+	})
+
+	st := &HostStats{Recorded: len(recorded)}
+	hosts := make(map[string]*HostAttendance)
+	current := make(map[string]*Absence) // absences in progress
+	pairs := make(map[[2]string]int)
+	for _, ep := range recorded {
+		present := make(map[string]bool)
+		for _, name := range ep.Hosts {
+			present[name] = true
+		}
+		names := make([]string, 0, len(present))
+		for name := range present {
+			names = append(names, name)
+			h := hosts[name]
+			if h == nil {
+				h = &HostAttendance{Name: name}
+				hosts[name] = h
+			}
+			h.Episodes++
+			if a := current[name]; a != nil {
+				h.noteAbsence(a)
+				delete(current, name)
+			}
+		}
+		for name := range hosts {
+			if present[name] {
+				continue
+			}
+			a := current[name]
+			if a == nil {
+				a = &Absence{First: ep.Episode, Start: ep.Date}
+				current[name] = a
+			}
+			a.Episodes++
+			a.Last, a.End = ep.Episode, ep.Date
+		}
+
+		sort.Strings(names)
+		for i, a := range names {
+			for _, b := range names[i+1:] {
+				pairs[[2]string{a, b}]++
+			}
+		}
+	}
+	for name, a := range current {
+		a.Ongoing = true
+		hosts[name].noteAbsence(a)
+	}
+
+	for _, h := range hosts {
+		h.Rate = float64(h.Episodes) / float64(st.Recorded)
+		st.Hosts = append(st.Hosts, h)
+	}
+	sort.Slice(st.Hosts, func(i, j int) bool {
+		if st.Hosts[i].Episodes != st.Hosts[j].Episodes {
+			return st.Hosts[i].Episodes > st.Hosts[j].Episodes
+		}
+		return st.Hosts[i].Name < st.Hosts[j].Name
+	})
+	for p, n := range pairs {
+		st.Pairs = append(st.Pairs, &HostPair{Hosts: p, Episodes: n})
+	}
+	sort.Slice(st.Pairs, func(i, j int) bool {
+		pi, pj := st.Pairs[i], st.Pairs[j]
+		if pi.Episodes != pj.Episodes {
+			return pi.Episodes > pj.Episodes
+		} else if pi.Hosts[0] != pj.Hosts[0] {
+			return pi.Hosts[0] < pj.Hosts[0]
+		}
+		return pi.Hosts[1] < pj.Hosts[1]
+	})
+	return st
+}
+
+// noteAbsence records a as the longest absence of h, if it is longer than
+// any so far. Ties go to the earlier absence.
+func (h *HostAttendance) noteAbsence(a *Absence) {
+	if h.LongestAbsence == nil || a.Episodes > h.LongestAbsence.Episodes {
+		h.LongestAbsence = a
+	}
+}
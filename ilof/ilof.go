@@ -4,6 +4,7 @@
 package ilof
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -11,7 +12,6 @@ import (
 	"io"
 	"log"
 	"math"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -20,12 +20,12 @@ import (
 	"strings"
 	"time"
 
-	"github.com/creachadair/atomicfile"
 	"github.com/creachadair/twitter"
 	"github.com/creachadair/twitter/jape"
 	"github.com/creachadair/twitter/query"
 	"github.com/creachadair/twitter/tweets"
 	"github.com/creachadair/twitter/types"
+	"github.com/inlieuoffun/tools/repo"
 	yaml "gopkg.in/yaml.v3"
 )
 
@@ -54,19 +54,33 @@ var KnownUsers = map[string]bool{
 
 // An Episode records details about an episode of the webcast.
 type Episode struct {
-	Episode      Label    `json:"episode"`
-	Date         Date     `json:"airDate" yaml:"date"`
-	Guests       []string `json:"guestNames,omitempty" yaml:"-"`
-	Topics       string   `json:"topics,omitempty" yaml:"topics,omitempty"`
-	CrowdcastURL string   `json:"crowdcastURL,omitempty" yaml:"crowdcast,omitempty"`
-	YouTubeURL   string   `json:"youTubeURL,omitempty" yaml:"youtube,omitempty"`
-	AcastURL     string   `json:"acastURL,omitempty" yaml:"acast,omitempty"`
-	AudioFileURL string   `json:"audioFileURL,omitempty" yaml:"audio-file,omitempty"`
-	Summary      string   `json:"summary,omitempty" yaml:"summary,omitempty"`
-	Special      bool     `json:"special,omitempty" yaml:"special,omitempty"`
-	Tags         []string `json:"tags,omitempty" yaml:"tags,flow,omitempty"`
-	Links        []*Link  `json:"links,omitempty" yaml:"links,omitempty"`
-	Detail       string   `json:"detail,omitempty" yaml:"-"`
+	Episode      Label      `json:"episode"`
+	Date         Date       `json:"airDate" yaml:"date"`
+	Season       int        `json:"season,omitempty" yaml:"season,omitempty"`
+	Guests       []string   `json:"guestNames,omitempty" yaml:"-"`
+	Hosts        []string   `json:"hosts,omitempty" yaml:"hosts,flow,omitempty"` // hosts present, if recorded
+	Topics       string     `json:"topics,omitempty" yaml:"topics,omitempty"`
+	CrowdcastURL string     `json:"crowdcastURL,omitempty" yaml:"crowdcast,omitempty"`
+	YouTubeURL   string     `json:"youTubeURL,omitempty" yaml:"youtube,omitempty"`
+	AcastURL     string     `json:"acastURL,omitempty" yaml:"acast,omitempty"`
+	AudioFileURL string     `json:"audioFileURL,omitempty" yaml:"audio-file,omitempty"`
+	Duration     string     `json:"duration,omitempty" yaml:"duration,omitempty"`
+	Transcript   string     `json:"transcript,omitempty" yaml:"transcript,omitempty"`
+	CaptionURL   string     `json:"captionURL,omitempty" yaml:"caption-url,omitempty"`
+	CaptionDate  *Date      `json:"captionDate,omitempty" yaml:"caption-date,omitempty"`
+	Summary      string     `json:"summary,omitempty" yaml:"summary,omitempty"`
+	AutoSummary  bool       `json:"autoSummary,omitempty" yaml:"auto-summary,omitempty"` // summary needs review
+	Special      bool       `json:"special,omitempty" yaml:"special,omitempty"`
+	Tags         []string   `json:"tags,omitempty" yaml:"tags,flow,omitempty"`
+	Segments     []*Segment `json:"segments,omitempty" yaml:"segments,omitempty"`
+	Links        []*Link    `json:"links,omitempty" yaml:"links,omitempty"`
+	Chapters     []*Chapter `json:"chapters,omitempty" yaml:"chapters,omitempty"`
+	Detail       string     `json:"detail,omitempty" yaml:"-"`
+}
+
+// PageURL returns the URL of the page for e on the production site.
+func (e *Episode) PageURL() string {
+	return fmt.Sprintf("%s/episode/%s", BaseURL, e.Episode)
 }
 
 // HasTag reports whether e has the specified tag.
@@ -98,6 +112,17 @@ func (x Label) Number() float64 {
 	return -1
 }
 
+// ParseLabel returns the episode label for s, with numeric labels normalized
+// as they are when read from an episode file; for example, "100.0" and "100"
+// both give the label "100".
+func ParseLabel(s string) Label {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseFloat(s, 64); err == nil && v >= 0 {
+		return Label(numToString(v))
+	}
+	return Label(s)
+}
+
 func numToString(f float64) string {
 	z, frac := math.Modf(f)
 	if frac == 0 {
@@ -129,6 +154,22 @@ func (x Label) MarshalJSON() ([]byte, error) {
 	return json.Marshal(string(x))
 }
 
+// UnmarshalYAML decodes a label from a YAML number or string. Numbers are
+// normalized as for JSON, so that 100 and 100.0 give the same label.
+func (x *Label) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: episode label must be a number or string", node.Line)
+	}
+	if tag := node.ShortTag(); tag == "!!int" || tag == "!!float" {
+		if v, err := strconv.ParseFloat(node.Value, 64); err == nil {
+			*x = Label(numToString(v))
+			return nil
+		}
+	}
+	*x = Label(node.Value)
+	return nil
+}
+
 // MarshalYAML encodes a label as a YAML number or string.
 func (x Label) MarshalYAML() (interface{}, error) {
 	if v := x.Number(); v >= 0 {
@@ -145,6 +186,9 @@ const dateFormat = "2006-01-02"
 
 func (d Date) String() string { return time.Time(d).Format(dateFormat) }
 
+// IsZero reports whether d is the zero date.
+func (d Date) IsZero() bool { return time.Time(d).IsZero() }
+
 // UnmarshalText decodes a date from a string formatted "2006-01-02".
 func (d *Date) UnmarshalText(data []byte) error {
 	ts, err := time.Parse(dateFormat, string(data))
@@ -170,9 +214,9 @@ func (d Date) MarshalText() ([]byte, error) {
 	return []byte(d.String()), nil
 }
 
-// MarshalYAML encodes a date as a YAML string.
+// MarshalYAML encodes a date as an unquoted YAML timestamp.
 func (d Date) MarshalYAML() (interface{}, error) {
-	return d.String(), nil
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: d.String()}, nil
 }
 
 // A Link records the title and URL of a hyperlink.
@@ -182,59 +226,62 @@ type Link struct {
 }
 
 // LoadEpisode loads an episode from the markdown file at path.
-func LoadEpisode(path string) (*Episode, error) {
-	data, err := os.ReadFile(path)
+func LoadEpisode(path string) (*Episode, error) { return LoadEpisodeIn(repo.OS, path) }
+
+// LoadEpisodeIn loads an episode from the markdown file at path in wt.
+func LoadEpisodeIn(wt repo.Worktree, path string) (*Episode, error) {
+	data, err := wt.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	// Hacky parse for Jekyll front matter. Actually these are YAML doc headers,
-	// but the document handling is too fiddly to bother.
-	chunks := strings.SplitN(string(data), "---\n", 3)
-	if len(chunks) != 3 || chunks[0] != "" {
-		return nil, errors.New("invalid episode file format")
+	front, body, err := splitFrontMatter(data)
+	if err != nil {
+		return nil, err
 	}
 
 	var ep Episode
-	if err := yaml.Unmarshal([]byte(chunks[1]), &ep); err != nil {
+	if err := yaml.Unmarshal([]byte(front), &ep); err != nil {
 		return nil, fmt.Errorf("decoding front matter: %v", err)
 	}
-	ep.Detail = strings.TrimSpace(chunks[2])
+	ep.Detail = strings.TrimSpace(body)
 	return &ep, nil
 }
 
-// WriteEpisode writes the specified episode to path, overwriting an existing
-// file if it exists.
-func WriteEpisode(path string, ep *Episode) error {
-	f, err := atomicfile.New(path, 0644)
-	if err != nil {
-		return err
+// splitFrontMatter splits the contents of an episode file into its front
+// matter and body.
+func splitFrontMatter(data []byte) (front, body string, _ error) {
+	// Hacky parse for Jekyll front matter. Actually these are YAML doc headers,
+	// but the document handling is too fiddly to bother.
+	chunks := strings.SplitN(string(data), "---\n", 3)
+	if len(chunks) != 3 || chunks[0] != "" {
+		return "", "", errors.New("invalid episode file format")
 	}
-	defer f.Cancel()
-	fmt.Fprintln(f, "---")
-	data, err := yaml.Marshal(ep)
+	return chunks[1], chunks[2], nil
+}
+
+// WriteEpisode writes the specified episode to path, overwriting an existing
+// file if it exists. The front matter is written in canonical form; if the
+// existing file has comments or unrecognized fields in its front matter,
+// they are preserved.
+func WriteEpisode(path string, ep *Episode) error { return WriteEpisodeIn(repo.OS, path, ep) }
+
+// WriteEpisodeIn is as WriteEpisode, but writes the episode file through wt.
+func WriteEpisodeIn(wt repo.Worktree, path string, ep *Episode) error {
+	var orig string
+	if data, err := wt.ReadFile(path); err == nil {
+		orig, _, _ = splitFrontMatter(data) // if invalid, start over
+	}
+	data, err := formatEpisode(ep, orig)
 	if err != nil {
 		return err
 	}
-	f.Write(data)
-	fmt.Fprintln(f, "---")
-	if ep.Detail != "" {
-		fmt.Fprintln(f, ep.Detail)
-	}
-	return f.Close()
+	return wt.WriteFile(path, data, 0644)
 }
 
 // LatestEpisode queries the site for the latest episode.
 func LatestEpisode(ctx context.Context) (*Episode, error) {
-	rsp, err := http.Get(BaseURL + "/latest.json")
-	if err != nil {
-		return nil, err
-	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed: %s", rsp.Status)
-	}
-	body, err := io.ReadAll(rsp.Body)
-	rsp.Body.Close()
+	body, err := fetchSite(ctx, BaseURL+"/latest.json")
 	if err != nil {
 		return nil, err
 	}
@@ -247,17 +294,26 @@ func LatestEpisode(ctx context.Context) (*Episode, error) {
 	return ep.Latest, nil
 }
 
+// NextEpisodeLabel returns the label for the next regular episode after those
+// in index, one more than the whole part of the greatest numeric label.
+// Fractional labels, as given to specials that air between regular episodes,
+// count toward the greatest label but are not themselves succeeded, so after
+// 100.5 comes 101. Non-numeric labels are ignored. Gaps in the numbering are
+// not filled, since episode numbers are never reused. If index has no numeric
+// labels, NextEpisodeLabel returns "1".
+func NextEpisodeLabel(index []*Episode) Label {
+	top := 0.0
+	for _, ep := range index {
+		if v := ep.Episode.Number(); v > top {
+			top = v
+		}
+	}
+	return Label(strconv.Itoa(int(math.Floor(top)) + 1))
+}
+
 // FetchEpisode queries the site for the specified episode.
 func FetchEpisode(ctx context.Context, num string) (*Episode, error) {
-	rsp, err := http.Get(fmt.Sprintf("%s/episode/%s.json", BaseURL, num))
-	if err != nil {
-		return nil, err
-	}
-	if rsp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed: %s", rsp.Status)
-	}
-	body, err := io.ReadAll(rsp.Body)
-	rsp.Body.Close()
+	body, err := fetchSite(ctx, fmt.Sprintf("%s/episode/%s.json", BaseURL, num))
 	if err != nil {
 		return nil, err
 	}
@@ -272,12 +328,17 @@ func FetchEpisode(ctx context.Context, num string) (*Episode, error) {
 
 // AllEpisodes queries the site for all episodes.
 func AllEpisodes(ctx context.Context) ([]*Episode, error) {
-	rsp, err := http.Get(BaseURL + "/episodes.json")
+	body, err := fetchSite(ctx, BaseURL+"/episodes.json")
 	if err != nil {
 		return nil, err
 	}
-	body, err := io.ReadAll(rsp.Body)
-	rsp.Body.Close()
+	return DecodeEpisodes(bytes.NewReader(body))
+}
+
+// DecodeEpisodes decodes a list of episodes in the format of the site's
+// episodes.json endpoint from r.
+func DecodeEpisodes(r io.Reader) ([]*Episode, error) {
+	body, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
@@ -290,36 +351,50 @@ func AllEpisodes(ctx context.Context) ([]*Episode, error) {
 	return eps.Episodes, nil
 }
 
-var epFileName = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-.*\.md$`)
+// IsEpisodeFileName reports whether name is the base name of an episode file.
+func IsEpisodeFileName(name string) bool { return repo.IsEpisodeFileName(name) }
 
 // ForEachEpisode calls f for each episode file in the given directory.
 // If f reports an error, the traversal stops and that error is reported to the
 // caller of ForEachEpisode.
 func ForEachEpisode(dir string, f func(path string, ep *Episode) error) error {
-	ls, err := os.ReadDir(dir)
+	return ForEachEpisodeWith(dir, nil, f)
+}
+
+// ForEachEpisodeFile calls f with the path of each episode file in the given
+// directory, without loading its contents. If f reports an error, the
+// traversal stops and that error is reported to the caller.
+func ForEachEpisodeFile(dir string, f func(path string) error) error {
+	names, err := repo.EpisodeFileNames(dir)
 	if err != nil {
-		return fmt.Errorf("listing episodes: %v", err)
+		return err
 	}
-	for _, elt := range ls {
-		if elt.IsDir() || !epFileName.MatchString(elt.Name()) {
-			continue // not an episode file
-		}
-		path := filepath.Join(dir, elt.Name())
-		ep, err := LoadEpisode(path)
-		if err != nil {
-			return fmt.Errorf("loading episode file: %v", err)
-		}
-		if err := f(path, ep); err != nil {
+	for _, name := range names {
+		if err := f(filepath.Join(dir, name)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// newTwitter constructs a twitter client wrapper using the given bearer token.
-func newTwitter(token string) *twitter.Client {
+// EpisodePaths returns a map from episode labels to the paths of the episode
+// files in the given directory.
+func EpisodePaths(dir string) (map[Label]string, error) {
+	paths := make(map[Label]string)
+	if err := ForEachEpisode(dir, func(path string, ep *Episode) error {
+		paths[ep.Episode] = path
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// newTwitter constructs a twitter client wrapper using the bearer tokens of
+// the given pool.
+func newTwitter(tokens *TokenPool) *twitter.Client {
 	cli := twitter.NewClient(&jape.Client{
-		Authorize: jape.BearerTokenAuthorizer(token),
+		HTTPClient: tokens.HTTPClient(),
 	})
 	v, err := strconv.Atoi(os.Getenv("TWITTER_DEBUG"))
 	if err == nil && v > 0 {
@@ -340,8 +415,21 @@ func limitBeforeToday(d Date, limit time.Duration) Date {
 }
 
 // TwitterUpdates queries Twitter for episode updates since the specified date.
-// Updates (if any) are returned in order from oldest to newest.
+// Updates (if any) are returned in order from oldest to newest. The token may
+// hold several bearer tokens separated by commas, which are rotated as their
+// rate limits are reached; see TokenPool.
 func TwitterUpdates(ctx context.Context, token string, since Date) ([]*TwitterUpdate, error) {
+	tokens, err := NewTokenPool(SplitTokens(token)...)
+	if err != nil {
+		return nil, err
+	}
+	return TwitterUpdatesWith(ctx, tokens, since)
+}
+
+// TwitterUpdatesWith is as TwitterUpdates, but uses the tokens of the given
+// pool. Long-running callers should use one pool for all their queries, so
+// that the rate limits of its tokens are tracked across queries.
+func TwitterUpdatesWith(ctx context.Context, tokens *TokenPool, since Date) ([]*TwitterUpdate, error) {
 	b := query.New()
 	query := b.And(
 		b.Or(
@@ -370,14 +458,17 @@ func TwitterUpdates(ctx context.Context, token string, since Date) ([]*TwitterUp
 		return nil, ErrNoUpdates
 	}
 
-	cli := newTwitter(token)
+	cli := newTwitter(tokens)
 	rsp, err := tweets.SearchRecent(query, &tweets.SearchOpts{
 		StartTime:  then,
 		MaxResults: 10,
 		Optional: []types.Fields{
-			types.TweetFields{CreatedAt: true, Entities: true},
+			types.TweetFields{
+				AuthorID: true, ConversationID: true, CreatedAt: true,
+				Entities: true, Language: true, Source: true,
+			},
 			types.UserFields{Description: true, ProfileURL: true, Entities: true},
-			types.Expansions{MentionUsername: true},
+			types.Expansions{AuthorID: true, MentionUsername: true},
 		},
 	}).Invoke(ctx, cli)
 	if err != nil {
@@ -393,6 +484,8 @@ func TwitterUpdates(ctx context.Context, token string, since Date) ([]*TwitterUp
 			TweetID: tw.ID,
 			Date:    time.Time(*tw.CreatedAt),
 			AirDate: time.Time(*tw.CreatedAt),
+			Post:    tw,
+			Author:  users.FindByID(tw.AuthorID),
 		}
 
 		// Try to figure out whether this is an update for the current date.
@@ -407,13 +500,16 @@ func TwitterUpdates(ctx context.Context, token string, since Date) ([]*TwitterUp
 			u := pickURL(try)
 			if u == nil {
 				continue
+			} else if IsShortLink(u.String()) {
+				if exp, err := url.Parse(DefaultExpander.ExpandIfShort(ctx, u.String())); err == nil {
+					u = exp
+				}
 			}
 			switch u.Host {
 			case "crowdcast.io", "www.crowdcast.io":
-				up.Crowdcast = u.String()
+				up.Crowdcast = NormalizeURL(u.String())
 			default:
-				yt := cleanURL(u).String()
-				if id, ok := YouTubeVideoID(yt); ok {
+				if id, ok := YouTubeVideoID(NormalizeURL(u.String())); ok {
 					up.YouTube = fmt.Sprintf("https://www.youtube.com/watch?v=%s", id)
 				}
 			}
@@ -426,8 +522,10 @@ func TwitterUpdates(ctx context.Context, token string, since Date) ([]*TwitterUp
 			}
 			g := &Guest{Twitter: m.Username}
 			if info := users.FindByUsername(m.Username); info != nil {
+				up.Mentioned = append(up.Mentioned, info)
 				g.Name = info.Name
-				g.URL = pickUserURL(info)
+				g.TwitterID = info.ID
+				g.URL = NormalizeURL(DefaultExpander.ExpandIfShort(ctx, pickUserURL(info)))
 				g.Notes = info.Description
 			}
 			up.Guests = append(up.Guests, g)
@@ -489,46 +587,68 @@ type TwitterUpdate struct {
 	YouTube   string    // if available, the YouTube stream link
 	Crowdcast string    // if available, the Crowdcast stream link
 	Guests    []*Guest  // if available, possible guest twitter handles
+
+	Post      *types.Tweet  // the announcement tweet, as reported by the API
+	Author    *types.User   // if available, the author of the tweet
+	Mentioned []*types.User // if available, the users mentioned as guests
 }
 
 // YouTubeVideoInfo returns metadata about the specified YouTube video ID.
+// To look up more than one video, use YouTubeVideosInfo.
 func YouTubeVideoInfo(ctx context.Context, id, apiKey string) (*VideoInfo, error) {
-	u, err := url.Parse("https://www.googleapis.com/youtube/v3/videos")
-	if err != nil {
-		return nil, err
-	}
-	q := make(url.Values)
-	q.Set("id", id)
-	q.Set("key", apiKey)
-	q.Set("part", "snippet")
-	u.RawQuery = q.Encode()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-	req.Header.Add("Accept", "application/json")
-	bits, err := loadRequest(ctx, req)
+	infos, err := YouTubeVideosInfo(ctx, []string{id}, apiKey)
 	if err != nil {
 		return nil, err
-	}
-
-	var msg struct {
-		Items []struct {
-			ID      string     `json:"id"`
-			Snippet *VideoInfo `json:"snippet"`
+	} else if info, ok := infos[id]; ok {
+		return info, nil
+	}
+	return nil, errors.New("id not found")
+}
+
+// YouTubeVideosInfo returns metadata about the specified YouTube video IDs,
+// keyed by ID. Videos that are not found, for example because they have been
+// deleted, are omitted. The IDs are looked up in batches of up to 50, the most
+// the API permits in one request, so that each batch costs one unit of quota.
+func YouTubeVideosInfo(ctx context.Context, ids []string, apiKey string) (map[string]*VideoInfo, error) {
+	out := make(map[string]*VideoInfo)
+	for len(ids) != 0 {
+		batch := ids[:min(len(ids), maxVideoIDs)]
+		ids = ids[len(batch):]
+
+		q := make(url.Values)
+		q.Set("id", strings.Join(batch, ","))
+		q.Set("key", apiKey)
+		q.Set("part", "snippet,contentDetails,status")
+		q.Set("maxResults", strconv.Itoa(maxVideoIDs))
+		var msg struct {
+			Items []struct {
+				ID      string     `json:"id"`
+				Snippet *VideoInfo `json:"snippet"`
+				Details struct {
+					Duration string `json:"duration"`
+				} `json:"contentDetails"`
+				Status struct {
+					Upload  string `json:"uploadStatus"`
+					Privacy string `json:"privacyStatus"`
+				} `json:"status"`
+			} `json:"items"`
 		}
-	}
-	if err := json.Unmarshal(bits, &msg); err != nil {
-		return &VideoInfo{Reply: bits}, err
-	}
-	for _, item := range msg.Items {
-		if item.ID == id {
-			item.Snippet.ID = id
-			return item.Snippet, nil
+		if err := youTubeAPI(ctx, "videos", q, &msg); err != nil {
+			return nil, err
+		}
+		for _, item := range msg.Items {
+			info := item.Snippet
+			if info == nil {
+				info = new(VideoInfo)
+			}
+			info.ID = item.ID
+			info.Duration, _ = parseISODuration(item.Details.Duration)
+			info.UploadStatus = item.Status.Upload
+			info.Privacy = item.Status.Privacy
+			out[item.ID] = info
 		}
 	}
-	return &VideoInfo{Reply: bits}, errors.New("id not found")
+	return out, nil
 }
 
 // VideoInfo carries metadata about a YouTube video.
@@ -540,7 +660,40 @@ type VideoInfo struct {
 	Title        string    `json:"title"`
 	Description  string    `json:"description"`
 
-	Reply json.RawMessage `json:"-"`
+	// These fields are filled in by YouTubeVideosInfo.
+	Duration     time.Duration `json:"-"` // zero if unknown, or for a live stream in progress
+	UploadStatus string        `json:"-"` // e.g., "processed", "deleted", "rejected"
+	Privacy      string        `json:"-"` // "public", "unlisted", or "private"
+}
+
+// Available reports whether the video can be watched by the public.
+func (v *VideoInfo) Available() bool {
+	return v.Privacy != "private" && (v.UploadStatus == "" || v.UploadStatus == "processed" || v.UploadStatus == "uploaded")
+}
+
+// isoDurationRE matches an ISO 8601 duration as reported by the YouTube API,
+// e.g., "PT1H2M3S" or "P1DT2H".
+var isoDurationRE = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseISODuration parses an ISO 8601 duration of days, hours, minutes, and
+// seconds.
+func parseISODuration(s string) (time.Duration, error) {
+	m := isoDurationRE.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, nil
 }
 
 func parseURL(u string) (*url.URL, error) {
@@ -561,20 +714,6 @@ func pickURL(u *types.URL) *url.URL {
 	return nil
 }
 
-func cleanURL(u *url.URL) *url.URL {
-	q, err := url.ParseQuery(u.RawQuery)
-	if err != nil {
-		return u
-	}
-	for key := range q {
-		if key != "v" {
-			q.Del(key)
-		}
-	}
-	u.RawQuery = q.Encode()
-	return u
-}
-
 func isSameEpisode(u1, u2 *TwitterUpdate) bool {
 	return u1.YouTube == u2.YouTube &&
 		u1.Crowdcast == u2.Crowdcast &&
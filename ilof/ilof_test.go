@@ -3,14 +3,28 @@
 package ilof_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/creachadair/twitter/types"
 	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
 )
 
 var doManual = flag.Bool("manual", false, "Run manual tests")
@@ -83,6 +97,62 @@ func TestVideoTranscript(t *testing.T) {
 	}
 }
 
+func TestPlayerCaptionTracks(t *testing.T) {
+	if !*doManual {
+		t.Skip("Skipping manual test (-manual=false)")
+	}
+
+	const videoID = "s9vNrZSRUbc"
+	tracks, err := ilof.YouTubePlayerCaptionTracks(context.Background(), videoID)
+	if err != nil {
+		t.Fatalf("Fetching caption tracks for %q failed: %v", videoID, err)
+	} else if len(tracks) == 0 {
+		t.Fatalf("No caption tracks found for %q", videoID)
+	}
+	for _, tr := range tracks {
+		t.Logf("Track %q (%s, kind %q): %s", tr.Name.Text, tr.Lang, tr.Kind, tr.URL)
+	}
+}
+
+func TestDecodePlayerCaptions(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "youtube", "player.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tracks, err := ilof.DecodePlayerCaptions(f)
+	if err != nil {
+		t.Fatalf("DecodePlayerCaptions failed: %v", err)
+	}
+	var got []string
+	for _, tr := range tracks {
+		got = append(got, fmt.Sprintf("%s/%s/%s", tr.Lang, tr.Kind, tr.Name.Text))
+	}
+	if s := strings.Join(got, "; "); s != "en/asr/English (auto-generated); en-US//English (United States) - Edited" {
+		t.Errorf("DecodePlayerCaptions: got %q", s)
+	}
+	if tr := (&ilof.CaptionOptions{Kind: "standard"}).Select(tracks); tr == nil || tr.Lang != "en-US" {
+		t.Errorf("Select: got %+v, want the en-US track", tr)
+	}
+
+	// Responses for missing and unplayable videos are reported as errors.
+	for _, tc := range []struct{ input, want string }{
+		{`{"playabilityStatus": {"status": "ERROR", "reason": "Video unavailable"}}`, "video not found"},
+		{`{"playabilityStatus": {"status": "LOGIN_REQUIRED", "reason": "Private video"}}`, "not playable: LOGIN_REQUIRED (Private video)"},
+		{`{"playabilityStatus": {"status": "OK"}}`, ""},
+		{`{}`, "missing playability status"},
+	} {
+		tracks, err := ilof.DecodePlayerCaptions(strings.NewReader(tc.input))
+		if tc.want == "" {
+			if err != nil || len(tracks) != 0 {
+				t.Errorf("DecodePlayerCaptions(%s): got %v, %v; want no tracks", tc.input, tracks, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("DecodePlayerCaptions(%s): got %v, want error %q", tc.input, err, tc.want)
+		}
+	}
+}
+
 func TestLatestEpisode(t *testing.T) {
 	if !*doManual {
 		t.Skip("Skipping manual test (-manual=false)")
@@ -121,6 +191,31 @@ Guests:    %+v`, num, up.Date.Format("2006-01-02"), up.YouTube, up.Crowdcast, up
 	}
 }
 
+func TestNextEpisodeLabel(t *testing.T) {
+	tests := []struct {
+		labels []ilof.Label
+		want   ilof.Label
+	}{
+		{nil, "1"},
+		{[]ilof.Label{"holiday"}, "1"},
+		{[]ilof.Label{"99", "100"}, "101"},
+		{[]ilof.Label{"100", "99"}, "101"},
+		{[]ilof.Label{"99", "100", "100.5"}, "101"},
+		{[]ilof.Label{"100", "holiday", "100.5", "new-years"}, "101"},
+		{[]ilof.Label{"95", "100"}, "101"},
+		{[]ilof.Label{"0.5"}, "1"},
+	}
+	for _, test := range tests {
+		var index []*ilof.Episode
+		for _, label := range test.labels {
+			index = append(index, &ilof.Episode{Episode: label})
+		}
+		if got := ilof.NextEpisodeLabel(index); got != test.want {
+			t.Errorf("NextEpisodeLabel(%q): got %q, want %q", test.labels, got, test.want)
+		}
+	}
+}
+
 func TestAcastFeed(t *testing.T) {
 	if !*doManual {
 		t.Skip("Skipping manual test (-manual=false)")
@@ -165,3 +260,3533 @@ func TestSimilarity(t *testing.T) {
 		}
 	}
 }
+
+func TestWordsWith(t *testing.T) {
+	tests := []struct {
+		input string
+		opts  *ilof.WordOptions
+		want  string
+	}{
+		{"José O’Brien's subpoenas", nil, "jos obriens subpoenas"},
+		{"José O’Brien's subpoenas", &ilof.WordOptions{Unicode: true}, "josé o'brien's subpoenas"},
+		{"José O’Brien's subpoenas", &ilof.WordOptions{Unicode: true, Stem: true}, "josé o'brien subpoena"},
+		{"the subpoena's parties", &ilof.WordOptions{Stem: true}, "the subpoena party"},
+		{"well-known (guests), 'quoted' this", &ilof.WordOptions{Unicode: true}, "well known guests quoted this"},
+		{"bus glass heroes is", &ilof.WordOptions{Stem: true}, "bus glass hero is"},
+		{"glasses boxes churches wishes houses shoes", &ilof.WordOptions{Stem: true}, "glass box church wish house shoe"},
+		{"  ", &ilof.WordOptions{Unicode: true}, ""},
+	}
+	for _, test := range tests {
+		got := strings.Join(ilof.WordsWith(test.input, test.opts), " ")
+		if got != test.want {
+			t.Errorf("WordsWith(%q, %+v): got %q, want %q", test.input, test.opts, got, test.want)
+		}
+	}
+}
+
+func TestSimilarityOpts(t *testing.T) {
+	tests := []struct {
+		metric ilof.Metric
+		a, b   string
+		want   float64
+	}{
+		{ilof.WordCoefficient, "a b", "b c", 0.5},
+		{ilof.WordJaccard, "a b", "b c", 1. / 3},
+		{ilof.WordJaccard, "", "", 1},
+		{ilof.NGramCosine, "Lawfare Live", "lawfare  live", 1},
+		{ilof.NGramCosine, "abc", "xyz", 0},
+		{ilof.EditDistance, "kitten", "sitting", 1 - 3./7},
+		{ilof.EditDistance, "Benjamin Wittes", "benjamin wittes", 1},
+		{ilof.EditDistance, "", "abc", 0},
+	}
+	for _, test := range tests {
+		opts := &ilof.SimilarityOpts{Metric: test.metric}
+		if got := opts.Similarity(test.a, test.b); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("Similarity[%v](%q, %q): got %v, want %v", test.metric, test.a, test.b, got, test.want)
+		}
+	}
+
+	// Misspellings score higher under n-grams than words.
+	ng := &ilof.SimilarityOpts{Metric: ilof.NGramCosine}
+	const a, b = "Impeachment Trial Day 3", "Impeachmnt trial, day 3"
+	if w, n := ilof.Similarity(a, b), ng.Similarity(a, b); n <= w {
+		t.Errorf("Similarity(%q, %q): n-gram %v <= word %v", a, b, n, w)
+	}
+
+	// Platform listings match across spelling fixes that cost a short title a
+	// whole word, but not a neighbouring episode or one out of range.
+	pub := time.Date(2021, 3, 4, 18, 0, 0, 0, time.UTC)
+	audio := &ilof.AudioEpisode{Title: "Episode 42: Alice Able", Published: pub}
+	fixed := &ilof.PlatformEpisode{Title: "Episode 42: Alice Abel", Published: pub.Add(time.Hour)}
+	if w := ilof.Similarity(audio.Title, fixed.Title); w >= 0.8 {
+		t.Errorf("Similarity(%q, %q): word %v, want < 0.8", audio.Title, fixed.Title, w)
+	}
+	ps := []*ilof.PlatformEpisode{
+		{Title: "Episode 43: Bob Baker", Published: pub.Add(24 * time.Hour)},
+		fixed,
+		{Title: "Episode 42: Alice Able", Published: pub.Add(30 * 24 * time.Hour)},
+	}
+	if got := ilof.FindPlatformEpisode(audio, ps); got != fixed {
+		t.Errorf("FindPlatformEpisode: got %+v, want %+v", got, fixed)
+	}
+	if got := ilof.FindPlatformEpisode(audio, []*ilof.PlatformEpisode{ps[0], ps[2]}); got != nil {
+		t.Errorf("FindPlatformEpisode: got %+v, want nil", got)
+	}
+
+	gl := &ilof.GuestList{Guests: []*ilof.Guest{
+		{Name: "Scott R. Anderson"}, {Name: "Scott Anderson"}, {Name: "Quinta Jurecic"},
+	}}
+	if got := gl.Similar("Scot Anderson", 0.7); len(got) != 2 || got[0].Name != "Scott Anderson" {
+		t.Errorf("Similar: got %v, want Scott Anderson first of 2", got)
+	}
+	if got := gl.Similar("quinta jurecic", 0.8); len(got) != 0 {
+		t.Errorf("Similar: got %v, want none for an exact match", got)
+	}
+}
+
+func TestStopwords(t *testing.T) {
+	if got := strings.Join(ilof.WordsFiltered("The judge and I don't agree on THE ruling"), " "); got != "judge agree ruling" {
+		t.Errorf("WordsFiltered: got %q, want %q", got, "judge agree ruling")
+	}
+	opts := &ilof.WordOptions{Unicode: true, Stopwords: ilof.EnglishStopwords}
+	if got := strings.Join(ilof.WordsWith("I don’t know what’s there", opts), " "); got != "know what's" {
+		t.Errorf("WordsWith: got %q, want %q", got, "know what's")
+	}
+
+	const a, b = "the judge and the jury", "on the bus and in the car"
+	if got := ilof.Similarity(a, b); got == 0 {
+		t.Errorf("Similarity(%q, %q): got 0, want nonzero", a, b)
+	}
+	if got := ilof.SimilarityWith(a, b, &ilof.WordOptions{Stopwords: ilof.EnglishStopwords}); got != 0 {
+		t.Errorf("SimilarityWith(%q, %q): got %v, want 0", a, b, got)
+	}
+}
+
+func TestMatchAudio(t *testing.T) {
+	day := func(s string) ilof.Date {
+		ts, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatalf("Invalid date %q: %v", s, err)
+		}
+		return ilof.Date(ts)
+	}
+	eps := []*ilof.Episode{
+		{Episode: "100", Date: day("2021-01-05"), Guests: []string{"Alice Able", "Bob Baker"}},
+		{Episode: "101", Date: day("2021-01-07"), Guests: []string{"Carol Cutter"}},
+		{Episode: "102", Date: day("2021-03-01"), Guests: []string{"Alice Able"}},
+	}
+	audio := &ilof.AudioEpisode{
+		Title:     "Episode 9: with Alice Able and Bob Baker",
+		Published: time.Time(day("2021-01-06")),
+	}
+
+	ms := ilof.MatchAudio(audio, eps)
+	if len(ms) != 1 {
+		t.Fatalf("MatchAudio: got %d matches, want 1", len(ms))
+	}
+	if got := ms[0].Episode.Episode; got != "100" {
+		t.Errorf("MatchAudio: got episode %q, want 100", got)
+	}
+	if _, ok := ilof.ConfidentMatch(ms, 0.3, 0.1); !ok {
+		t.Errorf("ConfidentMatch(%v): got false, want true", ms[0].Score)
+	}
+	if _, ok := ilof.ConfidentMatch(ms, 1.1, 0); ok {
+		t.Error("ConfidentMatch with min 1.1: got true, want false")
+	}
+}
+
+func TestRepublishedAudio(t *testing.T) {
+	audio := []*ilof.AudioEpisode{
+		{PageLink: "https://shows.acast.com/ilof/episodes/one", FileLink: "https://sphinx.acast.com/one-v2.mp3", Duration: 65 * time.Minute},
+		{PageLink: "https://shows.acast.com/ilof/episodes/two", FileLink: "https://sphinx.acast.com/two.mp3", Duration: time.Hour},
+		{PageLink: "https://shows.acast.com/ilof/episodes/three", FileLink: "https://sphinx.acast.com/three.mp3"},
+	}
+	eps := []*ilof.Episode{
+		{Episode: "1", AcastURL: "https://shows.acast.com/ilof/episodes/one", AudioFileURL: "https://sphinx.acast.com/one.mp3", Duration: "1h2m0s"},
+		{Episode: "2", AcastURL: "https://shows.acast.com/ilof/episodes/two", AudioFileURL: "https://sphinx.acast.com/two.mp3", Duration: "59m0s"},
+		{Episode: "3", AcastURL: "https://shows.acast.com/ilof/episodes/three"},
+		{Episode: "4", AudioFileURL: "https://sphinx.acast.com/four.mp3"},
+	}
+	cs := ilof.RepublishedAudio(audio, eps)
+	if len(cs) != 1 || cs[0].Episode != eps[0] || cs[0].Audio != audio[0] {
+		t.Fatalf("RepublishedAudio: got %+v, want episode 1", cs)
+	}
+
+	ep := *eps[0]
+	changes := cs[0].Apply(&ep, false)
+	if ep.AudioFileURL != audio[0].FileLink || ep.Duration != "1h2m0s" {
+		t.Errorf("Apply: got audio %q duration %q", ep.AudioFileURL, ep.Duration)
+	}
+	if len(changes) != 1 {
+		t.Errorf("Apply: got changes %q, want 1", changes)
+	}
+
+	ep = *eps[0]
+	changes = cs[0].Apply(&ep, true)
+	if ep.AudioFileURL != audio[0].FileLink || ep.Duration != "1h5m0s" {
+		t.Errorf("Apply with duration: got audio %q duration %q", ep.AudioFileURL, ep.Duration)
+	}
+	if len(changes) != 2 {
+		t.Errorf("Apply with duration: got changes %q, want 2", changes)
+	}
+	if again := cs[0].Apply(&ep, true); len(again) != 0 {
+		t.Errorf("Apply again: got changes %q, want none", again)
+	}
+}
+
+func TestLoadEpisodeTranscript(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.Mkdir("transcripts", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("transcripts/0001-xyzzy.json",
+		[]byte(`{"transcript":{"videoID":"xyzzy","captions":[{"start":1,"duration":2,"text":"hello"}]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ep := &ilof.Episode{Episode: "1", Transcript: "/transcripts/0001-xyzzy.json"}
+	if got, want := ep.TranscriptFile(), "transcripts/0001-xyzzy.json"; got != want {
+		t.Errorf("TranscriptFile: got %q, want %q", got, want)
+	}
+	if tr, err := ilof.LoadEpisodeTranscript(ep); err != nil {
+		t.Errorf("LoadEpisodeTranscript: unexpected error: %v", err)
+	} else if tr == nil || tr.Text() != "hello" {
+		t.Errorf("LoadEpisodeTranscript: got %+v, want one caption", tr)
+	}
+
+	for _, path := range []string{"", "https://example.com/transcript.txt"} {
+		ep := &ilof.Episode{Episode: "2", Transcript: path}
+		if tr, err := ilof.LoadEpisodeTranscript(ep); tr != nil || err != nil {
+			t.Errorf("LoadEpisodeTranscript(%q): got %+v, %v; want nil, nil", path, tr, err)
+		}
+	}
+	ep.Transcript = "transcripts/missing.json"
+	if _, err := ilof.LoadEpisodeTranscript(ep); !os.IsNotExist(err) {
+		t.Errorf("LoadEpisodeTranscript(missing): got %v, want not-exist", err)
+	}
+}
+
+func TestTranscriptFormats(t *testing.T) {
+	tr := &ilof.Transcript{
+		VideoID: "xyzzy",
+		Captions: []*ilof.Caption{
+			{Start: 0.5, Duration: 2, Text: "hello there"},
+			{Start: 3661.25, Duration: 1.5, Text: "general\n\nkenobi"},
+		},
+	}
+	tests := []struct {
+		name  string
+		write func(*strings.Builder) error
+		want  string
+	}{
+		{"SRT", func(b *strings.Builder) error { return tr.WriteSRT(b) }, `1
+00:00:00,500 --> 00:00:02,500
+hello there
+
+2
+01:01:01,250 --> 01:01:02,750
+general
+kenobi
+
+`},
+		{"VTT", func(b *strings.Builder) error { return tr.WriteVTT(b) }, `WEBVTT
+NOTE video xyzzy
+
+00:00:00.500 --> 00:00:02.500
+hello there
+
+01:01:01.250 --> 01:01:02.750
+general
+kenobi
+
+`},
+		{"Text", func(b *strings.Builder) error { return tr.WriteText(b, 12) }, "hello there\ngeneral\nkenobi\n"},
+	}
+	for _, test := range tests {
+		var buf strings.Builder
+		if err := test.write(&buf); err != nil {
+			t.Errorf("Write%s failed: %v", test.name, err)
+		} else if got := buf.String(); got != test.want {
+			t.Errorf("Write%s: got:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCaptionSelect(t *testing.T) {
+	tracks := []*ilof.CaptionTrack{
+		{URL: "fr", Lang: "fr"},
+		{URL: "en-asr", Lang: "en", Kind: "asr"},
+		{URL: "en-GB", Lang: "en-GB"},
+	}
+	tests := []struct {
+		opts *ilof.CaptionOptions
+		want string
+	}{
+		{nil, "en-asr"},
+		{&ilof.CaptionOptions{Kind: "standard"}, "en-GB"},
+		{&ilof.CaptionOptions{Lang: "fr"}, "fr"},
+		{&ilof.CaptionOptions{Lang: "de"}, ""},
+		{&ilof.CaptionOptions{Lang: "fr", Kind: "asr"}, ""},
+		{&ilof.CaptionOptions{Lang: "en-GB"}, "en-GB"},
+	}
+	for _, test := range tests {
+		got := ""
+		if t := test.opts.Select(tracks); t != nil {
+			got = t.URL
+		}
+		if got != test.want {
+			t.Errorf("Select(%+v): got %q, want %q", test.opts, got, test.want)
+		}
+	}
+}
+
+func TestSeasonOf(t *testing.T) {
+	day := func(s string) ilof.Date {
+		ts, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatalf("Invalid date %q: %v", s, err)
+		}
+		return ilof.Date(ts)
+	}
+	ss := ilof.Seasons{
+		{Season: 1, Start: day("2020-03-16")},
+		{Season: 2, Start: day("2021-01-04")},
+		{Season: 3, Start: day("2022-01-03")},
+	}
+	tests := []struct {
+		date string
+		want int
+	}{
+		{"2020-01-01", 0},
+		{"2020-03-16", 1},
+		{"2020-12-31", 1},
+		{"2021-01-04", 2},
+		{"2023-06-01", 3},
+	}
+	for _, test := range tests {
+		if got := ss.SeasonOf(day(test.date)); got != test.want {
+			t.Errorf("SeasonOf(%s): got %d, want %d", test.date, got, test.want)
+		}
+	}
+}
+
+func TestValidateEpisodeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "2021-01-05-0100.md")
+	if err := os.WriteFile(path, []byte(`---
+episode: 100
+date: 2021-01-05
+youtube: https://youtu.be/xyzzy
+tags: [cheese-night, Cheese Night]
+links:
+  - title: nothing
+---
+Body text.
+`), 0644); err != nil {
+		t.Fatalf("Writing test file: %v", err)
+	}
+
+	fs, err := ilof.ValidateEpisodeFile(path)
+	if err != nil {
+		t.Fatalf("ValidateEpisodeFile failed: %v", err)
+	}
+	type result struct {
+		Line    int
+		Field   string
+		Fixable bool
+	}
+	var got []result
+	for _, f := range fs {
+		t.Log(f)
+		got = append(got, result{f.Line, f.Field, f.Fixable})
+	}
+	want := []result{
+		{4, "youtube", true},
+		{5, "tags", true},
+		{6, "links", false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Findings: got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Finding %d: got %+v, want %+v", i+1, got[i], want[i])
+		}
+	}
+
+	ep, err := ilof.LoadEpisode(path)
+	if err != nil {
+		t.Fatalf("LoadEpisode failed: %v", err)
+	}
+	ilof.FixEpisode(ep)
+	if ep.YouTubeURL != "https://www.youtube.com/watch?v=xyzzy" {
+		t.Errorf("Fixed YouTube URL: got %q", ep.YouTubeURL)
+	}
+	if len(ep.Tags) != 1 || ep.Tags[0] != "cheese-night" {
+		t.Errorf("Fixed tags: got %+v, want [cheese-night]", ep.Tags)
+	}
+}
+
+func TestEpisodeFileNameConsistency(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, front string
+		findings    []string // fields with findings
+		wantPath    string
+		check       func(*ilof.Episode) bool
+	}{
+		{"2021-01-05-0100.md", "episode: 100\ndate: 2021-01-05", nil, "2021-01-05-0100.md", nil},
+		{"2021-01-05-100.md", "episode: 100\ndate: 2021-01-05", nil, "2021-01-05-100.md", nil},
+		{"2021-01-06-0100.md", "episode: 100\ndate: 2021-01-05", []string{"date"}, "2021-01-05-0100.md", nil},
+		{"2021-01-05-0101.md", "episode: 100\ndate: 2021-01-05", []string{"episode"}, "2021-01-05-0100.md", nil},
+		{"2021-01-04-special.md", "episode: holiday\ndate: 2021-01-05\nspecial: true", []string{"date"}, "2021-01-05-special.md", nil},
+		{"2021-01-07-0102.md", "episode: 102", []string{"date"}, "2021-01-07-0102.md", func(ep *ilof.Episode) bool {
+			return ep.Date.String() == "2021-01-07"
+		}},
+		{"2021-01-08-0103.md", "date: 2021-01-08", []string{"episode"}, "2021-01-08-0103.md", func(ep *ilof.Episode) bool {
+			return ep.Episode == "103"
+		}},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		if err := os.WriteFile(path, []byte("---\n"+test.front+"\n---\n"), 0644); err != nil {
+			t.Fatalf("Writing test file: %v", err)
+		}
+		fs, err := ilof.ValidateEpisodeFile(path)
+		if err != nil {
+			t.Fatalf("ValidateEpisodeFile(%s) failed: %v", test.name, err)
+		}
+		var got []string
+		for _, f := range fs {
+			if f.Field == "date" || f.Field == "episode" {
+				if !f.Fixable {
+					t.Errorf("%s: finding is not fixable: %v", test.name, f)
+				}
+				got = append(got, f.Field)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.findings) {
+			t.Errorf("%s: findings for %v, want %v", test.name, got, test.findings)
+		}
+
+		ep, err := ilof.LoadEpisode(path)
+		if err != nil {
+			t.Fatalf("LoadEpisode(%s) failed: %v", test.name, err)
+		}
+		newPath, changes := ilof.FixEpisodeFileName(path, ep)
+		if want := filepath.Join(dir, test.wantPath); newPath != want {
+			t.Errorf("%s: fixed path is %q, want %q", test.name, newPath, want)
+		}
+		if (len(changes) == 0) != (len(test.findings) == 0) {
+			t.Errorf("%s: got changes %q for findings %v", test.name, changes, test.findings)
+		}
+		if test.check != nil && !test.check(ep) {
+			t.Errorf("%s: fixed episode %s (%s) is wrong", test.name, ep.Episode, ep.Date)
+		}
+	}
+}
+
+func TestTextURLs(t *testing.T) {
+	got := ilof.TextURLs(`See [the book](https://example.com/book?id=1), or
+https://lawfareblog.com/x. Also <http://foo.org/a_b>. See [the
+case](https://en.wikipedia.org/wiki/Marbury_v._Madison_(1803)) (and
+https://en.wikipedia.org/wiki/Writ_(law)).`)
+	want := []string{"https://example.com/book?id=1", "https://lawfareblog.com/x", "http://foo.org/a_b",
+		"https://en.wikipedia.org/wiki/Marbury_v._Madison_(1803)", "https://en.wikipedia.org/wiki/Writ_(law)"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("TextURLs: got %q, want %q", got, want)
+	}
+}
+
+func TestScheduleUpcoming(t *testing.T) {
+	s := ilof.DefaultSchedule()
+	now := time.Date(2021, 1, 6, 23, 0, 0, 0, time.UTC) // Wed 6pm Eastern
+	got := s.Upcoming(now, 3)
+	want := []string{"2021-01-08 17:00 EST", "2021-01-11 17:00 EST", "2021-01-13 17:00 EST"}
+	if len(got) != len(want) {
+		t.Fatalf("Upcoming: got %v, want %v", got, want)
+	}
+	for i, ts := range got {
+		if s := ts.Format("2006-01-02 15:04 MST"); s != want[i] {
+			t.Errorf("Upcoming %d: got %s, want %s", i+1, s, want[i])
+		}
+	}
+
+	// Daylight saving time begins at 2am on 2021-03-14.
+	dst := ilof.Date(time.Date(2021, 3, 14, 0, 0, 0, 0, time.UTC))
+	if got, want := s.StartOn(dst).Format("2006-01-02 15:04 MST"), "2021-03-14 17:00 EDT"; got != want {
+		t.Errorf("StartOn(%v): got %s, want %s", dst, got, want)
+	}
+}
+
+func TestGuestList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guests.yaml")
+	if err := os.WriteFile(path, []byte(`# Guests
+- name: Alice Able
+  twitter: "@alice"
+  episodes: [1, 3]
+
+- name: A. Able
+  twitter: https://twitter.com/AliceA
+  notes: duplicate
+  episodes: [2]
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gl, err := ilof.OpenGuestList(path)
+	if err != nil {
+		t.Fatalf("OpenGuestList failed: %v", err)
+	}
+	keep, drop := gl.Find("alice able"), gl.Find("@alicea")
+	if keep == nil || drop == nil || keep == drop {
+		t.Fatalf("Find: got %v, %v", keep, drop)
+	}
+	if err := gl.Add(&ilof.Guest{Name: "Bob", Twitter: "alice"}); err == nil {
+		t.Error("Add with a duplicate handle: got nil error")
+	}
+	if err := gl.Merge(keep, drop); err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if err := gl.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `# Guests
+- name: Alice Able
+  twitter: '@alice'
+  notes: duplicate
+  episodes: [1, 2, 3]
+`
+	if got := string(data); got != want {
+		t.Errorf("Saved guest file:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCrowdcastExport(t *testing.T) {
+	const export = `Event Title,Start Time,Registrants,Replay URL
+"In Lieu of Fun, Episode 100",2021-01-05 17:00,"1,234",https://www.crowdcast.io/e/ilof-100
+Cheese night,2021-01-07T22:00:00Z,,
+`
+	evs, err := ilof.ParseCrowdcastExport(strings.NewReader(export), time.UTC)
+	if err != nil {
+		t.Fatalf("ParseCrowdcastExport failed: %v", err)
+	}
+	if len(evs) != 2 {
+		t.Fatalf("Got %d events, want 2", len(evs))
+	}
+	if got := evs[0].Label(); got != "100" {
+		t.Errorf("Label: got %q, want 100", got)
+	}
+	if evs[0].Registrants != 1234 || evs[0].URL != "https://www.crowdcast.io/e/ilof-100" {
+		t.Errorf("Event 1: got %+v", evs[0])
+	}
+	if got := evs[1].Label(); got != "" {
+		t.Errorf("Label: got %q, want empty", got)
+	}
+	if got := (&ilof.CrowdcastEvent{Title: "Ep. 042.0 replay"}).Label(); got != "42" {
+		t.Errorf("Label: got %q, want 42", got)
+	}
+	if want := time.Date(2021, 1, 7, 22, 0, 0, 0, time.UTC); !evs[1].Start.Equal(want) {
+		t.Errorf("Start: got %v, want %v", evs[1].Start, want)
+	}
+}
+
+func TestTagRuleMentions(t *testing.T) {
+	r := &ilof.TagRule{Tag: "game-night", Phrases: []string{"game night"}}
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"", 0},
+		{"a game of chess at night", 0},
+		{"It's GAME NIGHT!", 1},
+		{"game night, game night", 2},
+		{"endgame nights", 0},
+	}
+	for _, test := range tests {
+		if got := r.Mentions(test.input); got != test.want {
+			t.Errorf("Mentions(%q): got %d, want %d", test.input, got, test.want)
+		}
+	}
+
+	near := &ilof.TagRule{Tag: "cheese-night", Phrases: []string{"cheese night"}, Near: []string{"cheese night"}, Window: 4}
+	for _, test := range []struct {
+		input string
+		want  int
+	}{
+		{"a night of cheese", 1},
+		{"cheese night", 1},
+		{"night of fine cheese; then cheese night", 2},
+		{"cheese, and much later that night", 0},
+	} {
+		if got := near.Mentions(test.input); got != test.want {
+			t.Errorf("Mentions(%q) with Near: got %d, want %d", test.input, got, test.want)
+		}
+	}
+}
+
+func TestLoadTagRules(t *testing.T) {
+	dir := t.TempDir()
+	if rules, err := ilof.LoadTagRules(filepath.Join(dir, "missing.yaml")); err != nil {
+		t.Errorf("LoadTagRules (missing): unexpected error: %v", err)
+	} else if len(rules) != len(ilof.TagRules) {
+		t.Errorf("LoadTagRules (missing): got %d rules, want the %d built-in rules", len(rules), len(ilof.TagRules))
+	}
+
+	path := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(path, []byte(`
+- tag: wheres-the-lie
+  patterns: ["where'?s the lie"]
+  threshold: 2
+  transcript-threshold: 3
+- tag: cheese-night
+  phrases: [cheese night]
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := ilof.LoadTagRules(path)
+	if err != nil {
+		t.Fatalf("LoadTagRules: unexpected error: %v", err)
+	} else if len(rules) != 2 {
+		t.Fatalf("LoadTagRules: got %d rules, want 2", len(rules))
+	}
+	lie, cheese := rules[0], rules[1]
+	tests := []struct {
+		rule       *ilof.TagRule
+		desc, tran string
+		want       bool
+	}{
+		{lie, "Where's the lie?", "", false}, // below the threshold
+		{lie, "Wheres the lie? Where's the lie!", "", true},
+		{lie, "", "where's the lie, wheres the lie", false},
+		{lie, "", "where's the lie, wheres the lie, WHERE'S THE LIE", true},
+		{cheese, "It's cheese night", "", true},
+		{cheese, "", "cheese night, cheese night", true}, // uses the caller's minimum
+		{cheese, "", "cheese night", false},
+	}
+	for _, test := range tests {
+		if _, ok := test.rule.Match(test.desc, test.tran, 2); ok != test.want {
+			t.Errorf("Match %s (%q, %q): got %v, want %v", test.rule.Tag, test.desc, test.tran, ok, test.want)
+		}
+	}
+
+	for _, bad := range []string{"- phrases: [x]", "- tag: x", "- tag: x\n  patterns: ['(']"} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ilof.LoadTagRules(path); err == nil {
+			t.Errorf("LoadTagRules(%q): got nil error", bad)
+		}
+	}
+}
+
+func TestPhraseMatching(t *testing.T) {
+	if !ilof.ContainsPhrase("Tonight: Cheese Night!", "cheese night") {
+		t.Error("ContainsPhrase: missed an exact phrase")
+	}
+	if ilof.ContainsPhrase("a night of cheese", "cheese night") {
+		t.Error("ContainsPhrase: matched words out of order")
+	}
+	if !ilof.Near("a night of cheese", 3, "cheese", "night") {
+		t.Error("Near: missed words within the window")
+	}
+	if ilof.Near("a night of fine old cheese", 3, "cheese", "night") {
+		t.Error("Near: matched words outside the window")
+	}
+	if !ilof.Near("night, cheese", 0, "cheese", "night") || ilof.Near("night of cheese", 0, "cheese", "night") {
+		t.Error("Near: with no window, want only adjacent words to match")
+	}
+
+	// Tag rules find mentions the same way.
+	r := &ilof.TagRule{Tag: "cheese-night", Phrases: []string{"cheese night"}, Near: []string{"cheese night"}, Window: 3}
+	if n := r.Mentions("Cheese night! A night of cheese; cheese and crackers at night"); n != 2 {
+		t.Errorf("Mentions: got %d, want 2", n)
+	}
+}
+
+func TestFormatEpisodeFile(t *testing.T) {
+	const input = `---
+# About this episode.
+tags:  ["Books", cheese-night]   # check these
+date: "2021-01-09"
+episode: 102
+custom: keep me
+summary: 'Plain text.'
+---
+Body text.   
+
+`
+	const want = `---
+# About this episode.
+episode: 102
+date: 2021-01-09
+summary: Plain text.
+tags: [Books, cheese-night] # check these
+custom: keep me
+---
+Body text.
+`
+	got, err := ilof.FormatEpisodeFile([]byte(input))
+	if err != nil {
+		t.Fatalf("FormatEpisodeFile failed: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("FormatEpisodeFile:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	again, err := ilof.FormatEpisodeFile(got)
+	if err != nil {
+		t.Fatalf("FormatEpisodeFile (again) failed: %v", err)
+	} else if string(again) != string(got) {
+		t.Errorf("FormatEpisodeFile is not idempotent:\n%s", again)
+	}
+}
+
+func TestDryRunWorktree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guests.yaml")
+	const orig = "# Guests\n- name: Alice Able\n  episodes: [1]\n"
+	if err := os.WriteFile(path, []byte(orig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dry := new(repo.DryRun)
+	for _, ep := range []ilof.Label{"2", "3"} {
+		if err := ilof.AddOrUpdateGuestsIn(dry, ep, path, []*ilof.Guest{{Name: "Alice Able"}}, nil); err != nil {
+			t.Fatalf("AddOrUpdateGuestsIn(%v) failed: %v", ep, err)
+		}
+	}
+
+	// The file must not have been modified.
+	if data, err := os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if string(data) != orig {
+		t.Errorf("Guest file was modified:\n%s", data)
+	}
+
+	// Both updates must be reflected in a single recorded write.
+	ws := dry.Writes()
+	if len(ws) != 1 {
+		t.Fatalf("Got %d writes, want 1", len(ws))
+	}
+	if string(ws[0].Old) != orig || ws[0].Created || !ws[0].Changed() {
+		t.Errorf("Write: got %+v", ws[0])
+	}
+	if got := string(ws[0].New); !strings.Contains(got, "episodes: [1, 2, 3]") {
+		t.Errorf("Recorded contents:\n%s", got)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.txt")
+	newPath := filepath.Join(dir, "new.txt")
+	if err := os.WriteFile(oldPath, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checkFiles := func(want ...string) {
+		t.Helper()
+		ls, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range ls {
+			data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, e.Name()+"="+string(data))
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Files: got %q, want %q", got, want)
+		}
+	}
+	stage := func(tx *repo.Transaction) {
+		t.Helper()
+		for _, w := range []struct{ path, data string }{
+			{oldPath, "first\n"}, {newPath, "new\n"}, {oldPath, "second\n"},
+		} {
+			if err := tx.WriteFile(w.path, []byte(w.data), 0644); err != nil {
+				t.Fatalf("WriteFile %q: %v", w.path, err)
+			}
+		}
+		if data, err := tx.ReadFile(oldPath); err != nil || string(data) != "second\n" {
+			t.Errorf("ReadFile: got %q, %v; want second", data, err)
+		}
+	}
+
+	// A rolled-back transaction leaves the files unchanged.
+	tx := new(repo.Transaction)
+	stage(tx)
+	tx.Rollback()
+	checkFiles("old.txt=old\n")
+
+	// A committed transaction applies the last write to each file.
+	tx = new(repo.Transaction)
+	stage(tx)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	tx.Rollback() // no effect after commit
+	checkFiles("new.txt=new\n", "old.txt=second\n")
+	if got := tx.Paths(); len(got) != 2 {
+		t.Errorf("Paths: got %q, want 2 paths", got)
+	}
+}
+
+func TestCorpus(t *testing.T) {
+	c := ilof.EpisodeCorpus([]*ilof.Episode{
+		{Episode: "1", Summary: "Impeachment trial and the Senate vote"},
+		{Episode: "2", Summary: "Senate vote on the budget"},
+		{Episode: "3", Summary: "Subpoenas in the impeachment inquiry"},
+		{Episode: "4", Summary: "Cheese night with the Senate"},
+	})
+	c.Add("4", "A discussion of cheeses")
+	if n := c.Len(); n != 4 {
+		t.Errorf("Len: got %d, want 4", n)
+	}
+
+	rel := c.Related("3", 0)
+	if len(rel) != 1 || rel[0].Episode != "1" {
+		t.Errorf("Related(3): got %d results, want only episode 1", len(rel))
+	}
+	if rel := c.Related("1", 2); len(rel) != 2 || rel[0].Score < rel[1].Score {
+		t.Errorf("Related(1, 2): got %d results, want 2 in decreasing order", len(rel))
+	}
+
+	// A shared rare word counts for more than a shared common one.
+	rare := c.EpisodeSimilarity("1", "3")   // impeachment
+	common := c.EpisodeSimilarity("2", "4") // senate
+	if rare <= common {
+		t.Errorf("Similarity: rare term %v <= common term %v", rare, common)
+	}
+	if got := c.EpisodeSimilarity("1", "nonesuch"); got != 0 {
+		t.Errorf("Similarity with missing episode: got %v, want 0", got)
+	}
+	if got := c.Similarity("the cheese", "cheeses!"); math.Abs(got-1) > 1e-9 {
+		t.Errorf("Similarity of stemmed text: got %v, want 1", got)
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	const text = `The Supreme Court heard arguments on the emergency application.
+Our guests explained why the Supreme Court would rule quickly, and what an
+emergency application is. Also: 42 cats.`
+	got := ilof.Keywords(text, 2)
+	want := []string{"supreme court heard", "supreme court"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Keywords: got %q, want %q", got, want)
+	}
+	for _, kw := range ilof.Keywords(text, 0) {
+		if kw == "42" || kw == "the" {
+			t.Errorf("Keywords: unexpected keyword %q", kw)
+		}
+	}
+}
+
+func TestCorpusCluster(t *testing.T) {
+	c := ilof.EpisodeCorpus([]*ilof.Episode{
+		{Episode: "1", Summary: "Impeachment trial managers"},
+		{Episode: "2", Summary: "Impeachment trial witnesses"},
+		{Episode: "3", Summary: "Vaccine rollout and vaccine trials"},
+		{Episode: "4", Summary: "Vaccine distribution"},
+		{Episode: "5", Summary: "Impeachment vote"},
+	})
+	cs := c.Cluster(2)
+	if len(cs) != 2 {
+		t.Fatalf("Cluster: got %d clusters, want 2", len(cs))
+	}
+	got := labels(cs[0].Episodes) + " " + labels(cs[1].Episodes)
+	if want := "1,2,5 3,4"; got != want {
+		t.Errorf("Cluster: got %s, want %s", got, want)
+	}
+	if len(cs[0].Terms) == 0 || cs[0].Terms[0] != "impeachment" {
+		t.Errorf("Cluster terms: got %q, want impeachment first", cs[0].Terms)
+	}
+}
+
+func labels(ls []ilof.Label) string {
+	ss := make([]string, len(ls))
+	for i, l := range ls {
+		ss[i] = string(l)
+	}
+	return strings.Join(ss, ",")
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"", ""},
+		{"  not a url ", "not a url"},
+		{"HTTPS://Example.COM:443/Path?utm_source=x&b=2&a=1&fbclid=z", "https://example.com/Path?a=1&b=2"},
+		{"http://example.com:8080/", "http://example.com:8080/"},
+		{"https://youtu.be/abc123?t=60", "https://www.youtube.com/watch?t=60&v=abc123"},
+		{"https://m.youtube.com/watch?v=abc123&feature=share", "https://www.youtube.com/watch?v=abc123"},
+		{"https://www.youtube.com/live/abc123?si=xyz", "https://www.youtube.com/watch?v=abc123"},
+		{"https://mobile.twitter.com/lawfareblog/status/1?s=20&t=abc", "https://twitter.com/lawfareblog/status/1"},
+		{"https://en.m.wikipedia.org/wiki/Cheese", "https://en.wikipedia.org/wiki/Cheese"},
+		{"https://crowdcast.io/e/ilof", "https://www.crowdcast.io/e/ilof"},
+		{"https://example.com/page#section", "https://example.com/page#section"},
+	}
+	for _, test := range tests {
+		if got := ilof.NormalizeURL(test.input); got != test.want {
+			t.Errorf("NormalizeURL(%q): got %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestExpander(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/short":
+			http.Redirect(w, r, "/middle?utm_source=x", http.StatusMovedPermanently)
+		case "/middle":
+			if r.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			http.Redirect(w, r, "/final", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			fmt.Fprintln(w, "ok")
+		}
+	}))
+	defer srv.Close()
+
+	e := &ilof.Expander{MaxRedirects: 3}
+	ctx := context.Background()
+	if got, err := e.Expand(ctx, srv.URL+"/short"); err != nil || got != srv.URL+"/final" {
+		t.Errorf("Expand: got %q, %v; want %q", got, err, srv.URL+"/final")
+	}
+	if _, err := e.Expand(ctx, srv.URL+"/loop"); !errors.Is(err, ilof.ErrTooManyRedirects) {
+		t.Errorf("Expand loop: got %v, want %v", err, ilof.ErrTooManyRedirects)
+	}
+
+	// Results are cached, so a second expansion does not contact the server.
+	srv.Close()
+	if got, err := e.Expand(ctx, srv.URL+"/short"); err != nil || got != srv.URL+"/final" {
+		t.Errorf("Expand (cached): got %q, %v", got, err)
+	}
+	if got := e.ExpandIfShort(ctx, srv.URL+"/short"); got != srv.URL+"/short" {
+		t.Errorf("ExpandIfShort: got %q, want unchanged", got)
+	}
+	if !ilof.IsShortLink("https://t.co/abc") || ilof.IsShortLink("https://example.com/x") {
+		t.Error("IsShortLink gave the wrong answer")
+	}
+}
+
+func TestHostLimiter(t *testing.T) {
+	var nilLimiter *ilof.HostLimiter
+	if err := nilLimiter.Wait(context.Background(), "example.com"); err != nil {
+		t.Errorf("Wait on nil limiter: %v", err)
+	}
+
+	h := ilof.NewHostLimiter(20, 2) // one token per 50ms, burst of 2
+	h.SetHostRate("fast.example", 0, 1)
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := h.Wait(ctx, "slow.example"); err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+		if err := h.WaitURL(ctx, "https://fast.example/x"); err != nil {
+			t.Fatalf("WaitURL: %v", err)
+		}
+	}
+	// Two requests are permitted at once; the other two wait 50ms each.
+	if d := time.Since(start); d < 90*time.Millisecond || d > 2*time.Second {
+		t.Errorf("Four requests took %v, want about 100ms", d)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := h.Wait(cctx, "slow.example"); err == nil {
+		t.Error("Wait with a cancelled context: got nil error")
+	}
+}
+
+func TestCaptionCacheUnlimited(t *testing.T) {
+	const url = "https://captions.example/timedtext?v=x"
+	defer func(c ilof.ResponseCache, h *ilof.HostLimiter) {
+		ilof.CaptionCache, ilof.RateLimiter = c, h
+	}(ilof.CaptionCache, ilof.RateLimiter)
+
+	ilof.CaptionCache = ilof.DirCache(t.TempDir())
+	ilof.CaptionCache.Put(url, []byte(`<transcript><text start="1" dur="2">hi</text></transcript>`))
+
+	// Use up the only request to the host for the next several minutes; a
+	// cache hit must not wait for another.
+	ilof.RateLimiter = ilof.NewHostLimiter(0.01, 1)
+	ctx := context.Background()
+	if err := ilof.RateLimiter.Wait(ctx, "captions.example"); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	cap, err := ilof.YouTubeCaptionData(ctx, url)
+	if err != nil {
+		t.Fatalf("YouTubeCaptionData: %v", err)
+	}
+	if len(cap.Captions) != 1 || cap.Captions[0].Text != "hi" {
+		t.Errorf("Captions: got %+v, want one caption %q", cap.Captions, "hi")
+	}
+}
+
+func TestCaptionCacheSignedURLs(t *testing.T) {
+	var numFetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numFetches++
+		fmt.Fprint(w, `<transcript><text start="1" dur="2">hi</text></transcript>`)
+	}))
+	defer srv.Close()
+	defer func(c ilof.ResponseCache) { ilof.CaptionCache = c }(ilof.CaptionCache)
+	ilof.CaptionCache = ilof.DirCache(t.TempDir())
+
+	// The same track, as signed for two different watch page fetches.
+	signed := func(ei, expire, sig string) string {
+		return srv.URL + "/api/timedtext?v=abc&ei=" + ei + "&caps=asr&hl=en&ip=0.0.0.0&ipbits=0&expire=" + expire +
+			"&sparams=ip,ipbits,expire,v,ei,caps&signature=" + sig + "&key=yt8&kind=asr&lang=en"
+	}
+	ctx := context.Background()
+	for _, u := range []string{
+		signed("first", "1722054000", "AAA.111"),
+		signed("second", "1722140400", "BBB.222"),
+	} {
+		cap, err := ilof.YouTubeCaptionData(ctx, u)
+		if err != nil {
+			t.Fatalf("YouTubeCaptionData: %v", err)
+		}
+		if len(cap.Captions) != 1 {
+			t.Errorf("Captions: got %d, want 1", len(cap.Captions))
+		}
+	}
+	if numFetches != 1 {
+		t.Errorf("Got %d fetches, want 1 (the second URL should hit the cache)", numFetches)
+	}
+
+	// A different track of the same video is not a hit.
+	if _, err := ilof.YouTubeCaptionData(ctx, srv.URL+"/api/timedtext?v=abc&kind=asr&lang=fr&expire=1&signature=C"); err != nil {
+		t.Fatalf("YouTubeCaptionData: %v", err)
+	}
+	if numFetches != 2 {
+		t.Errorf("Got %d fetches, want 2 (another language should miss)", numFetches)
+	}
+}
+
+func TestFetchOptions(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ua := r.Header.Get("User-Agent"); ua != "ilof-test" {
+			t.Errorf("User-Agent: got %q, want ilof-test", ua)
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	save := ilof.DefaultFetchOptions
+	defer func() { ilof.DefaultFetchOptions = save }()
+	ilof.DefaultFetchOptions = &ilof.FetchOptions{
+		Timeout: 10 * time.Second,
+		Header:  http.Header{"User-Agent": {"ilof-test"}},
+	}
+
+	ctx := context.Background()
+	cli := &ilof.ChatClient{BaseURL: srv.URL, Model: "test"}
+	if got, err := cli.Complete(ctx, "sys", "hi"); err != nil || got != "ok" {
+		t.Errorf("Complete: got %q, %v; want ok", got, err)
+	}
+
+	// Statuses other than 200 are rejected unless accepted.
+	status = http.StatusAccepted
+	if _, err := cli.Complete(ctx, "sys", "hi"); err == nil {
+		t.Error("Complete with status 202: got nil error")
+	}
+	ilof.DefaultFetchOptions.AcceptStatus = []int{http.StatusOK, http.StatusAccepted}
+	if _, err := cli.Complete(ctx, "sys", "hi"); err != nil {
+		t.Errorf("Complete with status 202 accepted: %v", err)
+	}
+
+	// Bodies over the size limit are rejected.
+	ilof.DefaultFetchOptions.MaxBodySize = 10
+	if _, err := cli.Complete(ctx, "sys", "hi"); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("Complete with a small size limit: got %v, want too large", err)
+	}
+
+	// Link checks and webhooks use the configured client, and failed GET and
+	// HEAD requests are retried.
+	var fails, sent int
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ua := r.Header.Get("User-Agent"); ua != "ilof-test" {
+			t.Errorf("User-Agent: got %q, want ilof-test", ua)
+		}
+		if r.Method != "POST" && fails < 1 {
+			fails++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer flaky.Close()
+	ilof.DefaultFetchOptions.Retries = 1
+	ilof.DefaultFetchOptions.Client = &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			sent++
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}
+	if st := ilof.CheckLink(ctx, flaky.URL); st.Status != http.StatusOK {
+		t.Errorf("CheckLink after a failure: got %+v, want status 200", st)
+	}
+	if err := ilof.Notify(ctx, flaky.URL, "hi", nil); err != nil {
+		t.Errorf("Notify: %v", err)
+	}
+	if sent != 3 {
+		t.Errorf("Configured client sent %d requests, want 3", sent)
+	}
+}
+
+func TestWordFreq(t *testing.T) {
+	f := ilof.NewWordFreq(&ilof.FreqOptions{MaxPhrase: 2})
+	f.Add("The cheese night was great. Cheese night is the best night!")
+	g := ilof.NewWordFreq(&ilof.FreqOptions{MaxPhrase: 2})
+	g.Add("We had a game night, not a cheese night.")
+	f.Merge(g)
+
+	if f.Len() != 2 {
+		t.Errorf("Len: got %d, want 2", f.Len())
+	}
+	top := f.Top(3)
+	var got []string
+	for _, wc := range top {
+		got = append(got, fmt.Sprintf("%s:%d/%d", wc.Text, wc.Count, wc.Texts))
+	}
+	want := "night:5/2 cheese:3/2 cheese night:3/2"
+	if s := strings.Join(got, " "); s != want {
+		t.Errorf("Top(3): got %q, want %q", s, want)
+	}
+	if top[0].Weight != 1 || top[1].Weight != 0.6 {
+		t.Errorf("Weights: got %v, %v; want 1, 0.6", top[0].Weight, top[1].Weight)
+	}
+
+	// Stopwords and short words are not counted, and phrases do not span
+	// punctuation or stopwords.
+	for _, wc := range f.Top(0) {
+		switch wc.Text {
+		case "the", "was", "great cheese", "night cheese", "had":
+			t.Errorf("Top: unexpected term %q", wc.Text)
+		}
+	}
+	if c := f.Cloud("test", 1); c.Title != "test" || c.Texts != 2 || len(c.Terms) != 1 {
+		t.Errorf("Cloud: got %+v", c)
+	}
+}
+
+func TestTranscriptQuotes(t *testing.T) {
+	tr := &ilof.Transcript{Captions: []*ilof.Caption{
+		{Start: 0, Duration: 4, Text: "[Music] Welcome to the show. So, um, what do"},
+		{Start: 4, Duration: 4, Text: "you think about it? Yeah. And then we went"},
+		{Start: 8, Duration: 6, Text: "home after that. >> Constitutional law is the greatest"},
+		{Start: 14, Duration: 4, Text: "invention of eighteenth century political philosophy!"},
+		{Start: 18, Duration: 4, Text: "Welcome to the show."},
+	}}
+	qs := tr.Quotes(0, &ilof.QuoteOptions{MinWords: 3})
+	var got []string
+	for _, q := range qs {
+		got = append(got, q.Text)
+	}
+	want := []string{
+		"Constitutional law is the greatest invention of eighteenth century political philosophy!",
+		"Welcome to the show.",
+		"And then we went home after that.",
+		"So, what do you think about it?",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Quotes:\n got %q\nwant %q", got, want)
+	}
+	if q := qs[0]; q.Start != 10.25 || q.End != 18 {
+		t.Errorf("Quote span: got %v–%v, want 10.25–18", q.Start, q.End)
+	}
+	if qs := tr.Quotes(1, nil); len(qs) != 1 {
+		t.Errorf("Quotes(1): got %d, want 1", len(qs))
+	}
+}
+
+func TestHeuristicExtractor(t *testing.T) {
+	ex := &ilof.HeuristicExtractor{Dictionary: map[string]ilof.EntityKind{
+		"Benjamin Wittes": ilof.PersonEntity,
+	}}
+	const text = `Ben wittes and Benjamin Wittes talk with Judge Jane Doe about
+Brown v. Board of Education and what the Court said in Roe v. Wade, and the
+new book *The Federalist Papers*. In re Gault came up too. Last Tuesday
+Jane Doe wrote a memoir called "Life on the Bench".`
+
+	es, err := ex.Extract(context.Background(), text)
+	if err != nil {
+		t.Fatalf("Extract: unexpected error: %v", err)
+	}
+	var got []string
+	for _, e := range es {
+		got = append(got, fmt.Sprintf("%s:%s:%d", e.Kind, e.Name, e.Count))
+	}
+	want := []string{
+		"book:Life on the Bench:1",
+		"book:The Federalist Papers:1",
+		"case:Brown v. Board of Education:1",
+		"case:In re Gault:1",
+		"case:Roe v. Wade:1",
+		"person:Jane Doe:2",
+		"person:Benjamin Wittes:1",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Extract:\n got %q\nwant %q", got, want)
+	}
+
+	merged := ilof.MergeEntities(es, []*ilof.Entity{{Kind: ilof.PersonEntity, Name: "Benjamin Wittes", Count: 2}})
+	for _, e := range merged {
+		if e.Name == "Benjamin Wittes" && e.Count != 3 {
+			t.Errorf("MergeEntities: got count %d, want 3", e.Count)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	es := ilof.EpisodeSchema()
+	if p := es.Properties["youtube"]; p == nil || p.Format != "uri" {
+		t.Errorf("Episode schema youtube: got %+v, want a uri", p)
+	}
+	if _, ok := es.Properties["guestNames"]; ok {
+		t.Error("Episode schema includes a field not stored in YAML")
+	}
+	if got := strings.Join(es.Required, ","); got != "episode,date" {
+		t.Errorf("Episode schema required: got %q, want episode,date", got)
+	}
+
+	const front = `episode: 10
+date: 2021-02-30
+youtube: https://www.youtube.com/watch?v=x
+tags: [a, b]
+links:
+  - title: no url
+season: two
+`
+	var got []string
+	for _, f := range es.ValidateYAML("ep.md", []byte(front), 1) {
+		got = append(got, fmt.Sprintf("%d:%d:%s", f.Line, f.Column, f.Field))
+	}
+	want := "3:7:date 7:5:links[0] 8:9:season"
+	if s := strings.Join(got, " "); s != want {
+		t.Errorf("ValidateYAML: got %q, want %q", s, want)
+	}
+
+	gs := ilof.GuestSchema()
+	fs := gs.ValidateYAML("guests.yaml", []byte("- name: A\n  episodes: [1, 2]\n- name: B\n  episodes: [{x: 1}]\n"), 0)
+	if len(fs) != 1 || fs[0].Line != 4 || fs[0].Column != 14 {
+		t.Errorf("ValidateYAML guests: got %v, want one finding at 4:14", fs)
+	}
+	if fs := gs.ValidateYAML("guests.yaml", []byte("name: [\n"), 0); len(fs) != 1 || fs[0].Line == 0 {
+		t.Errorf("ValidateYAML invalid: got %v, want one finding with a line", fs)
+	}
+}
+
+func TestEpisodeCard(t *testing.T) {
+	ep := &ilof.Episode{
+		Episode: "100",
+		Guests:  []string{"Alice", "Bob", "Carol"},
+		Detail:  "\n\nWe discuss *cheese* and [books](https://example.com/books).\n\nMore later.",
+	}
+	c := ilof.EpisodeCard(ep)
+	if want := "In Lieu of Fun, Episode 100: Alice, Bob, and Carol"; c.Title != want {
+		t.Errorf("Title: got %q, want %q", c.Title, want)
+	}
+	if want := "We discuss cheese and books."; c.Description != want {
+		t.Errorf("Description: got %q, want %q", c.Description, want)
+	}
+	if c.Image != "" || c.Card != "summary" || c.Type != "article" {
+		t.Errorf("Card without video: got image %q, card %q, type %q", c.Image, c.Card, c.Type)
+	}
+
+	ep.YouTubeURL = "https://www.youtube.com/watch?v=abc"
+	ep.Summary = strings.Repeat("word ", 100)
+	c = ilof.EpisodeCard(ep)
+	if c.Image != "https://i.ytimg.com/vi/abc/hqdefault.jpg" || c.Card != "summary_large_image" {
+		t.Errorf("Card with video: got image %q, card %q", c.Image, c.Card)
+	}
+	if n := len([]rune(c.Description)); n > 200 || !strings.HasSuffix(c.Description, "word…") {
+		t.Errorf("Long description: got %d runes %q", n, c.Description)
+	}
+}
+
+func TestReading(t *testing.T) {
+	tests := []struct {
+		input      string
+		kind, want string
+		isbn       string
+	}{
+		{"https://www.amazon.com/Some-Book-Title/dp/0393609391/ref=sr_1_1?tag=x", "book", "https://www.amazon.com/dp/0393609391", "0393609391"},
+		{"https://bookshop.org/a/1234/9780593133354", "book", "https://bookshop.org/book/9780593133354", "9780593133354"},
+		{"https://press.uchicago.edu/ucp/books/book/chicago/T/bo123.html", "book", "https://press.uchicago.edu/ucp/books/book/chicago/T/bo123.html", ""},
+		{"https://www.lawfareblog.com/some-article?utm_source=x", "article", "https://www.lawfareblog.com/some-article", ""},
+		{"https://www.nytimes.com/", "", "", ""},
+		{"https://example.com/book", "", "", ""},
+	}
+	for _, test := range tests {
+		item, ok := ilof.ClassifyReading(test.input)
+		if test.kind == "" {
+			if ok {
+				t.Errorf("ClassifyReading(%q): got %+v, want no item", test.input, item)
+			}
+			continue
+		}
+		if !ok || string(item.Kind) != test.kind || item.URL != test.want || item.ISBN != test.isbn {
+			t.Errorf("ClassifyReading(%q): got %+v, want %s %q isbn %q", test.input, item, test.kind, test.want, test.isbn)
+		}
+	}
+
+	ep1 := &ilof.Episode{
+		Episode: "1",
+		Links:   []*ilof.Link{{Title: "The Book", URL: "https://amazon.com/dp/0393609391"}},
+		Detail:  "Read [an *article*](https://lawfareblog.com/x) and https://www.amazon.com/gp/product/0393609391.",
+	}
+	ep2 := &ilof.Episode{Episode: "2", Detail: "See https://www.lawfareblog.com/x."}
+	r1 := ilof.EpisodeReading(ep1)
+	if len(r1) != 2 || r1[0].Title != "The Book" || r1[1].Title != "an article" {
+		t.Fatalf("EpisodeReading: got %+v, want a book and an article", r1)
+	}
+	cat := ilof.MergeReading(r1, ilof.EpisodeReading(ep2))
+	if len(cat) != 2 || cat[0].Kind != ilof.ArticleReading || len(cat[0].Episodes) != 2 || len(cat[1].Episodes) != 1 {
+		t.Errorf("MergeReading: got %+v", cat)
+	}
+}
+
+func TestLinkTitles(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plain":
+			fmt.Fprint(w, "<html><head><title>\n  A Plain   Page\n</title></head><body>hi</body></html>")
+		case "/og":
+			fmt.Fprint(w, `<html><head><title>Site | Story</title>`+
+				`<meta property="og:title" content="The Story"></head><body></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ep := &ilof.Episode{Links: []*ilof.Link{
+		{URL: srv.URL + "/plain"},
+		{URL: srv.URL + "/og"},
+		{Title: "Kept", URL: srv.URL + "/og?fbclid=abc"},
+		{URL: srv.URL + "/missing"},
+		{Title: "Given", URL: srv.URL + "/plain?utm_source=x"},
+	}}
+	links, removed := ilof.DedupLinks(ep.Links)
+	if len(links) != 3 || len(removed) != 2 {
+		t.Fatalf("DedupLinks: got %d links, %d removed; want 3, 2", len(links), len(removed))
+	}
+	if links[0].Title != "Given" {
+		t.Errorf("DedupLinks: first title is %q, want Given", links[0].Title)
+	}
+	if links[1].Title != "Kept" {
+		t.Errorf("DedupLinks: second title is %q, want Kept", links[1].Title)
+	}
+
+	ep = &ilof.Episode{Links: []*ilof.Link{
+		{URL: srv.URL + "/plain"},
+		{URL: srv.URL + "/og"},
+		{URL: srv.URL + "/missing"},
+		{Title: "Given", URL: srv.URL + "/other"},
+	}}
+	added, errs := ilof.FillLinkTitles(context.Background(), ep)
+	if len(added) != 2 || len(errs) != 1 {
+		t.Errorf("FillLinkTitles: got %d added, %v; want 2 added, 1 error", len(added), errs)
+	}
+	want := []string{"A Plain Page", "The Story", "", "Given"}
+	for i, link := range ep.Links {
+		if link.Title != want[i] {
+			t.Errorf("Link %d title: got %q, want %q", i+1, link.Title, want[i])
+		}
+	}
+}
+
+func TestDescriptionSummary(t *testing.T) {
+	tests := []struct {
+		desc, want string
+	}{
+		{"", ""},
+		{"Links:\nhttps://example.com", ""},
+		{"\n\nBen and Kate talk with\n*Alice Able* about [courts](https://x.com).\n\nSecond paragraph.",
+			"Ben and Kate talk with Alice Able about courts."},
+		{"Tonight's guests discuss the news.\nLinks:\n- https://example.com/a",
+			"Tonight's guests discuss the news."},
+		{"A short episode.\nhttps://example.com\nMore text.", "A short episode."},
+		{"A chapter list follows.\n00:00 Intro\n05:12 Courts", "A chapter list follows."},
+		{"Tonight's guests are:\nAlice Able and Bob Baker.\nThings we mentioned:\nThe court",
+			"Tonight's guests are: Alice Able and Bob Baker."},
+		{"About the news.\nShow notes\nMore text.", "About the news."},
+
+		// Long paragraphs are cut to whole sentences.
+		{strings.Repeat("This sentence has exactly seven words here. ", 20),
+			strings.TrimSpace(strings.Repeat("This sentence has exactly seven words here. ", 9))},
+	}
+	for _, tc := range tests {
+		if got := ilof.DescriptionSummary(tc.desc); got != tc.want {
+			t.Errorf("DescriptionSummary(%q):\ngot  %q\nwant %q", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestCorpusKeyPhrases(t *testing.T) {
+	c := ilof.EpisodeCorpus([]*ilof.Episode{
+		{Episode: "1", Summary: "Ben and Kate talk about the supreme court"},
+		{Episode: "2", Summary: "Ben and Kate talk about the election"},
+		{Episode: "3", Summary: "Ben and Kate talk about the filibuster"},
+	})
+	text := "Ben and Kate talk about the filibuster. The filibuster rules; the Senate filibuster. Ben."
+	got := c.KeyPhrases(text, 3)
+	if len(got) == 0 || got[0] != "filibuster" {
+		t.Errorf("Corpus.KeyPhrases: got %q, want filibuster first", got)
+	}
+	for _, key := range got {
+		if key == "ben" {
+			t.Errorf("Corpus.KeyPhrases: unexpected phrase %q in %q", key, got)
+		}
+	}
+}
+
+func TestVideoTimesAirTime(t *testing.T) {
+	pub := time.Date(2021, 1, 6, 2, 0, 0, 0, time.UTC)
+	sched := time.Date(2021, 1, 5, 22, 0, 0, 0, time.UTC)
+	start := time.Date(2021, 1, 5, 22, 3, 0, 0, time.UTC)
+	tests := []struct {
+		v    ilof.VideoTimes
+		want time.Time
+	}{
+		{ilof.VideoTimes{Published: pub}, pub},
+		{ilof.VideoTimes{Published: pub, Scheduled: sched}, sched},
+		{ilof.VideoTimes{Published: pub, Scheduled: sched, ActualStart: start}, start},
+	}
+	for _, tc := range tests {
+		if got := tc.v.AirTime(); !got.Equal(tc.want) {
+			t.Errorf("AirTime(%+v): got %v, want %v", tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for _, ep := range []struct{ label, date, extra string }{
+		{"1", "2020-12-30", ""}, // Wednesday, before check-since
+		{"2", "2021-01-04", ""}, // Monday
+		{"3", "2021-01-06", ""}, // Wednesday
+		{"4", "2021-01-05", ""}, // Tuesday, and before episode 3
+		{"5", "2021-02-01", ""}, // Monday, but a long gap
+		{"6", "2021-02-02", "special: true\n"},
+		{"7", "2021-02-03", ""}, // Wednesday, but skipped
+	} {
+		path := filepath.Join(dir, ep.date+"-"+ep.label+".md")
+		text := "---\nepisode: " + ep.label + "\ndate: " + ep.date + "\n" + ep.extra + "---\n"
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatalf("Writing test file: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	schedPath := filepath.Join(dir, "schedule.yaml")
+	if err := os.WriteFile(schedPath, []byte(`days: [Monday, Wednesday, Friday]
+start: "17:00"
+timezone: America/New_York
+skip: [2021-02-03]
+check-since: 2021-01-01
+max-gap: 14
+`), 0644); err != nil {
+		t.Fatalf("Writing schedule: %v", err)
+	}
+	s, err := ilof.LoadSchedule(schedPath)
+	if err != nil {
+		t.Fatalf("LoadSchedule failed: %v", err)
+	}
+
+	var got []string
+	for _, f := range ilof.ValidateSchedule(s, paths) {
+		t.Log(f)
+		if f.Line != 3 || f.Field != "date" {
+			t.Errorf("Finding %v: wrong line or field", f)
+		}
+		got = append(got, filepath.Base(f.Path)+": "+f.Message)
+	}
+	want := []string{
+		"2021-01-05-4.md: 2021-01-05 is a Tuesday, not a scheduled show day",
+		"2021-01-05-4.md: airs on or before episode 3 (2021-01-06)",
+		"2021-02-01-5.md: airs 27 days after episode 4 (2021-01-05)",
+		"2021-02-03-7.md: 2021-02-03 is a skipped day in the schedule",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ValidateSchedule:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestMatchVideo(t *testing.T) {
+	air := time.Date(2021, 1, 5, 0, 0, 0, 0, time.UTC)
+	ep := &ilof.Episode{
+		Episode: "100",
+		Date:    ilof.Date(air),
+		Guests:  []string{"Alice Able"},
+		Topics:  "Cheese and the courts",
+	}
+	videos := []*ilof.VideoInfo{
+		{ID: "numbered", Title: "In Lieu of Fun, Episode 100", PublishedAt: air.Add(22 * time.Hour)},
+		{ID: "guest", Title: "Alice Able on cheese", PublishedAt: air.Add(-12 * time.Hour)},
+		{ID: "other", Title: "In Lieu of Fun, Episode 101: Alice Able", PublishedAt: air.Add(48 * time.Hour)},
+		{ID: "late", Title: "Episode 100", PublishedAt: air.Add(30 * 24 * time.Hour)},
+		{ID: "unrelated", Title: "Bloopers", PublishedAt: air},
+	}
+	ms := ilof.MatchVideo(ep, videos)
+	var got []string
+	for _, m := range ms {
+		t.Logf("%s: %.3f", m.Video.ID, m.Score)
+		got = append(got, m.Video.ID)
+	}
+	if want := []string{"numbered", "guest"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("MatchVideo: got %q, want %q", got, want)
+	}
+}
+
+func TestCrowdcastSlug(t *testing.T) {
+	tests := []struct {
+		input, want string
+		ok          bool
+	}{
+		{"https://www.crowdcast.io/e/ilof-100", "ilof-100", true},
+		{"https://www.crowdcast.io/e/ilof-100/register?x=1", "ilof-100", true},
+		{"https://crowdcast.io/c/ilof-100/", "ilof-100", true},
+		{"https://lawfare.crowdcast.io/e/ilof-100", "ilof-100", true},
+		{"https://www.crowdcast.io/lawfare", "", false},
+		{"https://www.crowdcast.io/e/", "", false},
+		{"https://example.com/e/ilof-100", "", false},
+	}
+	for _, tc := range tests {
+		got, ok := ilof.CrowdcastSlug(tc.input)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("CrowdcastSlug(%q): got %q, %v; want %q, %v", tc.input, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	if !ilof.IsWaybackURL("https://web.archive.org/web/20210105000000/https://www.crowdcast.io/e/ilof-100") {
+		t.Error("IsWaybackURL: snapshot URL not recognized")
+	}
+	if ilof.IsWaybackURL("https://www.crowdcast.io/e/ilof-100") {
+		t.Error("IsWaybackURL: Crowdcast URL recognized as a snapshot")
+	}
+}
+
+func TestEmbeddingIndex(t *testing.T) {
+	tr := &ilof.Transcript{Captions: []*ilof.Caption{
+		{Start: 0, Text: "welcome to the show"},
+		{Start: 4, Text: "tonight we discuss packing the supreme court"},
+		{Start: 9, Text: "with more justices"},
+		{Start: 12, Text: "and later we eat some cheese"},
+	}}
+	chunks := ilof.TranscriptChunks("100", tr, 6, 2)
+	var starts []float64
+	for _, c := range chunks {
+		t.Logf("%.0f: %s", c.Start, c.Text)
+		starts = append(starts, c.Start)
+	}
+	if len(chunks) < 3 || chunks[0].Start != 0 || chunks[len(chunks)-1].Start != 12 {
+		t.Errorf("TranscriptChunks: got starts %v", starts)
+	}
+
+	ctx := context.Background()
+	emb := ilof.HashEmbedder{Dim: 256}
+	idx := ilof.NewEmbeddingIndex(emb)
+	if err := idx.Add(ctx, emb, "100", chunks); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	other := []*ilof.Chunk{{Episode: "101", Start: 30, Text: "a long talk about cheese and wine"}}
+	if err := idx.Add(ctx, emb, "101", other); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if !idx.Current("100", chunks) || idx.Current("101", chunks) {
+		t.Error("Current: wrong result")
+	}
+
+	// The index survives a round trip through a file.
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := idx.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	idx, err := ilof.LoadEmbeddingIndex(path)
+	if err != nil {
+		t.Fatalf("LoadEmbeddingIndex failed: %v", err)
+	}
+
+	ms, err := idx.Search(ctx, emb, "court packing justices", 1, 0)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(ms) != 1 || ms[0].Episode != "100" || !strings.Contains(ms[0].Text, "court") {
+		t.Errorf("Search court: got %+v", ms)
+	}
+	ms, err = idx.Search(ctx, emb, "cheese", 0, 1)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(ms) != 2 || ms[0].Episode == ms[1].Episode {
+		t.Errorf("Search cheese: got %d matches, want 1 from each episode", len(ms))
+	}
+	if _, err := idx.Search(ctx, ilof.HashEmbedder{Dim: 128}, "cheese", 1, 0); err == nil {
+		t.Error("Search with a different embedder: got nil error")
+	}
+}
+
+func TestEmbeddingClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		fmt.Fprint(w, `{"data":[`)
+		for i := range req.Input {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			// Reply out of order, to check that indexes are respected.
+			j := len(req.Input) - 1 - i
+			fmt.Fprintf(w, `{"index":%d,"embedding":[%d,1]}`, j, j)
+		}
+		fmt.Fprint(w, `]}`)
+	}))
+	defer srv.Close()
+
+	c := &ilof.EmbeddingClient{BaseURL: srv.URL + "/v1", Model: "test"}
+	vecs, err := c.Embed(context.Background(), []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	for i, v := range vecs {
+		if len(v) != 2 || v[0] != float32(i) {
+			t.Errorf("Vector %d: got %v", i, v)
+		}
+	}
+}
+
+func TestDataset(t *testing.T) {
+	day := func(s string) ilof.Date {
+		ts, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatalf("Invalid date %q: %v", s, err)
+		}
+		return ilof.Date(ts)
+	}
+	items := []*ilof.DatasetItem{{
+		Episode: &ilof.Episode{Episode: "101", Date: day("2021-01-07"), YouTubeURL: "https://youtu.be/xyzzy"},
+		Transcript: &ilof.Transcript{Captions: []*ilof.Caption{
+			{Start: 0, Duration: 2, Text: "[Music]"},
+			{Start: 2, Duration: 3.5, Text: "BEN WITTES: welcome  to the show"},
+			{Start: 5.5, Duration: 2, Text: "it's a good one (laughs)"},
+			{Start: 7.5, Duration: 2, Text: ">> thanks for having me"},
+		}},
+	}, {
+		Episode:    &ilof.Episode{Episode: "100", Date: day("2021-01-05"), Guests: []string{"Alice Able"}},
+		Transcript: &ilof.Transcript{VideoID: "abc", Captions: []*ilof.Caption{{Start: 1, Duration: 1, Text: "hello"}}},
+	}}
+	var buf1, buf2 bytes.Buffer
+	hdr, err := ilof.WriteDataset(&buf1, items)
+	if err != nil {
+		t.Fatalf("WriteDataset failed: %v", err)
+	}
+	if hdr.Episodes != 2 || hdr.Segments != 4 {
+		t.Errorf("Header: got %d episodes, %d segments; want 2, 4", hdr.Episodes, hdr.Segments)
+	}
+
+	// The output does not depend on the order of the items.
+	if _, err := ilof.WriteDataset(&buf2, []*ilof.DatasetItem{items[1], items[0]}); err != nil {
+		t.Fatalf("WriteDataset failed: %v", err)
+	} else if buf1.String() != buf2.String() {
+		t.Error("WriteDataset: output depends on the order of items")
+	}
+
+	data := buf1.String()
+	_, eps, err := ilof.ReadDataset(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadDataset failed: %v", err)
+	}
+	if len(eps) != 2 || eps[0].Episode != "100" || eps[0].VideoID != "abc" || eps[1].VideoID != "xyzzy" {
+		t.Fatalf("ReadDataset: got episodes %+v", eps)
+	}
+	type seg struct {
+		Start   float64
+		Turn    int
+		Speaker string
+		Text    string
+	}
+	var got []seg
+	for _, s := range eps[1].Text {
+		got = append(got, seg{s.Start, s.Turn, s.Speaker, s.Text})
+	}
+	want := []seg{
+		{2, 1, "BEN WITTES", "welcome to the show"},
+		{5.5, 1, "BEN WITTES", "it's a good one"},
+		{7.5, 2, "", "thanks for having me"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Segments:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	// Tampering is detected.
+	bad := strings.Replace(data, "hello", "jello", 1)
+	if _, _, err := ilof.ReadDataset(strings.NewReader(bad)); err == nil {
+		t.Error("ReadDataset of a modified corpus: got nil error")
+	}
+}
+
+func TestAnnouncement(t *testing.T) {
+	if a := new(ilof.TwitterUpdate).Announcement("1"); a != nil {
+		t.Errorf("Announcement without a post: got %+v, want nil", a)
+	}
+
+	posted := time.Date(2021, 1, 5, 14, 30, 0, 0, time.UTC)
+	up := &ilof.TwitterUpdate{
+		TweetID: "12345",
+		Post: &types.Tweet{
+			ID:        "12345",
+			Text:      "Tonight on @inlieuoffunshow: @alice",
+			AuthorID:  "99",
+			CreatedAt: &posted,
+		},
+		Author:    &types.User{ID: "99", Username: "benjaminwittes", Name: "Benjamin Wittes"},
+		Mentioned: []*types.User{{ID: "7", Username: "alice", Name: "Alice Able"}},
+	}
+	dir := t.TempDir()
+	path, err := ilof.WriteAnnouncementIn(repo.OS, dir, up.Announcement("100"))
+	if err != nil {
+		t.Fatalf("WriteAnnouncementIn failed: %v", err)
+	} else if want := filepath.Join(dir, "100.json"); path != want {
+		t.Errorf("Path: got %q, want %q", path, want)
+	}
+
+	a, err := ilof.LoadAnnouncement(path)
+	if err != nil {
+		t.Fatalf("LoadAnnouncement failed: %v", err)
+	}
+	if a.Episode != "100" || a.Platform != "twitter" {
+		t.Errorf("Announcement: got episode %q platform %q", a.Episode, a.Platform)
+	}
+	if want := "https://twitter.com/benjaminwittes/status/12345"; a.URL != want {
+		t.Errorf("URL: got %q, want %q", a.URL, want)
+	}
+	if a.Post == nil || a.Post.Text != up.Post.Text || !a.Post.CreatedAt.Equal(posted) {
+		t.Errorf("Post: got %+v, want %+v", a.Post, up.Post)
+	}
+	if a.Author == nil || a.Author.Name != "Benjamin Wittes" {
+		t.Errorf("Author: got %+v", a.Author)
+	}
+	if len(a.Mentioned) != 1 || a.Mentioned[0].Username != "alice" {
+		t.Errorf("Mentioned: got %+v", a.Mentioned)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(os.Mkdir("_data", 0755))
+	must(os.WriteFile("_data/a.json", []byte(`{"a":1}`), 0644))
+	must(os.WriteFile("_data/b.json", []byte(`{"b":2}`), 0644))
+	outside := filepath.Join(t.TempDir(), "c.json")
+	must(os.WriteFile(outside, []byte(`{}`), 0644))
+
+	const mpath = "_data/manifest.json"
+	must(ilof.RecordGenerated(mpath, "test", "_data/a.json", filepath.Join(dir, "_data/b.json"), outside))
+	m, err := ilof.LoadManifest(mpath)
+	must(err)
+	if len(m.Files) != 2 || m.Files["_data/a.json"] == nil || m.Files["_data/b.json"] == nil {
+		t.Fatalf("Manifest files: got %+v, want _data/a.json and _data/b.json", m.Files)
+	}
+	if e := m.Files["_data/a.json"]; e.Generator != "test" || e.Version == "" || e.Generated.IsZero() {
+		t.Errorf("Entry: got %+v", e)
+	}
+	if probs, err := m.Verify(); err != nil || len(probs) != 0 {
+		t.Errorf("Verify: got %v, %v; want no problems", probs, err)
+	}
+
+	// Regenerating the same contents does not change the entry.
+	old := *m.Files["_data/a.json"]
+	must(ilof.RecordGenerated(mpath, "test", "_data/a.json"))
+	m, err = ilof.LoadManifest(mpath)
+	must(err)
+	if got := *m.Files["_data/a.json"]; got != old {
+		t.Errorf("Entry after regenerating: got %+v, want %+v", got, old)
+	}
+
+	must(os.WriteFile("_data/a.json", []byte(`{"a":"edited"}`), 0644))
+	must(os.Remove("_data/b.json"))
+	probs, err := m.Verify()
+	must(err)
+	var got []string
+	for _, p := range probs {
+		got = append(got, p.Path+" "+p.Problem)
+	}
+	if want := []string{"_data/a.json modified", "_data/b.json missing"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Verify: got %q, want %q", got, want)
+	}
+	if got, want := m.Generated("test"), []string{"_data/a.json", "_data/b.json"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Generated: got %q, want %q", got, want)
+	}
+	if got := m.Generated("other"); len(got) != 0 {
+		t.Errorf("Generated(other): got %q, want none", got)
+	}
+}
+
+func TestCredentialLoader(t *testing.T) {
+	vals, err := ilof.ParseCredentialFile([]byte(`# credentials
+export TWITTER_TOKEN=file-token
+YOUTUBE_API_KEY = "yt\tkey"
+LLM_API_KEY='single quoted'
+
+EMBED_API_URL=
+`))
+	if err != nil {
+		t.Fatalf("ParseCredentialFile failed: %v", err)
+	}
+	if got := vals["YOUTUBE_API_KEY"]; got != "yt\tkey" {
+		t.Errorf("Double-quoted value: got %q", got)
+	}
+	if got := vals["LLM_API_KEY"]; got != "single quoted" {
+		t.Errorf("Single-quoted value: got %q", got)
+	}
+	if _, err := ilof.ParseCredentialFile([]byte("OK=1\nnot a line\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ParseCredentialFile of a bad line: got %v, want an error for line 2", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "credentials.env")
+	if err := os.WriteFile(path, []byte("TWITTER_TOKEN=file-token\nYOUTUBE_API_KEY=file-key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	l := &ilof.CredentialLoader{
+		LookupEnv: func(name string) (string, bool) {
+			if name == "TWITTER_TOKEN" {
+				return "env-token", true
+			}
+			return "", false
+		},
+		File: path,
+		Keychain: func(name string) (string, error) {
+			if name == "LLM_API_KEY" {
+				return "chain-key", nil
+			}
+			return "", nil
+		},
+	}
+	tests := []struct {
+		cred        *ilof.Credential
+		value, from string
+	}{
+		{ilof.TwitterToken, "env-token", "environment"},
+		{ilof.YouTubeAPIKey, "file-key", path},
+		{ilof.LLMAPIKey, "chain-key", "keychain"},
+	}
+	for _, tc := range tests {
+		v, src, err := l.Lookup(tc.cred)
+		if err != nil || v != tc.value || src != tc.from {
+			t.Errorf("Lookup(%s): got %q, %q, %v; want %q, %q", tc.cred.Name, v, src, err, tc.value, tc.from)
+		}
+	}
+
+	_, _, err = l.Lookup(ilof.SpotifyClientID)
+	var missing *ilof.MissingCredentialError
+	if !errors.As(err, &missing) {
+		t.Fatalf("Lookup of a missing credential: got %v, want MissingCredentialError", err)
+	}
+	msg := err.Error()
+	for _, want := range []string{"SPOTIFY_CLIENT_ID", path, "keychain", "developer.spotify.com"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Missing credential error %q does not mention %q", msg, want)
+		}
+	}
+}
+
+func TestTokenPool(t *testing.T) {
+	if _, err := ilof.NewTokenPool(ilof.SplitTokens(" , ")...); err == nil {
+		t.Error("NewTokenPool with no tokens: got nil error")
+	}
+	if got := ilof.SplitTokens("aaa, bbb\tccc"); fmt.Sprint(got) != "[aaa bbb ccc]" {
+		t.Errorf("SplitTokens: got %q", got)
+	}
+
+	// Token "one" is rate limited for an hour; token "two" is not limited.
+	reset := time.Now().Add(time.Hour).Unix()
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		seen = append(seen, auth)
+		if auth == "token-one" {
+			w.Header().Set("x-rate-limit-remaining", "0")
+			w.Header().Set("x-rate-limit-reset", fmt.Sprint(reset))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("x-rate-limit-remaining", "99")
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	p, err := ilof.NewTokenPool("token-one", "token-two", "token-one")
+	if err != nil {
+		t.Fatalf("NewTokenPool failed: %v", err)
+	} else if p.Len() != 2 {
+		t.Errorf("Len: got %d, want 2", p.Len())
+	}
+	cli := p.HTTPClient()
+	for i := 0; i < 2; i++ {
+		rsp, err := cli.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			t.Errorf("Get %d: got status %d, want 200", i+1, rsp.StatusCode)
+		}
+	}
+	// The first request is retried with the second token, which is then used
+	// for the second request.
+	if want := "[token-one token-two token-two]"; fmt.Sprint(seen) != want {
+		t.Errorf("Tokens used: got %v, want %v", seen, want)
+	}
+	st := p.Stats()
+	if st[0].Limited != 1 || st[0].Remaining != 0 || st[0].Reset.Unix() != reset {
+		t.Errorf("Stats for token 1: got %+v", st[0])
+	}
+	if st[1].Requests != 2 || st[1].Remaining != 99 || strings.Contains(st[1].Token, "token") {
+		t.Errorf("Stats for token 2: got %+v", st[1])
+	}
+
+	// With every token limited, a request waits until its context ends.
+	p, _ = ilof.NewTokenPool("token-one")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	if rsp, err := p.HTTPClient().Do(req); err == nil {
+		rsp.Body.Close()
+		t.Errorf("Get with all tokens limited: got status %d, want error", rsp.StatusCode)
+	}
+}
+
+func TestShardedGuests(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "guests")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a-f.yaml", "# Guests A-F\n- name: Alice Able\n  episodes: [1]\n\n- name: Zoe Zed\n  episodes: [2]\n")
+	write("m-r.yaml", "- name: Mary Moe\n  episodes: [3]\n")
+
+	for _, tc := range []struct{ name, want string }{
+		{"Alice", "a-f.yaml"}, {"bob", "a-f.yaml"}, {"Hal", "g-l.yaml"},
+		{"Ruth", "m-r.yaml"}, {"Zoe", "s-z.yaml"}, {"3M", "other.yaml"}, {"", "other.yaml"},
+	} {
+		if got := ilof.GuestShard(tc.name); got != tc.want {
+			t.Errorf("GuestShard(%q): got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	gs, err := ilof.LoadGuests(dir)
+	if err != nil {
+		t.Fatalf("LoadGuests failed: %v", err)
+	} else if len(gs) != 3 {
+		t.Fatalf("LoadGuests: got %d guests, want 3", len(gs))
+	}
+
+	// A guest in the wrong shard is reported, and fixed by moving it.
+	fs, err := ilof.ValidateGuestFile(dir)
+	if err != nil {
+		t.Fatalf("ValidateGuestFile failed: %v", err)
+	} else if len(fs) != 1 || !strings.Contains(fs[0].Message, "belongs in shard s-z.yaml") {
+		t.Errorf("ValidateGuestFile: got %v, want a misplaced guest", fs)
+	}
+	if _, err := ilof.FixGuestFile(dir); err != nil {
+		t.Fatalf("FixGuestFile failed: %v", err)
+	}
+
+	// New guests are added to their shards; shards not changed are not written.
+	if err := ilof.AddOrUpdateGuests("4", dir, []*ilof.Guest{{Name: "Hal Hope"}, {Name: "Mary Moe"}}, nil); err != nil {
+		t.Fatalf("AddOrUpdateGuests failed: %v", err)
+	}
+	files, err := ilof.GuestFiles(dir)
+	if err != nil {
+		t.Fatalf("GuestFiles failed: %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	if want := "[a-f.yaml g-l.yaml m-r.yaml s-z.yaml]"; fmt.Sprint(names) != want {
+		t.Errorf("GuestFiles: got %v, want %v", names, want)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "a-f.yaml")); err != nil {
+		t.Fatal(err)
+	} else if want := "# Guests A-F\n- name: Alice Able\n  episodes: [1]\n"; string(data) != want {
+		t.Errorf("Shard a-f.yaml:\ngot  %q\nwant %q", data, want)
+	}
+	gl, err := ilof.OpenGuestList(dir)
+	if err != nil {
+		t.Fatalf("OpenGuestList failed: %v", err)
+	} else if g := gl.Find("Mary Moe"); g == nil || fmt.Sprint(g.Episodes) != "[3 4]" {
+		t.Errorf("Mary Moe: got %v, want episodes [3 4]", g)
+	}
+
+	// Combining the shards yields a single file with all the guests.
+	file := dir + ".yaml"
+	if err := gl.SaveAs(file, false); err != nil {
+		t.Fatalf("SaveAs failed: %v", err)
+	} else if gs, err := ilof.LoadGuests(file); err != nil || len(gs) != 4 {
+		t.Errorf("LoadGuests(%q): got %d guests, %v; want 4", file, len(gs), err)
+	}
+
+	write("extra.yaml", "[]\n")
+	if _, err := ilof.LoadGuests(dir); err == nil {
+		t.Error("LoadGuests with a stray file: got nil error")
+	}
+}
+
+func TestGuestLabels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guests.yaml")
+	const orig = "- name: Alice Able\n  episodes: [100.0, special-1, 5]\n- name: Bob Baker\n  episodes: [5]\n"
+	if err := os.WriteFile(path, []byte(orig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gs, err := ilof.LoadGuests(path)
+	if err != nil {
+		t.Fatalf("LoadGuests failed: %v", err)
+	}
+	if got := fmt.Sprint(gs[0].Episodes); got != "[100 special-1 5]" {
+		t.Errorf("Episodes: got %s, want [100 special-1 5]", got)
+	}
+	for _, ep := range []ilof.Label{"100", "special-1", "5"} {
+		if !gs[0].OnEpisode(ep) {
+			t.Errorf("OnEpisode(%q): got false, want true", ep)
+		}
+	}
+	if gs[0].OnEpisode("special-2") {
+		t.Error("OnEpisode(special-2): got true, want false")
+	}
+	if got := ilof.ParseLabel(" 100.0 "); got != "100" {
+		t.Errorf("ParseLabel: got %q, want 100", got)
+	}
+
+	idx := ilof.GuestIndex(gs)
+	if len(idx["5"]) != 2 || len(idx["special-1"]) != 1 {
+		t.Errorf("GuestIndex: got %v", idx)
+	}
+
+	// Adding a guest to a special episode keeps the labels sorted, with
+	// numbered episodes first, and writes numbers as numbers.
+	if err := ilof.AddOrUpdateGuests("special-0", path, []*ilof.Guest{{Name: "Bob Baker"}}, nil); err != nil {
+		t.Fatalf("AddOrUpdateGuests failed: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(data), "episodes: [5, special-0]") {
+		t.Errorf("Updated guest file:\n%s", data)
+	}
+}
+
+func TestGuestNotes(t *testing.T) {
+	const bio = "Professor of law 🎓⚖️\nAuthor of https://example.com/book | Views my own | RTs ≠ endorsements\nShe/her"
+	tests := []struct {
+		opts *ilof.GuestOptions
+		want string
+	}{
+		{nil, "Professor of law; Author of; She/her"},
+		{&ilof.GuestOptions{KeepURLs: true}, "Professor of law; Author of https://example.com/book; She/her"},
+		{&ilof.GuestOptions{KeepNewlines: true}, "Professor of law\nAuthor of\nShe/her"},
+		{&ilof.GuestOptions{KeepBoilerplate: true}, "Professor of law; Author of | Views my own | RTs ≠ endorsements; She/her"},
+		{&ilof.GuestOptions{MaxNotes: 24}, "Professor of law…"},
+	}
+	for _, test := range tests {
+		if got := ilof.SanitizeNotes(bio, test.opts); got != test.want {
+			t.Errorf("SanitizeNotes(%+v): got %q, want %q", test.opts, got, test.want)
+		}
+	}
+	if got := ilof.SanitizeNotes(bio, &ilof.GuestOptions{KeepEmoji: true}); !strings.Contains(got, "🎓") {
+		t.Errorf("SanitizeNotes(KeepEmoji): got %q", got)
+	}
+	// Flags and keycaps are removed, but not symbols of ordinary text.
+	if got, want := ilof.SanitizeNotes("Flag 🇺🇸 keycap #️⃣ at 20° ✓ 👩🏽‍💻", nil), "Flag keycap # at 20° ✓"; got != want {
+		t.Errorf("SanitizeNotes(symbols): got %q, want %q", got, want)
+	}
+
+	// New guests have their notes sanitized; existing guests are untouched.
+	path := filepath.Join(t.TempDir(), "guests.yaml")
+	if err := os.WriteFile(path, []byte("- name: Alice Able\n  notes: \"Old notes 🎉\"\n  episodes: [1]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ilof.AddOrUpdateGuests("2", path, []*ilof.Guest{
+		{Name: "Alice Able", Notes: "New notes"},
+		{Name: "Bob Baker", Notes: bio},
+		{Name: "Carol Cole", Notes: "Plain notes"},
+	}, &ilof.GuestOptions{KeepBio: true}); err != nil {
+		t.Fatalf("AddOrUpdateGuests failed: %v", err)
+	}
+	gs, err := ilof.LoadGuests(path)
+	if err != nil {
+		t.Fatalf("LoadGuests failed: %v", err)
+	}
+	got := make(map[string]*ilof.Guest)
+	for _, g := range gs {
+		got[g.Name] = g
+	}
+	if g := got["Alice Able"]; g == nil || g.Notes != "Old notes 🎉" || g.Bio != "" {
+		t.Errorf("Existing guest: got %+v", g)
+	}
+	if g := got["Bob Baker"]; g == nil || g.Notes != tests[0].want || g.Bio != bio {
+		t.Errorf("New guest: got %+v", g)
+	}
+	if g := got["Carol Cole"]; g == nil || g.Notes != "Plain notes" || g.Bio != "" {
+		t.Errorf("New guest with clean notes: got %+v", g)
+	}
+}
+
+func TestMemoCache(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fetch := func(context.Context) ([]byte, error) {
+		calls.Add(1)
+		<-release
+		return []byte("data"), nil
+	}
+
+	// Concurrent callers share a single fetch.
+	var c ilof.MemoCache
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	results := make([]string, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := c.Fetch(ctx, "u", fetch)
+			if err != nil {
+				t.Errorf("Fetch %d failed: %v", i, err)
+			}
+			results[i] = string(data)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Got %d fetches, want 1", n)
+	}
+	for i, r := range results {
+		if r != "data" {
+			t.Errorf("Result %d: got %q, want data", i, r)
+		}
+	}
+
+	// Later calls reuse the result, until the cache is reset.
+	c.Fetch(ctx, "u", fetch)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("After reuse: got %d fetches, want 1", n)
+	}
+	c.Reset()
+	c.Fetch(ctx, "u", fetch)
+	if n := calls.Load(); n != 2 {
+		t.Errorf("After reset: got %d fetches, want 2", n)
+	}
+
+	// Failures are not retained.
+	fail := func(context.Context) ([]byte, error) { calls.Add(1); return nil, errors.New("bad") }
+	for i := 0; i < 2; i++ {
+		if _, err := c.Fetch(ctx, "v", fail); err == nil {
+			t.Error("Fetch: got nil error, want failure")
+		}
+	}
+	if n := calls.Load(); n != 4 {
+		t.Errorf("After failures: got %d fetches, want 4", n)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	var buf bytes.Buffer
+	bar := ilof.NewProgressBar(&buf, "Testing")
+	var got []ilof.Progress
+	var hits [10]atomic.Int32
+	err := ilof.RunBatch(context.Background(), len(hits), &ilof.BatchOptions{
+		Workers: 3,
+		Progress: func(p ilof.Progress) {
+			got = append(got, p)
+			bar.Update(p)
+		},
+	}, func(_ context.Context, i int) string {
+		hits[i].Add(1)
+		return fmt.Sprint("item ", i)
+	})
+	if err != nil {
+		t.Fatalf("RunBatch failed: %v", err)
+	}
+	for i := range hits {
+		if n := hits[i].Load(); n != 1 {
+			t.Errorf("Item %d: called %d times, want 1", i, n)
+		}
+	}
+	if len(got) != len(hits) {
+		t.Fatalf("Got %d progress reports, want %d", len(got), len(hits))
+	}
+	for i, p := range got {
+		if p.Done != i+1 || p.Total != len(hits) || !strings.HasPrefix(p.Item, "item ") {
+			t.Errorf("Progress %d: got %+v", i, p)
+		}
+	}
+
+	// The bar is not a terminal, so it reports only the first and last
+	// updates, which are not rate limited.
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "Testing: 10/10 (100%) item ") {
+		t.Errorf("Progress output:\n%s", buf.String())
+	}
+
+	// A canceled batch stops early.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ilof.RunBatch(ctx, 5, nil, func(context.Context, int) string { return "" }); !errors.Is(err, context.Canceled) {
+		t.Errorf("RunBatch canceled: got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestForEachEpisodeWith(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"2020-01-01-0001.md":      "not a valid episode file",
+		"2021-01-05-0100.md":      "---\nepisode: 100\ndate: 2021-01-05\nseason: 1\nduration: 1h\n---\n",
+		"2021-01-07-0101.md":      "---\nepisode: 101\ndate: 2021-01-07\nseason: 1\n---\n",
+		"2021-02-01-0102.md":      "---\nepisode: 102\ndate: 2021-02-01\nseason: 2\n---\n",
+		"2021-02-03-special-1.md": "---\nepisode: special-1\ndate: 2021-02-03\nseason: 2\n---\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	date := func(s string) ilof.Date {
+		var d ilof.Date
+		if err := d.UnmarshalText([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		name   string
+		filter *ilof.EpisodeFilter
+		want   string
+	}{
+		{"Since", &ilof.EpisodeFilter{Since: date("2021-01-06")}, "[101 102 special-1]"},
+		{"Range", &ilof.EpisodeFilter{Since: date("2021-01-02"), Until: date("2021-02-01")}, "[100 101 102]"},
+		{"Labels", &ilof.EpisodeFilter{From: "100", To: "101"}, "[100 101]"},
+		{"Special", &ilof.EpisodeFilter{From: "special-1", Since: date("2021-01-01")}, "[special-1]"},
+		{"Season", &ilof.EpisodeFilter{Season: 2, From: "100"}, "[102 special-1]"},
+		{"Missing", &ilof.EpisodeFilter{Since: date("2021-01-01"), Missing: []string{"duration"}}, "[101 102 special-1]"},
+		{"Match", &ilof.EpisodeFilter{From: "100", Match: func(ep *ilof.Episode) bool {
+			return ep.Episode.Number() > 100.5
+		}}, "[101 102]"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []ilof.Label
+			if err := ilof.ForEachEpisodeWith(dir, tc.filter, func(_ string, ep *ilof.Episode) error {
+				got = append(got, ep.Episode)
+				return nil
+			}); err != nil {
+				t.Fatalf("ForEachEpisodeWith failed: %v", err)
+			}
+			if s := fmt.Sprint(got); s != tc.want {
+				t.Errorf("Episodes: got %s, want %s", s, tc.want)
+			}
+		})
+	}
+
+	// The invalid file is loaded if it is not excluded by name.
+	if err := ilof.ForEachEpisodeWith(dir, &ilof.EpisodeFilter{Season: 1}, func(string, *ilof.Episode) error {
+		return nil
+	}); err == nil {
+		t.Error("ForEachEpisodeWith: got nil error, want a load failure")
+	}
+	if err := ilof.ForEachEpisodeWith(dir, &ilof.EpisodeFilter{Missing: []string{"bogus"}}, nil); err == nil {
+		t.Error("ForEachEpisodeWith: got nil error for an unknown field")
+	}
+}
+
+func TestEpisodes(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"2021-01-05-0100.md": "---\nepisode: 100\ndate: 2021-01-05\n---\n",
+		"2021-01-07-0101.md": "---\nepisode: 101\ndate: 2021-01-07\n---\n",
+		"2021-01-09-0102.md": "not a valid episode file",
+		"notes.md":           "not an episode file",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Stopping early does not load the invalid file.
+	it := ilof.Episodes(context.Background(), dir, nil)
+	var got []ilof.Label
+	for len(got) < 2 && it.Next() {
+		got = append(got, it.Episode().Episode)
+	}
+	if s := fmt.Sprint(got); s != "[100 101]" {
+		t.Errorf("Episodes: got %s, want [100 101]", s)
+	}
+	if want := filepath.Join(dir, "2021-01-07-0101.md"); it.Path() != want {
+		t.Errorf("Path: got %q, want %q", it.Path(), want)
+	}
+
+	// Continuing reaches the invalid file.
+	if it.Next() {
+		t.Errorf("Next: got %s, want a load failure", it.Episode().Episode)
+	} else if it.Err() == nil {
+		t.Error("Err: got nil, want a load failure")
+	} else if it.Next() || it.Episode() != nil {
+		t.Error("Next succeeded after an error")
+	}
+
+	// The filter is applied.
+	it = ilof.Episodes(context.Background(), dir, &ilof.EpisodeFilter{From: "101", To: "101"})
+	got = nil
+	for it.Next() {
+		got = append(got, it.Episode().Episode)
+	}
+	if err := it.Err(); err != nil {
+		t.Errorf("Err: unexpected error: %v", err)
+	} else if s := fmt.Sprint(got); s != "[101]" {
+		t.Errorf("Episodes: got %s, want [101]", s)
+	}
+
+	// Cancellation stops the iteration.
+	ctx, cancel := context.WithCancel(context.Background())
+	it = ilof.Episodes(ctx, dir, nil)
+	if !it.Next() {
+		t.Fatalf("Next failed: %v", it.Err())
+	}
+	cancel()
+	if it.Next() {
+		t.Error("Next succeeded after cancellation")
+	} else if err := it.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Err: got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCreateOrUpdateEpisodeFromUpdate(t *testing.T) {
+	dir := t.TempDir()
+	up := &ilof.TwitterUpdate{
+		AirDate:   time.Date(2021, 3, 4, 17, 0, 0, 0, time.UTC),
+		YouTube:   "https://youtu.be/abc",
+		Crowdcast: "https://www.crowdcast.io/e/x",
+	}
+	rules := []*ilof.TagRule{{Tag: "game-night", Phrases: []string{"game night"}}}
+	const desc = "It is game night with our friends.\n\nhttps://example.com"
+
+	// A new file is created, with its detail and summary from the description.
+	res, err := ilof.CreateOrUpdateEpisodeFromUpdate(dir, 120, up, &ilof.EpisodeFileOptions{
+		Description: desc,
+		TagRules:    rules,
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if want := filepath.Join(dir, "2021-03-04-0120.md"); res.Path != want {
+		t.Errorf("Path: got %q, want %q", res.Path, want)
+	}
+	if !res.Created || !res.Summarized {
+		t.Errorf("Create: got %+v, want created and summarized", res)
+	}
+	ep, err := ilof.LoadEpisode(res.Path)
+	if err != nil {
+		t.Fatalf("LoadEpisode failed: %v", err)
+	}
+	if ep.Episode != "120" || ep.YouTubeURL != up.YouTube || ep.CrowdcastURL != up.Crowdcast ||
+		!ep.HasSegment("game-night") || !ep.AutoSummary || ep.Detail == "" {
+		t.Errorf("Created episode: got %+v", ep)
+	}
+
+	// An existing file keeps its contents, apart from the stream URLs.
+	ep.Summary = "A reviewed summary."
+	ep.AutoSummary = false
+	ep.Tags = []string{"guests"}
+	if err := ilof.WriteEpisode(res.Path, ep); err != nil {
+		t.Fatal(err)
+	}
+	up.YouTube = "https://youtu.be/def"
+	dry := new(repo.DryRun)
+	res, err = ilof.CreateOrUpdateEpisodeFromUpdate(dir, 120, up, &ilof.EpisodeFileOptions{
+		Worktree:    dry,
+		Description: desc,
+		TagRules:    rules,
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if res.Created || res.Summarized {
+		t.Errorf("Update: got %+v, want neither created nor summarized", res)
+	}
+	if got := res.Episode; got.Summary != "A reviewed summary." || got.YouTubeURL != up.YouTube ||
+		fmt.Sprint(got.Tags) != "[guests]" || !got.HasSegment("game-night") {
+		t.Errorf("Updated episode: got %+v", got)
+	}
+	if ws := dry.Writes(); len(ws) != 1 || ws[0].Created {
+		t.Errorf("Update writes: got %+v, want one update", ws)
+	}
+
+	if got := ilof.EpisodeFileName(ep.Date, "special-2"); got != "2021-03-04-special-2.md" {
+		t.Errorf("EpisodeFileName: got %q", got)
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	ep := func(label, date string, season int, dur string, tags ...string) *ilof.Episode {
+		d, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &ilof.Episode{
+			Episode:  ilof.Label(label),
+			Date:     ilof.Date(d),
+			Season:   season,
+			Duration: dur,
+			Tags:     tags,
+		}
+	}
+	special := ep("S1", "2020-12-25", 1, "30m", "holiday")
+	special.Special = true
+	withSeg := ep("3", "2021-01-03", 2, "", "cheese")
+	withSeg.Segments = []*ilof.Segment{{Name: "cheese-night"}, {Name: "cheese"}}
+	eps := []*ilof.Episode{
+		ep("1", "2020-12-01", 1, "1h30m", "cheese", "news"),
+		special,
+		ep("2", "2021-01-01", 2, "bogus", "news"),
+		withSeg,
+	}
+	guests := []*ilof.Guest{
+		{Name: "Alice", Episodes: []ilof.Label{"1", "2", "3"}},
+		{Name: "Bob", Episodes: []ilof.Label{"2", "99"}},
+		{Name: "Cara", Episodes: []ilof.Label{"99"}},
+	}
+
+	// summary renders the parts of st that depend on the inputs compactly.
+	summary := func(st *ilof.Stats) string {
+		var seasons, tags, segs, top []string
+		for _, sc := range st.BySeason {
+			seasons = append(seasons, fmt.Sprintf("%d:%d:%.1f", sc.Season, sc.Episodes, sc.Hours))
+		}
+		for _, nc := range st.Tags {
+			tags = append(tags, fmt.Sprintf("%s:%d", nc.Name, nc.Count))
+		}
+		for _, nc := range st.Segments {
+			segs = append(segs, fmt.Sprintf("%s:%d", nc.Name, nc.Count))
+		}
+		for _, nc := range st.TopGuests {
+			top = append(top, fmt.Sprintf("%s:%d", nc.Name, nc.Count))
+		}
+		return fmt.Sprintf("eps=%d specials=%d range=%s..%s timed=%d hours=%.1f seasons=%v tags=%v segments=%v guests=%d visits=%d top=%v",
+			st.Episodes, st.Specials, st.First, st.Last, st.NumTimed, st.Hours,
+			seasons, tags, segs, st.NumGuests, st.GuestVisits, top)
+	}
+
+	tests := []struct {
+		name string
+		eps  []*ilof.Episode
+		topN int
+		want string
+	}{
+		{"Empty", nil, 0,
+			"eps=0 specials=0 range=0001-01-01..0001-01-01 timed=0 hours=0.0 seasons=[] tags=[] segments=[] guests=0 visits=0 top=[]"},
+		{"All", eps, 0,
+			"eps=4 specials=1 range=2020-12-01..2021-01-03 timed=2 hours=2.0 seasons=[1:2:2.0 2:2:0.0] " +
+				"tags=[cheese:2 news:2 cheese-night:1 holiday:1] segments=[cheese:1 cheese-night:1] " +
+				"guests=2 visits=4 top=[Alice:3 Bob:1]"},
+		{"TopOne", eps, 1,
+			"eps=4 specials=1 range=2020-12-01..2021-01-03 timed=2 hours=2.0 seasons=[1:2:2.0 2:2:0.0] " +
+				"tags=[cheese:2 news:2 cheese-night:1 holiday:1] segments=[cheese:1 cheese-night:1] " +
+				"guests=2 visits=4 top=[Alice:3]"},
+		{"Subset", eps[2:], 0,
+			"eps=2 specials=0 range=2021-01-01..2021-01-03 timed=0 hours=0.0 seasons=[2:2:0.0] " +
+				"tags=[cheese:1 cheese-night:1 news:1] segments=[cheese:1 cheese-night:1] " +
+				"guests=2 visits=3 top=[Alice:2 Bob:1]"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			st := ilof.ComputeStats(tc.eps, guests, tc.topN)
+			if got := summary(st); got != tc.want {
+				t.Errorf("ComputeStats:\ngot  %s\nwant %s", got, tc.want)
+			}
+			if st.Hosts != nil { // no episode records its hosts
+				t.Errorf("Hosts: got %+v, want nil", st.Hosts)
+			}
+		})
+	}
+}
+
+func TestHostStats(t *testing.T) {
+	ep := func(label string, hosts ...string) *ilof.Episode {
+		return &ilof.Episode{Episode: ilof.Label(label), Hosts: hosts}
+	}
+	eps := []*ilof.Episode{
+		ep("5", "Ann", "Bo", "Cy"),
+		ep("1", "Ann", "Bo"),
+		ep("2", "Ann"),
+		ep("3", "Ann", "Cy"),
+		ep("4"), // attendance not recorded
+		ep("6", "Bo"),
+		ep("7", "Bo", "Cy"),
+	}
+	if st := ilof.ComputeHostStats(eps[4:5]); st != nil {
+		t.Errorf("ComputeHostStats with no records: got %+v, want nil", st)
+	}
+	st := ilof.ComputeHostStats(eps)
+	if st.Recorded != 6 {
+		t.Errorf("Recorded: got %d, want 6", st.Recorded)
+	}
+
+	var got []string
+	for _, h := range st.Hosts {
+		s := fmt.Sprintf("%s:%d:%.2f", h.Name, h.Episodes, h.Rate)
+		if a := h.LongestAbsence; a != nil {
+			s += fmt.Sprintf(":%d[%s-%s]", a.Episodes, a.First, a.Last)
+			if a.Ongoing {
+				s += "+"
+			}
+		}
+		got = append(got, s)
+	}
+	// Bo misses 2 and 3; Ann misses 6 and 7, still absent; Cy joins at 3 and
+	// misses only 6, so the episodes before Cy first appeared do not count.
+	want := "Ann:4:0.67:2[6-7]+ Bo:4:0.67:2[2-3] Cy:3:0.50:1[6-6]"
+	if s := strings.Join(got, " "); s != want {
+		t.Errorf("Hosts:\ngot  %s\nwant %s", s, want)
+	}
+
+	got = got[:0]
+	for _, p := range st.Pairs {
+		got = append(got, fmt.Sprintf("%s+%s:%d", p.Hosts[0], p.Hosts[1], p.Episodes))
+	}
+	if s, want := strings.Join(got, " "), "Ann+Bo:2 Ann+Cy:2 Bo+Cy:2"; s != want {
+		t.Errorf("Pairs: got %s, want %s", s, want)
+	}
+
+	// Host statistics are included in the catalog statistics.
+	if cs := ilof.ComputeStats(eps, nil, 0); cs.Hosts == nil || cs.Hosts.Recorded != 6 {
+		t.Errorf("ComputeStats hosts: got %+v", cs.Hosts)
+	}
+}
+
+func TestOnThisDay(t *testing.T) {
+	date := func(s string) ilof.Date {
+		var d ilof.Date
+		if err := d.UnmarshalText([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	eps := []*ilof.Episode{
+		{Episode: "300", Date: date("2022-01-05"), Guests: []string{"Alice"}},
+		{Episode: "100", Date: date("2021-01-05"), Topics: "First"},
+		{Episode: "101", Date: date("2021-01-07")},
+		{Episode: "special-1", Date: date("2020-02-29")},
+		{Episode: "400"}, // no date
+	}
+	days := ilof.OnThisDay(eps)
+	if len(days) != 3 {
+		t.Errorf("OnThisDay: got %d days, want 3", len(days))
+	}
+	var got []string
+	for _, tb := range days["01-05"] {
+		got = append(got, fmt.Sprintf("%s:%d", tb.Episode, tb.Year))
+	}
+	if s := strings.Join(got, " "); s != "100:2021 300:2022" {
+		t.Errorf("Day 01-05: got %s", s)
+	}
+	if tb := days["01-05"][1]; tb.URL != "https://inlieuof.fun/episode/300" || fmt.Sprint(tb.Guests) != "[Alice]" {
+		t.Errorf("Throwback: got %+v", tb)
+	}
+
+	// Only episodes from earlier years are throwbacks.
+	for _, tc := range []struct {
+		on   string
+		want string
+	}{
+		{"2022-01-05", "[100]"},
+		{"2023-01-05", "[100 300]"},
+		{"2021-01-05", "[]"},
+		{"2024-02-29", "[special-1]"},
+		{"2024-03-01", "[]"},
+	} {
+		var labels []ilof.Label
+		for _, tb := range ilof.Throwbacks(days, date(tc.on)) {
+			labels = append(labels, tb.Episode)
+		}
+		if s := fmt.Sprint(labels); s != tc.want {
+			t.Errorf("Throwbacks(%s): got %s, want %s", tc.on, s, tc.want)
+		}
+	}
+}
+
+func TestSeasonArchives(t *testing.T) {
+	date := func(s string) ilof.Date {
+		var d ilof.Date
+		if err := d.UnmarshalText([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	seasons := ilof.Seasons{
+		{Season: 1, Start: date("2020-03-16")},
+		{Season: 2, Start: date("2021-01-01"), Title: "Encore"},
+	}
+	eps := []*ilof.Episode{
+		{Episode: "101", Date: date("2021-01-07"), Tags: []string{"books"}},
+		{Episode: "100", Date: date("2021-01-05"), Topics: "Cheese | crackers", Duration: "1h"},
+		{Episode: "50", Date: date("2020-06-01"), Season: 1},
+		{Episode: "1", Date: date("2019-01-01")}, // before any season
+	}
+	guests := []*ilof.Guest{
+		{Name: "Alice Able", Episodes: []ilof.Label{"100", "101"}},
+		{Name: "Bob Baker", Episodes: []ilof.Label{"101"}},
+	}
+	as := ilof.SeasonArchives(eps, guests, seasons, 1)
+	if len(as) != 2 || as[0].Season != 1 || as[1].Season != 2 {
+		t.Fatalf("SeasonArchives: got %d archives, want seasons 1 and 2", len(as))
+	}
+	a := as[1]
+	if got := fmt.Sprint(a.Guests["101"]); got != "[Alice Able Bob Baker]" {
+		t.Errorf("Guests of 101: got %s", got)
+	}
+	if a.Stats.Episodes != 2 || len(a.Stats.TopGuests) != 1 || len(a.Stats.Tags) != 1 {
+		t.Errorf("Stats: got %+v", a.Stats)
+	}
+
+	data, err := a.Markdown("page")
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	page := string(data)
+	for _, want := range []string{
+		"title: \"Season 2: Encore\"\n",
+		"permalink: /season/2/\n",
+		"\n" + ilof.ArchiveMarker + "\n",
+		"**2 episodes**",
+		"| [100](/episode/100) | 2021-01-05 | Alice Able | Cheese \\| crackers |  |\n",
+		"| [101](/episode/101) | 2021-01-07 | Alice Able, Bob Baker |  | books |\n",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Page is missing %q:\n%s", want, page)
+		}
+	}
+	if i, j := strings.Index(page, "[100]"), strings.Index(page, "[101]"); i > j {
+		t.Errorf("Episodes out of order:\n%s", page)
+	}
+}
+
+func TestTranscriptFeed(t *testing.T) {
+	at := func(day int) time.Time { return time.Date(2022, 5, day, 12, 0, 0, 0, time.UTC) }
+	ups := []*ilof.TranscriptUpdate{
+		{Episode: &ilof.Episode{Episode: "100", Transcript: "/transcripts/100.json"}, Updated: at(1)},
+		{
+			Episode: &ilof.Episode{
+				Episode:    "101",
+				Transcript: "transcripts/101.json",
+				YouTubeURL: "https://youtu.be/abc",
+				Summary:    "Cheese & crackers",
+			},
+			Updated:    at(3),
+			Transcript: &ilof.Transcript{Captions: []*ilof.Caption{{Start: 42.5, Text: "hello"}}},
+		},
+		{Episode: &ilof.Episode{Episode: "102", Transcript: "/transcripts/102.json"}, Updated: at(2)},
+	}
+	var buf bytes.Buffer
+	if err := ilof.WriteTranscriptFeed(&buf, ups, 2); err != nil {
+		t.Fatalf("WriteTranscriptFeed failed: %v", err)
+	}
+	var feed struct {
+		Items []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			GUID        string `xml:"guid"`
+			Description string `xml:"description"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &feed); err != nil {
+		t.Fatalf("Invalid feed: %v\n%s", err, buf.String())
+	}
+	if len(feed.Items) != 2 {
+		t.Fatalf("Got %d items, want 2", len(feed.Items))
+	}
+	item := feed.Items[0]
+	if item.Link != "https://inlieuof.fun/transcripts/101.json" || !strings.HasPrefix(item.Title, "Transcript of episode 101") {
+		t.Errorf("First item: got %+v", item)
+	}
+	for _, want := range []string{
+		"<p>Cheese &amp; crackers</p>",
+		"https://www.youtube.com/watch?v=abc&amp;t=42s",
+		"https://inlieuof.fun/episode/101",
+	} {
+		if !strings.Contains(item.Description, want) {
+			t.Errorf("Description is missing %q: %s", want, item.Description)
+		}
+	}
+	if got := feed.Items[1].Link; got != "https://inlieuof.fun/transcripts/102.json" {
+		t.Errorf("Second item: got %q, want episode 102", got)
+	}
+
+	// An update to the same transcript gets a new GUID.
+	ups[1].Updated = at(4)
+	buf.Reset()
+	if err := ilof.WriteTranscriptFeed(&buf, ups[1:2], 0); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), item.GUID) {
+		t.Errorf("Updated item has the old GUID %q", item.GUID)
+	}
+}
+
+func TestTagPages(t *testing.T) {
+	date := func(s string) ilof.Date {
+		var d ilof.Date
+		if err := d.UnmarshalText([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	eps := []*ilof.Episode{
+		{Episode: "101", Date: date("2021-01-07"), Tags: []string{"cheese-night", "books"}},
+		{Episode: "100", Date: date("2021-01-05"), Tags: []string{"cheese-night", "Bad Tag"}},
+		{Episode: "99", Date: date("2021-01-04"), Tags: []string{"cheese-night", "secret", "cheese-night"}},
+	}
+	reg := []*ilof.TagInfo{
+		{Tag: "cheese-night", Title: "Cheese & Crackers", Description: "Episodes about cheese."},
+		{Tag: "secret", Hidden: true},
+	}
+	pages := ilof.TagPages(eps, reg)
+	var got []string
+	for _, p := range pages {
+		got = append(got, fmt.Sprintf("%s:%d:%d", p.Tag, len(p.Episodes), p.Weight))
+	}
+	if want := "[books:1:1 cheese-night:3:5]"; fmt.Sprint(got) != want {
+		t.Fatalf("TagPages: got %v, want %s", got, want)
+	}
+
+	data, err := pages[1].Markdown("page")
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	page := string(data)
+	for _, want := range []string{
+		"title: \"Cheese & Crackers\"\n",
+		"permalink: /tag/cheese-night/\n",
+		"\n" + ilof.TagPageMarker + "\n",
+		"Episodes about cheese.\n",
+		"3 episodes tagged **cheese-night**, from 2021-01-04 to 2021-01-07.",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Page is missing %q:\n%s", want, page)
+		}
+	}
+	if i, j := strings.Index(page, "[99]"), strings.Index(page, "[100]"); i < 0 || i > j {
+		t.Errorf("Episodes out of order:\n%s", page)
+	}
+
+	data, err = ilof.TagIndexMarkdown(pages, "page")
+	if err != nil {
+		t.Fatalf("TagIndexMarkdown failed: %v", err)
+	}
+	for _, want := range []string{
+		`<a href="/tag/books/" class="tag-cloud-1" title="1 episode">books</a>`,
+		`<a href="/tag/cheese-night/" class="tag-cloud-5" title="3 episodes">Cheese &amp; Crackers</a>`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Index is missing %q:\n%s", want, data)
+		}
+	}
+
+	// Check that a page set replaces only changed and stale generated pages.
+	dir := t.TempDir()
+	old := map[string]string{
+		"tag-books.md": string(data), // wrong contents
+		"tag-gone.md":  "---\n" + ilof.TagPageMarker + "\n---\n",
+		"by-hand.md":   "---\ntitle: mine\n---\n",
+		"tag-mine.md":  "---\ntitle: mine\n---\n",
+		"tags.md":      "unchanged",
+		"season-1.md":  "---\n" + ilof.ArchiveMarker + "\n---\n",
+	}
+	for name, text := range old {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ps := &ilof.PageSet{Dir: dir, Marker: ilof.TagPageMarker, Pages: map[string][]byte{
+		"tag-books.md": []byte("books"),
+		"tags.md":      []byte("unchanged"),
+		"tag-mine.md":  []byte("generated"),
+	}}
+	stale, remove, conflict := ps.Plan()
+	if want := []string{filepath.Join(dir, "tag-books.md")}; fmt.Sprint(stale) != fmt.Sprint(want) {
+		t.Errorf("Plan stale: got %v, want %v", stale, want)
+	}
+	if want := []string{filepath.Join(dir, "tag-gone.md")}; fmt.Sprint(remove) != fmt.Sprint(want) {
+		t.Errorf("Plan remove: got %v, want %v", remove, want)
+	}
+	if want := []string{filepath.Join(dir, "tag-mine.md")}; fmt.Sprint(conflict) != fmt.Sprint(want) {
+		t.Errorf("Plan conflict: got %v, want %v", conflict, want)
+	}
+
+	// Apply records the pages it writes in the manifest, and drops the
+	// entries for the pages it removes.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	ps.Dir, ps.Manifest, ps.Generator = ".", "manifest.json", "tagpages"
+	if err := ilof.RecordGenerated(ps.Manifest, "tagpages", "tag-gone.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Apply([]string{"tag-books.md"}, []string{"tag-gone.md"}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if stale, remove, _ := ps.Plan(); len(stale)+len(remove) != 0 {
+		t.Errorf("After Apply: stale %v, remove %v", stale, remove)
+	}
+	if m, err := ilof.LoadManifest(ps.Manifest); err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	} else if m.Files["tag-gone.md"] != nil || m.Files["tag-books.md"] == nil {
+		t.Errorf("After Apply: manifest has %v", m.Files)
+	}
+	if data, err := os.ReadFile("tag-mine.md"); err != nil || string(data) != old["tag-mine.md"] {
+		t.Errorf("After Apply: tag-mine.md is %q, %v", data, err)
+	}
+}
+
+func TestVideoCatalog(t *testing.T) {
+	date := func(s string) ilof.Date {
+		var d ilof.Date
+		if err := d.UnmarshalText([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	seasons := ilof.Seasons{
+		{Season: 1, Start: date("2020-03-16")},
+		{Season: 2, Start: date("2021-01-01"), Playlist: "PL2"},
+	}
+	eps := []*ilof.Episode{
+		{Episode: "101", Date: date("2021-01-07"), YouTubeURL: "https://youtu.be/v101"},
+		{Episode: "100", Date: date("2021-01-05"), YouTubeURL: "https://www.youtube.com/watch?v=v100"},
+		{Episode: "50", Date: date("2020-06-01"), YouTubeURL: "https://youtu.be/v50"},
+		{Episode: "51", Date: date("2020-06-02")}, // no video
+	}
+	vc := ilof.NewVideoCatalog(eps, seasons)
+	if got, want := fmt.Sprint(vc.All), "[v50 v100 v101]"; got != want {
+		t.Errorf("All: got %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(vc.BySeason[2]), "[v100 v101]"; got != want {
+		t.Errorf("Season 2: got %s, want %s", got, want)
+	}
+	if got := vc.Episodes["v100"]; got != "100" {
+		t.Errorf("Episode of v100: got %q, want 100", got)
+	}
+
+	have := []*ilof.PlaylistItem{
+		{ID: "i1", VideoID: "v100"},
+		{ID: "i2", VideoID: "trailer", Title: "Trailer"},
+	}
+	plan := vc.PlanPlaylist("PL2", vc.BySeason[2], have)
+	if got, want := fmt.Sprint(plan.Add), "[v101]"; got != want {
+		t.Errorf("Add: got %s, want %s", got, want)
+	}
+	if len(plan.Unknown) != 1 || plan.Unknown[0].VideoID != "trailer" {
+		t.Errorf("Unknown: got %+v, want the trailer", plan.Unknown)
+	}
+}
+
+func TestYouTubeAuthURL(t *testing.T) {
+	// The example verifier and challenge from RFC 7636, Appendix B.
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	const challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if got := ilof.PKCEChallenge(verifier); got != challenge {
+		t.Errorf("PKCEChallenge: got %q, want %q", got, challenge)
+	}
+
+	u, err := url.Parse(ilof.YouTubeAuthURL("client", "http://127.0.0.1:5555/", "xyzzy", verifier))
+	if err != nil {
+		t.Fatalf("Parsing auth URL: %v", err)
+	}
+	q := u.Query()
+	for key, want := range map[string]string{
+		"client_id":             "client",
+		"redirect_uri":          "http://127.0.0.1:5555/",
+		"state":                 "xyzzy",
+		"code_challenge":        challenge,
+		"code_challenge_method": "S256",
+	} {
+		if got := q.Get(key); got != want {
+			t.Errorf("Auth URL %s: got %q, want %q", key, got, want)
+		}
+	}
+
+	a, err := ilof.NewOAuthSecret()
+	if err != nil {
+		t.Fatalf("NewOAuthSecret failed: %v", err)
+	}
+	b, _ := ilof.NewOAuthSecret()
+	if len(a) < 43 || a == b {
+		t.Errorf("NewOAuthSecret: got %q and %q, want distinct strings of at least 43 characters", a, b)
+	}
+}
+
+func TestCheckCrowdcastReplay(t *testing.T) {
+	// The pages are saved from Crowdcast. Each has the text of every status
+	// message in its scripts, so only the title and status are examined.
+	page := func(w http.ResponseWriter, name string) {
+		data, err := os.ReadFile(filepath.Join("testdata", "crowdcast", name))
+		if err != nil {
+			t.Errorf("Reading page: %v", err)
+		}
+		w.Write(data)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/e/replay":
+			page(w, "replay.html")
+		case "/e/gone":
+			page(w, "not-found.html")
+		case "/e/live":
+			page(w, "no-replay.html")
+		case "/e/moved":
+			http.Redirect(w, r, "/e/replay", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		path, problem string
+	}{
+		{"/e/replay", ""},
+		{"/e/gone", "not-found"},
+		{"/e/live", "no-replay"},
+		{"/e/moved", ""},
+		{"/e/nonesuch", "dead"},
+	}
+	for _, tc := range tests {
+		st := ilof.CheckCrowdcastReplay(context.Background(), srv.URL+tc.path)
+		if st.Problem != tc.problem || st.OK() != (tc.problem == "") {
+			t.Errorf("Check %s: got problem %q (status %d), want %q", tc.path, st.Problem, st.Status, tc.problem)
+		}
+	}
+}
+
+func TestTwitterIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guests.yaml")
+	const orig = "- name: Alice Able\n  twitter: '@AliceA'\n  episodes: [1]\n" +
+		"- name: Bob Baker\n  twitter: bobb\n  twitter-id: \"222\"\n  episodes: [2]\n" +
+		"- name: Carol Cole\n  twitter: carolc\n  twitter-id: \"333\"\n  episodes: [3]\n"
+	if err := os.WriteFile(path, []byte(orig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gl, err := ilof.OpenGuestList(path)
+	if err != nil {
+		t.Fatalf("OpenGuestList failed: %v", err)
+	}
+
+	// Record IDs by handle, ignoring case and the leading "@".
+	set := ilof.SetTwitterIDs(gl.Guests, []*ilof.TwitterUser{
+		{ID: "111", Handle: "alicea"},
+		{ID: "222", Handle: "bobb"},
+	})
+	if len(set) != 1 || set[0].Name != "Alice Able" || set[0].TwitterID != "111" {
+		t.Errorf("SetTwitterIDs: got %v, want Alice Able with ID 111", set)
+	}
+
+	// Re-derive handles from IDs: Bob was renamed, and Carol is gone.
+	changed, missing := ilof.RefreshTwitterHandles(gl.Guests, []*ilof.TwitterUser{
+		{ID: "111", Handle: "AliceA"},
+		{ID: "222", Handle: "bob_baker"},
+	})
+	if len(changed) != 1 || changed[0].Old != "bobb" || changed[0].New != "bob_baker" {
+		t.Errorf("RefreshTwitterHandles changed: got %+v", changed)
+	}
+	if len(missing) != 1 || missing[0].Name != "Carol Cole" {
+		t.Errorf("RefreshTwitterHandles missing: got %v", missing)
+	}
+
+	if err := gl.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`twitter-id: "111"`, "twitter: bob_baker\n  twitter-id: \"222\""} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Saved guest file is missing %q:\n%s", want, data)
+		}
+	}
+	if g := gl.Find("@bob_baker"); g == nil || g.TwitterID != "222" {
+		t.Errorf("Find(@bob_baker): got %v", g)
+	}
+}
+
+func TestGuestGraph(t *testing.T) {
+	eps := []*ilof.Episode{
+		{Episode: "1", Hosts: []string{"Ben", "Kate"}},
+		{Episode: "2", Hosts: []string{"Ben"}},
+		{Episode: "3"},
+	}
+	guests := []*ilof.Guest{
+		{Name: "Alice \"Al\" Able", Episodes: []ilof.Label{"1", "2", "3"}},
+		{Name: "Bob Baker", Episodes: []ilof.Label{"2", "3"}},
+		{Name: "kate", Episodes: []ilof.Label{"3"}}, // also a host
+	}
+	g := ilof.BuildGuestGraph(eps, guests, nil)
+	var nodes []string
+	name := make(map[string]string)
+	for _, n := range g.Nodes {
+		nodes = append(nodes, fmt.Sprintf("%s/%s/%d", n.Name, n.Kind, n.Episodes))
+		name[n.ID] = n.Name
+	}
+	if got, want := strings.Join(nodes, ", "), `Ben/host/2, Kate/host/2, Alice "Al" Able/guest/3, Bob Baker/guest/2`; got != want {
+		t.Errorf("Nodes: got %s, want %s", got, want)
+	}
+	weight := make(map[string]int)
+	for _, e := range g.Edges {
+		a, b := name[e.Source], name[e.Target]
+		if a > b {
+			a, b = b, a
+		}
+		weight[a+"+"+b] = e.Weight
+	}
+	for pair, want := range map[string]int{
+		`Alice "Al" Able+Bob Baker`: 2,
+		`Alice "Al" Able+Ben`:       2,
+		`Alice "Al" Able+Kate`:      2,
+		`Ben+Kate`:                  1,
+		`Bob Baker+Kate`:            1,
+	} {
+		if weight[pair] != want {
+			t.Errorf("Edge %s: got weight %d, want %d", pair, weight[pair], want)
+		}
+	}
+	if g.Edges[0].Weight != 2 {
+		t.Errorf("Edges not in order of weight: %+v", g.Edges[0])
+	}
+
+	g = ilof.BuildGuestGraph(eps, guests, &ilof.GraphOptions{OmitHosts: true, MinWeight: 2})
+	if len(g.Nodes) != 3 || len(g.Edges) != 1 {
+		t.Errorf("Without hosts: got %d nodes and %d edges, want 3 and 1", len(g.Nodes), len(g.Edges))
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatalf("WriteDOT failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `[label="Alice \"Al\" Able", episodes=3]`) {
+		t.Errorf("DOT output:\n%s", buf.String())
+	}
+	buf.Reset()
+	if err := g.WriteGraphML(&buf); err != nil {
+		t.Fatalf("WriteGraphML failed: %v", err)
+	}
+	var doc struct {
+		Nodes []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"graph>node"`
+		Edges []struct {
+			Source string `xml:"source,attr"`
+		} `xml:"graph>edge"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Decoding GraphML: %v", err)
+	} else if len(doc.Nodes) != 3 || len(doc.Edges) != 1 {
+		t.Errorf("GraphML: got %d nodes and %d edges, want 3 and 1", len(doc.Nodes), len(doc.Edges))
+	}
+	buf.Reset()
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var js struct {
+		Nodes []json.RawMessage `json:"nodes"`
+		Links []json.RawMessage `json:"links"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &js); err != nil || len(js.Nodes) != 3 || len(js.Links) != 1 {
+		t.Errorf("JSON: got %d nodes, %d links, err %v", len(js.Nodes), len(js.Links), err)
+	}
+}
+
+func TestPodcastTranscript(t *testing.T) {
+	tr := &ilof.Transcript{Captions: []*ilof.Caption{
+		{Start: 1.5, Duration: 2.25, Text: "hello\nthere"},
+		{Start: 3.75, Duration: 1.0001, Text: "cheese & crackers"},
+	}}
+	var buf bytes.Buffer
+	if err := tr.WritePodcastJSON(&buf); err != nil {
+		t.Fatalf("WritePodcastJSON failed: %v", err)
+	}
+	var doc struct {
+		Version  string `json:"version"`
+		Segments []struct {
+			Start float64 `json:"startTime"`
+			End   float64 `json:"endTime"`
+			Body  string  `json:"body"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Decoding output: %v", err)
+	}
+	if doc.Version != "1.0.0" || len(doc.Segments) != 2 {
+		t.Fatalf("Got %+v, want version 1.0.0 and 2 segments", doc)
+	}
+	if s := doc.Segments[0]; s.Start != 1.5 || s.End != 3.75 || s.Body != "hello there" {
+		t.Errorf("Segment 1: got %+v", s)
+	}
+	if s := doc.Segments[1]; s.End != 4.75 || s.Body != "cheese & crackers" {
+		t.Errorf("Segment 2: got %+v", s)
+	}
+
+	jsonPath, srtPath := ilof.PodcastTranscriptPaths("transcripts/0100-abc.json")
+	if jsonPath != "transcripts/0100-abc.podcast.json" || srtPath != "transcripts/0100-abc.srt" {
+		t.Errorf("PodcastTranscriptPaths: got %q, %q", jsonPath, srtPath)
+	}
+
+	// The feed advertises exported files in the podcast namespace.
+	buf.Reset()
+	if err := ilof.WriteTranscriptFeed(&buf, []*ilof.TranscriptUpdate{{
+		Episode: &ilof.Episode{Episode: "100", Transcript: "transcripts/0100-abc.json"},
+		Updated: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC),
+		Files: []*ilof.TranscriptFile{
+			{URL: "https://example.com/" + jsonPath, Type: ilof.PodcastTranscriptJSON},
+			{URL: "https://example.com/" + srtPath, Type: ilof.PodcastTranscriptSRT},
+		},
+		ChaptersURL: "https://example.com/transcripts/0100-abc.chapters.json",
+	}}, 0); err != nil {
+		t.Fatalf("WriteTranscriptFeed failed: %v", err)
+	}
+	feed := buf.String()
+	for _, want := range []string{
+		`xmlns:podcast="https://podcastindex.org/namespace/1.0"`,
+		`<podcast:transcript url="https://example.com/transcripts/0100-abc.podcast.json" type="application/json"></podcast:transcript>`,
+		`<podcast:transcript url="https://example.com/transcripts/0100-abc.srt" type="application/x-subrip"></podcast:transcript>`,
+		`<podcast:chapters url="https://example.com/transcripts/0100-abc.chapters.json" type="application/json+chapters"></podcast:chapters>`,
+	} {
+		if !strings.Contains(feed, want) {
+			t.Errorf("Feed is missing %q:\n%s", want, feed)
+		}
+	}
+}
+
+func TestChapters(t *testing.T) {
+	const desc = `Tonight we talk about the courts.
+
+0:00 Intro
+(05:12) - Courts & "standing"
+1:02:03 | Q&A
+
+See also https://example.com/`
+	chs := ilof.ParseChapters(desc)
+	var got []string
+	for _, c := range chs {
+		got = append(got, c.Start.String()+" "+c.Title)
+	}
+	if s := strings.Join(got, "; "); s != `0:00 Intro; 5:12 Courts & "standing"; 1:02:03 Q&A` {
+		t.Errorf("ParseChapters: got %q", s)
+	}
+	for _, bad := range []string{"5:00 Only one", "5:00 Later\n1:00 Earlier", "No chapters here"} {
+		if chs := ilof.ParseChapters(bad); chs != nil {
+			t.Errorf("ParseChapters(%q): got %d chapters, want none", bad, len(chs))
+		}
+	}
+
+	// Chapters round-trip through the front matter as timestamps.
+	input := "---\nepisode: 100\ndate: 2021-01-05\nduration: 1h5m\nchapters:\n  - start: 0:00\n    title: Intro\n  - start: \"5:12\"\n    title: Courts\n---\nBody.\n"
+	out, err := ilof.FormatEpisodeFile([]byte(input))
+	if err != nil {
+		t.Fatalf("FormatEpisodeFile failed: %v", err)
+	}
+	if want := "chapters:\n  - start: 0:00\n    title: Intro\n  - start: 5:12\n    title: Courts\n"; !strings.Contains(string(out), want) {
+		t.Errorf("FormatEpisodeFile: output lacks %q:\n%s", want, out)
+	}
+	path := filepath.Join(t.TempDir(), "2021-01-05-0100.md")
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ep, err := ilof.LoadEpisode(path)
+	if err != nil {
+		t.Fatalf("LoadEpisode failed: %v", err)
+	} else if len(ep.Chapters) != 2 || ep.Chapters[1].Start != ilof.Offset(312*time.Second) {
+		t.Fatalf("LoadEpisode: got chapters %+v", ep.Chapters)
+	}
+
+	var buf bytes.Buffer
+	if err := ilof.WritePodcastChapters(&buf, ep.Chapters); err != nil {
+		t.Fatalf("WritePodcastChapters failed: %v", err)
+	}
+	if want := `"startTime": 312,`; !strings.Contains(buf.String(), want) {
+		t.Errorf("WritePodcastChapters: output lacks %q:\n%s", want, buf.String())
+	}
+	buf.Reset()
+	if err := ilof.WriteChaptersVTT(&buf, ep.Chapters, ilof.EpisodeEnd(ep, nil)); err != nil {
+		t.Fatalf("WriteChaptersVTT failed: %v", err)
+	}
+	if got, want := buf.String(), `WEBVTT
+
+1
+00:00:00.000 --> 00:05:12.000
+Intro
+
+2
+00:05:12.000 --> 01:05:00.000
+Courts
+
+`; got != want {
+		t.Errorf("WriteChaptersVTT: got\n%s\nwant\n%s", got, want)
+	}
+
+	// Validation reports chapters out of order.
+	bad := strings.Replace(input, `"5:12"`, "0:00", 1)
+	if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := ilof.ValidateEpisodeFile(path)
+	if err != nil {
+		t.Fatalf("ValidateEpisodeFile failed: %v", err)
+	} else if len(fs) != 1 || fs[0].Field != "chapters" {
+		t.Errorf("ValidateEpisodeFile: got %v, want one chapters finding", fs)
+	}
+
+	// Segments with known start times make chapters for an episode without.
+	segEp := &ilof.Episode{Segments: []*ilof.Segment{
+		{Name: "game-night", Start: ilof.Offset(50 * time.Minute)},
+		{Name: "book-club"},
+		{Name: "cheese-night", Start: ilof.Offset(10 * time.Minute)},
+	}}
+	reg := []*ilof.TagInfo{{Tag: "cheese-night", Title: "Cheese Night"}}
+	got = nil
+	for _, c := range ilof.SegmentChapters(segEp, reg) {
+		got = append(got, c.Start.String()+" "+c.Title)
+	}
+	if s := strings.Join(got, "; "); s != "0:00 Introduction; 10:00 Cheese Night; 50:00 game-night" {
+		t.Errorf("SegmentChapters: got %q", s)
+	}
+	if chs := ilof.SegmentChapters(&ilof.Episode{Segments: []*ilof.Segment{{Name: "book-club"}}}, reg); chs != nil {
+		t.Errorf("SegmentChapters without starts: got %+v, want nil", chs)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	date := func(s string) ilof.Date {
+		var d ilof.Date
+		if err := d.UnmarshalText([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	eps := []*ilof.Episode{
+		{Episode: "101", Date: date("2021-01-07"), Tags: []string{"books", "cheese-night"}},
+		{Episode: "100", Date: date("2021-01-05"), Tags: []string{"cheese-night", "game-night"}},
+		{Episode: "99", Date: date("2021-01-04"), Segments: []*ilof.Segment{{Name: "book-club"}}},
+	}
+	names := ilof.SegmentNames(ilof.TagRules)
+	for _, ep := range eps {
+		ep.MoveTagsToSegments(names)
+	}
+	if got := fmt.Sprint(eps[0].Tags, eps[1].Tags); got != "[books] []" {
+		t.Errorf("MoveTagsToSegments: tags left %s, want [books] []", got)
+	}
+	if !eps[1].HasSegment("game-night") || eps[1].HasSegment("books") {
+		t.Errorf("MoveTagsToSegments: got segments %+v", eps[1].Segments)
+	}
+	if got := fmt.Sprint(eps[0].AllTags()); got != "[books cheese-night]" {
+		t.Errorf("AllTags: got %s", got)
+	}
+
+	idx := ilof.SegmentIndex(eps)
+	var got []string
+	for _, ep := range idx["cheese-night"] {
+		got = append(got, string(ep.Episode))
+	}
+	if s := fmt.Sprint(got); s != "[100 101]" || len(idx["book-club"]) != 1 {
+		t.Errorf("SegmentIndex: got cheese-night %s, book-club %d", s, len(idx["book-club"]))
+	}
+	st := ilof.ComputeStats(eps, nil, 0)
+	if len(st.Segments) == 0 || st.Segments[0].Name != "cheese-night" || st.Segments[0].Count != 2 {
+		t.Errorf("ComputeStats: got segments %+v", st.Segments)
+	} else if len(st.Tags) == 0 || st.Tags[0].Name != "cheese-night" || st.Tags[0].Count != 2 {
+		t.Errorf("ComputeStats: got tags %+v, want segments counted as tags", st.Tags)
+	}
+	var pages []string
+	for _, p := range ilof.TagPages(eps, nil) {
+		pages = append(pages, p.Tag)
+	}
+	if s := fmt.Sprint(pages); s != "[book-club books cheese-night game-night]" {
+		t.Errorf("TagPages: got %s", s)
+	}
+
+	// Segments round-trip through the front matter, with optional start times.
+	eps[1].AddSegment("cheese-night").Start = ilof.Offset(42 * time.Minute)
+	path := filepath.Join(t.TempDir(), "2021-01-05-0100.md")
+	if err := ilof.WriteEpisode(path, eps[1]); err != nil {
+		t.Fatalf("WriteEpisode failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "segments:\n  - name: cheese-night\n    start: 42:00\n  - name: game-night\n"; !strings.Contains(string(data), want) {
+		t.Errorf("WriteEpisode: output lacks %q:\n%s", want, data)
+	}
+	ep, err := ilof.LoadEpisode(path)
+	if err != nil {
+		t.Fatalf("LoadEpisode failed: %v", err)
+	} else if s := ep.Segment("cheese-night"); s == nil || s.Start != ilof.Offset(42*time.Minute) {
+		t.Errorf("LoadEpisode: got segments %+v", ep.Segments)
+	}
+
+	// Validation reports duplicate segments and segments also given as tags.
+	ep.Segments = append(ep.Segments, &ilof.Segment{Name: "game-night"})
+	ep.Tags = []string{"cheese-night"}
+	if err := ilof.WriteEpisode(path, ep); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := ilof.ValidateEpisodeFile(path)
+	if err != nil {
+		t.Fatalf("ValidateEpisodeFile failed: %v", err)
+	}
+	var msgs []string
+	for _, f := range fs {
+		msgs = append(msgs, f.Message)
+	}
+	if s := strings.Join(msgs, "; "); s != `segment "cheese-night" is also a tag; duplicate segment "game-night"` {
+		t.Errorf("ValidateEpisodeFile: got %q", s)
+	}
+}
+
+func TestGuestEpisodes(t *testing.T) {
+	guests, err := ilof.DecodeGuests(strings.NewReader(`{"guests": [
+  {"name": "Alice Able", "twitter": "aable", "episodes": [101, 99]},
+  {"name": "Bob Baker", "episodes": [100]}
+]}`))
+	if err != nil {
+		t.Fatalf("DecodeGuests failed: %v", err)
+	}
+	eps, err := ilof.DecodeEpisodes(strings.NewReader(`{"episodes": [
+  {"episode": 101, "airDate": "2021-01-07", "guestNames": ["Alice Able"]},
+  {"episode": 100, "airDate": "2021-01-05", "guestNames": ["Bob Baker", "alice able"]},
+  {"episode": 99, "airDate": "2021-01-04"},
+  {"episode": "special-1", "airDate": "2021-01-06", "guestNames": ["Carol Cole"]}
+]}`))
+	if err != nil {
+		t.Fatalf("DecodeEpisodes failed: %v", err)
+	}
+
+	tests := []struct {
+		key, name, want string
+	}{
+		{"@AAble", "Alice Able", "[99 100 101]"}, // by handle, and by name on episode 100
+		{"bob baker", "Bob Baker", "[100]"},
+		{"Carol Cole", "Carol Cole", "[special-1]"}, // only in the episode data
+		{"nobody", "", "[]"},
+	}
+	for _, tc := range tests {
+		g, got := ilof.GuestEpisodes(guests, eps, tc.key)
+		var name string
+		if g != nil {
+			name = g.Name
+		}
+		var labels []ilof.Label
+		for _, ep := range got {
+			labels = append(labels, ep.Episode)
+		}
+		if name != tc.name || fmt.Sprint(labels) != tc.want {
+			t.Errorf("GuestEpisodes(%q): got %q %v, want %q %s", tc.key, name, labels, tc.name, tc.want)
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestYouTubeVideosInfo(t *testing.T) {
+	// Serve canned API responses in place of the YouTube Data API.
+	var requests []int
+	old := http.DefaultClient.Transport
+	t.Cleanup(func() { http.DefaultClient.Transport = old })
+	http.DefaultClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host != "www.googleapis.com" || req.URL.Path != "/youtube/v3/videos" {
+			t.Errorf("Unexpected request: %s", req.URL)
+		}
+		ids := strings.Split(req.URL.Query().Get("id"), ",")
+		requests = append(requests, len(ids))
+		var items []string
+		for _, id := range ids {
+			switch id {
+			case "gone":
+				continue // deleted videos are omitted
+			case "secret":
+				items = append(items, `{"id": "secret", "snippet": {"title": "Secret"}, "status": {"uploadStatus": "processed", "privacyStatus": "private"}}`)
+			default:
+				items = append(items, fmt.Sprintf(`{"id": %q, "snippet": {"title": "Video %[1]s"},
+  "contentDetails": {"duration": "PT1H2M3S"}, "status": {"uploadStatus": "processed", "privacyStatus": "public"}}`, id))
+			}
+		}
+		rec := httptest.NewRecorder()
+		fmt.Fprintf(rec, `{"items": [%s]}`, strings.Join(items, ","))
+		return rec.Result(), nil
+	})
+
+	ids := []string{"gone", "secret"}
+	for i := 0; i < 118; i++ {
+		ids = append(ids, fmt.Sprintf("v%d", i))
+	}
+	infos, err := ilof.YouTubeVideosInfo(context.Background(), ids, "key")
+	if err != nil {
+		t.Fatalf("YouTubeVideosInfo failed: %v", err)
+	}
+	if got := fmt.Sprint(requests); got != "[50 50 20]" {
+		t.Errorf("Request batches: got %s, want [50 50 20]", got)
+	}
+	if len(infos) != 119 {
+		t.Errorf("YouTubeVideosInfo: got %d videos, want 119", len(infos))
+	}
+	if v := infos["v7"]; v == nil || v.ID != "v7" || v.Title != "Video v7" || v.Duration != time.Hour+2*time.Minute+3*time.Second || !v.Available() {
+		t.Errorf("Video v7: got %+v", v)
+	}
+	if v := infos["secret"]; v == nil || v.Available() {
+		t.Errorf("Video secret: got %+v, want unavailable", v)
+	}
+
+	if _, err := ilof.YouTubeVideoInfo(context.Background(), "gone", "key"); err == nil {
+		t.Error("YouTubeVideoInfo(gone): got nil error")
+	}
+}
+
+func TestSitePolling(t *testing.T) {
+	// Serve a site whose latest episode advances after the first poll.
+	latest := 100
+	old := http.DefaultClient.Transport
+	t.Cleanup(func() { http.DefaultClient.Transport = old })
+	http.DefaultClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		switch req.URL.Path {
+		case "/latest.json":
+			fmt.Fprintf(rec, `{"latest": {"episode": %d, "airDate": "2021-01-05"}}`, latest)
+		case "/episodes.json":
+			var eps []string
+			for i := 99; i <= latest; i++ {
+				eps = append(eps, fmt.Sprintf(`{"episode": %d}`, i))
+			}
+			fmt.Fprintf(rec, `{"episodes": [%s, {"episode": "holiday"}]}`, strings.Join(eps, ","))
+		default:
+			http.Error(rec, "not found", http.StatusNotFound)
+		}
+		return rec.Result(), nil
+	})
+
+	ctx := context.Background()
+	poll := func() (ilof.Label, ilof.Label) {
+		t.Helper()
+		ep, err := ilof.LatestEpisode(ctx)
+		if err != nil {
+			t.Fatalf("LatestEpisode failed: %v", err)
+		}
+		eps, err := ilof.AllEpisodes(ctx)
+		if err != nil {
+			t.Fatalf("AllEpisodes failed: %v", err)
+		}
+		return ep.Episode, ilof.NextEpisodeLabel(eps)
+	}
+	if got, next := poll(); got != "100" || next != "101" {
+		t.Errorf("First poll: got latest %s, next %s; want 100, 101", got, next)
+	}
+	latest = 101
+	if got, next := poll(); got != "101" || next != "102" {
+		t.Errorf("Second poll: got latest %s, next %s; want 101, 102", got, next)
+	}
+}
+
+func TestEpisodesByGuestFallback(t *testing.T) {
+	// The site serves episodes.json but not guests.json, so the guests are
+	// derived from the guest names of the episodes.
+	old := http.DefaultClient.Transport
+	t.Cleanup(func() { http.DefaultClient.Transport = old })
+	http.DefaultClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		if req.URL.Path == "/episodes.json" {
+			fmt.Fprint(rec, `{"episodes": [
+  {"episode": 101, "airDate": "2021-01-07", "guestNames": ["Alice Able", "Bob Baker"]},
+  {"episode": 100, "airDate": "2021-01-05", "guestNames": ["alice able"]},
+  {"episode": 99, "airDate": "2021-01-04", "guestNames": ["Carol Cole"]}
+]}`)
+		} else {
+			http.Error(rec, "not found", http.StatusNotFound)
+		}
+		return rec.Result(), nil
+	})
+
+	ctx := context.Background()
+	guests, err := ilof.AllGuests(ctx)
+	if err != nil {
+		t.Fatalf("AllGuests failed: %v", err)
+	} else if len(guests) != 3 || guests[0].Name != "Alice Able" || len(guests[0].Episodes) != 2 {
+		t.Errorf("AllGuests: got %v, want 3 guests with Alice Able on 2 episodes", guests)
+	}
+	eps, err := ilof.EpisodesByGuest(ctx, "ALICE ABLE")
+	if err != nil {
+		t.Fatalf("EpisodesByGuest failed: %v", err)
+	}
+	var got []string
+	for _, ep := range eps {
+		got = append(got, string(ep.Episode))
+	}
+	if s := fmt.Sprint(got); s != "[100 101]" {
+		t.Errorf("EpisodesByGuest: got %s, want [100 101]", s)
+	}
+	if _, err := ilof.EpisodesByGuest(ctx, "Dana Doe"); !errors.Is(err, ilof.ErrGuestNotFound) {
+		t.Errorf("EpisodesByGuest(Dana Doe): got %v, want %v", err, ilof.ErrGuestNotFound)
+	}
+}
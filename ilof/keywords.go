@@ -0,0 +1,203 @@
+package ilof
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"bitbucket.org/creachadair/stringset"
+)
+
+// maxKeyPhrase is the maximum number of words in a key phrase.
+const maxKeyPhrase = 3
+
+// Keywords returns up to k key phrases of text, in decreasing order of
+// importance, using EnglishStopwords. See KeywordsWith.
+func Keywords(text string, k int) []string { return KeywordsWith(text, k, EnglishStopwords) }
+
+// KeywordsWith returns up to k key phrases of text, in decreasing order of
+// importance. If k <= 0, all the candidate phrases are returned.
+//
+// Phrases are found by the RAKE method: candidate phrases are the runs of
+// words between punctuation and stopwords, and each phrase is scored by the
+// sum over its words of the ratio of the word's degree (the total length of
+// the candidate phrases it occurs in) to its frequency. Phrases are returned
+// in lower case, and have at most three words; single words must have at
+// least three letters and not be numbers.
+func KeywordsWith(text string, k int, stop stringset.Set) []string {
+	phrases := candidatePhrases(text, stop, maxKeyPhrase)
+	freq := make(map[string]int)
+	degree := make(map[string]int)
+	for _, p := range phrases {
+		for _, w := range p {
+			freq[w]++
+			degree[w] += len(p)
+		}
+	}
+
+	score := make(map[string]float64)
+	for _, p := range phrases {
+		key := strings.Join(p, " ")
+		if _, ok := score[key]; ok {
+			continue
+		}
+		var s float64
+		for _, w := range p {
+			s += float64(degree[w]) / float64(freq[w])
+		}
+		score[key] = s
+	}
+	out := make([]string, 0, len(score))
+	for key := range score {
+		out = append(out, key)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if score[out[i]] != score[out[j]] {
+			return score[out[i]] > score[out[j]]
+		}
+		return out[i] < out[j]
+	})
+	if k > 0 && len(out) > k {
+		out = out[:k]
+	}
+	return out
+}
+
+// candidatePhrases splits text into the candidate key phrases used by
+// KeywordsWith, the runs of words between punctuation and stopwords. Runs
+// longer than maxLen words are split; if maxLen <= 0 runs are not split.
+func candidatePhrases(text string, stop stringset.Set, maxLen int) [][]string {
+	var out [][]string
+	var cur []string
+	var word strings.Builder
+	endPhrase := func() {
+		if len(cur) == 1 && !goodKeyword(cur[0]) {
+			cur = nil
+		}
+		if len(cur) != 0 {
+			out = append(out, cur)
+		}
+		cur = nil
+	}
+	endWord := func() {
+		w := strings.Trim(word.String(), "'")
+		word.Reset()
+		if w == "" {
+			return
+		} else if stop.Contains(strings.ReplaceAll(w, "'", "")) {
+			endPhrase()
+			return
+		}
+		cur = append(cur, w)
+		if len(cur) == maxLen {
+			endPhrase()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		case isApostrophe(r) && word.Len() != 0:
+			word.WriteByte('\'')
+		case unicode.IsSpace(r):
+			endWord()
+		default:
+			endWord()
+			endPhrase()
+		}
+	}
+	endWord()
+	endPhrase()
+	return out
+}
+
+// goodKeyword reports whether w is acceptable as a single-word keyword.
+func goodKeyword(w string) bool {
+	var letters int
+	for _, r := range w {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return letters >= 3
+}
+
+// KeyPhrases returns up to k key phrases of text, in decreasing order of
+// importance, for use as the topics of an episode. If k <= 0, all candidate
+// phrases are returned.
+//
+// Candidate phrases are found as for KeywordsWith, using the stopwords of the
+// corpus, or EnglishStopwords if it has none. Each phrase is scored by the
+// mean TF-IDF weight of its words, with the term frequencies taken from text
+// and the document frequencies from the corpus, so that phrases whose words
+// are frequent in text but rare in the corpus are preferred. Phrases that
+// recur as a whole score higher. URLs in text are ignored. A phrase is
+// omitted if all its words occur in phrases already chosen.
+func (c *Corpus) KeyPhrases(text string, k int) []string {
+	stop := c.opts.Stopwords
+	if stop == nil {
+		stop = EnglishStopwords
+	}
+	// Remove link targets and URLs, which are not topics.
+	text = bareURL.ReplaceAllString(mdLinkRE.ReplaceAllString(text, "$1"), ".")
+
+	count := make(map[string]int)
+	words := make(map[string][]string)
+	for _, p := range candidatePhrases(text, stop, maxKeyPhrase) {
+		key := strings.Join(p, " ")
+		count[key]++
+		words[key] = p
+	}
+	tf := make(map[string]int)
+	for _, w := range WordsWith(text, c.opts) {
+		tf[w]++
+	}
+
+	c.mu.Lock()
+	score := make(map[string]float64, len(count))
+	for key, n := range count {
+		var sum float64
+		var nw int
+		for _, w := range words[key] {
+			for _, t := range WordsWith(w, c.opts) {
+				sum += float64(tf[t]) * c.idf(t)
+				nw++
+			}
+		}
+		if nw != 0 {
+			score[key] = (1 + math.Log(float64(n))) * sum / float64(nw)
+		}
+	}
+	c.mu.Unlock()
+
+	cands := make([]string, 0, len(score))
+	for key := range score {
+		cands = append(cands, key)
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		if score[cands[i]] != score[cands[j]] {
+			return score[cands[i]] > score[cands[j]]
+		}
+		return cands[i] < cands[j]
+	})
+
+	var out []string
+	used := make(map[string]bool)
+	for _, key := range cands {
+		if k > 0 && len(out) == k {
+			break
+		}
+		novel := false
+		for _, w := range words[key] {
+			if !used[w] {
+				novel = true
+			}
+			used[w] = true
+		}
+		if novel {
+			out = append(out, key)
+		}
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package ilof
+
+import (
+	"regexp"
+	"strings"
+)
+
+// An EpisodeURL is a URL mentioned by an episode, with the name of the field
+// where it occurs.
+type EpisodeURL struct {
+	Field string `json:"field"` // e.g., "youtube", "links", "detail"
+	URL   string `json:"url"`
+}
+
+// bareURL matches URLs embedded in markdown or plain text. A URL may contain
+// balanced parentheses, as in Wikipedia links, but an unbalanced parenthesis
+// ends it, so that URLs in markdown links and parenthetical remarks match.
+var bareURL = regexp.MustCompile(`https?://(?:[^\s<>()\[\]"']|\([^\s<>()\[\]"']*\))+`)
+
+// TextURLs returns the URLs embedded in the text of s, in order of occurrence.
+func TextURLs(s string) []string {
+	var out []string
+	for _, u := range bareURL.FindAllString(s, -1) {
+		out = append(out, strings.TrimRight(u, ".,;:!?*_"))
+	}
+	return out
+}
+
+// URLs returns all the URLs mentioned by e, from its front matter fields, its
+// list of links, and the text of its summary and body. Each distinct URL is
+// reported once, attributed to the first field where it occurs.
+func (e *Episode) URLs() []EpisodeURL {
+	var out []EpisodeURL
+	seen := make(map[string]bool)
+	add := func(field, url string) {
+		if url != "" && !seen[url] {
+			seen[url] = true
+			out = append(out, EpisodeURL{Field: field, URL: url})
+		}
+	}
+	add("crowdcast", e.CrowdcastURL)
+	add("youtube", e.YouTubeURL)
+	add("acast", e.AcastURL)
+	add("audio-file", e.AudioFileURL)
+	for _, link := range e.Links {
+		add("links", link.URL)
+	}
+	for _, u := range TextURLs(e.Summary) {
+		add("summary", u)
+	}
+	for _, u := range TextURLs(e.Detail) {
+		add("detail", u)
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package ilof
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DedupLinks returns links with duplicates removed, keeping the first of
+// each set of links whose URLs are the same after NormalizeURL. If the link
+// kept has no title, it takes the title of a later duplicate. Links without
+// URLs are kept as they are. The second result describes each link removed.
+func DedupLinks(links []*Link) ([]*Link, []string) {
+	var out []*Link
+	var removed []string
+	seen := make(map[string]*Link)
+	for _, link := range links {
+		if link.URL == "" {
+			out = append(out, link)
+			continue
+		}
+		key := NormalizeURL(link.URL)
+		if first, ok := seen[key]; ok {
+			if first.Title == "" {
+				first.Title = link.Title
+			}
+			removed = append(removed, link.URL)
+			continue
+		}
+		seen[key] = link
+		out = append(out, link)
+	}
+	return out, removed
+}
+
+// maxTitlePage is the maximum size of a page read by FetchTitle. The title is
+// nearly always near the top of a page, so larger pages are not needed.
+const maxTitlePage = 1 << 20
+
+// FetchTitle fetches the HTML page at url and returns its title. The
+// OpenGraph title (og:title) is preferred if present, since it usually omits
+// the site name; otherwise the content of the <title> element is used.
+func FetchTitle(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/html")
+	opts := *DefaultFetchOptions
+	if opts.MaxBodySize == 0 || opts.MaxBodySize > maxTitlePage {
+		opts.MaxBodySize = maxTitlePage
+	}
+	data, err := loadRequestWith(ctx, req, &opts)
+	if err != nil && !errors.Is(err, errBodyTooLarge) {
+		return "", err
+	}
+	title := pageTitle(data)
+	if title == "" {
+		return "", fmt.Errorf("no title found at %q", url)
+	}
+	return title, nil
+}
+
+// pageTitle returns the title of the HTML page in data, or "".
+func pageTitle(data []byte) string {
+	var title, ogTitle string
+	var inTitle bool
+	tok := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch tok.Next() {
+		case html.ErrorToken:
+			if ogTitle != "" {
+				return ogTitle
+			}
+			return strings.Join(strings.Fields(title), " ")
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := tok.Token()
+			switch t.DataAtom {
+			case atom.Title:
+				inTitle = title == ""
+			case atom.Meta:
+				if p, _ := getAttr(t, "property"); p == "og:title" {
+					c, _ := getAttr(t, "content")
+					ogTitle = strings.Join(strings.Fields(c), " ")
+				}
+			case atom.Body:
+				if ogTitle != "" {
+					return ogTitle
+				}
+				return strings.Join(strings.Fields(title), " ")
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(tok.Text())
+			}
+		case html.EndTagToken:
+			if tok.Token().DataAtom == atom.Title {
+				inTitle = false
+			}
+		}
+	}
+}
+
+// FillLinkTitles fetches the titles of the links of ep that lack them, and
+// returns a description of each title added. Links whose titles cannot be
+// fetched are left unchanged, and the errors are reported in the second
+// result, one per link.
+func FillLinkTitles(ctx context.Context, ep *Episode) ([]string, []error) {
+	var added []string
+	var errs []error
+	for _, link := range ep.Links {
+		if link.Title != "" || link.URL == "" {
+			continue
+		}
+		title, err := FetchTitle(ctx, link.URL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("link %q: %w", link.URL, err))
+			continue
+		}
+		link.Title = title
+		added = append(added, fmt.Sprintf("links: title %q for %s", title, link.URL))
+	}
+	return added, errs
+}
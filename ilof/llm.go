@@ -0,0 +1,74 @@
+package ilof
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// llmTimeout is the minimum time limit for a chat completion request.
+const llmTimeout = 5 * time.Minute
+
+// A ChatClient calls the chat completions method of an OpenAI-compatible
+// language model API.
+type ChatClient struct {
+	BaseURL string // e.g., "https://api.openai.com/v1"
+	APIKey  string // sent as a bearer token, if set
+	Model   string // the model name to request
+}
+
+// Complete sends a system instruction and a user prompt to the model, and
+// returns the text of the first choice in the response.
+func (c *ChatClient) Complete(ctx context.Context, system, prompt string) (string, error) {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	body, err := json.Marshal(struct {
+		Model    string     `json:"model"`
+		Messages []*message `json:"messages"`
+	}{
+		Model: c.Model,
+		Messages: []*message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	u := strings.TrimSuffix(c.BaseURL, "/") + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	// Completions can take much longer than an ordinary fetch.
+	opts := *DefaultFetchOptions
+	if opts.Timeout != 0 {
+		opts.Timeout = max(opts.Timeout, llmTimeout)
+	}
+	bits, err := loadRequestWith(ctx, req, &opts)
+	if err != nil {
+		return "", err
+	}
+	var rsp struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(bits, &rsp); err != nil {
+		return "", fmt.Errorf("decoding response: %w", err)
+	} else if len(rsp.Choices) == 0 {
+		return "", errors.New("no choices in response")
+	}
+	return strings.TrimSpace(rsp.Choices[0].Message.Content), nil
+}
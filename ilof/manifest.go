@@ -0,0 +1,243 @@
+package ilof
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/creachadair/atomicfile"
+)
+
+// A Manifest records the SHA-256 digests of the data files written by the
+// generator tools, so that hand edits or corruption of the generated files
+// can be detected. Paths are relative to the repository root, with forward
+// slashes.
+type Manifest struct {
+	Files map[string]*ManifestEntry `json:"files"`
+}
+
+// A ManifestEntry describes a generated file in a Manifest.
+type ManifestEntry struct {
+	SHA256    string    `json:"sha256"`
+	Generator string    `json:"generator"` // the name of the tool
+	Version   string    `json:"version"`   // the version of the tool
+	Generated time.Time `json:"generated"`
+}
+
+// LoadManifest loads a manifest from the file at path.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := new(Manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]*ManifestEntry)
+	}
+	return m, nil
+}
+
+// Save writes m to the file at path.
+func (m *Manifest) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteData(path, append(data, '\n'), 0644)
+}
+
+// Record records that generator wrote data to the file at path, which is
+// relative to the current directory. If the file already has an entry for
+// the same contents and generator, it is not changed, so that regenerating
+// a file without changes does not change the manifest. Record reports
+// whether path is recorded; paths outside the current directory are not.
+func (m *Manifest) Record(generator, path string, data []byte) bool {
+	key, ok := manifestKey(path)
+	if !ok {
+		return false
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	if old, ok := m.Files[key]; ok && old.SHA256 == digest && old.Generator == generator {
+		return true
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]*ManifestEntry)
+	}
+	m.Files[key] = &ManifestEntry{
+		SHA256:    digest,
+		Generator: generator,
+		Version:   ToolVersion(),
+		Generated: time.Now().UTC().Truncate(time.Second),
+	}
+	return true
+}
+
+// A ManifestProblem reports a generated file that does not match its entry in
+// a manifest.
+type ManifestProblem struct {
+	Path    string         `json:"path"`
+	Problem string         `json:"problem"` // "missing" or "modified"
+	Entry   *ManifestEntry `json:"entry"`
+}
+
+func (p *ManifestProblem) String() string {
+	return fmt.Sprintf("%s: %s since generated by %s (%s) at %s",
+		p.Path, p.Problem, p.Entry.Generator, p.Entry.Version, p.Entry.Generated.Format(time.RFC3339))
+}
+
+// Verify checks the files recorded in m, relative to the current directory,
+// and returns the problems found in order of path.
+func (m *Manifest) Verify() ([]*ManifestProblem, error) {
+	var out []*ManifestProblem
+	for key, e := range m.Files {
+		data, err := os.ReadFile(filepath.FromSlash(key))
+		if os.IsNotExist(err) {
+			out = append(out, &ManifestProblem{Path: key, Problem: "missing", Entry: e})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != e.SHA256 {
+			out = append(out, &ManifestProblem{Path: key, Problem: "modified", Entry: e})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, nil
+}
+
+// RecordGenerated records in the manifest at manifestPath that generator
+// wrote the files at paths, creating the manifest if it does not exist.
+// Paths outside the current directory, which should be the repository root,
+// are ignored.
+func RecordGenerated(manifestPath, generator string, paths ...string) error {
+	m, err := LoadManifest(manifestPath)
+	if os.IsNotExist(err) {
+		m = &Manifest{Files: make(map[string]*ManifestEntry)}
+	} else if err != nil {
+		return err
+	}
+	var n int
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if m.Record(generator, path, data) {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	return m.Save(manifestPath)
+}
+
+// Generated returns the paths of the files in m written by generator, in
+// lexicographic order.
+func (m *Manifest) Generated(generator string) []string {
+	var out []string
+	for key, e := range m.Files {
+		if e.Generator == generator {
+			out = append(out, key)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Forget removes the entry for the file at path, which is relative to the
+// current directory, and reports whether m had one.
+func (m *Manifest) Forget(path string) bool {
+	key, ok := manifestKey(path)
+	if !ok {
+		return false
+	} else if _, ok := m.Files[key]; !ok {
+		return false
+	}
+	delete(m.Files, key)
+	return true
+}
+
+// ForgetGenerated removes the entries for the files at paths, which have been
+// deleted, from the manifest at manifestPath. It is not an error if the
+// manifest does not exist.
+func ForgetGenerated(manifestPath string, paths ...string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	m, err := LoadManifest(manifestPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var n int
+	for _, path := range paths {
+		if m.Forget(path) {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	return m.Save(manifestPath)
+}
+
+// manifestKey returns the manifest key for path, relative to the current
+// directory, and reports whether path is inside the current directory.
+func manifestKey(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// ToolVersion returns the version of the running program, from its build
+// information: the module version if it has one, otherwise the VCS revision.
+func ToolVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	} else if v := bi.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	var rev, dirty string
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				dirty = "+dirty"
+			}
+		}
+	}
+	if rev == "" {
+		return "devel"
+	}
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	return rev + dirty
+}
@@ -0,0 +1,148 @@
+package ilof
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// An AudioMatch records a candidate pairing of an audio episode from the Acast
+// feed with an episode of the webcast.
+type AudioMatch struct {
+	Audio   *AudioEpisode `json:"audio"`
+	Episode *Episode      `json:"episode"`
+	Score   float64       `json:"score"`
+}
+
+// maxAudioDelay is the longest interval after the air date of an episode that
+// we expect its audio to be published. In practice most audio is posted within
+// a day or two, but there have been some long stragglers.
+const maxAudioDelay = 30 * 24 * time.Hour
+
+// MatchAudio scores each of the candidate episodes in eps against audio, and
+// returns the candidates with nonzero scores in decreasing order of score.
+//
+// The score combines the similarity of the audio title and description to the
+// guest names, topics, and summary of the episode, ignoring common words such
+// as "the" and "and", with a penalty for the distance between the air date
+// and the publication date of the audio. Audio published before an episode
+// aired is never matched to it.
+func MatchAudio(audio *AudioEpisode, eps []*Episode) []*AudioMatch {
+	text := strings.Join([]string{audio.Title, audio.Subtitle, audio.Description}, " ")
+
+	var out []*AudioMatch
+	for _, ep := range eps {
+		w := audioDateWeight(time.Time(ep.Date), audio.Published)
+		if w == 0 {
+			continue
+		}
+		sim := SimilarityWith(text, episodeText(ep), stopWordOptions)
+		if sim == 0 {
+			continue
+		}
+		out = append(out, &AudioMatch{Audio: audio, Episode: ep, Score: sim * w})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+	return out
+}
+
+// ConfidentMatch reports whether the first of the given candidates, as
+// returned by MatchAudio, has a score of at least min and exceeds the score of
+// the runner-up (if any) by at least margin.
+func ConfidentMatch(ms []*AudioMatch, min, margin float64) (*AudioMatch, bool) {
+	if len(ms) == 0 || ms[0].Score < min {
+		return nil, false
+	} else if len(ms) > 1 && ms[0].Score-ms[1].Score < margin {
+		return nil, false
+	}
+	return ms[0], true
+}
+
+// episodeText returns the text of ep used for matching against audio.
+func episodeText(ep *Episode) string {
+	parts := append([]string{ep.Topics, ep.Summary}, ep.Guests...)
+	return strings.Join(parts, " ")
+}
+
+// audioDateWeight returns a weight in [0, 1] reflecting how plausible it is
+// that audio published at pub belongs to an episode that aired on air.
+func audioDateWeight(air, pub time.Time) float64 {
+	// Air dates do not carry a time, so allow for audio published on the same
+	// calendar day in any time zone.
+	delay := pub.Sub(air)
+	if delay < -24*time.Hour || delay > maxAudioDelay {
+		return 0
+	} else if delay <= 72*time.Hour {
+		return 1
+	}
+	return 1 - float64(delay-72*time.Hour)/float64(maxAudioDelay)
+}
+
+// A VideoMatch records a candidate pairing of a YouTube video with an
+// episode of the webcast.
+type VideoMatch struct {
+	Video   *VideoInfo `json:"video"`
+	Episode *Episode   `json:"episode"`
+	Score   float64    `json:"score"` // in [0, 1]
+}
+
+// MatchVideo scores each of the candidate videos against ep, and returns the
+// candidates with nonzero scores in decreasing order of score.
+//
+// The score combines whether the video title gives the number of the episode
+// with the similarity of the video title and description to the guest names,
+// topics, and summary of the episode, weighted by the distance between the
+// air date and the publication date of the video. A video whose title gives
+// the number of a different episode is never matched, nor is a video
+// published more than a week before or after the episode aired.
+func MatchVideo(ep *Episode, videos []*VideoInfo) []*VideoMatch {
+	text := episodeText(ep)
+	var out []*VideoMatch
+	for _, v := range videos {
+		w := videoDateWeight(time.Time(ep.Date), v.PublishedAt)
+		if w == 0 {
+			continue
+		}
+		var num float64
+		if m := titleEpisode.FindStringSubmatch(v.Title); m != nil {
+			if Label(m[1]).Number() != ep.Episode.Number() {
+				continue // a different episode
+			}
+			num = 1
+		}
+		var sim float64
+		if text != "" {
+			sim = SimilarityWith(v.Title+" "+v.Description, text, stopWordOptions)
+		}
+		if score := w * (0.6*num + 0.4*min(1, 2*sim)); score > 0 {
+			out = append(out, &VideoMatch{Video: v, Episode: ep, Score: score})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+	return out
+}
+
+// maxVideoSkew is the longest interval between the air date of an episode
+// and the publication date of its video that we consider plausible. Live
+// streams are often published when they are scheduled, before they air, and
+// recordings were sometimes uploaded a few days late.
+const maxVideoSkew = 7 * 24 * time.Hour
+
+// videoDateWeight returns a weight in [0, 1] reflecting how plausible it is
+// that a video published at pub belongs to an episode that aired on air.
+func videoDateWeight(air, pub time.Time) float64 {
+	skew := pub.Sub(air)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxVideoSkew {
+		return 0
+	} else if skew <= 48*time.Hour {
+		return 1
+	}
+	return 1 - 0.5*float64(skew-48*time.Hour)/float64(maxVideoSkew-48*time.Hour)
+}
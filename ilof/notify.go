@@ -0,0 +1,37 @@
+package ilof
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Notify posts a message to the webhook at url. The message is sent as a JSON
+// object with the text in both the "text" and "content" fields, which are
+// understood by Slack and Discord respectively, along with optional details.
+func Notify(ctx context.Context, url, text string, detail any) error {
+	bits, err := json.Marshal(struct {
+		Text    string `json:"text"`
+		Content string `json:"content"`
+		Detail  any    `json:"detail,omitempty"`
+	}{Text: text, Content: text, Detail: detail})
+	if err != nil {
+		return fmt.Errorf("encoding message: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(bits))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := DefaultFetchOptions.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook failed: %s", rsp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package ilof
+
+import (
+	"sort"
+	"time"
+)
+
+// A Throwback describes an episode for an "on this day" listing.
+type Throwback struct {
+	Episode Label    `json:"episode"`
+	Date    Date     `json:"date"`
+	Year    int      `json:"year"`
+	URL     string   `json:"url"` // the episode page on the site
+	Topics  string   `json:"topics,omitempty"`
+	Summary string   `json:"summary,omitempty"`
+	Guests  []string `json:"guests,omitempty"`
+	Links   []*Link  `json:"links,omitempty"`
+}
+
+// DayKey returns the key for the calendar day of d in the map returned by
+// OnThisDay, formatted "01-02".
+func DayKey(d Date) string { return time.Time(d).Format("01-02") }
+
+// OnThisDay returns a map from each calendar day, as formatted by DayKey, to
+// the episodes that aired on that day in any year, in order of air date. Days
+// on which no episode aired are omitted. The guests of each episode are taken
+// from its Guests field, which the caller should populate from the guest list.
+func OnThisDay(eps []*Episode) map[string][]*Throwback {
+	out := make(map[string][]*Throwback)
+	for _, ep := range eps {
+		if ep.Date.IsZero() {
+			continue
+		}
+		key := DayKey(ep.Date)
+		out[key] = append(out[key], &Throwback{
+			Episode: ep.Episode,
+			Date:    ep.Date,
+			Year:    time.Time(ep.Date).Year(),
+			URL:     ep.PageURL(),
+			Topics:  ep.Topics,
+			Summary: ep.Summary,
+			Guests:  ep.Guests,
+			Links:   ep.Links,
+		})
+	}
+	for _, tbs := range out {
+		sort.SliceStable(tbs, func(i, j int) bool {
+			if !time.Time(tbs[i].Date).Equal(time.Time(tbs[j].Date)) {
+				return time.Time(tbs[i].Date).Before(time.Time(tbs[j].Date))
+			}
+			return labelLess(tbs[i].Episode, tbs[j].Episode)
+		})
+	}
+	return out
+}
+
+// Throwbacks returns the episodes from days, as returned by OnThisDay, that
+// aired on the calendar day of d in years before the year of d.
+func Throwbacks(days map[string][]*Throwback, d Date) []*Throwback {
+	year := time.Time(d).Year()
+	var out []*Throwback
+	for _, tb := range days[DayKey(d)] {
+		if tb.Year < year {
+			out = append(out, tb)
+		}
+	}
+	return out
+}
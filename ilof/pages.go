@@ -0,0 +1,150 @@
+package ilof
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/creachadair/atomicfile"
+)
+
+// A PageSet is a set of pages generated into one directory of the site,
+// keyed by file name. Each generated page contains a marker line in its front
+// matter, so that pages left over from an earlier run can be told apart from
+// pages written by hand. Pages without the marker are never overwritten or
+// removed.
+type PageSet struct {
+	Dir    string
+	Marker string            // e.g., ArchiveMarker
+	Pages  map[string][]byte // file name ⇒ contents
+
+	// If Manifest is set, Apply records the pages it writes in the manifest
+	// at that path as written by Generator, and drops the entries for the
+	// pages it removes.
+	Manifest  string
+	Generator string
+
+	Kind string // the kind of pages, for log messages, e.g., "tag"
+}
+
+// Plan compares ps with the contents of its directory. It returns the paths
+// of the pages whose contents differ from what is on disk, the paths of
+// generated pages in the directory that are no longer in ps, and the paths
+// of pages in ps that differ from existing pages not written by a generator.
+// All three are sorted.
+func (ps *PageSet) Plan() (stale, remove, conflict []string) {
+	for name, data := range ps.Pages {
+		path := filepath.Join(ps.Dir, name)
+		old, err := os.ReadFile(path)
+		if err == nil && bytes.Equal(old, data) {
+			continue
+		} else if err == nil && !ps.isGenerated(old) {
+			conflict = append(conflict, path)
+		} else {
+			stale = append(stale, path)
+		}
+	}
+	if ls, err := os.ReadDir(ps.Dir); err == nil {
+		for _, e := range ls {
+			if _, ok := ps.Pages[e.Name()]; ok || e.IsDir() || filepath.Ext(e.Name()) != ".md" {
+				continue
+			}
+			path := filepath.Join(ps.Dir, e.Name())
+			if data, err := os.ReadFile(path); err == nil && ps.isGenerated(data) {
+				remove = append(remove, path)
+			}
+		}
+	}
+	sort.Strings(stale)
+	sort.Strings(remove)
+	sort.Strings(conflict)
+	return
+}
+
+// isGenerated reports whether data contains the marker line of ps.
+func (ps *PageSet) isGenerated(data []byte) bool {
+	return bytes.Contains(data, []byte("\n"+ps.Marker+"\n"))
+}
+
+// Page returns the contents of the page at path, as reported by Plan.
+func (ps *PageSet) Page(path string) []byte { return ps.Pages[filepath.Base(path)] }
+
+// Apply writes the stale pages and removes the pages reported by Plan, and
+// updates the manifest if ps has one.
+func (ps *PageSet) Apply(stale, remove []string) error {
+	if len(stale) != 0 {
+		if err := os.MkdirAll(ps.Dir, 0755); err != nil {
+			return err
+		}
+	}
+	for _, path := range stale {
+		if err := atomicfile.WriteData(path, ps.Page(path), 0644); err != nil {
+			return err
+		}
+	}
+	for _, path := range remove {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	if ps.Manifest == "" {
+		return nil
+	}
+	if err := RecordGenerated(ps.Manifest, ps.Generator, stale...); err != nil {
+		return err
+	}
+	return ForgetGenerated(ps.Manifest, remove...)
+}
+
+// A PageMode selects what Update does with the changes found by Plan.
+type PageMode int
+
+const (
+	ApplyPages PageMode = iota // write and remove pages as needed
+	CheckPages                 // log the changes without making them
+	PrintPages                 // print the pages to be written without writing them
+)
+
+// Update plans the changes to the directory of ps and handles them as
+// selected by mode, logging what it does. Pages in conflict are logged and
+// skipped in every mode. Update returns the number of pages that are out of
+// date, no longer needed, or in conflict.
+func (ps *PageSet) Update(mode PageMode, w io.Writer) (int, error) {
+	stale, remove, conflict := ps.Plan()
+	for _, path := range conflict {
+		log.Printf("- Not generated by %s, skipped: %s", ps.Generator, path)
+	}
+	n := len(stale) + len(remove) + len(conflict)
+
+	switch mode {
+	case PrintPages:
+		for _, path := range stale {
+			fmt.Fprintf(w, "==> %s <==\n%s\n", path, ps.Page(path))
+		}
+		for _, path := range remove {
+			fmt.Fprintf(w, "==> %s <== (remove)\n", path)
+		}
+	case CheckPages:
+		for _, path := range stale {
+			log.Printf("Out of date: %s", path)
+		}
+		for _, path := range remove {
+			log.Printf("No longer needed: %s", path)
+		}
+		if n != 0 {
+			log.Printf("%d %s pages need to be regenerated", n, ps.Kind)
+		} else {
+			log.Printf("All %d %s pages are up to date", len(ps.Pages), ps.Kind)
+		}
+	default:
+		if err := ps.Apply(stale, remove); err != nil {
+			return n, err
+		}
+		log.Printf("Wrote %d of %d %s pages, removed %d", len(stale), len(ps.Pages), ps.Kind, len(remove))
+	}
+	return n, nil
+}
@@ -0,0 +1,161 @@
+package ilof
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A PlatformEpisode records the listing of an audio episode on a podcast
+// platform other than Acast.
+type PlatformEpisode struct {
+	Platform  string    `json:"platform"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url"`
+	Published time.Time `json:"published"`
+}
+
+// LoadApplePodcasts fetches the episode listing for the Apple Podcasts show
+// with the given collection ID, using the public iTunes lookup API.
+// The API reports at most the most recent 200 episodes.
+func LoadApplePodcasts(ctx context.Context, showID string) ([]*PlatformEpisode, error) {
+	q := make(url.Values)
+	q.Set("id", showID)
+	q.Set("entity", "podcastEpisode")
+	q.Set("limit", "200")
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://itunes.apple.com/lookup?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	bits, err := loadRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var msg struct {
+		Results []struct {
+			Kind        string    `json:"wrapperType"`
+			TrackName   string    `json:"trackName"`
+			TrackURL    string    `json:"trackViewUrl"`
+			ReleaseDate time.Time `json:"releaseDate"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(bits, &msg); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	var out []*PlatformEpisode
+	for _, r := range msg.Results {
+		if r.Kind != "podcastEpisode" {
+			continue // the show record itself
+		}
+		out = append(out, &PlatformEpisode{
+			Platform:  "apple",
+			Title:     r.TrackName,
+			URL:       r.TrackURL,
+			Published: r.ReleaseDate,
+		})
+	}
+	return out, nil
+}
+
+// LoadSpotifyShow fetches the episode listing for the Spotify show with the
+// given ID. The clientID and secret are the credentials of a Spotify API
+// application, used to obtain an access token.
+func LoadSpotifyShow(ctx context.Context, showID, clientID, secret string) ([]*PlatformEpisode, error) {
+	token, err := spotifyToken(ctx, clientID, secret)
+	if err != nil {
+		return nil, fmt.Errorf("getting access token: %w", err)
+	}
+
+	var out []*PlatformEpisode
+	next := fmt.Sprintf("https://api.spotify.com/v1/shows/%s/episodes?limit=50&market=US", url.PathEscape(showID))
+	for next != "" {
+		req, err := http.NewRequestWithContext(ctx, "GET", next, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		bits, err := loadRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		var msg struct {
+			Items []struct {
+				Name        string `json:"name"`
+				ReleaseDate string `json:"release_date"`
+				URLs        struct {
+					Spotify string `json:"spotify"`
+				} `json:"external_urls"`
+			} `json:"items"`
+			Next string `json:"next"`
+		}
+		if err := json.Unmarshal(bits, &msg); err != nil {
+			return nil, fmt.Errorf("decoding response: %w", err)
+		}
+		for _, item := range msg.Items {
+			pub, _ := time.Parse(dateFormat, item.ReleaseDate)
+			out = append(out, &PlatformEpisode{
+				Platform:  "spotify",
+				Title:     item.Name,
+				URL:       item.URLs.Spotify,
+				Published: pub,
+			})
+		}
+		next = msg.Next
+	}
+	return out, nil
+}
+
+func spotifyToken(ctx context.Context, clientID, secret string) (string, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://accounts.spotify.com/api/token",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(clientID, secret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	bits, err := loadRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	var msg struct {
+		Token string `json:"access_token"`
+	}
+	if err := json.Unmarshal(bits, &msg); err != nil {
+		return "", err
+	} else if msg.Token == "" {
+		return "", fmt.Errorf("no access token in reply")
+	}
+	return msg.Token, nil
+}
+
+// titleSimilarity is the metric used to compare episode titles across
+// platforms. Character n-grams tolerate the spelling fixes and punctuation
+// changes a title picks up between one listing and another, which cost a
+// short title a whole word under the word metrics.
+var titleSimilarity = &SimilarityOpts{Metric: NGramCosine}
+
+// FindPlatformEpisode returns the listing from ps that corresponds to the
+// given audio episode, or nil if none does. Listings are matched by title
+// using titleSimilarity, and must have been published within a few days of
+// the audio episode.
+func FindPlatformEpisode(audio *AudioEpisode, ps []*PlatformEpisode) *PlatformEpisode {
+	var best *PlatformEpisode
+	var bestScore float64
+	for _, p := range ps {
+		if d := p.Published.Sub(audio.Published); d < -72*time.Hour || d > 72*time.Hour {
+			continue
+		}
+		if s := titleSimilarity.Similarity(audio.Title, p.Title); s > bestScore {
+			best, bestScore = p, s
+		}
+	}
+	if bestScore < 0.8 {
+		return nil
+	}
+	return best
+}
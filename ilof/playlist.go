@@ -0,0 +1,314 @@
+package ilof
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// YouTubeScope is the OAuth scope needed to manage the playlists of a
+// YouTube channel.
+const YouTubeScope = "https://www.googleapis.com/auth/youtube"
+
+const (
+	googleAuthURL  = "https://accounts.google.com/o/oauth2/v2/auth"
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+)
+
+// NewOAuthSecret returns a random string suitable for the state parameter or
+// the PKCE code verifier of an OAuth authorization request.
+func NewOAuthSecret() (string, error) {
+	var buf [32]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf[:]), nil
+}
+
+// PKCEChallenge returns the S256 code challenge for the PKCE code verifier,
+// as defined by RFC 7636.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// YouTubeAuthURL returns the URL of the page where the owner of a YouTube
+// channel grants the OAuth client clientID access to manage its playlists.
+// On approval, the browser is redirected to redirectURI with a "code" query
+// parameter, to be passed to YouTubeAuthorize, and a "state" parameter that
+// the caller should check matches state. The verifier is the PKCE code
+// verifier, which must also be passed to YouTubeAuthorize.
+func YouTubeAuthURL(clientID, redirectURI, state, verifier string) string {
+	q := make(url.Values)
+	q.Set("client_id", clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("response_type", "code")
+	q.Set("scope", YouTubeScope)
+	q.Set("access_type", "offline")
+	q.Set("prompt", "consent")
+	q.Set("state", state)
+	q.Set("code_challenge", PKCEChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	return googleAuthURL + "?" + q.Encode()
+}
+
+// YouTubeAuthorize exchanges an authorization code obtained via the page at
+// YouTubeAuthURL for a refresh token, which can be stored and passed to
+// NewYouTubeClient. The verifier must be the one passed to YouTubeAuthURL.
+func YouTubeAuthorize(ctx context.Context, clientID, secret, code, redirectURI, verifier string) (string, error) {
+	tok, err := googleToken(ctx, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"client_secret": {secret},
+		"code":          {code},
+		"code_verifier": {verifier},
+		"redirect_uri":  {redirectURI},
+	})
+	if err != nil {
+		return "", err
+	} else if tok.Refresh == "" {
+		return "", fmt.Errorf("no refresh token in reply")
+	}
+	return tok.Refresh, nil
+}
+
+type googleTokenReply struct {
+	Access  string `json:"access_token"`
+	Refresh string `json:"refresh_token"`
+}
+
+func googleToken(ctx context.Context, form url.Values) (*googleTokenReply, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", googleTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	bits, err := loadRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var msg googleTokenReply
+	if err := json.Unmarshal(bits, &msg); err != nil {
+		return nil, err
+	} else if msg.Access == "" {
+		return nil, fmt.Errorf("no access token in reply")
+	}
+	return &msg, nil
+}
+
+// A YouTubeClient calls the YouTube Data API on behalf of the owner of a
+// channel, so that it can read and modify the playlists of the channel.
+type YouTubeClient struct {
+	token  string
+	apiKey string // if set, use this key in place of an access token
+}
+
+// NewYouTubeReader returns a client authorized by a YouTube Data API key
+// rather than OAuth. It can read public playlists, but not modify them.
+func NewYouTubeReader(apiKey string) *YouTubeClient {
+	return &YouTubeClient{apiKey: apiKey}
+}
+
+// NewYouTubeClient returns a client authorized by the given OAuth client
+// credentials and refresh token.
+func NewYouTubeClient(ctx context.Context, clientID, secret, refreshToken string) (*YouTubeClient, error) {
+	tok, err := googleToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {clientID},
+		"client_secret": {secret},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting access token: %w", err)
+	}
+	return &YouTubeClient{token: tok.Access}, nil
+}
+
+// call calls the specified method of the YouTube Data API with the given HTTP
+// method, query parameters, and JSON request body (if not nil), and decodes
+// the JSON response into v.
+func (c *YouTubeClient) call(ctx context.Context, verb, method string, q url.Values, body, v any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	if c.apiKey != "" {
+		q.Set("key", c.apiKey)
+	}
+	u := "https://www.googleapis.com/youtube/v3/" + method + "?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, verb, u, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Add("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	bits, err := loadRequest(ctx, req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bits, v)
+}
+
+// A PlaylistItem is an entry in a YouTube playlist.
+type PlaylistItem struct {
+	ID       string // the ID of the playlist item
+	VideoID  string
+	Title    string
+	Position int
+}
+
+// URL returns the watch URL for the video of the item.
+func (p *PlaylistItem) URL() string { return youTubeWatchURL(p.VideoID) }
+
+// PlaylistItems returns the items of the specified playlist, in order of
+// position.
+func (c *YouTubeClient) PlaylistItems(ctx context.Context, playlistID string) ([]*PlaylistItem, error) {
+	var out []*PlaylistItem
+	var page string
+	for {
+		q := make(url.Values)
+		q.Set("playlistId", playlistID)
+		q.Set("part", "snippet")
+		q.Set("maxResults", "50")
+		if page != "" {
+			q.Set("pageToken", page)
+		}
+		var items struct {
+			Items []struct {
+				ID      string `json:"id"`
+				Snippet struct {
+					Title    string `json:"title"`
+					Position int    `json:"position"`
+					Resource struct {
+						VideoID string `json:"videoId"`
+					} `json:"resourceId"`
+				} `json:"snippet"`
+			} `json:"items"`
+			Next string `json:"nextPageToken"`
+		}
+		if err := c.call(ctx, "GET", "playlistItems", q, nil, &items); err != nil {
+			return nil, err
+		}
+		for _, item := range items.Items {
+			out = append(out, &PlaylistItem{
+				ID:       item.ID,
+				VideoID:  item.Snippet.Resource.VideoID,
+				Title:    item.Snippet.Title,
+				Position: item.Snippet.Position,
+			})
+		}
+		if items.Next == "" {
+			break
+		}
+		page = items.Next
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Position < out[j].Position })
+	return out, nil
+}
+
+// AddPlaylistItem adds the specified video to the end of a playlist.
+func (c *YouTubeClient) AddPlaylistItem(ctx context.Context, playlistID, videoID string) error {
+	type resource struct {
+		Kind    string `json:"kind"`
+		VideoID string `json:"videoId"`
+	}
+	type snippet struct {
+		PlaylistID string   `json:"playlistId"`
+		Resource   resource `json:"resourceId"`
+	}
+	body := struct {
+		Snippet snippet `json:"snippet"`
+	}{snippet{playlistID, resource{"youtube#video", videoID}}}
+	var reply struct {
+		ID string `json:"id"`
+	}
+	return c.call(ctx, "POST", "playlistItems", url.Values{"part": {"snippet"}}, body, &reply)
+}
+
+// A VideoCatalog lists the YouTube videos of the episodes, in order of air
+// date, for populating playlists.
+type VideoCatalog struct {
+	All      []string         // all video IDs
+	BySeason map[int][]string // video IDs by season
+	Episodes map[string]Label // video ID ⇒ episode label
+}
+
+// NewVideoCatalog returns a catalog of the videos of eps. An episode with no
+// season is assigned to the season of its air date according to seasons.
+// Episodes without a YouTube video are omitted.
+func NewVideoCatalog(eps []*Episode, seasons Seasons) *VideoCatalog {
+	eps = append([]*Episode(nil), eps...)
+	sort.SliceStable(eps, func(i, j int) bool {
+		di, dj := time.Time(eps[i].Date), time.Time(eps[j].Date)
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return labelLess(eps[i].Episode, eps[j].Episode)
+	})
+	vc := &VideoCatalog{BySeason: make(map[int][]string), Episodes: make(map[string]Label)}
+	for _, ep := range eps {
+		id, ok := YouTubeVideoID(ep.YouTubeURL)
+		if !ok {
+			continue
+		} else if _, dup := vc.Episodes[id]; dup {
+			continue
+		}
+		vc.Episodes[id] = ep.Episode
+		vc.All = append(vc.All, id)
+		num := ep.Season
+		if num == 0 {
+			num = seasons.SeasonOf(ep.Date)
+		}
+		if num > 0 {
+			vc.BySeason[num] = append(vc.BySeason[num], id)
+		}
+	}
+	return vc
+}
+
+// A PlaylistPlan describes the changes needed to bring a playlist in sync
+// with the catalog.
+type PlaylistPlan struct {
+	Playlist string
+	Add      []string        // video IDs to add, in order
+	Unknown  []*PlaylistItem // items whose videos are not in the catalog
+}
+
+// PlanPlaylist compares the items of a playlist with the video IDs that
+// belong in it, in order. Videos in want that are not in the playlist are to
+// be added; items whose videos are not episodes in vc are reported, but are
+// not removed.
+func (vc *VideoCatalog) PlanPlaylist(playlistID string, want []string, have []*PlaylistItem) *PlaylistPlan {
+	plan := &PlaylistPlan{Playlist: playlistID}
+	present := make(map[string]bool)
+	for _, item := range have {
+		present[item.VideoID] = true
+		if _, ok := vc.Episodes[item.VideoID]; !ok {
+			plan.Unknown = append(plan.Unknown, item)
+		}
+	}
+	for _, id := range want {
+		if !present[id] {
+			plan.Add = append(plan.Add, id)
+		}
+	}
+	return plan
+}
@@ -0,0 +1,175 @@
+package ilof
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Progress reports the progress of a batch operation.
+type Progress struct {
+	Done  int    // the number of items complete
+	Total int    // the total number of items
+	Item  string // a description of the item most recently completed
+}
+
+// Fraction returns the fraction of the items complete, from 0 to 1.
+func (p Progress) Fraction() float64 {
+	if p.Total <= 0 {
+		return 1
+	}
+	return float64(p.Done) / float64(p.Total)
+}
+
+// A ProgressFunc is called to report the progress of a batch operation.
+// Calls for a single operation are not concurrent.
+type ProgressFunc func(Progress)
+
+// BatchOptions control the execution of RunBatch.
+type BatchOptions struct {
+	Workers  int          // the number of concurrent workers; if ≤ 0, 1
+	Progress ProgressFunc // if non-nil, called as each item is completed
+}
+
+// RunBatch calls f for each index 0 ≤ i < n, using up to opts.Workers
+// concurrent goroutines, and returns when the calls are complete. A nil opts
+// uses one worker and does not report progress. The value returned by f
+// describes item i, and is reported as the Item of the progress when it is
+// complete. If ctx ends, items not yet started are skipped, and RunBatch
+// reports the error from ctx.
+func RunBatch(ctx context.Context, n int, opts *BatchOptions, f func(ctx context.Context, i int) string) error {
+	if opts == nil {
+		opts = new(BatchOptions)
+	}
+	var (
+		mu      sync.Mutex
+		numDone int
+		wg      sync.WaitGroup
+		work    = make(chan int)
+	)
+	for i := 0; i < max(opts.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				item := f(ctx, i)
+				mu.Lock()
+				numDone++
+				if opts.Progress != nil {
+					opts.Progress(Progress{Done: numDone, Total: n, Item: item})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(work)
+	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case work <- i:
+		}
+	}
+	return nil
+}
+
+// A ProgressBar displays the progress of a batch operation. On a terminal it
+// draws a bar that is updated in place; otherwise it writes a line of
+// progress periodically. Its Update method is a ProgressFunc.
+//
+// A ProgressBar is also an io.Writer, which writes above the bar, so that a
+// program can direct its log output to the bar while it is displayed.
+type ProgressBar struct {
+	w     io.Writer
+	label string
+	tty   bool
+	every time.Duration // minimum time between updates
+
+	mu   sync.Mutex
+	last time.Time
+	line string // the bar as last drawn, or "" if none
+}
+
+// NewProgressBar returns a ProgressBar that writes to w, labelling the
+// progress with label, e.g., "Checking links".
+func NewProgressBar(w io.Writer, label string) *ProgressBar {
+	b := &ProgressBar{w: w, label: label, every: 10 * time.Second}
+	if f, ok := w.(*os.File); ok && IsTerminal(f) {
+		b.tty = true
+		b.every = 100 * time.Millisecond
+	}
+	return b
+}
+
+// IsTerminal reports whether f is a terminal. Other character devices, such
+// as /dev/null, are not terminals.
+func IsTerminal(f *os.File) bool { return term.IsTerminal(int(f.Fd())) }
+
+// Update displays p. Updates are rate limited, except that the first and last
+// updates of the operation are always displayed.
+func (b *ProgressBar) Update(p Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	done := p.Done >= p.Total
+	if !done && !b.last.IsZero() && now.Sub(b.last) < b.every {
+		return
+	}
+	b.last = now
+	if !b.tty {
+		fmt.Fprintf(b.w, "%s: %d/%d (%.0f%%) %s\n", b.label, p.Done, p.Total, 100*p.Fraction(), p.Item)
+		return
+	}
+	const width = 30
+	fill := int(p.Fraction() * width)
+	bar := strings.Repeat("=", fill) + strings.Repeat(" ", width-fill)
+	b.line = fmt.Sprintf("%s [%s] %d/%d %s", b.label, bar, p.Done, p.Total, truncate(p.Item, 40))
+	fmt.Fprint(b.w, "\r\033[K"+b.line)
+	if done {
+		fmt.Fprintln(b.w)
+		b.line = ""
+	}
+}
+
+// Write writes data to the output of b, above the bar if one is displayed.
+func (b *ProgressBar) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.line == "" {
+		return b.w.Write(data)
+	}
+	fmt.Fprint(b.w, "\r\033[K")
+	nw, err := b.w.Write(data)
+	fmt.Fprint(b.w, b.line)
+	return nw, err
+}
+
+// Finish ends the display of the bar, if it is displayed.
+func (b *ProgressBar) Finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.line != "" {
+		fmt.Fprintln(b.w)
+		b.line = ""
+	}
+}
+
+// truncate returns s truncated to at most n runes, marking any truncation
+// with an ellipsis.
+func truncate(s string, n int) string {
+	rs := []rune(s)
+	if len(rs) <= n {
+		return s
+	}
+	return string(rs[:n-1]) + "…"
+}
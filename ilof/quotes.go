@@ -0,0 +1,195 @@
+package ilof
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"bitbucket.org/creachadair/stringset"
+)
+
+// A Quote is a candidate quotation from a transcript.
+type Quote struct {
+	Text  string  `json:"text"`
+	Start float64 `json:"startSec"` // seconds since start
+	End   float64 `json:"endSec"`   // seconds since start
+	Score float64 `json:"score"`    // higher is more quotable
+}
+
+// QuoteOptions control the selection of quotes by Transcript.Quotes. A nil
+// *QuoteOptions uses default values.
+type QuoteOptions struct {
+	MinWords int // the minimum length of a quote in words; default 8
+	MaxWords int // the maximum length of a quote in words; default 40
+}
+
+func (o *QuoteOptions) minWords() int {
+	if o == nil || o.MinWords <= 0 {
+		return 8
+	}
+	return o.MinWords
+}
+
+func (o *QuoteOptions) maxWords() int {
+	if o == nil || o.MaxWords <= 0 {
+		return 40
+	}
+	return o.MaxWords
+}
+
+// Quotes returns up to n candidate quotations from the captions of t, in
+// decreasing order of score. If n <= 0, all candidates are returned.
+//
+// Candidates are complete sentences, after removing annotations such as
+// "[Music]", speaker-change markers, and filler words. Sentences are scored by
+// the proportion of content words they contain, the rarity of those words
+// within the transcript, and their length; sentences ending in an exclamation
+// or containing emphatic words score higher. Sentences that begin with a
+// conjunction or pronoun, and so probably depend on what came before, and
+// questions score lower. Captions without sentence punctuation, as produced by
+// automatic captioning, yield no candidates.
+func (t *Transcript) Quotes(n int, opts *QuoteOptions) []*Quote {
+	sents := t.sentences()
+
+	// Count the number of sentences containing each word, for rarity.
+	df := make(map[string]int)
+	for _, s := range sents {
+		for w := range stringset.New(s.words...) {
+			df[w]++
+		}
+	}
+	lnN := math.Log(float64(len(sents) + 1))
+
+	minWords, maxWords := opts.minWords(), opts.maxWords()
+	ideal := float64(minWords+maxWords) / 2
+	seen := make(map[string]bool)
+	var out []*Quote
+	for _, s := range sents {
+		key := strings.Join(s.words, " ")
+		if len(s.words) < minWords || len(s.words) > maxWords || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		content := make(map[string]bool)
+		for _, w := range s.words {
+			if !EnglishStopwords.Contains(strings.ReplaceAll(w, "'", "")) && goodKeyword(w) {
+				content[w] = true
+			}
+		}
+		if len(content) == 0 {
+			continue
+		}
+		var rarity float64
+		for w := range content {
+			rarity += math.Log(float64(len(sents)+1)/float64(df[w]+1)) / lnN
+		}
+		rarity /= float64(len(content))
+		density := float64(len(content)) / float64(len(s.words))
+		length := math.Max(0, 1-math.Abs(float64(len(s.words))-ideal)/ideal)
+
+		score := 0.5*rarity + 0.3*density + 0.2*length
+		if strings.HasSuffix(s.text, "!") {
+			score += 0.2
+		}
+		for _, w := range s.words {
+			if emphaticWords[w] {
+				score += 0.1
+				break
+			}
+		}
+		if danglingWords[s.words[0]] {
+			score *= 0.5
+		}
+		if strings.HasSuffix(s.text, "?") {
+			score *= 0.7
+		}
+		out = append(out, &Quote{
+			Text:  s.text,
+			Start: s.start,
+			End:   s.end,
+			Score: math.Round(score*1000) / 1000,
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// emphaticWords are words that suggest a strongly-stated, quotable sentence.
+var emphaticWords = map[string]bool{
+	"always": true, "never": true, "best": true, "worst": true, "love": true,
+	"hate": true, "important": true, "remember": true, "truth": true,
+	"everyone": true, "nobody": true, "absolutely": true, "greatest": true,
+}
+
+// danglingWords are words that, at the start of a sentence, suggest that it
+// depends on the sentence before.
+var danglingWords = map[string]bool{
+	"and": true, "but": true, "so": true, "because": true, "or": true,
+	"which": true, "also": true, "then": true, "it": true, "this": true,
+	"that": true, "these": true, "those": true, "he": true, "she": true,
+	"they": true, "him": true, "her": true, "them": true, "yeah": true,
+}
+
+// A sentence is a complete sentence of a transcript, with its time span.
+type sentence struct {
+	text       string
+	words      []string // as parsed by Words
+	start, end float64
+}
+
+var (
+	annotationRE = regexp.MustCompile(`\[[^\]]*\]|\([A-Za-z ]*\)|>>+`)
+	fillerRE     = regexp.MustCompile(`(?i)\b(?:u+m+|u+h+|e+r+m+|h+m+)\b,?`)
+)
+
+// cleanCaption removes annotations, speaker markers, and filler words from
+// the text of a caption.
+func cleanCaption(s string) string {
+	s = annotationRE.ReplaceAllString(s, " ")
+	s = fillerRE.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// sentences splits the cleaned text of t into sentences. The time of each
+// word is estimated by spacing the words of a caption evenly over its
+// duration.
+func (t *Transcript) sentences() []*sentence {
+	var out []*sentence
+	var cur []string
+	var start float64
+	for _, c := range t.Captions {
+		toks := strings.Fields(cleanCaption(c.Text))
+		for i, tok := range toks {
+			if len(cur) == 0 {
+				start = c.Start + c.Duration*float64(i)/float64(len(toks))
+			}
+			cur = append(cur, tok)
+			if !endsSentence(tok) {
+				continue
+			}
+			text := strings.Join(cur, " ")
+			if words := Words(text); len(words) != 0 {
+				out = append(out, &sentence{
+					text:  text,
+					words: words,
+					start: start,
+					end:   c.Start + c.Duration*float64(i+1)/float64(len(toks)),
+				})
+			}
+			cur = nil
+		}
+	}
+	return out
+}
+
+// endsSentence reports whether tok ends with sentence punctuation, possibly
+// followed by closing quotes or parentheses.
+func endsSentence(tok string) bool {
+	tok = strings.TrimRight(tok, `"'”’)`)
+	return strings.HasSuffix(tok, ".") || strings.HasSuffix(tok, "!") || strings.HasSuffix(tok, "?")
+}
@@ -0,0 +1,121 @@
+package ilof
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A HostLimiter limits the rate of requests to each host, using a token
+// bucket per host. Requests to different hosts do not affect each other.
+// A HostLimiter is safe for concurrent use by multiple goroutines.
+type HostLimiter struct {
+	rate  float64 // tokens per second
+	burst int
+
+	mu      sync.Mutex
+	rates   map[string]hostRate // per-host overrides
+	buckets map[string]*bucket
+}
+
+type hostRate struct {
+	rate  float64
+	burst int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewHostLimiter returns a limiter that permits rate requests per second to
+// each host, with bursts of up to burst requests. If rate <= 0, requests are
+// not limited; if burst < 1, a burst of 1 is used.
+func NewHostLimiter(rate float64, burst int) *HostLimiter {
+	return &HostLimiter{
+		rate:    rate,
+		burst:   max(burst, 1),
+		rates:   make(map[string]hostRate),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// SetHostRate sets the rate and burst for requests to host, overriding the
+// defaults given to NewHostLimiter.
+func (h *HostLimiter) SetHostRate(host string, rate float64, burst int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rates[strings.ToLower(host)] = hostRate{rate: rate, burst: max(burst, 1)}
+}
+
+// Wait blocks until a request to host is permitted, or ctx ends. A nil
+// *HostLimiter permits all requests immediately.
+func (h *HostLimiter) Wait(ctx context.Context, host string) error {
+	if h == nil {
+		return nil
+	}
+	d := h.reserve(strings.ToLower(host), time.Now())
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// WaitURL blocks until a request to the host of rawURL is permitted, or ctx
+// ends. If rawURL cannot be parsed, it does not wait.
+func (h *HostLimiter) WaitURL(ctx context.Context, rawURL string) error {
+	if h == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	return h.Wait(ctx, u.Host)
+}
+
+// reserve takes a token from the bucket for host as of now, and returns how
+// long the caller must wait before using it.
+func (h *HostLimiter) reserve(host string, now time.Time) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hr, ok := h.rates[host]
+	if !ok {
+		hr = hostRate{rate: h.rate, burst: h.burst}
+	}
+	if hr.rate <= 0 {
+		return 0
+	}
+	b, ok := h.buckets[host]
+	if !ok {
+		b = &bucket{tokens: float64(hr.burst), last: now}
+		h.buckets[host] = b
+	}
+	if now.After(b.last) {
+		b.tokens = min(float64(hr.burst), b.tokens+now.Sub(b.last).Seconds()*hr.rate)
+		b.last = now
+	}
+
+	// Tokens may go negative, representing requests already waiting.
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / hr.rate * float64(time.Second))
+}
+
+// RateLimiter, if non-nil, limits the rate of HTTP requests made by this
+// package to each host. It is shared by all the fetches of the package,
+// including YouTube pages and captions, feeds, and link checks, so that batch
+// tools can set a single policy. Responses served from CaptionCache do not
+// wait for it.
+var RateLimiter *HostLimiter
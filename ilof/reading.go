@@ -0,0 +1,221 @@
+package ilof
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A ReadingKind classifies an item of a reading list.
+type ReadingKind string
+
+// The kinds of reading list items.
+const (
+	BookReading    ReadingKind = "book"
+	ArticleReading ReadingKind = "article"
+)
+
+// A ReadingItem is a book or article mentioned in one or more episodes.
+type ReadingItem struct {
+	Kind     ReadingKind `json:"kind"`
+	Title    string      `json:"title,omitempty"`
+	URL      string      `json:"url"`            // normalized
+	ISBN     string      `json:"isbn,omitempty"` // for books, if known
+	Episodes []Label     `json:"episodes,omitempty"`
+}
+
+// bookHosts are hosts whose product pages are books. Publisher hosts match
+// any subdomain, so that "press.uchicago.edu" matches "uchicago.edu".
+var bookHosts = map[string]bool{
+	"bookshop.org": true, "books.google.com": true, "goodreads.com": true,
+	"indiebound.org": true, "barnesandnoble.com": true, "powells.com": true,
+	"penguinrandomhouse.com": true, "simonandschuster.com": true,
+	"hachettebookgroup.com": true, "harpercollins.com": true,
+	"macmillan.com": true, "us.macmillan.com": true, "wwnorton.com": true,
+	"press.princeton.edu": true, "yalebooks.yale.edu": true,
+	"press.uchicago.edu": true, "hup.harvard.edu": true,
+	"global.oup.com": true, "cambridge.org": true, "mitpress.mit.edu": true,
+	"ucpress.edu": true, "brookings.edu": true,
+}
+
+// articleHosts are hosts whose pages, other than the home page, are articles.
+var articleHosts = map[string]bool{
+	"lawfareblog.com": true, "lawfaremedia.org": true, "nytimes.com": true,
+	"washingtonpost.com": true, "theatlantic.com": true, "newyorker.com": true,
+	"wsj.com": true, "politico.com": true, "foreignaffairs.com": true,
+	"foreignpolicy.com": true, "theguardian.com": true, "vox.com": true,
+	"slate.com": true, "wired.com": true, "nybooks.com": true,
+	"bloomberg.com": true, "ft.com": true, "economist.com": true,
+	"justsecurity.org": true, "substack.com": true, "medium.com": true,
+}
+
+var (
+	asinRE   = regexp.MustCompile(`/(?:dp|gp/product|exec/obidos/ASIN)/([0-9A-Z]{10})(?:[/?]|$)`)
+	isbn13RE = regexp.MustCompile(`(?:^|\D)(97[89]\d{10})(?:\D|$)`)
+	isbn10RE = regexp.MustCompile(`^\d{9}[\dX]$`)
+)
+
+// ClassifyReading reports whether the URL s refers to a book or an article,
+// and if so returns a reading list item for it with a normalized URL. Book
+// links on Amazon are reduced to their product page, and books are given an
+// ISBN where one can be found in the URL.
+func ClassifyReading(s string) (*ReadingItem, bool) {
+	u, err := url.Parse(NormalizeURL(s))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, false
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	if isAmazonHost(host) {
+		m := asinRE.FindStringSubmatch(u.Path)
+		if m == nil {
+			return nil, false
+		}
+		if host == "amzn.com" || host == "smile.amazon.com" {
+			host = "amazon.com"
+		}
+		item := &ReadingItem{Kind: BookReading, URL: "https://www." + host + "/dp/" + m[1]}
+		if isbn10RE.MatchString(m[1]) {
+			item.ISBN = m[1]
+		}
+		return item, true
+	}
+	if u.Path == "" || u.Path == "/" {
+		return nil, false
+	} else if matchHost(bookHosts, host) {
+		item := &ReadingItem{Kind: BookReading, URL: u.String()}
+		if m := isbn13RE.FindStringSubmatch(u.Path + "?" + u.RawQuery); m != nil {
+			item.ISBN = m[1]
+		}
+		if host == "bookshop.org" && item.ISBN != "" {
+			item.URL = "https://bookshop.org/book/" + item.ISBN // drop affiliate codes
+		}
+		return item, true
+	} else if matchHost(articleHosts, host) {
+		return &ReadingItem{Kind: ArticleReading, URL: u.String()}, true
+	}
+	return nil, false
+}
+
+func isAmazonHost(host string) bool {
+	return host == "amazon.com" || host == "smile.amazon.com" || host == "amzn.com" ||
+		strings.HasPrefix(host, "amazon.") || strings.HasPrefix(host, "amazon.co.")
+}
+
+// matchHost reports whether host or any of its parent domains is in m.
+func matchHost(m map[string]bool, host string) bool {
+	for host != "" {
+		if m[host] {
+			return true
+		}
+		_, rest, ok := strings.Cut(host, ".")
+		if !ok || !strings.Contains(rest, ".") {
+			return false
+		}
+		host = rest
+	}
+	return false
+}
+
+// EpisodeReading returns the books and articles linked from ep, in its Links
+// and in the markdown links and bare URLs of its summary, topics, and detail.
+// Items are returned in order of first mention, each once.
+func EpisodeReading(ep *Episode) []*ReadingItem {
+	var out []*ReadingItem
+	seen := make(map[string]*ReadingItem)
+	add := func(title, link string) {
+		item, ok := ClassifyReading(link)
+		if !ok {
+			return
+		} else if old, ok := seen[item.key()]; ok {
+			if old.Title == "" {
+				old.Title = plainText(title)
+			}
+			return
+		}
+		item.Title = plainText(title)
+		item.Episodes = []Label{ep.Episode}
+		seen[item.key()] = item
+		out = append(out, item)
+	}
+	for _, link := range ep.Links {
+		add(link.Title, link.URL)
+	}
+	for _, text := range []string{ep.Summary, ep.Topics, ep.Detail} {
+		for _, m := range mdLinkRE.FindAllStringSubmatch(text, -1) {
+			if strings.HasPrefix(m[0], "!") {
+				continue // an image
+			}
+			_, target, _ := strings.Cut(m[0], "](")
+			add(m[1], strings.TrimSuffix(target, ")"))
+		}
+		for _, u := range TextURLs(mdLinkRE.ReplaceAllString(text, " ")) {
+			add("", u)
+		}
+	}
+	return out
+}
+
+// MergeReading combines the reading lists of several episodes into a single
+// catalog, in which each item appears once with all the episodes that
+// mention it. Items are identified by ISBN if known, otherwise by URL
+// ignoring any "www." prefix of the host. The result is ordered by kind, then
+// by decreasing number of episodes, then by title and URL.
+func MergeReading(lists ...[]*ReadingItem) []*ReadingItem {
+	byKey := make(map[string]*ReadingItem)
+	var out []*ReadingItem
+	for _, list := range lists {
+		for _, item := range list {
+			key := item.key()
+			old, ok := byKey[key]
+			if !ok {
+				cp := *item
+				cp.Episodes = append([]Label(nil), item.Episodes...)
+				byKey[key] = &cp
+				out = append(out, &cp)
+				continue
+			}
+			if old.Title == "" {
+				old.Title = item.Title
+			}
+			for _, ep := range item.Episodes {
+				if !containsLabel(old.Episodes, ep) {
+					old.Episodes = append(old.Episodes, ep)
+				}
+			}
+		}
+	}
+	for _, item := range out {
+		sort.Slice(item.Episodes, func(i, j int) bool { return labelLess(item.Episodes[i], item.Episodes[j]) })
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		} else if len(a.Episodes) != len(b.Episodes) {
+			return len(a.Episodes) > len(b.Episodes)
+		} else if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+		return a.URL < b.URL
+	})
+	return out
+}
+
+// key returns a string identifying the item, its ISBN if known or otherwise
+// its URL without a "www." prefix on the host.
+func (r *ReadingItem) key() string {
+	if r.ISBN != "" {
+		return "isbn:" + r.ISBN
+	}
+	return strings.Replace(r.URL, "://www.", "://", 1)
+}
+
+func containsLabel(labels []Label, x Label) bool {
+	for _, label := range labels {
+		if label == x {
+			return true
+		}
+	}
+	return false
+}
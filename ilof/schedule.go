@@ -0,0 +1,147 @@
+package ilof
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// A Schedule describes the regular broadcast schedule of the show.
+type Schedule struct {
+	Days     []string `json:"days" yaml:"days"`                             // weekday names, e.g., "Monday"
+	Start    string   `json:"start" yaml:"start"`                           // local start time, "15:04"
+	Duration string   `json:"duration,omitempty" yaml:"duration,omitempty"` // typical length, e.g., "1h"
+	TimeZone string   `json:"timeZone" yaml:"timezone"`                     // IANA zone name
+	Skip     []Date   `json:"skip,omitempty" yaml:"skip,omitempty"`         // scheduled days with no show
+
+	// Plausibility checks of episode dates, used by ValidateSchedule.
+	CheckSince Date `json:"checkSince,omitempty" yaml:"check-since,omitempty"` // check episodes aired on or after this date
+	MaxGap     int  `json:"maxGap,omitempty" yaml:"max-gap,omitempty"`         // maximum days between episodes; 0 for no limit
+
+	loc        *time.Location
+	days       map[time.Weekday]bool
+	hour, mins int // local start time
+	dur        time.Duration
+}
+
+// DefaultSchedule returns the standard schedule of the show: Monday,
+// Wednesday, and Friday at 5pm US Eastern time, for about an hour.
+func DefaultSchedule() *Schedule {
+	s := &Schedule{
+		Days:     []string{"Monday", "Wednesday", "Friday"},
+		Start:    "17:00",
+		Duration: "1h",
+		TimeZone: "America/New_York",
+	}
+	if err := s.init(); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// LoadSchedule loads a schedule from the YAML file at path. If path does not
+// exist, it returns DefaultSchedule without error.
+func LoadSchedule(path string) (*Schedule, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return DefaultSchedule(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	s := new(Schedule)
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if err := s.init(); err != nil {
+		return nil, fmt.Errorf("invalid schedule: %w", err)
+	}
+	return s, nil
+}
+
+func (s *Schedule) init() error {
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return err
+	}
+	s.loc = loc
+	s.days = make(map[time.Weekday]bool)
+	for _, day := range s.Days {
+		wd, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("unknown weekday %q", day)
+		}
+		s.days[wd] = true
+	}
+	t, err := time.Parse("15:04", s.Start)
+	if err != nil {
+		return fmt.Errorf("invalid start time: %w", err)
+	}
+	if s.MaxGap < 0 {
+		return fmt.Errorf("invalid max-gap: %d", s.MaxGap)
+	}
+	s.hour, s.mins = t.Hour(), t.Minute()
+	s.dur = time.Hour
+	if s.Duration != "" {
+		d, err := time.ParseDuration(s.Duration)
+		if err != nil {
+			return fmt.Errorf("invalid duration: %w", err)
+		}
+		s.dur = d
+	}
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday,
+	"wednesday": time.Wednesday, "thursday": time.Thursday, "friday": time.Friday,
+	"saturday": time.Saturday,
+}
+
+// Location returns the time zone of the schedule.
+func (s *Schedule) Location() *time.Location { return s.loc }
+
+// Length returns the typical length of a show.
+func (s *Schedule) Length() time.Duration { return s.dur }
+
+// IsShowDay reports whether the calendar date d is a regularly scheduled day.
+func (s *Schedule) IsShowDay(d Date) bool {
+	if !s.days[time.Time(d).Weekday()] {
+		return false
+	}
+	for _, skip := range s.Skip {
+		if skip.String() == d.String() {
+			return false
+		}
+	}
+	return true
+}
+
+// StartOn returns the scheduled start time of a show on the calendar date d.
+// The start is a wall-clock time, so it does not shift on the days when
+// daylight saving time begins or ends.
+func (s *Schedule) StartOn(d Date) time.Time {
+	t := time.Time(d)
+	return time.Date(t.Year(), t.Month(), t.Day(), s.hour, s.mins, 0, 0, s.loc)
+}
+
+// Upcoming returns the start times of the next n scheduled shows that begin
+// after t.
+func (s *Schedule) Upcoming(t time.Time, n int) []time.Time {
+	if len(s.days) == 0 {
+		return nil
+	}
+	var out []time.Time
+	lt := t.In(s.loc)
+	day := time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, time.UTC)
+	for len(out) < n {
+		d := Date(day)
+		if start := s.StartOn(d); s.IsShowDay(d) && start.After(t) {
+			out = append(out, start)
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return out
+}
@@ -0,0 +1,288 @@
+package ilof
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// A Schema is a JSON Schema definition, restricted to the keywords needed to
+// describe the episode and guest files. Schemas are encoded as JSON for use by
+// editors and other tools, and can be checked against YAML by ValidateYAML.
+type Schema struct {
+	Schema     string             `json:"$schema,omitempty"`
+	ID         string             `json:"$id,omitempty"`
+	Title      string             `json:"title,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	AnyOf      []*Schema          `json:"anyOf,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+}
+
+// schemaVersion is the JSON Schema dialect of the generated schemas.
+const schemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+// EpisodeSchema returns a schema for the front matter of an episode file,
+// generated from the Episode type.
+func EpisodeSchema() *Schema {
+	s := schemaFor(reflect.TypeOf(Episode{}))
+	s.Schema = schemaVersion
+	s.ID = BaseURL + "/schema/episode.schema.json"
+	s.Title = "Episode front matter"
+	return s
+}
+
+// GuestSchema returns a schema for the guest list file, generated from the
+// Guest type.
+func GuestSchema() *Schema {
+	return &Schema{
+		Schema: schemaVersion,
+		ID:     BaseURL + "/schema/guests.schema.json",
+		Title:  "Guest list",
+		Type:   "array",
+		Items:  schemaFor(reflect.TypeOf(Guest{})),
+	}
+}
+
+var (
+	labelType  = reflect.TypeOf(Label(""))
+	dateType   = reflect.TypeOf(Date{})
+	offsetType = reflect.TypeOf(Offset(0))
+)
+
+// schemaFor returns a schema for values of type t, as encoded in YAML. Struct
+// fields excluded from YAML are omitted, fields not marked omitempty are
+// required, and fields whose Go names end in "URL" have the "uri" format.
+func schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case labelType:
+		return &Schema{AnyOf: []*Schema{{Type: "number"}, {Type: "string"}}}
+	case dateType:
+		return &Schema{Type: "string", Format: "date"}
+	case offsetType:
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return &Schema{Type: "integer"}
+	case reflect.Float64, reflect.Float32:
+		return &Schema{Type: "number"}
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: schemaFor(t.Elem())}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			} else if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+			fs := schemaFor(f.Type)
+			if strings.HasSuffix(f.Name, "URL") && fs.Type == "string" {
+				fs.Format = "uri"
+			}
+			s.Properties[name] = fs
+			if !strings.Contains(opts, "omitempty") {
+				s.Required = append(s.Required, name)
+			}
+		}
+		return s
+	}
+	panic(fmt.Sprintf("schema: unsupported type %v", t))
+}
+
+// ValidateYAML checks the YAML document in data against s, and returns a
+// finding for each violation, with the line and column of the offending
+// value. Line numbers are increased by offset, for a document embedded in a
+// larger file.
+func (s *Schema) ValidateYAML(path string, data []byte, offset int) []*Finding {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []*Finding{{Path: path, Line: yamlErrorLine(err, offset), Severity: Error, Message: err.Error()}}
+	} else if len(doc.Content) == 0 {
+		return []*Finding{{Path: path, Line: offset + 1, Severity: Error, Message: "empty document"}}
+	}
+	var out []*Finding
+	s.check(doc.Content[0], "", func(node *yaml.Node, field, msg string, args ...any) {
+		out = append(out, &Finding{
+			Path:     path,
+			Line:     node.Line + offset,
+			Column:   node.Column,
+			Field:    field,
+			Severity: Error,
+			Message:  fmt.Sprintf(msg, args...),
+		})
+	})
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Line != out[j].Line {
+			return out[i].Line < out[j].Line
+		}
+		return out[i].Column < out[j].Column
+	})
+	return out
+}
+
+var yamlLineRE = regexp.MustCompile(`line (\d+)`)
+
+// yamlErrorLine extracts the line number from a YAML decoding error, or
+// returns 0 if it has none.
+func yamlErrorLine(err error, offset int) int {
+	if m := yamlLineRE.FindStringSubmatch(err.Error()); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			return n + offset
+		}
+	}
+	return 0
+}
+
+type reportFunc func(node *yaml.Node, field, msg string, args ...any)
+
+// check reports the violations of s by node, whose path from the document
+// root is field.
+func (s *Schema) check(node *yaml.Node, field string, report reportFunc) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if len(s.AnyOf) != 0 {
+		for _, alt := range s.AnyOf {
+			var n int
+			alt.check(node, field, func(*yaml.Node, string, string, ...any) { n++ })
+			if n == 0 {
+				return
+			}
+		}
+		report(node, field, "value %q does not match any permitted type", node.Value)
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			report(node, field, "expected a mapping")
+			return
+		}
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			seen[key] = true
+			if ps, ok := s.Properties[key]; ok {
+				ps.check(node.Content[i+1], joinField(field, key), report)
+			}
+		}
+		for _, key := range s.Required {
+			if !seen[key] {
+				report(node, field, "missing required field %q", key)
+			}
+		}
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			if !isNull(node) {
+				report(node, field, "expected a sequence")
+			}
+			return
+		}
+		for i, elt := range node.Content {
+			s.Items.check(elt, fmt.Sprintf("%s[%d]", field, i), report)
+		}
+	default:
+		if node.Kind != yaml.ScalarNode {
+			report(node, field, "expected a %s value", s.Type)
+			return
+		} else if isNull(node) {
+			return // treated as absent
+		}
+		s.checkScalar(node, field, report)
+	}
+}
+
+// checkScalar reports the violations of s by the scalar value in node.
+func (s *Schema) checkScalar(node *yaml.Node, field string, report reportFunc) {
+	tag := node.ShortTag()
+	switch s.Type {
+	case "boolean":
+		if tag != "!!bool" {
+			report(node, field, "expected true or false, not %q", node.Value)
+		}
+	case "integer":
+		if tag != "!!int" {
+			report(node, field, "expected an integer, not %q", node.Value)
+		}
+	case "number":
+		if tag != "!!int" && tag != "!!float" {
+			report(node, field, "expected a number, not %q", node.Value)
+		}
+	}
+	switch s.Format {
+	case "date":
+		if _, err := time.Parse(dateFormat, node.Value); err != nil {
+			report(node, field, "expected a date YYYY-MM-DD, not %q", node.Value)
+		}
+	case "uri":
+		if u, err := url.Parse(node.Value); err != nil || !u.IsAbs() || u.Host == "" {
+			report(node, field, "expected an absolute URL, not %q", node.Value)
+		}
+	}
+}
+
+func isNull(node *yaml.Node) bool { return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" }
+
+func joinField(base, key string) string {
+	if base == "" {
+		return key
+	}
+	return base + "." + key
+}
+
+// ValidateEpisodeSchema checks the front matter of the episode file at path
+// against EpisodeSchema. An error is reported only if the file cannot be
+// read; problems with its contents are reported as findings.
+func ValidateEpisodeSchema(path string) ([]*Finding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	front, _, err := splitFrontMatter(data)
+	if err != nil {
+		return []*Finding{{Path: path, Line: 1, Severity: Error, Message: err.Error()}}, nil
+	}
+	// The front matter starts after the opening "---" line.
+	return EpisodeSchema().ValidateYAML(path, []byte(front), 1), nil
+}
+
+// ValidateGuestSchema checks the guest list at path, or each of its shards,
+// against GuestSchema. An error is reported only if the files cannot be read;
+// problems with their contents are reported as findings.
+func ValidateGuestSchema(path string) ([]*Finding, error) {
+	files, err := GuestFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []*Finding
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, GuestSchema().ValidateYAML(file, data, 0)...)
+	}
+	return out, nil
+}
@@ -0,0 +1,52 @@
+package ilof
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// A Season records the start date of a season of the webcast.
+type Season struct {
+	Season int    `json:"season" yaml:"season"`
+	Start  Date   `json:"start" yaml:"start"`
+	Title  string `json:"title,omitempty" yaml:"title,omitempty"`
+
+	// The ID of the YouTube playlist of the episodes of the season, if any.
+	Playlist string `json:"playlist,omitempty" yaml:"playlist,omitempty"`
+}
+
+// Seasons is a list of seasons in order of start date.
+type Seasons []*Season
+
+// LoadSeasons loads a list of seasons from the YAML file at path.
+// The result is sorted in order of start date.
+func LoadSeasons(path string) (Seasons, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ss Seasons
+	if err := yaml.Unmarshal(data, &ss); err != nil {
+		return nil, err
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		return time.Time(ss[i].Start).Before(time.Time(ss[j].Start))
+	})
+	return ss, nil
+}
+
+// SeasonOf returns the number of the season containing date d, or 0 if d
+// precedes the start of the first season.
+func (ss Seasons) SeasonOf(d Date) int {
+	var num int
+	for _, s := range ss {
+		if time.Time(d).Before(time.Time(s.Start)) {
+			break
+		}
+		num = s.Season
+	}
+	return num
+}
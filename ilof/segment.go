@@ -0,0 +1,242 @@
+package ilof
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// A Segment records that a recurring segment of the show, such as cheese
+// night, occurred on an episode.
+type Segment struct {
+	Name  string `json:"name" yaml:"name"`                       // e.g., "cheese-night"
+	Start Offset `json:"start,omitempty" yaml:"start,omitempty"` // where it begins, if known
+}
+
+// Segment returns the segment of e with the given name, or nil if e does not
+// have that segment.
+func (e *Episode) Segment(name string) *Segment {
+	for _, s := range e.Segments {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// HasSegment reports whether e has the segment with the given name.
+func (e *Episode) HasSegment(name string) bool { return e.Segment(name) != nil }
+
+// AddSegment adds a segment with the given name to e, if it is not already
+// present, and returns the segment.
+func (e *Episode) AddSegment(name string) *Segment {
+	if s := e.Segment(name); s != nil {
+		return s
+	}
+	s := &Segment{Name: name}
+	e.Segments = append(e.Segments, s)
+	return s
+}
+
+// MoveTagsToSegments replaces each tag of e that is one of names with a
+// segment of the same name, and returns the names moved. Segments used to be
+// recorded as tags; this converts an episode to the current form.
+func (e *Episode) MoveTagsToSegments(names []string) []string {
+	isName := make(map[string]bool)
+	for _, name := range names {
+		isName[name] = true
+	}
+	var keep, moved []string
+	for _, tag := range e.Tags {
+		if isName[tag] {
+			e.AddSegment(tag)
+			moved = append(moved, tag)
+		} else {
+			keep = append(keep, tag)
+		}
+	}
+	if len(moved) != 0 {
+		e.Tags = keep
+	}
+	return moved
+}
+
+// AllTags returns the tags of e followed by the names of its segments that
+// are not also tags. The site lists segments among the tags of an episode.
+func (e *Episode) AllTags() []string {
+	out := append([]string(nil), e.Tags...)
+	for _, s := range e.Segments {
+		if !e.HasTag(s.Name) {
+			out = append(out, s.Name)
+		}
+	}
+	return out
+}
+
+// SegmentIndex returns a map from segment names to the episodes of eps on
+// which those segments occurred, in order of air date.
+func SegmentIndex(eps []*Episode) map[string][]*Episode {
+	idx := make(map[string][]*Episode)
+	for _, ep := range eps {
+		for _, s := range ep.Segments {
+			idx[s.Name] = append(idx[s.Name], ep)
+		}
+	}
+	for _, seps := range idx {
+		sort.SliceStable(seps, func(i, j int) bool {
+			di, dj := time.Time(seps[i].Date), time.Time(seps[j].Date)
+			if !di.Equal(dj) {
+				return di.Before(dj)
+			}
+			return labelLess(seps[i].Episode, seps[j].Episode)
+		})
+	}
+	return idx
+}
+
+// SegmentNames returns the names of the segments detected by rules, in order.
+func SegmentNames(rules []*TagRule) []string {
+	var out []string
+	for _, r := range rules {
+		out = append(out, r.Tag)
+	}
+	return out
+}
+
+// A TagRule detects a recurring segment in an episode whose text mentions any
+// of a set of phrases, such as the name of the segment. Rules are so named
+// because segments were once recorded as tags.
+type TagRule struct {
+	Tag     string   `yaml:"tag"`               // the name of the segment
+	Phrases []string `yaml:"phrases,omitempty"` // phrases that indicate the tag
+
+	// Each element of Near is a set of words that indicate the tag when they
+	// all occur, in any order, within Window consecutive words. For example,
+	// "cheese night" with a window of 4 matches "a night of cheese".
+	Near   []string `yaml:"near,flow,omitempty"`
+	Window int      `yaml:"window,omitempty"` // if <= 0, the number of words in the set is used
+
+	// Regular expressions that indicate the tag, matched without regard to
+	// case against the original text.
+	Patterns []string `yaml:"patterns,omitempty"`
+
+	// The minimum number of mentions in the episode description to propose
+	// the tag. If zero, 1 is used.
+	Threshold int `yaml:"threshold,omitempty"`
+
+	// The minimum number of mentions in a transcript to propose the tag.
+	// Transcripts are long and wander, so this is usually larger than the
+	// threshold for descriptions. If zero, a default chosen by the caller is
+	// used.
+	TranscriptThreshold int `yaml:"transcript-threshold,omitempty"`
+}
+
+// TagRules are the built-in heuristics for recurring segments, used when the
+// repository does not have a rules file.
+var TagRules = []*TagRule{
+	{Tag: "cheese-night", Phrases: []string{"cheese night"}, Near: []string{"cheese night"}, Window: 4},
+	{Tag: "truth-from-fiction", Phrases: []string{"where's the lie", "truth from fiction"}},
+	{Tag: "game-night", Phrases: []string{"game night", "games night"}, Near: []string{"game night"}, Window: 4},
+	{Tag: "book-club", Phrases: []string{"book club"}},
+}
+
+// LoadTagRules loads a list of tag rules from the YAML file at path. If path
+// does not exist, it returns the built-in TagRules. For example:
+//
+//	# _data/tag-rules.yaml
+//	- tag: cheese-night
+//	  phrases: [cheese night]
+//	  near: [cheese night]
+//	  window: 4
+//	- tag: truth-from-fiction
+//	  patterns: ["where'?s the lie"]
+//	  transcript-threshold: 3
+func LoadTagRules(path string) ([]*TagRule, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return TagRules, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var rules []*TagRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for i, r := range rules {
+		if err := r.check(); err != nil {
+			return nil, fmt.Errorf("invalid rule %d: %w", i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+// check reports an error if r is not a valid rule.
+func (r *TagRule) check() error {
+	if r.Tag == "" {
+		return errors.New("missing tag")
+	} else if len(r.Phrases) == 0 && len(r.Near) == 0 && len(r.Patterns) == 0 {
+		return fmt.Errorf("tag %q: no phrases, near, or patterns", r.Tag)
+	} else if r.Threshold < 0 || r.TranscriptThreshold < 0 {
+		return fmt.Errorf("tag %q: negative threshold", r.Tag)
+	}
+	for _, p := range r.Patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("tag %q: %w", r.Tag, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether r detects its segment in an episode with the given
+// description and transcript text, and if so returns a description of the
+// evidence. Either text may be empty. If r does not set TranscriptThreshold,
+// minTranscript is used; if that is also zero, the transcript is not checked.
+func (r *TagRule) Match(desc, transcript string, minTranscript int) (string, bool) {
+	if n := r.Mentions(desc); n > 0 && n >= r.Threshold {
+		return fmt.Sprintf("%d mentions in episode text", n), true
+	}
+	if r.TranscriptThreshold > 0 {
+		minTranscript = r.TranscriptThreshold
+	}
+	if transcript == "" || minTranscript <= 0 {
+		return "", false
+	} else if n := r.Mentions(transcript); n >= minTranscript {
+		return fmt.Sprintf("%d mentions in transcript", n), true
+	}
+	return "", false
+}
+
+// Mentions reports the number of times any of the phrases or nearby word sets
+// of r occurs in text. Phrases are matched as sequences of whole words,
+// ignoring case and punctuation. Overlapping occurrences are counted once.
+// Matches of the patterns of r are counted separately, and added.
+func (r *TagRule) Mentions(text string) int {
+	n := newWordMatcher(r.Phrases, r.Near, r.Window).count(Words(text))
+	for _, p := range r.Patterns {
+		if re := tagPattern(p); re != nil {
+			n += len(re.FindAllStringIndex(text, -1))
+		}
+	}
+	return n
+}
+
+// tagPatterns caches the compiled patterns of tag rules, keyed by pattern, so
+// that each is compiled only once. An invalid pattern is cached as nil.
+var tagPatterns sync.Map // string → *regexp.Regexp
+
+// tagPattern returns the compiled, case-insensitive form of the tag rule
+// pattern p, or nil if p is not a valid regular expression.
+func tagPattern(p string) *regexp.Regexp {
+	if v, ok := tagPatterns.Load(p); ok {
+		return v.(*regexp.Regexp)
+	}
+	re, _ := regexp.Compile("(?i)" + p) // nil if p is invalid
+	v, _ := tagPatterns.LoadOrStore(p, re)
+	return v.(*regexp.Regexp)
+}
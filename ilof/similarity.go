@@ -0,0 +1,151 @@
+package ilof
+
+import (
+	"math"
+	"strings"
+
+	"bitbucket.org/creachadair/stringset"
+)
+
+// A Metric selects a method of comparing two strings for similarity.
+type Metric int
+
+// Similarity metrics. All report values in the range [0, 1], where 1 means
+// the strings are equivalent under the metric. Two empty strings are always
+// equivalent.
+const (
+	// WordCoefficient is the Otsuka-Ochiai coefficient of the sets of words
+	// in each string. This is the metric used by Similarity.
+	WordCoefficient Metric = iota
+
+	// WordJaccard is the Jaccard index of the sets of words in each string.
+	WordJaccard
+
+	// NGramCosine is the cosine similarity of the counts of character
+	// n-grams in each string. It tolerates misspellings and differences in
+	// punctuation, which suits short texts such as titles.
+	NGramCosine
+
+	// EditDistance is one minus the Levenshtein distance between the strings,
+	// divided by the length of the longer one. It suits comparing names.
+	EditDistance
+)
+
+// SimilarityOpts select the metric used to compare strings. A nil
+// *SimilarityOpts is valid, and gives the same results as Similarity.
+type SimilarityOpts struct {
+	Metric Metric
+
+	// For the word metrics, the options for splitting strings into words.
+	Words *WordOptions
+
+	// For NGramCosine, the length of n-grams in characters. If N <= 0, a
+	// default of 3 is used.
+	N int
+}
+
+// Similarity reports the similarity of a and b under the selected metric.
+// Strings are compared without regard to case. For the character metrics,
+// runs of whitespace are treated as a single space.
+func (o *SimilarityOpts) Similarity(a, b string) float64 {
+	if o == nil {
+		return Similarity(a, b)
+	}
+	switch o.Metric {
+	case WordJaccard:
+		return jaccard(WordsWith(a, o.Words), WordsWith(b, o.Words))
+	case NGramCosine:
+		n := o.N
+		if n <= 0 {
+			n = 3
+		}
+		return nGramCosine(normalizeSpace(a), normalizeSpace(b), n)
+	case EditDistance:
+		return editSimilarity(normalizeSpace(a), normalizeSpace(b))
+	default:
+		return SimilarityWith(a, b, o.Words)
+	}
+}
+
+// normalizeSpace returns s in lower-case, with leading and trailing space
+// removed and interior runs of whitespace replaced by a single space.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func jaccard(a, b []string) float64 {
+	wa, wb := stringset.New(a...), stringset.New(b...)
+	union := wa.Union(wb).Len()
+	if union == 0 {
+		return 1
+	}
+	return float64(wa.Intersect(wb).Len()) / float64(union)
+}
+
+// nGramCosine reports the cosine similarity of the n-gram counts of a and b.
+// The strings are padded with a space at each end, so that n-grams spanning
+// the start and end of a word are counted.
+func nGramCosine(a, b string, n int) float64 {
+	if a == b {
+		return 1
+	}
+	ga, gb := nGrams(a, n), nGrams(b, n)
+	var dot, na, nb float64
+	for g, ca := range ga {
+		dot += float64(ca * gb[g])
+		na += float64(ca * ca)
+	}
+	for _, cb := range gb {
+		nb += float64(cb * cb)
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+func nGrams(s string, n int) map[string]int {
+	rs := []rune(" " + s + " ")
+	out := make(map[string]int)
+	if len(rs) <= n {
+		out[string(rs)]++
+		return out
+	}
+	for i := 0; i+n <= len(rs); i++ {
+		out[string(rs[i:i+n])]++
+	}
+	return out
+}
+
+// editSimilarity reports one minus the Levenshtein distance between a and b
+// normalized by the length of the longer, in runes.
+func editSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein returns the edit distance between a and b, counting each
+// insertion, deletion, and substitution of a rune as one edit.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
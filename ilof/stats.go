@@ -0,0 +1,121 @@
+package ilof
+
+import (
+	"sort"
+	"time"
+)
+
+// Stats summarizes the episode and guest catalog.
+type Stats struct {
+	Episodes    int            `json:"episodes"`
+	Specials    int            `json:"specials"`
+	First       Date           `json:"first"`
+	Last        Date           `json:"last"`
+	Hours       float64        `json:"hours"`       // total duration, where known
+	NumTimed    int            `json:"numTimed"`    // episodes with a known duration
+	BySeason    []*SeasonCount `json:"bySeason"`    // in order of season
+	Tags        []*NameCount   `json:"tags"`        // in decreasing order of count
+	Segments    []*NameCount   `json:"segments"`    // in decreasing order of count
+	TopGuests   []*NameCount   `json:"topGuests"`   // in decreasing order of count
+	NumGuests   int            `json:"numGuests"`   // distinct guests
+	GuestVisits int            `json:"guestVisits"` // total guest appearances
+	Hosts       *HostStats     `json:"hosts,omitempty"`
+}
+
+// A SeasonCount reports the number of episodes in a season.
+type SeasonCount struct {
+	Season   int     `json:"season"`
+	Episodes int     `json:"episodes"`
+	Hours    float64 `json:"hours"`
+}
+
+// A NameCount reports the number of occurrences of a name.
+type NameCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ComputeStats computes statistics over the given episodes and guests. Only
+// guest appearances on the given episodes are counted. At most topN guests are
+// reported in TopGuests; if topN <= 0 all guests are reported.
+func ComputeStats(eps []*Episode, guests []*Guest, topN int) *Stats {
+	st := new(Stats)
+	seasons := make(map[int]*SeasonCount)
+	tags := make(map[string]int)
+	segments := make(map[string]int)
+	labels := make(map[Label]bool)
+	for _, ep := range eps {
+		st.Episodes++
+		if ep.Special {
+			st.Specials++
+		}
+		if st.First.IsZero() || time.Time(ep.Date).Before(time.Time(st.First)) {
+			st.First = ep.Date
+		}
+		if time.Time(ep.Date).After(time.Time(st.Last)) {
+			st.Last = ep.Date
+		}
+		labels[ep.Episode] = true
+
+		sc := seasons[ep.Season]
+		if sc == nil {
+			sc = &SeasonCount{Season: ep.Season}
+			seasons[ep.Season] = sc
+		}
+		sc.Episodes++
+		if d, err := time.ParseDuration(ep.Duration); err == nil && d > 0 {
+			st.NumTimed++
+			st.Hours += d.Hours()
+			sc.Hours += d.Hours()
+		}
+		for _, tag := range ep.AllTags() {
+			tags[tag]++
+		}
+		for _, s := range ep.Segments {
+			segments[s.Name]++
+		}
+	}
+	for _, sc := range seasons {
+		st.BySeason = append(st.BySeason, sc)
+	}
+	sort.Slice(st.BySeason, func(i, j int) bool {
+		return st.BySeason[i].Season < st.BySeason[j].Season
+	})
+	st.Tags = sortCounts(tags)
+	st.Segments = sortCounts(segments)
+
+	visits := make(map[string]int)
+	for _, g := range guests {
+		for _, v := range g.Episodes {
+			if labels[v] {
+				visits[g.Name]++
+			}
+		}
+	}
+	st.NumGuests = len(visits)
+	for _, n := range visits {
+		st.GuestVisits += n
+	}
+	st.TopGuests = sortCounts(visits)
+	if topN > 0 && len(st.TopGuests) > topN {
+		st.TopGuests = st.TopGuests[:topN]
+	}
+	st.Hosts = ComputeHostStats(eps)
+	return st
+}
+
+// sortCounts converts m to a slice in decreasing order of count, with ties
+// broken by name.
+func sortCounts(m map[string]int) []*NameCount {
+	out := make([]*NameCount, 0, len(m))
+	for name, n := range m {
+		out = append(out, &NameCount{Name: name, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
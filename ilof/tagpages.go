@@ -0,0 +1,216 @@
+package ilof
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"text/template"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// TagInfo is an entry in the tag registry, giving the title and description
+// of a tag for its page on the site.
+type TagInfo struct {
+	Tag         string `yaml:"tag"`
+	Title       string `yaml:"title,omitempty"`
+	Description string `yaml:"description,omitempty"`
+
+	// If true, no page is generated for the tag.
+	Hidden bool `yaml:"hidden,omitempty"`
+}
+
+// LoadTagRegistry loads the tag registry from the YAML file at path. For
+// example:
+//
+//	# _data/tags.yaml
+//	- tag: cheese-night
+//	  title: Cheese Night
+//	  description: Episodes featuring a survey of cheeses.
+//	- tag: game-night
+//	  hidden: true
+func LoadTagRegistry(path string) ([]*TagInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var reg []*TagInfo
+	if err := yaml.Unmarshal(data, &reg); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for i, t := range reg {
+		if !tagWord.MatchString(t.Tag) {
+			return nil, fmt.Errorf("entry %d: invalid tag %q", i+1, t.Tag)
+		} else if seen[t.Tag] {
+			return nil, fmt.Errorf("entry %d: duplicate tag %q", i+1, t.Tag)
+		}
+		seen[t.Tag] = true
+	}
+	return reg, nil
+}
+
+// A TagPage gathers the episodes with one tag, for a tag page.
+type TagPage struct {
+	Tag      string
+	Info     *TagInfo   // nil if the tag is not registered
+	Episodes []*Episode // in order of air date
+	Weight   int        // 1 (least used) to TagCloudWeights (most used)
+}
+
+// TagCloudWeights is the number of distinct weights in a tag cloud.
+const TagCloudWeights = 5
+
+// TagPages returns a page for each tag used in eps, in order of tag. Titles
+// and descriptions are taken from reg; tags hidden in reg, and tags that are
+// not well-formed, are omitted.
+func TagPages(eps []*Episode, reg []*TagInfo) []*TagPage {
+	info := make(map[string]*TagInfo)
+	for _, t := range reg {
+		info[t.Tag] = t
+	}
+	byTag := make(map[string][]*Episode)
+	for _, ep := range eps {
+		seen := make(map[string]bool)
+		for _, tag := range ep.AllTags() {
+			if seen[tag] || !tagWord.MatchString(tag) || (info[tag] != nil && info[tag].Hidden) {
+				continue
+			}
+			seen[tag] = true
+			byTag[tag] = append(byTag[tag], ep)
+		}
+	}
+
+	var out []*TagPage
+	var most int
+	for tag, teps := range byTag {
+		sort.SliceStable(teps, func(i, j int) bool {
+			di, dj := time.Time(teps[i].Date), time.Time(teps[j].Date)
+			if !di.Equal(dj) {
+				return di.Before(dj)
+			}
+			return labelLess(teps[i].Episode, teps[j].Episode)
+		})
+		out = append(out, &TagPage{Tag: tag, Info: info[tag], Episodes: teps})
+		if len(teps) > most {
+			most = len(teps)
+		}
+	}
+	for _, p := range out {
+		p.Weight = tagWeight(len(p.Episodes), most)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tag < out[j].Tag })
+	return out
+}
+
+// tagWeight returns the tag cloud weight of a tag used n times, where the
+// most used tag is used most times. Weights are scaled logarithmically.
+func tagWeight(n, most int) int {
+	if most <= 1 {
+		return 1
+	}
+	f := math.Log(float64(n)) / math.Log(float64(most))
+	return 1 + int(math.Round(f*(TagCloudWeights-1)))
+}
+
+// Title returns the title of the page for p, from the registry if it has one
+// there, otherwise the tag itself.
+func (p *TagPage) Title() string {
+	if p.Info != nil && p.Info.Title != "" {
+		return p.Info.Title
+	}
+	return p.Tag
+}
+
+// Description returns the registered description of the tag, or "".
+func (p *TagPage) Description() string {
+	if p.Info != nil {
+		return p.Info.Description
+	}
+	return ""
+}
+
+// First returns the air date of the first episode with the tag.
+func (p *TagPage) First() Date { return p.Episodes[0].Date }
+
+// Last returns the air date of the last episode with the tag.
+func (p *TagPage) Last() Date { return p.Episodes[len(p.Episodes)-1].Date }
+
+// Permalink returns the site path of the page for p, e.g., "/tag/cheese-night/".
+func (p *TagPage) Permalink() string { return "/tag/" + p.Tag + "/" }
+
+// FileName returns the name of the file for the page for p.
+func (p *TagPage) FileName() string { return "tag-" + p.Tag + ".md" }
+
+// TagIndexFile is the name of the file for the tag cloud index page.
+const TagIndexFile = "tags.md"
+
+// TagPageMarker is a front matter field that marks a page written by
+// TagPage.Markdown or TagIndexMarkdown.
+const TagPageMarker = "generated: tag-index"
+
+// Markdown renders p as a markdown page with Jekyll front matter, listing the
+// episodes with the tag in order of air date.
+func (p *TagPage) Markdown(layout string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := tagPageTemplate.Execute(&buf, struct {
+		*TagPage
+		Layout, Marker string
+	}{p, layout, TagPageMarker})
+	return buf.Bytes(), err
+}
+
+// TagIndexMarkdown renders a tag cloud of pages as a markdown page with
+// Jekyll front matter and the permalink /tags/. Each tag links to its page,
+// with the class "tag-cloud-N" for its weight N.
+func TagIndexMarkdown(pages []*TagPage, layout string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := tagIndexTemplate.Execute(&buf, struct {
+		Pages          []*TagPage
+		Layout, Marker string
+	}{pages, layout, TagPageMarker})
+	return buf.Bytes(), err
+}
+
+var tagFuncs = template.FuncMap{
+	"cell":  markdownCell,
+	"quote": func(s string) string { return fmt.Sprintf("%q", s) },
+	"html":  template.HTMLEscapeString,
+	"plural": func(n int) string {
+		if n == 1 {
+			return "1 episode"
+		}
+		return fmt.Sprintf("%d episodes", n)
+	},
+}
+
+var tagPageTemplate = template.Must(template.New("tag").Funcs(tagFuncs).Parse(`---
+layout: {{.Layout}}
+title: {{quote .Title}}
+permalink: {{.Permalink}}
+tag: {{.Tag}}
+{{.Marker}}
+---
+{{with .Description}}{{.}}
+
+{{end -}}
+{{plural (len .Episodes)}} tagged **{{.Tag}}**, from {{.First}} to {{.Last}}. See also [all tags](/tags/).
+
+| Episode | Date | Topics |
+|---|---|---|
+{{range .Episodes}}| [{{.Episode}}](/episode/{{.Episode}}) | {{.Date}} | {{cell .Topics}} |
+{{end}}`))
+
+var tagIndexTemplate = template.Must(template.New("tags").Funcs(tagFuncs).Parse(`---
+layout: {{.Layout}}
+title: "Tags"
+permalink: /tags/
+{{.Marker}}
+---
+<p class="tag-cloud">
+{{range .Pages}}<a href="{{.Permalink}}" class="tag-cloud-{{.Weight}}" title="{{plural (len .Episodes)}}">{{html .Title}}</a>
+{{end}}</p>
+`))
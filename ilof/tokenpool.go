@@ -0,0 +1,205 @@
+package ilof
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultLimitWindow is how long a token is set aside when the API reports
+// that it is rate limited without saying when the limit resets. Twitter rate
+// limits are counted over 15-minute windows.
+const defaultLimitWindow = 15 * time.Minute
+
+// A TokenPool rotates among several bearer tokens for the Twitter API. Each
+// request uses the current token until the API reports that its rate limit
+// is exhausted; the token is then set aside until its limit resets, and the
+// request is retried with the next available token. If every token is
+// limited, the request waits for the earliest reset, or until its context
+// ends. A TokenPool is safe for concurrent use by multiple goroutines.
+type TokenPool struct {
+	mu     sync.Mutex
+	tokens []*poolToken
+	cur    int
+}
+
+type poolToken struct {
+	token     string
+	requests  int       // requests made with the token
+	limited   int       // number of times the token was rate limited
+	remaining int       // requests remaining in the window, or -1 if unknown
+	reset     time.Time // when the window resets, if known
+}
+
+// NewTokenPool returns a pool of the given tokens, which must not be empty.
+// Duplicate and empty tokens are discarded.
+func NewTokenPool(tokens ...string) (*TokenPool, error) {
+	p := new(TokenPool)
+	seen := make(map[string]bool)
+	for _, tok := range tokens {
+		if tok == "" || seen[tok] {
+			continue
+		}
+		seen[tok] = true
+		p.tokens = append(p.tokens, &poolToken{token: tok, remaining: -1})
+	}
+	if len(p.tokens) == 0 {
+		return nil, errors.New("no tokens provided")
+	}
+	return p, nil
+}
+
+// SplitTokens splits a list of tokens separated by commas or whitespace, as
+// in the value of TWITTER_TOKEN when several tokens are configured.
+func SplitTokens(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+}
+
+// Len reports the number of tokens in p.
+func (p *TokenPool) Len() int { return len(p.tokens) }
+
+// TokenStats reports the use of a token in a TokenPool.
+type TokenStats struct {
+	Token     string    // the token, masked
+	Requests  int       // requests made with the token
+	Limited   int       // times the token was rate limited
+	Remaining int       // requests remaining in the current window, or -1
+	Reset     time.Time // when the current window resets, if known
+}
+
+// Stats reports the use of each token in p, in order.
+func (p *TokenPool) Stats() []TokenStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]TokenStats, len(p.tokens))
+	for i, t := range p.tokens {
+		out[i] = TokenStats{
+			Token:     maskToken(t.token),
+			Requests:  t.requests,
+			Limited:   t.limited,
+			Remaining: t.remaining,
+			Reset:     t.reset,
+		}
+	}
+	return out
+}
+
+func maskToken(s string) string {
+	if len(s) <= 8 {
+		return "****"
+	}
+	return "****" + s[len(s)-4:]
+}
+
+// HTTPClient returns an HTTP client whose requests are authorized with the
+// tokens of p, and retried with another token when rate limited.
+func (p *TokenPool) HTTPClient() *http.Client {
+	return &http.Client{Transport: &tokenTransport{pool: p}}
+}
+
+// acquire returns the index of a token that is not rate limited as of now.
+// If there is none, it waits until the earliest reset or ctx ends.
+func (p *TokenPool) acquire(ctx context.Context) (int, error) {
+	for {
+		now := time.Now()
+		p.mu.Lock()
+		var next time.Time
+		for k := range p.tokens {
+			i := (p.cur + k) % len(p.tokens)
+			t := p.tokens[i]
+			if t.remaining != 0 || !now.Before(t.reset) {
+				if i != p.cur {
+					log.Printf("Switching to Twitter token %d of %d", i+1, len(p.tokens))
+					p.cur = i
+				}
+				t.requests++
+				p.mu.Unlock()
+				return i, nil
+			}
+			if next.IsZero() || t.reset.Before(next) {
+				next = t.reset
+			}
+		}
+		p.mu.Unlock()
+
+		wait := next.Sub(now)
+		log.Printf("All %d Twitter tokens are rate limited; waiting %v", len(p.tokens), wait.Round(time.Second))
+		tm := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			tm.Stop()
+			return 0, ctx.Err()
+		case <-tm.C:
+		}
+	}
+}
+
+// update records the rate limit state reported by a response for the token
+// at index i, as of now, and reports whether the token was rate limited.
+func (p *TokenPool) update(i int, rsp *http.Response, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := p.tokens[i]
+	if v, err := strconv.Atoi(rsp.Header.Get("x-rate-limit-remaining")); err == nil {
+		t.remaining = v
+	}
+	if v, err := strconv.ParseInt(rsp.Header.Get("x-rate-limit-reset"), 10, 64); err == nil {
+		t.reset = time.Unix(v, 0)
+	}
+	if rsp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+	t.limited++
+	t.remaining = 0
+	if !t.reset.After(now) {
+		t.reset = now.Add(defaultLimitWindow)
+	}
+	log.Printf("Twitter token %d of %d is rate limited until %s",
+		i+1, len(p.tokens), t.reset.In(time.Local).Format(time.Kitchen))
+	return true
+}
+
+// tokenTransport is an http.RoundTripper that authorizes requests with the
+// tokens of a TokenPool.
+type tokenTransport struct {
+	pool *TokenPool
+	base http.RoundTripper // if nil, http.DefaultTransport
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	for try := 0; ; try++ {
+		i, err := t.pool.acquire(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		r := req.Clone(req.Context())
+		if try > 0 && req.Body != nil {
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, fmt.Errorf("retrying request: %w", err)
+			}
+		}
+		r.Header.Set("Authorization", "Bearer "+t.pool.tokens[i].token)
+		rsp, err := base.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		if !t.pool.update(i, rsp, time.Now()) || (req.Body != nil && req.GetBody == nil) {
+			return rsp, nil
+		}
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+	}
+}
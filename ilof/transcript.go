@@ -0,0 +1,189 @@
+package ilof
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// WriteSRT writes the captions of t to w in SubRip (SRT) format.
+func (t *Transcript) WriteSRT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for i, c := range t.Captions {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1,
+			formatCueTime(c.Start, ","), formatCueTime(c.Start+c.Duration, ","), cueText(c.Text))
+	}
+	return bw.Flush()
+}
+
+// WriteVTT writes the captions of t to w in WebVTT format.
+func (t *Transcript) WriteVTT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n")
+	if t.VideoID != "" {
+		fmt.Fprintf(bw, "NOTE video %s\n", t.VideoID)
+	}
+	bw.WriteString("\n")
+	for _, c := range t.Captions {
+		fmt.Fprintf(bw, "%s --> %s\n%s\n\n",
+			formatCueTime(c.Start, "."), formatCueTime(c.Start+c.Duration, "."), cueText(c.Text))
+	}
+	return bw.Flush()
+}
+
+// The MIME types of the transcript formats that a podcast feed can advertise
+// with the podcast:transcript element of the Podcasting 2.0 namespace.
+const (
+	PodcastTranscriptJSON = "application/json"
+	PodcastTranscriptSRT  = "application/x-subrip"
+)
+
+// WritePodcastJSON writes the captions of t to w in the JSON transcript format
+// of the Podcasting 2.0 namespace, with one segment per caption.
+func (t *Transcript) WritePodcastJSON(w io.Writer) error {
+	type segment struct {
+		Start float64 `json:"startTime"`
+		End   float64 `json:"endTime"`
+		Body  string  `json:"body"`
+	}
+	doc := struct {
+		Version  string     `json:"version"`
+		Segments []*segment `json:"segments"`
+	}{Version: "1.0.0", Segments: []*segment{}}
+	ms := func(sec float64) float64 { return math.Round(sec*1000) / 1000 }
+	for _, c := range t.Captions {
+		doc.Segments = append(doc.Segments, &segment{
+			Start: ms(c.Start),
+			End:   ms(c.Start + c.Duration),
+			Body:  strings.Join(strings.Fields(c.Text), " "),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
+
+// PodcastTranscriptPaths returns the paths of the podcast transcript files
+// exported for the stored JSON transcript at path, in the Podcasting 2.0 JSON
+// format and as SubRip. For "transcripts/0100-abc.json" they are
+// "transcripts/0100-abc.podcast.json" and "transcripts/0100-abc.srt".
+func PodcastTranscriptPaths(path string) (jsonPath, srtPath string) {
+	base := strings.TrimSuffix(path, ".json")
+	return base + ".podcast.json", base + ".srt"
+}
+
+// WriteText writes the captions of t to w as plain text, wrapped into lines of
+// at most about width characters. If width <= 0, each caption is written on a
+// line by itself.
+func (t *Transcript) WriteText(w io.Writer, width int) error {
+	bw := bufio.NewWriter(w)
+	if width <= 0 {
+		for _, c := range t.Captions {
+			bw.WriteString(cueText(c.Text))
+			bw.WriteString("\n")
+		}
+		return bw.Flush()
+	}
+	var n int
+	for _, c := range t.Captions {
+		for _, word := range strings.Fields(c.Text) {
+			if n > 0 && n+1+len(word) > width {
+				bw.WriteString("\n")
+				n = 0
+			} else if n > 0 {
+				bw.WriteString(" ")
+				n++
+			}
+			bw.WriteString(word)
+			n += len(word)
+		}
+	}
+	if n > 0 {
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// Text returns the text of all the captions in t, separated by spaces.
+func (t *Transcript) Text() string {
+	var buf strings.Builder
+	for i, c := range t.Captions {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(strings.Join(strings.Fields(c.Text), " "))
+	}
+	return buf.String()
+}
+
+// formatCueTime formats sec as a cue timestamp HH:MM:SS<sep>mmm.
+func formatCueTime(sec float64, sep string) string {
+	d := time.Duration(sec * float64(time.Second)).Round(time.Millisecond)
+	h := d / time.Hour
+	m := (d % time.Hour) / time.Minute
+	s := (d % time.Minute) / time.Second
+	ms := (d % time.Second) / time.Millisecond
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, s, sep, ms)
+}
+
+// cueText cleans up caption text for use in a subtitle cue. Blank lines are
+// removed, since they terminate a cue.
+func cueText(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if t := strings.TrimSpace(line); t != "" {
+			lines = append(lines, t)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TranscriptFile returns the path of the stored JSON transcript of e,
+// relative to the root of the site repository, or "" if e has none.
+func (e *Episode) TranscriptFile() string {
+	if !strings.HasSuffix(e.Transcript, ".json") {
+		return ""
+	}
+	return path.Clean(strings.TrimPrefix(e.Transcript, "/"))
+}
+
+// LoadEpisodeTranscript reads the stored JSON transcript of ep, relative to the
+// current directory, which should be the root of the site repository. It
+// returns nil without error if ep has no stored JSON transcript.
+func LoadEpisodeTranscript(ep *Episode) (*Transcript, error) {
+	path := ep.TranscriptFile()
+	if path == "" {
+		return nil, nil
+	}
+	return LoadTranscript(path)
+}
+
+// LoadTranscript reads a transcript stored in JSON format at path. The file
+// may contain either a bare transcript or an envelope with the transcript in
+// its "transcript" field, as written by the fytt tool.
+func LoadTranscript(path string) (*Transcript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var env struct {
+		Transcript *Transcript `json:"transcript"`
+	}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("decoding transcript: %w", err)
+	} else if env.Transcript != nil {
+		return env.Transcript, nil
+	}
+	t := new(Transcript)
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("decoding transcript: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,184 @@
+package ilof
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VideoDeepLink returns a link to the YouTube video with the given ID,
+// starting at the given offset in seconds.
+func VideoDeepLink(id string, sec float64) string {
+	return fmt.Sprintf("https://www.youtube.com/watch?v=%s&t=%ds", id, int(sec))
+}
+
+// A TranscriptUpdate records that the transcript of an episode was added or
+// updated, for a transcript feed.
+type TranscriptUpdate struct {
+	Episode *Episode
+	Updated time.Time
+
+	// The transcript, if it is available. It is used to report the number of
+	// captions, and to link to the video where the speech begins.
+	Transcript *Transcript
+
+	// Exported transcript files to advertise to podcast apps, if any.
+	Files []*TranscriptFile
+
+	// The URL of the exported chapters of the episode in the Podcasting 2.0
+	// JSON chapters format, if any, advertised by a podcast:chapters element.
+	ChaptersURL string
+}
+
+// A TranscriptFile is a transcript in a format that podcast apps can render,
+// advertised in a feed by a podcast:transcript element.
+type TranscriptFile struct {
+	URL  string
+	Type string // MIME type, e.g., PodcastTranscriptJSON
+}
+
+// TranscriptPageURL returns the URL of the transcript of ep on the site, or
+// "" if ep has no transcript.
+func TranscriptPageURL(ep *Episode) string {
+	if ep.Transcript == "" {
+		return ""
+	}
+	return BaseURL + "/" + strings.TrimPrefix(ep.Transcript, "/")
+}
+
+// RecentTranscriptUpdates returns a copy of ups ordered newest first, with at
+// most max items if max > 0. These are the updates a feed of ups reports.
+func RecentTranscriptUpdates(ups []*TranscriptUpdate, max int) []*TranscriptUpdate {
+	ups = append([]*TranscriptUpdate(nil), ups...)
+	sort.SliceStable(ups, func(i, j int) bool {
+		if !ups[i].Updated.Equal(ups[j].Updated) {
+			return ups[i].Updated.After(ups[j].Updated)
+		}
+		return labelLess(ups[j].Episode.Episode, ups[i].Episode.Episode)
+	})
+	if max > 0 && len(ups) > max {
+		ups = ups[:max]
+	}
+	return ups
+}
+
+// WriteTranscriptFeed writes an RSS 2.0 feed of the transcript updates in ups
+// to w, newest first, with at most max items if max > 0. The exported files
+// of each update are advertised with the podcast:transcript element of the
+// Podcasting 2.0 namespace.
+func WriteTranscriptFeed(w io.Writer, ups []*TranscriptUpdate, max int) error {
+	ups = RecentTranscriptUpdates(ups, max)
+
+	ch := &rssChannel{
+		Title:       "In Lieu of Fun: Transcripts",
+		Link:        BaseURL + "/",
+		Description: "Transcripts of In Lieu of Fun episodes, as they are added or updated.",
+	}
+	if len(ups) != 0 {
+		ch.LastBuild = ups[0].Updated.UTC().Format(time.RFC1123Z)
+	}
+	doc := &rss{Version: "2.0", Channel: ch}
+	for _, up := range ups {
+		ep := up.Episode
+		link := TranscriptPageURL(ep)
+		title := fmt.Sprintf("Transcript of episode %s (%s)", ep.Episode, ep.Date)
+		if ep.Topics != "" {
+			title += ": " + ep.Topics
+		}
+		item := &rssItem{
+			Title:       title,
+			Link:        link,
+			GUID:        rssGUID{Value: fmt.Sprintf("%s#%d", link, up.Updated.Unix())},
+			PubDate:     up.Updated.UTC().Format(time.RFC1123Z),
+			Description: transcriptItemHTML(up),
+		}
+		for _, f := range up.Files {
+			item.Transcripts = append(item.Transcripts, &rssTranscript{URL: f.URL, Type: f.Type})
+			doc.PodcastNS = podcastNamespace
+		}
+		if up.ChaptersURL != "" {
+			item.Chapters = &rssTranscript{URL: up.ChaptersURL, Type: PodcastChapterJSON}
+			doc.PodcastNS = podcastNamespace
+		}
+		ch.Items = append(ch.Items, item)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// transcriptItemHTML returns the HTML description of a feed item for up.
+func transcriptItemHTML(up *TranscriptUpdate) string {
+	ep := up.Episode
+	var sb strings.Builder
+	if ep.Summary != "" {
+		fmt.Fprintf(&sb, "<p>%s</p>\n", html.EscapeString(ep.Summary))
+	}
+	var links []string
+	links = append(links, fmt.Sprintf(`<a href="%s">Transcript</a>`, html.EscapeString(TranscriptPageURL(ep))))
+	if id, ok := YouTubeVideoID(ep.YouTubeURL); ok {
+		start := 0.0
+		if t := up.Transcript; t != nil && len(t.Captions) != 0 {
+			start = t.Captions[0].Start
+		}
+		links = append(links, fmt.Sprintf(`<a href="%s">Watch from the start of the conversation</a>`,
+			html.EscapeString(VideoDeepLink(id, start))))
+	}
+	links = append(links, fmt.Sprintf(`<a href="%s">Episode page</a>`, html.EscapeString(ep.PageURL())))
+	sb.WriteString("<p>" + strings.Join(links, " · ") + "</p>")
+	if t := up.Transcript; t != nil {
+		fmt.Fprintf(&sb, "\n<p>Captions: %d</p>", len(t.Captions))
+	}
+	return sb.String()
+}
+
+// podcastNamespace is the XML namespace of the Podcasting 2.0 elements.
+const podcastNamespace = "https://podcastindex.org/namespace/1.0"
+
+type rss struct {
+	XMLName   xml.Name    `xml:"rss"`
+	Version   string      `xml:"version,attr"`
+	PodcastNS string      `xml:"xmlns:podcast,attr,omitempty"`
+	Channel   *rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	Description string     `xml:"description"`
+	LastBuild   string     `xml:"lastBuildDate,omitempty"`
+	Items       []*rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+
+	Transcripts []*rssTranscript `xml:"podcast:transcript"`
+	Chapters    *rssTranscript   `xml:"podcast:chapters,omitempty"`
+}
+
+type rssTranscript struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
@@ -0,0 +1,106 @@
+package ilof
+
+import (
+	"context"
+	"strings"
+
+	"github.com/creachadair/twitter/users"
+)
+
+// A TwitterUser identifies a Twitter account by its numeric user ID, which
+// does not change, and its handle, which the owner may change at any time.
+type TwitterUser struct {
+	ID     string
+	Handle string
+}
+
+// maxUserLookup is the maximum number of users per lookup request.
+const maxUserLookup = 100
+
+// LookupTwitterHandles returns the accounts with the given handles, using
+// the tokens of the given pool. Handles that do not name an existing account
+// are omitted.
+func LookupTwitterHandles(ctx context.Context, tokens *TokenPool, handles []string) ([]*TwitterUser, error) {
+	var names []string
+	for _, h := range handles {
+		if h = NormalizeHandle(h); h != "" {
+			names = append(names, h)
+		}
+	}
+	return lookupTwitterUsers(ctx, tokens, names, users.LookupByName)
+}
+
+// LookupTwitterIDs returns the accounts with the given numeric user IDs,
+// using the tokens of the given pool. IDs of accounts that no longer exist
+// (or are suspended) are omitted.
+func LookupTwitterIDs(ctx context.Context, tokens *TokenPool, ids []string) ([]*TwitterUser, error) {
+	return lookupTwitterUsers(ctx, tokens, ids, users.Lookup)
+}
+
+func lookupTwitterUsers(ctx context.Context, tokens *TokenPool, keys []string,
+	lookup func(string, *users.LookupOpts) users.Query) ([]*TwitterUser, error) {
+	cli := newTwitter(tokens)
+	var out []*TwitterUser
+	for len(keys) != 0 {
+		batch := keys[:min(len(keys), maxUserLookup)]
+		keys = keys[len(batch):]
+
+		rsp, err := lookup(batch[0], &users.LookupOpts{More: batch[1:]}).Invoke(ctx, cli)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range rsp.Users {
+			out = append(out, &TwitterUser{ID: u.ID, Handle: u.Username})
+		}
+	}
+	return out, nil
+}
+
+// SetTwitterIDs records the user ID of each guest in gs whose handle matches
+// one of us, ignoring case, and returns the guests whose IDs were added or
+// changed.
+func SetTwitterIDs(gs []*Guest, us []*TwitterUser) []*Guest {
+	byHandle := make(map[string]*TwitterUser)
+	for _, u := range us {
+		byHandle[strings.ToLower(u.Handle)] = u
+	}
+	var out []*Guest
+	for _, g := range gs {
+		u := byHandle[strings.ToLower(NormalizeHandle(g.Twitter))]
+		if u != nil && g.TwitterID != u.ID {
+			g.TwitterID = u.ID
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// A HandleChange records that the handle of a guest's account has changed.
+type HandleChange struct {
+	Guest    *Guest
+	Old, New string
+}
+
+// RefreshTwitterHandles updates the handle of each guest in gs that has a
+// user ID to the current handle of that account in us. It returns the
+// changes made, and the guests whose IDs were not found in us, which may
+// have been deleted or suspended.
+func RefreshTwitterHandles(gs []*Guest, us []*TwitterUser) (changed []*HandleChange, missing []*Guest) {
+	byID := make(map[string]*TwitterUser)
+	for _, u := range us {
+		byID[u.ID] = u
+	}
+	for _, g := range gs {
+		if g.TwitterID == "" {
+			continue
+		}
+		u := byID[g.TwitterID]
+		if u == nil {
+			missing = append(missing, g)
+		} else if !strings.EqualFold(NormalizeHandle(g.Twitter), u.Handle) {
+			changed = append(changed, &HandleChange{Guest: g, Old: g.Twitter, New: u.Handle})
+			g.Twitter = u.Handle
+		}
+	}
+	return
+}
@@ -0,0 +1,104 @@
+package ilof
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters that identify the source of a click
+// rather than the content of a page, on any host.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true,
+	"mc_cid": true, "mc_eid": true, "igshid": true, "_hsenc": true,
+	"_hsmi": true, "ref_src": true, "ref_url": true, "cmpid": true,
+}
+
+// hostTrackingParams are tracking parameters specific to particular hosts.
+var hostTrackingParams = map[string][]string{
+	"twitter.com":      {"s", "t"},
+	"x.com":            {"s", "t"},
+	"www.youtube.com":  {"feature", "si", "ab_channel", "pp"},
+	"open.spotify.com": {"si"},
+}
+
+// hostAliases map alternative (typically mobile) hostnames to the canonical
+// hostname for the same content.
+var hostAliases = map[string]string{
+	"youtube.com":        "www.youtube.com",
+	"m.youtube.com":      "www.youtube.com",
+	"www.twitter.com":    "twitter.com",
+	"mobile.twitter.com": "twitter.com",
+	"m.twitter.com":      "twitter.com",
+	"m.facebook.com":     "www.facebook.com",
+	"facebook.com":       "www.facebook.com",
+	"crowdcast.io":       "www.crowdcast.io",
+}
+
+// NormalizeURL returns s in a canonical form, so that URLs for the same
+// content can be compared. The scheme and host are converted to lower case,
+// default ports are removed, mobile and alternate hostnames are replaced by
+// their canonical equivalents, and tracking parameters such as utm_source and
+// fbclid are removed from the query. YouTube short links, live links, and
+// shorts are converted to watch URLs. If s is not an absolute URL, it is
+// returned with surrounding whitespace removed but otherwise unchanged.
+func NormalizeURL(s string) string {
+	s = strings.TrimSpace(s)
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || u.Opaque != "" {
+		return s
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	if strings.HasSuffix(host, ".m.wikipedia.org") {
+		host = strings.TrimSuffix(host, ".m.wikipedia.org") + ".wikipedia.org"
+	}
+	if alias, ok := hostAliases[host]; ok {
+		host = alias
+	}
+	u.Host = host
+
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return u.String()
+	}
+
+	// Convert YouTube variants to watch URLs.
+	if id, ok := youTubePathID(u); ok {
+		u.Host = "www.youtube.com"
+		u.Path, u.RawPath = "/watch", ""
+		q.Set("v", id)
+	}
+
+	for key := range q {
+		if trackingParams[key] || strings.HasPrefix(key, "utm_") {
+			q.Del(key)
+		}
+	}
+	for _, key := range hostTrackingParams[u.Host] {
+		q.Del(key)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// youTubePathID reports whether u is a YouTube URL that carries a video ID in
+// its path rather than its query, and if so returns the ID.
+func youTubePathID(u *url.URL) (string, bool) {
+	var id string
+	switch u.Host {
+	case "youtu.be":
+		id = strings.TrimPrefix(u.Path, "/")
+	case "www.youtube.com":
+		for _, prefix := range []string{"/live/", "/shorts/", "/embed/"} {
+			if strings.HasPrefix(u.Path, prefix) {
+				id = strings.TrimPrefix(u.Path, prefix)
+				break
+			}
+		}
+	}
+	id = strings.TrimSuffix(id, "/")
+	return id, id != "" && !strings.Contains(id, "/")
+}
@@ -3,20 +3,27 @@ package ilof
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"bitbucket.org/creachadair/stringset"
 )
 
 // Similarity computes a Otsuka-Ochiai coefficient for the words in a and b.
-func Similarity(a, b string) float64 {
-	wa := stringset.New(Words(a)...)
-	wb := stringset.New(Words(b)...)
+func Similarity(a, b string) float64 { return SimilarityWith(a, b, nil) }
+
+// SimilarityWith computes a Otsuka-Ochiai coefficient for the words in a and
+// b, parsed as directed by opts. For example, with EnglishStopwords common
+// words are not counted.
+func SimilarityWith(a, b string, opts *WordOptions) float64 {
+	wa := stringset.New(WordsWith(a, opts)...)
+	wb := stringset.New(WordsWith(b, opts)...)
 	if wa.Empty() && wb.Empty() {
 		return 1
 	}
@@ -45,16 +52,150 @@ func Words(s string) []string {
 	return words
 }
 
+// FetchOptions control the HTTP requests made by this package.
+type FetchOptions struct {
+	// The time limit for a request, including reading the response body.
+	// If zero, there is no limit other than that of the request context.
+	Timeout time.Duration
+
+	// The maximum size of a response body in bytes. A longer response is
+	// reported as an error. If zero, there is no limit.
+	MaxBodySize int64
+
+	// The HTTP status codes accepted as success. If empty, only 200 OK is
+	// accepted. Other statuses are reported as errors.
+	AcceptStatus []int
+
+	// Headers added to each request, unless the request already sets them.
+	Header http.Header
+
+	// The number of times to retry a GET or HEAD request that fails with a
+	// network error or a 429 or 5xx status, waiting twice as long before each
+	// retry as the one before, starting at one second.
+	Retries int
+
+	// The client used to send requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// DefaultFetchOptions are the options applied to HTTP requests made by this
+// package, unless a caller specifies otherwise.
+var DefaultFetchOptions = &FetchOptions{
+	Timeout:     60 * time.Second,
+	MaxBodySize: 64 << 20,
+	Header:      http.Header{"User-Agent": {"ilof-tools (+https://github.com/inlieuoffun/tools)"}},
+	Retries:     2,
+}
+
+func (o *FetchOptions) accepts(status int) bool {
+	if len(o.AcceptStatus) == 0 {
+		return status == http.StatusOK
+	}
+	for _, s := range o.AcceptStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// send sends req as directed by o, waiting first for RateLimiter, and returns
+// its response. The caller must close the body of the response. The timeout
+// of o applies to reading the body as well as to sending the request.
+func (o *FetchOptions) send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	cli := http.DefaultClient
+	if o.Client != nil {
+		cli = o.Client
+	}
+	if o.Timeout > 0 {
+		c := *cli
+		c.Timeout = o.Timeout
+		cli = &c
+	}
+	for key, vals := range o.Header {
+		if req.Header.Get(key) == "" {
+			req.Header[key] = vals
+		}
+	}
+	retries := o.Retries
+	if req.Method != "GET" && req.Method != "HEAD" {
+		retries = 0
+	}
+	wait := time.Second
+	for try := 0; ; try++ {
+		if err := RateLimiter.Wait(ctx, req.URL.Host); err != nil {
+			return nil, err
+		}
+		rsp, err := cli.Do(req)
+		if try == retries {
+			return rsp, err
+		} else if err == nil {
+			if rsp.StatusCode != http.StatusTooManyRequests && rsp.StatusCode < 500 {
+				return rsp, nil
+			}
+			io.Copy(io.Discard, io.LimitReader(rsp.Body, 1<<16))
+			rsp.Body.Close()
+		} else if ctx.Err() != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+			wait *= 2
+		}
+	}
+}
+
+// A StatusError reports an HTTP response whose status was not accepted.
+type StatusError struct {
+	Code   int    // e.g., 404
+	Status string // e.g., "404 Not Found"
+}
+
+func (e *StatusError) Error() string { return "request failed: " + e.Status }
+
+// errBodyTooLarge is reported for a response body that exceeds the size limit.
+var errBodyTooLarge = errors.New("response body too large")
+
 func loadRequest(ctx context.Context, req *http.Request) ([]byte, error) {
-	rsp, err := http.DefaultClient.Do(req)
+	return loadRequestWith(ctx, req, DefaultFetchOptions)
+}
+
+// fetchURL sends a GET request for url and returns the body of its response.
+func fetchURL(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
+	return loadRequest(ctx, req)
+}
+
+// loadRequestWith sends req and returns the body of its response, as directed
+// by opts. A nil opts uses DefaultFetchOptions. If the body exceeds the size
+// limit, the error wraps errBodyTooLarge, and the data returned are the
+// first MaxBodySize bytes of the body.
+func loadRequestWith(ctx context.Context, req *http.Request, opts *FetchOptions) ([]byte, error) {
+	if opts == nil {
+		opts = DefaultFetchOptions
+	}
+	rsp, err := opts.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	var body io.Reader = rsp.Body
+	if opts.MaxBodySize > 0 {
+		body = io.LimitReader(rsp.Body, opts.MaxBodySize+1)
+	}
 	var buf bytes.Buffer
-	io.Copy(&buf, rsp.Body)
-	rsp.Body.Close()
-	if rsp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed: %s", rsp.Status)
+	if _, err := io.Copy(&buf, body); err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	} else if !opts.accepts(rsp.StatusCode) {
+		return nil, &StatusError{Code: rsp.StatusCode, Status: rsp.Status}
+	} else if opts.MaxBodySize > 0 && int64(buf.Len()) > opts.MaxBodySize {
+		return buf.Bytes()[:opts.MaxBodySize], fmt.Errorf("%w (over %d bytes)", errBodyTooLarge, opts.MaxBodySize)
 	}
 	return buf.Bytes(), nil
 }
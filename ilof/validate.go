@@ -0,0 +1,591 @@
+package ilof
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/inlieuoffun/tools/repo"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Severity classifies a validation finding.
+type Severity int
+
+// Severity values for validation findings.
+const (
+	Warning Severity = iota // a suspicious value that may be intended
+	Error                   // an invalid value
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// MarshalText encodes a severity as a string (used for JSON).
+func (s Severity) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// A Finding reports a problem found during validation.
+type Finding struct {
+	Path     string   `json:"path"`
+	Line     int      `json:"line,omitempty"`   // 1-based; 0 if unknown
+	Column   int      `json:"column,omitempty"` // 1-based; 0 if unknown
+	Field    string   `json:"field,omitempty"`  // the front matter field, if known
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Fixable  bool     `json:"fixable,omitempty"` // can be repaired automatically
+}
+
+func (f *Finding) String() string {
+	var buf strings.Builder
+	buf.WriteString(f.Path)
+	if f.Line > 0 {
+		fmt.Fprintf(&buf, ":%d", f.Line)
+		if f.Column > 0 {
+			fmt.Fprintf(&buf, ":%d", f.Column)
+		}
+	}
+	fmt.Fprintf(&buf, ": %s: ", f.Severity)
+	if f.Field != "" {
+		fmt.Fprintf(&buf, "%s: ", f.Field)
+	}
+	buf.WriteString(f.Message)
+	if f.Fixable {
+		buf.WriteString(" (fixable)")
+	}
+	return buf.String()
+}
+
+// keyLines maps top-level YAML mapping keys to their line numbers, offset by
+// the given number of lines.
+type keyLines map[string]int
+
+func newKeyLines(src string, offset int) keyLines {
+	kl := make(keyLines)
+	var doc yaml.Node
+	if yaml.Unmarshal([]byte(src), &doc) != nil || len(doc.Content) == 0 {
+		return kl
+	}
+	m := doc.Content[0]
+	if m.Kind != yaml.MappingNode {
+		return kl
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		kl[m.Content[i].Value] = m.Content[i].Line + offset
+	}
+	return kl
+}
+
+// ValidateEpisodeFile checks the episode file at path for problems. An error
+// is reported only if the file cannot be read; problems with its contents are
+// reported as findings.
+func ValidateEpisodeFile(path string) ([]*Finding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	front, _, err := splitFrontMatter(data)
+	if err != nil {
+		return []*Finding{{Path: path, Line: 1, Severity: Error, Message: err.Error()}}, nil
+	}
+	var ep Episode
+	if err := yaml.Unmarshal([]byte(front), &ep); err != nil {
+		return []*Finding{{Path: path, Severity: Error, Message: fmt.Sprintf("decoding front matter: %v", err)}}, nil
+	}
+
+	// The front matter starts after the opening "---" line.
+	kl := newKeyLines(front, 1)
+	var out []*Finding
+	add := func(field string, sev Severity, fixable bool, msg string, args ...interface{}) {
+		out = append(out, &Finding{
+			Path:     path,
+			Line:     kl[field],
+			Field:    field,
+			Severity: sev,
+			Message:  fmt.Sprintf(msg, args...),
+			Fixable:  fixable,
+		})
+	}
+	checkEpisodeFileName(path, &ep, add)
+	for _, c := range episodeChecks {
+		c(&ep, add)
+	}
+	return out, nil
+}
+
+// checkEpisodeFileName checks that the name of the episode file at path
+// encodes the air date of ep and, if its label is numeric, its label. The
+// site derives episode permalinks from file names, so a mismatch breaks
+// links to the episode. A missing date or label is fixable if the file name
+// supplies it.
+func checkEpisodeFileName(path string, ep *Episode, add addFinding) {
+	date, label, ok := parseEpisodeFileName(filepath.Base(path))
+	if ep.Episode == "" {
+		add("episode", Error, ok && label != "", "missing episode label")
+	} else if ok && ep.Episode.Number() >= 0 && label != ep.Episode {
+		add("episode", Error, true, "file name has episode %s, but the episode is %s", label, ep.Episode)
+	}
+	if ep.Date.IsZero() {
+		add("date", Error, ok, "missing air date")
+	} else if ok && date.String() != ep.Date.String() {
+		add("date", Error, true, "file name has date %s, but the episode airs %s", date, ep.Date)
+	}
+}
+
+// parseEpisodeFileName returns the air date and label encoded in the base name
+// of an episode file, as "2021-01-05-0100.md". It reports false if base is not
+// an episode file name or does not begin with a valid date.
+func parseEpisodeFileName(base string) (Date, Label, bool) {
+	if !IsEpisodeFileName(base) {
+		return Date{}, "", false
+	}
+	date, err := time.Parse(dateFormat, base[:len(dateFormat)])
+	if err != nil {
+		return Date{}, "", false
+	}
+	return Date(date), ParseLabel(strings.TrimSuffix(base[len(dateFormat)+1:], ".md")), true
+}
+
+// FixEpisodeFileName repairs disagreement between the name of the episode file
+// at path and the air date and label of ep, as reported by validation. The
+// front matter is taken to be correct: a date or label missing from ep is
+// filled in from the file name, and otherwise the file should be renamed. It
+// returns the path where the file belongs, which is path unless it must be
+// renamed, and a description of each change made or needed.
+func FixEpisodeFileName(path string, ep *Episode) (string, []string) {
+	dir, base := filepath.Split(path)
+	date, label, ok := parseEpisodeFileName(base)
+	if !ok {
+		return path, nil
+	}
+	var out []string
+	if ep.Episode == "" && label != "" {
+		ep.Episode = label
+		out = append(out, fmt.Sprintf("episode: set %s from file name", label))
+	}
+	if ep.Date.IsZero() {
+		ep.Date = date
+		out = append(out, fmt.Sprintf("date: set %s from file name", date))
+	}
+	renumber := ep.Episode.Number() >= 0 && label != ep.Episode
+	if date.String() != ep.Date.String() || renumber {
+		// Keep the rest of a non-numeric name, which need not match the label.
+		name := ep.Date.String() + base[len(dateFormat):]
+		if renumber {
+			name = EpisodeFileName(ep.Date, ep.Episode)
+		}
+		out = append(out, fmt.Sprintf("rename to %s", name))
+		path = filepath.Join(dir, name)
+	}
+	return path, out
+}
+
+// addFinding is the signature of a callback used by validation checks to
+// report findings for a field.
+type addFinding func(field string, sev Severity, fixable bool, msg string, args ...interface{})
+
+// episodeChecks are the validation checks applied to each episode.
+var episodeChecks = []func(*Episode, addFinding){
+	checkEpisodeLabel,
+	checkEpisodeURLs,
+	checkEpisodeTags,
+	checkEpisodeSegments,
+	checkEpisodeLinks,
+	checkEpisodeChapters,
+	checkEpisodeText,
+}
+
+func checkEpisodeLabel(ep *Episode, add addFinding) {
+	if ep.Episode != "" && ep.Episode.Number() < 0 && !ep.Special {
+		add("episode", Warning, false, "non-numeric label %q on an episode not marked special", ep.Episode)
+	}
+}
+
+func checkEpisodeURLs(ep *Episode, add addFinding) {
+	if ep.YouTubeURL != "" {
+		if id, ok := YouTubeVideoID(ep.YouTubeURL); !ok {
+			add("youtube", Error, false, "not a YouTube video URL: %q", ep.YouTubeURL)
+		} else if canon := youTubeWatchURL(id); canon != ep.YouTubeURL {
+			add("youtube", Warning, true, "non-canonical YouTube URL %q", ep.YouTubeURL)
+		}
+	}
+	for _, f := range []struct {
+		field, url string
+	}{
+		{"crowdcast", ep.CrowdcastURL},
+		{"acast", ep.AcastURL},
+		{"audio-file", ep.AudioFileURL},
+	} {
+		if f.url == "" {
+			continue
+		}
+		u, err := url.Parse(f.url)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			add(f.field, Error, false, "invalid URL %q", f.url)
+		}
+	}
+}
+
+var tagWord = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func checkEpisodeTags(ep *Episode, add addFinding) {
+	seen := make(map[string]bool)
+	for _, tag := range ep.Tags {
+		if seen[tag] {
+			add("tags", Warning, true, "duplicate tag %q", tag)
+		}
+		seen[tag] = true
+		if !tagWord.MatchString(tag) {
+			add("tags", Warning, tagWord.MatchString(canonicalTag(tag)), "malformed tag %q", tag)
+		}
+	}
+}
+
+func checkEpisodeSegments(ep *Episode, add addFinding) {
+	seen := make(map[string]bool)
+	for i, s := range ep.Segments {
+		if s.Name == "" {
+			add("segments", Error, false, "segment %d has no name", i+1)
+			continue
+		} else if !tagWord.MatchString(s.Name) {
+			add("segments", Warning, false, "malformed segment name %q", s.Name)
+		}
+		if seen[s.Name] {
+			add("segments", Warning, false, "duplicate segment %q", s.Name)
+		} else if ep.HasTag(s.Name) {
+			add("tags", Warning, false, "segment %q is also a tag", s.Name)
+		}
+		seen[s.Name] = true
+	}
+}
+
+func checkEpisodeLinks(ep *Episode, add addFinding) {
+	seen := make(map[string]int)
+	for i, link := range ep.Links {
+		if link.URL == "" {
+			add("links", Error, false, "link %d has no URL", i+1)
+			continue
+		} else if u, err := url.Parse(link.URL); err != nil || u.Host == "" {
+			add("links", Error, false, "link %d has invalid URL %q", i+1, link.URL)
+			continue
+		}
+		key := NormalizeURL(link.URL)
+		if j, ok := seen[key]; ok {
+			add("links", Warning, true, "link %d duplicates link %d", i+1, j)
+		} else {
+			seen[key] = i + 1
+		}
+	}
+}
+
+func checkEpisodeChapters(ep *Episode, add addFinding) {
+	end, _ := time.ParseDuration(ep.Duration)
+	for i, c := range ep.Chapters {
+		if strings.TrimSpace(c.Title) == "" {
+			add("chapters", Error, false, "chapter %d has no title", i+1)
+		}
+		if i > 0 && c.Start <= ep.Chapters[i-1].Start {
+			add("chapters", Error, false, "chapter %d starts at %s, not after chapter %d", i+1, c.Start, i)
+		} else if end > 0 && time.Duration(c.Start) >= end {
+			add("chapters", Warning, false, "chapter %d starts at %s, after the end of the episode (%s)", i+1, c.Start, ep.Duration)
+		}
+	}
+}
+
+func checkEpisodeText(ep *Episode, add addFinding) {
+	if ep.Summary != strings.TrimSpace(ep.Summary) {
+		add("summary", Warning, true, "leading or trailing whitespace")
+	}
+	if ep.Topics != strings.TrimSpace(ep.Topics) {
+		add("topics", Warning, true, "leading or trailing whitespace")
+	}
+	if ep.AutoSummary {
+		add("auto-summary", Warning, false, "summary was filled in automatically; review it and remove auto-summary")
+	}
+}
+
+// FixEpisode repairs the fixable problems reported by validation in ep, and
+// returns a description of each change made.
+func FixEpisode(ep *Episode) []string {
+	var out []string
+	if id, ok := YouTubeVideoID(ep.YouTubeURL); ok {
+		if canon := youTubeWatchURL(id); canon != ep.YouTubeURL {
+			out = append(out, fmt.Sprintf("youtube: %s → %s", ep.YouTubeURL, canon))
+			ep.YouTubeURL = canon
+		}
+	}
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range ep.Tags {
+		fix := tag
+		if !tagWord.MatchString(tag) && tagWord.MatchString(canonicalTag(tag)) {
+			fix = canonicalTag(tag)
+			out = append(out, fmt.Sprintf("tags: %q → %q", tag, fix))
+		}
+		if seen[fix] {
+			out = append(out, fmt.Sprintf("tags: remove duplicate %q", fix))
+			continue
+		}
+		seen[fix] = true
+		tags = append(tags, fix)
+	}
+	ep.Tags = tags
+
+	links, removed := DedupLinks(ep.Links)
+	for _, u := range removed {
+		out = append(out, fmt.Sprintf("links: remove duplicate %s", u))
+	}
+	ep.Links = links
+
+	if t := strings.TrimSpace(ep.Summary); t != ep.Summary {
+		ep.Summary = t
+		out = append(out, "summary: trim whitespace")
+	}
+	if t := strings.TrimSpace(ep.Topics); t != ep.Topics {
+		ep.Topics = t
+		out = append(out, "topics: trim whitespace")
+	}
+	return out
+}
+
+// canonicalTag converts tag to lower-case words separated by hyphens.
+func canonicalTag(tag string) string {
+	return strings.Join(Words(strings.NewReplacer("-", " ", "_", " ").Replace(tag)), "-")
+}
+
+func youTubeWatchURL(id string) string { return fmt.Sprintf(youTubeWatchBase, id) }
+
+// ValidateSchedule checks the dates of the episode files at paths against
+// the schedule s, and reports episodes that air on a day that is not a
+// scheduled show day, that air on or before the episode preceding them, or
+// that air more than s.MaxGap days after it. These usually indicate a typo in
+// the date rather than an off-schedule show, so they are reported as
+// warnings. Special episodes, and episodes that air before s.CheckSince, are
+// not checked. Files that cannot be loaded are skipped; ValidateEpisodeFile
+// reports their problems.
+func ValidateSchedule(s *Schedule, paths []string) []*Finding {
+	type episode struct {
+		path string
+		line int
+		ep   *Episode
+	}
+	var eps []episode
+	for _, path := range paths {
+		ep, err := LoadEpisode(path)
+		if err != nil || ep.Special || ep.Episode.Number() < 0 {
+			continue
+		}
+		line := 0
+		if data, err := os.ReadFile(path); err == nil {
+			if front, _, err := splitFrontMatter(data); err == nil {
+				line = newKeyLines(front, 1)["date"]
+			}
+		}
+		eps = append(eps, episode{path, line, ep})
+	}
+	sort.Slice(eps, func(i, j int) bool {
+		return eps[i].ep.Episode.Number() < eps[j].ep.Episode.Number()
+	})
+
+	var out []*Finding
+	for i, e := range eps {
+		date := time.Time(e.ep.Date)
+		if date.Before(time.Time(s.CheckSince)) {
+			continue
+		}
+		add := func(msg string, args ...interface{}) {
+			out = append(out, &Finding{
+				Path:     e.path,
+				Line:     e.line,
+				Field:    "date",
+				Severity: Warning,
+				Message:  fmt.Sprintf(msg, args...),
+			})
+		}
+		if !s.IsShowDay(e.ep.Date) {
+			if s.days[date.Weekday()] {
+				add("%s is a skipped day in the schedule", e.ep.Date)
+			} else {
+				add("%s is a %s, not a scheduled show day", e.ep.Date, date.Weekday())
+			}
+		}
+		if i == 0 {
+			continue
+		}
+		prev := eps[i-1].ep
+		days := int(date.Sub(time.Time(prev.Date)).Round(time.Hour).Hours()) / 24
+		if days <= 0 {
+			add("airs on or before episode %s (%s)", prev.Episode, prev.Date)
+		} else if s.MaxGap > 0 && days > s.MaxGap {
+			add("airs %d days after episode %s (%s)", days, prev.Episode, prev.Date)
+		}
+	}
+	return out
+}
+
+// ValidateGuestFile checks the guest list at path, which may be a single file
+// or sharded, for problems. An error is reported only if the files cannot be
+// read; problems with their contents are reported as findings.
+func ValidateGuestFile(path string) ([]*Finding, error) {
+	files, err := GuestFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	v := &guestValidator{
+		sharded: IsShardedGuestList(path),
+		names:   make(map[string]guestLoc),
+		handles: make(map[string]guestLoc),
+	}
+	var out []*Finding
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v.check(file, data)...)
+	}
+	return out, nil
+}
+
+// A guestLoc is the location of a guest record.
+type guestLoc struct {
+	path string
+	line int
+}
+
+// seeAlso returns a reference to loc, for a finding in the file at path.
+func (loc guestLoc) seeAlso(path string) string {
+	if loc.path == path {
+		return fmt.Sprintf("see line %d", loc.line)
+	}
+	return fmt.Sprintf("see %s line %d", loc.path, loc.line)
+}
+
+// A guestValidator checks the files of a guest list, tracking names and
+// handles across files.
+type guestValidator struct {
+	sharded bool
+	names   map[string]guestLoc
+	handles map[string]guestLoc
+}
+
+func (v *guestValidator) check(path string, data []byte) []*Finding {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []*Finding{{Path: path, Severity: Error, Message: err.Error()}}
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.SequenceNode {
+		return []*Finding{{Path: path, Line: 1, Severity: Error, Message: "guest list is not a sequence"}}
+	}
+
+	var out []*Finding
+	for _, node := range doc.Content[0].Content {
+		var g Guest
+		add := func(sev Severity, fixable bool, msg string, args ...interface{}) {
+			out = append(out, &Finding{
+				Path:     path,
+				Line:     node.Line,
+				Field:    "guest",
+				Severity: sev,
+				Message:  fmt.Sprintf(msg, args...),
+				Fixable:  fixable,
+			})
+		}
+		if err := node.Decode(&g); err != nil {
+			add(Error, false, "invalid guest record: %v", err)
+			continue
+		}
+
+		if g.Name == "" {
+			add(Error, false, "missing guest name")
+		} else if loc, ok := v.names[g.Name]; ok {
+			add(Error, false, "duplicate guest name %q (%s)", g.Name, loc.seeAlso(path))
+		} else {
+			v.names[g.Name] = guestLoc{path, node.Line}
+		}
+		if h := strings.ToLower(strings.TrimPrefix(g.Twitter, "@")); h != "" {
+			if loc, ok := v.handles[h]; ok {
+				add(Warning, false, "duplicate Twitter handle %q (%s)", g.Twitter, loc.seeAlso(path))
+			} else {
+				v.handles[h] = guestLoc{path, node.Line}
+			}
+		}
+		if strings.HasPrefix(g.Twitter, "@") {
+			add(Warning, true, "Twitter handle %q should not include @", g.Twitter)
+		}
+		if len(g.Episodes) == 0 {
+			add(Warning, false, "guest %q has no episodes", g.Name)
+		} else if !labelsSorted(g.Episodes) || hasDuplicates(g.Episodes) {
+			add(Warning, true, "episode list for %q is unsorted or has duplicates", g.Name)
+		}
+		if v.sharded && g.Name != "" {
+			if want := GuestShard(g.Name); want != filepath.Base(path) {
+				add(Warning, true, "guest %q belongs in shard %s", g.Name, want)
+			}
+		}
+	}
+	return out
+}
+
+func labelsSorted(vs []Label) bool {
+	return sort.SliceIsSorted(vs, func(i, j int) bool { return labelLess(vs[i], vs[j]) })
+}
+
+func hasDuplicates(vs []Label) bool {
+	seen := make(map[Label]bool)
+	for _, v := range vs {
+		if seen[v] {
+			return true
+		}
+		seen[v] = true
+	}
+	return false
+}
+
+// FixGuestFile repairs the fixable problems reported by validation in the guest
+// list at path, and returns a description of each change made. The files are
+// rewritten only if changes were made.
+func FixGuestFile(path string) ([]string, error) {
+	src, entries, err := loadGuestFile(repo.OS, path)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, g := range entries {
+		if src.sharded {
+			if want := GuestShard(g.Name); want != filepath.Base(src.files[g]) {
+				out = append(out, fmt.Sprintf("%s: move to shard %s", g.Name, want))
+			}
+		}
+		if t := strings.TrimPrefix(g.Twitter, "@"); t != g.Twitter {
+			out = append(out, fmt.Sprintf("%s: twitter %q → %q", g.Name, g.Twitter, t))
+			g.Twitter = t
+		}
+		if !labelsSorted(g.Episodes) || hasDuplicates(g.Episodes) {
+			var eps []Label
+			seen := make(map[Label]bool)
+			for _, v := range g.Episodes {
+				if !seen[v] {
+					seen[v] = true
+					eps = append(eps, v)
+				}
+			}
+			SortLabels(eps)
+			g.Episodes = eps
+			out = append(out, fmt.Sprintf("%s: sort episode list", g.Name))
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, writeGuestFile(repo.OS, path, src, entries)
+}
@@ -0,0 +1,57 @@
+package ilof
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// waybackAPI is the URL of the Wayback Machine availability API.
+const waybackAPI = "https://archive.org/wayback/available"
+
+// WaybackSnapshot returns the URL of the archived snapshot of target in the
+// Wayback Machine closest to the given timestamp (in the form "20060102"), or
+// to the present if timestamp is "". It reports ok == false without error if
+// no snapshot is available.
+func WaybackSnapshot(ctx context.Context, target, timestamp string) (_ string, ok bool, _ error) {
+	q := make(url.Values)
+	q.Set("url", target)
+	if timestamp != "" {
+		q.Set("timestamp", timestamp)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", waybackAPI+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", false, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Add("Accept", "application/json")
+	bits, err := loadRequest(ctx, req)
+	if err != nil {
+		return "", false, err
+	}
+	var rsp struct {
+		Snapshots struct {
+			Closest *struct {
+				Available bool   `json:"available"`
+				URL       string `json:"url"`
+				Status    string `json:"status"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.Unmarshal(bits, &rsp); err != nil {
+		return "", false, fmt.Errorf("decoding response: %w", err)
+	}
+	c := rsp.Snapshots.Closest
+	if c == nil || !c.Available || c.URL == "" || !strings.HasPrefix(c.Status, "2") {
+		return "", false, nil
+	}
+	return strings.Replace(c.URL, "http://", "https://", 1), true, nil
+}
+
+// IsWaybackURL reports whether s is the URL of a Wayback Machine snapshot.
+func IsWaybackURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Host == "web.archive.org" || u.Host == "archive.org") && strings.HasPrefix(u.Path, "/web/")
+}
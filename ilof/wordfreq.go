@@ -0,0 +1,157 @@
+package ilof
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"bitbucket.org/creachadair/stringset"
+)
+
+// FreqOptions control how a WordFreq counts words and phrases. A nil
+// *FreqOptions counts single words, discarding EnglishStopwords.
+type FreqOptions struct {
+	// Phrases of up to this many words are counted along with single words.
+	// If MaxPhrase <= 1, only single words are counted.
+	MaxPhrase int
+
+	// Words in this set are not counted, and phrases do not span them. If
+	// nil, EnglishStopwords is used; to count all words, use an empty set.
+	Stopwords stringset.Set
+}
+
+// A WordFreq is a table of the frequencies of the words and phrases in a
+// collection of texts, such as a transcript or all the episodes of a season.
+// Words are normalized to lower case, and single words with fewer than three
+// letters are not counted. A WordFreq is safe for concurrent use by multiple
+// goroutines.
+type WordFreq struct {
+	maxPhrase int
+	stop      stringset.Set
+
+	mu     sync.Mutex
+	texts  int
+	counts map[string]int
+	docs   map[string]int // number of texts containing each term
+}
+
+// NewWordFreq constructs an empty frequency table with the given options.
+func NewWordFreq(opts *FreqOptions) *WordFreq {
+	f := &WordFreq{
+		maxPhrase: 1,
+		stop:      EnglishStopwords,
+		counts:    make(map[string]int),
+		docs:      make(map[string]int),
+	}
+	if opts != nil {
+		f.maxPhrase = max(opts.MaxPhrase, 1)
+		if opts.Stopwords != nil {
+			f.stop = opts.Stopwords
+		}
+	}
+	return f
+}
+
+// Add counts the words and phrases of text as a separate text of the table.
+func (f *WordFreq) Add(text string) {
+	counts := make(map[string]int)
+	for _, run := range candidatePhrases(text, f.stop, 0) {
+		for i := range run {
+			for n := 1; n <= f.maxPhrase && i+n <= len(run); n++ {
+				if n == 1 && !goodKeyword(run[i]) {
+					continue
+				}
+				counts[strings.Join(run[i:i+n], " ")]++
+			}
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.texts++
+	for term, n := range counts {
+		f.counts[term] += n
+		f.docs[term]++
+	}
+}
+
+// Merge adds the counts of o to f.
+func (f *WordFreq) Merge(o *WordFreq) {
+	o.mu.Lock()
+	counts := make(map[string]int, len(o.counts))
+	docs := make(map[string]int, len(o.docs))
+	for term, n := range o.counts {
+		counts[term] = n
+	}
+	for term, n := range o.docs {
+		docs[term] = n
+	}
+	texts := o.texts
+	o.mu.Unlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.texts += texts
+	for term, n := range counts {
+		f.counts[term] += n
+	}
+	for term, n := range docs {
+		f.docs[term] += n
+	}
+}
+
+// Len reports the number of texts added to f.
+func (f *WordFreq) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.texts
+}
+
+// A WordCount reports the frequency of a word or phrase.
+type WordCount struct {
+	Text   string  `json:"text"`
+	Count  int     `json:"count"`  // total occurrences
+	Texts  int     `json:"texts"`  // number of texts containing it
+	Weight float64 `json:"weight"` // count relative to the most frequent term, in (0, 1]
+}
+
+// Top returns up to n of the most frequent words and phrases, in decreasing
+// order of count with ties broken by text. If n <= 0, all terms are returned.
+func (f *WordFreq) Top(n int) []*WordCount {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]*WordCount, 0, len(f.counts))
+	for term, c := range f.counts {
+		out = append(out, &WordCount{Text: term, Count: c, Texts: f.docs[term]})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Text < out[j].Text
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	if len(out) != 0 {
+		top := float64(out[0].Count)
+		for _, wc := range out {
+			wc.Weight = float64(wc.Count) / top
+		}
+	}
+	return out
+}
+
+// A WordCloud is the data for rendering a word cloud, in a form suitable for
+// encoding as JSON.
+type WordCloud struct {
+	Title string       `json:"title,omitempty"`
+	Texts int          `json:"texts"` // number of texts counted
+	Terms []*WordCount `json:"terms"` // in decreasing order of count
+}
+
+// Cloud returns a word cloud with the given title of up to n of the most
+// frequent terms of f, as reported by Top.
+func (f *WordFreq) Cloud(title string, n int) *WordCloud {
+	return &WordCloud{Title: title, Texts: f.Len(), Terms: f.Top(n)}
+}
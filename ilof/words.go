@@ -0,0 +1,256 @@
+package ilof
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"bitbucket.org/creachadair/stringset"
+)
+
+// WordOptions control how text is split into words by WordsWith. A nil
+// *WordOptions is valid, and gives the same results as Words.
+type WordOptions struct {
+	// If true, words are maximal runs of Unicode letters, marks, and digits,
+	// so that accented letters are retained and punctuation separates words.
+	// Apostrophes within a word are kept, as in "o'brien". Otherwise, words
+	// are separated by whitespace and non-word characters are discarded.
+	Unicode bool
+
+	// If true, each word is reduced by Stem, so that plurals and possessives
+	// match their base forms.
+	Stem bool
+
+	// If non-nil, words in this set are discarded. Apostrophes are ignored
+	// when matching words against the set, so it should contain words like
+	// "don't" in the form "dont". Stopwords are removed before stemming.
+	Stopwords stringset.Set
+}
+
+// EnglishStopwords is a list of common English words that carry little
+// meaning for comparing texts. Programs may add or remove words.
+var EnglishStopwords = stringset.New(
+	"a", "about", "after", "again", "all", "also", "am", "an", "and", "any",
+	"are", "arent", "as", "at", "be", "because", "been", "before", "being",
+	"between", "both", "but", "by", "can", "cant", "could", "did", "didnt",
+	"do", "does", "doesnt", "doing", "dont", "down", "during", "each", "few",
+	"for", "from", "further", "had", "has", "have", "having", "he", "her",
+	"here", "hers", "herself", "him", "himself", "his", "how", "i", "if",
+	"im", "in", "into", "is", "isnt", "it", "its", "itself", "just", "me",
+	"more", "most", "my", "myself", "no", "nor", "not", "now", "of", "off",
+	"on", "once", "only", "or", "other", "our", "ours", "ourselves", "out",
+	"over", "own", "same", "she", "should", "so", "some", "such", "than",
+	"that", "thats", "the", "their", "theirs", "them", "themselves", "then",
+	"there", "these", "they", "this", "those", "through", "to", "too",
+	"under", "until", "up", "very", "was", "wasnt", "we", "were", "what",
+	"when", "where", "which", "while", "who", "whom", "why", "will", "with",
+	"would", "you", "your", "yours", "yourself", "yourselves",
+)
+
+// stopWordOptions are the options used by WordsFiltered.
+var stopWordOptions = &WordOptions{Stopwords: EnglishStopwords}
+
+// WordsFiltered parses s into a bag of words as Words does, but discards the
+// words in EnglishStopwords.
+func WordsFiltered(s string) []string { return WordsWith(s, stopWordOptions) }
+
+// WordsWith parses s into a bag of words normalized to lower-case, as
+// directed by opts.
+func WordsWith(s string, opts *WordOptions) []string {
+	if opts == nil {
+		return Words(s)
+	} else if !opts.Unicode {
+		var words []string
+		for _, w := range Words(s) {
+			if opts.isStopword(w) {
+				continue
+			} else if opts.Stem {
+				w = Stem(w)
+			}
+			words = append(words, w)
+		}
+		return words
+	}
+
+	var words []string
+	var cur strings.Builder
+	flush := func() {
+		w := strings.Trim(cur.String(), "'")
+		cur.Reset()
+		if w == "" || opts.isStopword(w) {
+			return
+		} else if opts.Stem {
+			w = Stem(w)
+		}
+		words = append(words, w)
+	}
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r):
+			cur.WriteRune(r)
+		case isApostrophe(r) && cur.Len() != 0:
+			cur.WriteByte('\'')
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
+
+func (o *WordOptions) isStopword(w string) bool {
+	return o.Stopwords != nil && o.Stopwords.Contains(strings.ReplaceAll(w, "'", ""))
+}
+
+func isApostrophe(r rune) bool { return r == '\'' || r == '’' || r == 'ʼ' }
+
+// Stem applies a light stemmer to word, which must be lower-case. It removes
+// a possessive suffix and reduces regular English plurals to their singular,
+// so that for example "subpoenas" and "subpoena's" both become "subpoena".
+// Words of three or fewer letters are not changed.
+func Stem(word string) string {
+	word = strings.TrimSuffix(word, "'s")
+	word = strings.TrimSuffix(word, "'")
+	if utf8.RuneCountInString(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "ies") && !strings.HasSuffix(word, "eies") && !strings.HasSuffix(word, "aies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case hasAnySuffix(word, "sses", "zzes", "xes", "ches", "shes"):
+		return strings.TrimSuffix(word, "es") // e.g., "glasses", "churches"
+	case strings.HasSuffix(word, "oes") && utf8.RuneCountInString(word) > 5:
+		return strings.TrimSuffix(word, "es") // e.g., "heroes", but not "shoes"
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "ss"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suf := range suffixes {
+		if strings.HasSuffix(s, suf) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsPhrase reports whether s contains phrase as a sequence of whole
+// words, ignoring case and punctuation.
+func ContainsPhrase(s, phrase string) bool {
+	start, _ := newWordMatcher([]string{phrase}, nil, 0).next(Words(s), 0)
+	return start >= 0
+}
+
+// Near reports whether s contains all the given words, in any order, within
+// window consecutive words, ignoring case and punctuation. If window <= 0,
+// the words must be adjacent.
+func Near(s string, window int, words ...string) bool {
+	set := strings.Join(words, " ")
+	start, _ := newWordMatcher(nil, []string{set}, window).next(Words(s), 0)
+	return start >= 0
+}
+
+// A wordMatcher finds mentions of phrases, each a sequence of whole words,
+// and of sets of words occurring near each other in any order. It is the
+// common implementation of ContainsPhrase, Near, and TagRule.Mentions.
+type wordMatcher struct {
+	phrases [][]string
+	near    [][]string
+	window  int // if <= 0, the number of words in each near set
+}
+
+// newWordMatcher returns a wordMatcher for the given phrases and near sets,
+// each of which is split into words. Those with no words are discarded.
+func newWordMatcher(phrases, near []string, window int) *wordMatcher {
+	m := &wordMatcher{window: window}
+	for _, p := range phrases {
+		if pw := Words(p); len(pw) != 0 {
+			m.phrases = append(m.phrases, pw)
+		}
+	}
+	for _, p := range near {
+		if pw := Words(p); len(pw) != 0 {
+			m.near = append(m.near, pw)
+		}
+	}
+	return m
+}
+
+// next returns the offsets of the first mention in words at or after offset
+// i, or -1, -1 if there is none. If several mentions begin at the same word,
+// the one that ends last is reported.
+func (m *wordMatcher) next(words []string, i int) (start, end int) {
+	for ; i < len(words); i++ {
+		end := -1
+		for _, pw := range m.phrases {
+			if hasPhraseAt(words, i, pw) {
+				end = max(end, i+len(pw))
+			}
+		}
+		for _, nw := range m.near {
+			window := m.window
+			if window <= 0 {
+				window = len(nw)
+			}
+			if j := nearAt(words, i, window, nw); j > 0 {
+				end = max(end, j)
+			}
+		}
+		if end >= 0 {
+			return i, end
+		}
+	}
+	return -1, -1
+}
+
+// count reports the number of mentions in words. Overlapping mentions are
+// counted once.
+func (m *wordMatcher) count(words []string) int {
+	var n int
+	for i := 0; ; n++ {
+		start, end := m.next(words, i)
+		if start < 0 {
+			return n
+		}
+		i = end
+	}
+}
+
+// hasPhraseAt reports whether words contains the words of phrase starting at
+// offset i.
+func hasPhraseAt(words []string, i int, phrase []string) bool {
+	if i+len(phrase) > len(words) {
+		return false
+	}
+	for j, w := range phrase {
+		if words[i+j] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// nearAt reports whether all of want occur in the window of words starting
+// at offset i, with words[i] among them. If so, it returns the offset just
+// past the last of them; otherwise it returns 0.
+func nearAt(words []string, i, window int, want []string) int {
+	need := make(map[string]int)
+	for _, w := range want {
+		need[w]++
+	}
+	if need[words[i]] == 0 {
+		return 0
+	}
+	left := len(want)
+	for j := i; j < len(words) && j < i+window; j++ {
+		if need[words[j]] > 0 {
+			need[words[j]]--
+			if left--; left == 0 {
+				return j + 1
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+// Program installhook installs a git pre-commit hook in the site repository
+// that runs lint on the episode files and guest list staged for each commit,
+// and rejects the commit if lint finds any errors.
+//
+// The hook runs the lint binary at the path given by -lint, which defaults to
+// the lint found on $PATH. The hook checks the working tree copies of the
+// staged files, so a file that is only partly staged is checked in full.
+//
+// An existing pre-commit hook is not replaced unless -force is given, or it
+// was installed by this program. Use -uninstall to remove the hook.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	lintPath    = flag.String("lint", "", "Path of the lint binary (default: lint on $PATH)")
+	doForce     = flag.Bool("force", false, "Replace an existing pre-commit hook")
+	doUninstall = flag.Bool("uninstall", false, "Remove the pre-commit hook installed by this program")
+)
+
+// hookMarker identifies a hook installed by this program.
+const hookMarker = "# Installed by installhook: lint staged episode and guest files."
+
+const hookTemplate = `#!/bin/sh
+%s
+# Reinstall with installhook to update, or remove with installhook -uninstall.
+cd "$(git rev-parse --show-toplevel)" || exit 1
+git diff --cached -z --name-only --diff-filter=ACMR |
+  xargs -0 sh -c '[ "$#" -eq 0 ] || exec "$0" -warnings=false -- "$@"' %s
+`
+
+// shellQuote quotes s as a single word for the POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	dir, err := repo.HooksDir()
+	if err != nil {
+		log.Fatalf("Finding hooks directory: %v", err)
+	}
+	path := filepath.Join(dir, "pre-commit")
+	old, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Reading existing hook: %v", err)
+	}
+	ours := bytes.Contains(old, []byte(hookMarker))
+
+	if *doUninstall {
+		if old == nil {
+			log.Printf("No pre-commit hook is installed")
+			return
+		} else if !ours {
+			log.Fatalf("Hook %q was not installed by this program; not removing it", path)
+		}
+		if err := os.Remove(path); err != nil {
+			log.Fatalf("Removing hook: %v", err)
+		}
+		log.Printf("Removed pre-commit hook %q", path)
+		return
+	}
+
+	if old != nil && !ours && !*doForce {
+		log.Fatalf("A pre-commit hook already exists at %q (use -force to replace it)", path)
+	}
+	lint := *lintPath
+	if lint == "" {
+		lint, err = exec.LookPath("lint")
+		if err != nil {
+			log.Fatalf("Finding lint: %v\n(Install lint or set -lint)", err)
+		}
+	}
+	lint, err = filepath.Abs(lint)
+	if err != nil {
+		log.Fatalf("Resolving lint path: %v", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatalf("Creating hooks directory: %v", err)
+	}
+	hook := fmt.Sprintf(hookTemplate, hookMarker, shellQuote(lint))
+	if err := atomicfile.WriteData(path, []byte(hook), 0755); err != nil {
+		log.Fatalf("Writing hook: %v", err)
+	}
+	log.Printf("Installed pre-commit hook %q running %q", path, lint)
+}
@@ -0,0 +1,203 @@
+// Program linkcheck checks the URLs mentioned by episode files in the site
+// repository, and reports links that are dead or have been redirected.
+//
+// URLs are collected from the stream and audio fields of each episode, its
+// list of links, and the text of its summary and body. Checks run
+// concurrently, but requests to any one host are spaced by at least -host-delay
+// to avoid tripping rate limits. Progress is displayed on stderr, as a bar if
+// stderr is a terminal.
+//
+// Links on link-shortening services such as bit.ly and t.co are reported as
+// "shortened", with their expanded destinations as suggested replacements.
+// Crowdcast links that answer with an error page are not detected here; use
+// cccheck for those.
+//
+// YouTube answers 200 OK for the watch page of a deleted or private video, so
+// if a YOUTUBE_API_KEY credential is available, the YouTube videos of the
+// episodes are instead looked up with the YouTube Data API, in batches of 50,
+// and those that are missing or not public are reported as "unavailable".
+//
+// The report is written to stdout as JSON. Exit status 0 means no problem
+// links were found; exit status 3 means some were, and exit status 1 means
+// the check could not be completed.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	numWorkers = flag.Int("workers", 8, "Number of concurrent checks")
+	hostDelay  = flag.Duration("host-delay", 1*time.Second, "Minimum time between requests to the same host")
+	timeout    = flag.Duration("timeout", 30*time.Second, "Timeout for each check")
+	showRedir  = flag.Bool("redirects", true, "Report redirected links as well as dead ones")
+	showShort  = flag.Bool("shortened", true, "Report shortened links with their expansions")
+)
+
+// A result reports a problem with a link found in an episode file.
+type result struct {
+	Path    string           `json:"path"`
+	Episode ilof.Label       `json:"episode"`
+	Field   string           `json:"field"`
+	Problem string           `json:"problem"` // "dead", "redirected", "shortened", or "unavailable"
+	Status  *ilof.LinkStatus `json:"status"`
+	Suggest string           `json:"suggest,omitempty"` // suggested replacement
+}
+
+type check struct {
+	path string
+	ep   *ilof.Episode
+	eu   ilof.EpisodeURL
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	var checks []check
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		for _, eu := range ep.URLs() {
+			checks = append(checks, check{path: path, ep: ep, eu: eu})
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	ctx := context.Background()
+	var results []*result
+	if apiKey := ilof.YouTubeAPIKey.Get(); apiKey != "" {
+		var rs []*result
+		var err error
+		checks, rs, err = checkVideos(ctx, checks, apiKey)
+		if err != nil {
+			log.Fatalf("Checking videos: %v", err)
+		}
+		results = append(results, rs...)
+	}
+	log.Printf("Checking %d links", len(checks))
+
+	if *hostDelay > 0 {
+		ilof.RateLimiter = ilof.NewHostLimiter(1/hostDelay.Seconds(), 1)
+	}
+
+	// The timeout applies to each request once it is sent, so that time spent
+	// waiting for other requests to the same host is not counted.
+	opts := *ilof.DefaultFetchOptions
+	opts.Timeout = *timeout
+	ilof.DefaultFetchOptions = &opts
+	var mu sync.Mutex
+	bar := ilof.NewProgressBar(os.Stderr, "Checking links")
+	log.SetOutput(bar)
+	err := ilof.RunBatch(ctx, len(checks), &ilof.BatchOptions{
+		Workers:  *numWorkers,
+		Progress: bar.Update,
+	}, func(ctx context.Context, i int) string {
+		c := checks[i]
+		if r := checkOne(ctx, c); r != nil {
+			mu.Lock()
+			results = append(results, r)
+			mu.Unlock()
+		}
+		return c.eu.URL
+	})
+	bar.Finish()
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		log.Fatalf("Checking links: %v", err)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Path != results[j].Path {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].Status.URL < results[j].Status.URL
+	})
+	log.Printf("Found %d problem links", len(results))
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(struct {
+		R []*result `json:"results"`
+	}{R: results}); err != nil {
+		log.Fatalf("Encoding JSON: %v", err)
+	}
+	if len(results) != 0 {
+		os.Exit(3)
+	}
+}
+
+// checkVideos looks up the videos of the "youtube" checks with the YouTube
+// Data API, and returns the remaining checks and a result for each video that
+// is not available.
+func checkVideos(ctx context.Context, checks []check, apiKey string) ([]check, []*result, error) {
+	var rest, videos []check
+	var ids []string
+	for _, c := range checks {
+		if id, ok := ilof.YouTubeVideoID(c.eu.URL); ok && c.eu.Field == "youtube" {
+			videos = append(videos, c)
+			ids = append(ids, id)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	log.Printf("Looking up %d videos", len(ids))
+	infos, err := ilof.YouTubeVideosInfo(ctx, ids, apiKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	var out []*result
+	for i, c := range videos {
+		st := &ilof.LinkStatus{URL: c.eu.URL}
+		info, ok := infos[ids[i]]
+		switch {
+		case !ok:
+			st.Error = "video not found"
+		case info.Privacy == "private":
+			st.Error = "video is private"
+		case !info.Available():
+			st.Error = "video upload is " + info.UploadStatus
+		default:
+			continue
+		}
+		out = append(out, &result{Path: c.path, Episode: c.ep.Episode, Field: c.eu.Field, Problem: "unavailable", Status: st})
+	}
+	return rest, out, nil
+}
+
+// checkOne checks a single link, and returns a result if it has a problem.
+func checkOne(ctx context.Context, c check) *result {
+	st := ilof.CheckLink(ctx, c.eu.URL)
+
+	r := &result{Path: c.path, Episode: c.ep.Episode, Field: c.eu.Field, Status: st}
+	if *showShort && st.OK() && ilof.IsShortLink(c.eu.URL) {
+		if final, err := ilof.DefaultExpander.Expand(ctx, c.eu.URL); err == nil && final != c.eu.URL {
+			r.Problem = "shortened"
+			r.Suggest = final
+			return r
+		}
+	}
+	switch {
+	case !st.OK():
+		r.Problem = "dead"
+		if st.Redirected() {
+			r.Suggest = st.Final
+		}
+	case st.Redirected() && *showRedir:
+		r.Problem = "redirected"
+		r.Suggest = st.Final
+	default:
+		return nil
+	}
+	return r
+}
@@ -0,0 +1,215 @@
+// Program lint checks the episode files and guest list of the site repository
+// for problems, and optionally repairs those that can be fixed automatically.
+//
+// Findings are printed one per line as "path:line: severity: message".
+//
+// If file paths are given as arguments, only those files are checked; paths
+// that are not episode files or the guest list are ignored. This allows lint
+// to check just the files staged for a commit (see installhook).
+//
+// Each episode file name must encode the air date of the episode and, if its
+// label is numeric, its label, since the site derives permalinks from file
+// names. With -fix, a mismatched file is renamed to agree with its front
+// matter, and a date or label missing from the front matter is filled in from
+// the file name.
+//
+// Episode dates are also checked against the show schedule (see the schedule
+// file in the site repository): an episode that airs on a day that is not a
+// show day, or out of order or long after the episode before it, is reported
+// as a warning. Set "check-since" and "max-gap" in the schedule file to tune
+// these checks.
+//
+// With -fix -fetch-titles, lint also fetches the page titles of episode links
+// that have none, so that the site does not show the bare URLs.
+//
+// Exit status 0 means no errors were found (warnings are permitted).
+// Exit status 3 means at least one error was found.
+// Any other status means some other failure.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	doFix       = flag.Bool("fix", false, "Repair fixable problems in place")
+	fetchTitles = flag.Bool("fetch-titles", false, "With -fix, fetch page titles for links that lack them")
+	doSchedule  = flag.Bool("schedule", true, "Check episode dates against the show schedule")
+	doJSON      = flag.Bool("json", false, "Write findings as JSON")
+	warnings    = flag.Bool("warnings", true, "Report warnings as well as errors")
+)
+
+func main() {
+	flag.Parse()
+
+	// Resolve paths named on the command line before changing directory.
+	var args []string
+	for _, arg := range flag.Args() {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			log.Fatalf("Resolving path: %v", err)
+		}
+		args = append(args, abs)
+	}
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	epPaths, doGuests, err := repo.SelectFiles(args)
+	if err != nil {
+		log.Fatalf("Selecting files: %v", err)
+	}
+
+	if *doFix {
+		if err := fixFiles(epPaths, doGuests); err != nil {
+			log.Fatalf("Fixing: %v", err)
+		}
+	}
+
+	var findings []*ilof.Finding
+	for _, path := range epPaths {
+		fs, err := ilof.ValidateEpisodeFile(path)
+		if err != nil {
+			log.Fatalf("Checking episodes: %v", err)
+		}
+		findings = append(findings, fs...)
+	}
+	if *doSchedule && len(epPaths) != 0 {
+		fs, err := checkSchedule(epPaths)
+		if err != nil {
+			log.Fatalf("Checking schedule: %v", err)
+		}
+		findings = append(findings, fs...)
+	}
+	if doGuests {
+		fs, err := ilof.ValidateGuestFile(repo.GuestFile)
+		if err != nil {
+			log.Fatalf("Checking guests: %v", err)
+		}
+		findings = append(findings, fs...)
+	}
+
+	var numErrors int
+	var report []*ilof.Finding
+	for _, f := range findings {
+		if f.Severity == ilof.Error {
+			numErrors++
+		} else if !*warnings {
+			continue
+		}
+		report = append(report, f)
+	}
+	sort.SliceStable(report, func(i, j int) bool {
+		if report[i].Path != report[j].Path {
+			return report[i].Path < report[j].Path
+		}
+		return report[i].Line < report[j].Line
+	})
+
+	if *doJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			F []*ilof.Finding `json:"findings"`
+		}{F: report}); err != nil {
+			log.Fatalf("Encoding JSON: %v", err)
+		}
+	} else {
+		for _, f := range report {
+			fmt.Println(f)
+		}
+	}
+	if numErrors != 0 {
+		log.Printf("Found %d errors", numErrors)
+		os.Exit(3)
+	}
+}
+
+// checkSchedule checks the dates of the episode files at epPaths against the
+// show schedule. Since an episode date is checked against the episode before
+// it, all the episode files are loaded, but only findings for epPaths are
+// reported.
+func checkSchedule(epPaths []string) ([]*ilof.Finding, error) {
+	sched, err := ilof.LoadSchedule(repo.ScheduleFile)
+	if err != nil {
+		return nil, err
+	}
+	var all []string
+	if err := ilof.ForEachEpisodeFile(repo.EpisodeDir, func(path string) error {
+		all = append(all, path)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	want := make(map[string]bool)
+	for _, path := range epPaths {
+		want[path] = true
+	}
+	var out []*ilof.Finding
+	for _, f := range ilof.ValidateSchedule(sched, all) {
+		if want[f.Path] {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+// fixFiles repairs fixable problems in the given episode files, and in the
+// guest list if doGuests is true. Episode files whose names disagree with
+// their front matter are renamed, and their entries in epPaths updated.
+func fixFiles(epPaths []string, doGuests bool) error {
+	for i, path := range epPaths {
+		ep, err := ilof.LoadEpisode(path)
+		if err != nil {
+			continue // not fixable; this will be reported by validation
+		}
+		newPath, changes := ilof.FixEpisodeFileName(path, ep)
+		changes = append(changes, ilof.FixEpisode(ep)...)
+		if *fetchTitles {
+			added, errs := ilof.FillLinkTitles(context.Background(), ep)
+			for _, err := range errs {
+				log.Printf("* %s: %v", filepath.Base(path), err)
+			}
+			changes = append(changes, added...)
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		for _, c := range changes {
+			log.Printf("- %s: %s", filepath.Base(path), c)
+		}
+		// Write the new file before removing the old one, so that a failure
+		// does not lose the episode.
+		if newPath != path {
+			if _, err := os.Stat(newPath); err == nil {
+				return fmt.Errorf("%s: cannot rename, %s already exists", path, newPath)
+			}
+		}
+		if err := ilof.WriteEpisode(newPath, ep); err != nil {
+			return err
+		}
+		if newPath != path {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			epPaths[i] = newPath
+		}
+	}
+	if !doGuests {
+		return nil
+	}
+	changes, err := ilof.FixGuestFile(repo.GuestFile)
+	for _, c := range changes {
+		log.Printf("- %s: %s", filepath.Base(repo.GuestFile), c)
+	}
+	return err
+}
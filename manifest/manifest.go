@@ -0,0 +1,89 @@
+// Program manifest verifies the generated data files in the site repository
+// against the manifest of their digests.
+//
+// The tools that generate data files for the site (cards, corpus, onthisday,
+// podtranscripts, reading, schema, searchindex, seasonpages, sitemap,
+// tagpages, and transcriptfeed) record the SHA-256 digest of each file they
+// write, with the name and version of the tool and the time, in the manifest
+// file (by default _data/manifest.json). A file whose contents no longer
+// match the manifest has been edited by hand or corrupted, and should be
+// regenerated with the tool that wrote it.
+//
+// Exit status 0 means every recorded file matches. Exit status 1 means some
+// file is missing or modified; each one is reported.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	doJSON = flag.Bool("json", false, "Write problems as JSON")
+	doList = flag.Bool("list", false, "List the recorded files instead of verifying them")
+)
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	m, err := ilof.LoadManifest(repo.ManifestFile)
+	if os.IsNotExist(err) {
+		log.Fatalf("No manifest found at %q; it is created when a generator writes a data file", repo.ManifestFile)
+	} else if err != nil {
+		log.Fatalf("Loading manifest: %v", err)
+	}
+
+	if *doList {
+		if *doJSON {
+			writeJSON(m.Files)
+			return
+		}
+		paths := make([]string, 0, len(m.Files))
+		for path := range m.Files {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			e := m.Files[path]
+			fmt.Printf("%s\t%s\t%s\t%s\n", e.SHA256[:12], e.Generator, e.Generated.Format("2006-01-02T15:04:05Z"), path)
+		}
+		return
+	}
+
+	probs, err := m.Verify()
+	if err != nil {
+		log.Fatalf("Verifying manifest: %v", err)
+	}
+	if *doJSON {
+		if probs == nil {
+			probs = []*ilof.ManifestProblem{}
+		}
+		writeJSON(probs)
+	} else {
+		for _, p := range probs {
+			fmt.Println(p)
+		}
+	}
+	if len(probs) != 0 {
+		log.Printf("Found %d problems among %d generated files", len(probs), len(m.Files))
+		os.Exit(1)
+	}
+	log.Printf("All %d generated files match the manifest", len(m.Files))
+}
+
+func writeJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("Writing output: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+// Program milestones scans the episode catalog for upcoming milestones, such
+// as round-numbered episodes, anniversaries of the first episode, and guests
+// approaching a notable number of appearances.
+//
+// Milestones falling within the next -days days are reported. Episode
+// numbers for upcoming shows are projected from the broadcast schedule. If a
+// -webhook is set, the report is also posted there.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	numDays     = flag.Int("days", 14, "Report milestones within this many days")
+	epEvery     = flag.Int("every", 100, "Report episode numbers that are multiples of this")
+	appearances = flag.String("appearances", "10,25,50,100", "Comma-separated guest appearance counts to report")
+	webhookURL  = flag.String("webhook", "", "Post the report to this webhook URL (default $ILOF_WEBHOOK)")
+	doJSON      = flag.Bool("json", false, "Write the report as JSON")
+)
+
+// A milestone is a single report entry.
+type milestone struct {
+	Date    string `json:"date,omitempty"` // projected date, if known
+	Kind    string `json:"kind"`           // episode, anniversary, guest
+	Message string `json:"message"`
+}
+
+func main() {
+	flag.Parse()
+	counts, err := parseCounts(*appearances)
+	if err != nil {
+		log.Fatalf("Invalid -appearances: %v", err)
+	}
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	sched, err := ilof.LoadSchedule(repo.ScheduleFile)
+	if err != nil {
+		log.Fatalf("Loading schedule: %v", err)
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+
+	var lastNum int
+	var lastDate, firstDate time.Time
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		v := ep.Episode.Number()
+		if v < 0 || v != float64(int(v)) {
+			return nil // specials are not numbered in sequence
+		}
+		if int(v) > lastNum {
+			lastNum = int(v)
+		}
+		if at := time.Time(ep.Date); at.After(lastDate) {
+			lastDate = at
+		}
+		if v == 1 {
+			firstDate = time.Time(ep.Date)
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+
+	now := time.Now().In(sched.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	horizon := today.AddDate(0, 0, *numDays)
+	var out []*milestone
+
+	// Project episode numbers onto the scheduled shows after the most recent
+	// recorded episode.
+	num := lastNum
+	for _, start := range sched.Upcoming(now, 7*(*numDays)+7) {
+		day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		if !day.After(lastDate) {
+			continue // already recorded
+		} else if day.After(horizon) {
+			break
+		}
+		num++
+		if *epEvery > 0 && num%*epEvery == 0 {
+			out = append(out, &milestone{
+				Date:    day.Format("2006-01-02"),
+				Kind:    "episode",
+				Message: fmt.Sprintf("Episode %d is projected to air on %s", num, day.Format("Monday, January 2")),
+			})
+		}
+	}
+
+	if !firstDate.IsZero() {
+		for y := 1; ; y++ {
+			day := firstDate.AddDate(y, 0, 0)
+			if day.After(horizon) {
+				break
+			} else if day.Before(today) {
+				continue
+			}
+			out = append(out, &milestone{
+				Date:    day.Format("2006-01-02"),
+				Kind:    "anniversary",
+				Message: fmt.Sprintf("%d-year anniversary of episode 1 (%s)", y, firstDate.Format("2006-01-02")),
+			})
+		}
+	}
+
+	for _, g := range guests {
+		next := len(g.Episodes) + 1
+		if counts[next] {
+			out = append(out, &milestone{
+				Kind:    "guest",
+				Message: fmt.Sprintf("%s's next appearance will be their %s", g.Name, ordinal(next)),
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Date != "" && (out[j].Date == "" || out[i].Date < out[j].Date)
+	})
+	log.Printf("Found %d milestones through %s", len(out), horizon.Format("2006-01-02"))
+
+	if *doJSON {
+		if out == nil {
+			out = []*milestone{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			log.Fatalf("Writing output: %v", err)
+		}
+	} else {
+		for _, m := range out {
+			fmt.Println(m)
+		}
+	}
+	if *webhookURL == "" && len(out) != 0 {
+		*webhookURL = ilof.WebhookURL.Get()
+	}
+	if *webhookURL != "" && len(out) != 0 {
+		var lines []string
+		for _, m := range out {
+			lines = append(lines, m.String())
+		}
+		if err := ilof.Notify(context.Background(), *webhookURL, "Upcoming milestones:\n"+strings.Join(lines, "\n"), out); err != nil {
+			log.Fatalf("Sending notification: %v", err)
+		}
+	}
+}
+
+func (m *milestone) String() string {
+	if m.Date == "" {
+		return fmt.Sprintf("[%s] %s", m.Kind, m.Message)
+	}
+	return fmt.Sprintf("%s [%s] %s", m.Date, m.Kind, m.Message)
+}
+
+func parseCounts(s string) (map[int]bool, error) {
+	out := make(map[int]bool)
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid count %q", f)
+		}
+		out[n] = true
+	}
+	return out, nil
+}
+
+// ordinal returns the English ordinal form of n, e.g., "1st", "22nd".
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 10 {
+	case 1:
+		suffix = "st"
+	case 2:
+		suffix = "nd"
+	case 3:
+		suffix = "rd"
+	}
+	if n%100 >= 11 && n%100 <= 13 {
+		suffix = "th"
+	}
+	return strconv.Itoa(n) + suffix
+}
@@ -0,0 +1,189 @@
+// Program missing reports which important fields are empty for each episode
+// in the site repository, as a guide to prioritizing backfill work.
+//
+// The report begins with a summary of the coverage of each field over the
+// selected episodes. It then lists the episodes with missing fields, grouped
+// either by episode (most incomplete first) or by field.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	fieldList  = flag.String("fields", "summary,audio,youtube,guests,tags", "Comma-separated fields to check (see -help)")
+	groupBy    = flag.String("group", "episode", `Group the report by "episode" or "field"`)
+	seasonFlag = flag.Int("season", 0, "Only report episodes in this season")
+	doSpecials = flag.Bool("specials", false, "Include special episodes")
+	doJSON     = flag.Bool("json", false, "Write the report as JSON")
+)
+
+// checks maps field names to functions reporting whether that field is empty
+// for an episode.
+var checks = map[string]func(*ilof.Episode) bool{
+	"summary":    func(ep *ilof.Episode) bool { return ep.Summary == "" },
+	"topics":     func(ep *ilof.Episode) bool { return ep.Topics == "" },
+	"audio":      func(ep *ilof.Episode) bool { return ep.AcastURL == "" && ep.AudioFileURL == "" },
+	"youtube":    func(ep *ilof.Episode) bool { return ep.YouTubeURL == "" },
+	"crowdcast":  func(ep *ilof.Episode) bool { return ep.CrowdcastURL == "" },
+	"guests":     func(ep *ilof.Episode) bool { return len(ep.Guests) == 0 },
+	"tags":       func(ep *ilof.Episode) bool { return len(ep.AllTags()) == 0 },
+	"duration":   func(ep *ilof.Episode) bool { return ep.Duration == "" },
+	"transcript": func(ep *ilof.Episode) bool { return ep.Transcript == "" },
+	"links":      func(ep *ilof.Episode) bool { return len(ep.Links) == 0 },
+}
+
+func init() {
+	flag.Usage = func() {
+		var names []string
+		for name := range checks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, `Usage: %s [options]
+
+Report episodes with missing fields. The fields that can be checked are:
+  %s
+
+Options:
+`, filepath.Base(os.Args[0]), strings.Join(names, ", "))
+		flag.PrintDefaults()
+	}
+}
+
+// An entry reports the missing fields of one episode.
+type entry struct {
+	Episode ilof.Label `json:"episode"`
+	Date    string     `json:"airDate"`
+	Path    string     `json:"path"`
+	Missing []string   `json:"missing"`
+}
+
+// A coverage reports how many of the selected episodes have a field.
+type coverage struct {
+	Field   string  `json:"field"`
+	Present int     `json:"present"`
+	Missing int     `json:"missing"`
+	Percent float64 `json:"percent"` // present, as a percentage
+}
+
+func main() {
+	flag.Parse()
+	fields := strings.Split(*fieldList, ",")
+	for _, f := range fields {
+		if checks[f] == nil {
+			log.Fatalf("Unknown field %q (see -help)", f)
+		}
+	}
+	if *groupBy != "episode" && *groupBy != "field" {
+		log.Fatalf("Unknown -group %q", *groupBy)
+	}
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	gidx := ilof.GuestIndex(guests)
+
+	var total int
+	var entries []*entry
+	missing := make(map[string]int)
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		if ep.Special && !*doSpecials {
+			return nil
+		} else if *seasonFlag > 0 && ep.Season != *seasonFlag {
+			return nil
+		}
+		for _, g := range gidx[ep.Episode] {
+			ep.Guests = append(ep.Guests, g.Name)
+		}
+		total++
+		e := &entry{Episode: ep.Episode, Date: ep.Date.String(), Path: path}
+		for _, f := range fields {
+			if checks[f](ep) {
+				e.Missing = append(e.Missing, f)
+				missing[f]++
+			}
+		}
+		if len(e.Missing) != 0 {
+			entries = append(entries, e)
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+
+	// Most incomplete first, then most recent first.
+	sort.Slice(entries, func(i, j int) bool {
+		if len(entries[i].Missing) != len(entries[j].Missing) {
+			return len(entries[i].Missing) > len(entries[j].Missing)
+		}
+		return entries[i].Date > entries[j].Date
+	})
+	var cov []*coverage
+	for _, f := range fields {
+		c := &coverage{Field: f, Missing: missing[f], Present: total - missing[f]}
+		if total > 0 {
+			c.Percent = 100 * float64(c.Present) / float64(total)
+		}
+		cov = append(cov, c)
+	}
+
+	if *doJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			Generated time.Time   `json:"generated"`
+			Episodes  int         `json:"episodes"`
+			Coverage  []*coverage `json:"coverage"`
+			Entries   []*entry    `json:"entries"`
+		}{
+			Generated: time.Now().UTC(),
+			Episodes:  total,
+			Coverage:  cov,
+			Entries:   entries,
+		}); err != nil {
+			log.Fatalf("Writing output: %v", err)
+		}
+		return
+	}
+
+	fmt.Printf("Coverage over %d episodes:\n", total)
+	for _, c := range cov {
+		fmt.Printf("  %-12s %5d present  %5d missing  %5.1f%%\n", c.Field, c.Present, c.Missing, c.Percent)
+	}
+	fmt.Println()
+	if *groupBy == "field" {
+		for _, f := range fields {
+			if missing[f] == 0 {
+				continue
+			}
+			fmt.Printf("Missing %s (%d):\n", f, missing[f])
+			for _, e := range entries {
+				for _, m := range e.Missing {
+					if m == f {
+						fmt.Printf("  %-6s %s  %s\n", e.Episode, e.Date, e.Path)
+					}
+				}
+			}
+			fmt.Println()
+		}
+		return
+	}
+	for _, e := range entries {
+		fmt.Printf("%-6s %s  %s\n", e.Episode, e.Date, strings.Join(e.Missing, ", "))
+	}
+}
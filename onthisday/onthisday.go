@@ -0,0 +1,94 @@
+// Program onthisday writes an "on this day" data file for the site, mapping
+// each calendar day to the episodes that aired on that day, so that the site
+// or a bot can post daily throwbacks.
+//
+// The output is a JSON object whose keys are calendar days formatted as
+// "MM-DD", e.g., "01-05". The value for each day is a list of the episodes
+// that aired on that day in any year, in order of air date, giving the label,
+// date, year, page URL, topics, summary, guests, and links of each. To show
+// throwbacks, select the episodes whose year is before the current one, e.g.,
+// site.data.onthisday["01-05"] filtered by year.
+//
+// With -on, the throwbacks for the given date (the episodes that aired on
+// the same day in earlier years) are printed as JSON instead, and no file is
+// written.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath  = flag.String("o", "_data/onthisday.json", "Write the data file to this path")
+	doDryRun = flag.Bool("dry-run", false, "Print the data file to stdout instead of writing it")
+	onDate   = flag.String("on", "", "Print the throwbacks for this date (YYYY-MM-DD) instead")
+)
+
+func main() {
+	flag.Parse()
+	var on ilof.Date
+	if *onDate != "" {
+		if err := on.UnmarshalText([]byte(*onDate)); err != nil {
+			log.Fatalf("Invalid -on date: %v", err)
+		}
+	}
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	gidx := ilof.GuestIndex(guests)
+
+	var eps []*ilof.Episode
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		for _, g := range gidx[ep.Episode] {
+			ep.Guests = append(ep.Guests, g.Name)
+		}
+		eps = append(eps, ep)
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	days := ilof.OnThisDay(eps)
+
+	if !on.IsZero() {
+		tbs := ilof.Throwbacks(days, on)
+		if tbs == nil {
+			tbs = []*ilof.Throwback{}
+		}
+		log.Printf("Found %d throwbacks for %s", len(tbs), on)
+		os.Stdout.Write(encode(tbs))
+		return
+	}
+	log.Printf("Found episodes on %d calendar days", len(days))
+
+	data := encode(days)
+	if *doDryRun {
+		os.Stdout.Write(data)
+	} else if err := atomicfile.WriteData(*outPath, data, 0644); err != nil {
+		log.Fatalf("Writing data file: %v", err)
+	} else if err := ilof.RecordGenerated(repo.ManifestFile, "onthisday", *outPath); err != nil {
+		log.Fatalf("Updating manifest: %v", err)
+	}
+}
+
+func encode(v any) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		log.Fatalf("Encoding output: %v", err)
+	}
+	return buf.Bytes()
+}
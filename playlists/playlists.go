@@ -0,0 +1,222 @@
+// Program playlists keeps the YouTube playlists of the show in sync with the
+// episode catalog: a playlist for each season, and one for the full archive.
+//
+// The playlist of each season is given by the "playlist" field of its entry
+// in the season list, for example:
+//
+//	# _data/seasons.yaml
+//	- season: 2
+//	  start: 2021-01-01
+//	  playlist: PLxxxxxxxxxxxxxxxx
+//
+// The full-archive playlist is given by -archive. Videos of episodes that are
+// not yet in a playlist are added at the end, in order of air date. Videos in
+// a playlist that are not the video of any episode are reported, but are not
+// removed. With -dry-run, the changes are reported but not made.
+//
+// Modifying a playlist requires OAuth credentials for the account that owns
+// the channel: YOUTUBE_CLIENT_ID and YOUTUBE_CLIENT_SECRET for a desktop OAuth
+// client, and YOUTUBE_REFRESH_TOKEN (run "doctor" to see where credentials
+// are sought). To obtain a refresh token, run
+//
+//	playlists -authorize
+//
+// and follow the link it prints, signed in as the owner of the channel.
+//
+// A dry run only reads the playlists, so if the OAuth credentials are not
+// set, it uses YOUTUBE_API_KEY instead. In that case, private playlists
+// cannot be read.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	archiveID   = flag.String("archive", os.Getenv("YOUTUBE_ARCHIVE_PLAYLIST"), "YouTube playlist ID for the full archive")
+	seasonNum   = flag.Int("season", 0, "Sync only the playlist of this season (and not the archive)")
+	doDryRun    = flag.Bool("dry-run", false, "Report changes without making them")
+	doAuthorize = flag.Bool("authorize", false, "Obtain an OAuth refresh token for the channel and exit")
+)
+
+func main() {
+	flag.Parse()
+	ctx := context.Background()
+	if *doAuthorize {
+		if err := authorize(ctx); err != nil {
+			log.Fatalf("Authorizing: %v", err)
+		}
+		return
+	}
+
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	seasons, err := ilof.LoadSeasons(repo.SeasonFile)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Loading seasons: %v", err)
+	}
+	var eps []*ilof.Episode
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		eps = append(eps, ep)
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	vc := ilof.NewVideoCatalog(eps, seasons)
+	log.Printf("Found %d episode videos", len(vc.All))
+
+	type target struct {
+		name, id string
+		want     []string
+	}
+	var targets []target
+	for _, s := range seasons {
+		if *seasonNum != 0 && s.Season != *seasonNum {
+			continue
+		} else if s.Playlist == "" {
+			log.Printf("- Season %d has no playlist; skipping", s.Season)
+			continue
+		}
+		targets = append(targets, target{fmt.Sprintf("Season %d", s.Season), s.Playlist, vc.BySeason[s.Season]})
+	}
+	if *seasonNum == 0 {
+		if *archiveID != "" {
+			targets = append(targets, target{"Archive", *archiveID, vc.All})
+		} else {
+			log.Print("No -archive specified; skipping the full archive")
+		}
+	}
+	if len(targets) == 0 {
+		log.Fatal("No playlists to sync")
+	}
+
+	yt, err := connect(ctx)
+	if err != nil {
+		log.Fatalf("Connecting to YouTube: %v", err)
+	}
+
+	var numAdded, numUnknown int
+	for _, t := range targets {
+		items, err := yt.PlaylistItems(ctx, t.id)
+		if err != nil {
+			log.Fatalf("Loading %s playlist: %v", t.name, err)
+		}
+		plan := vc.PlanPlaylist(t.id, t.want, items)
+		log.Printf("%s playlist (%s): %d videos, %d to add", t.name, t.id, len(items), len(plan.Add))
+		for _, item := range plan.Unknown {
+			fmt.Printf("%s: %s %q is not an episode video\n", t.name, item.URL(), item.Title)
+			numUnknown++
+		}
+		for _, id := range plan.Add {
+			fmt.Printf("%s: add episode %s (%s)\n", t.name, vc.Episodes[id], id)
+			if *doDryRun {
+				continue
+			}
+			if err := yt.AddPlaylistItem(ctx, t.id, id); err != nil {
+				log.Fatalf("Adding %s to %s playlist: %v", id, t.name, err)
+			}
+			numAdded++
+		}
+	}
+	log.Printf("Added %d videos; %d playlist videos are not in the catalog", numAdded, numUnknown)
+}
+
+// connect returns a YouTube client authorized by the OAuth credentials. For a
+// dry run, if the OAuth credentials are not set, it falls back to a read-only
+// client authorized by the API key.
+func connect(ctx context.Context) (*ilof.YouTubeClient, error) {
+	clientID, secret, refresh, err := oauthCredentials()
+	if err == nil {
+		return ilof.NewYouTubeClient(ctx, clientID, secret, refresh)
+	} else if !*doDryRun {
+		return nil, err
+	}
+	key := ilof.YouTubeAPIKey.Get()
+	if key == "" {
+		return nil, err
+	}
+	log.Print("- No OAuth credentials are set; reading playlists with the API key")
+	return ilof.NewYouTubeReader(key), nil
+}
+
+// oauthCredentials returns the OAuth client credentials and refresh token for
+// the channel.
+func oauthCredentials() (clientID, secret, refresh string, err error) {
+	if clientID, err = ilof.YouTubeClientID.Value(); err != nil {
+		return
+	} else if secret, err = ilof.YouTubeClientSecret.Value(); err != nil {
+		return
+	}
+	refresh, err = ilof.YouTubeRefreshToken.Value()
+	return
+}
+
+// authorize runs the OAuth flow for an installed application: it serves the
+// redirect on a loopback address, prints the authorization link, and prints
+// the refresh token obtained once the user grants access. The request is
+// protected by a random state and a PKCE code verifier.
+func authorize(ctx context.Context) error {
+	clientID, err := ilof.YouTubeClientID.Value()
+	if err != nil {
+		return err
+	}
+	secret, err := ilof.YouTubeClientSecret.Value()
+	if err != nil {
+		return err
+	}
+	state, err := ilof.NewOAuthSecret()
+	if err != nil {
+		return err
+	}
+	verifier, err := ilof.NewOAuthSecret()
+	if err != nil {
+		return err
+	}
+
+	lst, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer lst.Close()
+	redirect := fmt.Sprintf("http://%s/", lst.Addr())
+
+	codec := make(chan string, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("state") != state {
+			http.Error(w, "authorization failed: state mismatch", http.StatusBadRequest)
+			return
+		}
+		code := r.FormValue("code")
+		if code == "" {
+			http.Error(w, "authorization failed: "+r.FormValue("error"), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "Authorized; you may close this window.")
+		select {
+		case codec <- code:
+		default:
+		}
+	})}
+	go srv.Serve(lst)
+	defer srv.Close()
+
+	fmt.Fprintf(os.Stderr, "Visit this link as the owner of the channel:\n\n  %s\n\n", ilof.YouTubeAuthURL(clientID, redirect, state, verifier))
+	code := <-codec
+	token, err := ilof.YouTubeAuthorize(ctx, clientID, secret, code, redirect, verifier)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Store this refresh token as the YOUTUBE_REFRESH_TOKEN credential:")
+	fmt.Printf("%s=%s\n", ilof.YouTubeRefreshToken.Name, token)
+	return nil
+}
@@ -0,0 +1,183 @@
+// Program podtranscripts exports the stored transcripts of the episodes in
+// the site repository in the formats that podcast apps render natively, as
+// advertised by the podcast:transcript element of the Podcasting 2.0
+// namespace.
+//
+// For each episode whose transcript is stored as JSON (as written by fytt),
+// two files are written beside it: <base>.podcast.json, in the Podcasting 2.0
+// JSON transcript format, and <base>.srt, in SubRip format. For example, the
+// transcript transcripts/0100-abc.json is exported as
+// transcripts/0100-abc.podcast.json and transcripts/0100-abc.srt.
+//
+// If the episode has chapters, they are exported beside the transcript as
+// well: <base>.chapters.json, in the Podcasting 2.0 JSON chapters format, and
+// <base>.chapters.vtt, as a WebVTT chapters track. An episode without
+// chapters of its own gets chapters for its segments whose start times are
+// recorded, titled from the tag registry. The transcript feed (see
+// transcriptfeed) advertises the exported files.
+//
+// Only files whose contents have changed are rewritten. Chapter files that
+// podtranscripts wrote earlier, according to the manifest, but that are no
+// longer exported (because the episode lost its chapters) are removed. With
+// -check, nothing is written or removed, and the exit status is 3 if any
+// file is out of date.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	doSRT      = flag.Bool("srt", true, "Also export transcripts in SubRip format")
+	doChapters = flag.Bool("chapters", true, "Also export the chapters of episodes that have them")
+	doCheck    = flag.Bool("check", false, "Report out-of-date files without writing them")
+	doDryRun   = flag.Bool("dry-run", false, "Report the files that would be written without writing them")
+)
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	reg, err := ilof.LoadTagRegistry(repo.TagFile)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Loading tag registry: %v", err)
+	}
+
+	var numTranscripts int
+	var stale []string
+	files := make(map[string][]byte)
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		src := ep.TranscriptFile()
+		if src == "" || strings.HasSuffix(src, ".podcast.json") {
+			return nil
+		}
+		t, err := ilof.LoadTranscript(src)
+		if err != nil {
+			log.Printf("* Episode %s: %v", ep.Episode, err)
+			return nil
+		}
+		numTranscripts++
+
+		jsonPath, srtPath := ilof.PodcastTranscriptPaths(src)
+		var buf bytes.Buffer
+		if err := t.WritePodcastJSON(&buf); err != nil {
+			return err
+		}
+		files[jsonPath] = bytes.Clone(buf.Bytes())
+		if *doSRT {
+			buf.Reset()
+			if err := t.WriteSRT(&buf); err != nil {
+				return err
+			}
+			files[srtPath] = bytes.Clone(buf.Bytes())
+		}
+		chapJSON, chapVTT := ilof.PodcastChapterPaths(src)
+		chapters := ep.Chapters
+		if len(chapters) == 0 {
+			chapters = ilof.SegmentChapters(ep, reg)
+		}
+		if *doChapters && len(chapters) != 0 {
+			buf.Reset()
+			if err := ilof.WritePodcastChapters(&buf, chapters); err != nil {
+				return err
+			}
+			files[chapJSON] = bytes.Clone(buf.Bytes())
+			buf.Reset()
+			if err := ilof.WriteChaptersVTT(&buf, chapters, ilof.EpisodeEnd(ep, t)); err != nil {
+				return err
+			}
+			files[chapVTT] = bytes.Clone(buf.Bytes())
+		}
+		for _, p := range []string{jsonPath, srtPath, chapJSON, chapVTT} {
+			if data, ok := files[p]; ok {
+				if old, err := os.ReadFile(p); err != nil || !bytes.Equal(old, data) {
+					stale = append(stale, p)
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("Exporting transcripts: %v", err)
+	}
+	log.Printf("Found %d stored transcripts", numTranscripts)
+
+	var remove []string
+	if *doChapters {
+		remove, err = staleChapters(files)
+		if err != nil {
+			log.Fatalf("Reading manifest: %v", err)
+		}
+	}
+
+	switch {
+	case *doDryRun:
+		for _, p := range stale {
+			log.Printf("Would write %s", p)
+		}
+		for _, p := range remove {
+			log.Printf("Would remove %s", p)
+		}
+		return
+	case *doCheck:
+		for _, p := range stale {
+			log.Printf("Out of date: %s", p)
+		}
+		for _, p := range remove {
+			log.Printf("No longer exported: %s", p)
+		}
+		if n := len(stale) + len(remove); n != 0 {
+			log.Printf("%d transcript files need to be exported or removed", n)
+			os.Exit(3)
+		}
+		log.Printf("All %d transcript files are up to date", len(files))
+		return
+	}
+	for _, p := range stale {
+		if err := atomicfile.WriteData(p, files[p], 0644); err != nil {
+			log.Fatalf("Writing transcript: %v", err)
+		}
+	}
+	for _, p := range remove {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Removing chapters: %v", err)
+		}
+	}
+	if err := ilof.RecordGenerated(repo.ManifestFile, "podtranscripts", stale...); err != nil {
+		log.Fatalf("Updating manifest: %v", err)
+	} else if err := ilof.ForgetGenerated(repo.ManifestFile, remove...); err != nil {
+		log.Fatalf("Updating manifest: %v", err)
+	}
+	log.Printf("Wrote %d of %d transcript files, removed %d", len(stale), len(files), len(remove))
+}
+
+// staleChapters returns the paths of the chapter files recorded in the
+// manifest as written by podtranscripts that are not among the files to be
+// exported. Other files are never removed, so that hand-written chapters are
+// safe.
+func staleChapters(files map[string][]byte) ([]string, error) {
+	m, err := ilof.LoadManifest(repo.ManifestFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, p := range m.Generated("podtranscripts") {
+		if _, ok := files[p]; ok {
+			continue
+		} else if strings.HasSuffix(p, ".chapters.json") || strings.HasSuffix(p, ".chapters.vtt") {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,93 @@
+// Program quotes proposes notable quotations from the stored transcripts of
+// episodes, for use in social posts and on episode pages.
+//
+// Candidates are written as JSON, grouped by episode, with the time of each
+// quotation in the episode and, where the episode has a YouTube video, a link
+// to that point in the video:
+//
+//	quotes -episode 100 -n 5
+//
+// Candidates are chosen by heuristics and should be reviewed before use.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath   = flag.String("o", "", "Write candidates to this file (default stdout)")
+	maxCount  = flag.Int("n", 5, "Maximum number of candidates per episode (0 for all)")
+	labelFlag = flag.String("episode", "", "Propose quotes only for this episode")
+	minWords  = flag.Int("min-words", 8, "Minimum length of a quote in words")
+	maxWords  = flag.Int("max-words", 40, "Maximum length of a quote in words")
+)
+
+// A candidate is a proposed quotation from an episode.
+type candidate struct {
+	*ilof.Quote
+	URL string `json:"url,omitempty"` // link to the quote in the video
+}
+
+// An episodeQuotes records the candidate quotations for an episode.
+type episodeQuotes struct {
+	Episode ilof.Label   `json:"episode"`
+	Quotes  []*candidate `json:"quotes"`
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	opts := &ilof.QuoteOptions{MinWords: *minWords, MaxWords: *maxWords}
+	out := []*episodeQuotes{}
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		if *labelFlag != "" && string(ep.Episode) != *labelFlag {
+			return nil
+		}
+		t, err := ilof.LoadEpisodeTranscript(ep)
+		if err != nil {
+			log.Printf("* Episode %s: %v", ep.Episode, err)
+			return nil
+		} else if t == nil {
+			return nil
+		}
+		qs := t.Quotes(*maxCount, opts)
+		if len(qs) == 0 {
+			return nil
+		}
+		eq := &episodeQuotes{Episode: ep.Episode}
+		id, hasVideo := ilof.YouTubeVideoID(ep.YouTubeURL)
+		for _, q := range qs {
+			c := &candidate{Quote: q}
+			if hasVideo {
+				c.URL = ilof.VideoDeepLink(id, q.Start)
+			}
+			eq.Quotes = append(eq.Quotes, c)
+		}
+		out = append(out, eq)
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	log.Printf("Found candidates for %d episodes", len(out))
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		log.Fatalf("Encoding candidates: %v", err)
+	}
+	data = append(data, '\n')
+	if *outPath == "" {
+		os.Stdout.Write(data)
+	} else if err := atomicfile.WriteData(*outPath, data, 0644); err != nil {
+		log.Fatalf("Writing candidates: %v", err)
+	}
+}
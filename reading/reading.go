@@ -0,0 +1,73 @@
+// Program reading extracts the books and articles linked from the episodes in
+// the site repository, and writes a reading list data file for the site.
+//
+// Links are recognized by their hosts: bookstores and publishers for books,
+// and news sites and blogs for articles. Book links are normalized, so that
+// the same book linked from several episodes appears once.
+//
+// The output is a JSON object with two fields: "episodes" maps each episode
+// label to the reading list for that episode, and "catalog" lists every item
+// with the episodes that mention it. The site templates can render them,
+// e.g., as site.data.reading.episodes[page.episode].
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath  = flag.String("o", "_data/reading.json", "Write the reading list to this file")
+	doDryRun = flag.Bool("dry-run", false, "Print the reading list to stdout instead of writing it")
+)
+
+type readingList struct {
+	Episodes map[ilof.Label][]*ilof.ReadingItem `json:"episodes"`
+	Catalog  []*ilof.ReadingItem                `json:"catalog"`
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	out := &readingList{Episodes: make(map[ilof.Label][]*ilof.ReadingItem)}
+	var lists [][]*ilof.ReadingItem
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		if items := ilof.EpisodeReading(ep); len(items) != 0 {
+			out.Episodes[ep.Episode] = items
+			lists = append(lists, items)
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	out.Catalog = ilof.MergeReading(lists...)
+	if out.Catalog == nil {
+		out.Catalog = []*ilof.ReadingItem{}
+	}
+	log.Printf("Found %d items in %d episodes", len(out.Catalog), len(out.Episodes))
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(out); err != nil {
+		log.Fatalf("Encoding reading list: %v", err)
+	}
+	if *doDryRun {
+		os.Stdout.Write(buf.Bytes())
+	} else if err := atomicfile.WriteData(*outPath, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("Writing reading list: %v", err)
+	} else if err := ilof.RecordGenerated(repo.ManifestFile, "reading", *outPath); err != nil {
+		log.Fatalf("Updating manifest: %v", err)
+	}
+}
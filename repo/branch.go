@@ -0,0 +1,122 @@
+package repo
+
+import (
+	"errors"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// BranchExists reports whether a local branch with the given name exists.
+func BranchExists(name string) (bool, error) {
+	r, err := open()
+	if err != nil {
+		_, ferr := fallback(err, func() (string, error) {
+			return execGit("show-ref", "--verify", "--quiet", "refs/heads/"+name)
+		})
+
+		// The command exits with status 1 if the branch does not exist, and
+		// with other statuses if it cannot read the repository.
+		var xerr *exec.ExitError
+		if errors.As(ferr, &xerr) && xerr.ExitCode() == 1 {
+			return false, nil
+		}
+		return ferr == nil, ferr
+	}
+	_, err = r.Reference(plumbing.NewBranchReferenceName(name), false)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Checkout switches the working tree to the existing branch with the given
+// name. It fails if the working tree has uncommitted changes to tracked
+// files.
+func Checkout(name string) error {
+	r, err := open()
+	if err != nil {
+		return fallbackErr(err, "checkout", name)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(name),
+	})
+}
+
+// DeleteBranch deletes the local branch with the given name. The branch must
+// not be checked out.
+func DeleteBranch(name string) error {
+	r, err := open()
+	if err != nil {
+		return fallbackErr(err, "branch", "-D", name)
+	}
+	ref := plumbing.NewBranchReferenceName(name)
+	if head, err := r.Head(); err == nil && head.Name() == ref {
+		return errors.New("cannot delete the current branch")
+	}
+	if _, err := r.Reference(ref, false); err != nil {
+		return err
+	}
+	return r.Storer.RemoveReference(ref)
+}
+
+// MergedBranches returns the names of the local branches with the given name
+// prefix whose heads are contained in the history of the current HEAD, in
+// sorted order. The current branch is not included.
+func MergedBranches(prefix string) ([]string, error) {
+	r, err := open()
+	if err != nil {
+		out, ferr := fallback(err, func() (string, error) {
+			return execGit("branch", "--merged", "HEAD", "--format=%(refname:short)", "--list", prefix+"*")
+		})
+		if ferr != nil {
+			return nil, ferr
+		}
+		cur, _ := execGit("symbolic-ref", "--short", "HEAD")
+		var names []string
+		for _, name := range strings.Split(out, "\n") {
+			if name != "" && name != cur {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	hc, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	iter, err := r.Branches()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		if !strings.HasPrefix(name, prefix) || ref.Name() == head.Name() {
+			return nil
+		}
+		c, err := r.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		if ok, err := c.IsAncestor(hc); err != nil {
+			return err
+		} else if ok || c.Hash == hc.Hash {
+			names = append(names, name)
+		}
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
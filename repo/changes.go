@@ -0,0 +1,170 @@
+package repo
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ChangedSince returns the paths of the episode and guest files that differ
+// from their contents at ref, which may name a commit, branch, or tag. Changes
+// committed since ref and uncommitted changes in the working tree, including
+// new files, are both reported. Paths are relative to the repository root, in
+// sorted order.
+func ChangedSince(ref string) ([]string, error) {
+	r, err := open()
+	if err != nil {
+		return fallbackList(err, ref)
+	}
+	paths, err := changedSince(r, ref)
+	if err != nil {
+		return nil, err
+	}
+	return filterData(paths), nil
+}
+
+func changedSince(r *git.Repository, ref string) ([]string, error) {
+	h, err := r.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("resolving %q: %w", ref, err)
+	}
+	base, err := commitTree(r, *h)
+	if err != nil {
+		return nil, err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	cur, err := commitTree(r, head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	diffs, err := object.DiffTree(base, cur)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, d := range diffs {
+		if d.To.Name != "" {
+			paths = append(paths, d.To.Name)
+		}
+		if d.From.Name != "" && d.From.Name != d.To.Name {
+			paths = append(paths, d.From.Name)
+		}
+	}
+
+	wt, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	st, err := wt.Status()
+	if err != nil {
+		return nil, err
+	}
+	for file, fs := range st {
+		if fs.Staging != git.Unmodified || fs.Worktree != git.Unmodified {
+			paths = append(paths, file)
+		}
+	}
+	return paths, nil
+}
+
+func commitTree(r *git.Repository, h plumbing.Hash) (*object.Tree, error) {
+	c, err := r.CommitObject(h)
+	if err != nil {
+		return nil, err
+	}
+	return c.Tree()
+}
+
+// fallbackList implements ChangedSince using the git binary, if one is
+// available, and otherwise returns err.
+func fallbackList(err error, ref string) ([]string, error) {
+	var paths []string
+	_, ferr := fallback(err, func() (string, error) {
+		for _, args := range [][]string{
+			{"diff", "--name-only", ref, "--"},
+			{"ls-files", "--others", "--exclude-standard"},
+		} {
+			out, err := execGit(args...)
+			if err != nil {
+				return "", err
+			}
+			for _, line := range strings.Split(out, "\n") {
+				if line != "" {
+					paths = append(paths, line)
+				}
+			}
+		}
+		return "", nil
+	})
+	if ferr != nil {
+		return nil, ferr
+	}
+	return filterData(paths), nil
+}
+
+// filterData returns the sorted, unique paths that are episode files or
+// guest files.
+func filterData(paths []string) []string {
+	epDir := filepath.ToSlash(filepath.Clean(EpisodeDir)) + "/"
+	seen := make(map[string]bool)
+	var out []string
+	for _, p := range paths {
+		p = path.Clean(filepath.ToSlash(p))
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if IsGuestPath(filepath.FromSlash(p)) || (strings.HasPrefix(p, epDir) && path.Ext(p) == ".md") {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// IsClean reports whether the specified paths have no uncommitted changes,
+// either staged or in the working tree. An untracked file is not clean. A
+// path may name a directory, which is clean if all the files in it are. If
+// no paths are given, IsClean reports whether the whole working tree is
+// clean.
+func IsClean(paths ...string) (bool, error) {
+	r, err := open()
+	if err != nil {
+		out, ferr := fallback(err, func() (string, error) {
+			return execGit(append([]string{"status", "--porcelain", "--"}, paths...)...)
+		})
+		return out == "" && ferr == nil, ferr
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return false, err
+	}
+	st, err := wt.Status()
+	if err != nil {
+		return false, err
+	}
+	if len(paths) == 0 {
+		return st.IsClean(), nil
+	}
+	for _, p := range paths {
+		p = filepath.ToSlash(filepath.Clean(p))
+		for file, fs := range st {
+			if file != p && !strings.HasPrefix(file, p+"/") {
+				continue
+			}
+			if fs.Staging != git.Unmodified || fs.Worktree != git.Unmodified {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// fallback calls f if a git binary is available, and otherwise returns err,
+// the error from the go-git implementation of an operation.
+func fallback(err error, f func() (string, error)) (string, error) {
+	if _, lerr := exec.LookPath("git"); lerr != nil {
+		return "", err
+	}
+	return f()
+}
+
+// fallbackRun calls f if a git binary is available, and otherwise returns
+// err, the error from the go-git implementation of an operation.
+func fallbackRun(err error, f func() error) error {
+	_, ferr := fallback(err, func() (string, error) { return "", f() })
+	return ferr
+}
+
+// fallbackErr runs git with args if a git binary is available, and otherwise
+// returns err, the error from the go-git implementation of an operation.
+func fallbackErr(err error, args ...string) error {
+	_, ferr := fallback(err, func() (string, error) { return execGit(args...) })
+	return ferr
+}
+
+// execGit runs a git subcommand with the given arguments in the current
+// working directory, and returns its output with surrounding whitespace
+// removed.
+func execGit(args ...string) (string, error) { return execGitIn("", args...) }
+
+// execGitIn is as execGit, but runs git in dir. If dir == "", it uses the
+// current working directory.
+func execGitIn(dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
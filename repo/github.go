@@ -0,0 +1,262 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// GitHub is a client for the GitHub REST API, for a single repository.
+type GitHub struct {
+	Repo    string // "owner/name"
+	Token   string // a personal access or app token
+	BaseURL string // if empty, https://api.github.com
+
+	// If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+var githubRemote = regexp.MustCompile(`github\.com[:/]([\w.-]+/[\w.-]+?)(?:\.git)?/?$`)
+
+// GitHubRepo returns the "owner/name" of the GitHub repository corresponding
+// to the given git remote.
+func GitHubRepo(remote string) (string, error) {
+	u, err := remoteURL(remote)
+	if err != nil {
+		return "", err
+	}
+	m := githubRemote.FindStringSubmatch(u)
+	if m == nil {
+		return "", fmt.Errorf("remote %q is not a GitHub repository: %q", remote, u)
+	}
+	return m[1], nil
+}
+
+// A PullRequest describes a pull request.
+type PullRequest struct {
+	Number int    `json:"number,omitempty"`
+	Title  string `json:"title"`
+	Body   string `json:"body,omitempty"`
+	Head   string `json:"head"` // the branch with the changes
+	Base   string `json:"base"` // the branch to merge into
+	Draft  bool   `json:"draft,omitempty"`
+	URL    string `json:"html_url,omitempty"`
+}
+
+// CreatePullRequest opens a pull request, and returns the resulting pull
+// request with its number and URL populated.
+func (g *GitHub) CreatePullRequest(ctx context.Context, pr *PullRequest) (*PullRequest, error) {
+	req := struct {
+		Title string `json:"title"`
+		Body  string `json:"body,omitempty"`
+		Head  string `json:"head"`
+		Base  string `json:"base"`
+		Draft bool   `json:"draft,omitempty"`
+	}{Title: pr.Title, Body: pr.Body, Head: pr.Head, Base: pr.Base, Draft: pr.Draft}
+
+	// In the response, the head and base are objects describing the branches.
+	type branchRef struct {
+		Ref string `json:"ref"`
+	}
+	var rsp struct {
+		Number int       `json:"number"`
+		Title  string    `json:"title"`
+		Body   string    `json:"body"`
+		Head   branchRef `json:"head"`
+		Base   branchRef `json:"base"`
+		Draft  bool      `json:"draft"`
+		URL    string    `json:"html_url"`
+	}
+	if err := g.call(ctx, "POST", "pulls", req, &rsp); err != nil {
+		return nil, err
+	}
+	return &PullRequest{
+		Number: rsp.Number,
+		Title:  rsp.Title,
+		Body:   rsp.Body,
+		Head:   rsp.Head.Ref,
+		Base:   rsp.Base.Ref,
+		Draft:  rsp.Draft,
+		URL:    rsp.URL,
+	}, nil
+}
+
+// An Issue describes an issue.
+type Issue struct {
+	Number int      `json:"number,omitempty"`
+	Title  string   `json:"title"`
+	Body   string   `json:"body,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+	URL    string   `json:"html_url,omitempty"`
+}
+
+// CreateIssue files an issue, and returns the resulting issue with its number
+// and URL populated.
+func (g *GitHub) CreateIssue(ctx context.Context, issue *Issue) (*Issue, error) {
+	var out struct {
+		Issue
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}
+	if err := g.call(ctx, "POST", "issues", issue, &out); err != nil {
+		return nil, err
+	}
+	for _, lb := range out.Labels {
+		out.Issue.Labels = append(out.Issue.Labels, lb.Name)
+	}
+	return &out.Issue, nil
+}
+
+// FindOpenIssue returns the open issue with exactly the given title, or nil
+// if there is none. Automation can use this to avoid filing duplicates.
+func (g *GitHub) FindOpenIssue(ctx context.Context, title string) (*Issue, error) {
+	q := fmt.Sprintf("repo:%s is:issue is:open in:title %q", g.Repo, title)
+	var rsp struct {
+		Items []*struct {
+			Issue
+			Labels []struct {
+				Name string `json:"name"`
+			} `json:"labels"`
+		} `json:"items"`
+	}
+	if err := g.do(ctx, "GET", "/search/issues?q="+url.QueryEscape(q), nil, &rsp); err != nil {
+		return nil, err
+	}
+	for _, item := range rsp.Items {
+		if item.Title == title {
+			return &item.Issue, nil
+		}
+	}
+	return nil, nil
+}
+
+// A CIStatus summarizes the checks and statuses for a commit.
+type CIStatus struct {
+	State   string   // "success", "failure", or "pending"
+	Total   int      // number of checks and statuses
+	Failing []string // names of failing checks
+	Pending []string // names of incomplete checks
+}
+
+// CIStatus reports the combined status of the check runs and commit statuses
+// for ref, which may be a branch name, tag, or commit SHA.
+func (g *GitHub) CIStatus(ctx context.Context, ref string) (*CIStatus, error) {
+	var runs struct {
+		Runs []struct {
+			Name       string `json:"name"`
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+		} `json:"check_runs"`
+	}
+	if err := g.call(ctx, "GET", "commits/"+url.PathEscape(ref)+"/check-runs", nil, &runs); err != nil {
+		return nil, err
+	}
+	var combined struct {
+		Statuses []struct {
+			Context string `json:"context"`
+			State   string `json:"state"`
+		} `json:"statuses"`
+	}
+	if err := g.call(ctx, "GET", "commits/"+url.PathEscape(ref)+"/status", nil, &combined); err != nil {
+		return nil, err
+	}
+
+	st := new(CIStatus)
+	for _, r := range runs.Runs {
+		st.Total++
+		switch {
+		case r.Status != "completed":
+			st.Pending = append(st.Pending, r.Name)
+		case r.Conclusion == "success", r.Conclusion == "neutral", r.Conclusion == "skipped":
+		default:
+			st.Failing = append(st.Failing, r.Name)
+		}
+	}
+	for _, s := range combined.Statuses {
+		st.Total++
+		switch s.State {
+		case "pending":
+			st.Pending = append(st.Pending, s.Context)
+		case "success":
+		default:
+			st.Failing = append(st.Failing, s.Context)
+		}
+	}
+	switch {
+	case len(st.Failing) != 0:
+		st.State = "failure"
+	case len(st.Pending) != 0:
+		st.State = "pending"
+	default:
+		st.State = "success"
+	}
+	return st, nil
+}
+
+// call invokes a method of the repository API. The path is relative to the
+// repository, e.g., "pulls".
+func (g *GitHub) call(ctx context.Context, method, path string, req, rsp any) error {
+	return g.do(ctx, method, "/repos/"+g.Repo+"/"+path, req, rsp)
+}
+
+// do invokes an API method at the given path, with req as the JSON request
+// body if it is non-nil, and decodes the JSON response into rsp.
+func (g *GitHub) do(ctx context.Context, method, path string, req, rsp any) error {
+	var body io.Reader
+	if req != nil {
+		bits, err := json.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		body = bytes.NewReader(bits)
+	}
+	base := g.BaseURL
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	hreq, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(base, "/")+path, body)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	hreq.Header.Set("Accept", "application/vnd.github+json")
+	hreq.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if req != nil {
+		hreq.Header.Set("Content-Type", "application/json")
+	}
+	if g.Token != "" {
+		hreq.Header.Set("Authorization", "Bearer "+g.Token)
+	}
+	cli := g.HTTPClient
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+	hrsp, err := cli.Do(hreq)
+	if err != nil {
+		return err
+	}
+	defer hrsp.Body.Close()
+	data, err := io.ReadAll(hrsp.Body)
+	if err != nil {
+		return err
+	}
+	if hrsp.StatusCode/100 != 2 {
+		var msg struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &msg) == nil && msg.Message != "" {
+			return fmt.Errorf("%s %s: %s: %s", method, path, hrsp.Status, msg.Message)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, hrsp.Status)
+	}
+	if rsp == nil {
+		return nil
+	}
+	return json.Unmarshal(data, rsp)
+}
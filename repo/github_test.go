@@ -0,0 +1,61 @@
+package repo_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/inlieuoffun/tools/repo"
+)
+
+func TestCreatePullRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/repos/ilof/site/pulls" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization: got %q", got)
+		}
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Decoding request: %v", err)
+		}
+		if req["head"] != "epdate/ep-0100" || req["base"] != "main" || req["title"] != "Add episode 100" {
+			t.Errorf("Request: got %+v", req)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{
+  "number": 17,
+  "title": "Add episode 100",
+  "html_url": "https://github.com/ilof/site/pull/17",
+  "head": {"ref": "epdate/ep-0100", "sha": "abc123"},
+  "base": {"ref": "main", "sha": "def456"},
+  "draft": false
+}`))
+	}))
+	defer srv.Close()
+
+	gh := &repo.GitHub{Repo: "ilof/site", Token: "token", BaseURL: srv.URL, HTTPClient: srv.Client()}
+	pr, err := gh.CreatePullRequest(context.Background(), &repo.PullRequest{
+		Title: "Add episode 100",
+		Head:  "epdate/ep-0100",
+		Base:  "main",
+	})
+	if err != nil {
+		t.Fatalf("CreatePullRequest failed: %v", err)
+	}
+	want := repo.PullRequest{
+		Number: 17,
+		Title:  "Add episode 100",
+		Head:   "epdate/ep-0100",
+		Base:   "main",
+		URL:    "https://github.com/ilof/site/pull/17",
+	}
+	if *pr != want {
+		t.Errorf("CreatePullRequest: got %+v, want %+v", *pr, want)
+	}
+}
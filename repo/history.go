@@ -0,0 +1,109 @@
+package repo
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// LastCommitTimes returns the time of the most recent commit that changed
+// each file under any of dirs, keyed by the path of the file relative to the
+// repository root, with forward slashes. Files that have never been
+// committed are not reported. The current directory should be the root.
+// The history is read once, however many dirs are given.
+func LastCommitTimes(dirs ...string) (map[string]time.Time, error) {
+	if len(dirs) == 0 {
+		return map[string]time.Time{}, nil
+	}
+	var prefixes []string
+	for _, dir := range dirs {
+		prefixes = append(prefixes, path.Clean(filepath.ToSlash(dir))+"/")
+	}
+	r, err := open()
+	if err != nil {
+		out, ferr := fallback(err, func() (string, error) {
+			args := append([]string{"log", "--format=%x00%cI", "--name-only", "--"}, dirs...)
+			return execGit(args...)
+		})
+		if ferr != nil {
+			return nil, ferr
+		}
+		return parseLogTimes(out, prefixes), nil
+	}
+	return lastCommitTimes(r, prefixes)
+}
+
+// hasAnyPrefix reports whether name has any of the given prefixes.
+func hasAnyPrefix(name string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func lastCommitTimes(r *git.Repository, prefixes []string) (map[string]time.Time, error) {
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	iter, err := r.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	out := make(map[string]time.Time)
+	err = iter.ForEach(func(c *object.Commit) error {
+		cur, err := c.Tree()
+		if err != nil {
+			return err
+		}
+		var prev *object.Tree
+		if c.NumParents() != 0 {
+			p, err := c.Parent(0)
+			if err != nil {
+				return err
+			}
+			if prev, err = p.Tree(); err != nil {
+				return err
+			}
+		}
+		diffs, err := object.DiffTree(prev, cur)
+		if err != nil {
+			return err
+		}
+		when := c.Committer.When
+		for _, d := range diffs {
+			name := d.To.Name
+			if hasAnyPrefix(name, prefixes) && when.After(out[name]) {
+				out[name] = when
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// parseLogTimes parses the output of git log with the format "%x00%cI" and
+// --name-only, for files with any of the given path prefixes.
+func parseLogTimes(log string, prefixes []string) map[string]time.Time {
+	out := make(map[string]time.Time)
+	for _, rec := range strings.Split(log, "\x00") {
+		lines := strings.Split(strings.TrimSpace(rec), "\n")
+		when, err := time.Parse(time.RFC3339, lines[0])
+		if err != nil {
+			continue
+		}
+		for _, name := range lines[1:] {
+			if name = strings.TrimSpace(name); hasAnyPrefix(name, prefixes) && when.After(out[name]) {
+				out[name] = when
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package repo_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+func TestLastCommitTimes(t *testing.T) {
+	dir := t.TempDir()
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	commit := func(when time.Time, files ...string) {
+		t.Helper()
+		for _, name := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(when.String()), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := wt.Add(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sig := &object.Signature{Name: "test", Email: "test@example.com", When: when}
+		if _, err := wt.Commit("update", &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit(base, "a/one.json", "b/two.json", "c/three.json")
+	commit(base.Add(time.Hour), "a/one.json")
+	commit(base.Add(2*time.Hour), "c/three.json")
+
+	defer func(old string) { repo.Dir = old }(repo.Dir)
+	repo.Dir = dir
+
+	got, err := repo.LastCommitTimes("a", "b")
+	if err != nil {
+		t.Fatalf("LastCommitTimes failed: %v", err)
+	}
+	want := map[string]time.Time{
+		"a/one.json": base.Add(time.Hour),
+		"b/two.json": base,
+	}
+	if len(got) != len(want) {
+		t.Errorf("LastCommitTimes: got %v, want %v", got, want)
+	}
+	for name, when := range want {
+		if !got[name].Equal(when) {
+			t.Errorf("LastCommitTimes %q: got %v, want %v", name, got[name], when)
+		}
+	}
+}
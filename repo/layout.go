@@ -0,0 +1,211 @@
+package repo
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/creachadair/atomicfile"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// LayoutFile is the name of the optional file at the root of the repository
+// that describes its layout. For example:
+//
+//	episodes: collections/_episodes
+//	guests: _data/people/guests.yaml
+//
+// Locations not given in the file have their default values.
+const LayoutFile = ".ilof.yaml"
+
+// A Layout gives the locations of data files in the repository, relative to
+// its root.
+type Layout struct {
+	EpisodeDir      string `yaml:"episodes,omitempty"`
+	GuestFile       string `yaml:"guests,omitempty"`
+	SeasonFile      string `yaml:"seasons,omitempty"`
+	ScheduleFile    string `yaml:"schedule,omitempty"`
+	TagRuleFile     string `yaml:"tag-rules,omitempty"`
+	TagFile         string `yaml:"tags,omitempty"`
+	AnnouncementDir string `yaml:"announcements,omitempty"`
+	ManifestFile    string `yaml:"manifest,omitempty"`
+	TranscriptDir   string `yaml:"transcripts,omitempty"`
+	AssetDir        string `yaml:"assets,omitempty"`
+}
+
+// DefaultLayout is the layout of a repository without a layout file.
+var DefaultLayout = Layout{
+	EpisodeDir:      "_episodes",
+	GuestFile:       "_data/guests.yaml",
+	SeasonFile:      "_data/seasons.yaml",
+	ScheduleFile:    "_data/schedule.yaml",
+	TagRuleFile:     "_data/tag-rules.yaml",
+	TagFile:         "_data/tags.yaml",
+	AnnouncementDir: "_data/announcements",
+	ManifestFile:    "_data/manifest.json",
+	TranscriptDir:   "transcripts",
+	AssetDir:        "assets",
+}
+
+// LoadLayout loads the layout of the repository whose root is the directory
+// root. If there is no layout file, it returns the default layout.
+func LoadLayout(root string) (*Layout, error) {
+	lo := DefaultLayout
+	data, err := os.ReadFile(filepath.Join(root, LayoutFile))
+	if os.IsNotExist(err) {
+		return &lo, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var file Layout
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", LayoutFile, err)
+	}
+	for _, f := range []struct {
+		dst *string
+		val string
+	}{
+		{&lo.EpisodeDir, file.EpisodeDir},
+		{&lo.GuestFile, file.GuestFile},
+		{&lo.SeasonFile, file.SeasonFile},
+		{&lo.ScheduleFile, file.ScheduleFile},
+		{&lo.TagRuleFile, file.TagRuleFile},
+		{&lo.TagFile, file.TagFile},
+		{&lo.AnnouncementDir, file.AnnouncementDir},
+		{&lo.ManifestFile, file.ManifestFile},
+		{&lo.TranscriptDir, file.TranscriptDir},
+		{&lo.AssetDir, file.AssetDir},
+	} {
+		if f.val == "" {
+			continue
+		} else if filepath.IsAbs(f.val) {
+			return nil, fmt.Errorf("%s: path %q must be relative to the repository root", LayoutFile, f.val)
+		}
+		*f.dst = filepath.Clean(f.val)
+	}
+	return &lo, nil
+}
+
+// SetLayoutPath sets the location named by key (e.g., "guests") to path in
+// the layout file of the repository whose root is the directory root,
+// creating the file if it does not exist. Other settings and comments in the
+// file are preserved.
+func SetLayoutPath(root, key, path string) error {
+	fpath := filepath.Join(root, LayoutFile)
+	var doc yaml.Node
+	data, err := os.ReadFile(fpath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	} else if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("decoding %s: %w", LayoutFile, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	m := doc.Content[0]
+	if m.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: layout is not a mapping", LayoutFile)
+	}
+	val := filepath.ToSlash(path)
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1].SetString(val)
+			return writeLayout(fpath, &doc)
+		}
+	}
+	m.Content = append(m.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: val},
+	)
+	return writeLayout(fpath, &doc)
+}
+
+func writeLayout(path string, doc *yaml.Node) error {
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteData(path, data, 0644)
+}
+
+// IsGuestPath reports whether path, relative to the repository root, is the
+// guest file, or one of its shards if the guest list is a directory.
+func IsGuestPath(path string) bool {
+	p, g := filepath.Clean(path), filepath.Clean(GuestFile)
+	return p == g || (filepath.Dir(p) == g && filepath.Ext(p) == ".yaml")
+}
+
+var episodeFileName = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-.*\.md$`)
+
+// IsEpisodeFileName reports whether name is the base name of an episode file.
+func IsEpisodeFileName(name string) bool { return episodeFileName.MatchString(name) }
+
+// EpisodeFileNames returns the names of the episode files in dir, in order.
+func EpisodeFileNames(dir string) ([]string, error) {
+	ls, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing episodes: %v", err)
+	}
+	var names []string
+	for _, elt := range ls {
+		if !elt.IsDir() && IsEpisodeFileName(elt.Name()) {
+			names = append(names, elt.Name())
+		}
+	}
+	return names, nil
+}
+
+// SelectFiles returns the paths of the episode files to check, and reports
+// whether to check the guest list. If args is empty, all the episode files
+// and the guest list are selected; otherwise only those named in args, which
+// are absolute paths, and any others are logged and skipped. The current
+// directory must be the repository root.
+func SelectFiles(args []string) ([]string, bool, error) {
+	if len(args) == 0 {
+		names, err := EpisodeFileNames(EpisodeDir)
+		if err != nil {
+			return nil, false, err
+		}
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join(EpisodeDir, name)
+		}
+		return paths, true, nil
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, false, err
+	}
+	var paths []string
+	var guests bool
+	for _, arg := range args {
+		path, err := filepath.Rel(root, arg)
+		if err != nil {
+			return nil, false, err
+		}
+		if IsGuestPath(path) {
+			guests = true
+		} else if filepath.Dir(path) == filepath.Clean(EpisodeDir) && IsEpisodeFileName(filepath.Base(path)) {
+			paths = append(paths, path)
+		} else {
+			log.Printf("* Skipping %s: not an episode file or the guest list", path)
+		}
+	}
+	return paths, guests, nil
+}
+
+// apply updates the package location variables from lo.
+func (lo *Layout) apply() {
+	EpisodeDir = lo.EpisodeDir
+	GuestFile = lo.GuestFile
+	SeasonFile = lo.SeasonFile
+	ScheduleFile = lo.ScheduleFile
+	TagRuleFile = lo.TagRuleFile
+	TagFile = lo.TagFile
+	AnnouncementDir = lo.AnnouncementDir
+	ManifestFile = lo.ManifestFile
+	TranscriptDir = lo.TranscriptDir
+	AssetDir = lo.AssetDir
+}
@@ -1,51 +1,304 @@
 package repo
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/filesystem"
 )
 
-const (
+// The locations of data files in the repository, relative to its root. These
+// are initialized from DefaultLayout, and updated by ChdirRoot from the
+// layout file of the repository, if it has one.
+var (
 	// The directory where episode files are stored.
-	EpisodeDir = "_episodes"
+	EpisodeDir = DefaultLayout.EpisodeDir
 
 	// The file where guest metadata are stored.
-	GuestFile = "_data/guests.yaml"
+	GuestFile = DefaultLayout.GuestFile
+
+	// The file where season start dates are stored.
+	SeasonFile = DefaultLayout.SeasonFile
+
+	// The file where the broadcast schedule is stored.
+	ScheduleFile = DefaultLayout.ScheduleFile
+
+	// The file where the rules for proposing tags are stored.
+	TagRuleFile = DefaultLayout.TagRuleFile
+
+	// The file where the titles and descriptions of tags are registered.
+	TagFile = DefaultLayout.TagFile
+
+	// The directory where archived episode announcements are stored.
+	AnnouncementDir = DefaultLayout.AnnouncementDir
+
+	// The file where the digests of generated data files are recorded.
+	ManifestFile = DefaultLayout.ManifestFile
+
+	// The directory where transcripts fetched for episodes are stored.
+	TranscriptDir = DefaultLayout.TranscriptDir
+
+	// The directory where images and other static assets are stored.
+	AssetDir = DefaultLayout.AssetDir
 )
 
+// Dir, if non-empty, is the path of the repository on which to operate, in
+// place of the repository containing the current working directory. It may
+// name any directory within the repository. It is set by the -repo flag,
+// which this package registers on the default flag set of every program that
+// imports it, or else from the environment variable named by DirEnv.
+var Dir = os.Getenv(DirEnv)
+
+// DirEnv is the name of the environment variable that sets the default for
+// the -repo flag.
+const DirEnv = "ILOF_REPO"
+
+func init() {
+	flag.StringVar(&Dir, "repo", Dir,
+		"Path of the site repository (default $"+DirEnv+", or the repository containing the working directory)")
+}
+
+// startDir returns the directory from which to find the repository.
+func startDir() string {
+	if Dir != "" {
+		return Dir
+	}
+	return "."
+}
+
+// The functions in this package use go-git to access the repository, so that
+// they do not require a git binary. If go-git cannot handle an operation (for
+// example, a push that needs credentials from a git helper), and a git binary
+// is available, they fall back to running git.
+
+// open opens the repository containing the current working directory, or
+// Dir if it is set.
+func open() (*git.Repository, error) {
+	return git.PlainOpenWithOptions(startDir(), &git.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: true,
+	})
+}
+
 // Root returns the root directory of the repository.
 func Root() (string, error) {
-	data, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	r, err := open()
+	if err != nil {
+		return fallback(err, func() (string, error) { return execGitIn(startDir(), "rev-parse", "--show-toplevel") })
+	}
+	wt, err := r.Worktree()
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(string(data)), nil
+	return wt.Filesystem.Root(), nil
 }
 
-// ChdirRoot changes the current working directory to the repository root.
+// ChdirRoot changes the current working directory to the repository root,
+// and loads the layout of the repository. If Dir is set, the root is that of
+// the repository containing Dir. Dir is then set to the absolute path of the
+// root, so that a relative Dir does not go astray after the change.
 func ChdirRoot() error {
 	root, err := Root()
 	if err != nil {
 		return err
 	}
-	return os.Chdir(root)
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	Dir = root
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	lo, err := LoadLayout(".")
+	if err != nil {
+		return err
+	}
+	lo.apply()
+	return nil
 }
 
 // RemoteRepo returns the repository name corresponding to the given git
 // remote.
 func RemoteRepo(remote string) (string, error) {
-	data, err := exec.Command("git", "remote", "get-url", remote).Output()
+	url, err := remoteURL(remote)
 	if err != nil {
 		return "", err
 	}
-	repo := filepath.Base(strings.TrimSpace(string(data)))
+	repo := filepath.Base(strings.TrimSpace(url))
 	return strings.TrimSuffix(repo, ".git"), nil
 }
 
+func remoteURL(remote string) (string, error) {
+	r, err := open()
+	if err != nil {
+		return fallback(err, func() (string, error) { return execGit("remote", "get-url", remote) })
+	}
+	rem, err := r.Remote(remote)
+	if err != nil {
+		return "", fmt.Errorf("remote %q: %w", remote, err)
+	}
+	if urls := rem.Config().URLs; len(urls) != 0 {
+		return urls[0], nil
+	}
+	return "", fmt.Errorf("remote %q has no URL", remote)
+}
+
 // FileExists reports whether the specified file path exists.
 func FileExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
+
+// CurrentBranch returns the name of the branch checked out in the current
+// working directory.
+func CurrentBranch() (string, error) {
+	r, err := open()
+	if err != nil {
+		return fallback(err, func() (string, error) { return execGit("symbolic-ref", "--short", "HEAD") })
+	}
+	head, err := r.Head()
+	if err != nil {
+		return "", err
+	} else if !head.Name().IsBranch() {
+		return "", errors.New("HEAD is not a branch")
+	}
+	return head.Name().Short(), nil
+}
+
+// CreateBranch creates a new branch with the given name at the current HEAD,
+// and checks it out. Changes in the working tree are kept.
+func CreateBranch(name string) error {
+	r, err := open()
+	if err != nil {
+		return fallbackErr(err, "checkout", "-b", name)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(name),
+		Create: true,
+		Keep:   true,
+	})
+}
+
+// Commit commits the current contents of the specified paths with the given
+// commit message. Other changes in the working tree or index are not
+// included in the commit.
+func Commit(paths []string, message string) error {
+	if len(paths) == 0 {
+		return errors.New("no paths to commit")
+	}
+	execCommit := func() error {
+		if _, err := execGit(append([]string{"add", "--"}, paths...)...); err != nil {
+			return err
+		}
+		_, err := execGit(append([]string{"commit", "-m", message, "--"}, paths...)...)
+		return err
+	}
+	r, err := open()
+	if err != nil {
+		return fallbackRun(err, execCommit)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	// A go-git commit includes everything staged in the index, so it can only
+	// be used if nothing other than the selected paths is staged.
+	st, err := wt.Status()
+	if err != nil {
+		return err
+	}
+	want := make(map[string]bool)
+	for _, p := range paths {
+		want[filepath.ToSlash(filepath.Clean(p))] = true
+	}
+	for file, fs := range st {
+		if !want[file] && fs.Staging != git.Unmodified && fs.Staging != git.Untracked {
+			return fallbackRun(fmt.Errorf("other changes are staged (%s)", file), execCommit)
+		}
+	}
+	for _, p := range paths {
+		if _, err := wt.Add(filepath.ToSlash(p)); err != nil {
+			return err
+		}
+	}
+	if _, err := wt.Commit(message, &git.CommitOptions{}); err != nil {
+		return fallbackRun(err, execCommit)
+	}
+	return nil
+}
+
+// Push pushes the specified branch to the given remote.
+func Push(remote, branch string) error {
+	r, err := open()
+	if err != nil {
+		return fallbackErr(err, "push", remote, branch)
+	}
+	ref := plumbing.NewBranchReferenceName(branch)
+	err = r.Push(&git.PushOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec(ref + ":" + ref)},
+	})
+	if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	// Pushing frequently requires credentials that only the git binary knows
+	// how to obtain, so fall back if possible.
+	return fallbackErr(err, "push", remote, branch)
+}
+
+// HooksDir returns the directory where git hooks are installed for the
+// repository, respecting the core.hooksPath setting.
+func HooksDir() (string, error) {
+	r, err := open()
+	if err != nil {
+		return fallback(err, func() (string, error) {
+			dir, err := execGit("rev-parse", "--git-path", "hooks")
+			if err != nil {
+				return "", err
+			}
+			return filepath.Abs(dir)
+		})
+	}
+	root, err := Root()
+	if err != nil {
+		return "", err
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return "", err
+	}
+	if hp := cfg.Raw.Section("core").Option("hooksPath"); hp != "" {
+		if filepath.IsAbs(hp) {
+			return hp, nil
+		}
+		return filepath.Join(root, hp), nil
+	}
+	fs, ok := r.Storer.(*filesystem.Storage)
+	if !ok {
+		return "", errors.New("repository has no git directory")
+	}
+	gitDir := fs.Filesystem().Root()
+
+	// In a linked worktree, hooks are shared from the common directory.
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		dir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(gitDir, dir)
+		}
+		gitDir = dir
+	}
+	return filepath.Join(gitDir, "hooks"), nil
+}
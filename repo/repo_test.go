@@ -0,0 +1,119 @@
+package repo_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+func TestChdirRootRelative(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "site")
+	if _, err := git.PlainInit(root, false); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(parent); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	defer func(old string) { repo.Dir = old }(repo.Dir)
+
+	// A relative Dir names the repository from the original directory, and
+	// must still name it after ChdirRoot has changed directory.
+	repo.Dir = "site"
+	if err := repo.ChdirRoot(); err != nil {
+		t.Fatalf("ChdirRoot failed: %v", err)
+	}
+	if !filepath.IsAbs(repo.Dir) {
+		t.Errorf("Dir after ChdirRoot: got %q, want an absolute path", repo.Dir)
+	}
+	got, err := repo.Root()
+	if err != nil {
+		t.Fatalf("Root after ChdirRoot failed: %v", err)
+	}
+	want, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = filepath.EvalSymlinks(got); got != want {
+		t.Errorf("Root: got %q, want %q", got, want)
+	}
+}
+
+func TestLoadLayout(t *testing.T) {
+	root := t.TempDir()
+	const file = "episodes: collections/_episodes\ntranscripts: media/transcripts/\n"
+	if err := os.WriteFile(filepath.Join(root, repo.LayoutFile), []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lo, err := repo.LoadLayout(root)
+	if err != nil {
+		t.Fatalf("LoadLayout failed: %v", err)
+	}
+	want := repo.DefaultLayout
+	want.EpisodeDir = "collections/_episodes"
+	want.TranscriptDir = "media/transcripts"
+	if *lo != want {
+		t.Errorf("LoadLayout: got %+v, want %+v", *lo, want)
+	}
+}
+
+func TestSelectFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"2020-03-25-ep1.md", "2020-03-26-ep2.md", "notes.md"} {
+		path := filepath.Join(root, repo.EpisodeDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	root, err = os.Getwd() // the temp directory may be a symlink
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ep1 := filepath.Join(repo.EpisodeDir, "2020-03-25-ep1.md")
+	ep2 := filepath.Join(repo.EpisodeDir, "2020-03-26-ep2.md")
+	tests := []struct {
+		args   []string
+		paths  []string
+		guests bool
+	}{
+		{nil, []string{ep1, ep2}, true},
+		{[]string{ep2}, []string{ep2}, false},
+		{[]string{repo.GuestFile, ep1, filepath.Join(repo.EpisodeDir, "notes.md")}, []string{ep1}, true},
+		{[]string{"README.md"}, nil, false},
+	}
+	for _, test := range tests {
+		var args []string
+		for _, arg := range test.args {
+			args = append(args, filepath.Join(root, arg))
+		}
+		paths, guests, err := repo.SelectFiles(args)
+		if err != nil {
+			t.Errorf("SelectFiles(%q) failed: %v", test.args, err)
+			continue
+		}
+		if !slices.Equal(paths, test.paths) || guests != test.guests {
+			t.Errorf("SelectFiles(%q): got %q, %v; want %q, %v", test.args, paths, guests, test.paths, test.guests)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/creachadair/atomicfile"
+)
+
+// A Transaction is a Worktree that stages writes to the local filesystem in
+// temporary files, and applies them together when committed. Reads of a file
+// that has been written return the staged contents.
+//
+// Commit replaces the target files by renaming the staged files over them,
+// so a failure while staging, or before Commit is called, leaves the files
+// unchanged. If a rename fails during Commit, the files already replaced are
+// restored to their previous contents.
+//
+// The zero value is ready for use. A Transaction must be ended by a call to
+// Commit or Rollback, to remove its temporary files.
+type Transaction struct {
+	mu     sync.Mutex
+	staged []*txFile
+	byPath map[string]*txFile
+	done   bool
+}
+
+type txFile struct {
+	path    string
+	data    []byte
+	perm    fs.FileMode
+	tmp     *atomicfile.File
+	old     []byte // contents before the transaction, if existed
+	existed bool
+}
+
+// errTxDone is reported for operations on a transaction that has ended.
+var errTxDone = errors.New("transaction has already ended")
+
+// ReadFile implements part of the Worktree interface.
+func (t *Transaction) ReadFile(path string) ([]byte, error) {
+	t.mu.Lock()
+	f, ok := t.byPath[filepath.Clean(path)]
+	t.mu.Unlock()
+	if ok {
+		return bytes.Clone(f.data), nil
+	}
+	return os.ReadFile(path)
+}
+
+// WriteFile implements part of the Worktree interface. The data are written
+// to a temporary file, which replaces path when t is committed.
+func (t *Transaction) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	key := filepath.Clean(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return errTxDone
+	}
+	tmp, err := atomicfile.New(path, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Cancel()
+		return err
+	}
+
+	if f, ok := t.byPath[key]; ok {
+		f.tmp.Cancel()
+		f.tmp, f.data, f.perm = tmp, bytes.Clone(data), perm
+		return nil
+	}
+	f := &txFile{path: path, data: bytes.Clone(data), perm: perm, tmp: tmp}
+	if old, err := os.ReadFile(path); err == nil {
+		f.old, f.existed = old, true
+	} else if !os.IsNotExist(err) {
+		tmp.Cancel()
+		return err
+	}
+	if t.byPath == nil {
+		t.byPath = make(map[string]*txFile)
+	}
+	t.byPath[key] = f
+	t.staged = append(t.staged, f)
+	return nil
+}
+
+// Paths returns the paths of the files written in t, in order of their first
+// write.
+func (t *Transaction) Paths() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]string, len(t.staged))
+	for i, f := range t.staged {
+		out[i] = f.path
+	}
+	return out
+}
+
+// Commit applies the writes staged in t. If any file cannot be replaced, the
+// files already replaced are restored, and Commit reports an error.
+func (t *Transaction) Commit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return errTxDone
+	}
+	t.done = true
+	for i, f := range t.staged {
+		if err := f.tmp.Close(); err != nil {
+			for _, g := range t.staged[i+1:] {
+				g.tmp.Cancel()
+			}
+			if rerr := restore(t.staged[:i]); rerr != nil {
+				return fmt.Errorf("writing %q: %w (restoring: %v)", f.path, err, rerr)
+			}
+			return fmt.Errorf("writing %q: %w", f.path, err)
+		}
+	}
+	return nil
+}
+
+// restore puts back the previous contents of the given files.
+func restore(files []*txFile) error {
+	var errs []error
+	for _, f := range files {
+		if !f.existed {
+			errs = append(errs, os.Remove(f.path))
+		} else {
+			errs = append(errs, atomicfile.WriteData(f.path, f.old, f.perm))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Rollback discards the writes staged in t. Rollback has no effect if t has
+// already ended, so it is safe to defer.
+func (t *Transaction) Rollback() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return
+	}
+	t.done = true
+	for _, f := range t.staged {
+		f.tmp.Cancel()
+	}
+}
@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/creachadair/atomicfile"
+)
+
+// A Worktree provides access to the files of a working tree. Tools that
+// modify the repository read and write files through a Worktree, so that the
+// same code can run for real or as a dry run.
+type Worktree interface {
+	// ReadFile reads the contents of the file at path.
+	ReadFile(path string) ([]byte, error)
+
+	// WriteFile replaces the contents of the file at path with data.
+	WriteFile(path string, data []byte, perm fs.FileMode) error
+}
+
+// OS is a Worktree that reads and writes the local filesystem directly.
+// Relative paths are resolved against the current working directory, and
+// files are replaced atomically.
+var OS Worktree = osWorktree{}
+
+type osWorktree struct{}
+
+func (osWorktree) ReadFile(path string) ([]byte, error) { return os.ReadFile(path) }
+
+func (osWorktree) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	return atomicfile.WriteData(path, data, perm)
+}
+
+// A DryRun is a Worktree that records writes without applying them. Reads of
+// a file that has been written return the recorded contents, so a sequence
+// of updates behaves as it would if the writes were real.
+type DryRun struct {
+	// Reads of files that have not been written are delegated to Base.
+	// If nil, OS is used.
+	Base Worktree
+
+	mu     sync.Mutex
+	writes []*Write
+	byPath map[string]*Write
+}
+
+// A Write records the effect of the writes to a file in a DryRun.
+type Write struct {
+	Path    string // as given to the first write
+	Old     []byte // contents before the first write; nil if Created
+	New     []byte // contents after the last write
+	Created bool   // the file did not exist before the first write
+}
+
+// Changed reports whether the write changed the contents of the file.
+func (w *Write) Changed() bool { return w.Created || !bytes.Equal(w.Old, w.New) }
+
+func (d *DryRun) base() Worktree {
+	if d.Base == nil {
+		return OS
+	}
+	return d.Base
+}
+
+// ReadFile implements part of the Worktree interface.
+func (d *DryRun) ReadFile(path string) ([]byte, error) {
+	d.mu.Lock()
+	w, ok := d.byPath[filepath.Clean(path)]
+	d.mu.Unlock()
+	if ok {
+		return bytes.Clone(w.New), nil
+	}
+	return d.base().ReadFile(path)
+}
+
+// WriteFile implements part of the Worktree interface.
+func (d *DryRun) WriteFile(path string, data []byte, perm fs.FileMode) error {
+	key := filepath.Clean(path)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if w, ok := d.byPath[key]; ok {
+		w.New = bytes.Clone(data)
+		return nil
+	}
+	w := &Write{Path: path, New: bytes.Clone(data)}
+	old, err := d.base().ReadFile(path)
+	if os.IsNotExist(err) {
+		w.Created = true
+	} else if err != nil {
+		return err
+	} else {
+		w.Old = old
+	}
+	if d.byPath == nil {
+		d.byPath = make(map[string]*Write)
+	}
+	d.byPath[key] = w
+	d.writes = append(d.writes, w)
+	return nil
+}
+
+// Writes returns the files written to d, in order of their first write.
+func (d *DryRun) Writes() []*Write {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*Write(nil), d.writes...)
+}
@@ -0,0 +1,158 @@
+// Program resync reconstructs the episode files of the site repository from
+// the episode data published by the site, at /episodes.json.
+//
+// For each published episode, resync creates the episode file if it does not
+// exist, or otherwise fills in fields that are empty in the local file. With
+// -overwrite, fields in the local file are replaced by the published values
+// where they differ. With -guests, the guest names published for each
+// episode are also recorded in the guest list.
+//
+// This is useful for bootstrapping the data in a fresh clone of the site, or
+// for recovering local files that have been damaged.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	inputFile   = flag.String("input", "", "Read episodes from this file instead of the site")
+	doOverwrite = flag.Bool("overwrite", false, "Replace local field values that differ from the site")
+	doCreate    = flag.Bool("create", true, "Create episode files missing locally")
+	doGuests    = flag.Bool("guests", false, "Record published guest names in the guest list")
+	doDryRun    = flag.Bool("dry-run", false, "Report changes without modifying any files")
+)
+
+func main() {
+	flag.Parse()
+	ctx := context.Background()
+
+	var eps []*ilof.Episode
+	var err error
+	if *inputFile != "" {
+		eps, err = readEpisodes(*inputFile)
+	} else {
+		eps, err = ilof.AllEpisodes(ctx)
+	}
+	if err != nil {
+		log.Fatalf("Loading published episodes: %v", err)
+	}
+	log.Printf("Loaded %d published episodes", len(eps))
+
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	paths, err := ilof.EpisodePaths(repo.EpisodeDir)
+	if os.IsNotExist(err) && *doCreate {
+		paths = make(map[ilof.Label]string)
+	} else if err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+
+	var numCreated, numUpdated int
+	for _, pub := range eps {
+		path, ok := paths[pub.Episode]
+		if !ok {
+			if !*doCreate {
+				log.Printf("- Skipping episode %s: no local file", pub.Episode)
+				continue
+			}
+			path = filepath.Join(repo.EpisodeDir, ilof.EpisodeFileName(pub.Date, pub.Episode))
+			fmt.Printf("%s: create episode %s\n", path, pub.Episode)
+			numCreated++
+			if !*doDryRun {
+				if err := os.MkdirAll(repo.EpisodeDir, 0755); err != nil {
+					log.Fatalf("Creating episode directory: %v", err)
+				} else if err := ilof.WriteEpisode(path, pub); err != nil {
+					log.Fatalf("Writing episode %s: %v", pub.Episode, err)
+				}
+			}
+		} else {
+			ep, err := ilof.LoadEpisode(path)
+			if err != nil {
+				log.Fatalf("Loading episode %s: %v", pub.Episode, err)
+			}
+			changes := mergeEpisode(ep, pub, *doOverwrite)
+			for _, c := range changes {
+				fmt.Printf("%s: %s\n", path, c)
+			}
+			if len(changes) != 0 {
+				numUpdated++
+				if !*doDryRun {
+					if err := ilof.WriteEpisode(path, ep); err != nil {
+						log.Fatalf("Writing episode %s: %v", pub.Episode, err)
+					}
+				}
+			}
+		}
+
+		if *doGuests && len(pub.Guests) != 0 {
+			var guests []*ilof.Guest
+			for _, name := range pub.Guests {
+				guests = append(guests, &ilof.Guest{Name: name})
+			}
+			if *doDryRun {
+				continue
+			} else if err := ilof.AddOrUpdateGuests(pub.Episode, repo.GuestFile, guests, &ilof.GuestOptions{MaxNotes: ilof.DefaultMaxNotes}); err != nil {
+				log.Fatalf("Updating guest list: %v", err)
+			}
+		}
+	}
+	log.Printf("Created %d and updated %d episode files", numCreated, numUpdated)
+	if *doDryRun {
+		log.Print("@ No files were modified, this is a dry run")
+	}
+}
+
+func readEpisodes(path string) ([]*ilof.Episode, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ilof.DecodeEpisodes(f)
+}
+
+// mergeEpisode updates the fields of ep from pub, and returns a description of
+// each field changed. Fields that are empty in ep are filled in from pub, and
+// if overwrite is true, non-empty fields that differ from pub are replaced.
+// Fields that are empty in pub are never changed.
+func mergeEpisode(ep, pub *ilof.Episode, overwrite bool) []string {
+	var out []string
+	dst := reflect.ValueOf(ep).Elem()
+	src := reflect.ValueOf(pub).Elem()
+	typ := dst.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Name == "Episode" || f.Name == "Guests" {
+			continue // the key, and not stored in the episode file
+		}
+		d, s := dst.Field(i), src.Field(i)
+		if s.IsZero() || reflect.DeepEqual(d.Interface(), s.Interface()) {
+			continue
+		} else if !d.IsZero() && !overwrite {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			name = strings.ToLower(f.Name)
+		}
+		if d.IsZero() {
+			out = append(out, fmt.Sprintf("set %s", name))
+		} else {
+			out = append(out, fmt.Sprintf("replace %s", name))
+		}
+		d.Set(s)
+	}
+	return out
+}
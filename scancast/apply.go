@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+// applyMatches updates the episode files for each unrecorded audio episode in
+// acastIndex that confidently matches an episode lacking audio. It returns the
+// number of audio episodes that remain unrecorded.
+func applyMatches(audio []*ilof.AudioEpisode, acastIndex map[string]*ilof.AudioEpisode, eps []*ilof.Episode) (int, error) {
+	paths, err := episodePaths()
+	if err != nil {
+		return 0, err
+	}
+	candidates := missingAudio(eps)
+
+	var numApplied int
+	for _, ep := range audio {
+		if _, ok := acastIndex[ep.PageLink]; !ok {
+			continue // already recorded
+		}
+		m, ok := ilof.ConfidentMatch(ilof.MatchAudio(ep, candidates), *minScore, *minMargin)
+		if !ok {
+			vlogf("- No confident match for %q", ep.Title)
+			continue
+		}
+		path, ok := paths[m.Episode.Episode]
+		if !ok {
+			vlogf("* No episode file found for episode %s", m.Episode.Episode)
+			continue
+		}
+		vlogf("- Matched %q to episode %s (score %.3f): %s",
+			ep.Title, m.Episode.Episode, m.Score, filepath.Base(path))
+
+		// Once an episode is matched, it is no longer a candidate for the
+		// remaining audio episodes, even in a dry run.
+		candidates = removeEpisode(candidates, m.Episode)
+		if *doDryRun {
+			vlogf("@ Not writing episode file %q, this is a dry run", path)
+			continue
+		}
+		if err := updateAudio(path, ep); err != nil {
+			return 0, fmt.Errorf("updating %q: %w", path, err)
+		}
+		numApplied++
+	}
+	vlogf("Updated %d episode files", numApplied)
+	return len(acastIndex) - numApplied, nil
+}
+
+// updateAudio records the links for audio in the episode file at path.
+func updateAudio(path string, audio *ilof.AudioEpisode) error {
+	ep, err := ilof.LoadEpisode(path)
+	if err != nil {
+		return err
+	}
+	ep.AcastURL = ilof.NormalizeURL(audio.PageLink)
+	if audio.FileLink != "" {
+		ep.AudioFileURL = audio.FileLink
+	}
+	return ilof.WriteEpisode(path, ep)
+}
+
+// episodePaths changes to the repository root and returns a map from episode
+// labels to the paths of their episode files.
+func episodePaths() (map[ilof.Label]string, error) {
+	if err := repo.ChdirRoot(); err != nil {
+		return nil, fmt.Errorf("changing directory to repo root: %w", err)
+	}
+	paths := make(map[ilof.Label]string)
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		paths[ep.Episode] = path
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// removeEpisode returns eps without ep, reusing its storage.
+func removeEpisode(eps []*ilof.Episode, ep *ilof.Episode) []*ilof.Episode {
+	out := eps[:0]
+	for _, e := range eps {
+		if e != ep {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// missingAudio returns the episodes of eps that have no audio recorded.
+func missingAudio(eps []*ilof.Episode) []*ilof.Episode {
+	var out []*ilof.Episode
+	for _, ep := range eps {
+		if ep.AcastURL == "" {
+			out = append(out, ep)
+		}
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"sync"
+
+	"github.com/inlieuoffun/tools/ilof"
+)
+
+var auditWorkers = flag.Int("audit-workers", 4, "With -audit, number of concurrent link checks")
+
+// An auditFinding reports a problem with a recorded audio link.
+type auditFinding struct {
+	Episode ilof.Label       `json:"episode"`
+	Field   string           `json:"field"` // "acast" or "audio-file"
+	URL     string           `json:"url"`
+	Problem string           `json:"problem"`
+	Status  *ilof.LinkStatus `json:"status,omitempty"`
+	Suggest string           `json:"suggest,omitempty"` // a suggested replacement URL
+}
+
+// auditLinks checks the recorded audio links of eps against the feed and the
+// live site, and returns findings for links that are dead or have moved.
+func auditLinks(ctx context.Context, audio []*ilof.AudioEpisode, eps []*ilof.Episode) []*auditFinding {
+	byPage := make(map[string]*ilof.AudioEpisode)
+	byFile := make(map[string]*ilof.AudioEpisode)
+	for _, ep := range audio {
+		byPage[ep.PageLink] = ep
+		if ep.FileLink != "" {
+			byFile[ep.FileLink] = ep
+		}
+	}
+
+	type check struct {
+		ep    *ilof.Episode
+		field string
+		url   string
+	}
+	var checks []check
+	for _, ep := range eps {
+		// Links that appear in the feed as recorded are fine. Otherwise, we
+		// will need to check them against the live site.
+		if ep.AcastURL != "" {
+			if _, ok := byPage[ilof.NormalizeURL(ep.AcastURL)]; !ok {
+				checks = append(checks, check{ep, "acast", ep.AcastURL})
+			}
+		}
+		if ep.AudioFileURL != "" {
+			if _, ok := byFile[ep.AudioFileURL]; !ok {
+				checks = append(checks, check{ep, "audio-file", ep.AudioFileURL})
+			}
+		}
+	}
+	vlogf("Checking %d audio links not found in the feed", len(checks))
+
+	var mu sync.Mutex
+	var out []*auditFinding
+	sem := make(chan struct{}, max(*auditWorkers, 1))
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		c := c
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			f := &auditFinding{Episode: c.ep.Episode, Field: c.field, URL: c.url}
+			f.Status = ilof.CheckLink(ctx, c.url)
+			switch {
+			case !f.Status.OK():
+				f.Problem = "unreachable"
+			case f.Status.Redirected():
+				f.Problem = "redirected"
+				f.Suggest = f.Status.Final
+			}
+
+			// If the page is in the feed with a different audio file, the
+			// episode was re-published (see -republished).
+			if c.field == "audio-file" {
+				if a, ok := byPage[ilof.NormalizeURL(c.ep.AcastURL)]; ok && a.FileLink != "" {
+					f.Problem = "republished"
+					f.Suggest = a.FileLink
+				}
+			}
+
+			// If the page is gone but the audio file still appears in the feed
+			// under a different page, the episode was probably re-published
+			// under a new slug.
+			if c.field == "acast" {
+				if a, ok := byFile[c.ep.AudioFileURL]; ok && a.PageLink != c.url {
+					f.Problem = "moved"
+					f.Suggest = a.PageLink
+				}
+			}
+
+			// A link that is live but missing from the feed is not a problem.
+			if f.Problem == "" {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			out = append(out, f)
+		}()
+	}
+	wg.Wait()
+	return out
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/inlieuoffun/tools/ilof"
+)
+
+var numChoices = flag.Int("choices", 5, "With -interactive, number of candidates to offer")
+
+// interactiveMatch prompts the operator to choose a matching episode for each
+// unrecorded audio episode in acastIndex, and records the chosen matches. It
+// returns the number of audio episodes that remain unrecorded.
+func interactiveMatch(audio []*ilof.AudioEpisode, acastIndex map[string]*ilof.AudioEpisode, eps []*ilof.Episode) (int, error) {
+	paths, err := episodePaths()
+	if err != nil {
+		return 0, err
+	}
+	candidates := missingAudio(eps)
+	in := bufio.NewScanner(os.Stdin)
+
+	var numApplied int
+nextAudio:
+	for _, ep := range audio {
+		if _, ok := acastIndex[ep.PageLink]; !ok {
+			continue // already recorded
+		}
+		ms := ilof.MatchAudio(ep, candidates)
+		if len(ms) > *numChoices {
+			ms = ms[:*numChoices]
+		}
+
+		fmt.Printf("\n%s %q\n  %s\n", ep.Published.Format("2006-01-02 15:04"), ep.Title, ep.PageLink)
+		if ep.Subtitle != "" {
+			fmt.Printf("  %s\n", ep.Subtitle)
+		}
+		for i, m := range ms {
+			fmt.Printf("  [%d] %.3f  episode %s (%s) %s\n", i+1, m.Score,
+				m.Episode.Episode, m.Episode.Date, strings.Join(m.Episode.Guests, ", "))
+		}
+
+		for {
+			fmt.Print("Choice: 1-N accept, e <label> for another episode, s skip, q quit? ")
+			if !in.Scan() {
+				break nextAudio
+			}
+			var pick *ilof.Episode
+			cmd := strings.Fields(in.Text())
+			switch {
+			case len(cmd) == 0 || cmd[0] == "s":
+				continue nextAudio
+			case cmd[0] == "q":
+				break nextAudio
+			case cmd[0] == "e" && len(cmd) == 2:
+				label := ilof.Label(cmd[1])
+				if e := findEpisode(eps, label); e != nil && e.AcastURL != "" {
+					fmt.Printf("Episode %s already has audio: %s\n", label, e.AcastURL)
+					continue
+				} else if e != nil && findEpisode(candidates, label) == nil {
+					fmt.Printf("Episode %s was already matched in this session\n", label)
+					continue
+				}
+				pick = findEpisode(candidates, label)
+			default:
+				if n, err := strconv.Atoi(cmd[0]); err == nil && n >= 1 && n <= len(ms) {
+					pick = ms[n-1].Episode
+				}
+			}
+			if pick == nil {
+				fmt.Println("Invalid choice")
+				continue
+			}
+
+			path, ok := paths[pick.Episode]
+			if !ok {
+				fmt.Printf("No episode file found for episode %s\n", pick.Episode)
+				continue
+			}
+			// As in applyMatches, an accepted episode is no longer a candidate
+			// for the remaining audio episodes, even in a dry run.
+			candidates = removeEpisode(candidates, pick)
+			if *doDryRun {
+				vlogf("@ Not writing episode file %q, this is a dry run", path)
+			} else if err := updateAudio(path, ep); err != nil {
+				return 0, fmt.Errorf("updating %q: %w", path, err)
+			} else {
+				vlogf("- Recorded audio for episode %s: %s", pick.Episode, path)
+				numApplied++
+			}
+			continue nextAudio
+		}
+	}
+	if err := in.Err(); err != nil {
+		return 0, err
+	}
+	vlogf("Updated %d episode files", numApplied)
+	return len(acastIndex) - numApplied, nil
+}
+
+// findEpisode returns the episode of eps with the given label, or nil.
+func findEpisode(eps []*ilof.Episode, label ilof.Label) *ilof.Episode {
+	for _, ep := range eps {
+		if ep.Episode == label {
+			return ep
+		}
+	}
+	return nil
+}
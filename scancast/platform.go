@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/inlieuoffun/tools/ilof"
+)
+
+var (
+	appleShowID   = flag.String("apple-id", os.Getenv("APPLE_PODCAST_ID"), "Apple Podcasts collection ID for the show")
+	spotifyShowID = flag.String("spotify-id", os.Getenv("SPOTIFY_SHOW_ID"), "Spotify show ID for the show")
+)
+
+// scanPlatforms loads the Apple Podcasts and Spotify listings for the show,
+// where configured, and reports the audio episodes missing from either. The
+// result maps the Acast page link of each such episode to the names of the
+// platforms ("apple", "spotify") missing it.
+func scanPlatforms(ctx context.Context, audio []*ilof.AudioEpisode) (map[string][]string, error) {
+	var apple, spotify []*ilof.PlatformEpisode
+	if *appleShowID != "" {
+		eps, err := ilof.LoadApplePodcasts(ctx, *appleShowID)
+		if err != nil {
+			return nil, fmt.Errorf("loading Apple Podcasts: %w", err)
+		}
+		vlogf("Loaded %d Apple Podcasts episodes", len(eps))
+		apple = eps
+	} else {
+		vlogf("No -apple-id specified; skipping Apple Podcasts")
+	}
+	if *spotifyShowID != "" {
+		clientID, err := ilof.SpotifyClientID.Value()
+		if err != nil {
+			return nil, err
+		}
+		secret, err := ilof.SpotifyClientSecret.Value()
+		if err != nil {
+			return nil, err
+		}
+		eps, err := ilof.LoadSpotifyShow(ctx, *spotifyShowID, clientID, secret)
+		if err != nil {
+			return nil, fmt.Errorf("loading Spotify: %w", err)
+		}
+		vlogf("Loaded %d Spotify episodes", len(eps))
+		spotify = eps
+	} else {
+		vlogf("No -spotify-id specified; skipping Spotify")
+	}
+
+	gaps := make(map[string][]string)
+	for _, ep := range audio {
+		var missing []string
+		if *appleShowID != "" && ilof.FindPlatformEpisode(ep, apple) == nil && !olderThanListing(ep, apple) {
+			missing = append(missing, "apple")
+		}
+		if *spotifyShowID != "" && ilof.FindPlatformEpisode(ep, spotify) == nil {
+			missing = append(missing, "spotify")
+		}
+		if len(missing) != 0 {
+			gaps[ep.PageLink] = missing
+		}
+	}
+	return gaps, nil
+}
+
+// olderThanListing reports whether ep was published before the oldest entry
+// in ps. The Apple lookup API only reports recent episodes, so older episodes
+// cannot be checked.
+func olderThanListing(ep *ilof.AudioEpisode, ps []*ilof.PlatformEpisode) bool {
+	if len(ps) == 0 {
+		return false
+	}
+	oldest := ps[0].Published
+	for _, p := range ps[1:] {
+		if p.Published.Before(oldest) {
+			oldest = p.Published
+		}
+	}
+	return ep.Published.Before(oldest)
+}
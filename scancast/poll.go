@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+)
+
+var (
+	pollInterval = flag.Duration("poll-interval", 1*time.Hour, "With -poll, time between feed checks")
+	webhookURL   = flag.String("webhook", "", "With -poll, post new episodes to this webhook URL (default $ILOF_WEBHOOK)")
+)
+
+const minPollInterval = 5 * time.Minute
+
+// pollFeed periodically reloads the audio feed and reports audio episodes that
+// have appeared since the previous check. It runs until ctx ends.
+func pollFeed(ctx context.Context, audio []*ilof.AudioEpisode) error {
+	seen := make(map[string]bool)
+	for _, ep := range audio {
+		seen[ep.PageLink] = true
+	}
+	wait := *pollInterval
+	if wait < minPollInterval {
+		wait = minPollInterval
+	}
+	for {
+		vlogf("Sleeping for %v (until %s)...", wait,
+			time.Now().Add(wait).In(time.Local).Format(time.Kitchen))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		next, err := ilof.LoadAcastFeed(ctx, ilof.AcastFeedURL)
+		if err != nil {
+			log.Printf("* Loading acast feed: %v", err)
+			continue
+		}
+		var fresh []*ilof.AudioEpisode
+		for _, ep := range next {
+			if !seen[ep.PageLink] {
+				seen[ep.PageLink] = true
+				fresh = append(fresh, ep)
+			}
+		}
+		vlogf("Loaded %d audio episodes, %d new", len(next), len(fresh))
+		if len(fresh) == 0 {
+			continue
+		}
+		if err := reportFresh(ctx, fresh); err != nil {
+			log.Printf("* Reporting new episodes: %v", err)
+		}
+	}
+}
+
+// reportFresh reports newly-published audio episodes with their proposed
+// matches, and sends a notification if a webhook is configured.
+func reportFresh(ctx context.Context, fresh []*ilof.AudioEpisode) error {
+	eps, err := ilof.AllEpisodes(ctx) // not cached, so this reflects the latest poll
+	if err != nil {
+		return fmt.Errorf("loading ILoF episodes: %w", err)
+	}
+	candidates := missingAudio(eps)
+
+	var lines []string
+	for _, ep := range fresh {
+		line := fmt.Sprintf("New audio episode %q: %s", ep.Title, ep.PageLink)
+		if m, ok := ilof.ConfidentMatch(ilof.MatchAudio(ep, candidates), *minScore, *minMargin); ok {
+			line += fmt.Sprintf(" (probably episode %s, score %.3f)", m.Episode.Episode, m.Score)
+		}
+		log.Print(line)
+		lines = append(lines, line)
+	}
+	hook := *webhookURL
+	if hook == "" {
+		hook = ilof.WebhookURL.Get()
+	}
+	if hook == "" {
+		return nil
+	}
+	return ilof.Notify(ctx, hook, strings.Join(lines, "\n"), fresh)
+}
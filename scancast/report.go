@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/inlieuoffun/tools/ilof"
+)
+
+// A reportRow is a single line of the report of audio episodes that are
+// missing somewhere: not recorded in the episode log, or not listed on one of
+// the other platforms checked with -platforms.
+type reportRow struct {
+	Audio    *ilof.AudioEpisode
+	Recorded *ilof.Episode    // the episode recording the audio, or nil
+	Best     *ilof.AudioMatch // if not recorded, the best candidate, or nil
+	Missing  []string         // where it is missing: "log", "apple", "spotify"
+}
+
+func (r reportRow) fields() []string {
+	label, guess, score := "", "", ""
+	if r.Recorded != nil {
+		label = string(r.Recorded.Episode)
+	}
+	if r.Best != nil {
+		guess = string(r.Best.Episode.Episode)
+		score = fmt.Sprintf("%.3f", r.Best.Score)
+	}
+	return []string{
+		r.Audio.Published.Format("2006-01-02"), r.Audio.Title, r.Audio.PageLink,
+		label, guess, score, strings.Join(r.Missing, " "),
+	}
+}
+
+var reportHeader = []string{"Published", "Title", "Page", "Episode", "Best guess", "Score", "Missing from"}
+
+// reportRows constructs report rows for the audio episodes of audio that are
+// unrecorded, according to acastIndex, or missing from other platforms,
+// according to gaps, in feed order.
+func reportRows(audio []*ilof.AudioEpisode, acastIndex map[string]*ilof.AudioEpisode, gaps map[string][]string, eps []*ilof.Episode) []reportRow {
+	candidates := missingAudio(eps)
+	recorded := make(map[string]*ilof.Episode)
+	for _, ep := range eps {
+		if ep.AcastURL != "" {
+			recorded[ilof.NormalizeURL(ep.AcastURL)] = ep
+		}
+	}
+	var rows []reportRow
+	for _, ep := range audio {
+		row := reportRow{Audio: ep}
+		if _, ok := acastIndex[ep.PageLink]; ok {
+			row.Missing = append(row.Missing, "log")
+			if ms := ilof.MatchAudio(ep, candidates); len(ms) != 0 {
+				row.Best = ms[0]
+			}
+		} else {
+			row.Recorded = recorded[ep.PageLink]
+		}
+		row.Missing = append(row.Missing, gaps[ep.PageLink]...)
+		if len(row.Missing) != 0 {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// A jsonRow is the JSON encoding of a report row.
+type jsonRow struct {
+	Published string     `json:"published"`
+	Title     string     `json:"title"`
+	PageLink  string     `json:"pageLink"`
+	FileLink  string     `json:"fileLink,omitempty"`
+	Episode   ilof.Label `json:"episode,omitempty"`
+	Best      ilof.Label `json:"bestGuess,omitempty"`
+	Score     float64    `json:"score,omitempty"`
+	Missing   []string   `json:"missing"`
+}
+
+func jsonRows(rows []reportRow) []*jsonRow {
+	out := []*jsonRow{}
+	for _, row := range rows {
+		jr := &jsonRow{
+			Published: row.Audio.Published.Format("2006-01-02"),
+			Title:     row.Audio.Title,
+			PageLink:  row.Audio.PageLink,
+			FileLink:  row.Audio.FileLink,
+			Missing:   row.Missing,
+		}
+		if row.Recorded != nil {
+			jr.Episode = row.Recorded.Episode
+		}
+		if row.Best != nil {
+			jr.Best = row.Best.Episode.Episode
+			jr.Score = row.Best.Score
+		}
+		out = append(out, jr)
+	}
+	return out
+}
+
+// writeCSV writes rows to w in CSV format with a header line.
+func writeCSV(w io.Writer, rows []reportRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write(reportHeader)
+	for _, row := range rows {
+		cw.Write(row.fields())
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeMarkdown writes rows to w as a markdown table.
+func writeMarkdown(w io.Writer, rows []reportRow) error {
+	fmt.Fprintf(w, "| %s |\n", strings.Join(reportHeader, " | "))
+	fmt.Fprintf(w, "|%s\n", strings.Repeat("---|", len(reportHeader)))
+	for _, row := range rows {
+		fs := row.fields()
+		fs[1] = mdEscape(fs[1])
+		if fs[2] != "" {
+			fs[2] = fmt.Sprintf("[link](%s)", fs[2])
+		}
+		_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(fs, " | "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mdEscape(s string) string { return strings.ReplaceAll(s, "|", `\|`) }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+)
+
+// A republishedRow reports an episode whose audio was re-published on Acast.
+type republishedRow struct {
+	Episode     ilof.Label `json:"episode"`
+	Acast       string     `json:"acast"`
+	OldFile     string     `json:"oldFile"`
+	NewFile     string     `json:"newFile"`
+	OldDuration string     `json:"oldDuration,omitempty"`
+	NewDuration string     `json:"newDuration,omitempty"`
+}
+
+func republishedRows(cs []*ilof.AudioChange) []*republishedRow {
+	out := []*republishedRow{}
+	for _, c := range cs {
+		row := &republishedRow{
+			Episode:     c.Episode.Episode,
+			Acast:       c.Audio.PageLink,
+			OldFile:     c.Episode.AudioFileURL,
+			NewFile:     c.Audio.FileLink,
+			OldDuration: c.Episode.Duration,
+		}
+		if d := c.Audio.Duration; d > 0 {
+			row.NewDuration = d.Round(time.Second).String()
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+// refreshAudio updates the audio file, and with -refresh-duration the
+// duration, in the episode file for each of the re-published episodes in cs.
+func refreshAudio(cs []*ilof.AudioChange) error {
+	paths, err := episodePaths()
+	if err != nil {
+		return err
+	}
+	var numApplied int
+	for _, c := range cs {
+		path, ok := paths[c.Episode.Episode]
+		if !ok {
+			vlogf("* No episode file found for episode %s", c.Episode.Episode)
+			continue
+		}
+		ep, err := ilof.LoadEpisode(path)
+		if err != nil {
+			return err
+		}
+		changes := c.Apply(ep, *doDuration)
+		if len(changes) == 0 {
+			continue // already refreshed in the repository
+		}
+		for _, msg := range changes {
+			vlogf("- %s: %s", filepath.Base(path), msg)
+		}
+		if *doDryRun {
+			vlogf("@ Not writing episode file %q, this is a dry run", path)
+			continue
+		}
+		if err := ilof.WriteEpisode(path, ep); err != nil {
+			return fmt.Errorf("updating %q: %w", path, err)
+		}
+		numApplied++
+	}
+	vlogf("Updated %d episode files", numApplied)
+	return nil
+}
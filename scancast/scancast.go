@@ -1,10 +1,41 @@
 // Program scancast looks for audio episodes in the acast RSS feed that may not
 // have yet been recorded in the episode log.
 //
-// Ideally we would automatically correlate these, but the date of publication
-// is different, and the episode numbers on acast are hand-assigned and usually
+// We cannot correlate these exactly, because the date of publication is
+// different, and the episode numbers on acast are hand-assigned and usually
 // wrong. So instead, we list all the known audio episodes, cross off the ones
-// that have already been recorded, and list the leftovers.
+// that have already been recorded, and list the leftovers. Each leftover is
+// annotated with the best-scoring candidate episodes from a fuzzy match on the
+// guest names, topics, and air dates of episodes lacking audio.
+//
+// With -apply, leftovers that match an unrecorded episode with high confidence
+// are written into the corresponding episode files in the repository. With
+// -interactive, the operator chooses the match for each leftover instead.
+//
+// With -poll, scancast keeps running, reloading the feed every -poll-interval
+// and reporting the audio episodes that appear, with their likely matches. If
+// -webhook or the ILOF_WEBHOOK credential is set, each report is also posted
+// to that webhook, the same mechanism epdate uses to announce new episodes.
+//
+// With -platforms, the report also covers the show's Apple Podcasts and
+// Spotify listings (see -apple-id and -spotify-id): it lists each audio
+// episode that is unrecorded, missing from one of those platforms, or both,
+// and says where it is missing.
+//
+// Exit status 0 means no audio episodes require updating.
+// Exit status 3 means unrecorded audio episodes were found, or with -apply or
+// -interactive, that some remain unrecorded afterward, or with -platforms,
+// that some are missing from another platform.
+// Any other status means some other failure.
+//
+// Acast sometimes re-publishes an episode, keeping its landing page but
+// changing its audio file. With -republished, scancast reports recorded
+// episodes whose audio file differs from the one in the feed; with
+// -republished -apply, it refreshes the audio-file fields of their episode
+// files, and with -refresh-duration their duration fields too.
+//
+// With -quiet, informational logging is suppressed in every mode, and the
+// report is written to stdout as JSON, for use by automation.
 package main
 
 import (
@@ -14,24 +45,51 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/inlieuoffun/tools/ilof"
 )
 
 var (
-	doFeed    = flag.Bool("json-feed", false, "Print Acast feed as JSON and exit")
-	doMissing = flag.Bool("log-missing", false, "Log episodes missing audio and exit")
+	doFeed       = flag.Bool("json-feed", false, "Print Acast feed as JSON and exit")
+	doMissing    = flag.Bool("log-missing", false, "Log episodes missing audio and exit")
+	doApply      = flag.Bool("apply", false, "Write confident matches into episode files")
+	doAudit      = flag.Bool("audit", false, "Check recorded audio links against the feed and exit")
+	doRepub      = flag.Bool("republished", false, "Report episodes whose audio was re-published and exit; with -apply, refresh them")
+	doDuration   = flag.Bool("refresh-duration", false, "With -republished -apply, also refresh episode durations from the feed")
+	doQuiet      = flag.Bool("quiet", false, "Suppress logging and write only JSON to stdout")
+	doPoll       = flag.Bool("poll", false, "Poll the feed for newly-published audio episodes")
+	doPlatforms  = flag.Bool("platforms", false, "Also report audio episodes missing from Apple Podcasts or Spotify")
+	doInter      = flag.Bool("interactive", false, "Prompt to accept or reject candidate matches")
+	doDryRun     = flag.Bool("dry-run", false, "With -apply or -interactive, do not modify any files")
+	numProposals = flag.Int("proposals", 3, "Number of candidate matches to propose per audio episode")
+	outFormat    = flag.String("format", "yaml", "Output format of the report (yaml, json, csv, markdown)")
+	minScore     = flag.Float64("min-score", 0.3, "Minimum score for a confident match")
+	minMargin    = flag.Float64("min-margin", 0.1, "Minimum score margin over the runner-up for a confident match")
 )
 
+// Exit status reported when unrecorded audio episodes are found.
+const exitMissing = 3
+
 func main() {
 	flag.Parse()
+	if *doQuiet {
+		*outFormat = "json"
+	}
 
 	ctx := context.Background()
 	audio, err := ilof.LoadAcastFeed(ctx, ilof.AcastFeedURL)
 	if err != nil {
 		log.Fatalf("Loading acast feed: %v", err)
 	}
-	log.Printf("Loaded %d audio episodes", len(audio))
+	vlogf("Loaded %d audio episodes", len(audio))
+	if *doPoll {
+		if err := pollFeed(ctx, audio); err != nil {
+			log.Fatalf("Polling: %v", err)
+		}
+		return
+	}
 	if *doFeed {
 		mustWriteJSON(struct {
 			E []*ilof.AudioEpisode `json:"episodes"`
@@ -43,18 +101,39 @@ func main() {
 	if err != nil {
 		log.Fatalf("Loading ILoF episodes: %v", err)
 	}
-	log.Printf("Loaded %d ILoF episodes", len(eps))
+	vlogf("Loaded %d ILoF episodes", len(eps))
 
-	if *doMissing {
-		var missing []*ilof.Episode
-		for _, ep := range eps {
-			if ep.AcastURL == "" {
-				missing = append(missing, ep)
+	if *doAudit {
+		fs := auditLinks(ctx, audio, eps)
+		sort.Slice(fs, func(i, j int) bool {
+			return fs[i].Episode.Number() < fs[j].Episode.Number()
+		})
+		vlogf("Found %d audio link problems", len(fs))
+		mustWriteJSON(struct {
+			F []*auditFinding `json:"findings"`
+		}{F: fs})
+		return
+	}
+
+	if *doRepub {
+		cs := ilof.RepublishedAudio(audio, eps)
+		vlogf("Found %d re-published audio episodes", len(cs))
+		if *doApply {
+			if err := refreshAudio(cs); err != nil {
+				log.Fatalf("Refreshing audio links: %v", err)
 			}
+			return
 		}
+		mustWriteJSON(struct {
+			R []*republishedRow `json:"republished"`
+		}{R: republishedRows(cs)})
+		return
+	}
+
+	if *doMissing {
 		mustWriteJSON(struct {
 			M []*ilof.Episode `json:"missing"`
-		}{M: missing})
+		}{M: missingAudio(eps)})
 		return
 	}
 
@@ -66,22 +145,109 @@ func main() {
 		acastIndex[ep.PageLink] = ep
 	}
 	for _, ep := range eps {
-		delete(acastIndex, ep.AcastURL)
+		delete(acastIndex, ilof.NormalizeURL(ep.AcastURL))
 	}
-	if len(acastIndex) == 0 {
-		log.Fatal("No audio episodes require updating")
+	var gaps map[string][]string
+	if *doPlatforms && !*doApply && !*doInter {
+		gaps, err = scanPlatforms(ctx, audio)
+		if err != nil {
+			log.Fatalf("Scanning platforms: %v", err)
+		}
+		vlogf("Found %d audio episodes missing from other platforms", len(gaps))
+	}
+	if len(acastIndex) == 0 && len(gaps) == 0 {
+		vlogf("No audio episodes require updating")
+		if *outFormat == "json" {
+			mustWriteJSON(struct {
+				U []*jsonRow `json:"unrecorded"`
+			}{U: []*jsonRow{}})
+		}
+		return
 	}
 
-	for _, ep := range audio {
-		if _, ok := acastIndex[ep.PageLink]; !ok {
-			continue // already recorded
+	if *doApply || *doInter {
+		var left int
+		if *doApply {
+			left, err = applyMatches(audio, acastIndex, eps)
+			if err != nil {
+				log.Fatalf("Applying matches: %v", err)
+			}
+		} else {
+			left, err = interactiveMatch(audio, acastIndex, eps)
+			if err != nil {
+				log.Fatalf("Interactive matching: %v", err)
+			}
+		}
+		if left != 0 {
+			vlogf("%d audio episodes remain unrecorded", left)
+			os.Exit(exitMissing)
+		}
+		return
+	}
+
+	rows := reportRows(audio, acastIndex, gaps, eps)
+	switch *outFormat {
+	case "yaml":
+	case "json":
+		mustWriteJSON(struct {
+			U []*jsonRow `json:"unrecorded"`
+		}{U: jsonRows(rows)})
+		os.Exit(exitMissing)
+	case "csv", "markdown":
+		write := writeCSV
+		if *outFormat == "markdown" {
+			write = writeMarkdown
+		}
+		if err := write(os.Stdout, rows); err != nil {
+			log.Fatalf("Writing report: %v", err)
 		}
+		os.Exit(exitMissing)
+	default:
+		log.Fatalf("Unknown output format %q", *outFormat)
+	}
+
+	candidates := missingAudio(eps)
+	for _, row := range rows {
+		ep := row.Audio
 		log.Printf("%s %q", ep.Published.Format("2006-01-02 15:04"), ep.Title)
+		if gap := gaps[ep.PageLink]; len(gap) != 0 {
+			fmt.Printf("# missing from %s\n", strings.Join(gap, ", "))
+		}
+		if row.Recorded != nil {
+			fmt.Printf("# recorded: episode %s (%s)\n", row.Recorded.Episode, row.Recorded.Date)
+			fmt.Printf("acast: %s\n", ep.PageLink)
+			continue
+		}
+
+		// Propose the best-scoring candidates, flagging a confident match.
+		ms := ilof.MatchAudio(ep, candidates)
+		best, ok := ilof.ConfidentMatch(ms, *minScore, *minMargin)
+		if len(ms) > *numProposals {
+			ms = ms[:*numProposals]
+		}
+		for _, m := range ms {
+			tag := "candidate"
+			if ok && m == best {
+				tag = "confident"
+			}
+			fmt.Printf("# %s: episode %s (%s) score %.3f\n", tag, m.Episode.Episode, m.Episode.Date, m.Score)
+		}
+		if len(ms) == 0 {
+			fmt.Println("# no candidate episodes found")
+		}
 		fmt.Printf("acast: %s\n", ep.PageLink)
 		if ep.FileLink != "" {
 			fmt.Printf("audio-file: %s\n", ep.FileLink)
 		}
 	}
+	os.Exit(exitMissing)
+}
+
+// vlogf logs an informational message, unless -quiet is set.
+func vlogf(msg string, args ...interface{}) {
+	if !*doQuiet {
+		log.Printf(msg, args...)
+	}
 }
 
 func mustWriteJSON(v interface{}) {
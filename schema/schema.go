@@ -0,0 +1,127 @@
+// Program schema publishes JSON Schema definitions for the episode and guest
+// files of the site repository, and checks the files against them.
+//
+// To write the schemas to a directory, for use by editors:
+//
+//	schema -write schema
+//
+// This writes episode.schema.json, for the front matter of episode files, and
+// guests.schema.json, for the guest list. Otherwise, schema checks the named
+// files, or all the episode files and the guest list if none are named, and
+// prints each violation as "path:line:column: error: message".
+//
+// Exit status 0 means no violations were found. Exit status 3 means at least
+// one violation was found. Any other status means some other failure.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	writeDir = flag.String("write", "", "Write the schemas to this directory and exit")
+	doJSON   = flag.Bool("json", false, "Write violations as JSON")
+)
+
+func main() {
+	flag.Parse()
+
+	// Resolve paths named on the command line before changing directory.
+	var args []string
+	for _, arg := range flag.Args() {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			log.Fatalf("Resolving path: %v", err)
+		}
+		args = append(args, abs)
+	}
+	dir := *writeDir
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			log.Fatalf("Resolving path: %v", err)
+		}
+		dir = abs
+	}
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	if dir != "" {
+		if err := writeSchemas(dir); err != nil {
+			log.Fatalf("Writing schemas: %v", err)
+		}
+		return
+	}
+	epPaths, doGuests, err := repo.SelectFiles(args)
+	if err != nil {
+		log.Fatalf("Selecting files: %v", err)
+	}
+
+	findings := []*ilof.Finding{}
+	for _, path := range epPaths {
+		fs, err := ilof.ValidateEpisodeSchema(path)
+		if err != nil {
+			log.Fatalf("Checking episodes: %v", err)
+		}
+		findings = append(findings, fs...)
+	}
+	if doGuests {
+		fs, err := ilof.ValidateGuestSchema(repo.GuestFile)
+		if err != nil {
+			log.Fatalf("Checking guests: %v", err)
+		}
+		findings = append(findings, fs...)
+	}
+
+	if *doJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			F []*ilof.Finding `json:"findings"`
+		}{F: findings}); err != nil {
+			log.Fatalf("Encoding JSON: %v", err)
+		}
+	} else {
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+	}
+	if len(findings) != 0 {
+		log.Printf("Found %d violations", len(findings))
+		os.Exit(3)
+	}
+}
+
+// writeSchemas writes the episode and guest schemas to files in dir.
+func writeSchemas(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, s := range map[string]*ilof.Schema{
+		"episode.schema.json": ilof.EpisodeSchema(),
+		"guests.schema.json":  ilof.GuestSchema(),
+	} {
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, name)
+		if err := atomicfile.WriteData(path, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+		log.Printf("Wrote %s", path)
+		if err := ilof.RecordGenerated(repo.ManifestFile, "schema", path); err != nil {
+			return fmt.Errorf("updating manifest: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+// Program search searches the stored episode transcripts in the site
+// repository for a word or phrase, and prints each match with the episode,
+// the time offset in the video, a link to that point in the video, and a
+// snippet of the surrounding text.
+//
+// Usage:
+//
+//	search [options] "cheese night"
+//
+// Matching is case-insensitive and ignores punctuation, and a phrase may span
+// caption boundaries. With -json, matches are written as a JSON array.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	sinceFlag  = flag.String("since", "", "Only search episodes aired on or after this date (YYYY, YYYY-MM, or YYYY-MM-DD)")
+	untilFlag  = flag.String("until", "", "Only search episodes aired before the end of this date (YYYY, YYYY-MM, or YYYY-MM-DD)")
+	contextLen = flag.Int("context", 8, "Number of words of context to show on each side of a match")
+	maxMatches = flag.Int("max", 0, "Maximum number of matches to report (0 for no limit)")
+	doJSON     = flag.Bool("json", false, "Write matches as JSON")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s [options] <word-or-phrase>
+
+Search the stored transcripts of episodes in the site repository.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+// A match is a single occurrence of the query in a transcript.
+type match struct {
+	Episode ilof.Label `json:"episode"`
+	Date    string     `json:"airDate"`
+	Offset  float64    `json:"offsetSec"`
+	Time    string     `json:"time"`
+	URL     string     `json:"url,omitempty"`
+	Snippet string     `json:"snippet"`
+}
+
+func main() {
+	flag.Parse()
+	query := ilof.Words(strings.Join(flag.Args(), " "))
+	if len(query) == 0 {
+		log.Fatal("You must provide a word or phrase to search for")
+	}
+	since, _, err := parseDateRange("since", *sinceFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, until, err := parseDateRange("until", *untilFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	var eps []*ilof.Episode
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		at := time.Time(ep.Date)
+		if ep.TranscriptFile() == "" {
+			return nil // no searchable transcript
+		} else if !since.IsZero() && at.Before(since) {
+			return nil
+		} else if !until.IsZero() && !at.Before(until) {
+			return nil
+		}
+		eps = append(eps, ep)
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	sort.Slice(eps, func(i, j int) bool {
+		return time.Time(eps[i].Date).Before(time.Time(eps[j].Date))
+	})
+
+	var matches []*match
+	for _, ep := range eps {
+		t, err := ilof.LoadEpisodeTranscript(ep)
+		if err != nil {
+			log.Printf("* Episode %s: %v", ep.Episode, err)
+			continue
+		}
+		matches = append(matches, findMatches(ep, t, query)...)
+		if *maxMatches > 0 && len(matches) >= *maxMatches {
+			matches = matches[:*maxMatches]
+			break
+		}
+	}
+	log.Printf("Found %d matches in %d transcripts", len(matches), len(eps))
+
+	if *doJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if matches == nil {
+			matches = []*match{}
+		}
+		if err := enc.Encode(matches); err != nil {
+			log.Fatalf("Writing output: %v", err)
+		}
+		return
+	}
+	for _, m := range matches {
+		fmt.Printf("Episode %s (%s) at %s\n  %s\n", m.Episode, m.Date, m.Time, m.Snippet)
+		if m.URL != "" {
+			fmt.Printf("  %s\n", m.URL)
+		}
+		fmt.Println()
+	}
+}
+
+// A token is a word of a transcript, with the caption it came from.
+type token struct {
+	text string  // as written
+	norm string  // normalized for matching
+	at   float64 // start time of the caption, in seconds
+}
+
+// findMatches returns the occurrences of the query words in t.
+func findMatches(ep *ilof.Episode, t *ilof.Transcript, query []string) []*match {
+	var toks []token
+	for _, c := range t.Captions {
+		for _, w := range strings.Fields(c.Text) {
+			if norm := ilof.Words(w); len(norm) == 1 && norm[0] != "" {
+				toks = append(toks, token{text: w, norm: norm[0], at: c.Start})
+			}
+		}
+	}
+
+	id, _ := ilof.YouTubeVideoID(ep.YouTubeURL)
+	if id == "" {
+		id = t.VideoID
+	}
+	var out []*match
+	for i := 0; i+len(query) <= len(toks); i++ {
+		if !matchAt(toks[i:], query) {
+			continue
+		}
+		lo := max(i-*contextLen, 0)
+		hi := min(i+len(query)+*contextLen, len(toks))
+		var words []string
+		for j := lo; j < hi; j++ {
+			w := toks[j].text
+			if j == i {
+				w = "[" + w
+			}
+			if j == i+len(query)-1 {
+				w += "]"
+			}
+			words = append(words, w)
+		}
+		snippet := strings.Join(words, " ")
+		if lo > 0 {
+			snippet = "… " + snippet
+		}
+		if hi < len(toks) {
+			snippet += " …"
+		}
+
+		m := &match{
+			Episode: ep.Episode,
+			Date:    ep.Date.String(),
+			Offset:  toks[i].at,
+			Time:    formatOffset(toks[i].at),
+			Snippet: snippet,
+		}
+		if id != "" {
+			m.URL = ilof.VideoDeepLink(id, toks[i].at)
+		}
+		out = append(out, m)
+		i += len(query) - 1 // do not report overlapping matches
+	}
+	return out
+}
+
+func matchAt(toks []token, query []string) bool {
+	for i, q := range query {
+		if toks[i].norm != q {
+			return false
+		}
+	}
+	return true
+}
+
+// formatOffset formats sec as H:MM:SS.
+func formatOffset(sec float64) string {
+	s := int(sec)
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, (s/60)%60, s%60)
+}
+
+// parseDateRange parses s as a date of the form YYYY, YYYY-MM, or YYYY-MM-DD
+// and returns the start of that period and the start of the following period.
+// If s is empty, it returns zero times.
+func parseDateRange(name, s string) (start, end time.Time, err error) {
+	if s == "" {
+		return
+	}
+	for _, f := range []struct {
+		layout  string
+		y, m, d int
+	}{
+		{"2006", 1, 0, 0},
+		{"2006-01", 0, 1, 0},
+		{"2006-01-02", 0, 0, 1},
+	} {
+		if t, perr := time.Parse(f.layout, s); perr == nil {
+			return t, t.AddDate(f.y, f.m, f.d), nil
+		}
+	}
+	return start, end, fmt.Errorf("invalid -%s date %q", name, s)
+}
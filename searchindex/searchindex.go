@@ -0,0 +1,201 @@
+// Program searchindex generates a search index for the episodes and guests in
+// the site repository, for use by a client-side search library such as
+// lunr.js on the static site.
+//
+// The index is a JSON object with an array of episode documents and an array
+// of guest documents:
+//
+//	{
+//	  "episodes": [{"id": "100", "title": "...", "url": "...", "date": "...",
+//	                "guests": [...], "summary": "...", "topics": "...",
+//	                "tags": [...], "keywords": [...], "hash": "..."}, ...],
+//	  "guests": [{"id": "...", "name": "...", "url": "...", "episodes": [...]}, ...]
+//	}
+//
+// With -transcripts, the "keywords" of each episode with a stored transcript
+// are the key phrases of its transcript, as found by ilof.Keywords.
+//
+// The index is regenerated incrementally: each episode document records a
+// hash of its inputs, and a document whose inputs have not changed since the
+// previous index was written is reused rather than rebuilt. Use -full to
+// rebuild every document.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath       = flag.String("o", "search.json", "Write the index to this file")
+	doTranscripts = flag.Bool("transcripts", false, "Include keywords from stored transcripts")
+	numKeywords   = flag.Int("keywords", 20, "With -transcripts, number of keywords per episode")
+	doFull        = flag.Bool("full", false, "Rebuild all documents, ignoring the existing index")
+)
+
+type index struct {
+	Episodes []*epDoc    `json:"episodes"`
+	Guests   []*guestDoc `json:"guests"`
+}
+
+type epDoc struct {
+	ID       ilof.Label `json:"id"`
+	Title    string     `json:"title"`
+	URL      string     `json:"url"`
+	Date     string     `json:"date"`
+	Guests   []string   `json:"guests,omitempty"`
+	Summary  string     `json:"summary,omitempty"`
+	Topics   string     `json:"topics,omitempty"`
+	Tags     []string   `json:"tags,omitempty"`
+	Keywords []string   `json:"keywords,omitempty"`
+	Hash     string     `json:"hash"`
+}
+
+type guestDoc struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	URL      string       `json:"url,omitempty"`
+	Episodes []ilof.Label `json:"episodes"`
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	gidx := ilof.GuestIndex(guests)
+
+	old := make(map[ilof.Label]*epDoc)
+	if !*doFull {
+		if data, err := os.ReadFile(*outPath); err == nil {
+			var prev index
+			if err := json.Unmarshal(data, &prev); err != nil {
+				log.Printf("* Ignoring invalid existing index: %v", err)
+			}
+			for _, doc := range prev.Episodes {
+				old[doc.ID] = doc
+			}
+		}
+	}
+
+	var out index
+	var numReused int
+	labels := make(map[ilof.Label]bool)
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		for _, g := range gidx[ep.Episode] {
+			ep.Guests = append(ep.Guests, g.Name)
+		}
+		labels[ep.Episode] = true
+		hash, err := inputHash(path, ep)
+		if err != nil {
+			return err
+		}
+		if doc, ok := old[ep.Episode]; ok && doc.Hash == hash {
+			out.Episodes = append(out.Episodes, doc)
+			numReused++
+			return nil
+		}
+		doc, err := newEpDoc(ep)
+		if err != nil {
+			return fmt.Errorf("episode %s: %w", ep.Episode, err)
+		}
+		doc.Hash = hash
+		out.Episodes = append(out.Episodes, doc)
+		return nil
+	}); err != nil {
+		log.Fatalf("Indexing episodes: %v", err)
+	}
+	sort.Slice(out.Episodes, func(i, j int) bool {
+		return out.Episodes[i].Date < out.Episodes[j].Date
+	})
+	log.Printf("Indexed %d episodes (%d unchanged)", len(out.Episodes), numReused)
+
+	for _, g := range guests {
+		doc := &guestDoc{ID: "guest:" + g.Name, Name: g.Name}
+		if g.Twitter != "" {
+			doc.URL = "https://twitter.com/" + strings.TrimPrefix(g.Twitter, "@")
+		}
+		for _, v := range g.Episodes {
+			if labels[v] {
+				doc.Episodes = append(doc.Episodes, v)
+			}
+		}
+		out.Guests = append(out.Guests, doc)
+	}
+	log.Printf("Indexed %d guests", len(out.Guests))
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		log.Fatalf("Encoding index: %v", err)
+	}
+	if err := atomicfile.WriteData(*outPath, append(data, '\n'), 0644); err != nil {
+		log.Fatalf("Writing index: %v", err)
+	} else if err := ilof.RecordGenerated(repo.ManifestFile, "searchindex", *outPath); err != nil {
+		log.Fatalf("Updating manifest: %v", err)
+	}
+}
+
+// keywordMethod names the method used to find keywords. It is included in
+// the input hash, so that changing the method rebuilds the documents.
+const keywordMethod = "rake"
+
+// inputHash returns a hash of the inputs to the search document for ep, whose
+// episode file is at path.
+func inputHash(path string, ep *ilof.Episode) (string, error) {
+	h := sha256.New()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	fmt.Fprintf(h, "\x00%q", ep.Guests)
+	if tpath := ep.TranscriptFile(); *doTranscripts && tpath != "" {
+		// Use the modification time of the transcript rather than reading it,
+		// since transcripts are large and rarely change.
+		if fi, err := os.Stat(tpath); err == nil {
+			fmt.Fprintf(h, "\x00%s\x00%d\x00%s", keywordMethod, *numKeywords, fi.ModTime().Format(time.RFC3339Nano))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+func newEpDoc(ep *ilof.Episode) (*epDoc, error) {
+	doc := &epDoc{
+		ID:      ep.Episode,
+		Title:   fmt.Sprintf("Episode %s", ep.Episode),
+		URL:     "/episode/" + string(ep.Episode),
+		Date:    ep.Date.String(),
+		Guests:  ep.Guests,
+		Summary: ep.Summary,
+		Topics:  ep.Topics,
+		Tags:    ep.AllTags(),
+	}
+	if *doTranscripts {
+		t, err := ilof.LoadEpisodeTranscript(ep)
+		if os.IsNotExist(err) {
+			log.Printf("* Episode %s: transcript %q not found", ep.Episode, ep.Transcript)
+			return doc, nil
+		} else if err != nil {
+			return nil, err
+		} else if t != nil {
+			doc.Keywords = ilof.Keywords(t.Text(), *numKeywords)
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,88 @@
+// Program seasonpages generates an archive page for each season of the
+// webcast, in the pages directory of the site repository.
+//
+// Each page lists the episodes of the season with their dates, guests,
+// topics, and tags, preceded by statistics for the season: the number of
+// episodes and guests, the total duration, and the most frequent tags and
+// guests. Episodes without a season are assigned one by air date, from the
+// season list. The page for season N is written to <dir>/season-N.md, with
+// the permalink /season/N/.
+//
+// Run seasonpages again whenever the episodes change. Only pages whose
+// contents have changed are rewritten, and generated pages for seasons that
+// no longer have any episodes are removed; pages not written by this program
+// are never modified, and are reported as conflicts instead. With -check,
+// nothing is written, and the exit status is 3 if any page is out of date or
+// in conflict.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	pageDir  = flag.String("dir", "_pages", "Write pages to this directory, relative to the repository root")
+	layout   = flag.String("layout", "page", "Jekyll layout for the generated pages")
+	topN     = flag.Int("top", 5, "Number of top tags and guests to report per season")
+	doCheck  = flag.Bool("check", false, "Report out-of-date pages without writing them")
+	doDryRun = flag.Bool("dry-run", false, "Print the pages to stdout instead of writing them")
+)
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	seasons, err := ilof.LoadSeasons(repo.SeasonFile)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Loading seasons: %v", err)
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	var eps []*ilof.Episode
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		eps = append(eps, ep)
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+
+	// Render the pages, then update those that differ from what is on disk.
+	ps := &ilof.PageSet{
+		Dir:       *pageDir,
+		Marker:    ilof.ArchiveMarker,
+		Pages:     make(map[string][]byte),
+		Manifest:  repo.ManifestFile,
+		Generator: "seasonpages",
+		Kind:      "season",
+	}
+	for _, a := range ilof.SeasonArchives(eps, guests, seasons, *topN) {
+		data, err := a.Markdown(*layout)
+		if err != nil {
+			log.Fatalf("Rendering season %d: %v", a.Season, err)
+		}
+		ps.Pages[fmt.Sprintf("season-%d.md", a.Season)] = data
+	}
+
+	mode := ilof.ApplyPages
+	switch {
+	case *doDryRun:
+		mode = ilof.PrintPages
+	case *doCheck:
+		mode = ilof.CheckPages
+	}
+	n, err := ps.Update(mode, os.Stdout)
+	if err != nil {
+		log.Fatalf("Updating pages: %v", err)
+	} else if mode == ilof.CheckPages && n != 0 {
+		os.Exit(3)
+	}
+}
@@ -0,0 +1,206 @@
+// Program segments detects recurring segments (such as cheese night) in the
+// back catalog of episodes, and records them in the segments field of the
+// episodes that lack them.
+//
+// Segments are detected by the tag rules in the rules file of the repository
+// (by default _data/tag-rules.yaml), or by built-in rules if there is no such
+// file. See ilof.LoadTagRules for the format.
+//
+// Detection is a two-step process. First, run segments to scan the text of
+// each episode and its stored transcript, and write the proposed segments to
+// a file:
+//
+//	segments -o proposals.json
+//
+// Review the file, and delete any proposals that should not be applied. Then
+// apply the remaining proposals to the episode files:
+//
+//	segments -apply proposals.json
+//
+// Segments used to be recorded as tags. To move the tags that name segments
+// known to the rules into the segments field, run:
+//
+//	segments -migrate
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath       = flag.String("o", "", "Write proposals to this file (default stdout)")
+	applyPath     = flag.String("apply", "", "Apply the proposals in this file")
+	doMigrate     = flag.Bool("migrate", false, "Move tags that name segments into the segments field")
+	doTranscripts = flag.Bool("transcripts", true, "Scan stored transcripts as well as episode text")
+	minMentions   = flag.Int("min-mentions", 2, "Minimum mentions in a transcript to propose a tag, for rules that do not set one")
+)
+
+// A proposal is a segment proposed for an episode.
+type proposal struct {
+	Episode  ilof.Label `json:"episode"`
+	Path     string     `json:"path"`
+	Segment  string     `json:"segment"`
+	Evidence string     `json:"evidence"`
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	if *applyPath != "" {
+		if err := applyProposals(*applyPath); err != nil {
+			log.Fatalf("Applying proposals: %v", err)
+		}
+		return
+	}
+
+	rules, err := ilof.LoadTagRules(repo.TagRuleFile)
+	if err != nil {
+		log.Fatalf("Loading tag rules: %v", err)
+	}
+	log.Printf("Loaded %d tag rules", len(rules))
+	if *doMigrate {
+		if err := migrateTags(ilof.SegmentNames(rules)); err != nil {
+			log.Fatalf("Migrating tags: %v", err)
+		}
+		return
+	}
+
+	props := []*proposal{}
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		props = append(props, detect(rules, path, ep)...)
+		return nil
+	}); err != nil {
+		log.Fatalf("Scanning episodes: %v", err)
+	}
+	sort.Slice(props, func(i, j int) bool {
+		if props[i].Path == props[j].Path {
+			return props[i].Segment < props[j].Segment
+		}
+		return props[i].Path < props[j].Path
+	})
+	log.Printf("Proposed %d segments", len(props))
+
+	data, err := json.MarshalIndent(props, "", "  ")
+	if err != nil {
+		log.Fatalf("Encoding proposals: %v", err)
+	}
+	data = append(data, '\n')
+	if *outPath == "" {
+		os.Stdout.Write(data)
+	} else if err := atomicfile.WriteData(*outPath, data, 0644); err != nil {
+		log.Fatalf("Writing proposals: %v", err)
+	}
+}
+
+// detect returns segment proposals for ep, whose episode file is at path, using
+// the given rules.
+func detect(rules []*ilof.TagRule, path string, ep *ilof.Episode) []*proposal {
+	text := strings.Join([]string{ep.Summary, ep.Topics, ep.Detail}, "\n")
+	var transcript string
+	if *doTranscripts {
+		t, err := ilof.LoadEpisodeTranscript(ep)
+		if err != nil {
+			log.Printf("* Episode %s: %v", ep.Episode, err)
+		} else if t != nil {
+			transcript = t.Text()
+		}
+	}
+
+	var out []*proposal
+	for _, r := range rules {
+		if ep.HasSegment(r.Tag) || ep.HasTag(r.Tag) {
+			continue
+		}
+		evidence, ok := r.Match(text, transcript, *minMentions)
+		if !ok {
+			continue
+		}
+		out = append(out, &proposal{
+			Episode:  ep.Episode,
+			Path:     path,
+			Segment:  r.Tag,
+			Evidence: evidence,
+		})
+	}
+	return out
+}
+
+// applyProposals adds the segments proposed in the file at path to the
+// episode files.
+func applyProposals(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var props []*proposal
+	if err := json.Unmarshal(data, &props); err != nil {
+		return fmt.Errorf("decoding proposals: %w", err)
+	}
+
+	// Group the proposals by file, so each file is rewritten only once.
+	byPath := make(map[string][]*proposal)
+	var paths []string
+	for _, p := range props {
+		if _, ok := byPath[p.Path]; !ok {
+			paths = append(paths, p.Path)
+		}
+		byPath[p.Path] = append(byPath[p.Path], p)
+	}
+	var numApplied int
+	for _, path := range paths {
+		ep, err := ilof.LoadEpisode(path)
+		if err != nil {
+			return err
+		}
+		var added []string
+		for _, p := range byPath[path] {
+			if p.Episode != ep.Episode {
+				return fmt.Errorf("%s: proposal is for episode %s, file has episode %s", path, p.Episode, ep.Episode)
+			} else if !ep.HasSegment(p.Segment) {
+				ep.AddSegment(p.Segment)
+				added = append(added, p.Segment)
+			}
+		}
+		if len(added) == 0 {
+			continue
+		}
+		if err := ilof.WriteEpisode(path, ep); err != nil {
+			return err
+		}
+		fmt.Printf("%s: add segments %s\n", path, strings.Join(added, ", "))
+		numApplied += len(added)
+	}
+	log.Printf("Applied %d segments to %d files", numApplied, len(paths))
+	return nil
+}
+
+// migrateTags moves the tags of each episode file that name segments into the
+// segments field.
+func migrateTags(names []string) error {
+	var numFiles int
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		moved := ep.MoveTagsToSegments(names)
+		if len(moved) == 0 {
+			return nil
+		}
+		numFiles++
+		fmt.Printf("%s: move tags %s to segments\n", path, strings.Join(moved, ", "))
+		return ilof.WriteEpisode(path, ep)
+	}); err != nil {
+		return err
+	}
+	log.Printf("Migrated %d files", numFiles)
+	return nil
+}
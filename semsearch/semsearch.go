@@ -0,0 +1,232 @@
+// Program semsearch searches the stored episode transcripts in the site
+// repository by meaning rather than by exact words, using an index of
+// embedding vectors for passages of the transcripts.
+//
+// First build or update the index:
+//
+//	semsearch -update
+//
+// Then search it:
+//
+//	semsearch "episodes where they discussed court packing"
+//
+// Updating only embeds the transcripts that have changed since the index was
+// last built. The index is saved as the update proceeds (see -save-every), and
+// if embedding fails, so an interrupted update can be resumed by running it
+// again. The index is stored outside the repository, in the user cache
+// directory by default (see -index).
+//
+// Embeddings are computed by an OpenAI-compatible API if EMBED_API_URL is set
+// to the base URL of the API (e.g., the URL of a local Ollama server,
+// http://localhost:11434/v1). If the API requires a key, set it in
+// EMBED_API_KEY. These may be set in the environment, the credentials file,
+// or the system keychain (see doctor). Otherwise, a built-in hashing embedder
+// is used, which needs no model but matches only words, not meanings. An
+// index can only be searched with the embedder that built it.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	indexPath  = flag.String("index", defaultIndexPath(), "Path of the embedding index")
+	doUpdate   = flag.Bool("update", false, "Build or update the index from the transcripts")
+	modelName  = flag.String("model", "text-embedding-3-small", "Embedding model to request from the API")
+	chunkSize  = flag.Int("chunk", 120, "With -update, number of words per indexed passage")
+	saveEvery  = flag.Int("save-every", 10, "With -update, save the index after embedding this many episodes (0 to save only at the end)")
+	numResults = flag.Int("n", 10, "Maximum number of passages to report")
+	perEpisode = flag.Int("per-episode", 2, "Maximum number of passages to report per episode (0 for no limit)")
+	doJSON     = flag.Bool("json", false, "Write matches as JSON")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s [options] -update
+       %s [options] <query>
+
+Search the stored transcripts of episodes in the site repository by meaning.
+
+Options:
+`, filepath.Base(os.Args[0]), filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+// A match is a passage found by a search.
+type match struct {
+	Episode ilof.Label `json:"episode"`
+	Date    string     `json:"airDate"`
+	Offset  float64    `json:"offsetSec"`
+	URL     string     `json:"url,omitempty"`
+	Score   float64    `json:"score"`
+	Text    string     `json:"text"`
+}
+
+func main() {
+	flag.Parse()
+	query := strings.TrimSpace(strings.Join(flag.Args(), " "))
+	if !*doUpdate && query == "" {
+		log.Fatal("You must provide a query, or -update to build the index")
+	} else if *indexPath == "" {
+		log.Fatal("You must provide an -index path")
+	}
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	emb := newEmbedder()
+	ctx := context.Background()
+
+	idx, err := ilof.LoadEmbeddingIndex(*indexPath)
+	if os.IsNotExist(err) {
+		idx = ilof.NewEmbeddingIndex(emb)
+	} else if err != nil {
+		log.Fatalf("Loading index: %v", err)
+	} else if idx.Model != emb.Name() {
+		if !*doUpdate {
+			log.Fatalf("Index was built with %q, but the embedder is %q; rebuild it with -update", idx.Model, emb.Name())
+		}
+		log.Printf("Rebuilding index for %q (was %q)", emb.Name(), idx.Model)
+		idx = ilof.NewEmbeddingIndex(emb)
+	}
+
+	eps := make(map[ilof.Label]*ilof.Episode)
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		eps[ep.Episode] = ep
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+
+	if *doUpdate {
+		if err := update(ctx, emb, idx, eps); err != nil {
+			log.Fatalf("Updating index: %v", err)
+		}
+		if query == "" {
+			return
+		}
+	}
+
+	ms, err := idx.Search(ctx, emb, query, *numResults, *perEpisode)
+	if err != nil {
+		log.Fatalf("Searching: %v", err)
+	}
+	out := []*match{}
+	for _, m := range ms {
+		r := &match{Episode: m.Episode, Offset: m.Start, Score: m.Score, Text: m.Text}
+		if ep, ok := eps[m.Episode]; ok {
+			r.Date = ep.Date.String()
+			if id, ok := ilof.YouTubeVideoID(ep.YouTubeURL); ok {
+				r.URL = ilof.VideoDeepLink(id, m.Start)
+			}
+		}
+		out = append(out, r)
+	}
+	if *doJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			log.Fatalf("Writing output: %v", err)
+		}
+		return
+	}
+	for _, m := range out {
+		fmt.Printf("Episode %s (%s) at %s, score %.3f\n  %s\n", m.Episode, m.Date, formatOffset(m.Offset), m.Score, m.Text)
+		if m.URL != "" {
+			fmt.Printf("  %s\n", m.URL)
+		}
+		fmt.Println()
+	}
+}
+
+// update embeds the transcripts of eps that are missing from idx or have
+// changed, removes episodes that no longer have transcripts, and saves idx.
+// The work done so far is saved every -save-every episodes, and before
+// reporting an error.
+func update(ctx context.Context, emb ilof.Embedder, idx *ilof.EmbeddingIndex, eps map[ilof.Label]*ilof.Episode) error {
+	save := func() error {
+		if err := os.MkdirAll(filepath.Dir(*indexPath), 0755); err != nil {
+			return err
+		}
+		return idx.Save(*indexPath)
+	}
+
+	var numAdded, numRemoved int
+	for label := range idx.Episodes {
+		if ep, ok := eps[label]; !ok || ep.TranscriptFile() == "" {
+			idx.Remove(label)
+			numRemoved++
+		}
+	}
+	for _, ep := range eps {
+		if ep.TranscriptFile() == "" {
+			continue
+		}
+		t, err := ilof.LoadEpisodeTranscript(ep)
+		if err != nil {
+			log.Printf("* Episode %s: %v", ep.Episode, err)
+			continue
+		}
+		chunks := ilof.TranscriptChunks(ep.Episode, t, *chunkSize, *chunkSize/4)
+		if idx.Current(ep.Episode, chunks) {
+			continue
+		}
+		if err := idx.Add(ctx, emb, ep.Episode, chunks); err != nil {
+			if numAdded != 0 || numRemoved != 0 {
+				if serr := save(); serr != nil {
+					log.Printf("* Saving index: %v", serr)
+				}
+			}
+			return err
+		}
+		numAdded++
+		log.Printf("- Indexed episode %s (%d passages)", ep.Episode, len(chunks))
+		if *saveEvery > 0 && numAdded%*saveEvery == 0 {
+			if err := save(); err != nil {
+				return fmt.Errorf("saving index: %w", err)
+			}
+		}
+	}
+	log.Printf("Indexed %d episodes, removed %d; the index has %d passages from %d episodes",
+		numAdded, numRemoved, len(idx.Chunks), len(idx.Episodes))
+	if numAdded == 0 && numRemoved == 0 {
+		return nil
+	}
+	return save()
+}
+
+// newEmbedder returns the embedder selected by the environment.
+func newEmbedder() ilof.Embedder {
+	if baseURL := ilof.EmbedAPIURL.Get(); baseURL != "" {
+		return &ilof.EmbeddingClient{
+			BaseURL: baseURL,
+			APIKey:  ilof.EmbedAPIKey.Get(),
+			Model:   *modelName,
+		}
+	}
+	return ilof.HashEmbedder{}
+}
+
+func defaultIndexPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ilof", "embeddings.json")
+}
+
+// formatOffset formats sec as H:MM:SS.
+func formatOffset(sec float64) string {
+	s := int(sec)
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, (s/60)%60, s%60)
+}
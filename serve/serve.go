@@ -0,0 +1,219 @@
+// Program serve serves the episode catalog of a site repository as JSON on a
+// local address, for previewing tooling changes without deploying the site.
+//
+// It serves the same endpoints as the production site:
+//
+//	/latest.json          {"latest": <episode>}
+//	/episodes.json        {"episodes": [<episode>, ...]}
+//	/episode/<label>.json {"episode": <episode>}
+//
+// and in addition:
+//
+//	/guests.json              {"guests": [<guest>, ...]}
+//	/search?q=<words>         {"query": "...", "results": [{"score": n, "episode": <episode>}, ...]}
+//	/transcript/<label>.json  the stored transcript for the episode, if any
+//
+// The catalog is loaded when the server starts; restart the server to pick up
+// changes to the repository.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	listenAddr = flag.String("addr", "localhost:8080", "Address to listen on")
+	maxResults = flag.Int("max-results", 25, "Maximum number of search results")
+)
+
+// A catalog holds the data loaded from the repository.
+type catalog struct {
+	episodes []*ilof.Episode // in order of air date
+	byLabel  map[ilof.Label]*ilof.Episode
+	guests   []*ilof.Guest
+	words    map[ilof.Label]map[string]bool // search terms for each episode
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	cat, err := loadCatalog()
+	if err != nil {
+		log.Fatalf("Loading catalog: %v", err)
+	}
+	log.Printf("Loaded %d episodes and %d guests", len(cat.episodes), len(cat.guests))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest.json", cat.handleLatest)
+	mux.HandleFunc("/episodes.json", cat.handleEpisodes)
+	mux.HandleFunc("/episode/", cat.handleEpisode)
+	mux.HandleFunc("/guests.json", cat.handleGuests)
+	mux.HandleFunc("/search", cat.handleSearch)
+	mux.HandleFunc("/transcript/", cat.handleTranscript)
+
+	log.Printf("Serving at http://%s", *listenAddr)
+	log.Fatal(http.ListenAndServe(*listenAddr, logRequests(mux)))
+}
+
+func loadCatalog() (*catalog, error) {
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		return nil, err
+	}
+	gidx := ilof.GuestIndex(guests)
+	cat := &catalog{
+		byLabel: make(map[ilof.Label]*ilof.Episode),
+		guests:  guests,
+		words:   make(map[ilof.Label]map[string]bool),
+	}
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		for _, g := range gidx[ep.Episode] {
+			ep.Guests = append(ep.Guests, g.Name)
+		}
+		cat.episodes = append(cat.episodes, ep)
+		cat.byLabel[ep.Episode] = ep
+
+		words := make(map[string]bool)
+		text := []string{string(ep.Episode), ep.Summary, ep.Topics, ep.Detail}
+		text = append(text, ep.Guests...)
+		text = append(text, ep.AllTags()...)
+		for _, w := range ilof.Words(strings.Join(text, " ")) {
+			words[w] = true
+		}
+		cat.words[ep.Episode] = words
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(cat.episodes, func(i, j int) bool {
+		return time.Time(cat.episodes[i].Date).Before(time.Time(cat.episodes[j].Date))
+	})
+	return cat, nil
+}
+
+func (c *catalog) handleLatest(w http.ResponseWriter, r *http.Request) {
+	if len(c.episodes) == 0 {
+		http.Error(w, "no episodes", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, struct {
+		Latest *ilof.Episode `json:"latest"`
+	}{Latest: c.episodes[len(c.episodes)-1]})
+}
+
+func (c *catalog) handleEpisodes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, struct {
+		Episodes []*ilof.Episode `json:"episodes"`
+	}{Episodes: c.episodes})
+}
+
+func (c *catalog) handleEpisode(w http.ResponseWriter, r *http.Request) {
+	ep, ok := c.lookup(r.URL.Path, "/episode/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, struct {
+		Episode *ilof.Episode `json:"episode"`
+	}{Episode: ep})
+}
+
+func (c *catalog) handleGuests(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, struct {
+		Guests []*ilof.Guest `json:"guests"`
+	}{Guests: c.guests})
+}
+
+type searchResult struct {
+	Score   int           `json:"score"`
+	Episode *ilof.Episode `json:"episode"`
+}
+
+func (c *catalog) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.FormValue("q")
+	terms := ilof.Words(query)
+	if len(terms) == 0 {
+		http.Error(w, "missing search query", http.StatusBadRequest)
+		return
+	}
+
+	// Score each episode by the number of query terms it contains, and rank
+	// more recent episodes first among those with equal scores.
+	var results []*searchResult
+	for i := len(c.episodes) - 1; i >= 0; i-- {
+		ep := c.episodes[i]
+		words := c.words[ep.Episode]
+		var score int
+		for _, t := range terms {
+			if words[t] {
+				score++
+			}
+		}
+		if score > 0 {
+			results = append(results, &searchResult{Score: score, Episode: ep})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > *maxResults {
+		results = results[:*maxResults]
+	}
+	writeJSON(w, struct {
+		Query   string          `json:"query"`
+		Results []*searchResult `json:"results"`
+	}{Query: query, Results: results})
+}
+
+func (c *catalog) handleTranscript(w http.ResponseWriter, r *http.Request) {
+	ep, ok := c.lookup(r.URL.Path, "/transcript/")
+	if !ok || ep.TranscriptFile() == "" {
+		http.NotFound(w, r)
+		return
+	}
+	t, err := ilof.LoadEpisodeTranscript(ep)
+	if err != nil {
+		log.Printf("* Loading transcript for episode %s: %v", ep.Episode, err)
+		http.Error(w, "transcript not available", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, t)
+}
+
+// lookup returns the episode whose label is given by a request path of the
+// form <prefix><label>.json.
+func (c *catalog) lookup(path, prefix string) (*ilof.Episode, bool) {
+	label := strings.TrimPrefix(path, prefix)
+	if !strings.HasSuffix(label, ".json") {
+		return nil, false
+	}
+	ep, ok := c.byLabel[ilof.Label(strings.TrimSuffix(label, ".json"))]
+	return ep, ok
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("* Writing response: %v", err)
+	}
+}
+
+func logRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		h.ServeHTTP(w, r)
+		log.Printf("%s %s [%v]", r.Method, r.URL, time.Since(start).Round(time.Millisecond))
+	})
+}
@@ -0,0 +1,230 @@
+// Program sitemap generates a sitemap and structured data (JSON-LD) for the
+// episodes in the site repository.
+//
+// The sitemap lists the home page and the page for each episode. The
+// structured data file is a JSON object mapping each episode label to a
+// schema.org PodcastEpisode record, with a VideoObject for the episode video
+// if there is one. The site templates can embed the record for an episode
+// page, e.g.:
+//
+//	<script type="application/ld+json">
+//	{{ site.data.structured[page.episode] | jsonify }}
+//	</script>
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	sitemapPath = flag.String("sitemap", "sitemap.xml", "Write the sitemap to this file (empty to skip)")
+	jsonldPath  = flag.String("jsonld", "_data/structured.json", "Write structured data to this file (empty to skip)")
+)
+
+const seriesName = "In Lieu of Fun"
+
+func main() {
+	flag.Parse()
+	if *sitemapPath == "" && *jsonldPath == "" {
+		log.Fatal("Nothing to do: both -sitemap and -jsonld are empty")
+	}
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	gidx := ilof.GuestIndex(guests)
+
+	var eps []*ilof.Episode
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		for _, g := range gidx[ep.Episode] {
+			ep.Guests = append(ep.Guests, g.Name)
+		}
+		eps = append(eps, ep)
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	sort.Slice(eps, func(i, j int) bool {
+		return time.Time(eps[i].Date).Before(time.Time(eps[j].Date))
+	})
+	log.Printf("Found %d episodes", len(eps))
+
+	var written []string
+	if *sitemapPath != "" {
+		if err := atomicfile.WriteData(*sitemapPath, sitemapXML(eps), 0644); err != nil {
+			log.Fatalf("Writing sitemap: %v", err)
+		}
+		log.Printf("Wrote sitemap to %q", *sitemapPath)
+		written = append(written, *sitemapPath)
+	}
+	if *jsonldPath != "" {
+		data, err := structuredData(eps)
+		if err != nil {
+			log.Fatalf("Encoding structured data: %v", err)
+		}
+		if err := atomicfile.WriteData(*jsonldPath, data, 0644); err != nil {
+			log.Fatalf("Writing structured data: %v", err)
+		}
+		log.Printf("Wrote structured data to %q", *jsonldPath)
+		written = append(written, *jsonldPath)
+	}
+	if err := ilof.RecordGenerated(repo.ManifestFile, "sitemap", written...); err != nil {
+		log.Fatalf("Updating manifest: %v", err)
+	}
+}
+
+type urlEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// sitemapXML renders a sitemap for the home page and eps.
+func sitemapXML(eps []*ilof.Episode) []byte {
+	set := struct {
+		XMLName xml.Name    `xml:"urlset"`
+		NS      string      `xml:"xmlns,attr"`
+		URLs    []*urlEntry `xml:"url"`
+	}{NS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+
+	home := &urlEntry{Loc: ilof.BaseURL + "/"}
+	set.URLs = append(set.URLs, home)
+	for _, ep := range eps {
+		mod := ep.Date.String()
+		if ep.CaptionDate != nil && time.Time(*ep.CaptionDate).After(time.Time(ep.Date)) {
+			mod = ep.CaptionDate.String()
+		}
+		if mod > home.LastMod {
+			home.LastMod = mod
+		}
+		set.URLs = append(set.URLs, &urlEntry{Loc: ep.PageURL(), LastMod: mod})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	enc.Encode(set) // cannot fail for this type
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+// structuredData renders a JSON object mapping the label of each episode in
+// eps to its schema.org record.
+func structuredData(eps []*ilof.Episode) ([]byte, error) {
+	m := make(map[ilof.Label]interface{})
+	for _, ep := range eps {
+		m[ep.Episode] = episodeLD(ep)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+type thing struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type mediaObject struct {
+	Type       string `json:"@type"`
+	ContentURL string `json:"contentUrl,omitempty"`
+	EmbedURL   string `json:"embedUrl,omitempty"`
+	Name       string `json:"name,omitempty"`
+	UploadDate string `json:"uploadDate,omitempty"`
+	Thumbnail  string `json:"thumbnailUrl,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+}
+
+type podcastEpisode struct {
+	Context       string       `json:"@context"`
+	Type          string       `json:"@type"`
+	URL           string       `json:"url"`
+	Name          string       `json:"name"`
+	EpisodeNumber string       `json:"episodeNumber"`
+	DatePublished string       `json:"datePublished"`
+	Description   string       `json:"description,omitempty"`
+	Keywords      string       `json:"keywords,omitempty"`
+	PartOfSeries  *thing       `json:"partOfSeries"`
+	Actors        []*thing     `json:"actor,omitempty"`
+	Audio         *mediaObject `json:"associatedMedia,omitempty"`
+	Video         *mediaObject `json:"video,omitempty"`
+	Transcript    string       `json:"transcript,omitempty"`
+}
+
+// episodeLD returns a schema.org PodcastEpisode record for ep.
+func episodeLD(ep *ilof.Episode) *podcastEpisode {
+	name := fmt.Sprintf("%s, Episode %s", seriesName, ep.Episode)
+	out := &podcastEpisode{
+		Context:       "https://schema.org",
+		Type:          "PodcastEpisode",
+		URL:           ep.PageURL(),
+		Name:          name,
+		EpisodeNumber: string(ep.Episode),
+		DatePublished: ep.Date.String(),
+		Description:   ep.Summary,
+		Keywords:      strings.Join(ep.AllTags(), ", "),
+		PartOfSeries:  &thing{Type: "PodcastSeries", Name: seriesName, URL: ilof.BaseURL},
+		Transcript:    ilof.TranscriptPageURL(ep),
+	}
+	if out.Description == "" {
+		out.Description = ep.Topics
+	}
+	for _, g := range ep.Guests {
+		out.Actors = append(out.Actors, &thing{Type: "Person", Name: g})
+	}
+	dur := isoDuration(ep.Duration)
+	if u := ep.AudioFileURL; u != "" {
+		out.Audio = &mediaObject{Type: "AudioObject", ContentURL: u, Duration: dur}
+	}
+	if id, ok := ilof.YouTubeVideoID(ep.YouTubeURL); ok {
+		out.Video = &mediaObject{
+			Type:       "VideoObject",
+			Name:       name,
+			ContentURL: ep.YouTubeURL,
+			EmbedURL:   "https://www.youtube.com/embed/" + id,
+			UploadDate: ep.Date.String(),
+			Thumbnail:  "https://i.ytimg.com/vi/" + id + "/hqdefault.jpg",
+			Duration:   dur,
+		}
+	}
+	return out
+}
+
+// isoDuration converts a duration string like "1h5m" to ISO 8601 format,
+// e.g., "PT1H5M". It returns "" if s is empty or invalid.
+func isoDuration(s string) string {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString("PT")
+	if h := int(d.Hours()); h > 0 {
+		fmt.Fprintf(&buf, "%dH", h)
+	}
+	if m := int(d.Minutes()) % 60; m > 0 {
+		fmt.Fprintf(&buf, "%dM", m)
+	}
+	if s := int(d.Seconds()) % 60; s > 0 || buf.Len() == 2 {
+		fmt.Fprintf(&buf, "%dS", s)
+	}
+	return buf.String()
+}
@@ -0,0 +1,198 @@
+// Program stats renders a report of statistics about the episodes and guests
+// recorded in the site repository, as markdown, HTML, or JSON.
+//
+// Episodes that list the hosts present in their "hosts" field also yield a
+// report of host attendance: how often each host appeared, each host's
+// longest absence, and how many episodes each pair of hosts hosted together.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	format  = flag.String("format", "markdown", "Output format (markdown, html, json)")
+	year    = flag.Int("year", 0, "Report only on episodes aired in this year")
+	topN    = flag.Int("top", 20, "Number of top guests to report")
+	title   = flag.String("title", "", "Report title (default depends on -year)")
+	outPath = flag.String("o", "", "Write output to this file (default stdout)")
+)
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	var eps []*ilof.Episode
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		if *year == 0 || time.Time(ep.Date).Year() == *year {
+			eps = append(eps, ep)
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	log.Printf("Loaded %d episodes and %d guests", len(eps), len(guests))
+
+	if *title == "" {
+		*title = "The State of the Fun"
+		if *year != 0 {
+			*title = fmt.Sprintf("The State of the Fun, %d", *year)
+		}
+	}
+	report := struct {
+		Title string
+		*ilof.Stats
+	}{Title: *title, Stats: ilof.ComputeStats(eps, guests, *topN)}
+
+	out := io.Writer(os.Stdout)
+	var outFile *os.File
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatalf("Creating output: %v", err)
+		}
+		out, outFile = f, f
+	}
+
+	switch *format {
+	case "markdown":
+		err = mdReport.Execute(out, report)
+	case "html":
+		err = htmlReport.Execute(out, report)
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report.Stats)
+	default:
+		log.Fatalf("Unknown output format %q", *format)
+	}
+	if err != nil {
+		log.Fatalf("Writing report: %v", err)
+	}
+	if outFile != nil {
+		if err := outFile.Close(); err != nil {
+			log.Fatalf("Closing output: %v", err)
+		}
+	}
+}
+
+var funcs = map[string]interface{}{
+	"hours":   func(h float64) string { return fmt.Sprintf("%.1f", h) },
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", 100*f) },
+}
+
+var mdReport = template.Must(template.New("md").Funcs(funcs).Parse(`# {{.Title}}
+
+From {{.First}} to {{.Last}} there were **{{.Episodes}} episodes**
+({{.Specials}} specials), with {{.GuestVisits}} appearances by {{.NumGuests}} guests.
+{{- if .NumTimed}} The {{.NumTimed}} episodes with a recorded duration
+total **{{hours .Hours}} hours**.{{end}}
+
+## Episodes per season
+
+| Season | Episodes | Hours |
+|---|---|---|
+{{range .BySeason}}| {{if .Season}}{{.Season}}{{else}}—{{end}} | {{.Episodes}} | {{hours .Hours}} |
+{{end}}
+## Most frequent guests
+
+| Guest | Appearances |
+|---|---|
+{{range .TopGuests}}| {{.Name}} | {{.Count}} |
+{{end}}
+{{- with .Hosts}}
+## Host attendance
+
+Of the {{.Recorded}} episodes that record their hosts:
+
+| Host | Episodes | Attendance | Longest absence |
+|---|---|---|---|
+{{range .Hosts}}| {{.Name}} | {{.Episodes}} | {{percent .Rate}} | {{with .LongestAbsence}}{{.Episodes}} ({{.First}}{{if ne .First .Last}}–{{.Last}}{{end}}{{if .Ongoing}}, ongoing{{end}}){{else}}—{{end}} |
+{{end}}
+| Hosts | Episodes together |
+|---|---|
+{{range .Pairs}}| {{index .Hosts 0}} & {{index .Hosts 1}} | {{.Episodes}} |
+{{end}}{{end}}
+{{- with .Segments}}
+## Recurring segments
+
+| Segment | Episodes |
+|---|---|
+{{range .}}| {{.Name}} | {{.Count}} |
+{{end}}{{end}}
+## Tags
+
+| Tag | Episodes |
+|---|---|
+{{range .Tags}}| {{.Name}} | {{.Count}} |
+{{end}}`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+
+<p>From {{.First}} to {{.Last}} there were <b>{{.Episodes}} episodes</b>
+({{.Specials}} specials), with {{.GuestVisits}} appearances by {{.NumGuests}} guests.
+{{- if .NumTimed}} The {{.NumTimed}} episodes with a recorded duration
+total <b>{{hours .Hours}} hours</b>.{{end}}</p>
+
+<h2>Episodes per season</h2>
+<table>
+<tr><th>Season</th><th>Episodes</th><th>Hours</th></tr>
+{{range .BySeason}}<tr><td>{{if .Season}}{{.Season}}{{else}}—{{end}}</td><td>{{.Episodes}}</td><td>{{hours .Hours}}</td></tr>
+{{end}}</table>
+
+<h2>Most frequent guests</h2>
+<table>
+<tr><th>Guest</th><th>Appearances</th></tr>
+{{range .TopGuests}}<tr><td>{{.Name}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+{{- with .Hosts}}
+
+<h2>Host attendance</h2>
+<p>Of the {{.Recorded}} episodes that record their hosts:</p>
+<table>
+<tr><th>Host</th><th>Episodes</th><th>Attendance</th><th>Longest absence</th></tr>
+{{range .Hosts}}<tr><td>{{.Name}}</td><td>{{.Episodes}}</td><td>{{percent .Rate}}</td><td>{{with .LongestAbsence}}{{.Episodes}} ({{.First}}{{if ne .First .Last}}–{{.Last}}{{end}}{{if .Ongoing}}, ongoing{{end}}){{else}}—{{end}}</td></tr>
+{{end}}</table>
+<table>
+<tr><th>Hosts</th><th>Episodes together</th></tr>
+{{range .Pairs}}<tr><td>{{index .Hosts 0}} &amp; {{index .Hosts 1}}</td><td>{{.Episodes}}</td></tr>
+{{end}}</table>
+{{- end}}
+{{- with .Segments}}
+
+<h2>Recurring segments</h2>
+<table>
+<tr><th>Segment</th><th>Episodes</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+{{- end}}
+
+<h2>Tags</h2>
+<table>
+<tr><th>Tag</th><th>Episodes</th></tr>
+{{range .Tags}}<tr><td>{{.Name}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
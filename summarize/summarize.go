@@ -0,0 +1,212 @@
+// Program summarize proposes summaries for episodes that lack one, using an
+// OpenAI-compatible language model API.
+//
+// This tool is opt-in: it does nothing unless LLM_API_URL is set to the base
+// URL of the API (e.g., https://api.openai.com/v1). If the API requires a key,
+// set it in LLM_API_KEY. These may be set in the environment, the credentials
+// file, or the system keychain (see doctor).
+//
+// Generated summaries are never written directly to the episode files.
+// Instead, they are written to a proposals file for review:
+//
+//	summarize -o proposals.json
+//
+// Edit or delete the proposals as needed, then apply them:
+//
+//	summarize -apply proposals.json
+//
+// Only proposals whose "reviewed" field is set to true are applied.
+//
+// If the API fails for an episode, the episode is skipped, and the proposals
+// for the others are still written; summarize then exits with status 1.
+//
+// Episodes whose summaries were filled in automatically by epdate (marked
+// "auto-summary: true") are treated as lacking a summary.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath   = flag.String("o", "", "Write proposals to this file (default stdout)")
+	applyPath = flag.String("apply", "", "Apply the reviewed proposals in this file")
+	modelName = flag.String("model", "gpt-4o-mini", "Model name to request")
+	maxInput  = flag.Int("max-input", 12000, "Maximum characters of source text to send per episode")
+	maxCount  = flag.Int("n", 10, "Maximum number of episodes to summarize (0 for no limit)")
+	labelFlag = flag.String("episode", "", "Summarize only this episode")
+)
+
+const systemPrompt = `You write summaries for episodes of "In Lieu of Fun", a webcast
+in which the hosts talk with guests about law, politics, books, and current
+events. Given the text of an episode, write a 2-3 sentence summary of what
+was discussed. Write in the present tense, name the guests if known, and do
+not speculate beyond the text. Reply with the summary only.`
+
+// A proposal is a generated summary for an episode, pending human review.
+type proposal struct {
+	Episode  ilof.Label `json:"episode"`
+	Path     string     `json:"path"`
+	Source   string     `json:"source"`   // "transcript" or "description"
+	Summary  string     `json:"summary"`  // generated text
+	Reviewed bool       `json:"reviewed"` // set to true by a human to apply
+}
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	if *applyPath != "" {
+		if err := applyProposals(*applyPath); err != nil {
+			log.Fatalf("Applying proposals: %v", err)
+		}
+		return
+	}
+
+	baseURL := ilof.LLMAPIURL.Get()
+	if baseURL == "" {
+		log.Fatal("No LLM_API_URL is set; summary generation is disabled")
+	}
+	cli := &ilof.ChatClient{
+		BaseURL: baseURL,
+		APIKey:  ilof.LLMAPIKey.Get(),
+		Model:   *modelName,
+	}
+	guests, err := ilof.LoadGuests(repo.GuestFile)
+	if err != nil {
+		log.Fatalf("Loading guests: %v", err)
+	}
+	gidx := ilof.GuestIndex(guests)
+
+	ctx := context.Background()
+	props := []*proposal{}
+	var numFailed int
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		if ep.Summary != "" && !ep.AutoSummary {
+			return nil
+		} else if *labelFlag != "" && string(ep.Episode) != *labelFlag {
+			return nil
+		} else if *maxCount > 0 && len(props) >= *maxCount {
+			return nil
+		}
+		for _, g := range gidx[ep.Episode] {
+			ep.Guests = append(ep.Guests, g.Name)
+		}
+		source, text := sourceText(ep)
+		if text == "" {
+			log.Printf("- Episode %s: no text to summarize", ep.Episode)
+			return nil
+		}
+		prompt := fmt.Sprintf("Episode %s, aired %s.\nGuests: %s\n\n%s",
+			ep.Episode, ep.Date, strings.Join(ep.Guests, ", "), text)
+		summary, err := cli.Complete(ctx, systemPrompt, prompt)
+		if err != nil {
+			// Keep the proposals generated so far, rather than losing them to
+			// one failed request.
+			log.Printf("* Episode %s: %v", ep.Episode, err)
+			numFailed++
+			return nil
+		}
+		log.Printf("Episode %s: generated summary from %s", ep.Episode, source)
+		props = append(props, &proposal{
+			Episode: ep.Episode,
+			Path:    path,
+			Source:  source,
+			Summary: summary,
+		})
+		return nil
+	}); err != nil {
+		log.Fatalf("Generating summaries: %v", err)
+	}
+
+	data, err := json.MarshalIndent(props, "", "  ")
+	if err != nil {
+		log.Fatalf("Encoding proposals: %v", err)
+	}
+	data = append(data, '\n')
+	if *outPath == "" {
+		os.Stdout.Write(data)
+	} else if err := atomicfile.WriteData(*outPath, data, 0644); err != nil {
+		log.Fatalf("Writing proposals: %v", err)
+	}
+	log.Printf("Proposed %d summaries; review them and set \"reviewed\": true to apply", len(props))
+	if numFailed != 0 {
+		log.Fatalf("Failed to generate summaries for %d episodes", numFailed)
+	}
+}
+
+// sourceText returns the text to summarize for ep, preferring its stored
+// transcript to its description, and a label for the source.
+func sourceText(ep *ilof.Episode) (source, text string) {
+	if t, err := ilof.LoadEpisodeTranscript(ep); err != nil {
+		log.Printf("* Episode %s: %v", ep.Episode, err)
+	} else if t != nil {
+		if text = t.Text(); text != "" {
+			return "transcript", truncate(text, *maxInput)
+		}
+	}
+	text = strings.TrimSpace(strings.Join([]string{ep.Topics, ep.Detail}, "\n"))
+	return "description", truncate(text, *maxInput)
+}
+
+// truncate returns a prefix of s of at most n bytes, not splitting a rune.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// applyProposals writes the reviewed summaries in the file at path to the
+// episode files. Episodes that have acquired a summary since the proposal
+// was generated are not changed.
+func applyProposals(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var props []*proposal
+	if err := json.Unmarshal(data, &props); err != nil {
+		return fmt.Errorf("decoding proposals: %w", err)
+	}
+	var numApplied, numSkipped int
+	for _, p := range props {
+		if !p.Reviewed {
+			numSkipped++
+			continue
+		}
+		ep, err := ilof.LoadEpisode(p.Path)
+		if err != nil {
+			return err
+		} else if ep.Episode != p.Episode {
+			return fmt.Errorf("%s: proposal is for episode %s, file has episode %s", p.Path, p.Episode, ep.Episode)
+		} else if ep.Summary != "" && !ep.AutoSummary {
+			log.Printf("- Episode %s already has a summary; skipped", ep.Episode)
+			continue
+		}
+		ep.Summary = strings.TrimSpace(p.Summary)
+		ep.AutoSummary = false
+		if err := ilof.WriteEpisode(p.Path, ep); err != nil {
+			return err
+		}
+		fmt.Printf("%s: set summary\n", p.Path)
+		numApplied++
+	}
+	log.Printf("Applied %d summaries (%d not reviewed)", numApplied, numSkipped)
+	return nil
+}
@@ -0,0 +1,100 @@
+// Program tagpages generates a page for each episode tag, and a tag cloud
+// index of all the tags, in the pages directory of the site repository.
+//
+// The page for a tag lists the episodes with that tag in order of air date,
+// and is written to <dir>/tag-<tag>.md with the permalink /tag/<tag>/. The
+// index is written to <dir>/tags.md with the permalink /tags/, and links to
+// each tag page with the CSS class tag-cloud-N, where N runs from 1 for the
+// least used tags to 5 for the most used.
+//
+// The titles and descriptions of the pages are taken from the tag registry
+// (by default _data/tags.yaml). Tags that are not in the registry get a page
+// titled with the tag itself, and are reported so that they can be added.
+// Tags marked hidden in the registry get no page.
+//
+// As with seasonpages, only pages whose contents have changed are rewritten,
+// generated pages for tags no longer in use are removed, and pages not
+// written by this program are never modified; they are reported as conflicts
+// instead. With -check, nothing is written, and the exit status is 3 if any
+// page is out of date or in conflict.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	pageDir  = flag.String("dir", "_pages", "Write pages to this directory, relative to the repository root")
+	layout   = flag.String("layout", "page", "Jekyll layout for the generated pages")
+	doCheck  = flag.Bool("check", false, "Report out-of-date pages without writing them")
+	doDryRun = flag.Bool("dry-run", false, "Print the pages to stdout instead of writing them")
+)
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	reg, err := ilof.LoadTagRegistry(repo.TagFile)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Loading tag registry: %v", err)
+	}
+	var eps []*ilof.Episode
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		eps = append(eps, ep)
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+
+	tags := ilof.TagPages(eps, reg)
+	ps := &ilof.PageSet{
+		Dir:       *pageDir,
+		Marker:    ilof.TagPageMarker,
+		Pages:     make(map[string][]byte),
+		Manifest:  repo.ManifestFile,
+		Generator: "tagpages",
+		Kind:      "tag",
+	}
+	used := make(map[string]bool)
+	for _, p := range tags {
+		used[p.Tag] = true
+		if p.Info == nil {
+			log.Printf("- Tag %q is not registered in %s", p.Tag, repo.TagFile)
+		}
+		data, err := p.Markdown(*layout)
+		if err != nil {
+			log.Fatalf("Rendering tag %q: %v", p.Tag, err)
+		}
+		ps.Pages[p.FileName()] = data
+	}
+	for _, t := range reg {
+		if !used[t.Tag] && !t.Hidden {
+			log.Printf("- Registered tag %q is not used by any episode", t.Tag)
+		}
+	}
+	index, err := ilof.TagIndexMarkdown(tags, *layout)
+	if err != nil {
+		log.Fatalf("Rendering tag index: %v", err)
+	}
+	ps.Pages[ilof.TagIndexFile] = index
+
+	mode := ilof.ApplyPages
+	switch {
+	case *doDryRun:
+		mode = ilof.PrintPages
+	case *doCheck:
+		mode = ilof.CheckPages
+	}
+	n, err := ps.Update(mode, os.Stdout)
+	if err != nil {
+		log.Fatalf("Updating pages: %v", err)
+	} else if mode == ilof.CheckPages && n != 0 {
+		os.Exit(3)
+	}
+}
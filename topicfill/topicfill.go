@@ -0,0 +1,169 @@
+// Program topicfill proposes topics for episodes that lack them, by keyword
+// extraction from their transcripts and descriptions. This is meant to fill
+// in the topics of the older episodes in the archive, so that site search
+// can find them.
+//
+// Like summarize, proposed topics are never written directly to the episode
+// files. Instead, they are written to a proposals file for review:
+//
+//	topicfill -o proposals.json
+//
+// Edit or delete the proposals as needed, then apply them:
+//
+//	topicfill -apply proposals.json
+//
+// Only proposals whose "reviewed" field is set to true are applied.
+//
+// Keywords are weighted against the whole catalog, so that phrases common to
+// many episodes (such as the names of the hosts) are not proposed.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath       = flag.String("o", "", "Write proposals to this file (default stdout)")
+	applyPath     = flag.String("apply", "", "Apply the reviewed proposals in this file")
+	numKeywords   = flag.Int("k", 6, "Number of keywords to propose per episode")
+	labelFlag     = flag.String("episode", "", "Propose topics only for this episode")
+	doTranscripts = flag.Bool("transcripts", true, "Use stored transcripts where available")
+)
+
+// A proposal is a topics line proposed for an episode, pending human review.
+type proposal struct {
+	Episode  ilof.Label `json:"episode"`
+	Path     string     `json:"path"`
+	Source   string     `json:"source"`   // "transcript" or "description"
+	Topics   string     `json:"topics"`   // proposed text
+	Reviewed bool       `json:"reviewed"` // set to true by a human to apply
+}
+
+func main() {
+	flag.Parse()
+	if *numKeywords <= 0 {
+		log.Fatal("The -k value must be positive")
+	}
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+	if *applyPath != "" {
+		if err := applyProposals(*applyPath); err != nil {
+			log.Fatalf("Applying proposals: %v", err)
+		}
+		return
+	}
+
+	var eps []*ilof.Episode
+	paths := make(map[ilof.Label]string)
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		eps = append(eps, ep)
+		paths[ep.Episode] = path
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	corpus := ilof.EpisodeCorpus(eps)
+	transcripts := make(map[ilof.Label]string)
+	for _, ep := range eps {
+		if *doTranscripts {
+			t, err := ilof.LoadEpisodeTranscript(ep)
+			if err != nil {
+				log.Printf("* Episode %s: %v", ep.Episode, err)
+				continue
+			} else if t != nil {
+				transcripts[ep.Episode] = t.Text()
+				corpus.Add(ep.Episode, transcripts[ep.Episode])
+			}
+		}
+	}
+	log.Printf("Loaded %d episodes (%d with transcripts)", corpus.Len(), len(transcripts))
+
+	props := []*proposal{}
+	for _, ep := range eps {
+		if ep.Topics != "" {
+			continue
+		} else if *labelFlag != "" && string(ep.Episode) != *labelFlag {
+			continue
+		}
+		source, text := "transcript", transcripts[ep.Episode]
+		if text == "" {
+			source = "description"
+			text = strings.TrimSpace(strings.Join([]string{ep.Summary, ep.Detail}, "\n"))
+		}
+		if text == "" {
+			log.Printf("- Episode %s: no text to extract topics from", ep.Episode)
+			continue
+		}
+		keys := corpus.KeyPhrases(text, *numKeywords)
+		if len(keys) == 0 {
+			log.Printf("- Episode %s: no keywords found", ep.Episode)
+			continue
+		}
+		props = append(props, &proposal{
+			Episode: ep.Episode,
+			Path:    paths[ep.Episode],
+			Source:  source,
+			Topics:  strings.Join(keys, ", "),
+		})
+	}
+
+	data, err := json.MarshalIndent(props, "", "  ")
+	if err != nil {
+		log.Fatalf("Encoding proposals: %v", err)
+	}
+	data = append(data, '\n')
+	if *outPath == "" {
+		os.Stdout.Write(data)
+	} else if err := atomicfile.WriteData(*outPath, data, 0644); err != nil {
+		log.Fatalf("Writing proposals: %v", err)
+	}
+	log.Printf("Proposed topics for %d episodes; review them and set \"reviewed\": true to apply", len(props))
+}
+
+// applyProposals writes the reviewed topics in the file at path to the
+// episode files. Episodes that have acquired topics since the proposal was
+// generated are not changed.
+func applyProposals(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var props []*proposal
+	if err := json.Unmarshal(data, &props); err != nil {
+		return fmt.Errorf("decoding proposals: %w", err)
+	}
+	var numApplied, numSkipped int
+	for _, p := range props {
+		if !p.Reviewed {
+			numSkipped++
+			continue
+		}
+		ep, err := ilof.LoadEpisode(p.Path)
+		if err != nil {
+			return err
+		} else if ep.Episode != p.Episode {
+			return fmt.Errorf("%s: proposal is for episode %s, file has episode %s", p.Path, p.Episode, ep.Episode)
+		} else if ep.Topics != "" {
+			log.Printf("- Episode %s already has topics; skipped", ep.Episode)
+			continue
+		}
+		ep.Topics = strings.TrimSpace(p.Topics)
+		if err := ilof.WriteEpisode(p.Path, ep); err != nil {
+			return err
+		}
+		fmt.Printf("%s: set topics\n", p.Path)
+		numApplied++
+	}
+	log.Printf("Applied %d topics (%d not reviewed)", numApplied, numSkipped)
+	return nil
+}
@@ -0,0 +1,121 @@
+// Program topics clusters the episodes in the back catalog by the content of
+// their summaries and transcripts, and proposes a tag for the episodes of each
+// cluster.
+//
+// Like segments, this is a two-step process. First, run topics to write the
+// proposed tags to a file:
+//
+//	topics -o proposals.json
+//
+// Each proposal records the episode, the proposed tag, and as evidence the
+// characteristic terms of its cluster. The proposed tag is derived from the
+// leading terms of the cluster; review the file, rename the tags as needed,
+// and delete any proposals that should not be applied. Then apply the
+// remaining proposals to the episode files with segments:
+//
+//	segments -apply proposals.json
+//
+// Clustering is deterministic, so repeated runs over the same catalog produce
+// the same proposals.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath       = flag.String("o", "", "Write proposals to this file (default stdout)")
+	numClusters   = flag.Int("k", 20, "Number of clusters to find")
+	minSize       = flag.Int("min-size", 3, "Minimum number of episodes in a cluster to propose a tag")
+	numTagTerms   = flag.Int("tag-terms", 1, "Number of leading cluster terms to use in a proposed tag")
+	doTranscripts = flag.Bool("transcripts", true, "Include stored transcripts in the episode text")
+)
+
+// A proposal is a tag proposed for an episode, in the format read by
+// segments -apply.
+type proposal struct {
+	Episode  ilof.Label `json:"episode"`
+	Path     string     `json:"path"`
+	Tag      string     `json:"tag"`
+	Evidence string     `json:"evidence"`
+}
+
+func main() {
+	flag.Parse()
+	if *numClusters <= 0 || *numTagTerms <= 0 {
+		log.Fatal("The -k and -tag-terms values must be positive")
+	}
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	var eps []*ilof.Episode
+	paths := make(map[ilof.Label]string)
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(path string, ep *ilof.Episode) error {
+		eps = append(eps, ep)
+		paths[ep.Episode] = path
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	byLabel := make(map[ilof.Label]*ilof.Episode)
+	corpus := ilof.EpisodeCorpus(eps)
+	for _, ep := range eps {
+		byLabel[ep.Episode] = ep
+		if *doTranscripts {
+			t, err := ilof.LoadEpisodeTranscript(ep)
+			if err != nil {
+				log.Printf("* Episode %s: %v", ep.Episode, err)
+				continue
+			} else if t != nil {
+				corpus.Add(ep.Episode, t.Text())
+			}
+		}
+	}
+	log.Printf("Loaded %d episodes", corpus.Len())
+
+	props := []*proposal{}
+	for i, cl := range corpus.Cluster(*numClusters) {
+		log.Printf("Cluster %d: %d episodes; terms: %s", i+1, len(cl.Episodes), strings.Join(cl.Terms, ", "))
+		if len(cl.Episodes) < *minSize || len(cl.Terms) == 0 {
+			continue
+		}
+		lead := cl.Terms[:min(*numTagTerms, len(cl.Terms))]
+		tag := strings.Join(ilof.Words(strings.Join(lead, " ")), "-")
+		evidence := fmt.Sprintf("cluster %d of %d episodes: %s", i+1, len(cl.Episodes), strings.Join(cl.Terms, ", "))
+		for _, label := range cl.Episodes {
+			if byLabel[label].HasTag(tag) {
+				continue
+			}
+			props = append(props, &proposal{
+				Episode:  label,
+				Path:     paths[label],
+				Tag:      tag,
+				Evidence: evidence,
+			})
+		}
+	}
+	sort.SliceStable(props, func(i, j int) bool { return props[i].Path < props[j].Path })
+	log.Printf("Proposed %d tags", len(props))
+
+	data, err := json.MarshalIndent(props, "", "  ")
+	if err != nil {
+		log.Fatalf("Encoding proposals: %v", err)
+	}
+	data = append(data, '\n')
+	if *outPath == "" {
+		os.Stdout.Write(data)
+	} else if err := atomicfile.WriteData(*outPath, data, 0644); err != nil {
+		log.Fatalf("Writing proposals: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Program transcriptfeed writes an RSS feed of the episode transcripts most
+// recently added or updated in the site repository, so that readers of the
+// transcripts can follow them separately from the main episode feed.
+//
+// Each item links to the transcript page on the site, and its description
+// links to the episode page and to the video, starting where the first
+// caption begins. The time of an item is the time of the last commit that
+// changed the transcript file, or the caption date recorded in the episode
+// file if that is later (or the file has not been committed). Episodes with
+// neither are listed as of their air date.
+//
+// An updated transcript gets a new item, so that subscribers see the update.
+//
+// If a transcript has been exported for podcast apps (see podtranscripts),
+// its item advertises the exported files with podcast:transcript elements,
+// and the exported chapters of the episode, if any, with a podcast:chapters
+// element.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath  = flag.String("o", "transcripts.xml", "Write the feed to this file")
+	maxItems = flag.Int("max", 50, "Maximum number of items in the feed (0 for all)")
+	doDryRun = flag.Bool("dry-run", false, "Print the feed to stdout instead of writing it")
+)
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	var ups []*ilof.TranscriptUpdate
+	var dirs []string
+	seenDir := make(map[string]bool)
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		if ep.Transcript == "" {
+			return nil
+		}
+		up := &ilof.TranscriptUpdate{Episode: ep, Updated: time.Time(ep.Date)}
+		if ep.CaptionDate != nil {
+			up.Updated = time.Time(*ep.CaptionDate)
+		}
+		ups = append(ups, up)
+		if dir := path.Dir(transcriptPath(ep)); !seenDir[dir] {
+			seenDir[dir] = true
+			dirs = append(dirs, dir)
+		}
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+
+	// Use the commit times of the transcript files where they are later.
+	commits, err := repo.LastCommitTimes(dirs...)
+	if err != nil {
+		log.Printf("* Reading history of %s: %v", strings.Join(dirs, ", "), err)
+	}
+	for _, up := range ups {
+		if t, ok := commits[transcriptPath(up.Episode)]; ok && t.After(up.Updated) {
+			up.Updated = t
+		}
+	}
+	log.Printf("Found %d episodes with transcripts", len(ups))
+
+	// Only the most recent updates are reported, so load the transcripts and
+	// look for exported files only for those.
+	ups = ilof.RecentTranscriptUpdates(ups, *maxItems)
+	for _, up := range ups {
+		path := up.Episode.TranscriptFile()
+		if path == "" {
+			continue
+		}
+		t, err := ilof.LoadTranscript(path)
+		if err != nil {
+			log.Printf("* Episode %s: %v", up.Episode.Episode, err)
+		}
+		up.Transcript = t
+		up.Files = exportedFiles(path)
+		if chapPath, _ := ilof.PodcastChapterPaths(path); repo.FileExists(chapPath) {
+			up.ChaptersURL = ilof.BaseURL + "/" + chapPath
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ilof.WriteTranscriptFeed(&buf, ups, *maxItems); err != nil {
+		log.Fatalf("Encoding feed: %v", err)
+	}
+	if *doDryRun {
+		os.Stdout.Write(buf.Bytes())
+	} else if err := atomicfile.WriteData(*outPath, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("Writing feed: %v", err)
+	} else if err := ilof.RecordGenerated(repo.ManifestFile, "transcriptfeed", *outPath); err != nil {
+		log.Fatalf("Updating manifest: %v", err)
+	}
+}
+
+// exportedFiles returns the podcast transcript files exported for the JSON
+// transcript at path that exist.
+func exportedFiles(path string) []*ilof.TranscriptFile {
+	jsonPath, srtPath := ilof.PodcastTranscriptPaths(path)
+	var out []*ilof.TranscriptFile
+	for _, f := range []struct{ path, mimeType string }{
+		{jsonPath, ilof.PodcastTranscriptJSON},
+		{srtPath, ilof.PodcastTranscriptSRT},
+	} {
+		if repo.FileExists(f.path) {
+			out = append(out, &ilof.TranscriptFile{URL: ilof.BaseURL + "/" + f.path, Type: f.mimeType})
+		}
+	}
+	return out
+}
+
+// transcriptPath returns the path of the transcript file of ep, relative to
+// the repository root.
+func transcriptPath(ep *ilof.Episode) string {
+	return path.Clean(strings.TrimPrefix(ep.Transcript, "/"))
+}
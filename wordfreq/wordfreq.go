@@ -0,0 +1,141 @@
+// Program wordfreq computes the frequencies of the words and phrases used in
+// the episodes recorded in the site repository, and writes them as JSON for
+// rendering word clouds.
+//
+// By default all episodes are counted together. Use -season or -year to
+// select episodes, or -episode to count a single episode:
+//
+//	wordfreq -year 2021 -n 50 -o words-2021.json
+//
+// With -by-season, a separate table is written for each season. The text of
+// each episode is its topics, summary, and detail, along with its transcript
+// if one is stored in the repository.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/creachadair/atomicfile"
+	"github.com/inlieuoffun/tools/ilof"
+	"github.com/inlieuoffun/tools/repo"
+)
+
+var (
+	outPath       = flag.String("o", "", "Write output to this file (default stdout)")
+	topN          = flag.Int("n", 100, "Number of terms to report per table (0 for all)")
+	maxPhrase     = flag.Int("phrase", 2, "Count phrases of up to this many words")
+	season        = flag.Int("season", 0, "Count only episodes in this season")
+	year          = flag.Int("year", 0, "Count only episodes aired in this year")
+	episode       = flag.String("episode", "", "Count only this episode")
+	bySeason      = flag.Bool("by-season", false, "Write a separate table for each season")
+	doTranscripts = flag.Bool("transcripts", true, "Include stored transcripts in the episode text")
+)
+
+func main() {
+	flag.Parse()
+	if err := repo.ChdirRoot(); err != nil {
+		log.Fatalf("Changing directory to repo root: %v\n(This tool requires a repository clone; use -repo to name one)", err)
+	}
+
+	opts := &ilof.FreqOptions{MaxPhrase: *maxPhrase}
+	tables := make(map[int]*ilof.WordFreq)
+	if err := ilof.ForEachEpisode(repo.EpisodeDir, func(_ string, ep *ilof.Episode) error {
+		if !wantEpisode(ep) {
+			return nil
+		}
+		key := 0
+		if *bySeason {
+			key = ep.Season
+		}
+		f := tables[key]
+		if f == nil {
+			f = ilof.NewWordFreq(opts)
+			tables[key] = f
+		}
+		f.Add(episodeText(ep))
+		return nil
+	}); err != nil {
+		log.Fatalf("Loading episodes: %v", err)
+	}
+	if len(tables) == 0 {
+		log.Fatal("No matching episodes found")
+	}
+
+	var out any
+	if *bySeason {
+		var keys []int
+		for key := range tables {
+			keys = append(keys, key)
+		}
+		sort.Ints(keys)
+		var clouds []*ilof.WordCloud
+		for _, key := range keys {
+			clouds = append(clouds, tables[key].Cloud(fmt.Sprintf("Season %d", key), *topN))
+		}
+		out = clouds
+	} else {
+		f := tables[0]
+		log.Printf("Counted %d episodes", f.Len())
+		out = f.Cloud(title(), *topN)
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		log.Fatalf("Encoding output: %v", err)
+	}
+	data = append(data, '\n')
+	if *outPath == "" {
+		os.Stdout.Write(data)
+	} else if err := atomicfile.WriteData(*outPath, data, 0644); err != nil {
+		log.Fatalf("Writing output: %v", err)
+	}
+}
+
+// wantEpisode reports whether ep is selected by the flags.
+func wantEpisode(ep *ilof.Episode) bool {
+	switch {
+	case *episode != "" && string(ep.Episode) != *episode:
+		return false
+	case *season != 0 && ep.Season != *season:
+		return false
+	case *year != 0 && time.Time(ep.Date).Year() != *year:
+		return false
+	}
+	return true
+}
+
+// episodeText returns the text of ep to be counted.
+func episodeText(ep *ilof.Episode) string {
+	text := strings.Join([]string{ep.Topics, ep.Summary, ep.Detail}, "\n")
+	if *doTranscripts {
+		t, err := ilof.LoadEpisodeTranscript(ep)
+		if err != nil {
+			log.Printf("* Episode %s: %v", ep.Episode, err)
+		} else if t != nil {
+			text += "\n" + t.Text()
+		}
+	}
+	return text
+}
+
+// title returns a title for a single table describing the selected episodes.
+func title() string {
+	switch {
+	case *episode != "":
+		return "Episode " + *episode
+	case *season != 0 && *year != 0:
+		return fmt.Sprintf("Season %d, %d", *season, *year)
+	case *season != 0:
+		return fmt.Sprintf("Season %d", *season)
+	case *year != 0:
+		return fmt.Sprintf("Most said words in %d", *year)
+	}
+	return "All episodes"
+}